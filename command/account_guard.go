@@ -0,0 +1,45 @@
+package command
+
+import (
+	"context"
+	"fmt"
+	"log"
+
+	"github.com/minamijoyo/tfmigrate/guard"
+	"github.com/minamijoyo/tfmigrate/tfmigrate"
+)
+
+// checkAccountGuard verifies that the active cloud credentials target the
+// expected account in the account_guard block before a new state is pushed.
+// It's a no-op if the block is not set or in dry-run mode, where nothing is
+// pushed.
+func (m *Meta) checkAccountGuard(ctx context.Context) error {
+	if m.config == nil || m.config.AccountGuard == nil {
+		return nil
+	}
+	if m.Option != nil && m.Option.DryRun {
+		log.Printf("[INFO] [command] skip account guard in dry-run mode\n")
+		return nil
+	}
+	return m.config.AccountGuard.Check(ctx, guard.NewIdentityResolver())
+}
+
+// checkAccountGuardEnv returns an error if a given migration overrides the
+// credentials checked by a given account guard with environment variables
+// for terraform, because the guard only verifies the credentials of the
+// current process. It's a no-op if the guard is not set.
+func checkAccountGuardEnv(g *guard.Config, mc *tfmigrate.MigrationConfig) error {
+	if g == nil {
+		return nil
+	}
+	envs := []map[string]string{mc.Env}
+	if c, ok := mc.Migrator.(*tfmigrate.MultiStateMigratorConfig); ok {
+		envs = append(envs, c.FromEnv, c.ToEnv)
+	}
+	for _, env := range envs {
+		if err := g.CheckEnv(env); err != nil {
+			return fmt.Errorf("%s in migration: %s", err, mc.Name)
+		}
+	}
+	return nil
+}
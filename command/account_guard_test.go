@@ -0,0 +1,87 @@
+package command
+
+import (
+	"testing"
+
+	"github.com/minamijoyo/tfmigrate/guard"
+	"github.com/minamijoyo/tfmigrate/tfmigrate"
+)
+
+func TestCheckAccountGuardEnv(t *testing.T) {
+	cases := []struct {
+		desc  string
+		guard *guard.Config
+		mc    *tfmigrate.MigrationConfig
+		ok    bool
+	}{
+		{
+			desc:  "no guard",
+			guard: nil,
+			mc: &tfmigrate.MigrationConfig{
+				Type:     "state",
+				Name:     "test",
+				Migrator: &tfmigrate.StateMigratorConfig{},
+				Env:      map[string]string{"AWS_PROFILE": "dev"},
+			},
+			ok: true,
+		},
+		{
+			desc:  "no credentials env",
+			guard: &guard.Config{AWSAccountID: "123456789012"},
+			mc: &tfmigrate.MigrationConfig{
+				Type:     "state",
+				Name:     "test",
+				Migrator: &tfmigrate.StateMigratorConfig{},
+				Env:      map[string]string{"TF_LOG": "DEBUG"},
+			},
+			ok: true,
+		},
+		{
+			desc:  "env",
+			guard: &guard.Config{AWSAccountID: "123456789012"},
+			mc: &tfmigrate.MigrationConfig{
+				Type:     "state",
+				Name:     "test",
+				Migrator: &tfmigrate.StateMigratorConfig{},
+				Env:      map[string]string{"AWS_PROFILE": "dev"},
+			},
+			ok: false,
+		},
+		{
+			desc:  "from_env",
+			guard: &guard.Config{AWSAccountID: "123456789012"},
+			mc: &tfmigrate.MigrationConfig{
+				Type: "multi_state",
+				Name: "test",
+				Migrator: &tfmigrate.MultiStateMigratorConfig{
+					FromEnv: map[string]string{"AWS_ROLE_ARN": "arn:aws:iam::210987654321:role/foo"},
+				},
+			},
+			ok: false,
+		},
+		{
+			desc:  "to_env",
+			guard: &guard.Config{GCPProject: "prod"},
+			mc: &tfmigrate.MigrationConfig{
+				Type: "multi_state",
+				Name: "test",
+				Migrator: &tfmigrate.MultiStateMigratorConfig{
+					ToEnv: map[string]string{"GOOGLE_PROJECT": "dev"},
+				},
+			},
+			ok: false,
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.desc, func(t *testing.T) {
+			err := checkAccountGuardEnv(tc.guard, tc.mc)
+			if tc.ok && err != nil {
+				t.Fatalf("unexpected err: %s", err)
+			}
+			if !tc.ok && err == nil {
+				t.Fatal("expected to return an error, but no error")
+			}
+		})
+	}
+}
@@ -2,10 +2,13 @@ package command
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"log"
 	"strings"
+	"time"
 
+	"github.com/minamijoyo/tfmigrate/tfmigrate"
 	flag "github.com/spf13/pflag"
 )
 
@@ -13,6 +16,25 @@ import (
 type ApplyCommand struct {
 	Meta
 	backendConfig []string
+	planFile      string
+	dryRun        bool
+	dryRunDir     string
+	stateOut      string
+	report        string
+	reportFormat  string
+	max           int
+	workDir       string
+	initTimeout   time.Duration
+	planTimeout   time.Duration
+	parallelism   int
+	skipInit      bool
+	pushTimeout   time.Duration
+	noHistory     bool
+	diagnostics   bool
+	showStateList string
+	name          string
+	only          []string
+	idempotent    bool
 }
 
 // Run runs the procedure of this command.
@@ -20,35 +42,142 @@ func (c *ApplyCommand) Run(args []string) int {
 	cmdFlags := flag.NewFlagSet("apply", flag.ContinueOnError)
 	cmdFlags.StringVar(&c.configFile, "config", defaultConfigFile, "A path to tfmigrate config file")
 	cmdFlags.StringArrayVar(&c.backendConfig, "backend-config", nil, "A backend configuration for remote state")
+	cmdFlags.BoolVar(&c.color, "color", false, "Force colored output")
+	cmdFlags.BoolVar(&c.noColor, "no-color", false, "Disable colored output")
+	cmdFlags.StringVar(&c.logLevel, "log-level", "", "A minimum log level")
+	cmdFlags.BoolVar(&c.quiet, "quiet", false, "Suppress log output unless failed")
+	cmdFlags.BoolVarP(&c.verbose, "verbose", "v", false, "Show terraform commands executed")
+	cmdFlags.BoolVar(&c.readOnly, "read-only", false, "Refuse any mutation of state and history")
+	cmdFlags.StringVar(&c.planFile, "plan-file", "", "A path to a plan file saved by plan --out to verify instead of running a new plan")
+	cmdFlags.BoolVar(&c.dryRun, "dry-run", false, "Run apply without pushing new states to remote nor saving history")
+	cmdFlags.StringVar(&c.dryRunDir, "dry-run-dir", "", "Save new states on --dry-run to a new directory under the given path")
+	cmdFlags.StringVar(&c.stateOut, "state-out", "", "Write a new state to the given path instead of pushing it to remote")
+	cmdFlags.IntVar(&c.max, "max", 0, "Apply at most N unapplied migrations")
+	cmdFlags.StringVar(&c.report, "report", "", "Write a summary report of the run in JSON to the given path")
+	cmdFlags.StringVar(&c.reportFormat, "report-format", reportFormatJSON, "A format of the report")
+	cmdFlags.StringVar(&c.workDir, "work-dir", "", "A base directory where terraform commands are executed")
+	cmdFlags.DurationVar(&c.initTimeout, "init-timeout", 0, "A timeout for each terraform init")
+	cmdFlags.DurationVar(&c.planTimeout, "plan-timeout", 0, "A timeout for each terraform plan")
+	cmdFlags.IntVar(&c.parallelism, "parallelism", 0, "Limit the number of concurrent operations of terraform plan")
+	cmdFlags.BoolVar(&c.skipInit, "skip-init", false, "Assume working dirs are already initialized and skip terraform init")
+	cmdFlags.DurationVar(&c.pushTimeout, "push-timeout", 0, "A timeout for each terraform state push")
+	cmdFlags.BoolVar(&c.noHistory, "no-history", false, "Run a given migration file without reading nor writing history")
+	cmdFlags.BoolVar(&c.diagnostics, "diagnostics", false, "Show a summary of warnings and errors reported by terraform plan")
+	cmdFlags.StringVar(&c.showStateList, "show-state-list", "", "Show a list of resource addresses in remote states after push")
+	cmdFlags.Lookup("show-state-list").NoOptDefVal = "all"
+	cmdFlags.StringVar(&c.name, "name", "", "Apply a migration whose block declares a given name")
+	cmdFlags.StringArrayVar(&c.only, "only", nil, "Apply only actions which change addresses matching a given pattern")
+	cmdFlags.BoolVar(&c.idempotent, "idempotent", false, "Record a migration as applied without running it if the state already reflects it")
 
 	if err := cmdFlags.Parse(args); err != nil {
 		c.UI.Error(fmt.Sprintf("failed to parse arguments: %s", err))
 		return 1
 	}
+	if err := errors.Join(checkTimeout("init-timeout", c.initTimeout), checkTimeout("plan-timeout", c.planTimeout), checkTimeout("push-timeout", c.pushTimeout), validateReportFormat(c.reportFormat)); err != nil {
+		c.UI.Error(err.Error())
+		return 1
+	}
+	if c.showStateList != "" && c.showStateList != "all" && c.showStateList != "diff" {
+		c.UI.Error(fmt.Sprintf("The --show-state-list option must be all or diff: %s", c.showStateList))
+		c.UI.Error(c.Help())
+		return 1
+	}
+	if c.parallelism < 0 {
+		c.UI.Error(fmt.Sprintf("The --parallelism option must not be negative: %d", c.parallelism))
+		return 1
+	}
+	if len(c.only) != 0 && len(c.planFile) != 0 {
+		c.UI.Error("The --only option cannot be used with --plan-file")
+		c.UI.Error(c.Help())
+		return 1
+	}
+	if len(c.dryRunDir) != 0 && !c.dryRun {
+		c.UI.Error("The --dry-run-dir option requires --dry-run")
+		c.UI.Error(c.Help())
+		return 1
+	}
+	if len(c.name) != 0 && len(cmdFlags.Args()) != 0 {
+		c.UI.Error("The --name option cannot be used with a migration file argument")
+		c.UI.Error(c.Help())
+		return 1
+	}
+	if err := c.setLogLevel(""); err != nil {
+		c.UI.Error(fmt.Sprintf("failed to set log level: %s", err))
+		return 1
+	}
+	c.UI = newColoredUI(c.UI, useColor(c.color, c.noColor))
 
 	var err error
 	if c.config, err = newConfig(c.configFile); err != nil {
 		c.UI.Error(fmt.Sprintf("failed to load config file: %s", err))
 		return 1
 	}
+	if err = c.setLogLevel(c.config.LogLevel); err != nil {
+		c.UI.Error(fmt.Sprintf("failed to set log level: %s", err))
+		return 1
+	}
+	if err = c.Redactor.AddPatterns(c.config.RedactPatterns); err != nil {
+		c.UI.Error(fmt.Sprintf("failed to add redact patterns: %s", err))
+		return 1
+	}
 	log.Printf("[DEBUG] [command] config: %#v\n", c.config)
 
 	c.Option = newOption()
 	c.Option.BackendConfig = c.backendConfig
+	c.Option.PlanFile = c.planFile
+	// --state-out implies --dry-run, which doesn't push nor save history.
+	c.Option.DryRun = c.dryRun || len(c.stateOut) != 0
+	c.Option.DryRunDir = c.dryRunDir
+	c.Option.StateOut = c.stateOut
+	c.Option.WorkDir = c.workDir
+	c.Option.InitTimeout = c.initTimeout
+	c.Option.PlanTimeout = c.planTimeout
+	c.Option.Parallelism = c.parallelism
+	c.Option.SkipInit = c.skipInit
+	c.Option.PushTimeout = c.pushTimeout
+	c.Option.Only = c.only
+	c.Option.Idempotent = c.idempotent
+	if c.diagnostics {
+		c.Option.DiagnosticsCollector = tfmigrate.NewDiagnosticsCollector()
+	}
+	if len(c.showStateList) != 0 {
+		c.Option.StateListCollector = tfmigrate.NewStateListCollector(c.showStateList == "diff")
+	}
+	c.setReadOnly()
+	c.setVerbose()
 	// The option may contain sensitive values such as environment variables.
 	// So logging the option set log level to DEBUG instead of INFO.
 	log.Printf("[DEBUG] [command] option: %#v\n", c.Option)
 
-	if c.config.History == nil {
+	if c.config.History == nil || c.noHistory {
 		// non-history mode
-		if len(cmdFlags.Args()) != 1 {
+		if c.config.History != nil {
+			log.Printf("[INFO] [command] no-history: skip reading and writing history\n")
+		}
+		if len(c.report) != 0 {
+			// A report is built from the data flowing through the history runner.
+			c.UI.Error("The --report option requires history mode")
+			c.UI.Error(c.Help())
+			return 1
+		}
+		if len(cmdFlags.Args()) != 1 && len(c.name) == 0 {
 			c.UI.Error(fmt.Sprintf("The command expects 1 argument, but got %d", len(cmdFlags.Args())))
 			c.UI.Error(c.Help())
 			return 1
 		}
 
-		migrationFile := cmdFlags.Arg(0)
-		if err = c.applyWithoutHistory(migrationFile); err != nil {
+		migrationFile, err := c.migrationFile(cmdFlags.Args())
+		if err != nil {
+			c.UI.Error(err.Error())
+			return 1
+		}
+		if c.max != 0 {
+			// A migration file argument is always required without history.
+			c.UI.Error("The --max option cannot be used with a migration file argument")
+			c.UI.Error(c.Help())
+			return 1
+		}
+		if err = c.runQuietly(func() error { return c.runWithHooks(func() error { return c.applyWithoutHistory(migrationFile) }) }); err != nil {
 			c.UI.Error(err.Error())
 			return 1
 		}
@@ -63,14 +192,49 @@ func (c *ApplyCommand) Run(args []string) int {
 		return 1
 	}
 
-	migrationFile := ""
-	if len(cmdFlags.Args()) == 1 {
-		// Apply a given single migration file and save it to history.
-		migrationFile = cmdFlags.Arg(0)
+	// Apply a given single migration file and save it to history if any.
+	migrationFile, err := c.migrationFile(cmdFlags.Args())
+	if err != nil {
+		c.UI.Error(err.Error())
+		return 1
+	}
+
+	if c.max < 0 {
+		c.UI.Error(fmt.Sprintf("The --max option must not be negative: %d", c.max))
+		c.UI.Error(c.Help())
+		return 1
+	}
+
+	if len(migrationFile) != 0 && c.max != 0 {
+		// A single migration is applied regardless of the limit.
+		c.UI.Error("The --max option cannot be used with a migration file argument")
+		c.UI.Error(c.Help())
+		return 1
+	}
+
+	if len(migrationFile) == 0 && len(c.planFile) != 0 {
+		// A saved plan file is only applicable to a single migration.
+		c.UI.Error("The --plan-file option requires a migration file argument")
+		c.UI.Error(c.Help())
+		return 1
+	}
+
+	if len(migrationFile) == 0 && len(c.stateOut) != 0 {
+		// A state file is written for a single migration.
+		c.UI.Error("The --state-out option requires a migration file argument")
+		c.UI.Error(c.Help())
+		return 1
+	}
+
+	if len(migrationFile) == 0 && len(c.only) != 0 {
+		// Applying all unapplied migrations partially doesn't make sense.
+		c.UI.Error("The --only option requires a migration file argument")
+		c.UI.Error(c.Help())
+		return 1
 	}
 
 	// Apply all unapplied pending migrations and save them to history.
-	if err = c.applyWithHistory(migrationFile); err != nil {
+	if err = c.runQuietly(func() error { return c.runWithHooks(func() error { return c.applyWithHistory(migrationFile) }) }); err != nil {
 		c.UI.Error(err.Error())
 		return 1
 	}
@@ -78,31 +242,81 @@ func (c *ApplyCommand) Run(args []string) int {
 	return 0
 }
 
+// migrationFile returns a migration file given by an argument or found by
+// the --name option. It returns an empty string if neither is given.
+func (c *ApplyCommand) migrationFile(args []string) (string, error) {
+	if len(c.name) == 0 {
+		if len(args) == 0 {
+			return "", nil
+		}
+		return args[0], nil
+	}
+	return findMigrationFileByName(c.config.MigrationDir, c.name)
+}
+
 // applyWithoutHistory is a helper function which applies a given migration file without history.
-func (c *ApplyCommand) applyWithoutHistory(filename string) error {
-	fr, err := NewFileRunner(filename, c.config, c.Option)
+func (c *ApplyCommand) applyWithoutHistory(filename string) (err error) {
+	ctx, span := tfmigrate.StartSpan(context.Background(), c.Option, "tfmigrate apply")
+	defer func() { tfmigrate.EndSpan(span, err) }()
+
+	if err := c.checkAccountGuard(ctx); err != nil {
+		return err
+	}
+
+	fr, err := NewFileRunner(ctx, filename, c.config, c.Option)
 	if err != nil {
+		reportProgress(c.UI, progressFailed, filename)
 		return err
 	}
 
-	return fr.Apply(context.Background())
+	err = fr.Apply(ctx)
+	if err != nil {
+		reportProgress(c.UI, progressFailed, filename)
+		reportDiagnostics(c.UI, c.Option, filename)
+		reportStateList(c.UI, c.Option, filename)
+		return err
+	}
+
+	reportProgress(c.UI, appliedStatus(c.Option), filename)
+	reportDiagnostics(c.UI, c.Option, filename)
+	reportStateList(c.UI, c.Option, filename)
+	return nil
 }
 
 // applyWithHistory is a helper function which applies all unapplied pending migrations and saves them to history.
-func (c *ApplyCommand) applyWithHistory(filename string) error {
-	ctx := context.Background()
+func (c *ApplyCommand) applyWithHistory(filename string) (err error) {
+	ctx, span := tfmigrate.StartSpan(context.Background(), c.Option, "tfmigrate apply")
+	defer func() { tfmigrate.EndSpan(span, err) }()
+
+	if err := c.checkAccountGuard(ctx); err != nil {
+		return err
+	}
+
 	hr, err := NewHistoryRunner(ctx, filename, c.config, c.Option)
 	if err != nil {
 		return err
 	}
+	hr.SetUI(c.UI)
+	hr.SetMax(c.max)
 
-	return hr.Apply(ctx)
+	err = hr.Apply(ctx)
+	if len(c.report) != 0 {
+		// write a report even if failed to apply.
+		if rerr := hr.Report().writeFile(c.report, c.reportFormat, c.Redactor); rerr != nil {
+			if err == nil {
+				return rerr
+			}
+			return fmt.Errorf("%s, failed to apply: %v", rerr, err)
+		}
+		log.Printf("[INFO] [command] write a report to %s\n", c.report)
+	}
+	return err
 }
 
 // Help returns long-form help text.
 func (c *ApplyCommand) Help() string {
 	helpText := `
-Usage: tfmigrate apply [PATH]
+Usage: tfmigrate apply [PATH | --name=name]
 
 Apply computes a new state and pushes it to remote state.
 It will fail if terraform plan detects any diffs with the new state.
@@ -116,6 +330,112 @@ Options:
   --backend-config=path    A backend configuration, a path to backend configuration file or
                            key=value format backend configuraion.
                            This option is passed to terraform init when switching backend to remote.
+
+  --color                  Force colored output even if the stdout is not a terminal.
+  --no-color               Disable colored output.
+                           Colors are also disabled if the NO_COLOR environment variable is set.
+
+  --log-level=level        A minimum log level. Valid values are TRACE, DEBUG, INFO, WARN and ERROR.
+                           It takes precedence over the TFMIGRATE_LOG environment variable
+                           and the log_level in the config file.
+
+  --quiet                  Suppress log output including terraform command details and show
+                           only a progress line per migration. The log is captured and
+                           written to stderr only if failed. The log level is still respected.
+
+  -v, --verbose            Show each terraform command executed with its full arguments in stdout,
+                           such as init, state pull, state mv, plan and state push.
+                           Secrets are redacted as the same as log output.
+
+  --read-only              Refuse any mutation of state and history at the lowest level,
+                           such as terraform state push and writing to history storage.
+                           The apply fails when it would push a new state. Use it with --dry-run
+                           to see what would be applied. It can also be enabled by the
+                           TFMIGRATE_READ_ONLY environment variable.
+
+  --plan-file=path         A path to a plan file saved by plan --out.
+                           If set, verify the saved plan instead of running a new plan.
+                           It fails if the saved plan is stale or has any diffs.
+                           It's only supported for a single state migration.
+
+  --dry-run                Run apply without pushing new states to remote nor saving history.
+
+  --dry-run-dir=path       Save new states on --dry-run to a new directory under the given path
+                           for inspection. Note that they contain secrets as the same as the
+                           remote states. If not set, they are not saved.
+
+  --state-out=path         Write a new state to the given path instead of pushing it to remote
+                           for offline inspection. It implies --dry-run. The state is ready to
+                           push by tfmigrate push as it is. Note that it contains secrets as
+                           the same as the remote state. It's only supported for a single
+                           state migration. In history mode, a migration file argument is required,
+                           and it's recorded as applied by tfmigrate push --migration.
+
+  --max=N                  Apply at most N unapplied migrations in order and save them to history.
+                           Subsequent runs pick up the next ones. Default to 0 (no limit).
+                           It cannot be used with a migration file argument.
+
+  --report=path            Write a summary report of the run in JSON to the given path.
+                           It contains the filename, type, name, duration and outcome of
+                           each migration, the overall success and pending migrations
+                           after the run. It's written even if failed. Secrets in errors
+                           are masked. It's only supported in history mode.
+
+  --report-format=format   A format of the report. Valid values are json (default) and
+                           markdown. The markdown is a human-readable summary, which is
+                           friendly for a comment on a pull request by Atlantis or CI.
+
+  --work-dir=path          A base directory where terraform commands are executed.
+                           If set, the dir in a migration is resolved relative to it
+                           instead of the current directory.
+
+  --init-timeout=duration  A timeout for each terraform init such as 5m.
+  --plan-timeout=duration  A timeout for each terraform plan such as 30m.
+  --push-timeout=duration  A timeout for each terraform state push such as 5m.
+                           They take precedence over the init_timeout, plan_timeout and
+                           push_timeout in the config file. Default to no timeout.
+  --parallelism=N          Limit the number of concurrent operations of terraform plan
+                           for verification. Default to the terraform's default.
+  --skip-init              Assume working dirs are already initialized and skip terraform init.
+                           It's useful for pipelines which run terraform init separately.
+                           Note that switching the backend to local temporarily still runs
+                           terraform init -reconfigure.
+
+  --no-history             Run a given migration file without reading nor writing history,
+                           even if history is configured. A migration file argument is required.
+                           It's useful for ad-hoc migrations which are not intended to be tracked.
+
+  --diagnostics            Capture diagnostics of terraform plan for verification in JSON and
+                           show a summary of warnings and errors per migration separately
+                           from the result. It's useful to notice deprecation warnings.
+
+  --show-state-list[=mode] Show a list of resource addresses in remote states by terraform state list
+                           after pushing new states as confirmation of the migration.
+                           Added and removed addresses compared to the original states are marked
+                           with + and -. Valid modes are all and diff. Default to all.
+                           If diff, show only added and removed addresses.
+                           It's not shown with --dry-run because nothing is pushed.
+
+  --name=name              Apply a migration whose block declares a given name instead of
+                           a migration file argument. The migration file is found in the
+                           migration_dir. It fails if no migration or more than one migration
+                           has the name.
+
+  --only=pattern           Apply only actions which change addresses matching a given pattern,
+                           which has the same wildcard grammar as the source of xmv.
+                           It can be specified multiple times. It partially applies a migration,
+                           so use it with care. Changes of the skipped actions are allowed in
+                           plan for verification. In history mode, a migration file argument and
+                           resumable = true in the migration block are required,
+                           and the migration is not recorded as applied.
+                           It's only supported for a single state migration.
+
+  --idempotent             If the current state already reflects a migration, that is, the sources
+                           of all mv and xmv actions are absent and the destinations are present,
+                           skip the actions and record the migration as applied without pushing
+                           anything. The plan for verification still runs against the current state.
+                           It's useful for a migration which has been applied manually.
+                           It's only supported for a single state migration.
 `
 	return strings.TrimSpace(helpText)
 }
@@ -0,0 +1,95 @@
+package command
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/minamijoyo/tfmigrate/redact"
+	"github.com/mitchellh/cli"
+)
+
+// setupConfigFileWithBrokenHistory is a test helper for setting up a
+// temporary config file whose history storage fails to read and write.
+// It returns paths of the config file and a migration file in the
+// migration dir.
+func setupConfigFileWithBrokenHistory(t *testing.T) (string, string) {
+	migrations := map[string]string{
+		"20201109000001_test.hcl": `
+migration "mock" "test" {
+	plan_error  = false
+	apply_error = false
+}
+`,
+	}
+	migrationDir := setupMigrationDir(t, migrations)
+
+	source := fmt.Sprintf(`
+tfmigrate {
+	migration_dir = "%s"
+	history {
+		storage "mock" {
+			data        = ""
+			read_error  = true
+			write_error = true
+		}
+	}
+}
+`, migrationDir)
+	configFile := filepath.Join(migrationDir, ".tfmigrate.hcl")
+	if err := os.WriteFile(configFile, []byte(source), 0600); err != nil {
+		t.Fatalf("failed to write config file: %s", err)
+	}
+
+	return configFile, filepath.Join(migrationDir, "20201109000001_test.hcl")
+}
+
+func TestApplyCommandNoHistory(t *testing.T) {
+	cases := []struct {
+		desc      string
+		noHistory bool
+		want      int
+	}{
+		{
+			desc:      "no history",
+			noHistory: true,
+			want:      0,
+		},
+		{
+			desc:      "history",
+			noHistory: false,
+			want:      1,
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.desc, func(t *testing.T) {
+			configFile, migrationFile := setupConfigFileWithBrokenHistory(t)
+			ui := cli.NewMockUi()
+			c := &ApplyCommand{
+				Meta: Meta{
+					UI:       ui,
+					Redactor: redact.NewRedactor(),
+				},
+			}
+
+			args := []string{"--config=" + configFile}
+			if tc.noHistory {
+				args = append(args, "--no-history")
+			}
+			args = append(args, migrationFile)
+
+			// The history storage fails to read and write, so the command
+			// succeeds only if it neither reads nor writes history.
+			got := c.Run(args)
+			if got != tc.want {
+				t.Errorf("got: %d, want: %d, stderr: %s", got, tc.want, ui.ErrorWriter.String())
+			}
+			if got != 0 && !strings.Contains(ui.ErrorWriter.String(), "failed to read mock storage") {
+				t.Errorf("unexpected error: %s", ui.ErrorWriter.String())
+			}
+		})
+	}
+}
@@ -0,0 +1,174 @@
+package command
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/minamijoyo/tfmigrate/tfmigrate"
+	flag "github.com/spf13/pflag"
+)
+
+// ExpandCommand is a command which previews moves generated by an xmv action
+// against a given list of addresses without running terraform.
+type ExpandCommand struct {
+	Meta
+	source          string
+	destination     string
+	stateList       string
+	caseInsensitive bool
+	includeData     bool
+	excludes        []string
+	withinModule    string
+	regex           bool
+	singleSegment   bool
+}
+
+// Run runs the procedure of this command.
+func (c *ExpandCommand) Run(args []string) int {
+	cmdFlags := flag.NewFlagSet("expand", flag.ContinueOnError)
+	cmdFlags.StringVar(&c.source, "source", "", "A source address of xmv which can contain wildcards")
+	cmdFlags.StringVar(&c.destination, "destination", "", "A destination address of xmv which can contain placeholders")
+	cmdFlags.StringVar(&c.stateList, "state-list", "", "A path to a file of addresses in state, or - for stdin")
+	cmdFlags.BoolVar(&c.caseInsensitive, "case-insensitive", false, "Match the source case-insensitively")
+	cmdFlags.BoolVar(&c.includeData, "include-data", false, "Allow wildcards to match data sources")
+	cmdFlags.StringArrayVar(&c.excludes, "exclude", nil, "A pattern of sources to be skipped")
+	cmdFlags.StringVar(&c.withinModule, "within-module", "", "Match only resources directly in a given module")
+	cmdFlags.BoolVar(&c.regex, "regex", false, "Interpret the source and exclude patterns as regular expressions")
+	cmdFlags.BoolVar(&c.singleSegment, "single-segment", false, "Make a single wildcard match only one segment of an address")
+	cmdFlags.StringVar(&c.logLevel, "log-level", "", "A minimum log level")
+
+	if err := cmdFlags.Parse(args); err != nil {
+		c.UI.Error(fmt.Sprintf("failed to parse arguments: %s", err))
+		return 1
+	}
+	if err := c.setLogLevel(""); err != nil {
+		c.UI.Error(fmt.Sprintf("failed to set log level: %s", err))
+		return 1
+	}
+
+	if len(cmdFlags.Args()) != 0 {
+		c.UI.Error(fmt.Sprintf("The command expects no argument, but got %d", len(cmdFlags.Args())))
+		c.UI.Error(c.Help())
+		return 1
+	}
+	if len(c.source) == 0 || len(c.destination) == 0 || len(c.stateList) == 0 {
+		c.UI.Error("The --source, --destination and --state-list options are required")
+		c.UI.Error(c.Help())
+		return 1
+	}
+
+	var r io.Reader = os.Stdin
+	if c.stateList != "-" {
+		f, err := os.Open(c.stateList)
+		if err != nil {
+			c.UI.Error(fmt.Sprintf("failed to read state list: %s", err))
+			return 1
+		}
+		defer f.Close()
+		r = f
+	}
+
+	out, err := expandXmv(r, c.xmvArgs())
+	if err != nil {
+		c.UI.Error(err.Error())
+		return 1
+	}
+	c.UI.Output(out)
+	return 0
+}
+
+// xmvArgs returns arguments of xmv action built from the flags.
+func (c *ExpandCommand) xmvArgs() []string {
+	args := []string{}
+	if c.caseInsensitive {
+		args = append(args, "--case-insensitive")
+	}
+	if c.includeData {
+		args = append(args, "--include-data")
+	}
+	for _, e := range c.excludes {
+		args = append(args, "--exclude="+e)
+	}
+	if len(c.withinModule) > 0 {
+		args = append(args, "--within-module="+c.withinModule)
+	}
+	if c.regex {
+		args = append(args, "--regex")
+	}
+	if c.singleSegment {
+		args = append(args, "--single-segment")
+	}
+	return append(args, c.source, c.destination)
+}
+
+// expandXmv reads a list of addresses from a given reader, one per line as
+// the output of terraform state list, and returns moves generated by xmv
+// with given args in the form of `<source> -> <destination>` per line.
+func expandXmv(r io.Reader, args []string) (string, error) {
+	stateList := []string{}
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		address := strings.TrimSpace(scanner.Text())
+		if len(address) == 0 {
+			continue
+		}
+		stateList = append(stateList, address)
+	}
+	if err := scanner.Err(); err != nil {
+		return "", fmt.Errorf("failed to read state list: %s", err)
+	}
+
+	moves, err := tfmigrate.ExpandXmv(args, stateList)
+	if err != nil {
+		return "", err
+	}
+	if len(moves) == 0 {
+		return "No addresses matched", nil
+	}
+
+	lines := make([]string, len(moves))
+	for i, m := range moves {
+		lines[i] = fmt.Sprintf("%s -> %s", m.Source, m.Destination)
+	}
+	return strings.Join(lines, "\n"), nil
+}
+
+// Help returns long-form help text.
+func (c *ExpandCommand) Help() string {
+	helpText := `
+Usage: tfmigrate expand [options]
+
+Expand previews moves generated by an xmv action against a given list of
+addresses in state without running terraform. It's useful for authoring
+and debugging wildcard patterns, and requires no backend access.
+Each move is printed in the form of <source> -> <destination>.
+
+Options:
+  --source=pattern         A source address of xmv which can contain wildcards.
+  --destination=pattern    A destination address of xmv which can contain placeholders.
+  --state-list=path        A path to a file of addresses in state, one per line,
+                           such as the output of terraform state list. Use - for stdin.
+
+  --case-insensitive       Match the source case-insensitively.
+  --include-data           Allow wildcards to match data sources.
+  --exclude=pattern        A pattern of sources to be skipped. It can be specified multiple times.
+  --within-module=module   Match only resources directly in a given module such as module.network,
+                           not in the other modules nor its child modules.
+  --regex                  Interpret the source and exclude patterns as regular expressions.
+                           Named capture groups can be referenced in the destination by ${name}.
+  --single-segment         Make a single wildcard match only one segment of an address,
+                           not across dots. Use ** to match across dots.
+
+  --log-level=level        A minimum log level. Valid values are TRACE, DEBUG, INFO, WARN and ERROR.
+                           It takes precedence over the TFMIGRATE_LOG environment variable.
+`
+	return strings.TrimSpace(helpText)
+}
+
+// Synopsis returns one-line help text.
+func (c *ExpandCommand) Synopsis() string {
+	return "Preview moves generated by xmv without running terraform"
+}
@@ -0,0 +1,99 @@
+package command
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestExpandXmv(t *testing.T) {
+	stateList := `
+aws_security_group.foo
+aws_security_group.bar
+aws_instance.web
+data.aws_ami.ubuntu
+`
+	cases := []struct {
+		desc string
+		args []string
+		want string
+		ok   bool
+	}{
+		{
+			desc: "wildcard",
+			args: []string{"aws_security_group.*", "aws_security_group.${1}2"},
+			want: `aws_security_group.foo -> aws_security_group.foo2
+aws_security_group.bar -> aws_security_group.bar2`,
+			ok: true,
+		},
+		{
+			desc: "with flags",
+			args: []string{"--include-data", "--exclude=aws_security_group.*", "*", "module.app.$1"},
+			want: `aws_instance.web -> module.app.aws_instance.web
+data.aws_ami.ubuntu -> module.app.data.aws_ami.ubuntu`,
+			ok: true,
+		},
+		{
+			desc: "no match",
+			args: []string{"aws_s3_bucket.*", "module.s3.aws_s3_bucket.$1"},
+			want: "No addresses matched",
+			ok:   true,
+		},
+		{
+			desc: "invalid args",
+			args: []string{"--foo", "aws_instance.*", "module.app.aws_instance.$1"},
+			want: "",
+			ok:   false,
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.desc, func(t *testing.T) {
+			got, err := expandXmv(strings.NewReader(stateList), tc.args)
+			if tc.ok && err != nil {
+				t.Fatalf("unexpected err: %s", err)
+			}
+			if !tc.ok && err == nil {
+				t.Fatalf("expected to return an error, but no error, got: %s", got)
+			}
+			if got != tc.want {
+				t.Errorf("got:\n%s\nwant:\n%s", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestExpandXmvWithinModule(t *testing.T) {
+	stateList := `
+aws_subnet.a
+module.network.aws_subnet.a
+module.network.module.child.aws_subnet.a
+module.app.aws_subnet.a
+`
+	args := []string{"--within-module=module.network", "module.*.aws_subnet.*", "module.vpc.aws_subnet.$2"}
+	got, err := expandXmv(strings.NewReader(stateList), args)
+	if err != nil {
+		t.Fatalf("unexpected err: %s", err)
+	}
+	want := "module.network.aws_subnet.a -> module.vpc.aws_subnet.a"
+	if got != want {
+		t.Errorf("got:\n%s\nwant:\n%s", got, want)
+	}
+}
+
+func TestExpandXmvRegex(t *testing.T) {
+	stateList := `
+aws_instance.web_0
+aws_instance.web_1
+aws_instance.web_old
+`
+	args := []string{"--regex", `aws_instance\.web_(?P<index>[0-9]+)`, "module.web.aws_instance.this[${index}]"}
+	got, err := expandXmv(strings.NewReader(stateList), args)
+	if err != nil {
+		t.Fatalf("unexpected err: %s", err)
+	}
+	want := `aws_instance.web_0 -> module.web.aws_instance.this[0]
+aws_instance.web_1 -> module.web.aws_instance.this[1]`
+	if got != want {
+		t.Errorf("got:\n%s\nwant:\n%s", got, want)
+	}
+}
@@ -2,12 +2,17 @@ package command
 
 import (
 	"context"
+	"errors"
+	"fmt"
 	"log"
 	"os"
 	"path/filepath"
 
 	"github.com/minamijoyo/tfmigrate/config"
+	"github.com/minamijoyo/tfmigrate/tfexec"
 	"github.com/minamijoyo/tfmigrate/tfmigrate"
+	"github.com/zclconf/go-cty/cty"
+	"go.opentelemetry.io/otel/trace"
 )
 
 // FileRunner is a runner for a single migration file.
@@ -20,26 +25,54 @@ type FileRunner struct {
 	mc *tfmigrate.MigrationConfig
 	// A migrator instance to be run.
 	m tfmigrate.Migrator
+	// An option for the migrator.
+	option *tfmigrate.MigratorOption
 }
 
 // NewFileRunner returns a new FileRunner instance.
-func NewFileRunner(filename string, config *config.TfmigrateConfig, option *tfmigrate.MigratorOption) (*FileRunner, error) {
+// Remote states referenced by remote_state blocks in the migration file are
+// read with a given context.
+func NewFileRunner(ctx context.Context, filename string, config *config.TfmigrateConfig, option *tfmigrate.MigratorOption) (*FileRunner, error) {
 	path := resolveMigrationFile(config.MigrationDir, filename)
+	if option != nil {
+		option.IsBackendTerraformCloud = config.IsBackendTerraformCloud
+		option.TerraformVersionPaths = config.TerraformVersionPaths
+		option.ExecPathResolver = config.ExecPathResolver
+		option.TmpDir = config.TmpDir
+		option.DefaultDir = config.DefaultDir
+		option.PluginCacheDir = config.PluginCacheDir
+		option.Validate = config.Validate
+		option.WarningsAsErrors = config.WarningsAsErrors
+		option.AllowedWarnings = config.AllowedWarnings
+		option.StateMvWarningsAsErrors = config.StateMvWarningsAsErrors
+		option.StreamState = config.StreamState
+		option.InitArgs = config.InitArgs
+		option.LockRetry = config.LockRetry
+		// The flags take precedence over the config file.
+		if option.InitTimeout == 0 {
+			option.InitTimeout = config.InitTimeout
+		}
+		if option.PlanTimeout == 0 {
+			option.PlanTimeout = config.PlanTimeout
+		}
+		if option.PushTimeout == 0 {
+			option.PushTimeout = config.PushTimeout
+		}
+	} else {
+		option = defaultMigratorOption(config)
+	}
+
 	log.Printf("[INFO] [runner] load migration file: %s\n", path)
-	mc, err := loadMigrationFile(path)
+	mc, err := loadMigrationFile(path, config.Locals, newRemoteStateReader(ctx, option))
 	if err != nil {
 		return nil, err
 	}
 
-	if option != nil {
-		option.IsBackendTerraformCloud = config.IsBackendTerraformCloud
-	} else {
-		option = &tfmigrate.MigratorOption{
-			IsBackendTerraformCloud: false,
-		}
+	if err := checkAccountGuardEnv(config.AccountGuard, mc); err != nil {
+		return nil, err
 	}
 
-	m, err := mc.Migrator.NewMigrator(option)
+	m, err := mc.NewMigrator(option)
 
 	if err != nil {
 		return nil, err
@@ -50,19 +83,55 @@ func NewFileRunner(filename string, config *config.TfmigrateConfig, option *tfmi
 		config:   config,
 		mc:       mc,
 		m:        m,
+		option:   option,
 	}
 
 	return r, nil
 }
 
+// defaultMigratorOption returns a MigratorOption with settings in a given
+// config, which is used when no option is given.
+func defaultMigratorOption(config *config.TfmigrateConfig) *tfmigrate.MigratorOption {
+	return &tfmigrate.MigratorOption{
+		IsBackendTerraformCloud: false,
+		TerraformVersionPaths:   config.TerraformVersionPaths,
+		ExecPathResolver:        config.ExecPathResolver,
+		TmpDir:                  config.TmpDir,
+		DefaultDir:              config.DefaultDir,
+		PluginCacheDir:          config.PluginCacheDir,
+		Validate:                config.Validate,
+		WarningsAsErrors:        config.WarningsAsErrors,
+		AllowedWarnings:         config.AllowedWarnings,
+		StateMvWarningsAsErrors: config.StateMvWarningsAsErrors,
+		StreamState:             config.StreamState,
+		InitArgs:                config.InitArgs,
+		InitTimeout:             config.InitTimeout,
+		PlanTimeout:             config.PlanTimeout,
+		PushTimeout:             config.PushTimeout,
+		LockRetry:               config.LockRetry,
+	}
+}
+
+// newRemoteStateReader returns a config.RemoteStateReader which pulls a
+// remote state referenced by a remote_state block with a given context and
+// option.
+func newRemoteStateReader(ctx context.Context, option *tfmigrate.MigratorOption) config.RemoteStateReader {
+	return func(dir string, workspace string) (*tfexec.State, error) {
+		log.Printf("[INFO] [runner] read remote state: %s (workspace %s)\n", dir, workspace)
+		return tfmigrate.PullRemoteState(ctx, dir, workspace, option)
+	}
+}
+
 // loadMigrationFile is a helper function which reads and parses a migration file.
-func loadMigrationFile(filename string) (*tfmigrate.MigrationConfig, error) {
+// Given local values can be referenced in the migration file. Remote states
+// referenced by remote_state blocks are read with a given reader.
+func loadMigrationFile(filename string, locals map[string]cty.Value, reader config.RemoteStateReader) (*tfmigrate.MigrationConfig, error) {
 	source, err := os.ReadFile(filename)
 	if err != nil {
 		return nil, err
 	}
 
-	config, err := config.ParseMigrationFile(filename, source)
+	config, err := config.ParseMigrationFileWithRemoteState(filename, source, locals, reader)
 	if err != nil {
 		return nil, err
 	}
@@ -70,14 +139,111 @@ func loadMigrationFile(filename string) (*tfmigrate.MigrationConfig, error) {
 	return config, nil
 }
 
+// loadMigrationFileHeader is a helper function which reads a migration file
+// and parses only the type and name of the migration block.
+func loadMigrationFileHeader(filename string) (string, string, error) {
+	source, err := os.ReadFile(filename)
+	if err != nil {
+		return "", "", err
+	}
+
+	return config.ParseMigrationFileHeader(filename, source)
+}
+
 // Plan plans a single migration.
-func (r *FileRunner) Plan(ctx context.Context) error {
-	return r.m.Plan(ctx)
+func (r *FileRunner) Plan(ctx context.Context) (err error) {
+	ctx, span := r.startSpan(ctx)
+	defer func() { tfmigrate.EndSpan(span, err) }()
+
+	return r.withTimeout(ctx, r.m.Plan)
 }
 
 // Apply applies a single migration.
-func (r *FileRunner) Apply(ctx context.Context) error {
-	return r.m.Apply(ctx)
+func (r *FileRunner) Apply(ctx context.Context) (err error) {
+	ctx, span := r.startSpan(ctx)
+	defer func() { tfmigrate.EndSpan(span, err) }()
+
+	return r.withTimeout(ctx, r.m.Apply)
+}
+
+// errVerifyNotSupported is returned by Verify if a migrator doesn't implement
+// the Verifier interface.
+var errVerifyNotSupported = errors.New("verify is not supported for the migration type")
+
+// Verify re-validates a single applied migration against the current remote
+// state and returns a result. Given runners of migrations applied after it
+// are replayed to follow resources moved again by them.
+func (r *FileRunner) Verify(ctx context.Context, later []*FileRunner) (_ *tfmigrate.VerifyResult, err error) {
+	v, ok := r.m.(tfmigrate.Verifier)
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", errVerifyNotSupported, r.mc.Type)
+	}
+
+	ctx, span := r.startSpan(ctx)
+	defer func() { tfmigrate.EndSpan(span, err) }()
+
+	migrators := make([]tfmigrate.Migrator, 0, len(later))
+	for _, l := range later {
+		migrators = append(migrators, l.m)
+	}
+
+	var result *tfmigrate.VerifyResult
+	err = r.withTimeout(ctx, func(ctx context.Context) error {
+		var verr error
+		result, verr = v.Verify(ctx, migrators)
+		return verr
+	})
+	return result, err
+}
+
+// errMovedBlocksNotSupported is returned by MovedBlocks if a migrator doesn't
+// implement the MovedBlockGenerator interface.
+var errMovedBlocksNotSupported = errors.New("generate-moved is not supported for the migration type")
+
+// MovedBlocks returns a list of terraform moved blocks equivalent to a single
+// migration.
+func (r *FileRunner) MovedBlocks(ctx context.Context) (_ []tfmigrate.MovedBlock, err error) {
+	g, ok := r.m.(tfmigrate.MovedBlockGenerator)
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", errMovedBlocksNotSupported, r.mc.Type)
+	}
+
+	ctx, span := r.startSpan(ctx)
+	defer func() { tfmigrate.EndSpan(span, err) }()
+
+	var blocks []tfmigrate.MovedBlock
+	err = r.withTimeout(ctx, func(ctx context.Context) error {
+		var gerr error
+		blocks, gerr = g.MovedBlocks(ctx)
+		return gerr
+	})
+	return blocks, err
+}
+
+// withTimeout runs a given function with the timeout of the migration.
+// If the timeout is zero, it just runs the function.
+func (r *FileRunner) withTimeout(ctx context.Context, f func(context.Context) error) error {
+	if r.mc.Timeout == 0 {
+		return f(ctx)
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, r.mc.Timeout)
+	defer cancel()
+
+	err := f(ctx)
+	if err != nil && errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		return fmt.Errorf("migration %s timed out after %s: %s", r.filename, r.mc.Timeout, err)
+	}
+	return err
+}
+
+// startSpan starts a span of the migration.
+func (r *FileRunner) startSpan(ctx context.Context) (context.Context, trace.Span) {
+	return tfmigrate.StartSpan(ctx, r.option, "migration",
+		tfmigrate.AttrMigrationFile.String(r.filename),
+		tfmigrate.AttrMigrationName.String(r.mc.Name),
+		tfmigrate.AttrMigrationType.String(r.mc.Type),
+	)
 }
 
 // MigrationConfig returns an instance of migration.
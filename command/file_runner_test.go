@@ -2,10 +2,14 @@ package command
 
 import (
 	"context"
+	"errors"
 	"reflect"
 	"testing"
+	"time"
 
 	"github.com/minamijoyo/tfmigrate/config"
+	"github.com/minamijoyo/tfmigrate/history"
+	"github.com/minamijoyo/tfmigrate/storage/mock"
 	"github.com/minamijoyo/tfmigrate/tfmigrate"
 )
 
@@ -40,7 +44,7 @@ migration "mock" "test" {
 		t.Run(tc.desc, func(t *testing.T) {
 			path := setupMigrationFile(t, tc.source)
 
-			got, err := loadMigrationFile(path)
+			got, err := loadMigrationFile(path, nil, nil)
 			if tc.ok && err != nil {
 				t.Fatalf("unexpected err: %s", err)
 			}
@@ -90,7 +94,7 @@ migration "mock" "test" {
 			path := setupMigrationFile(t, tc.source)
 
 			config := config.NewDefaultConfig()
-			r, err := NewFileRunner(path, config, nil)
+			r, err := NewFileRunner(context.Background(), path, config, nil)
 			if err != nil {
 				t.Fatalf("failed to new file runner: %s", err)
 			}
@@ -150,7 +154,7 @@ migration "mock" "test" {
 			path := setupMigrationFile(t, tc.source)
 
 			config := config.NewDefaultConfig()
-			r, err := NewFileRunner(path, config, nil)
+			r, err := NewFileRunner(context.Background(), path, config, nil)
 			if err != nil {
 				t.Fatalf("failed to new file runner: %s", err)
 			}
@@ -165,3 +169,97 @@ migration "mock" "test" {
 		})
 	}
 }
+
+func TestFileRunnerWithHistoryConfig(t *testing.T) {
+	source := `
+migration "mock" "test" {
+	plan_error  = false
+	apply_error = false
+}
+`
+	path := setupMigrationFile(t, source)
+
+	// The file runner is used for --no-history, so it must neither read nor
+	// write history even if it's configured.
+	mockConfig := &mock.Config{
+		Data:       "",
+		WriteError: true,
+		ReadError:  true,
+	}
+	config := config.NewDefaultConfig()
+	config.History = &history.Config{
+		Storage: mockConfig,
+	}
+	r, err := NewFileRunner(context.Background(), path, config, nil)
+	if err != nil {
+		t.Fatalf("failed to new file runner: %s", err)
+	}
+
+	if err := r.Plan(context.Background()); err != nil {
+		t.Fatalf("failed to plan: %s", err)
+	}
+	if err := r.Apply(context.Background()); err != nil {
+		t.Fatalf("failed to apply: %s", err)
+	}
+
+	if mockConfig.Storage() != nil {
+		t.Errorf("expected not to access the history storage, but got: %#v", mockConfig.Storage())
+	}
+}
+
+func TestFileRunnerWithTimeout(t *testing.T) {
+	cases := []struct {
+		desc    string
+		timeout time.Duration
+		f       func(ctx context.Context) error
+		want    string
+		ok      bool
+	}{
+		{
+			desc:    "no timeout",
+			timeout: 0,
+			f: func(ctx context.Context) error {
+				if _, ok := ctx.Deadline(); ok {
+					return errors.New("unexpected deadline")
+				}
+				return nil
+			},
+			ok: true,
+		},
+		{
+			desc:    "finished in time",
+			timeout: time.Hour,
+			f:       func(_ context.Context) error { return nil },
+			ok:      true,
+		},
+		{
+			desc:    "timed out",
+			timeout: time.Millisecond,
+			f: func(ctx context.Context) error {
+				<-ctx.Done()
+				return ctx.Err()
+			},
+			want: "migration foo.hcl timed out after 1ms: context deadline exceeded",
+			ok:   false,
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.desc, func(t *testing.T) {
+			r := &FileRunner{
+				filename: "foo.hcl",
+				mc:       &tfmigrate.MigrationConfig{Timeout: tc.timeout},
+			}
+			err := r.withTimeout(context.Background(), tc.f)
+			if tc.ok && err != nil {
+				t.Fatalf("unexpected err: %s", err)
+			}
+			if !tc.ok && err == nil {
+				t.Fatal("expected to return an error, but no error")
+			}
+			if !tc.ok && err.Error() != tc.want {
+				t.Errorf("got: %s, want: %s", err, tc.want)
+			}
+		})
+	}
+}
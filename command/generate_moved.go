@@ -0,0 +1,126 @@
+package command
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+
+	"github.com/minamijoyo/tfmigrate/tfmigrate"
+	flag "github.com/spf13/pflag"
+)
+
+// GenerateMovedCommand is a command which prints terraform moved blocks
+// equivalent to a given migration.
+type GenerateMovedCommand struct {
+	Meta
+	backendConfig []string
+	skipInit      bool
+}
+
+// Run runs the procedure of this command.
+func (c *GenerateMovedCommand) Run(args []string) int {
+	cmdFlags := flag.NewFlagSet("generate-moved", flag.ContinueOnError)
+	cmdFlags.StringVar(&c.configFile, "config", defaultConfigFile, "A path to tfmigrate config file")
+	cmdFlags.StringArrayVar(&c.backendConfig, "backend-config", nil, "A backend configuration for remote state")
+	cmdFlags.StringVar(&c.logLevel, "log-level", "", "A minimum log level")
+	cmdFlags.BoolVar(&c.skipInit, "skip-init", false, "Assume working dirs are already initialized and skip terraform init")
+
+	if err := cmdFlags.Parse(args); err != nil {
+		c.UI.Error(fmt.Sprintf("failed to parse arguments: %s", err))
+		return 1
+	}
+	if len(cmdFlags.Args()) != 1 {
+		c.UI.Error(fmt.Sprintf("The command expects 1 argument, but got %d", len(cmdFlags.Args())))
+		c.UI.Error(c.Help())
+		return 1
+	}
+	if err := c.setLogLevel(""); err != nil {
+		c.UI.Error(fmt.Sprintf("failed to set log level: %s", err))
+		return 1
+	}
+
+	var err error
+	if c.config, err = newConfig(c.configFile); err != nil {
+		c.UI.Error(fmt.Sprintf("failed to load config file: %s", err))
+		return 1
+	}
+	if err = c.setLogLevel(c.config.LogLevel); err != nil {
+		c.UI.Error(fmt.Sprintf("failed to set log level: %s", err))
+		return 1
+	}
+	if err = c.Redactor.AddPatterns(c.config.RedactPatterns); err != nil {
+		c.UI.Error(fmt.Sprintf("failed to add redact patterns: %s", err))
+		return 1
+	}
+	log.Printf("[DEBUG] [command] config: %#v\n", c.config)
+
+	c.Option = newOption()
+	c.Option.BackendConfig = c.backendConfig
+	c.Option.SkipInit = c.skipInit
+	c.setReadOnly()
+	// The option may contain sensitive values such as environment variables.
+	// So logging the option set log level to DEBUG instead of INFO.
+	log.Printf("[DEBUG] [command] option: %#v\n", c.Option)
+
+	var blocks []tfmigrate.MovedBlock
+	if err := c.runWithHooks(func() error {
+		var gerr error
+		blocks, gerr = c.generate(cmdFlags.Arg(0))
+		return gerr
+	}); err != nil {
+		c.UI.Error(err.Error())
+		return 1
+	}
+
+	if len(blocks) == 0 {
+		c.UI.Error("No moves found in the migration")
+		return 0
+	}
+	c.UI.Output(strings.TrimSuffix(tfmigrate.FormatMovedBlocks(blocks), "\n"))
+	return 0
+}
+
+// generate returns moved blocks equivalent to a given migration file.
+func (c *GenerateMovedCommand) generate(filename string) (_ []tfmigrate.MovedBlock, err error) {
+	ctx, span := tfmigrate.StartSpan(context.Background(), c.Option, "tfmigrate generate-moved")
+	defer func() { tfmigrate.EndSpan(span, err) }()
+
+	fr, err := NewFileRunner(ctx, filename, c.config, c.Option)
+	if err != nil {
+		return nil, err
+	}
+	return fr.MovedBlocks(ctx)
+}
+
+// Help returns long-form help text.
+func (c *GenerateMovedCommand) Help() string {
+	helpText := `
+Usage: tfmigrate generate-moved [options] PATH
+
+Print terraform moved blocks equivalent to a given migration file, so that
+they can be committed to configuration instead of or in addition to running
+the migration. Only mv and xmv actions of a state migration are supported.
+The current remote state is read only if an xmv action contains wildcards.
+It's read-only and never changes any state nor history.
+
+Arguments:
+  PATH                     A path of migration file
+
+Options:
+  --config                 A path to tfmigrate config file
+  --backend-config=path    A backend configuration, a path to backend configuration file or
+                           key=value format backend configuraion.
+                           This option is passed to terraform init.
+  --log-level=level        A minimum log level. Valid values are TRACE, DEBUG, INFO, WARN and ERROR.
+                           It takes precedence over the TFMIGRATE_LOG environment variable
+                           and the log_level in the config file.
+  --skip-init              Assume working dirs are already initialized and skip terraform init.
+`
+	return strings.TrimSpace(helpText)
+}
+
+// Synopsis returns one-line help text.
+func (c *GenerateMovedCommand) Synopsis() string {
+	return "Print terraform moved blocks equivalent to a migration"
+}
@@ -0,0 +1,231 @@
+package command
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"strings"
+
+	"github.com/minamijoyo/tfmigrate/config"
+	"github.com/minamijoyo/tfmigrate/history"
+	"github.com/mitchellh/cli"
+	flag "github.com/spf13/pflag"
+)
+
+// HistoryCommand is a parent command of history subcommands.
+type HistoryCommand struct {
+	Meta
+}
+
+// Run runs the procedure of this command.
+func (c *HistoryCommand) Run(_ []string) int {
+	return cli.RunResultHelp
+}
+
+// Help returns long-form help text.
+func (c *HistoryCommand) Help() string {
+	helpText := `
+Usage: tfmigrate history <subcommand>
+
+Manage the migration history.
+
+Subcommands:
+    changelog    Show migrations applied in a given time range
+    dump         Dump the current history to stdout
+    load         Load a history from stdin and overwrite the current history
+    mark-planned Mark a migration as planned in history without applying it
+    show         Show a record of a given migration in history
+`
+	return strings.TrimSpace(helpText)
+}
+
+// Synopsis returns one-line help text.
+func (c *HistoryCommand) Synopsis() string {
+	return "Manage the migration history"
+}
+
+// HistoryDumpCommand is a command which dumps the current history to stdout.
+type HistoryDumpCommand struct {
+	Meta
+}
+
+// Run runs the procedure of this command.
+func (c *HistoryDumpCommand) Run(args []string) int {
+	cmdFlags := flag.NewFlagSet("history dump", flag.ContinueOnError)
+	cmdFlags.StringVar(&c.configFile, "config", defaultConfigFile, "A path to tfmigrate config file")
+
+	if err := cmdFlags.Parse(args); err != nil {
+		c.UI.Error(fmt.Sprintf("failed to parse arguments: %s", err))
+		return 1
+	}
+
+	if err := c.loadHistoryConfig(); err != nil {
+		c.UI.Error(err.Error())
+		return 1
+	}
+
+	out, err := dumpHistory(context.Background(), c.config)
+	if err != nil {
+		c.UI.Error(err.Error())
+		return 1
+	}
+	c.UI.Output(out)
+	return 0
+}
+
+// dumpHistory returns the current history in JSON.
+// A checksum error is ignored with a warning, because dumping a corrupted
+// history is useful for investigating it. The checksum is not dumped.
+func dumpHistory(ctx context.Context, config *config.TfmigrateConfig) (string, error) {
+	hcfg := *config.History
+	hcfg.IgnoreChecksumError = true
+	hc, err := history.NewController(ctx, config.MigrationDir, &hcfg)
+	if err != nil {
+		return "", err
+	}
+
+	b, err := hc.Dump()
+	if err != nil {
+		return "", err
+	}
+	return string(b), nil
+}
+
+// Help returns long-form help text.
+func (c *HistoryDumpCommand) Help() string {
+	helpText := `
+Usage: tfmigrate history dump
+
+Dump the current history to stdout in JSON.
+
+Options:
+  --config           A path to tfmigrate config file
+`
+	return strings.TrimSpace(helpText)
+}
+
+// Synopsis returns one-line help text.
+func (c *HistoryDumpCommand) Synopsis() string {
+	return "Dump the current history to stdout"
+}
+
+// HistoryLoadCommand is a command which loads a history from stdin and
+// overwrites the current history.
+type HistoryLoadCommand struct {
+	Meta
+	autoApprove bool
+	// stdin is a reader for a history. Default to os.Stdin.
+	stdin io.Reader
+}
+
+// Run runs the procedure of this command.
+func (c *HistoryLoadCommand) Run(args []string) int {
+	cmdFlags := flag.NewFlagSet("history load", flag.ContinueOnError)
+	cmdFlags.StringVar(&c.configFile, "config", defaultConfigFile, "A path to tfmigrate config file")
+	cmdFlags.BoolVar(&c.autoApprove, "auto-approve", false, "Overwrite the current history without confirmation")
+	cmdFlags.BoolVar(&c.readOnly, "read-only", false, "Refuse any write to history storage")
+
+	if err := cmdFlags.Parse(args); err != nil {
+		c.UI.Error(fmt.Sprintf("failed to parse arguments: %s", err))
+		return 1
+	}
+
+	if err := c.loadHistoryConfig(); err != nil {
+		c.UI.Error(err.Error())
+		return 1
+	}
+
+	stdin := c.stdin
+	if stdin == nil {
+		stdin = os.Stdin
+	}
+	b, err := io.ReadAll(stdin)
+	if err != nil {
+		c.UI.Error(fmt.Sprintf("failed to read a history from stdin: %s", err))
+		return 1
+	}
+
+	out, err := loadHistory(context.Background(), c.config, b, c.autoApprove)
+	if err != nil {
+		c.UI.Error(err.Error())
+		return 1
+	}
+	c.UI.Output(out)
+	return 0
+}
+
+// loadHistory validates a given history and overwrites the current history
+// with it. Since stdin is used for the history, we cannot ask a confirmation
+// interactively. If autoApprove is false, it only validates the history and
+// returns a summary of changes without saving it.
+// A checksum error of the current history is ignored with a warning, because
+// it's overwritten anyway.
+func loadHistory(ctx context.Context, config *config.TfmigrateConfig, b []byte, autoApprove bool) (string, error) {
+	hcfg := *config.History
+	hcfg.IgnoreChecksumError = true
+	hc, err := history.NewController(ctx, config.MigrationDir, &hcfg)
+	if err != nil {
+		return "", err
+	}
+
+	before := hc.HistoryLength()
+	if err := hc.Load(b); err != nil {
+		return "", err
+	}
+	after := hc.HistoryLength()
+
+	if !autoApprove {
+		return fmt.Sprintf("The history is valid and will be overwritten: %d records => %d records.\nRe-run with --auto-approve to overwrite it.", before, after), nil
+	}
+
+	log.Print("[INFO] [command] save history\n")
+	if err := hc.Save(ctx); err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("The history has been overwritten: %d records => %d records.", before, after), nil
+}
+
+// Help returns long-form help text.
+func (c *HistoryLoadCommand) Help() string {
+	helpText := `
+Usage: tfmigrate history load
+
+Load a history in JSON from stdin and overwrite the current history.
+The history is validated before overwriting it.
+Without --auto-approve, it only validates the history and shows a summary of changes.
+
+Options:
+  --config           A path to tfmigrate config file
+  --auto-approve     Overwrite the current history without confirmation
+  --read-only        Refuse any write to history storage
+`
+	return strings.TrimSpace(helpText)
+}
+
+// Synopsis returns one-line help text.
+func (c *HistoryLoadCommand) Synopsis() string {
+	return "Load a history from stdin and overwrite the current history"
+}
+
+// loadHistoryConfig loads a config file and checks if history is configured.
+func (m *Meta) loadHistoryConfig() error {
+	var err error
+	if m.config, err = newConfig(m.configFile); err != nil {
+		return fmt.Errorf("failed to load config file: %s", err)
+	}
+	if err = m.setLogLevel(m.config.LogLevel); err != nil {
+		return fmt.Errorf("failed to set log level: %s", err)
+	}
+	if err = m.Redactor.AddPatterns(m.config.RedactPatterns); err != nil {
+		return fmt.Errorf("failed to add redact patterns: %s", err)
+	}
+	log.Printf("[DEBUG] [command] config: %#v\n", m.config)
+
+	if m.config.History == nil {
+		return fmt.Errorf("no history setting")
+	}
+	m.setReadOnly()
+	return nil
+}
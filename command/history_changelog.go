@@ -0,0 +1,195 @@
+package command
+
+import (
+	"bytes"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/minamijoyo/tfmigrate/config"
+	"github.com/minamijoyo/tfmigrate/history"
+	flag "github.com/spf13/pflag"
+)
+
+// HistoryChangelogCommand is a command which shows migrations applied in a
+// given time range as a changelog.
+type HistoryChangelogCommand struct {
+	Meta
+	from   string
+	to     string
+	format string
+}
+
+// Run runs the procedure of this command.
+func (c *HistoryChangelogCommand) Run(args []string) int {
+	cmdFlags := flag.NewFlagSet("history changelog", flag.ContinueOnError)
+	cmdFlags.StringVar(&c.configFile, "config", defaultConfigFile, "A path to tfmigrate config file")
+	cmdFlags.StringVar(&c.from, "from", "", "Show migrations applied at or after the given time")
+	cmdFlags.StringVar(&c.to, "to", "", "Show migrations applied before the given time")
+	cmdFlags.StringVar(&c.format, "format", "md", "An output format")
+
+	if err := cmdFlags.Parse(args); err != nil {
+		c.UI.Error(fmt.Sprintf("failed to parse arguments: %s", err))
+		return 1
+	}
+
+	from, err := parseChangelogTime(c.from)
+	if err != nil {
+		c.UI.Error(fmt.Sprintf("failed to parse --from: %s", err))
+		return 1
+	}
+	to, err := parseChangelogTime(c.to)
+	if err != nil {
+		c.UI.Error(fmt.Sprintf("failed to parse --to: %s", err))
+		return 1
+	}
+
+	if err := c.loadHistoryConfig(); err != nil {
+		c.UI.Error(err.Error())
+		return 1
+	}
+
+	out, err := historyChangelog(context.Background(), c.config, from, to, c.format)
+	if err != nil {
+		c.UI.Error(err.Error())
+		return 1
+	}
+	c.UI.Output(out)
+	return 0
+}
+
+// changelogTimeFormats is a list of accepted time formats for --from and --to.
+var changelogTimeFormats = []string{time.RFC3339, "2006-01-02"}
+
+// parseChangelogTime parses a given time in RFC3339 or a date in UTC.
+// It returns a zero value if the given string is empty.
+func parseChangelogTime(s string) (time.Time, error) {
+	if len(s) == 0 {
+		return time.Time{}, nil
+	}
+	for _, f := range changelogTimeFormats {
+		if t, err := time.Parse(f, s); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("invalid time: %s, expected RFC3339 (e.g. 2006-01-02T15:04:05Z) or a date (e.g. 2006-01-02)", s)
+}
+
+// historyChangelog returns migrations applied in a given time range in a
+// given format.
+func historyChangelog(ctx context.Context, config *config.TfmigrateConfig, from time.Time, to time.Time, format string) (string, error) {
+	hc, err := history.NewController(ctx, config.MigrationDir, config.History)
+	if err != nil {
+		return "", err
+	}
+
+	return formatChangelog(hc.RecordsBetween(from, to), format)
+}
+
+// recordEntry is a record of history with its file name in JSON.
+type recordEntry struct {
+	Filename  string    `json:"filename"`
+	Name      string    `json:"name"`
+	Type      string    `json:"type"`
+	AppliedAt time.Time `json:"applied_at"`
+	// Duration is how long the migration took to apply.
+	// It's omitted if unknown.
+	Duration string `json:"duration,omitempty"`
+	// Status is planned if the migration is planned but not applied yet.
+	// It's omitted if applied, so it's always omitted in the changelog,
+	// which contains only applied records.
+	Status string `json:"status,omitempty"`
+}
+
+// formatDuration returns a given duration as a string, or an empty string if
+// it's zero, that is, unknown.
+func formatDuration(d time.Duration) string {
+	if d == 0 {
+		return ""
+	}
+	return d.String()
+}
+
+// formatChangelog formats given records in a given format.
+// Valid formats are csv, md and json.
+func formatChangelog(records []history.NamedRecord, format string) (string, error) {
+	header := []string{"filename", "name", "type", "applied_at"}
+	rows := [][]string{}
+	for _, r := range records {
+		rows = append(rows, []string{r.Filename, r.Name, r.Type, r.AppliedAt.UTC().Format(time.RFC3339)})
+	}
+
+	switch format {
+	case "csv":
+		var b bytes.Buffer
+		w := csv.NewWriter(&b)
+		if err := w.WriteAll(append([][]string{header}, rows...)); err != nil {
+			return "", err
+		}
+		return strings.TrimSuffix(b.String(), "\n"), nil
+
+	case "md":
+		lines := []string{
+			"| " + strings.Join(header, " | ") + " |",
+			"|" + strings.Repeat(" --- |", len(header)),
+		}
+		for _, row := range rows {
+			cells := []string{}
+			for _, cell := range row {
+				// escape a pipe not to break the table.
+				cells = append(cells, strings.ReplaceAll(cell, "|", `\|`))
+			}
+			lines = append(lines, "| "+strings.Join(cells, " | ")+" |")
+		}
+		return strings.Join(lines, "\n"), nil
+
+	case "json":
+		entries := []recordEntry{}
+		for _, r := range records {
+			entries = append(entries, recordEntry{
+				Filename:  r.Filename,
+				Name:      r.Name,
+				Type:      r.Type,
+				AppliedAt: r.AppliedAt,
+				Duration:  formatDuration(r.Duration),
+				Status:    formatStatus(r.Record),
+			})
+		}
+		b, err := json.MarshalIndent(entries, "", "  ")
+		if err != nil {
+			return "", err
+		}
+		return string(b), nil
+
+	default:
+		return "", fmt.Errorf("unknown changelog format: %s, valid formats are csv, md and json", format)
+	}
+}
+
+// Help returns long-form help text.
+func (c *HistoryChangelogCommand) Help() string {
+	helpText := `
+Usage: tfmigrate history changelog [options]
+
+Show migrations applied in a given time range as a changelog.
+The migrations are sorted by the applied timestamp.
+
+Options:
+  --config           A path to tfmigrate config file
+  --from=time        Show migrations applied at or after the given time.
+                     The time is in RFC3339 (e.g. 2006-01-02T15:04:05Z) or a date (e.g. 2006-01-02) in UTC.
+  --to=time          Show migrations applied before the given time.
+                     The time format is the same as --from.
+  --format=format    An output format. Valid values are csv, md and json.
+                     Default to md.
+`
+	return strings.TrimSpace(helpText)
+}
+
+// Synopsis returns one-line help text.
+func (c *HistoryChangelogCommand) Synopsis() string {
+	return "Show migrations applied in a given time range"
+}
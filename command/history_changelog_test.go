@@ -0,0 +1,170 @@
+package command
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/minamijoyo/tfmigrate/config"
+	"github.com/minamijoyo/tfmigrate/history"
+	"github.com/minamijoyo/tfmigrate/storage/mock"
+)
+
+func TestHistoryChangelog(t *testing.T) {
+	historyFile := `{
+    "version": 1,
+    "records": {
+        "20201109000001_test1.hcl": {
+            "type": "state",
+            "name": "test1",
+            "applied_at": "2020-11-10T00:00:01Z"
+        },
+        "20201109000002_test2.hcl": {
+            "type": "multi_state",
+            "name": "test|2",
+            "applied_at": "2020-11-11T00:00:02Z"
+        },
+        "20201109000003_test3.hcl": {
+            "type": "state",
+            "name": "test3",
+            "applied_at": "2020-11-12T00:00:03Z"
+        }
+    }
+}`
+	cases := []struct {
+		desc   string
+		from   string
+		to     string
+		format string
+		want   string
+		ok     bool
+	}{
+		{
+			desc:   "md",
+			from:   "2020-11-11",
+			format: "md",
+			want: `| filename | name | type | applied_at |
+| --- | --- | --- | --- |
+| 20201109000002_test2.hcl | test\|2 | multi_state | 2020-11-11T00:00:02Z |
+| 20201109000003_test3.hcl | test3 | state | 2020-11-12T00:00:03Z |`,
+			ok: true,
+		},
+		{
+			desc:   "csv",
+			to:     "2020-11-12T00:00:00Z",
+			format: "csv",
+			want: `filename,name,type,applied_at
+20201109000001_test1.hcl,test1,state,2020-11-10T00:00:01Z
+20201109000002_test2.hcl,test|2,multi_state,2020-11-11T00:00:02Z`,
+			ok: true,
+		},
+		{
+			desc:   "json",
+			from:   "2020-11-12",
+			format: "json",
+			want: `[
+  {
+    "filename": "20201109000003_test3.hcl",
+    "name": "test3",
+    "type": "state",
+    "applied_at": "2020-11-12T00:00:03Z"
+  }
+]`,
+			ok: true,
+		},
+		{
+			desc:   "json (empty)",
+			from:   "2021-01-01",
+			format: "json",
+			want:   `[]`,
+			ok:     true,
+		},
+		{
+			desc:   "unknown format",
+			format: "xml",
+			want:   "",
+			ok:     false,
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.desc, func(t *testing.T) {
+			config := &config.TfmigrateConfig{
+				MigrationDir: setupMigrationDir(t, map[string]string{}),
+				History: &history.Config{
+					Storage: &mock.Config{
+						Data: historyFile,
+					},
+				},
+			}
+			from, err := parseChangelogTime(tc.from)
+			if err != nil {
+				t.Fatalf("failed to parse from: %s", err)
+			}
+			to, err := parseChangelogTime(tc.to)
+			if err != nil {
+				t.Fatalf("failed to parse to: %s", err)
+			}
+
+			got, err := historyChangelog(context.Background(), config, from, to, tc.format)
+			if tc.ok && err != nil {
+				t.Fatalf("unexpected err: %s", err)
+			}
+			if !tc.ok && err == nil {
+				t.Fatalf("expected to return an error, but no error, got: %s", got)
+			}
+			if got != tc.want {
+				t.Errorf("got:\n%s\nwant:\n%s", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestParseChangelogTime(t *testing.T) {
+	cases := []struct {
+		desc string
+		s    string
+		want time.Time
+		ok   bool
+	}{
+		{
+			desc: "empty",
+			s:    "",
+			want: time.Time{},
+			ok:   true,
+		},
+		{
+			desc: "RFC3339",
+			s:    "2020-11-10T09:00:00+09:00",
+			want: time.Date(2020, 11, 10, 0, 0, 0, 0, time.UTC),
+			ok:   true,
+		},
+		{
+			desc: "date",
+			s:    "2020-11-10",
+			want: time.Date(2020, 11, 10, 0, 0, 0, 0, time.UTC),
+			ok:   true,
+		},
+		{
+			desc: "invalid",
+			s:    "yesterday",
+			want: time.Time{},
+			ok:   false,
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.desc, func(t *testing.T) {
+			got, err := parseChangelogTime(tc.s)
+			if tc.ok && err != nil {
+				t.Fatalf("unexpected err: %s", err)
+			}
+			if !tc.ok && err == nil {
+				t.Fatalf("expected to return an error, but no error, got: %s", got)
+			}
+			if !got.Equal(tc.want) {
+				t.Errorf("got: %s, want: %s", got, tc.want)
+			}
+		})
+	}
+}
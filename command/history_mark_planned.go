@@ -0,0 +1,111 @@
+package command
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+
+	"github.com/minamijoyo/tfmigrate/config"
+	"github.com/minamijoyo/tfmigrate/history"
+	flag "github.com/spf13/pflag"
+)
+
+// HistoryMarkPlannedCommand is a command which marks a given migration file
+// as planned in history without applying it.
+type HistoryMarkPlannedCommand struct {
+	Meta
+}
+
+// Run runs the procedure of this command.
+func (c *HistoryMarkPlannedCommand) Run(args []string) int {
+	cmdFlags := flag.NewFlagSet("history mark-planned", flag.ContinueOnError)
+	cmdFlags.StringVar(&c.configFile, "config", defaultConfigFile, "A path to tfmigrate config file")
+	cmdFlags.BoolVar(&c.readOnly, "read-only", false, "Refuse any write to history storage")
+
+	if err := cmdFlags.Parse(args); err != nil {
+		c.UI.Error(fmt.Sprintf("failed to parse arguments: %s", err))
+		return 1
+	}
+
+	if len(cmdFlags.Args()) != 1 {
+		c.UI.Error(fmt.Sprintf("The command expects 1 argument, but got %d", len(cmdFlags.Args())))
+		c.UI.Error(c.Help())
+		return 1
+	}
+
+	if err := c.loadHistoryConfig(); err != nil {
+		c.UI.Error(err.Error())
+		return 1
+	}
+
+	out, err := markPlanned(context.Background(), c.config, cmdFlags.Arg(0))
+	if err != nil {
+		c.UI.Error(err.Error())
+		return 1
+	}
+	c.UI.Output(out)
+	return 0
+}
+
+// markPlanned adds a planned record of a given migration file to history and
+// saves it. The filename is keyed in the same way as history mode.
+// It fails if the migration has already been applied. If it has already been
+// planned, the timestamp is updated.
+func markPlanned(ctx context.Context, config *config.TfmigrateConfig, filename string) (string, error) {
+	key, err := historyKey(config.MigrationDir, filename)
+	if err != nil {
+		return "", err
+	}
+
+	hc, err := history.NewController(ctx, config.MigrationDir, config.History)
+	if err != nil {
+		return "", err
+	}
+
+	if hc.AlreadyApplied(key) {
+		return "", fmt.Errorf("a migration has already been applied: %s", key)
+	}
+
+	// Read the migration file to record its type and name, which also checks
+	// that the migration exists. Remote states are not read, because the
+	// migration is not run.
+	migrationType, migrationName, err := loadMigrationFileHeader(resolveMigrationFile(config.MigrationDir, key))
+	if err != nil {
+		return "", err
+	}
+
+	log.Printf("[INFO] [command] add a planned record to history: %s\n", key)
+	hc.AddPlannedRecord(key, migrationType, migrationName, nil)
+
+	log.Print("[INFO] [command] save history\n")
+	if err := hc.Save(ctx); err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("The migration has been marked as planned: %s", key), nil
+}
+
+// Help returns long-form help text.
+func (c *HistoryMarkPlannedCommand) Help() string {
+	helpText := `
+Usage: tfmigrate history mark-planned [options] <FILENAME>
+
+Mark a given migration file as planned in history without applying it.
+It's useful for a two-phase approval workflow to see which migrations are queued.
+A planned migration is still unapplied, and apply marks it as applied.
+It fails if the migration has already been applied.
+
+Arguments:
+  FILENAME           A migration file name directly under the migration dir
+
+Options:
+  --config           A path to tfmigrate config file
+  --read-only        Refuse any write to history storage
+`
+	return strings.TrimSpace(helpText)
+}
+
+// Synopsis returns one-line help text.
+func (c *HistoryMarkPlannedCommand) Synopsis() string {
+	return "Mark a migration as planned in history without applying it"
+}
@@ -0,0 +1,111 @@
+package command
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/minamijoyo/tfmigrate/config"
+	"github.com/minamijoyo/tfmigrate/history"
+	"github.com/minamijoyo/tfmigrate/storage/mock"
+)
+
+func TestMarkPlanned(t *testing.T) {
+	migrations := map[string]string{
+		"20201109000001_test1.hcl": `
+migration "mock" "test1" {
+	plan_error  = false
+	apply_error = false
+}
+`,
+		"20201109000002_test2.hcl": `
+migration "mock" "test2" {
+	plan_error  = false
+	apply_error = false
+}
+`,
+	}
+	historyFile := `{
+    "version": 1,
+    "records": {
+        "20201109000001_test1.hcl": {
+            "type": "mock",
+            "name": "test1",
+            "applied_at": "2020-11-10T00:00:01Z"
+        }
+    }
+}`
+
+	cases := []struct {
+		desc     string
+		filename string
+		want     *history.Record
+		ok       bool
+	}{
+		{
+			desc:     "unapplied",
+			filename: "20201109000002_test2.hcl",
+			want: &history.Record{
+				Type:   "mock",
+				Name:   "test2",
+				Status: history.RecordStatusPlanned,
+			},
+			ok: true,
+		},
+		{
+			desc:     "already applied",
+			filename: "20201109000001_test1.hcl",
+			want:     nil,
+			ok:       false,
+		},
+		{
+			desc:     "not found",
+			filename: "20201109000003_test3.hcl",
+			want:     nil,
+			ok:       false,
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.desc, func(t *testing.T) {
+			mockConfig := &mock.Config{
+				Data: historyFile,
+			}
+			config := &config.TfmigrateConfig{
+				MigrationDir: setupMigrationDir(t, migrations),
+				History: &history.Config{
+					Storage: mockConfig,
+				},
+			}
+
+			_, err := markPlanned(context.Background(), config, tc.filename)
+			if tc.ok && err != nil {
+				t.Fatalf("unexpected err: %s", err)
+			}
+			if !tc.ok && err == nil {
+				t.Fatal("expected to return an error, but no error")
+			}
+			if !tc.ok {
+				return
+			}
+
+			hc, err := history.NewController(context.Background(), config.MigrationDir, &history.Config{
+				Storage: &mock.Config{Data: mockConfig.Storage().Data()},
+			})
+			if err != nil {
+				t.Fatalf("failed to reload history: %s", err)
+			}
+			got, ok := hc.FindRecord(tc.filename)
+			if !ok {
+				t.Fatalf("no record found in history: %s", tc.filename)
+			}
+			if diff := cmp.Diff(got, *tc.want, cmp.Comparer(func(x, y time.Time) bool { return true })); diff != "" {
+				t.Errorf("got = %#v, want = %#v, diff = %s", got, tc.want, diff)
+			}
+			if hc.AlreadyApplied(tc.filename) {
+				t.Errorf("expected a planned migration not to be applied: %s", tc.filename)
+			}
+		})
+	}
+}
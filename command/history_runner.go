@@ -2,12 +2,18 @@ package command
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"log"
+	"path/filepath"
+	"strings"
+	"time"
 
 	"github.com/minamijoyo/tfmigrate/config"
 	"github.com/minamijoyo/tfmigrate/history"
+	"github.com/minamijoyo/tfmigrate/storage"
 	"github.com/minamijoyo/tfmigrate/tfmigrate"
+	"github.com/mitchellh/cli"
 )
 
 // HistoryRunner is a history-aware runner.
@@ -23,29 +29,133 @@ type HistoryRunner struct {
 	option *tfmigrate.MigratorOption
 	// A controller which manages history.
 	hc *history.Controller
+	// A UI to report progress of each migration. This is optional.
+	ui cli.Ui
+	// A summary report of the last run of apply.
+	report *ApplyReport
+	// A summary report of the last run of plan.
+	planReport *PlanReport
+	// The maximum number of migrations applied in directory mode.
+	// If zero, apply all unapplied migrations.
+	max int
+	// If true, report a one-line summary of plan per migration.
+	compact bool
+	// If true, plan all unapplied migrations in directory mode even if some
+	// of them fail, and report an ordered summary at the end.
+	planAll bool
 }
 
 // NewHistoryRunner returns a new HistoryRunner instance.
 func NewHistoryRunner(ctx context.Context, filename string, config *config.TfmigrateConfig, option *tfmigrate.MigratorOption) (*HistoryRunner, error) {
+	if len(filename) != 0 {
+		key, err := historyKey(config.MigrationDir, filename)
+		if err != nil {
+			return nil, err
+		}
+		filename = key
+	}
+
 	hc, err := history.NewController(ctx, config.MigrationDir, config.History)
 	if err != nil {
 		return nil, err
 	}
 
+	if err := checkMissingMigrations(hc, config.History.StrictMissingFiles); err != nil {
+		return nil, err
+	}
+
 	r := &HistoryRunner{
-		filename: filename,
-		config:   config,
-		option:   option,
-		hc:       hc,
+		filename:   filename,
+		config:     config,
+		option:     option,
+		hc:         hc,
+		report:     newApplyReport(),
+		planReport: newPlanReport(),
 	}
 
 	return r, nil
 }
 
+// checkMissingMigrations logs a warning for each migration file recorded as
+// applied in history which doesn't exist in the migration dir. If strict is
+// true, it returns an error instead.
+func checkMissingMigrations(hc *history.Controller, strict bool) error {
+	missing := hc.MissingMigrations()
+	if len(missing) == 0 {
+		return nil
+	}
+	if strict {
+		return fmt.Errorf("migration files recorded as applied in history don't exist in the migration dir: %s", strings.Join(missing, ", "))
+	}
+	for _, m := range missing {
+		log.Printf("[WARN] [runner] a migration file recorded as applied in history doesn't exist in the migration dir: %s\n", m)
+	}
+	return nil
+}
+
+// historyKey returns a key of history for a given migration file.
+// The history is keyed by the file name relative to the migration dir, which
+// is the same as the base name because nested directories are not scanned.
+// A given filename must be a file directly under the migration dir, so that
+// the key is consistent with the unapplied migrations in directory mode.
+func historyKey(migrationDir string, filename string) (string, error) {
+	path, err := filepath.Abs(resolveMigrationFile(migrationDir, filename))
+	if err != nil {
+		return "", err
+	}
+	dir, err := filepath.Abs(migrationDir)
+	if err != nil {
+		return "", err
+	}
+
+	key, err := filepath.Rel(dir, path)
+	if err != nil || strings.ContainsRune(key, filepath.Separator) || key == ".." {
+		return "", fmt.Errorf("a migration file must be directly under the migration dir %s in history mode: %s", migrationDir, filename)
+	}
+	return key, nil
+}
+
+// SetUI sets a UI to report progress of each migration.
+func (r *HistoryRunner) SetUI(ui cli.Ui) {
+	r.ui = ui
+}
+
+// SetMax sets the maximum number of migrations applied in directory mode.
+// If zero, apply all unapplied migrations.
+func (r *HistoryRunner) SetMax(n int) {
+	r.max = n
+}
+
+// SetCompact sets whether to report a one-line summary of plan per migration.
+func (r *HistoryRunner) SetCompact(compact bool) {
+	r.compact = compact
+}
+
+// SetPlanAll sets whether to plan all unapplied migrations in directory mode
+// even if some of them fail, and report an ordered summary at the end.
+func (r *HistoryRunner) SetPlanAll(planAll bool) {
+	r.planAll = planAll
+}
+
+// PlanReport returns a summary report of the last run of plan.
+func (r *HistoryRunner) PlanReport() *PlanReport {
+	return r.planReport
+}
+
+// Report returns a summary report of the last run of apply.
+func (r *HistoryRunner) Report() *ApplyReport {
+	return r.report
+}
+
 // Plan plans migrations with history-aware mode.
 // If a filename is set, run a single migration.
 // If not set, run all unapplied migrations.
-func (r *HistoryRunner) Plan(ctx context.Context) error {
+func (r *HistoryRunner) Plan(ctx context.Context) (err error) {
+	r.planReport = newPlanReport()
+	defer func() {
+		r.planReport.Success = err == nil
+	}()
+
 	if len(r.filename) != 0 {
 		// file mode
 		return r.planFile(ctx, r.filename)
@@ -61,13 +171,21 @@ func (r *HistoryRunner) planFile(ctx context.Context, filename string) error {
 		return fmt.Errorf("a migration has already been applied: %s", filename)
 	}
 
-	fr, err := NewFileRunner(filename, r.config, r.option)
+	start := time.Now()
+	fr, err := NewFileRunner(ctx, filename, r.config, r.option)
 	if err != nil {
 		log.Printf("[ERROR] [runner] failed to plan: %s\n", filename)
+		reportProgress(r.ui, progressFailed, filename)
+		r.planReport.add(filename, "", "", time.Since(start), progressFailed, err, nil)
 		return err
 	}
 
-	return fr.Plan(ctx)
+	mc := fr.MigrationConfig()
+	err = fr.Plan(ctx)
+	results := reportPlanResult(r.ui, r.option, filename, err, r.compact)
+	reportDiagnostics(r.ui, r.option, filename)
+	r.planReport.add(filename, mc.Type, mc.Name, time.Since(start), plannedStatus(err), err, results)
+	return err
 }
 
 // planDir plans all unapplied migrations.
@@ -80,13 +198,31 @@ func (r *HistoryRunner) planDir(ctx context.Context) error {
 	}
 	log.Printf("[INFO] [runner] unapplied migration files: %v\n", unapplied)
 
+	if !r.planAll {
+		for i, filename := range unapplied {
+			err := r.planFile(ctx, filename)
+			if err != nil {
+				reportSkipped(r.ui, unapplied[i+1:])
+				return err
+			}
+		}
+		return nil
+	}
+
+	// Plan all of them without stopping at the first failure, so that we can
+	// review the full catch-up sequence at once.
+	failed := []string{}
 	for _, filename := range unapplied {
-		err := r.planFile(ctx, filename)
-		if err != nil {
-			return err
+		if err := r.planFile(ctx, filename); err != nil {
+			log.Printf("[ERROR] [runner] failed to plan, continue to the next: %s: %s\n", filename, err)
+			failed = append(failed, filename)
 		}
 	}
+	reportPlanSummary(r.ui, r.planReport)
 
+	if len(failed) != 0 {
+		return fmt.Errorf("failed to plan %d of %d unapplied migrations: %s", len(failed), len(unapplied), strings.Join(failed, ", "))
+	}
 	return nil
 }
 
@@ -94,13 +230,23 @@ func (r *HistoryRunner) planDir(ctx context.Context) error {
 // If a filename is set, run a single migration.
 // If not set, run all unapplied migrations.
 func (r *HistoryRunner) Apply(ctx context.Context) (err error) {
+	// Record the overall result after saving history.
+	// Note that deferred functions are executed in LIFO order.
+	r.report = newApplyReport()
+	defer func() {
+		r.report.Success = err == nil
+		r.report.Pending = r.hc.UnappliedMigrations()
+	}()
+
 	// save history on exit
-	beforeLen := r.hc.HistoryLength()
+	// A planned record is replaced with an applied one on apply, so count
+	// only applied records to detect changes.
+	beforeLen := r.hc.AppliedHistoryLength()
 	defer func() {
-		// if the number of records in history doesn't change,
+		// if the number of applied records in history doesn't change,
 		// we don't want to update a timestamp of history file.
-		afterLen := r.hc.HistoryLength()
-		log.Printf("[DEBUG] [runner] length of history records: beforeLen = %d, afterLen = %d\n", beforeLen, afterLen)
+		afterLen := r.hc.AppliedHistoryLength()
+		log.Printf("[DEBUG] [runner] length of applied history records: beforeLen = %d, afterLen = %d\n", beforeLen, afterLen)
 		if beforeLen == afterLen {
 			return
 		}
@@ -115,11 +261,15 @@ func (r *HistoryRunner) Apply(ctx context.Context) (err error) {
 
 		// return a named error from defer
 		log.Printf("[ERROR] [runner] failed to save history. The history may be inconsistent\n")
+		if errors.Is(serr, storage.ErrConflict) {
+			log.Printf("[ERROR] [runner] the history has been modified by another process since it was read, so it was not overwritten. The migrations applied in this run are not recorded\n")
+		}
 		if err == nil {
-			err = fmt.Errorf("apply succeed, but failed to save history: %v", serr)
+			// The migrations have been pushed, but not recorded.
+			err = &tfmigrate.PushedError{Err: fmt.Errorf("apply succeed, but failed to save history: %v", serr)}
 			return
 		}
-		err = fmt.Errorf("failed to save history: %v, failed to apply: %v", serr, err)
+		err = fmt.Errorf("failed to save history: %v, failed to apply: %w", serr, err)
 	}()
 
 	if len(r.filename) != 0 {
@@ -139,24 +289,69 @@ func (r *HistoryRunner) applyFile(ctx context.Context, filename string) error {
 		return fmt.Errorf("a migration has already been applied: %s", filename)
 	}
 
-	fr, err := NewFileRunner(filename, r.config, r.option)
+	start := time.Now()
+	fr, err := NewFileRunner(ctx, filename, r.config, r.option)
 	if err != nil {
+		reportProgress(r.ui, progressFailed, filename)
+		r.report.add(filename, "", "", time.Since(start), progressFailed, err)
 		return err
 	}
 
+	mc := fr.MigrationConfig()
+	if err := validateOnlyInHistory(mc, r.option); err != nil {
+		reportProgress(r.ui, progressFailed, filename)
+		r.report.add(filename, mc.Type, mc.Name, time.Since(start), progressFailed, err)
+		return err
+	}
+	applyStart := time.Now()
 	err = fr.Apply(ctx)
+	elapsed := time.Since(applyStart).Round(time.Millisecond)
 	if err != nil {
 		log.Printf("[ERROR] [runner] failed to apply: %s\n", filename)
+		reportProgress(r.ui, progressFailed, filename)
+		reportDiagnostics(r.ui, r.option, filename)
+		reportStateList(r.ui, r.option, filename)
+		r.report.add(filename, mc.Type, mc.Name, time.Since(start), progressFailed, err)
 		return err
 	}
+	reportProgress(r.ui, appliedStatus(r.option), filename)
+	reportDiagnostics(r.ui, r.option, filename)
+	reportStateList(r.ui, r.option, filename)
+	r.report.add(filename, mc.Type, mc.Name, time.Since(start), appliedStatus(r.option), nil)
+
+	if r.option != nil && r.option.DryRun {
+		log.Printf("[INFO] [runner] dry-run: skip adding a record to history: %s\n", filename)
+		return nil
+	}
+
+	if r.option != nil && len(r.option.Only) > 0 {
+		// The migration has not been fully applied yet.
+		log.Printf("[WARN] [runner] the migration was partially applied by --only, skip adding a record to history: %s\n", filename)
+		return nil
+	}
 
-	mc := fr.MigrationConfig()
 	log.Printf("[INFO] [runner] add a record to history: %s\n", filename)
-	r.hc.AddRecord(filename, mc.Type, mc.Name, nil)
+	r.hc.AddRecord(filename, mc.Type, mc.Name, nil, elapsed)
 
 	return nil
 }
 
+// validateOnlyInHistory returns an error if a given migration is applied
+// partially by --only in history mode, but it is not resumable.
+// The partially applied migration is not recorded to history, so it must be
+// applied again later, and the already applied actions would fail on the
+// next run unless it is resumable.
+func validateOnlyInHistory(mc *tfmigrate.MigrationConfig, option *tfmigrate.MigratorOption) error {
+	if option == nil || len(option.Only) == 0 || option.DryRun {
+		return nil
+	}
+
+	if c, ok := mc.Migrator.(*tfmigrate.StateMigratorConfig); ok && !c.Resumable {
+		return fmt.Errorf("the --only option in history mode requires resumable = true in the migration block, so that the rest of the migration can be applied later: %s", mc.Name)
+	}
+	return nil
+}
+
 // applyDir applies all unapplied migrations.
 func (r *HistoryRunner) applyDir(ctx context.Context) (err error) {
 	unapplied := r.hc.UnappliedMigrations()
@@ -167,12 +362,20 @@ func (r *HistoryRunner) applyDir(ctx context.Context) (err error) {
 	}
 	log.Printf("[INFO] [runner] unapplied migration files: %v\n", unapplied)
 
-	for _, filename := range unapplied {
+	n := len(unapplied)
+	if r.max > 0 && n > r.max {
+		log.Printf("[INFO] [runner] apply only the first %d of %d unapplied migrations\n", r.max, n)
+		n = r.max
+	}
+
+	for i, filename := range unapplied[:n] {
 		err := r.applyFile(ctx, filename)
 		if err != nil {
+			reportSkipped(r.ui, unapplied[i+1:])
 			return err
 		}
 	}
+	reportSkipped(r.ui, unapplied[n:])
 
 	return nil
 }
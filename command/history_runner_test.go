@@ -2,6 +2,8 @@ package command
 
 import (
 	"context"
+	"path/filepath"
+	"strings"
 	"testing"
 
 	"github.com/google/go-cmp/cmp"
@@ -9,6 +11,8 @@ import (
 	"github.com/minamijoyo/tfmigrate/config"
 	"github.com/minamijoyo/tfmigrate/history"
 	"github.com/minamijoyo/tfmigrate/storage/mock"
+	"github.com/minamijoyo/tfmigrate/tfmigrate"
+	"github.com/mitchellh/cli"
 )
 
 func TestHistoryRunnerPlan(t *testing.T) {
@@ -256,6 +260,7 @@ func TestHistoryRunnerApply(t *testing.T) {
 		filename    string
 		writeError  bool
 		readError   bool
+		dryRun      bool
 		want        string
 		ok          bool
 	}{
@@ -582,6 +587,48 @@ migration "mock" "test4" {
 }`,
 			ok: false,
 		},
+		{
+			desc: "dry-run does not save history",
+			migrations: map[string]string{
+				"20201109000001_test1.hcl": `
+migration "mock" "test1" {
+	plan_error  = false
+	apply_error = false
+}
+`,
+				"20201109000002_test2.hcl": `
+migration "mock" "test2" {
+	plan_error  = false
+	apply_error = false
+}
+`,
+			},
+			historyFile: `{
+    "version": 1,
+    "records": {
+        "20201109000001_test1.hcl": {
+            "type": "mock",
+            "name": "test1",
+            "applied_at": "2020-11-10T00:00:01Z"
+        }
+    }
+}`,
+			filename:   "",
+			writeError: false,
+			readError:  false,
+			dryRun:     true,
+			want: `{
+    "version": 1,
+    "records": {
+        "20201109000001_test1.hcl": {
+            "type": "mock",
+            "name": "test1",
+            "applied_at": "2020-11-10T00:00:01Z"
+        }
+    }
+}`,
+			ok: true,
+		},
 		{
 			desc: "apply success but save history error",
 			migrations: map[string]string{
@@ -708,6 +755,58 @@ migration "mock" "test4" {
 }`,
 			ok: false,
 		},
+		{
+			desc: "a planned migration is marked as applied",
+			migrations: map[string]string{
+				"20201109000001_test1.hcl": `
+migration "mock" "test1" {
+	plan_error  = false
+	apply_error = false
+}
+`,
+				"20201109000002_test2.hcl": `
+migration "mock" "test2" {
+	plan_error  = false
+	apply_error = false
+}
+`,
+			},
+			historyFile: `{
+    "version": 1,
+    "records": {
+        "20201109000001_test1.hcl": {
+            "type": "mock",
+            "name": "test1",
+            "applied_at": "2020-11-10T00:00:01Z"
+        },
+        "20201109000002_test2.hcl": {
+            "type": "mock",
+            "name": "test2",
+            "applied_at": "2020-11-10T00:00:02Z",
+            "status": "planned"
+        }
+    }
+}`,
+			filename:   "",
+			writeError: false,
+			readError:  false,
+			want: `{
+    "version": 1,
+    "records": {
+        "20201109000001_test1.hcl": {
+            "type": "mock",
+            "name": "test1",
+            "applied_at": "2020-11-10T00:00:01Z"
+        },
+        "20201109000002_test2.hcl": {
+            "type": "mock",
+            "name": "test2",
+            "applied_at": "2020-11-10T00:00:02Z"
+        }
+    }
+}`,
+			ok: true,
+		},
 	}
 
 	for _, tc := range cases {
@@ -724,7 +823,10 @@ migration "mock" "test4" {
 					Storage: mockConfig,
 				},
 			}
-			r, err := NewHistoryRunner(context.Background(), tc.filename, config, nil)
+			option := &tfmigrate.MigratorOption{
+				DryRun: tc.dryRun,
+			}
+			r, err := NewHistoryRunner(context.Background(), tc.filename, config, option)
 			if err != nil {
 				t.Fatalf("failed to new history runner: %s", err)
 			}
@@ -752,3 +854,535 @@ migration "mock" "test4" {
 		})
 	}
 }
+
+func TestHistoryRunnerApplyReport(t *testing.T) {
+	migrations := map[string]string{
+		"20201109000001_test1.hcl": `
+migration "mock" "test1" {
+	plan_error  = false
+	apply_error = false
+}
+`,
+		"20201109000002_test2.hcl": `
+migration "mock" "test2" {
+	plan_error  = false
+	apply_error = false
+}
+`,
+		"20201109000003_test3.hcl": `
+migration "mock" "test3" {
+	plan_error  = false
+	apply_error = true
+}
+`,
+		"20201109000004_test4.hcl": `
+migration "mock" "test4" {
+	plan_error  = false
+	apply_error = false
+}
+`,
+	}
+	historyFile := `{
+    "version": 1,
+    "records": {
+        "20201109000001_test1.hcl": {
+            "type": "mock",
+            "name": "test1",
+            "applied_at": "2020-11-10T00:00:01Z"
+        }
+    }
+}`
+
+	migrationDir := setupMigrationDir(t, migrations)
+	config := &config.TfmigrateConfig{
+		MigrationDir: migrationDir,
+		History: &history.Config{
+			Storage: &mock.Config{
+				Data: historyFile,
+			},
+		},
+	}
+	r, err := NewHistoryRunner(context.Background(), "", config, &tfmigrate.MigratorOption{})
+	if err != nil {
+		t.Fatalf("failed to new history runner: %s", err)
+	}
+
+	err = r.Apply(context.Background())
+	if err == nil {
+		t.Fatal("expected to return an error, but no error")
+	}
+
+	want := &ApplyReport{
+		Success: false,
+		Migrations: []MigrationReport{
+			{Filename: "20201109000002_test2.hcl", Type: "mock", Name: "test2", Outcome: "applied"},
+			{Filename: "20201109000003_test3.hcl", Type: "mock", Name: "test3", Outcome: "failed"},
+		},
+		Pending: []string{"20201109000003_test3.hcl", "20201109000004_test4.hcl"},
+	}
+	got := r.Report()
+	if diff := cmp.Diff(got, want, cmpopts.IgnoreFields(MigrationReport{}, "DurationSeconds", "Error")); diff != "" {
+		t.Errorf("got = %#v, want = %#v, diff = %s", got, want, diff)
+	}
+	if got.Migrations[1].Error == "" {
+		t.Errorf("expected to record an error message, but got empty")
+	}
+}
+
+func TestHistoryRunnerPlanReport(t *testing.T) {
+	migrations := map[string]string{
+		"20201109000001_test1.hcl": `
+migration "mock" "test1" {
+	plan_error  = false
+	apply_error = false
+}
+`,
+		"20201109000002_test2.hcl": `
+migration "mock" "test2" {
+	plan_error  = false
+	apply_error = false
+}
+`,
+		"20201109000003_test3.hcl": `
+migration "mock" "test3" {
+	plan_error  = true
+	apply_error = false
+}
+`,
+	}
+	historyFile := `{
+    "version": 1,
+    "records": {
+        "20201109000001_test1.hcl": {
+            "type": "mock",
+            "name": "test1",
+            "applied_at": "2020-11-10T00:00:01Z"
+        }
+    }
+}`
+
+	migrationDir := setupMigrationDir(t, migrations)
+	config := &config.TfmigrateConfig{
+		MigrationDir: migrationDir,
+		History: &history.Config{
+			Storage: &mock.Config{
+				Data: historyFile,
+			},
+		},
+	}
+	r, err := NewHistoryRunner(context.Background(), "", config, &tfmigrate.MigratorOption{})
+	if err != nil {
+		t.Fatalf("failed to new history runner: %s", err)
+	}
+
+	err = r.Plan(context.Background())
+	if err == nil {
+		t.Fatal("expected to return an error, but no error")
+	}
+
+	want := &PlanReport{
+		Success: false,
+		Migrations: []MigrationReport{
+			{Filename: "20201109000002_test2.hcl", Type: "mock", Name: "test2", Outcome: "planned"},
+			{Filename: "20201109000003_test3.hcl", Type: "mock", Name: "test3", Outcome: "failed"},
+		},
+	}
+	got := r.PlanReport()
+	if diff := cmp.Diff(got, want, cmpopts.IgnoreFields(MigrationReport{}, "DurationSeconds", "Error")); diff != "" {
+		t.Errorf("got = %#v, want = %#v, diff = %s", got, want, diff)
+	}
+	if got.Migrations[1].Error == "" {
+		t.Errorf("expected to record an error message, but got empty")
+	}
+}
+
+func TestHistoryRunnerPlanAll(t *testing.T) {
+	migrations := map[string]string{
+		"20201109000001_test1.hcl": `
+migration "mock" "test1" {
+	plan_error  = false
+	apply_error = false
+}
+`,
+		"20201109000002_test2.hcl": `
+migration "mock" "test2" {
+	plan_error  = true
+	apply_error = false
+}
+`,
+		"20201109000003_test3.hcl": `
+migration "mock" "test3" {
+	plan_error  = false
+	apply_error = false
+}
+`,
+	}
+
+	migrationDir := setupMigrationDir(t, migrations)
+	config := &config.TfmigrateConfig{
+		MigrationDir: migrationDir,
+		History: &history.Config{
+			Storage: &mock.Config{
+				Data: "",
+			},
+		},
+	}
+	r, err := NewHistoryRunner(context.Background(), "", config, &tfmigrate.MigratorOption{})
+	if err != nil {
+		t.Fatalf("failed to new history runner: %s", err)
+	}
+	ui := cli.NewMockUi()
+	r.SetUI(ui)
+	r.SetPlanAll(true)
+
+	err = r.Plan(context.Background())
+	if err == nil {
+		t.Fatal("expected to return an error, but no error")
+	}
+	if want := "failed to plan 1 of 3 unapplied migrations: 20201109000002_test2.hcl"; err.Error() != want {
+		t.Errorf("got: %s, want: %s", err, want)
+	}
+
+	want := &PlanReport{
+		Success: false,
+		Migrations: []MigrationReport{
+			{Filename: "20201109000001_test1.hcl", Type: "mock", Name: "test1", Outcome: "planned"},
+			{Filename: "20201109000002_test2.hcl", Type: "mock", Name: "test2", Outcome: "failed"},
+			{Filename: "20201109000003_test3.hcl", Type: "mock", Name: "test3", Outcome: "planned"},
+		},
+	}
+	got := r.PlanReport()
+	if diff := cmp.Diff(got, want, cmpopts.IgnoreFields(MigrationReport{}, "DurationSeconds", "Error")); diff != "" {
+		t.Errorf("got = %#v, want = %#v, diff = %s", got, want, diff)
+	}
+	if !strings.Contains(ui.ErrorWriter.String(), "[tfmigrate] plan summary: 3 migration(s), 2 planned, 1 failed") {
+		t.Errorf("expected to report a summary, but got: %s", ui.ErrorWriter.String())
+	}
+}
+
+func TestHistoryRunnerApplyWithMax(t *testing.T) {
+	migrations := map[string]string{
+		"20201109000001_test1.hcl": `
+migration "mock" "test1" {
+	plan_error  = false
+	apply_error = false
+}
+`,
+		"20201109000002_test2.hcl": `
+migration "mock" "test2" {
+	plan_error  = false
+	apply_error = false
+}
+`,
+		"20201109000003_test3.hcl": `
+migration "mock" "test3" {
+	plan_error  = false
+	apply_error = false
+}
+`,
+		"20201109000004_test4.hcl": `
+migration "mock" "test4" {
+	plan_error  = false
+	apply_error = false
+}
+`,
+	}
+	historyFile := `{
+    "version": 1,
+    "records": {
+        "20201109000001_test1.hcl": {
+            "type": "mock",
+            "name": "test1",
+            "applied_at": "2020-11-10T00:00:01Z"
+        }
+    }
+}`
+
+	cases := []struct {
+		desc    string
+		max     int
+		want    []string
+		skipped []string
+	}{
+		{
+			desc: "no limit",
+			max:  0,
+			want: []string{
+				"20201109000001_test1.hcl",
+				"20201109000002_test2.hcl",
+				"20201109000003_test3.hcl",
+				"20201109000004_test4.hcl",
+			},
+			skipped: []string{},
+		},
+		{
+			desc: "limit to 2",
+			max:  2,
+			want: []string{
+				"20201109000001_test1.hcl",
+				"20201109000002_test2.hcl",
+				"20201109000003_test3.hcl",
+			},
+			skipped: []string{
+				"20201109000004_test4.hcl",
+			},
+		},
+		{
+			desc: "limit greater than unapplied",
+			max:  10,
+			want: []string{
+				"20201109000001_test1.hcl",
+				"20201109000002_test2.hcl",
+				"20201109000003_test3.hcl",
+				"20201109000004_test4.hcl",
+			},
+			skipped: []string{},
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.desc, func(t *testing.T) {
+			migrationDir := setupMigrationDir(t, migrations)
+			mockConfig := &mock.Config{
+				Data: historyFile,
+			}
+			config := &config.TfmigrateConfig{
+				MigrationDir: migrationDir,
+				History: &history.Config{
+					Storage: mockConfig,
+				},
+			}
+			r, err := NewHistoryRunner(context.Background(), "", config, &tfmigrate.MigratorOption{})
+			if err != nil {
+				t.Fatalf("failed to new history runner: %s", err)
+			}
+			ui := cli.NewMockUi()
+			r.SetUI(ui)
+			r.SetMax(tc.max)
+
+			err = r.Apply(context.Background())
+			if err != nil {
+				t.Fatalf("unexpected err: %s", err)
+			}
+
+			got, err := history.ParseHistoryFile([]byte(mockConfig.Storage().Data()))
+			if err != nil {
+				t.Fatalf("failed to parse history file (got): %s", err)
+			}
+			for _, filename := range tc.want {
+				if !got.Contains(filename) {
+					t.Errorf("expected to be applied: %s", filename)
+				}
+			}
+			if got.Length() != len(tc.want) {
+				t.Errorf("got %d records, want %d records", got.Length(), len(tc.want))
+			}
+			skipped := ui.ErrorWriter.String()
+			for _, filename := range tc.skipped {
+				if !strings.Contains(skipped, "[tfmigrate] skipped "+filename) {
+					t.Errorf("expected to report as skipped: %s, got: %s", filename, skipped)
+				}
+			}
+			if got := strings.Count(skipped, "[tfmigrate] skipped "); got != len(tc.skipped) {
+				t.Errorf("got %d skipped migrations, want %d: %s", got, len(tc.skipped), skipped)
+			}
+		})
+	}
+}
+
+func TestNewHistoryRunnerWithMissingMigrations(t *testing.T) {
+	migrations := map[string]string{
+		"20201109000002_test2.hcl": `
+migration "mock" "test2" {
+	plan_error  = false
+	apply_error = false
+}
+`,
+	}
+	historyFile := `{
+    "version": 1,
+    "records": {
+        "20201109000001_test1.hcl": {
+            "type": "mock",
+            "name": "test1",
+            "applied_at": "2020-11-10T00:00:01Z"
+        }
+    }
+}`
+
+	cases := []struct {
+		desc   string
+		strict bool
+		ok     bool
+	}{
+		{
+			desc:   "warning",
+			strict: false,
+			ok:     true,
+		},
+		{
+			desc:   "strict",
+			strict: true,
+			ok:     false,
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.desc, func(t *testing.T) {
+			migrationDir := setupMigrationDir(t, migrations)
+			config := &config.TfmigrateConfig{
+				MigrationDir: migrationDir,
+				History: &history.Config{
+					Storage: &mock.Config{
+						Data: historyFile,
+					},
+					StrictMissingFiles: tc.strict,
+				},
+			}
+			_, err := NewHistoryRunner(context.Background(), "", config, nil)
+			if tc.ok && err != nil {
+				t.Fatalf("unexpected err: %s", err)
+			}
+			if !tc.ok && err == nil {
+				t.Fatal("expected to return an error, but no error")
+			}
+			if !tc.ok && !strings.Contains(err.Error(), "20201109000001_test1.hcl") {
+				t.Errorf("err doesn't contain the missing file: %s", err)
+			}
+		})
+	}
+}
+
+func TestHistoryKey(t *testing.T) {
+	migrationDir := t.TempDir()
+	cases := []struct {
+		desc         string
+		migrationDir string
+		filename     string
+		want         string
+		ok           bool
+	}{
+		{
+			desc:         "file name",
+			migrationDir: migrationDir,
+			filename:     "20201109000001_test1.hcl",
+			want:         "20201109000001_test1.hcl",
+			ok:           true,
+		},
+		{
+			desc:         "absolute path under migration dir",
+			migrationDir: migrationDir,
+			filename:     filepath.Join(migrationDir, "20201109000001_test1.hcl"),
+			want:         "20201109000001_test1.hcl",
+			ok:           true,
+		},
+		{
+			desc:         "current dir",
+			migrationDir: ".",
+			filename:     "20201109000001_test1.hcl",
+			want:         "20201109000001_test1.hcl",
+			ok:           true,
+		},
+		{
+			desc:         "nested dir",
+			migrationDir: migrationDir,
+			filename:     "foo/20201109000001_test1.hcl",
+			want:         "",
+			ok:           false,
+		},
+		{
+			desc:         "outside of migration dir",
+			migrationDir: migrationDir,
+			filename:     "/tmp/20201109000001_test1.hcl",
+			want:         "",
+			ok:           false,
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.desc, func(t *testing.T) {
+			got, err := historyKey(tc.migrationDir, tc.filename)
+			if tc.ok && err != nil {
+				t.Fatalf("unexpected err: %s", err)
+			}
+			if !tc.ok && err == nil {
+				t.Fatalf("expected to return an error, but no error, got: %s", got)
+			}
+			if got != tc.want {
+				t.Errorf("got: %s, want: %s", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestValidateOnlyInHistory(t *testing.T) {
+	cases := []struct {
+		desc   string
+		mc     *tfmigrate.MigrationConfig
+		option *tfmigrate.MigratorOption
+		ok     bool
+	}{
+		{
+			desc: "without --only",
+			mc: &tfmigrate.MigrationConfig{
+				Type:     "state",
+				Name:     "test",
+				Migrator: &tfmigrate.StateMigratorConfig{},
+			},
+			option: &tfmigrate.MigratorOption{},
+			ok:     true,
+		},
+		{
+			desc: "--only with resumable",
+			mc: &tfmigrate.MigrationConfig{
+				Type:     "state",
+				Name:     "test",
+				Migrator: &tfmigrate.StateMigratorConfig{Resumable: true},
+			},
+			option: &tfmigrate.MigratorOption{Only: []string{"null_resource.foo*"}},
+			ok:     true,
+		},
+		{
+			desc: "--only without resumable",
+			mc: &tfmigrate.MigrationConfig{
+				Type:     "state",
+				Name:     "test",
+				Migrator: &tfmigrate.StateMigratorConfig{},
+			},
+			option: &tfmigrate.MigratorOption{Only: []string{"null_resource.foo*"}},
+			ok:     false,
+		},
+		{
+			desc: "--only without resumable in dry-run",
+			mc: &tfmigrate.MigrationConfig{
+				Type:     "state",
+				Name:     "test",
+				Migrator: &tfmigrate.StateMigratorConfig{},
+			},
+			option: &tfmigrate.MigratorOption{Only: []string{"null_resource.foo*"}, DryRun: true},
+			ok:     true,
+		},
+		{
+			desc: "nil option",
+			mc: &tfmigrate.MigrationConfig{
+				Type:     "state",
+				Name:     "test",
+				Migrator: &tfmigrate.StateMigratorConfig{},
+			},
+			option: nil,
+			ok:     true,
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.desc, func(t *testing.T) {
+			err := validateOnlyInHistory(tc.mc, tc.option)
+			if tc.ok && err != nil {
+				t.Fatalf("unexpected err: %s", err)
+			}
+			if !tc.ok && err == nil {
+				t.Fatal("expected to return an error, but no error")
+			}
+		})
+	}
+}
@@ -0,0 +1,133 @@
+package command
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/minamijoyo/tfmigrate/config"
+	"github.com/minamijoyo/tfmigrate/history"
+	flag "github.com/spf13/pflag"
+)
+
+// HistoryShowCommand is a command which shows a record of a given migration
+// file in history.
+type HistoryShowCommand struct {
+	Meta
+	json bool
+}
+
+// Run runs the procedure of this command.
+func (c *HistoryShowCommand) Run(args []string) int {
+	cmdFlags := flag.NewFlagSet("history show", flag.ContinueOnError)
+	cmdFlags.StringVar(&c.configFile, "config", defaultConfigFile, "A path to tfmigrate config file")
+	cmdFlags.BoolVar(&c.json, "json", false, "Output in JSON")
+
+	if err := cmdFlags.Parse(args); err != nil {
+		c.UI.Error(fmt.Sprintf("failed to parse arguments: %s", err))
+		return 1
+	}
+
+	if len(cmdFlags.Args()) != 1 {
+		c.UI.Error(fmt.Sprintf("The command expects 1 argument, but got %d", len(cmdFlags.Args())))
+		c.UI.Error(c.Help())
+		return 1
+	}
+
+	if err := c.loadHistoryConfig(); err != nil {
+		c.UI.Error(err.Error())
+		return 1
+	}
+
+	out, err := showHistoryRecord(context.Background(), c.config, cmdFlags.Arg(0), c.json)
+	if err != nil {
+		c.UI.Error(err.Error())
+		return 1
+	}
+	c.UI.Output(out)
+	return 0
+}
+
+// showHistoryRecord returns a record of a given migration file in history.
+// The filename is keyed in the same way as history mode.
+func showHistoryRecord(ctx context.Context, config *config.TfmigrateConfig, filename string, asJSON bool) (string, error) {
+	key, err := historyKey(config.MigrationDir, filename)
+	if err != nil {
+		return "", err
+	}
+
+	hc, err := history.NewController(ctx, config.MigrationDir, config.History)
+	if err != nil {
+		return "", err
+	}
+
+	r, ok := hc.FindRecord(key)
+	if !ok {
+		return "", fmt.Errorf("no record found in history: %s", key)
+	}
+
+	if asJSON {
+		b, err := json.MarshalIndent(recordEntry{
+			Filename:  key,
+			Name:      r.Name,
+			Type:      r.Type,
+			AppliedAt: r.AppliedAt,
+			Duration:  formatDuration(r.Duration),
+			Status:    formatStatus(r),
+		}, "", "  ")
+		if err != nil {
+			return "", err
+		}
+		return string(b), nil
+	}
+
+	lines := []string{
+		"filename:   " + key,
+		"type:       " + r.Type,
+		"name:       " + r.Name,
+		"applied_at: " + r.AppliedAt.UTC().Format(time.RFC3339),
+	}
+	if r.Duration != 0 {
+		lines = append(lines, "duration:   "+r.Duration.String())
+	}
+	if status := formatStatus(r); len(status) != 0 {
+		lines = append(lines, "status:     "+status)
+	}
+	return strings.Join(lines, "\n"), nil
+}
+
+// formatStatus returns a status of a given record, or an empty string if
+// applied, so that the output for applied records doesn't change.
+func formatStatus(r history.Record) string {
+	if r.Applied() {
+		return ""
+	}
+	return r.Status
+}
+
+// Help returns long-form help text.
+func (c *HistoryShowCommand) Help() string {
+	helpText := `
+Usage: tfmigrate history show [options] <FILENAME>
+
+Show a record of a given migration file in history.
+It's useful for debugging why a migration is considered applied.
+A migration marked as planned but not applied yet is shown with its status.
+Base histories are also searched. It fails if no record is found.
+
+Arguments:
+  FILENAME           A migration file name directly under the migration dir
+
+Options:
+  --config           A path to tfmigrate config file
+  --json             Output in JSON
+`
+	return strings.TrimSpace(helpText)
+}
+
+// Synopsis returns one-line help text.
+func (c *HistoryShowCommand) Synopsis() string {
+	return "Show a record of a given migration in history"
+}
@@ -0,0 +1,162 @@
+package command
+
+import (
+	"context"
+	"testing"
+
+	"github.com/minamijoyo/tfmigrate/config"
+	"github.com/minamijoyo/tfmigrate/history"
+	"github.com/minamijoyo/tfmigrate/storage"
+	"github.com/minamijoyo/tfmigrate/storage/mock"
+)
+
+func TestShowHistoryRecord(t *testing.T) {
+	historyFile := `{
+    "version": 1,
+    "records": {
+        "20201109000001_test1.hcl": {
+            "type": "state",
+            "name": "test1",
+            "applied_at": "2020-11-10T00:00:01Z"
+        },
+        "20201109000002_planned.hcl": {
+            "type": "state",
+            "name": "planned",
+            "applied_at": "2020-11-10T00:00:02Z",
+            "status": "planned"
+        }
+    }
+}`
+	baseHistoryFile := `{
+    "version": 1,
+    "records": {
+        "20201109000000_base.hcl": {
+            "type": "multi_state",
+            "name": "base",
+            "applied_at": "2020-11-09T00:00:00Z",
+            "duration": "1m2.5s"
+        }
+    }
+}`
+	cases := []struct {
+		desc     string
+		filename string
+		json     bool
+		want     string
+		ok       bool
+	}{
+		{
+			desc:     "text",
+			filename: "20201109000001_test1.hcl",
+			json:     false,
+			want: `filename:   20201109000001_test1.hcl
+type:       state
+name:       test1
+applied_at: 2020-11-10T00:00:01Z`,
+			ok: true,
+		},
+		{
+			desc:     "json",
+			filename: "20201109000001_test1.hcl",
+			json:     true,
+			want: `{
+  "filename": "20201109000001_test1.hcl",
+  "name": "test1",
+  "type": "state",
+  "applied_at": "2020-11-10T00:00:01Z"
+}`,
+			ok: true,
+		},
+		{
+			desc:     "base history",
+			filename: "20201109000000_base.hcl",
+			json:     false,
+			want: `filename:   20201109000000_base.hcl
+type:       multi_state
+name:       base
+applied_at: 2020-11-09T00:00:00Z
+duration:   1m2.5s`,
+			ok: true,
+		},
+		{
+			desc:     "base history in json",
+			filename: "20201109000000_base.hcl",
+			json:     true,
+			want: `{
+  "filename": "20201109000000_base.hcl",
+  "name": "base",
+  "type": "multi_state",
+  "applied_at": "2020-11-09T00:00:00Z",
+  "duration": "1m2.5s"
+}`,
+			ok: true,
+		},
+		{
+			desc:     "planned",
+			filename: "20201109000002_planned.hcl",
+			json:     false,
+			want: `filename:   20201109000002_planned.hcl
+type:       state
+name:       planned
+applied_at: 2020-11-10T00:00:02Z
+status:     planned`,
+			ok: true,
+		},
+		{
+			desc:     "planned in json",
+			filename: "20201109000002_planned.hcl",
+			json:     true,
+			want: `{
+  "filename": "20201109000002_planned.hcl",
+  "name": "planned",
+  "type": "state",
+  "applied_at": "2020-11-10T00:00:02Z",
+  "status": "planned"
+}`,
+			ok: true,
+		},
+		{
+			desc:     "not found",
+			filename: "20201109000002_test2.hcl",
+			json:     false,
+			want:     "",
+			ok:       false,
+		},
+		{
+			desc:     "nested file",
+			filename: "foo/20201109000001_test1.hcl",
+			json:     false,
+			want:     "",
+			ok:       false,
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.desc, func(t *testing.T) {
+			config := &config.TfmigrateConfig{
+				MigrationDir: setupMigrationDir(t, map[string]string{}),
+				History: &history.Config{
+					Storage: &mock.Config{
+						Data: historyFile,
+					},
+					BaseStorages: []storage.Config{
+						&mock.Config{
+							Data: baseHistoryFile,
+						},
+					},
+				},
+			}
+
+			got, err := showHistoryRecord(context.Background(), config, tc.filename, tc.json)
+			if tc.ok && err != nil {
+				t.Fatalf("unexpected err: %s", err)
+			}
+			if !tc.ok && err == nil {
+				t.Fatalf("expected to return an error, but no error, got: %s", got)
+			}
+			if got != tc.want {
+				t.Errorf("got:\n%s\nwant:\n%s", got, tc.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,134 @@
+package command
+
+import (
+	"context"
+	"testing"
+
+	"github.com/minamijoyo/tfmigrate/config"
+	"github.com/minamijoyo/tfmigrate/history"
+	"github.com/minamijoyo/tfmigrate/storage/mock"
+)
+
+func TestDumpHistory(t *testing.T) {
+	historyFile := `{
+    "version": 1,
+    "records": {
+        "20201109000001_test1.hcl": {
+            "type": "mock",
+            "name": "test1",
+            "applied_at": "2020-11-10T00:00:01Z"
+        }
+    }
+}`
+	config := &config.TfmigrateConfig{
+		MigrationDir: setupMigrationDir(t, map[string]string{}),
+		History: &history.Config{
+			Storage: &mock.Config{
+				Data: historyFile,
+			},
+		},
+	}
+
+	got, err := dumpHistory(context.Background(), config)
+	if err != nil {
+		t.Fatalf("unexpected err: %s", err)
+	}
+	if got != historyFile {
+		t.Errorf("got = %s, want = %s", got, historyFile)
+	}
+}
+
+func TestLoadHistory(t *testing.T) {
+	historyFile := `{
+    "version": 1,
+    "records": {
+        "20201109000001_test1.hcl": {
+            "type": "mock",
+            "name": "test1",
+            "applied_at": "2020-11-10T00:00:01Z"
+        }
+    }
+}`
+	newHistoryFile := `{
+    "version": 1,
+    "records": {
+        "20201109000001_test1.hcl": {
+            "type": "mock",
+            "name": "test1",
+            "applied_at": "2020-11-10T00:00:01Z"
+        },
+        "20201109000002_test2.hcl": {
+            "type": "mock",
+            "name": "test2",
+            "applied_at": "2020-11-10T00:00:02Z"
+        }
+    }
+}`
+
+	cases := []struct {
+		desc        string
+		input       string
+		autoApprove bool
+		want        string
+		ok          bool
+	}{
+		{
+			desc:        "auto approve",
+			input:       newHistoryFile,
+			autoApprove: true,
+			want:        newHistoryFile,
+			ok:          true,
+		},
+		{
+			desc:        "without auto approve",
+			input:       newHistoryFile,
+			autoApprove: false,
+			want:        historyFile,
+			ok:          true,
+		},
+		{
+			desc:        "invalid history",
+			input:       `{"version": 1, "records": {"20201109000002_test2.hcl": {"type": "mock"}}}`,
+			autoApprove: true,
+			want:        historyFile,
+			ok:          false,
+		},
+		{
+			desc:        "empty",
+			input:       ``,
+			autoApprove: true,
+			want:        historyFile,
+			ok:          false,
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.desc, func(t *testing.T) {
+			mockConfig := &mock.Config{
+				Data: historyFile,
+			}
+			config := &config.TfmigrateConfig{
+				MigrationDir: setupMigrationDir(t, map[string]string{}),
+				History: &history.Config{
+					Storage: mockConfig,
+				},
+			}
+
+			_, err := loadHistory(context.Background(), config, []byte(tc.input), tc.autoApprove)
+			if tc.ok && err != nil {
+				t.Fatalf("unexpected err: %s", err)
+			}
+			if !tc.ok && err == nil {
+				t.Fatal("expected to return an error, but no error")
+			}
+
+			got := historyFile
+			if mockConfig.Storage() != nil {
+				got = mockConfig.Storage().Data()
+			}
+			if got != tc.want {
+				t.Errorf("got = %s, want = %s", got, tc.want)
+			}
+		})
+	}
+}
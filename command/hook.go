@@ -0,0 +1,89 @@
+package command
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"os/exec"
+	"strings"
+	"time"
+)
+
+// hookOutputFlushTimeout is a duration to wait for the output of a hook to be
+// flushed after the hook exits. The output may be held by a process started in
+// background by the hook, so we don't wait for the end of it.
+const hookOutputFlushTimeout = 1 * time.Second
+
+// runWithHooks runs a given function between the pre_run and post_run hooks
+// in the config. The hooks run once per run of a command, not per migration.
+// The post_run hook runs even if the function failed, but it doesn't run if
+// the pre_run hook failed. The output of the hooks is redacted and written to
+// stderr.
+func (m *Meta) runWithHooks(f func() error) error {
+	if m.config == nil {
+		return f()
+	}
+
+	ctx := context.Background()
+	out := m.Redactor.Writer(os.Stderr)
+	if err := runHook(ctx, "pre_run", m.config.PreRunHook, out); err != nil {
+		return err
+	}
+
+	err := f()
+
+	if herr := runHook(ctx, "post_run", m.config.PostRunHook, out); herr != nil {
+		return errors.Join(err, herr)
+	}
+	return err
+}
+
+// runHook runs a given hook command and copies its output to a given writer
+// line by line. It's a no-op if the command is empty.
+func runHook(ctx context.Context, name string, command []string, out io.Writer) error {
+	if len(command) == 0 {
+		return nil
+	}
+
+	log.Printf("[INFO] [command] run %s hook: %s\n", name, strings.Join(command, " "))
+	cmd := exec.CommandContext(ctx, command[0], command[1:]...)
+	// Connect the output to a pipe file directly instead of letting exec copy
+	// it. A hook may start a process in background such as ssh -f, which
+	// inherits the output. If exec copied it, the hook would wait for the
+	// background process to exit.
+	pr, pw, err := os.Pipe()
+	if err != nil {
+		return fmt.Errorf("failed to run %s hook: %s", name, err)
+	}
+	cmd.Stdout = pw
+	cmd.Stderr = pw
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		defer pr.Close()
+		// Write line by line, so that a secret is redacted as a whole.
+		scanner := bufio.NewScanner(pr)
+		scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+		for scanner.Scan() {
+			fmt.Fprintln(out, scanner.Text())
+		}
+	}()
+
+	err = cmd.Run()
+	pw.Close()
+	select {
+	case <-done:
+	case <-time.After(hookOutputFlushTimeout):
+		log.Printf("[DEBUG] [command] the output of %s hook is still open, which may be held by a background process\n", name)
+	}
+
+	if err != nil {
+		return fmt.Errorf("failed to run %s hook: %s", name, err)
+	}
+	return nil
+}
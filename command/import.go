@@ -0,0 +1,445 @@
+package command
+
+import (
+	"context"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/minamijoyo/tfmigrate/config"
+	"github.com/minamijoyo/tfmigrate/tfmigrate"
+	flag "github.com/spf13/pflag"
+)
+
+// ImportCommand is a command which imports resources listed in a CSV file
+// by generating a migration file of import actions and applying it.
+type ImportCommand struct {
+	Meta
+	fromCSV       string
+	dir           string
+	workspace     string
+	name          string
+	autoRollback  bool
+	force         bool
+	backendConfig []string
+	skipInit      bool
+}
+
+// Run runs the procedure of this command.
+func (c *ImportCommand) Run(args []string) int {
+	cmdFlags := flag.NewFlagSet("import", flag.ContinueOnError)
+	cmdFlags.StringVar(&c.configFile, "config", defaultConfigFile, "A path to tfmigrate config file")
+	cmdFlags.StringVar(&c.fromCSV, "from-csv", "", "A path to a CSV file of address and id pairs, or - for stdin")
+	cmdFlags.StringVar(&c.dir, "dir", "", "A working directory where resources are imported")
+	cmdFlags.StringVar(&c.workspace, "workspace", "", "A terraform workspace")
+	cmdFlags.StringVar(&c.name, "name", "", "A name of the generated migration")
+	cmdFlags.BoolVar(&c.autoRollback, "auto-rollback", false, "Roll back imports which would be destroyed or replaced")
+	cmdFlags.BoolVar(&c.force, "force", false, "Apply the migration even if plan shows changes")
+	cmdFlags.StringArrayVar(&c.backendConfig, "backend-config", nil, "A backend configuration for remote state")
+	cmdFlags.StringVar(&c.logLevel, "log-level", "", "A minimum log level")
+	cmdFlags.BoolVarP(&c.verbose, "verbose", "v", false, "Show terraform commands executed")
+	cmdFlags.BoolVar(&c.skipInit, "skip-init", false, "Assume working dirs are already initialized and skip terraform init")
+
+	if err := cmdFlags.Parse(args); err != nil {
+		c.UI.Error(fmt.Sprintf("failed to parse arguments: %s", err))
+		return 1
+	}
+	if len(cmdFlags.Args()) != 0 {
+		c.UI.Error(fmt.Sprintf("The command expects no argument, but got %d", len(cmdFlags.Args())))
+		c.UI.Error(c.Help())
+		return 1
+	}
+	if len(c.fromCSV) == 0 {
+		c.UI.Error("The --from-csv option is required")
+		c.UI.Error(c.Help())
+		return 1
+	}
+	if err := c.setLogLevel(""); err != nil {
+		c.UI.Error(fmt.Sprintf("failed to set log level: %s", err))
+		return 1
+	}
+
+	name := c.name
+	if len(name) == 0 {
+		name = defaultImportMigrationName(c.fromCSV)
+	}
+	if !migrationNameRegex.MatchString(name) {
+		c.UI.Error(fmt.Sprintf("The --name option must consist of alphanumerics, underscores and hyphens: %s", name))
+		return 1
+	}
+
+	rows, err := c.readCSV()
+	if err != nil {
+		c.UI.Error(err.Error())
+		return 1
+	}
+
+	if c.config, err = newConfig(c.configFile); err != nil {
+		c.UI.Error(fmt.Sprintf("failed to load config file: %s", err))
+		return 1
+	}
+	if err = c.setLogLevel(c.config.LogLevel); err != nil {
+		c.UI.Error(fmt.Sprintf("failed to set log level: %s", err))
+		return 1
+	}
+	if err = c.Redactor.AddPatterns(c.config.RedactPatterns); err != nil {
+		c.UI.Error(fmt.Sprintf("failed to add redact patterns: %s", err))
+		return 1
+	}
+	log.Printf("[DEBUG] [command] config: %#v\n", c.config)
+	if err := validateImportMigrationDir(c.config); err != nil {
+		c.UI.Error(err.Error())
+		return 1
+	}
+
+	c.Option = newOption()
+	c.Option.BackendConfig = c.backendConfig
+	c.Option.SkipInit = c.skipInit
+	c.setVerbose()
+	// The option may contain sensitive values such as environment variables.
+	// So logging the option set log level to DEBUG instead of INFO.
+	log.Printf("[DEBUG] [command] option: %#v\n", c.Option)
+
+	// Check the account before generating a migration file to be applied.
+	if err := c.checkAccountGuard(context.Background()); err != nil {
+		c.UI.Error(err.Error())
+		return 1
+	}
+
+	source := generateImportMigration(name, c.dir, c.workspace, rows, c.autoRollback, c.force)
+	filename := importMigrationFilename(name, time.Now())
+	path := resolveMigrationFile(c.config.MigrationDir, filename)
+	if err := writeMigrationFile(path, source); err != nil {
+		c.UI.Error(err.Error())
+		return 1
+	}
+	c.UI.Output(fmt.Sprintf("Generated a migration file with %d import actions: %s", len(rows), path))
+
+	if err := c.runWithHooks(func() error { return c.apply(filename) }); err != nil {
+		c.UI.Error(err.Error())
+		c.UI.Error(importFailureReport(rows, err))
+		c.UI.Error(fmt.Sprintf("The generated migration file is kept for review: %s\n"+
+			"Fix it and run tfmigrate apply, or remove it.", path))
+		return 1
+	}
+
+	c.UI.Output(importReport(rows))
+	return 0
+}
+
+// validateImportMigrationDir returns an error if a generated migration file
+// cannot be kept in the migration dir of a given config. An archive given as
+// the migration dir is extracted to a temporary directory removed on exit, so
+// the generated file would be lost and couldn't be reviewed or applied again.
+func validateImportMigrationDir(cfg *config.TfmigrateConfig) error {
+	if len(cfg.MigrationArchive) > 0 {
+		return fmt.Errorf("The import command cannot write a migration file to an archive given as the migration_dir: %s\n"+
+			"Use a config with a plain directory as the migration_dir, and archive the generated file after that.", cfg.MigrationArchive)
+	}
+	return nil
+}
+
+// readCSV reads and parses rows to be imported from the --from-csv option.
+func (c *ImportCommand) readCSV() ([]importRow, error) {
+	var r io.Reader = os.Stdin
+	if c.fromCSV != "-" {
+		f, err := os.Open(c.fromCSV)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read CSV: %s", err)
+		}
+		defer f.Close()
+		r = f
+	}
+	return parseImportCSV(r)
+}
+
+// apply applies a given generated migration file. In history mode, it's
+// recorded in history as the same as the other migrations.
+func (c *ImportCommand) apply(filename string) (err error) {
+	ctx, span := tfmigrate.StartSpan(context.Background(), c.Option, "tfmigrate import")
+	defer func() { tfmigrate.EndSpan(span, err) }()
+
+	if c.config.History == nil {
+		fr, err := NewFileRunner(ctx, filename, c.config, c.Option)
+		if err != nil {
+			return err
+		}
+		return fr.Apply(ctx)
+	}
+
+	hr, err := NewHistoryRunner(ctx, filename, c.config, c.Option)
+	if err != nil {
+		return err
+	}
+	hr.SetUI(c.UI)
+	return hr.Apply(ctx)
+}
+
+// importRow is a row of a CSV file to be imported.
+type importRow struct {
+	// line is a line number in the CSV file for reporting.
+	line int
+	// address is an address to import resource to.
+	address string
+	// id is a resource identifier to be imported.
+	id string
+}
+
+// action returns an import action string of the row.
+func (r importRow) action() string {
+	return "import " + shellQuote(r.address) + " " + shellQuote(r.id)
+}
+
+// parseImportCSV reads rows of address and id pairs from a given reader.
+// A header row of `address,id` is skipped. Fields may be quoted as defined in
+// RFC 4180. It validates all rows and returns an error which lists all
+// invalid rows with their line numbers, so that they can be fixed at once.
+func parseImportCSV(r io.Reader) ([]importRow, error) {
+	cr := csv.NewReader(r)
+	cr.FieldsPerRecord = -1
+	cr.TrimLeadingSpace = true
+
+	rows := []importRow{}
+	errs := []error{}
+	seen := map[string]int{}
+	first := true
+	for {
+		record, err := cr.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse CSV: %s", err)
+		}
+		line, _ := cr.FieldPos(0)
+
+		if first {
+			first = false
+			if isImportCSVHeader(record) {
+				continue
+			}
+		}
+
+		if len(record) != 2 {
+			errs = append(errs, fmt.Errorf("line %d: expected 2 fields of address and id, but got %d", line, len(record)))
+			continue
+		}
+		row := importRow{
+			line:    line,
+			address: strings.TrimSpace(record[0]),
+			id:      strings.TrimSpace(record[1]),
+		}
+		if strings.IndexFunc(row.address+row.id, unicode.IsControl) != -1 {
+			errs = append(errs, fmt.Errorf("line %d: address and id must not contain control characters", line))
+			continue
+		}
+		if _, err := tfmigrate.NewStateActionFromString(row.action()); err != nil {
+			errs = append(errs, fmt.Errorf("line %d: %s", line, err))
+			continue
+		}
+		if prev, ok := seen[row.address]; ok {
+			errs = append(errs, fmt.Errorf("line %d: duplicate address %s, which is also on line %d", line, row.address, prev))
+			continue
+		}
+		seen[row.address] = line
+		rows = append(rows, row)
+	}
+
+	if len(errs) > 0 {
+		return nil, fmt.Errorf("invalid rows in CSV:\n%w", errors.Join(errs...))
+	}
+	if len(rows) == 0 {
+		return nil, fmt.Errorf("no rows to import in CSV")
+	}
+	return rows, nil
+}
+
+// isImportCSVHeader returns true if a given record is a header row.
+func isImportCSVHeader(record []string) bool {
+	return len(record) == 2 &&
+		strings.EqualFold(strings.TrimSpace(strings.TrimPrefix(record[0], "\ufeff")), "address") &&
+		strings.EqualFold(strings.TrimSpace(record[1]), "id")
+}
+
+// shellSafeRegex matches a string which doesn't need to be quoted in an action.
+var shellSafeRegex = regexp.MustCompile(`^[A-Za-z0-9_./:@,+=\[\]-]+$`)
+
+// shellQuote quotes a given string with single quotes if needed, so that it's
+// parsed as a single argument of an action.
+func shellQuote(s string) string {
+	if shellSafeRegex.MatchString(s) {
+		return s
+	}
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
+
+// hclQuote returns a given string as a quoted string literal in HCL.
+// Template sequences are escaped so that they're taken literally.
+func hclQuote(s string) string {
+	r := strings.NewReplacer(`\`, `\\`, `"`, `\"`, "${", "$${", "%{", "%%{")
+	return `"` + r.Replace(s) + `"`
+}
+
+// migrationNameRegex matches a valid name of a generated migration.
+var migrationNameRegex = regexp.MustCompile(`^[A-Za-z0-9_-]+$`)
+
+// invalidMigrationNameRegex matches characters not allowed in a name of a
+// generated migration.
+var invalidMigrationNameRegex = regexp.MustCompile(`[^A-Za-z0-9_-]+`)
+
+// defaultImportMigrationName returns a default name of a migration generated
+// from a given CSV file, such as `import_foo` for `path/to/foo.csv`.
+func defaultImportMigrationName(csvPath string) string {
+	if csvPath == "-" {
+		return "import"
+	}
+	base := strings.TrimSuffix(filepath.Base(csvPath), filepath.Ext(csvPath))
+	base = invalidMigrationNameRegex.ReplaceAllString(base, "_")
+	return "import_" + base
+}
+
+// importMigrationFilename returns a file name of a generated migration with a
+// timestamp prefix, so that it's ordered after the existing migrations.
+func importMigrationFilename(name string, now time.Time) string {
+	return now.UTC().Format("20060102150405") + "_" + name + ".hcl"
+}
+
+// generateImportMigration returns a source of a state migration file which
+// imports given rows in order.
+func generateImportMigration(name string, dir string, workspace string, rows []importRow, autoRollback bool, force bool) []byte {
+	var b strings.Builder
+	fmt.Fprintf(&b, "migration \"state\" %s {\n", hclQuote(name))
+	if len(dir) > 0 {
+		fmt.Fprintf(&b, "  dir = %s\n", hclQuote(dir))
+	}
+	if len(workspace) > 0 {
+		fmt.Fprintf(&b, "  workspace = %s\n", hclQuote(workspace))
+	}
+	if autoRollback {
+		b.WriteString("  auto_rollback = true\n")
+	}
+	if force {
+		b.WriteString("  force = true\n")
+	}
+	b.WriteString("  actions = [\n")
+	for _, r := range rows {
+		fmt.Fprintf(&b, "    %s,\n", hclQuote(r.action()))
+	}
+	b.WriteString("  ]\n")
+	b.WriteString("}\n")
+	return []byte(b.String())
+}
+
+// writeMigrationFile writes a given source to a new migration file.
+// It never overwrites an existing file.
+func writeMigrationFile(path string, source []byte) error {
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0644)
+	if err != nil {
+		return fmt.Errorf("failed to create a migration file: %s", err)
+	}
+	if _, err := f.Write(source); err != nil {
+		f.Close()
+		return fmt.Errorf("failed to write a migration file: %s", err)
+	}
+	if err := f.Close(); err != nil {
+		return fmt.Errorf("failed to write a migration file: %s", err)
+	}
+	return nil
+}
+
+// importReport returns a per-row report of imported resources.
+func importReport(rows []importRow) string {
+	lines := make([]string, 0, len(rows)+1)
+	lines = append(lines, fmt.Sprintf("Imported %d resources:", len(rows)))
+	for _, r := range rows {
+		lines = append(lines, fmt.Sprintf("OK    line %d: %s (id: %s)", r.line, r.address, r.id))
+	}
+	return strings.Join(lines, "\n")
+}
+
+// importFailureReport returns a per-row report of a failed import.
+// If a row failed, it's reported with the cause, and rows before it are
+// reported as imported. Otherwise, all rows were imported and the migration
+// failed after that, such as on the plan. Note that the imported rows are not
+// pushed to remote if the migration failed before push, because a new state
+// is pushed only if all succeed. If it failed after push, such as on
+// verifying the pushed state or saving history, it's reported as pushed.
+func importFailureReport(rows []importRow, err error) string {
+	failed := -1
+	var actionErr *tfmigrate.ActionError
+	if errors.As(err, &actionErr) && actionErr.Index < len(rows) {
+		failed = actionErr.Index
+	}
+	var pushedErr *tfmigrate.PushedError
+
+	lines := []string{}
+	switch {
+	case failed == -1 && errors.As(err, &pushedErr):
+		lines = append(lines, fmt.Sprintf("Imported all %d resources and the new state has been pushed, but the migration failed after that:", len(rows)))
+	case failed == -1:
+		lines = append(lines, fmt.Sprintf("Imported all %d resources, but the migration failed. Nothing has been pushed:", len(rows)))
+	default:
+		lines = append(lines, fmt.Sprintf("Failed to import line %d. Imported %d of %d resources before it, but nothing has been pushed:", rows[failed].line, failed, len(rows)))
+	}
+	for i, r := range rows {
+		switch {
+		case failed == -1 || i < failed:
+			lines = append(lines, fmt.Sprintf("OK    line %d: %s (id: %s)", r.line, r.address, r.id))
+		case i == failed:
+			lines = append(lines, fmt.Sprintf("FAIL  line %d: %s (id: %s): %s", r.line, r.address, r.id, actionErr.Err))
+		default:
+			lines = append(lines, fmt.Sprintf("SKIP  line %d: %s (id: %s)", r.line, r.address, r.id))
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+// Help returns long-form help text.
+func (c *ImportCommand) Help() string {
+	helpText := `
+Usage: tfmigrate import --from-csv=path [options]
+
+Import resources listed in a CSV file of address and id pairs.
+It generates a state migration file of import actions in the migration dir,
+and applies it. In history mode, it's recorded in history as the same as
+the other migrations. A header row of address,id is skipped, and fields
+may be quoted. All rows are validated before running terraform.
+A new state is pushed only if all imports succeed. If failed, it reports
+which row failed, and the generated file is kept for review. The migration
+dir must not be an archive, because the generated file would be lost.
+
+Options:
+  --from-csv=path          A path to a CSV file of address and id pairs. Use - for stdin.
+  --dir=path               A working directory where resources are imported.
+                           Default to the default_dir in the config file.
+  --workspace=name         A terraform workspace. Default to "default".
+  --name=name              A name of the generated migration.
+                           Default to import_<basename of the CSV file>.
+  --auto-rollback          Roll back imports which would be destroyed or replaced
+                           in the plan after importing. It matters with --force.
+  --force                  Apply the migration even if plan shows changes.
+  --config                 A path to tfmigrate config file
+  --backend-config=path    A backend configuration, a path to backend configuration file or
+                           key=value format backend configuraion.
+                           This option is passed to terraform init when switching backend to remote.
+  --log-level=level        A minimum log level. Valid values are TRACE, DEBUG, INFO, WARN and ERROR.
+                           It takes precedence over the TFMIGRATE_LOG environment variable
+                           and the log_level in the config file.
+  -v, --verbose            Show each terraform command executed with its full arguments in stdout.
+  --skip-init              Assume working dirs are already initialized and skip terraform init.
+`
+	return strings.TrimSpace(helpText)
+}
+
+// Synopsis returns one-line help text.
+func (c *ImportCommand) Synopsis() string {
+	return "Import resources listed in a CSV file"
+}
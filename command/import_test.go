@@ -0,0 +1,280 @@
+package command
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/mattn/go-shellwords"
+	"github.com/minamijoyo/tfmigrate/config"
+	"github.com/minamijoyo/tfmigrate/tfmigrate"
+)
+
+func TestParseImportCSV(t *testing.T) {
+	cases := []struct {
+		desc   string
+		source string
+		want   []importRow
+		ok     bool
+	}{
+		{
+			desc: "simple",
+			source: `aws_s3_bucket.foo,foo
+aws_s3_bucket.bar,bar
+`,
+			want: []importRow{
+				{line: 1, address: "aws_s3_bucket.foo", id: "foo"},
+				{line: 2, address: "aws_s3_bucket.bar", id: "bar"},
+			},
+			ok: true,
+		},
+		{
+			desc: "header and quoting",
+			source: `address, id
+"aws_s3_bucket.foo[""a,b""]", foo
+
+"module.x[""y""].aws_iam_role.baz","role with space"
+`,
+			want: []importRow{
+				{line: 2, address: `aws_s3_bucket.foo["a,b"]`, id: "foo"},
+				{line: 4, address: `module.x["y"].aws_iam_role.baz`, id: "role with space"},
+			},
+			ok: true,
+		},
+		{
+			desc: "invalid rows",
+			source: `aws_s3_bucket.foo,foo,extra
+module.foo,bar
+aws_s3_bucket.baz,
+aws_s3_bucket.qux,qux
+aws_s3_bucket.qux,qux2
+`,
+			want: nil,
+			ok:   false,
+		},
+		{
+			desc:   "only header",
+			source: "address,id\n",
+			want:   nil,
+			ok:     false,
+		},
+		{
+			desc:   "broken quote",
+			source: "\"aws_s3_bucket.foo,foo\n",
+			want:   nil,
+			ok:     false,
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.desc, func(t *testing.T) {
+			got, err := parseImportCSV(strings.NewReader(tc.source))
+			if tc.ok && err != nil {
+				t.Fatalf("unexpected err: %s", err)
+			}
+			if !tc.ok && err == nil {
+				t.Fatalf("expected to return an error, but no error, got: %#v", got)
+			}
+			if tc.ok && !reflect.DeepEqual(got, tc.want) {
+				t.Errorf("got: %#v, want: %#v", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestParseImportCSVReportsAllInvalidRows(t *testing.T) {
+	source := `aws_s3_bucket.foo,foo,extra
+aws_s3_bucket.bar,bar
+module.foo,bar
+aws_s3_bucket.bar,bar2
+`
+	_, err := parseImportCSV(strings.NewReader(source))
+	if err == nil {
+		t.Fatal("expected to return an error, but no error")
+	}
+	for _, want := range []string{"line 1:", "line 3:", "line 4: duplicate address aws_s3_bucket.bar, which is also on line 2"} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("expected an error to contain %q, but got: %s", want, err)
+		}
+	}
+	if strings.Contains(err.Error(), "line 2:") {
+		t.Errorf("unexpected error for a valid row: %s", err)
+	}
+}
+
+func TestGenerateImportMigration(t *testing.T) {
+	rows := []importRow{
+		{line: 1, address: "aws_s3_bucket.foo", id: "foo"},
+		{line: 2, address: `aws_s3_bucket.bar["a"]`, id: "it's ${bar}"},
+		{line: 3, address: "aws_iam_role.baz", id: `C:\baz %{x}`},
+	}
+	source := generateImportMigration("import_test", "dir1", "prod", rows, true, true)
+
+	got, err := config.ParseMigrationFile("test.hcl", source)
+	if err != nil {
+		t.Fatalf("failed to parse a generated migration: %s\n%s", err, source)
+	}
+	want := &tfmigrate.MigrationConfig{
+		Type: "state",
+		Name: "import_test",
+		Migrator: &tfmigrate.StateMigratorConfig{
+			Dir:          "dir1",
+			Workspace:    "prod",
+			AutoRollback: true,
+			Force:        true,
+			Actions: []string{
+				"import aws_s3_bucket.foo foo",
+				`import 'aws_s3_bucket.bar["a"]' 'it'\''s ${bar}'`,
+				`import aws_iam_role.baz 'C:\baz %{x}'`,
+			},
+		},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got: %#v, want: %#v", got, want)
+	}
+
+	// The actions should be parsed back to the original address and id.
+	for i, action := range want.Migrator.(*tfmigrate.StateMigratorConfig).Actions {
+		args, err := shellwords.Parse(action)
+		if err != nil {
+			t.Fatalf("failed to split action: %s", err)
+		}
+		if args[1] != rows[i].address || args[2] != rows[i].id {
+			t.Errorf("got args: %#v, want: %s %s", args, rows[i].address, rows[i].id)
+		}
+	}
+}
+
+func TestDefaultImportMigrationName(t *testing.T) {
+	cases := []struct {
+		csvPath string
+		want    string
+	}{
+		{csvPath: "path/to/foo.csv", want: "import_foo"},
+		{csvPath: "discovered buckets.v2.csv", want: "import_discovered_buckets_v2"},
+		{csvPath: "-", want: "import"},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.csvPath, func(t *testing.T) {
+			got := defaultImportMigrationName(tc.csvPath)
+			if got != tc.want {
+				t.Errorf("got: %s, want: %s", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestImportMigrationFilename(t *testing.T) {
+	now := time.Date(2020, 11, 9, 1, 2, 3, 0, time.UTC)
+	got := importMigrationFilename("import_foo", now)
+	want := "20201109010203_import_foo.hcl"
+	if got != want {
+		t.Errorf("got: %s, want: %s", got, want)
+	}
+}
+
+func TestImportFailureReport(t *testing.T) {
+	rows := []importRow{
+		{line: 2, address: "aws_s3_bucket.foo", id: "foo"},
+		{line: 3, address: "aws_s3_bucket.bar", id: "bar"},
+		{line: 4, address: "aws_s3_bucket.baz", id: "baz"},
+	}
+	cases := []struct {
+		desc string
+		err  error
+		want string
+	}{
+		{
+			desc: "a row failed",
+			err:  &tfmigrate.ActionError{Index: 1, Err: errors.New("cannot import non-existent remote object")},
+			want: `Failed to import line 3. Imported 1 of 3 resources before it, but nothing has been pushed:
+OK    line 2: aws_s3_bucket.foo (id: foo)
+FAIL  line 3: aws_s3_bucket.bar (id: bar): cannot import non-existent remote object
+SKIP  line 4: aws_s3_bucket.baz (id: baz)`,
+		},
+		{
+			desc: "the first row failed with a wrapped error",
+			err:  fmt.Errorf("failed to apply: %w", &tfmigrate.ActionError{Index: 0, Err: errors.New("foo")}),
+			want: `Failed to import line 2. Imported 0 of 3 resources before it, but nothing has been pushed:
+FAIL  line 2: aws_s3_bucket.foo (id: foo): foo
+SKIP  line 3: aws_s3_bucket.bar (id: bar)
+SKIP  line 4: aws_s3_bucket.baz (id: baz)`,
+		},
+		{
+			desc: "failed after all rows imported",
+			err:  errors.New("terraform plan command returns unexpected diffs"),
+			want: `Imported all 3 resources, but the migration failed. Nothing has been pushed:
+OK    line 2: aws_s3_bucket.foo (id: foo)
+OK    line 3: aws_s3_bucket.bar (id: bar)
+OK    line 4: aws_s3_bucket.baz (id: baz)`,
+		},
+		{
+			desc: "failed to verify the pushed state",
+			err:  &tfmigrate.PushedError{Err: errors.New("failed to verify the pushed state in dir1: serial mismatch")},
+			want: `Imported all 3 resources and the new state has been pushed, but the migration failed after that:
+OK    line 2: aws_s3_bucket.foo (id: foo)
+OK    line 3: aws_s3_bucket.bar (id: bar)
+OK    line 4: aws_s3_bucket.baz (id: baz)`,
+		},
+		{
+			desc: "failed to save history",
+			err:  &tfmigrate.PushedError{Err: errors.New("apply succeed, but failed to save history: conflict")},
+			want: `Imported all 3 resources and the new state has been pushed, but the migration failed after that:
+OK    line 2: aws_s3_bucket.foo (id: foo)
+OK    line 3: aws_s3_bucket.bar (id: bar)
+OK    line 4: aws_s3_bucket.baz (id: baz)`,
+		},
+		{
+			desc: "a row failed and failed to save history",
+			err:  fmt.Errorf("failed to save history: conflict, failed to apply: %w", &tfmigrate.ActionError{Index: 2, Err: errors.New("baz")}),
+			want: `Failed to import line 4. Imported 2 of 3 resources before it, but nothing has been pushed:
+OK    line 2: aws_s3_bucket.foo (id: foo)
+OK    line 3: aws_s3_bucket.bar (id: bar)
+FAIL  line 4: aws_s3_bucket.baz (id: baz): baz`,
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.desc, func(t *testing.T) {
+			got := importFailureReport(rows, tc.err)
+			if got != tc.want {
+				t.Errorf("got:\n%s\nwant:\n%s", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestValidateImportMigrationDir(t *testing.T) {
+	cases := []struct {
+		desc string
+		cfg  *config.TfmigrateConfig
+		ok   bool
+	}{
+		{
+			desc: "directory",
+			cfg:  &config.TfmigrateConfig{MigrationDir: "tfmigrate"},
+			ok:   true,
+		},
+		{
+			desc: "archive",
+			cfg:  &config.TfmigrateConfig{MigrationDir: "/tmp/tfmigrate-migrations-123", MigrationArchive: "migrations.tgz"},
+			ok:   false,
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.desc, func(t *testing.T) {
+			err := validateImportMigrationDir(tc.cfg)
+			if tc.ok && err != nil {
+				t.Fatalf("unexpected err: %s", err)
+			}
+			if !tc.ok && err == nil {
+				t.Fatal("expected to return an error, but no error")
+			}
+		})
+	}
+}
@@ -14,7 +14,8 @@ import (
 // ListCommand is a command which lists migrations.
 type ListCommand struct {
 	Meta
-	status string
+	status   string
+	duration bool
 }
 
 // Run runs the procedure of this command.
@@ -22,17 +23,31 @@ func (c *ListCommand) Run(args []string) int {
 	cmdFlags := flag.NewFlagSet("list", flag.ContinueOnError)
 	cmdFlags.StringVar(&c.configFile, "config", defaultConfigFile, "A path to tfmigrate config file")
 	cmdFlags.StringVar(&c.status, "status", "all", "A filter for migration status")
+	cmdFlags.BoolVar(&c.duration, "duration", false, "Show how long each applied migration took")
+	cmdFlags.StringVar(&c.logLevel, "log-level", "", "A minimum log level")
 
 	if err := cmdFlags.Parse(args); err != nil {
 		c.UI.Error(fmt.Sprintf("failed to parse arguments: %s", err))
 		return 1
 	}
+	if err := c.setLogLevel(""); err != nil {
+		c.UI.Error(fmt.Sprintf("failed to set log level: %s", err))
+		return 1
+	}
 
 	var err error
 	if c.config, err = newConfig(c.configFile); err != nil {
 		c.UI.Error(fmt.Sprintf("failed to load config file: %s", err))
 		return 1
 	}
+	if err = c.setLogLevel(c.config.LogLevel); err != nil {
+		c.UI.Error(fmt.Sprintf("failed to set log level: %s", err))
+		return 1
+	}
+	if err = c.Redactor.AddPatterns(c.config.RedactPatterns); err != nil {
+		c.UI.Error(fmt.Sprintf("failed to add redact patterns: %s", err))
+		return 1
+	}
 	log.Printf("[DEBUG] [command] config: %#v\n", c.config)
 
 	c.Option = newOption()
@@ -48,7 +63,7 @@ func (c *ListCommand) Run(args []string) int {
 
 	// history mode
 	ctx := context.Background()
-	out, err := listMigrations(ctx, c.config, c.status)
+	out, err := listMigrations(ctx, c.config, c.status, c.duration)
 	if err != nil {
 		c.UI.Error(err.Error())
 		return 1
@@ -58,7 +73,9 @@ func (c *ListCommand) Run(args []string) int {
 }
 
 // listMigrations lists migrations.
-func listMigrations(ctx context.Context, config *config.TfmigrateConfig, status string) (string, error) {
+// If duration is true, each line is followed by a tab and how long the
+// migration took to apply, or `-` if unknown or not applied.
+func listMigrations(ctx context.Context, config *config.TfmigrateConfig, status string, duration bool) (string, error) {
 	hc, err := history.NewController(ctx, config.MigrationDir, config.History)
 	if err != nil {
 		return "", err
@@ -72,10 +89,25 @@ func listMigrations(ctx context.Context, config *config.TfmigrateConfig, status
 	case "unapplied":
 		migrations = hc.UnappliedMigrations()
 
+	case "planned":
+		migrations = hc.PlannedMigrations()
+
 	default:
 		return "", fmt.Errorf("unknown filter for status: %s", status)
 	}
 
+	if duration {
+		lines := []string{}
+		for _, m := range migrations {
+			d := "-"
+			if r, ok := hc.FindRecord(m); ok && r.Duration != 0 {
+				d = r.Duration.String()
+			}
+			lines = append(lines, m+"\t"+d)
+		}
+		migrations = lines
+	}
+
 	out := strings.Join(migrations, "\n")
 	return out, nil
 }
@@ -93,6 +125,12 @@ Options:
                      Valid values are as follows:
                        - all (default)
                        - unapplied
+                       - planned (marked as planned but not applied yet)
+  --duration         Show how long each migration took to apply after a tab.
+                     It's - if unknown or not applied.
+  --log-level        A minimum log level. Valid values are TRACE, DEBUG, INFO, WARN and ERROR.
+                     It takes precedence over the TFMIGRATE_LOG environment variable
+                     and the log_level in the config file.
 `
 	return strings.TrimSpace(helpText)
 }
@@ -42,12 +42,19 @@ migration "mock" "test4" {
         "20201109000001_test1.hcl": {
             "type": "mock",
             "name": "test1",
-            "applied_at": "2020-11-10T00:00:01Z"
+            "applied_at": "2020-11-10T00:00:01Z",
+            "duration": "1m2.5s"
         },
         "20201109000002_test2.hcl": {
             "type": "mock",
             "name": "test2",
             "applied_at": "2020-11-10T00:00:02Z"
+        },
+        "20201109000003_test3.hcl": {
+            "type": "mock",
+            "name": "test3",
+            "applied_at": "2020-11-10T00:00:03Z",
+            "status": "planned"
         }
     }
 }`
@@ -55,6 +62,7 @@ migration "mock" "test4" {
 	cases := []struct {
 		desc        string
 		status      string
+		duration    bool
 		migrations  map[string]string
 		historyFile string
 		want        string
@@ -80,6 +88,26 @@ migration "mock" "test4" {
 20201109000004_test4.hcl`,
 			ok: true,
 		},
+		{
+			desc:        "planned",
+			status:      "planned",
+			migrations:  migrations,
+			historyFile: historyFile,
+			want:        `20201109000003_test3.hcl`,
+			ok:          true,
+		},
+		{
+			desc:        "all with duration",
+			status:      "all",
+			duration:    true,
+			migrations:  migrations,
+			historyFile: historyFile,
+			want: "20201109000001_test1.hcl\t1m2.5s\n" +
+				"20201109000002_test2.hcl\t-\n" +
+				"20201109000003_test3.hcl\t-\n" +
+				"20201109000004_test4.hcl\t-",
+			ok: true,
+		},
 		{
 			desc:        "unknown status",
 			status:      "foo",
@@ -104,7 +132,7 @@ migration "mock" "test4" {
 					Storage: storage,
 				},
 			}
-			got, err := listMigrations(context.Background(), config, tc.status)
+			got, err := listMigrations(context.Background(), config, tc.status, tc.duration)
 			if tc.ok && err != nil {
 				t.Fatalf("unexpected err: %s", err)
 			}
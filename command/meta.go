@@ -1,10 +1,16 @@
 package command
 
 import (
+	"bytes"
+	"fmt"
 	"log"
 	"os"
+	"strconv"
+	"time"
 
+	"github.com/hashicorp/logutils"
 	"github.com/minamijoyo/tfmigrate/config"
+	"github.com/minamijoyo/tfmigrate/redact"
 	"github.com/minamijoyo/tfmigrate/tfmigrate"
 	"github.com/mitchellh/cli"
 )
@@ -17,9 +23,35 @@ type Meta struct {
 	// UI is a user interface representing input and output.
 	UI cli.Ui
 
+	// Redactor masks secrets in log and error output.
+	// Patterns in the config file are added to it after loading.
+	Redactor *redact.Redactor
+
+	// LogFilter filters log output by a minimum log level.
+	// The level can be changed by the --log-level flag or the config file.
+	LogFilter *logutils.LevelFilter
+
+	// A minimum log level set by the --log-level flag.
+	logLevel string
+
 	// A path to tfmigrate config file.
 	configFile string
 
+	// Force colored output even if the stdout is not a terminal.
+	color bool
+
+	// Disable colored output.
+	noColor bool
+
+	// Suppress log output unless a command fails.
+	quiet bool
+
+	// Refuse any mutation of state and history.
+	readOnly bool
+
+	// Show terraform commands executed.
+	verbose bool
+
 	// a global configuration for tfmigrate.
 	config *config.TfmigrateConfig
 
@@ -38,7 +70,15 @@ func newConfig(filename string) (*config.TfmigrateConfig, error) {
 	}
 
 	log.Printf("[DEBUG] [command] load configuration file: %s\n", filename)
-	return config.LoadConfigurationFile(filename)
+	cfg, err := config.LoadConfigurationFile(filename)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := loadMigrationArchive(cfg); err != nil {
+		return nil, err
+	}
+	return cfg, nil
 }
 
 func newOption() *tfmigrate.MigratorOption {
@@ -46,3 +86,107 @@ func newOption() *tfmigrate.MigratorOption {
 		ExecPath: os.Getenv("TFMIGRATE_EXEC_PATH"),
 	}
 }
+
+// readOnlyEnabled returns true if read-only mode is enabled by the
+// --read-only flag or the TFMIGRATE_READ_ONLY environment variable.
+// An unparsable value of the environment variable also enables it to fail
+// closed.
+func (m *Meta) readOnlyEnabled() bool {
+	if m.readOnly {
+		return true
+	}
+	v := os.Getenv("TFMIGRATE_READ_ONLY")
+	if len(v) == 0 {
+		return false
+	}
+	b, err := strconv.ParseBool(v)
+	return err != nil || b
+}
+
+// setReadOnly plumbs read-only mode into the config and the option if
+// enabled, so that writes to history storage and terraform commands which
+// may mutate remote state are refused at the lowest level.
+func (m *Meta) setReadOnly() {
+	if !m.readOnlyEnabled() {
+		return
+	}
+	log.Printf("[INFO] [command] read-only mode: refuse any mutation of state and history\n")
+	if m.config != nil && m.config.History != nil {
+		m.config.History.ReadOnly = true
+	}
+	if m.Option != nil {
+		m.Option.ReadOnly = true
+	}
+}
+
+// setVerbose plumbs verbose mode into the option if enabled, so that each
+// terraform command line is written to the stdout. Secrets in the command
+// line are redacted as the same as log output.
+func (m *Meta) setVerbose() {
+	if !m.verbose || m.Option == nil {
+		return
+	}
+	m.Option.CommandLog = m.Redactor.Writer(os.Stdout)
+}
+
+// checkTimeout returns an error if a given timeout flag is negative.
+// A zero value means no timeout.
+func checkTimeout(name string, d time.Duration) error {
+	if d < 0 {
+		return fmt.Errorf("The --%s option must not be negative: %s", name, d)
+	}
+	return nil
+}
+
+// resolveLogLevel returns a minimum log level to be used.
+// The --log-level flag takes precedence over the TFMIGRATE_LOG environment
+// variable, and the environment variable takes precedence over the log_level
+// in the config file. It returns an empty string if none of them is set.
+func resolveLogLevel(flagLevel string, envLevel string, configLevel string) (string, error) {
+	if len(flagLevel) > 0 {
+		return config.ParseLogLevel(flagLevel)
+	}
+	if len(envLevel) > 0 {
+		// The environment variable has already been applied on startup.
+		return "", nil
+	}
+	return configLevel, nil
+}
+
+// setLogLevel updates a minimum log level of the LogFilter.
+// It's a no-op if the LogFilter is not set.
+func (m *Meta) setLogLevel(configLevel string) error {
+	level, err := resolveLogLevel(m.logLevel, os.Getenv("TFMIGRATE_LOG"), configLevel)
+	if err != nil {
+		return err
+	}
+	if m.LogFilter != nil && len(level) > 0 {
+		m.LogFilter.SetMinLevel(logutils.LogLevel(level))
+	}
+	return nil
+}
+
+// runQuietly runs a given function with log output captured in quiet mode.
+// If the function fails, the captured log is written to the original output
+// so that debugging is still possible. Otherwise it's discarded.
+// It just runs the function if quiet mode is disabled or the LogFilter is not set.
+func (m *Meta) runQuietly(f func() error) error {
+	if !m.quiet || m.LogFilter == nil {
+		return f()
+	}
+
+	w := m.LogFilter.Writer
+	var buf bytes.Buffer
+	m.LogFilter.Writer = &buf
+	err := f()
+	m.LogFilter.Writer = w
+
+	if err != nil {
+		// Output of terraform commands is captured and included in the log
+		// and the error, so dump it to help debugging.
+		if _, werr := w.Write(buf.Bytes()); werr != nil {
+			log.Printf("[ERROR] [command] failed to write captured log: %s\n", werr)
+		}
+	}
+	return err
+}
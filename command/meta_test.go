@@ -0,0 +1,192 @@
+package command
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/hashicorp/logutils"
+	"github.com/minamijoyo/tfmigrate/config"
+	"github.com/minamijoyo/tfmigrate/history"
+	"github.com/minamijoyo/tfmigrate/tfmigrate"
+)
+
+func TestResolveLogLevel(t *testing.T) {
+	cases := []struct {
+		desc        string
+		flagLevel   string
+		envLevel    string
+		configLevel string
+		want        string
+		ok          bool
+	}{
+		{
+			desc: "not set",
+			want: "",
+			ok:   true,
+		},
+		{
+			desc:        "config only",
+			configLevel: "DEBUG",
+			want:        "DEBUG",
+			ok:          true,
+		},
+		{
+			desc:        "env takes precedence over config",
+			envLevel:    "WARN",
+			configLevel: "DEBUG",
+			want:        "",
+			ok:          true,
+		},
+		{
+			desc:        "flag takes precedence over env and config",
+			flagLevel:   "trace",
+			envLevel:    "WARN",
+			configLevel: "DEBUG",
+			want:        "TRACE",
+			ok:          true,
+		},
+		{
+			desc:      "invalid flag",
+			flagLevel: "verbose",
+			want:      "",
+			ok:        false,
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.desc, func(t *testing.T) {
+			got, err := resolveLogLevel(tc.flagLevel, tc.envLevel, tc.configLevel)
+			if tc.ok && err != nil {
+				t.Fatalf("unexpected err: %s", err)
+			}
+			if !tc.ok && err == nil {
+				t.Fatalf("expected to return an error, but no error, got: %s", got)
+			}
+			if got != tc.want {
+				t.Errorf("got: %s, want: %s", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestRunQuietly(t *testing.T) {
+	cases := []struct {
+		desc  string
+		quiet bool
+		err   error
+		want  string
+		ok    bool
+	}{
+		{
+			desc:  "not quiet",
+			quiet: false,
+			want:  "[INFO] foo\n",
+			ok:    true,
+		},
+		{
+			desc:  "quiet and succeeded",
+			quiet: true,
+			want:  "",
+			ok:    true,
+		},
+		{
+			desc:  "quiet and failed",
+			quiet: true,
+			err:   errors.New("failed"),
+			want:  "[INFO] foo\n",
+			ok:    false,
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.desc, func(t *testing.T) {
+			var out bytes.Buffer
+			filter := &logutils.LevelFilter{
+				Levels:   []logutils.LogLevel{"DEBUG", "INFO"},
+				MinLevel: logutils.LogLevel("INFO"),
+				Writer:   &out,
+			}
+			m := &Meta{LogFilter: filter, quiet: tc.quiet}
+			err := m.runQuietly(func() error {
+				fmt.Fprint(filter, "[DEBUG] bar\n")
+				fmt.Fprint(filter, "[INFO] foo\n")
+				return tc.err
+			})
+			if tc.ok && err != nil {
+				t.Fatalf("unexpected err: %s", err)
+			}
+			if !tc.ok && err == nil {
+				t.Fatal("expected to return an error, but no error")
+			}
+			if filter.Writer != &out {
+				t.Error("expected to restore the original writer")
+			}
+			if got := out.String(); got != tc.want {
+				t.Errorf("got: %q, want: %q", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestMetaSetReadOnly(t *testing.T) {
+	cases := []struct {
+		desc     string
+		flag     bool
+		env      string
+		readOnly bool
+	}{
+		{
+			desc:     "disabled",
+			flag:     false,
+			env:      "",
+			readOnly: false,
+		},
+		{
+			desc:     "flag",
+			flag:     true,
+			env:      "",
+			readOnly: true,
+		},
+		{
+			desc:     "env true",
+			flag:     false,
+			env:      "true",
+			readOnly: true,
+		},
+		{
+			desc:     "env false",
+			flag:     false,
+			env:      "0",
+			readOnly: false,
+		},
+		{
+			desc:     "env invalid",
+			flag:     false,
+			env:      "yes",
+			readOnly: true,
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.desc, func(t *testing.T) {
+			t.Setenv("TFMIGRATE_READ_ONLY", tc.env)
+			m := &Meta{
+				readOnly: tc.flag,
+				config: &config.TfmigrateConfig{
+					History: &history.Config{},
+				},
+				Option: &tfmigrate.MigratorOption{},
+			}
+
+			m.setReadOnly()
+			if m.config.History.ReadOnly != tc.readOnly {
+				t.Errorf("got history read-only: %t, want: %t", m.config.History.ReadOnly, tc.readOnly)
+			}
+			if m.Option.ReadOnly != tc.readOnly {
+				t.Errorf("got option read-only: %t, want: %t", m.Option.ReadOnly, tc.readOnly)
+			}
+		})
+	}
+}
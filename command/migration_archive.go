@@ -0,0 +1,138 @@
+package command
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/minamijoyo/tfmigrate/config"
+)
+
+// archiveDirs is a list of temporary directories where migration archives
+// are extracted. They are removed by Cleanup.
+var (
+	archiveDirs   []string
+	archiveDirsMu sync.Mutex
+)
+
+// Cleanup removes temporary files created by commands such as extracted
+// migration archives. It's intended to be called before exiting the process.
+func Cleanup() {
+	archiveDirsMu.Lock()
+	defer archiveDirsMu.Unlock()
+	for _, dir := range archiveDirs {
+		if err := os.RemoveAll(dir); err != nil {
+			log.Printf("[WARN] [command] failed to remove a temporary directory %s: %s\n", dir, err)
+		}
+	}
+	archiveDirs = nil
+}
+
+// loadMigrationArchive extracts migration files from an archive given as the
+// migration dir to a temporary directory, and replaces the migration dir in a
+// given config with it. It's a no-op if the migration dir is not an archive.
+// The archive is verified with the checksum in the config if set.
+func loadMigrationArchive(cfg *config.TfmigrateConfig) error {
+	if !config.IsMigrationArchive(cfg.MigrationDir) {
+		return nil
+	}
+
+	dir, err := os.MkdirTemp(cfg.TmpDir, "tfmigrate-migrations-")
+	if err != nil {
+		return fmt.Errorf("failed to create a temporary directory for migration archive: %s", err)
+	}
+	archiveDirsMu.Lock()
+	archiveDirs = append(archiveDirs, dir)
+	archiveDirsMu.Unlock()
+
+	log.Printf("[INFO] [command] extract a migration archive %s to %s\n", cfg.MigrationDir, dir)
+	if err := extractMigrationArchive(cfg.MigrationDir, cfg.MigrationArchiveSHA256, dir); err != nil {
+		return err
+	}
+	cfg.MigrationArchive = cfg.MigrationDir
+	cfg.MigrationDir = dir
+	return nil
+}
+
+// extractMigrationArchive extracts migration files from a given .tar.gz
+// archive to a given dir. If a checksum is not empty, it returns an error
+// unless the SHA-256 checksum of the archive matches it.
+// Only regular files at the top level of the archive are extracted, because
+// nested directories are not scanned as migration files. An entry which
+// escapes the top level such as an absolute path or `..` is rejected.
+func extractMigrationArchive(archive string, checksum string, dir string) error {
+	b, err := os.ReadFile(archive)
+	if err != nil {
+		return fmt.Errorf("failed to read migration archive: %s", err)
+	}
+
+	if len(checksum) > 0 {
+		sum := sha256.Sum256(b)
+		got := hex.EncodeToString(sum[:])
+		if got != strings.ToLower(checksum) {
+			return fmt.Errorf("checksum mismatch of migration archive %s: got = %s, want = %s", archive, got, checksum)
+		}
+		log.Printf("[INFO] [command] verified the checksum of migration archive: %s\n", archive)
+	}
+
+	gz, err := gzip.NewReader(bytes.NewReader(b))
+	if err != nil {
+		return fmt.Errorf("failed to read migration archive %s: %s", archive, err)
+	}
+	defer gz.Close()
+
+	tr := tar.NewReader(gz)
+	for {
+		hdr, err := tr.Next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return fmt.Errorf("failed to read migration archive %s: %s", archive, err)
+		}
+
+		name := path.Clean(hdr.Name)
+		if path.IsAbs(name) || name == ".." || strings.HasPrefix(name, "../") {
+			return fmt.Errorf("invalid entry in migration archive %s: %s", archive, hdr.Name)
+		}
+		if hdr.Typeflag != tar.TypeReg || strings.Contains(name, "/") {
+			log.Printf("[DEBUG] [command] skip an entry in migration archive: %s\n", hdr.Name)
+			continue
+		}
+
+		dst := filepath.Join(dir, name)
+		if err := writeArchiveEntry(dst, tr, hdr.Size); err != nil {
+			return fmt.Errorf("failed to extract %s from migration archive %s: %s", hdr.Name, archive, err)
+		}
+		// keep the modification time for the mtime order of history.
+		if err := os.Chtimes(dst, hdr.ModTime, hdr.ModTime); err != nil {
+			return fmt.Errorf("failed to extract %s from migration archive %s: %s", hdr.Name, archive, err)
+		}
+	}
+	return nil
+}
+
+// writeArchiveEntry writes contents of an archive entry with a given size to
+// a given path.
+func writeArchiveEntry(dst string, r io.Reader, size int64) error {
+	f, err := os.OpenFile(dst, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0600)
+	if err != nil {
+		return err
+	}
+	if _, err := io.CopyN(f, r, size); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
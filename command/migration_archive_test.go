@@ -0,0 +1,200 @@
+package command
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"os"
+	"path/filepath"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/minamijoyo/tfmigrate/config"
+)
+
+// writeTestArchive writes a .tar.gz archive with given entries to a given
+// path and returns a hex-encoded SHA-256 checksum of it.
+// An entry with an empty content is written as a directory.
+func writeTestArchive(t *testing.T, path string, entries []testArchiveEntry) string {
+	t.Helper()
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	tw := tar.NewWriter(gz)
+	for _, e := range entries {
+		hdr := &tar.Header{
+			Name:    e.name,
+			Mode:    0600,
+			Size:    int64(len(e.content)),
+			ModTime: e.modTime,
+		}
+		if len(e.content) == 0 {
+			hdr.Typeflag = tar.TypeDir
+			hdr.Mode = 0700
+		}
+		if err := tw.WriteHeader(hdr); err != nil {
+			t.Fatalf("failed to write tar header: %s", err)
+		}
+		if _, err := tw.Write([]byte(e.content)); err != nil {
+			t.Fatalf("failed to write tar entry: %s", err)
+		}
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatalf("failed to close tar writer: %s", err)
+	}
+	if err := gz.Close(); err != nil {
+		t.Fatalf("failed to close gzip writer: %s", err)
+	}
+	if err := os.WriteFile(path, buf.Bytes(), 0600); err != nil {
+		t.Fatalf("failed to write archive: %s", err)
+	}
+	sum := sha256.Sum256(buf.Bytes())
+	return hex.EncodeToString(sum[:])
+}
+
+type testArchiveEntry struct {
+	name    string
+	content string
+	modTime time.Time
+}
+
+func TestExtractMigrationArchive(t *testing.T) {
+	mtime := time.Date(2020, 10, 12, 1, 1, 1, 0, time.UTC)
+	cases := []struct {
+		desc     string
+		entries  []testArchiveEntry
+		checksum func(sum string) string
+		want     []string
+		ok       bool
+	}{
+		{
+			desc: "simple",
+			entries: []testArchiveEntry{
+				{name: "20201012010101_foo.hcl", content: "migration \"state\" \"foo\" {}", modTime: mtime},
+				{name: "20201012020202_bar.hcl", content: "migration \"state\" \"bar\" {}", modTime: mtime},
+			},
+			want: []string{"20201012010101_foo.hcl", "20201012020202_bar.hcl"},
+			ok:   true,
+		},
+		{
+			desc: "with a leading ./ and nested entries",
+			entries: []testArchiveEntry{
+				{name: "./", modTime: mtime},
+				{name: "./20201012010101_foo.hcl", content: "migration \"state\" \"foo\" {}", modTime: mtime},
+				{name: "./nested/", modTime: mtime},
+				{name: "./nested/20201012020202_bar.hcl", content: "migration \"state\" \"bar\" {}", modTime: mtime},
+			},
+			want: []string{"20201012010101_foo.hcl"},
+			ok:   true,
+		},
+		{
+			desc: "checksum matched",
+			entries: []testArchiveEntry{
+				{name: "20201012010101_foo.hcl", content: "migration \"state\" \"foo\" {}", modTime: mtime},
+			},
+			checksum: func(sum string) string { return sum },
+			want:     []string{"20201012010101_foo.hcl"},
+			ok:       true,
+		},
+		{
+			desc: "checksum mismatch",
+			entries: []testArchiveEntry{
+				{name: "20201012010101_foo.hcl", content: "migration \"state\" \"foo\" {}", modTime: mtime},
+			},
+			checksum: func(string) string {
+				return "0000000000000000000000000000000000000000000000000000000000000000"
+			},
+			ok: false,
+		},
+		{
+			desc: "escape the top level",
+			entries: []testArchiveEntry{
+				{name: "../20201012010101_foo.hcl", content: "migration \"state\" \"foo\" {}", modTime: mtime},
+			},
+			ok: false,
+		},
+		{
+			desc: "absolute path",
+			entries: []testArchiveEntry{
+				{name: "/tmp/20201012010101_foo.hcl", content: "migration \"state\" \"foo\" {}", modTime: mtime},
+			},
+			ok: false,
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.desc, func(t *testing.T) {
+			archive := filepath.Join(t.TempDir(), "migrations.tar.gz")
+			sum := writeTestArchive(t, archive, tc.entries)
+			checksum := ""
+			if tc.checksum != nil {
+				checksum = tc.checksum(sum)
+			}
+			dir := t.TempDir()
+
+			err := extractMigrationArchive(archive, checksum, dir)
+			if tc.ok && err != nil {
+				t.Fatalf("unexpected err: %s", err)
+			}
+			if !tc.ok && err == nil {
+				t.Fatal("expected to return an error, but no error")
+			}
+			if !tc.ok {
+				return
+			}
+
+			files, err := os.ReadDir(dir)
+			if err != nil {
+				t.Fatalf("failed to read dir: %s", err)
+			}
+			got := []string{}
+			for _, f := range files {
+				got = append(got, f.Name())
+				info, err := f.Info()
+				if err != nil {
+					t.Fatalf("failed to get file info: %s", err)
+				}
+				if !info.ModTime().Equal(mtime) {
+					t.Errorf("mtime of %s: got = %s, want = %s", f.Name(), info.ModTime(), mtime)
+				}
+			}
+			sort.Strings(got)
+			if diff := cmp.Diff(got, tc.want); diff != "" {
+				t.Errorf("got: %v, want: %v, diff: %s", got, tc.want, diff)
+			}
+		})
+	}
+}
+
+func TestLoadMigrationArchive(t *testing.T) {
+	archive := filepath.Join(t.TempDir(), "migrations.tgz")
+	sum := writeTestArchive(t, archive, []testArchiveEntry{
+		{name: "20201012010101_foo.hcl", content: "migration \"state\" \"foo\" {}", modTime: time.Now()},
+	})
+	cfg := &config.TfmigrateConfig{
+		MigrationDir:           archive,
+		MigrationArchiveSHA256: sum,
+		TmpDir:                 t.TempDir(),
+	}
+
+	if err := loadMigrationArchive(cfg); err != nil {
+		t.Fatalf("unexpected err: %s", err)
+	}
+	if cfg.MigrationDir == archive {
+		t.Fatalf("expected the migration dir to be replaced, but got = %s", cfg.MigrationDir)
+	}
+	if cfg.MigrationArchive != archive {
+		t.Errorf("got migration archive = %s, want = %s", cfg.MigrationArchive, archive)
+	}
+	if _, err := os.Stat(filepath.Join(cfg.MigrationDir, "20201012010101_foo.hcl")); err != nil {
+		t.Fatalf("failed to stat an extracted migration file: %s", err)
+	}
+
+	Cleanup()
+	if _, err := os.Stat(cfg.MigrationDir); !os.IsNotExist(err) {
+		t.Errorf("expected the extracted dir to be removed, but got err = %v", err)
+	}
+}
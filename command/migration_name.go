@@ -0,0 +1,46 @@
+package command
+
+import (
+	"fmt"
+	"log"
+	"path/filepath"
+	"strings"
+
+	"github.com/minamijoyo/tfmigrate/history"
+)
+
+// findMigrationFileByName returns a file name of a migration whose block
+// declares a given name in a given migration dir. The migration files are
+// indexed by name on every call, which is fine for a command run once.
+// It returns an error if no migration or more than one migration has the name.
+// Only the migration block labels are parsed, so remote states referenced by
+// remote_state blocks are not read. A file which cannot be parsed is skipped.
+func findMigrationFileByName(migrationDir string, name string) (string, error) {
+	files, err := history.MigrationFileNames(migrationDir)
+	if err != nil {
+		return "", fmt.Errorf("failed to list migration files: %s", err)
+	}
+
+	matched := []string{}
+	for _, f := range files {
+		_, migrationName, err := loadMigrationFileHeader(filepath.Join(migrationDir, f))
+		if err != nil {
+			// A broken file is reported when it's run, so it shouldn't prevent
+			// finding the other migrations.
+			log.Printf("[WARN] [command] skip a migration file which cannot be parsed: %s\n", err)
+			continue
+		}
+		if migrationName == name {
+			matched = append(matched, f)
+		}
+	}
+
+	switch len(matched) {
+	case 0:
+		return "", fmt.Errorf("no migration named %s found in %s", name, migrationDir)
+	case 1:
+		return matched[0], nil
+	default:
+		return "", fmt.Errorf("migration name %s is ambiguous in %s: %s", name, migrationDir, strings.Join(matched, ", "))
+	}
+}
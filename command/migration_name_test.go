@@ -0,0 +1,99 @@
+package command
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestFindMigrationFileByName(t *testing.T) {
+	migrations := map[string]string{
+		"20201109000001_foo.hcl": `
+migration "mock" "foo" {
+	plan_error  = false
+	apply_error = false
+}
+`,
+		"20201109000002_bar.hcl": `
+migration "mock" "bar" {
+	plan_error  = false
+	apply_error = false
+}
+`,
+		"20201109000003_bar.hcl": `
+migration "mock" "bar" {
+	plan_error  = false
+	apply_error = false
+}
+`,
+		"20201109000005_broken.hcl": `
+migration "mock" "broken" {
+`,
+		"20201109000004_qux.hcl": `
+remote_state "network" {
+	dir = "nonexistent"
+}
+
+migration "mock" "qux" {
+	plan_error  = remote_state.network.outputs.plan_error
+	apply_error = false
+}
+`,
+	}
+
+	cases := []struct {
+		desc string
+		name string
+		want string
+		ok   bool
+	}{
+		{
+			desc: "found",
+			name: "foo",
+			want: "20201109000001_foo.hcl",
+			ok:   true,
+		},
+		{
+			desc: "remote state not read",
+			name: "qux",
+			want: "20201109000004_qux.hcl",
+			ok:   true,
+		},
+		{
+			desc: "broken file skipped",
+			name: "broken",
+			want: "no migration named broken found",
+			ok:   false,
+		},
+		{
+			desc: "not found",
+			name: "baz",
+			want: "no migration named baz found",
+			ok:   false,
+		},
+		{
+			desc: "ambiguous",
+			name: "bar",
+			want: "migration name bar is ambiguous",
+			ok:   false,
+		},
+	}
+
+	migrationDir := setupMigrationDir(t, migrations)
+	for _, tc := range cases {
+		t.Run(tc.desc, func(t *testing.T) {
+			got, err := findMigrationFileByName(migrationDir, tc.name)
+			if tc.ok && err != nil {
+				t.Fatalf("unexpected err: %s", err)
+			}
+			if !tc.ok && err == nil {
+				t.Fatalf("expected to return an error, but no error, got: %s", got)
+			}
+			if tc.ok && got != tc.want {
+				t.Errorf("got: %s, want: %s", got, tc.want)
+			}
+			if !tc.ok && !strings.Contains(err.Error(), tc.want) {
+				t.Errorf("got: %s, want to contain: %s", err, tc.want)
+			}
+		})
+	}
+}
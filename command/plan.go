@@ -2,10 +2,13 @@ package command
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"log"
 	"strings"
+	"time"
 
+	"github.com/minamijoyo/tfmigrate/tfmigrate"
 	flag "github.com/spf13/pflag"
 )
 
@@ -15,6 +18,17 @@ type PlanCommand struct {
 	Meta
 	backendConfig []string
 	out           string
+	compact       bool
+	all           bool
+	report        string
+	reportFormat  string
+	workDir       string
+	initTimeout   time.Duration
+	planTimeout   time.Duration
+	parallelism   int
+	skipInit      bool
+	noHistory     bool
+	diagnostics   bool
 }
 
 // Run runs the procedure of this command.
@@ -22,29 +36,94 @@ func (c *PlanCommand) Run(args []string) int {
 	cmdFlags := flag.NewFlagSet("plan", flag.ContinueOnError)
 	cmdFlags.StringVar(&c.configFile, "config", defaultConfigFile, "A path to tfmigrate config file")
 	cmdFlags.StringArrayVar(&c.backendConfig, "backend-config", nil, "A backend configuration for remote state")
+	cmdFlags.BoolVar(&c.color, "color", false, "Force colored output")
+	cmdFlags.BoolVar(&c.noColor, "no-color", false, "Disable colored output")
+	cmdFlags.StringVar(&c.logLevel, "log-level", "", "A minimum log level")
+	cmdFlags.BoolVar(&c.quiet, "quiet", false, "Suppress log output unless failed")
+	cmdFlags.BoolVarP(&c.verbose, "verbose", "v", false, "Show terraform commands executed")
+	cmdFlags.BoolVar(&c.readOnly, "read-only", false, "Refuse any mutation of state and history")
 	cmdFlags.StringVar(&c.out, "out", "", "Save a plan file after dry-run migration to the given path")
+	cmdFlags.BoolVar(&c.compact, "compact", false, "Print a one-line summary per migration instead of the full plan output")
+	cmdFlags.BoolVar(&c.all, "all", false, "Plan all unapplied migrations without stopping at the first failure and report an ordered summary")
+	cmdFlags.StringVar(&c.report, "report", "", "Write a summary report of the run in JSON including a normalized hash of each plan to the given path")
+	cmdFlags.StringVar(&c.reportFormat, "report-format", reportFormatJSON, "A format of the report")
+	cmdFlags.StringVar(&c.workDir, "work-dir", "", "A base directory where terraform commands are executed")
+	cmdFlags.DurationVar(&c.initTimeout, "init-timeout", 0, "A timeout for each terraform init")
+	cmdFlags.DurationVar(&c.planTimeout, "plan-timeout", 0, "A timeout for each terraform plan")
+	cmdFlags.IntVar(&c.parallelism, "parallelism", 0, "Limit the number of concurrent operations of terraform plan")
+	cmdFlags.BoolVar(&c.skipInit, "skip-init", false, "Assume working dirs are already initialized and skip terraform init")
+	cmdFlags.BoolVar(&c.noHistory, "no-history", false, "Run a given migration file without reading nor writing history")
+	cmdFlags.BoolVar(&c.diagnostics, "diagnostics", false, "Show a summary of warnings and errors reported by terraform plan")
 
 	if err := cmdFlags.Parse(args); err != nil {
 		c.UI.Error(fmt.Sprintf("failed to parse arguments: %s", err))
 		return 1
 	}
+	if err := errors.Join(checkTimeout("init-timeout", c.initTimeout), checkTimeout("plan-timeout", c.planTimeout), validateReportFormat(c.reportFormat)); err != nil {
+		c.UI.Error(err.Error())
+		return 1
+	}
+	if c.parallelism < 0 {
+		c.UI.Error(fmt.Sprintf("The --parallelism option must not be negative: %d", c.parallelism))
+		return 1
+	}
+	if err := c.setLogLevel(""); err != nil {
+		c.UI.Error(fmt.Sprintf("failed to set log level: %s", err))
+		return 1
+	}
+	c.UI = newColoredUI(c.UI, useColor(c.color, c.noColor))
 
 	var err error
 	if c.config, err = newConfig(c.configFile); err != nil {
 		c.UI.Error(fmt.Sprintf("failed to load config file: %s", err))
 		return 1
 	}
+	if err = c.setLogLevel(c.config.LogLevel); err != nil {
+		c.UI.Error(fmt.Sprintf("failed to set log level: %s", err))
+		return 1
+	}
+	if err = c.Redactor.AddPatterns(c.config.RedactPatterns); err != nil {
+		c.UI.Error(fmt.Sprintf("failed to add redact patterns: %s", err))
+		return 1
+	}
 	log.Printf("[DEBUG] [command] config: %#v\n", c.config)
 
 	c.Option = newOption()
 	c.Option.PlanOut = c.out
 	c.Option.BackendConfig = c.backendConfig
+	c.Option.WorkDir = c.workDir
+	c.Option.InitTimeout = c.initTimeout
+	c.Option.PlanTimeout = c.planTimeout
+	c.Option.Parallelism = c.parallelism
+	c.Option.SkipInit = c.skipInit
+	if c.compact || c.all || len(c.report) != 0 {
+		c.Option.PlanResultCollector = tfmigrate.NewPlanResultCollector()
+	}
+	if c.diagnostics {
+		c.Option.DiagnosticsCollector = tfmigrate.NewDiagnosticsCollector()
+	}
+	c.setReadOnly()
+	c.setVerbose()
 	// The option may contains sensitive values such as environment variables.
 	// So logging the option set log level to DEBUG instead of INFO.
 	log.Printf("[DEBUG] [command] option: %#v\n", c.Option)
 
-	if c.config.History == nil {
+	if c.config.History == nil || c.noHistory {
 		// non-history mode
+		if c.config.History != nil {
+			log.Printf("[INFO] [command] no-history: skip reading and writing history\n")
+		}
+		if len(c.report) != 0 {
+			// A report is built from the data flowing through the history runner.
+			c.UI.Error("The --report option requires history mode")
+			c.UI.Error(c.Help())
+			return 1
+		}
+		if c.all {
+			c.UI.Error("The --all option requires history mode")
+			c.UI.Error(c.Help())
+			return 1
+		}
 		if len(cmdFlags.Args()) != 1 {
 			c.UI.Error(fmt.Sprintf("The command expects 1 argument, but got %d", len(cmdFlags.Args())))
 			c.UI.Error(c.Help())
@@ -52,8 +131,8 @@ func (c *PlanCommand) Run(args []string) int {
 		}
 
 		migrationFile := cmdFlags.Arg(0)
-		if err = c.planWithoutHistory(migrationFile); err != nil {
-			c.UI.Error(err.Error())
+		if err = c.runQuietly(func() error { return c.runWithHooks(func() error { return c.planWithoutHistory(migrationFile) }) }); err != nil {
+			c.UI.Error(c.errorMessage(err))
 			return 1
 		}
 
@@ -72,10 +151,15 @@ func (c *PlanCommand) Run(args []string) int {
 		// plan a given single migration file.
 		migrationFile = cmdFlags.Arg(0)
 	}
+	if len(migrationFile) != 0 && c.all {
+		c.UI.Error("The --all option cannot be used with a migration file argument")
+		c.UI.Error(c.Help())
+		return 1
+	}
 
 	// Plan all unapplied pending migrations.
-	if err = c.planWithHistory(migrationFile); err != nil {
-		c.UI.Error(err.Error())
+	if err = c.runQuietly(func() error { return c.runWithHooks(func() error { return c.planWithHistory(migrationFile) }) }); err != nil {
+		c.UI.Error(c.errorMessage(err))
 		return 1
 	}
 
@@ -83,24 +167,56 @@ func (c *PlanCommand) Run(args []string) int {
 }
 
 // planWithoutHistory is a helper function which plans a given migration file without history.
-func (c *PlanCommand) planWithoutHistory(filename string) error {
-	fr, err := NewFileRunner(filename, c.config, c.Option)
+func (c *PlanCommand) planWithoutHistory(filename string) (err error) {
+	ctx, span := tfmigrate.StartSpan(context.Background(), c.Option, "tfmigrate plan")
+	defer func() { tfmigrate.EndSpan(span, err) }()
+
+	fr, err := NewFileRunner(ctx, filename, c.config, c.Option)
 	if err != nil {
+		reportProgress(c.UI, progressFailed, filename)
 		return err
 	}
 
-	return fr.Plan(context.Background())
+	err = fr.Plan(ctx)
+	reportPlanResult(c.UI, c.Option, filename, err, c.compact)
+	reportDiagnostics(c.UI, c.Option, filename)
+	return err
 }
 
 // planWithHistory is a helper function which plans all unapplied pending migrations.
-func (c *PlanCommand) planWithHistory(filename string) error {
-	ctx := context.Background()
+func (c *PlanCommand) planWithHistory(filename string) (err error) {
+	ctx, span := tfmigrate.StartSpan(context.Background(), c.Option, "tfmigrate plan")
+	defer func() { tfmigrate.EndSpan(span, err) }()
+
 	hr, err := NewHistoryRunner(ctx, filename, c.config, c.Option)
 	if err != nil {
 		return err
 	}
+	hr.SetUI(c.UI)
+	hr.SetCompact(c.compact)
+	hr.SetPlanAll(c.all)
 
-	return hr.Plan(ctx)
+	err = hr.Plan(ctx)
+	if len(c.report) != 0 {
+		// write a report even if failed to plan.
+		if rerr := hr.PlanReport().writeFile(c.report, c.reportFormat, c.Redactor); rerr != nil {
+			if err == nil {
+				return rerr
+			}
+			return fmt.Errorf("%s, failed to plan: %v", rerr, err)
+		}
+		log.Printf("[INFO] [command] write a report to %s\n", c.report)
+	}
+	return err
+}
+
+// errorMessage returns an error message to be shown.
+// In compact mode, the full output of terraform command is omitted.
+func (c *PlanCommand) errorMessage(err error) string {
+	if c.compact {
+		return compactError(err)
+	}
+	return err.Error()
 }
 
 // Help returns long-form help text.
@@ -121,9 +237,75 @@ Options:
                            key=value format backend configuraion.
                            This option is passed to terraform init when switching backend to remote.
 
+  --color                  Force colored output even if the stdout is not a terminal.
+  --no-color               Disable colored output.
+                           Colors are also disabled if the NO_COLOR environment variable is set.
+
+  --log-level=level        A minimum log level. Valid values are TRACE, DEBUG, INFO, WARN and ERROR.
+                           It takes precedence over the TFMIGRATE_LOG environment variable
+                           and the log_level in the config file.
+
+  --quiet                  Suppress log output including terraform command details and show
+                           only a progress line per migration. The log is captured and
+                           written to stderr only if failed. The log level is still respected.
+
+  -v, --verbose            Show each terraform command executed with its full arguments in stdout,
+                           such as init, state pull, state mv, plan and state push.
+                           Secrets are redacted as the same as log output.
+
+  --read-only              Refuse any mutation of state and history at the lowest level,
+                           such as terraform state push and writing to history storage.
+                           It can also be enabled by the TFMIGRATE_READ_ONLY environment variable.
+
   --out=path               Save a plan file after dry-run migration to the given path.
                            Note that the saved plan file is not applicable in Terraform 1.1+.
                            It's intended to use only for static analysis.
+
+  --compact                Print a one-line summary per migration instead of the full plan output.
+                           The summary shows whether the plan is empty and the number of changes.
+
+  --all                    Plan all unapplied migrations in order without stopping at the first
+                           failure, and report an ordered summary of each migration and
+                           its plan status at the end. It's useful to review a backlog
+                           before applying it. Note that each migration is planned against
+                           the current remote state, not the state after the preceding ones.
+                           It's only supported in history mode without a migration file argument.
+
+  --report=path            Write a summary report of the run in JSON to the given path.
+                           It contains the filename, type, name, duration and outcome of
+                           each migration and the overall success as the same as apply.
+                           In addition, it contains the changed addresses and a normalized
+                           hash of the plan for each dir, which is stable across runs unless
+                           the plan changes. It's useful to detect non-determinism.
+                           It's written even if failed. Secrets in errors are masked.
+                           It's only supported in history mode.
+
+  --report-format=format   A format of the report. Valid values are json (default) and
+                           markdown. The markdown is a human-readable summary, which is
+                           friendly for a comment on a pull request by Atlantis or CI.
+
+  --work-dir=path          A base directory where terraform commands are executed.
+                           If set, the dir in a migration is resolved relative to it
+                           instead of the current directory.
+
+  --init-timeout=duration  A timeout for each terraform init such as 5m.
+  --plan-timeout=duration  A timeout for each terraform plan such as 30m.
+                           They take precedence over the init_timeout and plan_timeout
+                           in the config file. Default to no timeout.
+  --parallelism=N          Limit the number of concurrent operations of terraform plan
+                           for verification. Default to the terraform's default.
+  --skip-init              Assume working dirs are already initialized and skip terraform init.
+                           It's useful for pipelines which run terraform init separately.
+                           Note that switching the backend to local temporarily still runs
+                           terraform init -reconfigure.
+
+  --no-history             Run a given migration file without reading nor writing history,
+                           even if history is configured. A migration file argument is required.
+                           It's useful for ad-hoc migrations which are not intended to be tracked.
+
+  --diagnostics            Capture diagnostics of terraform plan for verification in JSON and
+                           show a summary of warnings and errors per migration separately
+                           from the result. It's useful to notice deprecation warnings.
 `
 	return strings.TrimSpace(helpText)
 }
@@ -0,0 +1,57 @@
+package command
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/minamijoyo/tfmigrate/redact"
+	"github.com/mitchellh/cli"
+)
+
+func TestPlanCommandNoHistory(t *testing.T) {
+	cases := []struct {
+		desc      string
+		noHistory bool
+		want      int
+	}{
+		{
+			desc:      "no history",
+			noHistory: true,
+			want:      0,
+		},
+		{
+			desc:      "history",
+			noHistory: false,
+			want:      1,
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.desc, func(t *testing.T) {
+			configFile, migrationFile := setupConfigFileWithBrokenHistory(t)
+			ui := cli.NewMockUi()
+			c := &PlanCommand{
+				Meta: Meta{
+					UI:       ui,
+					Redactor: redact.NewRedactor(),
+				},
+			}
+
+			args := []string{"--config=" + configFile}
+			if tc.noHistory {
+				args = append(args, "--no-history")
+			}
+			args = append(args, migrationFile)
+
+			// The history storage fails to read and write, so the command
+			// succeeds only if it neither reads nor writes history.
+			got := c.Run(args)
+			if got != tc.want {
+				t.Errorf("got: %d, want: %d, stderr: %s", got, tc.want, ui.ErrorWriter.String())
+			}
+			if got != 0 && !strings.Contains(ui.ErrorWriter.String(), "failed to read mock storage") {
+				t.Errorf("unexpected error: %s", ui.ErrorWriter.String())
+			}
+		})
+	}
+}
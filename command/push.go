@@ -0,0 +1,276 @@
+package command
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/minamijoyo/tfmigrate/config"
+	"github.com/minamijoyo/tfmigrate/history"
+	"github.com/minamijoyo/tfmigrate/tfexec"
+	"github.com/minamijoyo/tfmigrate/tfmigrate"
+	flag "github.com/spf13/pflag"
+)
+
+// PushCommand is a command which pushes a state file written by
+// apply --state-out to the remote state.
+type PushCommand struct {
+	Meta
+	dir           string
+	workspace     string
+	backendConfig []string
+	skipInit      bool
+	pushTimeout   time.Duration
+	autoApprove   bool
+	force         bool
+	migration     string
+}
+
+// Run runs the procedure of this command.
+func (c *PushCommand) Run(args []string) int {
+	cmdFlags := flag.NewFlagSet("push", flag.ContinueOnError)
+	cmdFlags.StringVar(&c.configFile, "config", defaultConfigFile, "A path to tfmigrate config file")
+	cmdFlags.StringVar(&c.dir, "dir", "", "A working directory of the remote state")
+	cmdFlags.StringVar(&c.workspace, "workspace", "", "A terraform workspace")
+	cmdFlags.StringArrayVar(&c.backendConfig, "backend-config", nil, "A backend configuration for remote state")
+	cmdFlags.StringVar(&c.logLevel, "log-level", "", "A minimum log level")
+	cmdFlags.BoolVarP(&c.verbose, "verbose", "v", false, "Show terraform commands executed")
+	cmdFlags.BoolVar(&c.skipInit, "skip-init", false, "Assume working dirs are already initialized and skip terraform init")
+	cmdFlags.DurationVar(&c.pushTimeout, "push-timeout", 0, "A timeout for terraform state push")
+	cmdFlags.BoolVar(&c.autoApprove, "auto-approve", false, "Push the state without confirmation")
+	cmdFlags.BoolVar(&c.force, "force", false, "Push the state even if it's not the next of the remote state")
+	cmdFlags.StringVar(&c.migration, "migration", "", "A migration file to record as applied in history after pushing")
+
+	if err := cmdFlags.Parse(args); err != nil {
+		c.UI.Error(fmt.Sprintf("failed to parse arguments: %s", err))
+		return 1
+	}
+	if len(cmdFlags.Args()) != 1 {
+		c.UI.Error(fmt.Sprintf("The command expects 1 argument, but got %d", len(cmdFlags.Args())))
+		c.UI.Error(c.Help())
+		return 1
+	}
+	if err := checkTimeout("push-timeout", c.pushTimeout); err != nil {
+		c.UI.Error(err.Error())
+		return 1
+	}
+	if err := c.setLogLevel(""); err != nil {
+		c.UI.Error(fmt.Sprintf("failed to set log level: %s", err))
+		return 1
+	}
+
+	stateFile := cmdFlags.Arg(0)
+	b, err := os.ReadFile(stateFile)
+	if err != nil {
+		c.UI.Error(fmt.Sprintf("failed to read state file: %s", err))
+		return 1
+	}
+	state := tfexec.NewState(b)
+
+	if c.config, err = newConfig(c.configFile); err != nil {
+		c.UI.Error(fmt.Sprintf("failed to load config file: %s", err))
+		return 1
+	}
+	if err = c.setLogLevel(c.config.LogLevel); err != nil {
+		c.UI.Error(fmt.Sprintf("failed to set log level: %s", err))
+		return 1
+	}
+	if err = c.Redactor.AddPatterns(c.config.RedactPatterns); err != nil {
+		c.UI.Error(fmt.Sprintf("failed to add redact patterns: %s", err))
+		return 1
+	}
+	log.Printf("[DEBUG] [command] config: %#v\n", c.config)
+	if len(c.migration) != 0 && c.config.History == nil {
+		c.UI.Error("The --migration option requires a history setting")
+		return 1
+	}
+
+	c.Option = defaultMigratorOption(c.config)
+	c.Option.ExecPath = newOption().ExecPath
+	c.Option.BackendConfig = c.backendConfig
+	c.Option.SkipInit = c.skipInit
+	if c.pushTimeout != 0 {
+		c.Option.PushTimeout = c.pushTimeout
+	}
+	// Without --auto-approve, it only checks the state and shows a summary.
+	c.Option.DryRun = !c.autoApprove
+	c.setReadOnly()
+	c.setVerbose()
+	// The option may contain sensitive values such as environment variables.
+	// So logging the option set log level to DEBUG instead of INFO.
+	log.Printf("[DEBUG] [command] option: %#v\n", c.Option)
+
+	var r *tfmigrate.PushStateResult
+	if err := c.runWithHooks(func() error {
+		var perr error
+		r, perr = c.push(state)
+		return perr
+	}); err != nil {
+		c.UI.Error(err.Error())
+		return 1
+	}
+
+	c.UI.Output(formatPushStateResult(r))
+	if r.Pushed && len(c.migration) != 0 {
+		c.UI.Output(fmt.Sprintf("The migration has been recorded as applied: %s", c.migration))
+	}
+	return 0
+}
+
+// push pushes a given state to the remote state.
+// If a migration file is given, it's recorded as applied in history after
+// pushing.
+func (c *PushCommand) push(state *tfexec.State) (_ *tfmigrate.PushStateResult, err error) {
+	ctx, span := tfmigrate.StartSpan(context.Background(), c.Option, "tfmigrate push")
+	defer func() { tfmigrate.EndSpan(span, err) }()
+
+	if err := c.checkAccountGuard(ctx); err != nil {
+		return nil, err
+	}
+
+	var pm *pushedMigration
+	if len(c.migration) != 0 {
+		// Check the history before pushing, so that we don't push a state for
+		// a migration which cannot be recorded.
+		if pm, err = loadPushedMigration(ctx, c.config, c.migration); err != nil {
+			return nil, err
+		}
+	}
+
+	r, err := tfmigrate.PushStateFile(ctx, c.dir, c.workspace, state, c.Option, c.force)
+	if err != nil {
+		return nil, err
+	}
+
+	if pm != nil && r.Pushed {
+		if err := pm.record(ctx); err != nil {
+			return nil, fmt.Errorf("the state has been pushed, but failed to save history: %w", err)
+		}
+	}
+	return r, nil
+}
+
+// pushedMigration is a migration whose new state is pushed by the push
+// command, which is recorded as applied in history.
+type pushedMigration struct {
+	hc  *history.Controller
+	key string
+	// A type and name of the migration block to be recorded.
+	migrationType string
+	migrationName string
+}
+
+// loadPushedMigration reads history and a given migration file.
+// The filename is keyed in the same way as history mode.
+// It fails if the migration has already been applied.
+func loadPushedMigration(ctx context.Context, config *config.TfmigrateConfig, filename string) (*pushedMigration, error) {
+	key, err := historyKey(config.MigrationDir, filename)
+	if err != nil {
+		return nil, err
+	}
+
+	hc, err := history.NewController(ctx, config.MigrationDir, config.History)
+	if err != nil {
+		return nil, err
+	}
+
+	if hc.AlreadyApplied(key) {
+		return nil, fmt.Errorf("a migration has already been applied: %s", key)
+	}
+
+	// Read the migration file to record its type and name, which also checks
+	// that the migration exists. Remote states are not read, because the
+	// migration is not run.
+	migrationType, migrationName, err := loadMigrationFileHeader(resolveMigrationFile(config.MigrationDir, key))
+	if err != nil {
+		return nil, err
+	}
+
+	return &pushedMigration{hc: hc, key: key, migrationType: migrationType, migrationName: migrationName}, nil
+}
+
+// record adds an applied record of the migration to history and saves it.
+func (m *pushedMigration) record(ctx context.Context) error {
+	log.Printf("[INFO] [command] add a record to history: %s\n", m.key)
+	m.hc.AddRecord(m.key, m.migrationType, m.migrationName, nil, 0)
+
+	log.Print("[INFO] [command] save history\n")
+	return m.hc.Save(ctx)
+}
+
+// formatPushStateResult returns a summary of a given PushStateResult.
+// Added and removed addresses compared to the remote state are marked with
+// + and -.
+func formatPushStateResult(r *tfmigrate.PushStateResult) string {
+	lines := []string{}
+	if r.Pushed {
+		lines = append(lines, fmt.Sprintf("The state has been pushed to %s (workspace: %s): serial %d => %d", r.Dir, r.Workspace, r.CurrentSerial, r.Serial))
+	} else {
+		lines = append(lines, fmt.Sprintf("The state is valid and will be pushed to %s (workspace: %s): serial %d => %d", r.Dir, r.Workspace, r.CurrentSerial, r.Serial))
+	}
+	if r.Added == nil && r.Removed == nil {
+		lines = append(lines, "The addresses cannot be compared with the remote state.")
+	}
+	for _, addr := range r.Added {
+		lines = append(lines, "+ "+addr)
+	}
+	for _, addr := range r.Removed {
+		lines = append(lines, "- "+addr)
+	}
+	if !r.Pushed {
+		lines = append(lines, "Re-run with --auto-approve to push it.")
+	}
+	return strings.Join(lines, "\n")
+}
+
+// Help returns long-form help text.
+func (c *PushCommand) Help() string {
+	helpText := `
+Usage: tfmigrate push [options] PATH
+
+Push a state file written by apply --state-out to the remote state.
+It's intended to push a new state after reviewing it offline.
+It fails if the remote state has changed since the state file was written,
+that is, the lineage doesn't match or the serial is not exactly the next
+of the remote state. In that case, run apply --state-out again.
+Without --auto-approve, it only checks the state and shows a summary of
+changes of addresses compared to the remote state.
+Note that it doesn't run terraform plan. If --migration is given, the
+migration is recorded as applied in history after pushing.
+
+Arguments:
+  PATH                     A path of state file
+
+Options:
+  --dir=path               A working directory of the remote state.
+                           Default to the default_dir in the config file.
+  --workspace=name         A terraform workspace. Default to "default".
+  --config                 A path to tfmigrate config file
+  --backend-config=path    A backend configuration, a path to backend configuration file or
+                           key=value format backend configuraion.
+                           This option is passed to terraform init.
+  --log-level=level        A minimum log level. Valid values are TRACE, DEBUG, INFO, WARN and ERROR.
+                           It takes precedence over the TFMIGRATE_LOG environment variable
+                           and the log_level in the config file.
+  -v, --verbose            Show each terraform command executed with its full arguments in stdout.
+  --skip-init              Assume working dirs are already initialized and skip terraform init.
+  --push-timeout=duration  A timeout for terraform state push such as 5m.
+                           It takes precedence over the push_timeout in the config file.
+  --auto-approve           Push the state without confirmation.
+  --force                  Push the state even if it's not the next of the remote state.
+                           It passes -force to terraform state push, which may overwrite
+                           changes of the remote state. Use it with care.
+  --migration=FILENAME     The migration file passed to apply --state-out.
+                           It's recorded as applied in history after pushing.
+                           It requires a history setting.
+`
+	return strings.TrimSpace(helpText)
+}
+
+// Synopsis returns one-line help text.
+func (c *PushCommand) Synopsis() string {
+	return "Push a state file written by apply --state-out"
+}
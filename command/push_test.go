@@ -0,0 +1,174 @@
+package command
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/minamijoyo/tfmigrate/config"
+	"github.com/minamijoyo/tfmigrate/history"
+	"github.com/minamijoyo/tfmigrate/storage/mock"
+	"github.com/minamijoyo/tfmigrate/tfmigrate"
+)
+
+func TestFormatPushStateResult(t *testing.T) {
+	cases := []struct {
+		desc string
+		r    *tfmigrate.PushStateResult
+		want string
+	}{
+		{
+			desc: "not pushed",
+			r: &tfmigrate.PushStateResult{
+				Dir:           "dir1",
+				Workspace:     "default",
+				CurrentSerial: 3,
+				Serial:        4,
+				Added:         []string{"null_resource.foo2"},
+				Removed:       []string{"null_resource.foo"},
+				Pushed:        false,
+			},
+			want: `The state is valid and will be pushed to dir1 (workspace: default): serial 3 => 4
++ null_resource.foo2
+- null_resource.foo
+Re-run with --auto-approve to push it.`,
+		},
+		{
+			desc: "pushed",
+			r: &tfmigrate.PushStateResult{
+				Dir:           "dir1",
+				Workspace:     "default",
+				CurrentSerial: 3,
+				Serial:        4,
+				Added:         []string{},
+				Removed:       []string{},
+				Pushed:        true,
+			},
+			want: `The state has been pushed to dir1 (workspace: default): serial 3 => 4`,
+		},
+		{
+			desc: "cannot compare",
+			r: &tfmigrate.PushStateResult{
+				Dir:           "dir1",
+				Workspace:     "default",
+				CurrentSerial: 3,
+				Serial:        4,
+				Pushed:        true,
+			},
+			want: `The state has been pushed to dir1 (workspace: default): serial 3 => 4
+The addresses cannot be compared with the remote state.`,
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.desc, func(t *testing.T) {
+			got := formatPushStateResult(tc.r)
+			if got != tc.want {
+				t.Errorf("got:\n%s\nwant:\n%s", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestPushedMigrationRecord(t *testing.T) {
+	migrations := map[string]string{
+		"20201109000001_test1.hcl": `
+migration "mock" "test1" {
+	plan_error  = false
+	apply_error = false
+}
+`,
+		"20201109000002_test2.hcl": `
+migration "mock" "test2" {
+	plan_error  = false
+	apply_error = false
+}
+`,
+	}
+	historyFile := `{
+    "version": 1,
+    "records": {
+        "20201109000001_test1.hcl": {
+            "type": "mock",
+            "name": "test1",
+            "applied_at": "2020-11-10T00:00:01Z"
+        }
+    }
+}`
+
+	cases := []struct {
+		desc     string
+		filename string
+		want     *history.Record
+		ok       bool
+	}{
+		{
+			desc:     "unapplied",
+			filename: "20201109000002_test2.hcl",
+			want: &history.Record{
+				Type: "mock",
+				Name: "test2",
+			},
+			ok: true,
+		},
+		{
+			desc:     "already applied",
+			filename: "20201109000001_test1.hcl",
+			want:     nil,
+			ok:       false,
+		},
+		{
+			desc:     "not found",
+			filename: "20201109000003_test3.hcl",
+			want:     nil,
+			ok:       false,
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.desc, func(t *testing.T) {
+			mockConfig := &mock.Config{
+				Data: historyFile,
+			}
+			config := &config.TfmigrateConfig{
+				MigrationDir: setupMigrationDir(t, migrations),
+				History: &history.Config{
+					Storage: mockConfig,
+				},
+			}
+
+			pm, err := loadPushedMigration(context.Background(), config, tc.filename)
+			if tc.ok && err != nil {
+				t.Fatalf("unexpected err: %s", err)
+			}
+			if !tc.ok && err == nil {
+				t.Fatal("expected to return an error, but no error")
+			}
+			if !tc.ok {
+				return
+			}
+
+			if err := pm.record(context.Background()); err != nil {
+				t.Fatalf("failed to record: %s", err)
+			}
+
+			hc, err := history.NewController(context.Background(), config.MigrationDir, &history.Config{
+				Storage: &mock.Config{Data: mockConfig.Storage().Data()},
+			})
+			if err != nil {
+				t.Fatalf("failed to reload history: %s", err)
+			}
+			got, ok := hc.FindRecord(tc.filename)
+			if !ok {
+				t.Fatalf("no record found in history: %s", tc.filename)
+			}
+			if diff := cmp.Diff(got, *tc.want, cmp.Comparer(func(x, y time.Time) bool { return true })); diff != "" {
+				t.Errorf("got = %#v, want = %#v, diff = %s", got, tc.want, diff)
+			}
+			if !hc.AlreadyApplied(tc.filename) {
+				t.Errorf("expected the migration to be applied: %s", tc.filename)
+			}
+		})
+	}
+}
@@ -0,0 +1,283 @@
+package command
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/minamijoyo/tfmigrate/redact"
+	"github.com/minamijoyo/tfmigrate/tfmigrate"
+)
+
+// Valid formats of a report file.
+const (
+	// reportFormatJSON is a format for machines such as audit pipelines.
+	reportFormatJSON = "json"
+	// reportFormatMarkdown is a format for humans such as a comment on a pull
+	// request posted by Atlantis or CI.
+	reportFormatMarkdown = "markdown"
+)
+
+// markdownErrorMaxLen is a maximum length in bytes of an error message in a
+// report in markdown. A longer one is truncated, because an error of
+// terraform can be huge and a comment on a pull request has a size limit.
+const markdownErrorMaxLen = 4096
+
+// validateReportFormat returns an error if a given format of a report file
+// is invalid. An empty format is not allowed, and it's expected to default to
+// json by the flag.
+func validateReportFormat(format string) error {
+	switch format {
+	case reportFormatJSON, reportFormatMarkdown:
+		return nil
+	default:
+		return fmt.Errorf("The --report-format option must be %s or %s: %s", reportFormatJSON, reportFormatMarkdown, format)
+	}
+}
+
+// ApplyReport is a summary report of a run of apply in history mode.
+// It's written to a file as a per-run artifact for audit and notification
+// pipelines. Note that it's distinct from history.
+type ApplyReport struct {
+	// Success is true if all migrations have been applied and the history has
+	// been saved successfully.
+	Success bool `json:"success"`
+	// Migrations is a list of migrations which have been processed in the run.
+	Migrations []MigrationReport `json:"migrations"`
+	// Pending is a list of migrations which have not been applied yet after
+	// the run, such as ones left by --max or a failure.
+	Pending []string `json:"pending,omitempty"`
+}
+
+// MigrationReport is a report of a migration in ApplyReport.
+type MigrationReport struct {
+	// Filename is a migration file name.
+	Filename string `json:"filename"`
+	// Type is a migration type. It's empty if failed to load the file.
+	Type string `json:"type"`
+	// Name is a migration name. It's empty if failed to load the file.
+	Name string `json:"name"`
+	// DurationSeconds is an elapsed time of the migration in seconds.
+	DurationSeconds float64 `json:"duration_seconds"`
+	// Outcome is one of applied, dry-run, planned or failed.
+	Outcome string `json:"outcome"`
+	// Error is an error message if failed.
+	Error string `json:"error,omitempty"`
+	// Plans is a list of results of terraform plan for each working directory.
+	// It's only reported by plan, and empty if the plan was skipped.
+	Plans []PlanResultReport `json:"plans,omitempty"`
+}
+
+// PlanResultReport is a result of terraform plan in MigrationReport.
+type PlanResultReport struct {
+	// Dir is a working directory where terraform plan was executed.
+	Dir string `json:"dir"`
+	// ChangedAddresses is a list of resource addresses which have any change.
+	ChangedAddresses []string `json:"changed_addresses"`
+	// Hash is a normalized hash of the plan, which is stable across runs
+	// unless the plan changes. It's useful to detect non-determinism.
+	Hash string `json:"hash,omitempty"`
+}
+
+// PlanReport is a summary report of a run of plan.
+// It has the same structure as ApplyReport, and each migration has results
+// of terraform plan.
+type PlanReport struct {
+	// Success is true if all migrations have been planned successfully.
+	Success bool `json:"success"`
+	// Migrations is a list of migrations which have been processed in the run.
+	Migrations []MigrationReport `json:"migrations"`
+}
+
+// newApplyReport returns a new ApplyReport instance.
+func newApplyReport() *ApplyReport {
+	return &ApplyReport{
+		Migrations: []MigrationReport{},
+	}
+}
+
+// add appends a report of a migration.
+func (r *ApplyReport) add(filename string, migrationType string, name string, duration time.Duration, outcome progressStatus, err error) {
+	m := MigrationReport{
+		Filename:        filename,
+		Type:            migrationType,
+		Name:            name,
+		DurationSeconds: duration.Seconds(),
+		Outcome:         string(outcome),
+	}
+	if err != nil {
+		m.Error = err.Error()
+	}
+	r.Migrations = append(r.Migrations, m)
+}
+
+// writeFile writes the report to a given path in a given format.
+// Secrets in error messages are masked by a given redactor.
+func (r *ApplyReport) writeFile(path string, format string, redactor *redact.Redactor) error {
+	redacted := *r
+	redacted.Migrations = redactMigrationReports(r.Migrations, redactor)
+	if format == reportFormatMarkdown {
+		return writeMarkdownFile(path, markdownReport("apply", redacted.Success, redacted.Migrations, redacted.Pending))
+	}
+	return writeReportFile(path, &redacted)
+}
+
+// newPlanReport returns a new PlanReport instance.
+func newPlanReport() *PlanReport {
+	return &PlanReport{
+		Migrations: []MigrationReport{},
+	}
+}
+
+// add appends a report of a migration with given results of terraform plan.
+func (r *PlanReport) add(filename string, migrationType string, name string, duration time.Duration, outcome progressStatus, err error, results []tfmigrate.PlanResult) {
+	m := MigrationReport{
+		Filename:        filename,
+		Type:            migrationType,
+		Name:            name,
+		DurationSeconds: duration.Seconds(),
+		Outcome:         string(outcome),
+	}
+	if err != nil {
+		m.Error = err.Error()
+	}
+	for _, pr := range results {
+		m.Plans = append(m.Plans, PlanResultReport{
+			Dir:              pr.Dir,
+			ChangedAddresses: pr.ChangedAddresses,
+			Hash:             pr.Hash,
+		})
+	}
+	r.Migrations = append(r.Migrations, m)
+}
+
+// writeFile writes the report to a given path in a given format.
+// Secrets in error messages are masked by a given redactor.
+func (r *PlanReport) writeFile(path string, format string, redactor *redact.Redactor) error {
+	redacted := *r
+	redacted.Migrations = redactMigrationReports(r.Migrations, redactor)
+	if format == reportFormatMarkdown {
+		return writeMarkdownFile(path, markdownReport("plan", redacted.Success, redacted.Migrations, nil))
+	}
+	return writeReportFile(path, &redacted)
+}
+
+// redactMigrationReports returns a copy of given reports of migrations whose
+// error messages are redacted by a given redactor, because an error can
+// contain secrets such as captured terraform output.
+func redactMigrationReports(migrations []MigrationReport, redactor *redact.Redactor) []MigrationReport {
+	ret := make([]MigrationReport, 0, len(migrations))
+	for _, m := range migrations {
+		m.Error = redactor.Redact(m.Error)
+		ret = append(ret, m)
+	}
+	return ret
+}
+
+// writeReportFile writes a given report to a given path in JSON.
+func writeReportFile(path string, report interface{}) error {
+	b, err := json.MarshalIndent(report, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode a report: %s", err)
+	}
+	if err := os.WriteFile(path, append(b, '\n'), 0644); err != nil {
+		return fmt.Errorf("failed to write a report: %s", err)
+	}
+	return nil
+}
+
+// markdownReport renders a given report of a run of a given command in
+// markdown, which is friendly for a comment on a pull request. Migrations
+// are listed in a table, followed by errors and changed addresses in plans
+// if any, and then pending migrations. Errors are truncated, and expected to
+// be redacted by the caller.
+func markdownReport(command string, success bool, migrations []MigrationReport, pending []string) string {
+	result := "success"
+	if !success {
+		result = "failed"
+	}
+	lines := []string{
+		fmt.Sprintf("### tfmigrate %s: %s", command, result),
+		"",
+	}
+
+	if len(migrations) == 0 {
+		lines = append(lines, "No migrations were processed.")
+	} else {
+		lines = append(lines,
+			"| Migration | Type | Name | Outcome | Duration |",
+			"| --- | --- | --- | --- | --- |",
+		)
+		for _, m := range migrations {
+			d := time.Duration(m.DurationSeconds * float64(time.Second)).Round(time.Millisecond)
+			lines = append(lines, fmt.Sprintf("| `%s` | %s | %s | %s | %s |", escapeTableCell(m.Filename), escapeTableCell(m.Type), escapeTableCell(m.Name), m.Outcome, d))
+		}
+	}
+
+	for _, m := range migrations {
+		if len(m.Error) != 0 {
+			lines = append(lines,
+				"",
+				fmt.Sprintf("<details><summary>Error of <code>%s</code></summary>", m.Filename),
+				"",
+				"```",
+				truncateError(m.Error, markdownErrorMaxLen),
+				"```",
+				"</details>",
+			)
+		}
+		for _, p := range m.Plans {
+			if len(p.ChangedAddresses) == 0 {
+				continue
+			}
+			lines = append(lines,
+				"",
+				fmt.Sprintf("<details><summary>Changes of <code>%s</code> in <code>%s</code></summary>", m.Filename, p.Dir),
+				"",
+			)
+			for _, addr := range p.ChangedAddresses {
+				lines = append(lines, fmt.Sprintf("- `%s`", addr))
+			}
+			lines = append(lines, "</details>")
+		}
+	}
+
+	if len(pending) != 0 {
+		lines = append(lines, "", "Pending migrations:", "")
+		for _, p := range pending {
+			lines = append(lines, fmt.Sprintf("- `%s`", p))
+		}
+	}
+
+	return strings.Join(lines, "\n") + "\n"
+}
+
+// escapeTableCell escapes a pipe in a given string, which would otherwise
+// split a cell of a table in markdown.
+func escapeTableCell(s string) string {
+	return strings.ReplaceAll(s, "|", `\|`)
+}
+
+// truncateError returns a given error message truncated to a given maximum
+// length in bytes without breaking a multi-byte character.
+func truncateError(s string, maxLen int) string {
+	if len(s) <= maxLen {
+		return s
+	}
+	i := maxLen
+	for i > 0 && !utf8.RuneStart(s[i]) {
+		i--
+	}
+	return s[:i] + "\n... (truncated)"
+}
+
+// writeMarkdownFile writes a given report rendered in markdown to a given path.
+func writeMarkdownFile(path string, report string) error {
+	if err := os.WriteFile(path, []byte(report), 0644); err != nil {
+		return fmt.Errorf("failed to write a report: %s", err)
+	}
+	return nil
+}
@@ -0,0 +1,325 @@
+package command
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/fatih/color"
+	"github.com/mattn/go-isatty"
+	"github.com/minamijoyo/tfmigrate/tfmigrate"
+	"github.com/mitchellh/cli"
+)
+
+// progressStatus is a status of a migration reported to the UI.
+type progressStatus string
+
+const (
+	// progressPlanned means a migration has been planned successfully.
+	progressPlanned progressStatus = "planned"
+	// progressApplied means a migration has been applied successfully.
+	progressApplied progressStatus = "applied"
+	// progressDryRun means a migration has been applied in dry-run mode.
+	progressDryRun progressStatus = "dry-run"
+	// progressSkipped means a migration has been skipped.
+	progressSkipped progressStatus = "skipped"
+	// progressFailed means a migration has failed.
+	progressFailed progressStatus = "failed"
+)
+
+// reportProgress writes a progress line of a migration to a given UI.
+// It's a no-op if the UI is nil. The output method is selected by status so
+// that a ColoredUi can color each status.
+func reportProgress(ui cli.Ui, status progressStatus, filename string) {
+	if ui == nil {
+		return
+	}
+
+	msg := fmt.Sprintf("[tfmigrate] %-7s %s", status, filename)
+	switch status {
+	case progressPlanned, progressApplied, progressDryRun:
+		ui.Info(msg)
+	case progressSkipped:
+		ui.Warn(msg)
+	case progressFailed:
+		ui.Error(msg)
+	default:
+		ui.Output(msg)
+	}
+}
+
+// reportSkipped writes progress lines of migrations which are not run, such
+// as the ones after a failed migration in directory mode.
+func reportSkipped(ui cli.Ui, filenames []string) {
+	for _, filename := range filenames {
+		reportProgress(ui, progressSkipped, filename)
+	}
+}
+
+// appliedStatus returns a progress status of an applied migration.
+func appliedStatus(option *tfmigrate.MigratorOption) progressStatus {
+	if option != nil && option.DryRun {
+		return progressDryRun
+	}
+	return progressApplied
+}
+
+// plannedStatus returns a progress status of a planned migration.
+func plannedStatus(err error) progressStatus {
+	if err != nil {
+		return progressFailed
+	}
+	return progressPlanned
+}
+
+// reportPlanResult writes a progress line of a planned migration.
+// In compact mode, it writes a one-line summary of changes instead.
+// If a PlanResultCollector is set in the option, it returns the collected
+// results and resets the collector for the next migration.
+func reportPlanResult(ui cli.Ui, option *tfmigrate.MigratorOption, filename string, err error, compact bool) []tfmigrate.PlanResult {
+	status := plannedStatus(err)
+
+	var results []tfmigrate.PlanResult
+	if option != nil && option.PlanResultCollector != nil {
+		results = option.PlanResultCollector.Results()
+		option.PlanResultCollector.Reset()
+	}
+
+	if !compact {
+		reportProgress(ui, status, filename)
+		return results
+	}
+	if ui == nil {
+		return results
+	}
+
+	summary, changes := summarizePlanResults(results)
+	msg := fmt.Sprintf("[tfmigrate] %-7s %s: %s", status, filename, summary)
+	if err != nil {
+		ui.Error(msg)
+	} else {
+		ui.Info(msg)
+	}
+	if changes > 0 {
+		ui.Warn("[tfmigrate] hint: re-run without --compact to see the full plan output")
+	}
+	return results
+}
+
+// reportPlanSummary writes an ordered summary of planned migrations in a
+// given report, which is intended to review a sequence of migrations at once.
+// It's a no-op if the UI is nil.
+func reportPlanSummary(ui cli.Ui, report *PlanReport) {
+	if ui == nil {
+		return
+	}
+
+	failed := 0
+	for _, m := range report.Migrations {
+		if m.Outcome == string(progressFailed) {
+			failed++
+		}
+	}
+	msg := fmt.Sprintf("[tfmigrate] plan summary: %d migration(s), %d planned, %d failed", len(report.Migrations), len(report.Migrations)-failed, failed)
+	if failed == 0 {
+		ui.Info(msg)
+	} else {
+		ui.Warn(msg)
+	}
+
+	for i, m := range report.Migrations {
+		line := fmt.Sprintf("[tfmigrate]   %d. %-7s %s", i+1, m.Outcome, m.Filename)
+		if len(m.Plans) != 0 {
+			results := make([]tfmigrate.PlanResult, 0, len(m.Plans))
+			for _, p := range m.Plans {
+				results = append(results, tfmigrate.PlanResult{Dir: p.Dir, ChangedAddresses: p.ChangedAddresses})
+			}
+			summary, _ := summarizePlanResults(results)
+			line += ": " + summary
+		}
+		if m.Outcome == string(progressFailed) {
+			ui.Warn(line)
+		} else {
+			ui.Output(line)
+		}
+	}
+}
+
+// reportDiagnostics writes a summary of diagnostics such as warnings reported
+// by terraform plan for a migration, separately from the result of migration.
+// It's a no-op unless a DiagnosticsCollector is set in the option.
+// It resets the collector for the next migration.
+func reportDiagnostics(ui cli.Ui, option *tfmigrate.MigratorOption, filename string) {
+	if option == nil || option.DiagnosticsCollector == nil {
+		return
+	}
+
+	results := option.DiagnosticsCollector.Results()
+	option.DiagnosticsCollector.Reset()
+	if ui == nil {
+		return
+	}
+
+	counts := map[string]int{}
+	lines := []string{}
+	for _, r := range results {
+		for _, d := range r.Diagnostics {
+			counts[d.Severity]++
+			line := fmt.Sprintf("[tfmigrate]   %s: %s (dir=%s", d.Severity, d.Summary, r.Dir)
+			if len(d.Address) != 0 {
+				line += fmt.Sprintf(", address=%s", d.Address)
+			}
+			lines = append(lines, line+")")
+		}
+	}
+
+	msg := fmt.Sprintf("[tfmigrate] diagnostics %s: %d warning(s), %d error(s)", filename, counts["warning"], counts["error"])
+	if len(lines) == 0 {
+		ui.Info(msg)
+		return
+	}
+	ui.Warn(msg)
+	for _, line := range lines {
+		ui.Warn(line)
+	}
+}
+
+// reportStateList writes lists of resource addresses in remote states after
+// push for a migration as confirmation. Added and removed addresses compared
+// to the original states are marked with + and -. If the collector is in diff
+// only mode, unchanged addresses are omitted.
+// It's a no-op unless a StateListCollector is set in the option.
+// It resets the collector for the next migration.
+func reportStateList(ui cli.Ui, option *tfmigrate.MigratorOption, filename string) {
+	if option == nil || option.StateListCollector == nil {
+		return
+	}
+
+	results := option.StateListCollector.Results()
+	diffOnly := option.StateListCollector.DiffOnly()
+	option.StateListCollector.Reset()
+	if ui == nil {
+		return
+	}
+
+	for _, r := range results {
+		ui.Output(fmt.Sprintf("[tfmigrate] state list %s (dir=%s, workspace=%s): %s", filename, r.Dir, r.Workspace, formatStateListSummary(r)))
+		for _, line := range formatStateList(r, diffOnly) {
+			ui.Output("[tfmigrate]   " + line)
+		}
+	}
+}
+
+// formatStateListSummary returns a one-line summary of a given state list.
+func formatStateListSummary(r tfmigrate.StateListResult) string {
+	summary := fmt.Sprintf("%d address(es)", len(r.After))
+	if r.Before == nil {
+		return summary + ", unable to compare with the original state"
+	}
+	return summary + fmt.Sprintf(", %d added, %d removed", len(r.Added()), len(r.Removed()))
+}
+
+// formatStateList returns lines of addresses in a given state list.
+// The addresses are sorted and marked with + for added, - for removed and a
+// space for unchanged ones. If the original state is unknown, they are not
+// marked and diffOnly is ignored.
+func formatStateList(r tfmigrate.StateListResult, diffOnly bool) []string {
+	if r.Before == nil {
+		return append([]string{}, r.After...)
+	}
+
+	marks := map[string]string{}
+	for _, addr := range r.After {
+		marks[addr] = " "
+	}
+	for _, addr := range r.Added() {
+		marks[addr] = "+"
+	}
+	for _, addr := range r.Removed() {
+		marks[addr] = "-"
+	}
+
+	addrs := make([]string, 0, len(marks))
+	for addr := range marks {
+		addrs = append(addrs, addr)
+	}
+	sort.Strings(addrs)
+
+	lines := []string{}
+	for _, addr := range addrs {
+		mark := marks[addr]
+		if diffOnly && mark == " " {
+			continue
+		}
+		lines = append(lines, mark+" "+addr)
+	}
+	return lines
+}
+
+// summarizePlanResults returns a summary of changes and the total number of
+// changes across working directories.
+func summarizePlanResults(results []tfmigrate.PlanResult) (string, int) {
+	if len(results) == 0 {
+		return "no plan result", 0
+	}
+
+	total := 0
+	dirs := []string{}
+	for _, r := range results {
+		total += len(r.ChangedAddresses)
+		dirs = append(dirs, fmt.Sprintf("%s=%d", r.Dir, len(r.ChangedAddresses)))
+	}
+
+	if total == 0 {
+		return "empty", 0
+	}
+	return fmt.Sprintf("non-empty, %d change(s) (%s)", total, strings.Join(dirs, ", ")), total
+}
+
+// compactError returns the first line of a given error, which omits the full
+// output of terraform command.
+func compactError(err error) string {
+	msg := err.Error()
+	if i := strings.Index(msg, "\n"); i >= 0 {
+		return msg[:i]
+	}
+	return msg
+}
+
+// useColor decides whether to color the output.
+// The --no-color flag takes precedence over the --color flag. The --color
+// flag forces colors. Otherwise, colors are disabled if the NO_COLOR
+// environment variable is set or the stdout is not a terminal.
+func useColor(color bool, noColor bool) bool {
+	if noColor {
+		return false
+	}
+	if color {
+		return true
+	}
+	if _, ok := os.LookupEnv("NO_COLOR"); ok {
+		return false
+	}
+	fd := os.Stdout.Fd()
+	return isatty.IsTerminal(fd) || isatty.IsCygwinTerminal(fd)
+}
+
+// newColoredUI returns a UI which colors its output if enabled.
+func newColoredUI(ui cli.Ui, enabled bool) cli.Ui {
+	if !enabled {
+		return ui
+	}
+
+	// The color package disables colors for non-TTY by itself,
+	// so we need to override it when the --color flag forces colors.
+	color.NoColor = false
+
+	return &cli.ColoredUi{
+		OutputColor: cli.UiColorNone,
+		InfoColor:   cli.UiColorGreen,
+		WarnColor:   cli.UiColorYellow,
+		ErrorColor:  cli.UiColorRed,
+		Ui:          ui,
+	}
+}
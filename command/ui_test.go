@@ -0,0 +1,346 @@
+package command
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
+	"github.com/minamijoyo/tfmigrate/tfexec"
+	"github.com/minamijoyo/tfmigrate/tfmigrate"
+	"github.com/mitchellh/cli"
+)
+
+func TestReportProgress(t *testing.T) {
+	cases := []struct {
+		desc       string
+		status     progressStatus
+		filename   string
+		wantOutput string
+		wantError  string
+	}{
+		{
+			desc:       "applied",
+			status:     progressApplied,
+			filename:   "tfmigrate/mv_foo.hcl",
+			wantOutput: "[tfmigrate] applied tfmigrate/mv_foo.hcl\n",
+		},
+		{
+			desc:       "planned",
+			status:     progressPlanned,
+			filename:   "tfmigrate/mv_foo.hcl",
+			wantOutput: "[tfmigrate] planned tfmigrate/mv_foo.hcl\n",
+		},
+		{
+			desc:      "skipped",
+			status:    progressSkipped,
+			filename:  "tfmigrate/mv_foo.hcl",
+			wantError: "[tfmigrate] skipped tfmigrate/mv_foo.hcl\n",
+		},
+		{
+			desc:      "failed",
+			status:    progressFailed,
+			filename:  "tfmigrate/mv_foo.hcl",
+			wantError: "[tfmigrate] failed  tfmigrate/mv_foo.hcl\n",
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.desc, func(t *testing.T) {
+			ui := cli.NewMockUi()
+			reportProgress(ui, tc.status, tc.filename)
+			if got := ui.OutputWriter.String(); got != tc.wantOutput {
+				t.Errorf("got output: %q, want: %q", got, tc.wantOutput)
+			}
+			if got := ui.ErrorWriter.String(); got != tc.wantError {
+				t.Errorf("got error: %q, want: %q", got, tc.wantError)
+			}
+		})
+	}
+}
+
+func TestUseColor(t *testing.T) {
+	cases := []struct {
+		desc    string
+		color   bool
+		noColor bool
+		env     bool
+		want    bool
+	}{
+		{
+			desc:    "no-color",
+			color:   false,
+			noColor: true,
+			want:    false,
+		},
+		{
+			desc:    "no-color takes precedence over color",
+			color:   true,
+			noColor: true,
+			want:    false,
+		},
+		{
+			desc:  "force color",
+			color: true,
+			env:   true,
+			want:  true,
+		},
+		{
+			desc: "NO_COLOR",
+			env:  true,
+			want: false,
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.desc, func(t *testing.T) {
+			if tc.env {
+				t.Setenv("NO_COLOR", "1")
+			}
+			got := useColor(tc.color, tc.noColor)
+			if got != tc.want {
+				t.Errorf("got: %t, want: %t", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestReportPlanResult(t *testing.T) {
+	cases := []struct {
+		desc       string
+		results    []tfmigrate.PlanResult
+		err        error
+		compact    bool
+		wantOutput string
+		wantError  string
+	}{
+		{
+			desc: "empty",
+			results: []tfmigrate.PlanResult{
+				{Dir: "dir1", ChangedAddresses: []string{}},
+			},
+			compact:    true,
+			wantOutput: "[tfmigrate] planned tfmigrate/mv_foo.hcl: empty\n",
+		},
+		{
+			desc: "non-empty",
+			results: []tfmigrate.PlanResult{
+				{Dir: "dir1", ChangedAddresses: []string{"null_resource.foo", "null_resource.bar"}},
+				{Dir: "dir2", ChangedAddresses: []string{"null_resource.baz"}},
+			},
+			err:     fmt.Errorf("terraform plan command returns unexpected diffs"),
+			compact: true,
+			wantError: "[tfmigrate] failed  tfmigrate/mv_foo.hcl: non-empty, 3 change(s) (dir1=2, dir2=1)\n" +
+				"[tfmigrate] hint: re-run without --compact to see the full plan output\n",
+		},
+		{
+			desc:      "no plan result",
+			results:   nil,
+			err:       fmt.Errorf("failed to run terraform init"),
+			compact:   true,
+			wantError: "[tfmigrate] failed  tfmigrate/mv_foo.hcl: no plan result\n",
+		},
+		{
+			desc: "not compact",
+			results: []tfmigrate.PlanResult{
+				{Dir: "dir1", ChangedAddresses: []string{}, Hash: "sha256:0123"},
+			},
+			compact:    false,
+			wantOutput: "[tfmigrate] planned tfmigrate/mv_foo.hcl\n",
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.desc, func(t *testing.T) {
+			ui := cli.NewMockUi()
+			c := tfmigrate.NewPlanResultCollector()
+			for _, r := range tc.results {
+				c.Add(r)
+			}
+			option := &tfmigrate.MigratorOption{PlanResultCollector: c}
+
+			got := reportPlanResult(ui, option, "tfmigrate/mv_foo.hcl", tc.err, tc.compact)
+			if diff := cmp.Diff(got, tc.results, cmpopts.EquateEmpty()); diff != "" {
+				t.Errorf("got results: %#v, want: %#v, diff: %s", got, tc.results, diff)
+			}
+			if got := ui.OutputWriter.String(); got != tc.wantOutput {
+				t.Errorf("got output: %q, want: %q", got, tc.wantOutput)
+			}
+			if got := ui.ErrorWriter.String(); got != tc.wantError {
+				t.Errorf("got error: %q, want: %q", got, tc.wantError)
+			}
+			if got := c.Results(); len(got) != 0 {
+				t.Errorf("expected the collector to be reset, but got: %#v", got)
+			}
+		})
+	}
+}
+
+func TestReportPlanSummary(t *testing.T) {
+	report := &PlanReport{
+		Migrations: []MigrationReport{
+			{Filename: "20201109000001_test1.hcl", Outcome: "planned", Plans: []PlanResultReport{{Dir: "dir1", ChangedAddresses: []string{}}}},
+			{Filename: "20201109000002_test2.hcl", Outcome: "failed", Plans: []PlanResultReport{{Dir: "dir1", ChangedAddresses: []string{"null_resource.foo"}}}},
+			{Filename: "20201109000003_test3.hcl", Outcome: "planned"},
+		},
+	}
+	ui := cli.NewMockUi()
+	reportPlanSummary(ui, report)
+
+	wantOutput := "[tfmigrate]   1. planned 20201109000001_test1.hcl: empty\n" +
+		"[tfmigrate]   3. planned 20201109000003_test3.hcl\n"
+	if got := ui.OutputWriter.String(); got != wantOutput {
+		t.Errorf("got output: %q, want: %q", got, wantOutput)
+	}
+	wantError := "[tfmigrate] plan summary: 3 migration(s), 2 planned, 1 failed\n" +
+		"[tfmigrate]   2. failed  20201109000002_test2.hcl: non-empty, 1 change(s) (dir1=1)\n"
+	if got := ui.ErrorWriter.String(); got != wantError {
+		t.Errorf("got error: %q, want: %q", got, wantError)
+	}
+}
+
+func TestReportDiagnostics(t *testing.T) {
+	cases := []struct {
+		desc       string
+		results    []tfmigrate.PlanDiagnostics
+		wantOutput string
+		wantError  string
+	}{
+		{
+			desc: "no diagnostics",
+			results: []tfmigrate.PlanDiagnostics{
+				{Dir: "dir1", Diagnostics: []tfexec.Diagnostic{}},
+			},
+			wantOutput: "[tfmigrate] diagnostics tfmigrate/mv_foo.hcl: 0 warning(s), 0 error(s)\n",
+		},
+		{
+			desc: "warnings and errors",
+			results: []tfmigrate.PlanDiagnostics{
+				{Dir: "dir1", Diagnostics: []tfexec.Diagnostic{
+					{Severity: "warning", Summary: "Argument is deprecated", Address: "aws_s3_bucket.foo"},
+				}},
+				{Dir: "dir2", Diagnostics: []tfexec.Diagnostic{
+					{Severity: "error", Summary: "Invalid reference"},
+				}},
+			},
+			wantError: "[tfmigrate] diagnostics tfmigrate/mv_foo.hcl: 1 warning(s), 1 error(s)\n" +
+				"[tfmigrate]   warning: Argument is deprecated (dir=dir1, address=aws_s3_bucket.foo)\n" +
+				"[tfmigrate]   error: Invalid reference (dir=dir2)\n",
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.desc, func(t *testing.T) {
+			ui := cli.NewMockUi()
+			c := tfmigrate.NewDiagnosticsCollector()
+			for _, r := range tc.results {
+				c.Add(r)
+			}
+			option := &tfmigrate.MigratorOption{DiagnosticsCollector: c}
+
+			reportDiagnostics(ui, option, "tfmigrate/mv_foo.hcl")
+			if got := ui.OutputWriter.String(); got != tc.wantOutput {
+				t.Errorf("got output: %q, want: %q", got, tc.wantOutput)
+			}
+			if got := ui.ErrorWriter.String(); got != tc.wantError {
+				t.Errorf("got error: %q, want: %q", got, tc.wantError)
+			}
+			if got := c.Results(); len(got) != 0 {
+				t.Errorf("expected the collector to be reset, but got: %#v", got)
+			}
+		})
+	}
+}
+
+func TestReportStateList(t *testing.T) {
+	cases := []struct {
+		desc       string
+		diffOnly   bool
+		results    []tfmigrate.StateListResult
+		wantOutput string
+	}{
+		{
+			desc: "all",
+			results: []tfmigrate.StateListResult{
+				{
+					Dir:       "dir1",
+					Workspace: "default",
+					Before:    []string{"null_resource.bar", "null_resource.foo"},
+					After:     []string{"null_resource.bar", "null_resource.foo2"},
+				},
+			},
+			wantOutput: "[tfmigrate] state list tfmigrate/mv_foo.hcl (dir=dir1, workspace=default): 2 address(es), 1 added, 1 removed\n" +
+				"[tfmigrate]     null_resource.bar\n" +
+				"[tfmigrate]   - null_resource.foo\n" +
+				"[tfmigrate]   + null_resource.foo2\n",
+		},
+		{
+			desc:     "diff only",
+			diffOnly: true,
+			results: []tfmigrate.StateListResult{
+				{
+					Dir:       "dir1",
+					Workspace: "default",
+					Before:    []string{"null_resource.bar", "null_resource.foo"},
+					After:     []string{"null_resource.bar"},
+				},
+				{
+					Dir:       "dir2",
+					Workspace: "prod",
+					Before:    []string{},
+					After:     []string{"null_resource.foo"},
+				},
+			},
+			wantOutput: "[tfmigrate] state list tfmigrate/mv_foo.hcl (dir=dir1, workspace=default): 1 address(es), 0 added, 1 removed\n" +
+				"[tfmigrate]   - null_resource.foo\n" +
+				"[tfmigrate] state list tfmigrate/mv_foo.hcl (dir=dir2, workspace=prod): 1 address(es), 1 added, 0 removed\n" +
+				"[tfmigrate]   + null_resource.foo\n",
+		},
+		{
+			desc:     "unknown original state",
+			diffOnly: true,
+			results: []tfmigrate.StateListResult{
+				{
+					Dir:       "dir1",
+					Workspace: "default",
+					Before:    nil,
+					After:     []string{"null_resource.foo"},
+				},
+			},
+			wantOutput: "[tfmigrate] state list tfmigrate/mv_foo.hcl (dir=dir1, workspace=default): 1 address(es), unable to compare with the original state\n" +
+				"[tfmigrate]   null_resource.foo\n",
+		},
+		{
+			desc:       "no results",
+			results:    []tfmigrate.StateListResult{},
+			wantOutput: "",
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.desc, func(t *testing.T) {
+			ui := cli.NewMockUi()
+			c := tfmigrate.NewStateListCollector(tc.diffOnly)
+			for _, r := range tc.results {
+				c.Add(r)
+			}
+			option := &tfmigrate.MigratorOption{StateListCollector: c}
+
+			reportStateList(ui, option, "tfmigrate/mv_foo.hcl")
+			if got := ui.OutputWriter.String(); got != tc.wantOutput {
+				t.Errorf("got output: %q, want: %q", got, tc.wantOutput)
+			}
+			if got := c.Results(); len(got) != 0 {
+				t.Errorf("expected the collector to be reset, but got: %#v", got)
+			}
+		})
+	}
+}
+
+func TestCompactError(t *testing.T) {
+	err := fmt.Errorf("terraform plan command returns unexpected diffs: failed to run command (exited 2): terraform plan\nstdout:\nfoo\nstderr:\n")
+	want := "terraform plan command returns unexpected diffs: failed to run command (exited 2): terraform plan"
+	if got := compactError(err); got != want {
+		t.Errorf("got: %s, want: %s", got, want)
+	}
+}
@@ -0,0 +1,164 @@
+package command
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+
+	"github.com/minamijoyo/tfmigrate/config"
+	"github.com/minamijoyo/tfmigrate/history"
+	"github.com/minamijoyo/tfmigrate/tfmigrate"
+	flag "github.com/spf13/pflag"
+)
+
+// VerifyCommand is a command which re-validates applied migrations against
+// the current remote states to detect drift such as manual reverts.
+type VerifyCommand struct {
+	Meta
+	backendConfig []string
+	skipInit      bool
+}
+
+// Run runs the procedure of this command.
+func (c *VerifyCommand) Run(args []string) int {
+	cmdFlags := flag.NewFlagSet("verify", flag.ContinueOnError)
+	cmdFlags.StringVar(&c.configFile, "config", defaultConfigFile, "A path to tfmigrate config file")
+	cmdFlags.StringArrayVar(&c.backendConfig, "backend-config", nil, "A backend configuration for remote state")
+	cmdFlags.StringVar(&c.logLevel, "log-level", "", "A minimum log level")
+	cmdFlags.BoolVar(&c.skipInit, "skip-init", false, "Assume working dirs are already initialized and skip terraform init")
+
+	if err := cmdFlags.Parse(args); err != nil {
+		c.UI.Error(fmt.Sprintf("failed to parse arguments: %s", err))
+		return 1
+	}
+	if len(cmdFlags.Args()) != 0 {
+		c.UI.Error(fmt.Sprintf("The command expects 0 argument, but got %d", len(cmdFlags.Args())))
+		c.UI.Error(c.Help())
+		return 1
+	}
+	if err := c.setLogLevel(""); err != nil {
+		c.UI.Error(fmt.Sprintf("failed to set log level: %s", err))
+		return 1
+	}
+
+	if err := c.loadHistoryConfig(); err != nil {
+		c.UI.Error(err.Error())
+		return 1
+	}
+
+	c.Option = newOption()
+	c.Option.BackendConfig = c.backendConfig
+	c.Option.SkipInit = c.skipInit
+	// The option may contain sensitive values such as environment variables.
+	// So logging the option set log level to DEBUG instead of INFO.
+	log.Printf("[DEBUG] [command] option: %#v\n", c.Option)
+
+	var out string
+	var drifted bool
+	err := c.runWithHooks(func() error {
+		var verr error
+		out, drifted, verr = verifyMigrations(context.Background(), c.config, c.Option)
+		return verr
+	})
+	if err != nil {
+		c.UI.Error(err.Error())
+		return 1
+	}
+	c.UI.Output(out)
+	if drifted {
+		return 2
+	}
+	return 0
+}
+
+// verifyMigrations re-validates applied migrations in history against the
+// current remote states and returns a report. The second return value is true
+// if any discrepancy is found. Migrations whose type doesn't support verify
+// are reported as skipped. Each migration is verified with the migrations
+// applied after it, so that a resource moved again by them is followed.
+func verifyMigrations(ctx context.Context, config *config.TfmigrateConfig, option *tfmigrate.MigratorOption) (string, bool, error) {
+	hc, err := history.NewController(ctx, config.MigrationDir, config.History)
+	if err != nil {
+		return "", false, err
+	}
+
+	filenames := []string{}
+	runners := []*FileRunner{}
+	for _, filename := range hc.Migrations() {
+		if !hc.AlreadyApplied(filename) {
+			continue
+		}
+		fr, err := NewFileRunner(ctx, filename, config, option)
+		if err != nil {
+			return "", false, err
+		}
+		filenames = append(filenames, filename)
+		runners = append(runners, fr)
+	}
+
+	lines := []string{}
+	drifted := false
+	for i, fr := range runners {
+		filename := filenames[i]
+		log.Printf("[INFO] [command] verify migration: %s\n", filename)
+		result, err := fr.Verify(ctx, runners[i+1:])
+		if errors.Is(err, errVerifyNotSupported) {
+			log.Printf("[INFO] [command] skip verifying migration: %s: %s\n", filename, err)
+			lines = append(lines, "SKIP  "+filename+": "+err.Error())
+			continue
+		}
+		if err != nil {
+			return "", false, fmt.Errorf("failed to verify migration: %s: %s", filename, err)
+		}
+
+		if len(result.Discrepancies) == 0 {
+			lines = append(lines, "OK    "+filename)
+		}
+		for _, d := range result.Discrepancies {
+			drifted = true
+			lines = append(lines, "DRIFT "+filename+": "+d)
+		}
+		for _, u := range result.Unchecked {
+			lines = append(lines, "NOTE  "+filename+": "+u)
+		}
+	}
+
+	return strings.Join(lines, "\n"), drifted, nil
+}
+
+// Help returns long-form help text.
+func (c *VerifyCommand) Help() string {
+	helpText := `
+Usage: tfmigrate verify [options]
+
+Re-validate applied migrations in history against the current remote states.
+It detects drift such as a manual revert of a past migration, and reports
+each migration as OK, DRIFT or SKIP. Currently, only mv actions are checked,
+that is, the destination is still present in the current state. A destination
+moved again by a later migration is followed. The xmv actions and destinations
+moved to another state or removed by a later migration are reported as NOTE.
+It's read-only and never changes any state nor history.
+It requires history mode.
+
+Exit status is 0 if no drift is found, 2 if any drift is found,
+and 1 on error.
+
+Options:
+  --config                 A path to tfmigrate config file
+  --backend-config=path    A backend configuration, a path to backend configuration file or
+                           key=value format backend configuraion.
+                           This option is passed to terraform init.
+  --log-level              A minimum log level. Valid values are TRACE, DEBUG, INFO, WARN and ERROR.
+                           It takes precedence over the TFMIGRATE_LOG environment variable
+                           and the log_level in the config file.
+  --skip-init              Assume working dirs are already initialized and skip terraform init.
+`
+	return strings.TrimSpace(helpText)
+}
+
+// Synopsis returns one-line help text.
+func (c *VerifyCommand) Synopsis() string {
+	return "Re-validate applied migrations against the current states"
+}
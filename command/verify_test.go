@@ -0,0 +1,148 @@
+package command
+
+import (
+	"context"
+	"testing"
+
+	"github.com/minamijoyo/tfmigrate/config"
+	"github.com/minamijoyo/tfmigrate/history"
+	"github.com/minamijoyo/tfmigrate/storage/mock"
+	"github.com/minamijoyo/tfmigrate/tfmigrate"
+)
+
+func TestVerifyMigrations(t *testing.T) {
+	historyFile := `{
+    "version": 1,
+    "records": {
+        "20201109000001_test1.hcl": {
+            "type": "mock",
+            "name": "test1",
+            "applied_at": "2020-11-10T00:00:01Z"
+        },
+        "20201109000002_test2.hcl": {
+            "type": "mock",
+            "name": "test2",
+            "applied_at": "2020-11-10T00:00:02Z"
+        },
+        "20201109000003_test3.hcl": {
+            "type": "mock",
+            "name": "test3",
+            "applied_at": "2020-11-10T00:00:03Z",
+            "status": "planned"
+        }
+    }
+}`
+
+	cases := []struct {
+		desc        string
+		migrations  map[string]string
+		historyFile string
+		want        string
+		drifted     bool
+		ok          bool
+	}{
+		{
+			desc: "no drift",
+			migrations: map[string]string{
+				"20201109000001_test1.hcl": `
+migration "mock" "test1" {
+	plan_error  = false
+	apply_error = false
+}
+`,
+				"20201109000002_test2.hcl": `
+migration "mock" "test2" {
+	plan_error  = false
+	apply_error = false
+}
+`,
+				"20201109000003_test3.hcl": `
+migration "mock" "test3" {
+	plan_error  = false
+	apply_error = false
+	drift       = true
+}
+`,
+				"20201109000004_test4.hcl": `
+migration "mock" "test4" {
+	plan_error  = false
+	apply_error = false
+	drift       = true
+}
+`,
+			},
+			historyFile: historyFile,
+			want: `OK    20201109000001_test1.hcl
+OK    20201109000002_test2.hcl`,
+			drifted: false,
+			ok:      true,
+		},
+		{
+			desc: "drift",
+			migrations: map[string]string{
+				"20201109000001_test1.hcl": `
+migration "mock" "test1" {
+	plan_error  = false
+	apply_error = false
+}
+`,
+				"20201109000002_test2.hcl": `
+migration "mock" "test2" {
+	plan_error  = false
+	apply_error = false
+	drift       = true
+}
+`,
+			},
+			historyFile: historyFile,
+			want: `OK    20201109000001_test1.hcl
+DRIFT 20201109000002_test2.hcl: mock migrator drifted: drift = true`,
+			drifted: true,
+			ok:      true,
+		},
+		{
+			desc: "invalid migration file",
+			migrations: map[string]string{
+				"20201109000001_test1.hcl": `
+migration "mock" "test1" {
+	plan_error  = false
+}
+`,
+			},
+			historyFile: historyFile,
+			want:        "",
+			drifted:     false,
+			ok:          false,
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.desc, func(t *testing.T) {
+			migrationDir := setupMigrationDir(t, tc.migrations)
+			storage := &mock.Config{
+				Data:       tc.historyFile,
+				WriteError: false,
+				ReadError:  false,
+			}
+			config := &config.TfmigrateConfig{
+				MigrationDir: migrationDir,
+				History: &history.Config{
+					Storage: storage,
+				},
+			}
+			got, drifted, err := verifyMigrations(context.Background(), config, &tfmigrate.MigratorOption{})
+			if tc.ok && err != nil {
+				t.Fatalf("unexpected err: %s", err)
+			}
+			if !tc.ok && err == nil {
+				t.Fatal("expected to return an error, but no error")
+			}
+			if got != tc.want {
+				t.Errorf("got = %#v, want = %#v", got, tc.want)
+			}
+			if drifted != tc.drifted {
+				t.Errorf("got drifted = %t, want = %t", drifted, tc.drifted)
+			}
+		})
+	}
+}
@@ -1,22 +1,73 @@
 package config
 
-import "github.com/minamijoyo/tfmigrate/history"
+import (
+	"fmt"
+	"os"
+
+	"github.com/minamijoyo/tfmigrate/history"
+	"github.com/minamijoyo/tfmigrate/storage"
+)
 
 // HistoryBlock represents a block for migration history management in HCL.
 type HistoryBlock struct {
+	// Dependencies is a map of migration file name to a list of migration file
+	// names which must be applied before it.
+	Dependencies map[string][]string `hcl:"dependencies,optional"`
+	// Order is a strategy to order migration files.
+	// Valid values are `lexical`, `numeric-prefix` and `mtime`.
+	Order string `hcl:"order,optional"`
+	// StrictNaming requires all migration file names to have a numeric prefix
+	// followed by an underscore.
+	StrictNaming bool `hcl:"strict_naming,optional"`
+	// StrictMissingFiles fails if any migration file recorded as applied in
+	// history doesn't exist in the migration dir.
+	StrictMissingFiles bool `hcl:"strict_missing_files,optional"`
+	// Checksum enables a checksum of the history file to detect corruption or
+	// tampering. If the TFMIGRATE_HISTORY_CHECKSUM_KEY environment variable is
+	// set, it's used as a key of HMAC-SHA256.
+	Checksum bool `hcl:"checksum,optional"`
 	// Storage is a block for migration history data store.
 	Storage StorageBlock `hcl:"storage,block"`
+	// BaseStorages is a list of blocks for read-only migration history data
+	// stores. Migrations recorded in any of them are treated as applied.
+	BaseStorages []StorageBlock `hcl:"base_storage,block"`
 }
 
 // parseHistoryBlock parses a history block and returns a *history.Config.
 func parseHistoryBlock(b HistoryBlock) (*history.Config, error) {
+	switch b.Order {
+	case "", history.OrderLexical, history.OrderNumericPrefix, history.OrderMtime:
+	default:
+		return nil, fmt.Errorf("unknown migration order: %s, valid values are %s, %s and %s", b.Order, history.OrderLexical, history.OrderNumericPrefix, history.OrderMtime)
+	}
+
+	var baseStorages []storage.Config
+	for _, bs := range b.BaseStorages {
+		s, err := parseStorageBlock(bs)
+		if err != nil {
+			return nil, err
+		}
+		baseStorages = append(baseStorages, s)
+	}
+
 	storage, err := parseStorageBlock(b.Storage)
 	if err != nil {
 		return nil, err
 	}
 
 	history := &history.Config{
-		Storage: storage,
+		Storage:            storage,
+		BaseStorages:       baseStorages,
+		Dependencies:       b.Dependencies,
+		Order:              b.Order,
+		StrictNaming:       b.StrictNaming,
+		Checksum:           b.Checksum,
+		StrictMissingFiles: b.StrictMissingFiles,
+	}
+	if key := os.Getenv("TFMIGRATE_HISTORY_CHECKSUM_KEY"); b.Checksum && len(key) > 0 {
+		// Read the key from the environment variable not to write a secret in
+		// the config file.
+		history.ChecksumKey = []byte(key)
 	}
 
 	return history, nil
@@ -5,6 +5,7 @@ import (
 	"testing"
 
 	"github.com/minamijoyo/tfmigrate/history"
+	"github.com/minamijoyo/tfmigrate/storage"
 	"github.com/minamijoyo/tfmigrate/storage/local"
 )
 
@@ -34,6 +35,162 @@ tfmigrate {
 			},
 			ok: true,
 		},
+		{
+			desc: "with dependencies",
+			source: `
+tfmigrate {
+  migration_dir = "tfmigrate"
+  history {
+    dependencies = {
+      "20201012010101_foo.hcl" = ["20201012020202_bar.hcl"]
+    }
+    storage "local" {
+      path = "tmp/history.json"
+    }
+  }
+}
+`,
+			want: &history.Config{
+				Storage: &local.Config{
+					Path: "tmp/history.json",
+				},
+				Dependencies: map[string][]string{
+					"20201012010101_foo.hcl": {"20201012020202_bar.hcl"},
+				},
+			},
+			ok: true,
+		},
+		{
+			desc: "with order and strict naming",
+			source: `
+tfmigrate {
+  migration_dir = "tfmigrate"
+  history {
+    order         = "numeric-prefix"
+    strict_naming = true
+    storage "local" {
+      path = "tmp/history.json"
+    }
+  }
+}
+`,
+			want: &history.Config{
+				Storage: &local.Config{
+					Path: "tmp/history.json",
+				},
+				Order:        "numeric-prefix",
+				StrictNaming: true,
+			},
+			ok: true,
+		},
+		{
+			desc: "with strict missing files",
+			source: `
+tfmigrate {
+  migration_dir = "tfmigrate"
+  history {
+    strict_missing_files = true
+    storage "local" {
+      path = "tmp/history.json"
+    }
+  }
+}
+`,
+			want: &history.Config{
+				Storage: &local.Config{
+					Path: "tmp/history.json",
+				},
+				StrictMissingFiles: true,
+			},
+			ok: true,
+		},
+		{
+			desc: "with checksum",
+			source: `
+tfmigrate {
+  migration_dir = "tfmigrate"
+  history {
+    checksum = true
+    storage "local" {
+      path = "tmp/history.json"
+    }
+  }
+}
+`,
+			want: &history.Config{
+				Storage: &local.Config{
+					Path: "tmp/history.json",
+				},
+				Checksum: true,
+			},
+			ok: true,
+		},
+		{
+			desc: "unknown order",
+			source: `
+tfmigrate {
+  migration_dir = "tfmigrate"
+  history {
+    order = "foo"
+    storage "local" {
+      path = "tmp/history.json"
+    }
+  }
+}
+`,
+			want: nil,
+			ok:   false,
+		},
+		{
+			desc: "with base storages",
+			source: `
+tfmigrate {
+  migration_dir = "tfmigrate"
+  history {
+    storage "local" {
+      path = "tmp/history.json"
+    }
+    base_storage "local" {
+      path = "tmp/legacy1.json"
+    }
+    base_storage "local" {
+      path = "tmp/legacy2.json"
+    }
+  }
+}
+`,
+			want: &history.Config{
+				Storage: &local.Config{
+					Path: "tmp/history.json",
+				},
+				BaseStorages: []storage.Config{
+					&local.Config{
+						Path: "tmp/legacy1.json",
+					},
+					&local.Config{
+						Path: "tmp/legacy2.json",
+					},
+				},
+			},
+			ok: true,
+		},
+		{
+			desc: "unknown base storage type",
+			source: `
+tfmigrate {
+  migration_dir = "tfmigrate"
+  history {
+    storage "local" {
+      path = "tmp/history.json"
+    }
+    base_storage "foo" {
+    }
+  }
+}
+`,
+			want: nil,
+			ok:   false,
+		},
 		{
 			desc: "missing block (storage)",
 			source: `
@@ -1,14 +1,20 @@
 package config
 
 import (
+	"encoding/json"
+	"errors"
 	"fmt"
 	"os"
+	"path/filepath"
 	"strings"
+	"time"
 
 	"github.com/hashicorp/hcl/v2"
 	"github.com/hashicorp/hcl/v2/gohcl"
 	"github.com/hashicorp/hcl/v2/hclsimple"
+	hcljson "github.com/hashicorp/hcl/v2/json"
 	"github.com/zclconf/go-cty/cty"
+	"gopkg.in/yaml.v3"
 
 	"github.com/minamijoyo/tfmigrate/tfmigrate"
 )
@@ -19,15 +25,30 @@ type MigrationFile struct {
 	// It must contain only one block, and multiple blocks are not allowed,
 	// because it's hard to re-run the file if partially failed.
 	Migration MigrationBlock `hcl:"migration,block"`
+	// RemoteStates is a list of remote_state blocks, whose outputs can be
+	// referenced in the migration block.
+	RemoteStates []RemoteStateBlock `hcl:"remote_state,block"`
 }
 
 // MigrationBlock represents a migration block in HCL.
 type MigrationBlock struct {
 	// Type is a type for migration.
-	// Valid values are `state` and `multi_state`.
+	// Valid values are `state`, `multi_state` and `move_between_workspaces`.
 	Type string `hcl:"type,label"`
 	// Name is an arbitrary name for migration.
 	Name string `hcl:"name,label"`
+	// Timeout is a timeout for the whole migration such as `1h`.
+	// It's intended for a migration which legitimately takes longer or
+	// shorter than the others, such as a big import.
+	Timeout string `hcl:"timeout,optional"`
+	// Retries is a maximum number of retries when the state is locked.
+	// It overrides the attempts of the lock_retry block in the config file
+	// for the migration.
+	Retries *int `hcl:"retries,optional"`
+	// Env is a map of environment variables passed to terraform command in
+	// the migration. It's intended for credentials of a backend and providers
+	// which differ per migration, and never used for a history storage.
+	Env map[string]string `hcl:"env,optional"`
 	// Remain is a body of migration block.
 	// We first decode only a block header and then decode schema depending on
 	// its type label.
@@ -46,8 +67,24 @@ func envVarMap() cty.Value {
 
 // ParseMigrationFile parses a given source of migration file and returns a *tfmigrate.MigrationConfig.
 // Note that this method does not read a file and you should pass source of config in bytes.
-// The filename is used for error message and selecting HCL syntax (.hcl and .json).
+// The filename is used for error message and selecting syntax (.hcl, .json, .yaml and .yml).
 func ParseMigrationFile(filename string, source []byte) (*tfmigrate.MigrationConfig, error) {
+	return ParseMigrationFileWithLocals(filename, source, nil)
+}
+
+// ParseMigrationFileWithLocals is the same as ParseMigrationFile, but given
+// local values can be referenced via `local.<name>` in the migration file.
+// If no local values are given, any reference to `local` is an error.
+func ParseMigrationFileWithLocals(filename string, source []byte, locals map[string]cty.Value) (*tfmigrate.MigrationConfig, error) {
+	return ParseMigrationFileWithRemoteState(filename, source, locals, nil)
+}
+
+// ParseMigrationFileWithRemoteState is the same as ParseMigrationFileWithLocals,
+// but outputs of remote_state blocks can be referenced via
+// `remote_state.<name>.outputs.<output>` in the migration block. The remote
+// states are read with a given reader. If no reader is given, any
+// remote_state block is an error.
+func ParseMigrationFileWithRemoteState(filename string, source []byte, locals map[string]cty.Value, reader RemoteStateReader) (*tfmigrate.MigrationConfig, error) {
 	// Decode migration block header.
 	var f MigrationFile
 
@@ -56,26 +93,150 @@ func ParseMigrationFile(filename string, source []byte) (*tfmigrate.MigrationCon
 			"env": envVarMap(),
 		},
 	}
+	if len(locals) > 0 {
+		ctx.Variables["local"] = cty.ObjectVal(locals)
+	}
 
-	err := hclsimple.Decode(filename, source, ctx, &f)
+	err := decodeMigrationFile(filename, source, ctx, &f)
 	if err != nil {
 		return nil, fmt.Errorf("failed to decode migration file: %s, err: %s", filename, err)
 	}
 
+	if len(f.RemoteStates) > 0 {
+		remoteState, err := parseRemoteStateBlocks(f.RemoteStates, reader)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse migration file: %s, err: %s", filename, err)
+		}
+		ctx.Variables["remote_state"] = remoteState
+	}
+
 	migrator, err := parseMigrationBlock(f.Migration, ctx)
 	if err != nil {
 		return nil, err
 	}
 
+	timeout, retries, err := parseMigrationLimits(f.Migration)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse migration file: %s, err: %s", filename, err)
+	}
+	if err := validateEnv(f.Migration.Env); err != nil {
+		return nil, fmt.Errorf("failed to parse migration file: %s, err: %s", filename, err)
+	}
+
 	config := &tfmigrate.MigrationConfig{
 		Type:     f.Migration.Type,
 		Name:     f.Migration.Name,
 		Migrator: migrator,
+		Timeout:  timeout,
+		Retries:  retries,
+		Env:      f.Migration.Env,
 	}
 
 	return config, nil
 }
 
+// migrationFileHeader represents only the labels of a migration block.
+// The rest of the file is left undecoded, so that no expression is evaluated.
+type migrationFileHeader struct {
+	Migration struct {
+		Type   string   `hcl:"type,label"`
+		Name   string   `hcl:"name,label"`
+		Remain hcl.Body `hcl:",remain"`
+	} `hcl:"migration,block"`
+	Remain hcl.Body `hcl:",remain"`
+}
+
+// ParseMigrationFileHeader parses only the type and name labels of the
+// migration block in a given source of migration file.
+// It doesn't evaluate any expression, and so doesn't read remote states
+// referenced by remote_state blocks. It's intended for a caller which only
+// needs to identify a migration, not to run it.
+func ParseMigrationFileHeader(filename string, source []byte) (string, string, error) {
+	var f migrationFileHeader
+	if err := decodeMigrationFile(filename, source, nil, &f); err != nil {
+		return "", "", fmt.Errorf("failed to decode migration file: %s, err: %s", filename, err)
+	}
+	return f.Migration.Type, f.Migration.Name, nil
+}
+
+// parseMigrationLimits parses the timeout and retries of a migration block.
+// They must be positive if set. A zero value means not set.
+func parseMigrationLimits(b MigrationBlock) (time.Duration, int, error) {
+	var timeout time.Duration
+	if len(b.Timeout) > 0 {
+		d, err := time.ParseDuration(b.Timeout)
+		if err != nil {
+			return 0, 0, fmt.Errorf("failed to parse timeout: %s", err)
+		}
+		if d <= 0 {
+			return 0, 0, fmt.Errorf("timeout must be positive: %s", b.Timeout)
+		}
+		timeout = d
+	}
+
+	var retries int
+	if b.Retries != nil {
+		if *b.Retries <= 0 {
+			return 0, 0, fmt.Errorf("retries must be positive: %d", *b.Retries)
+		}
+		retries = *b.Retries
+	}
+	return timeout, retries, nil
+}
+
+// validateEnv returns an error if a given map of environment variables has
+// an invalid name.
+func validateEnv(env map[string]string) error {
+	for k := range env {
+		if len(k) == 0 || strings.ContainsAny(k, "=\x00") {
+			return fmt.Errorf("invalid name of env: %q", k)
+		}
+	}
+	return nil
+}
+
+// decodeMigrationFile decodes a given source of migration file.
+// A YAML file is decoded as a JSON file in HCL JSON syntax, so that it has
+// the same schema. The others are decoded by the syntax of its extension.
+func decodeMigrationFile(filename string, source []byte, ctx *hcl.EvalContext, f any) error {
+	if !isYAMLFile(filename) {
+		return hclsimple.Decode(filename, source, ctx, f)
+	}
+
+	b, err := yamlToJSON(source)
+	if err != nil {
+		return err
+	}
+	file, diags := hcljson.Parse(b, filename)
+	if diags.HasErrors() {
+		return diags
+	}
+	diags = gohcl.DecodeBody(file.Body, ctx, f)
+	if diags.HasErrors() {
+		return diags
+	}
+	return nil
+}
+
+// isYAMLFile returns true if a given filename has an extension of YAML.
+func isYAMLFile(filename string) bool {
+	ext := filepath.Ext(filename)
+	return ext == ".yaml" || ext == ".yml"
+}
+
+// yamlToJSON converts a given source in YAML to JSON.
+func yamlToJSON(source []byte) ([]byte, error) {
+	var v interface{}
+	if err := yaml.Unmarshal(source, &v); err != nil {
+		return nil, fmt.Errorf("failed to parse YAML: %s", err)
+	}
+	b, err := json.Marshal(v)
+	if err != nil {
+		return nil, fmt.Errorf("failed to convert YAML to JSON: %s", err)
+	}
+	return b, nil
+}
+
 // parseMigrationBlock parses a migration block and returns a tfmigrate.MigratorConfig.
 func parseMigrationBlock(b MigrationBlock, ctx *hcl.EvalContext) (tfmigrate.MigratorConfig, error) {
 	switch b.Type {
@@ -88,6 +249,9 @@ func parseMigrationBlock(b MigrationBlock, ctx *hcl.EvalContext) (tfmigrate.Migr
 	case "multi_state":
 		return parseMultiStateMigrationBlock(b, ctx)
 
+	case "move_between_workspaces":
+		return parseMoveBetweenWorkspacesMigrationBlock(b, ctx)
+
 	default:
 		return nil, fmt.Errorf("unknown migration type: %s", b.Type)
 	}
@@ -123,6 +287,21 @@ func parseMultiStateMigrationBlock(b MigrationBlock, ctx *hcl.EvalContext) (tfmi
 	if diags.HasErrors() {
 		return nil, diags
 	}
+	if err := errors.Join(validateEnv(config.FromEnv), validateEnv(config.ToEnv)); err != nil {
+		return nil, err
+	}
+
+	return &config, nil
+}
+
+// parseMoveBetweenWorkspacesMigrationBlock parses a migration block for
+// move_between_workspaces and returns a tfmigrate.MigratorConfig.
+func parseMoveBetweenWorkspacesMigrationBlock(b MigrationBlock, ctx *hcl.EvalContext) (tfmigrate.MigratorConfig, error) {
+	var config tfmigrate.MoveBetweenWorkspacesMigratorConfig
+	diags := gohcl.DecodeBody(b.Remain, ctx, &config)
+	if diags.HasErrors() {
+		return nil, diags
+	}
 
 	return &config, nil
 }
@@ -3,8 +3,10 @@ package config
 import (
 	"reflect"
 	"testing"
+	"time"
 
 	"github.com/minamijoyo/tfmigrate/tfmigrate"
+	"github.com/zclconf/go-cty/cty"
 )
 
 func TestParseMigrationFileWithNativeSyntax(t *testing.T) {
@@ -140,6 +142,140 @@ migration "state" "test" {
 			want:   nil,
 			ok:     false,
 		},
+		{
+			desc: "state with timeout and retries",
+			source: `
+migration "state" "test" {
+	timeout = "2h"
+	retries = 5
+	actions = [
+		"import time_static.qux 2006-01-02T15:04:05Z",
+	]
+}
+`,
+			want: &tfmigrate.MigrationConfig{
+				Type: "state",
+				Name: "test",
+				Migrator: &tfmigrate.StateMigratorConfig{
+					Actions: []string{
+						"import time_static.qux 2006-01-02T15:04:05Z",
+					},
+				},
+				Timeout: 2 * time.Hour,
+				Retries: 5,
+			},
+			ok: true,
+		},
+		{
+			desc: "state with invalid timeout",
+			source: `
+migration "state" "test" {
+	timeout = "foo"
+	actions = [
+		"rm time_static.baz",
+	]
+}
+`,
+			want: nil,
+			ok:   false,
+		},
+		{
+			desc: "state with zero timeout",
+			source: `
+migration "state" "test" {
+	timeout = "0s"
+	actions = [
+		"rm time_static.baz",
+	]
+}
+`,
+			want: nil,
+			ok:   false,
+		},
+		{
+			desc: "state with env",
+			source: `
+migration "state" "test" {
+	env = {
+		AWS_PROFILE = "account1"
+	}
+	actions = [
+		"rm time_static.baz",
+	]
+}
+`,
+			want: &tfmigrate.MigrationConfig{
+				Type: "state",
+				Name: "test",
+				Migrator: &tfmigrate.StateMigratorConfig{
+					Actions: []string{
+						"rm time_static.baz",
+					},
+				},
+				Env: map[string]string{"AWS_PROFILE": "account1"},
+			},
+			ok: true,
+		},
+		{
+			desc: "state with invalid env",
+			source: `
+migration "state" "test" {
+	env = {
+		"FOO=BAR" = "baz"
+	}
+	actions = [
+		"rm time_static.baz",
+	]
+}
+`,
+			want: nil,
+			ok:   false,
+		},
+		{
+			desc: "multi state with from_env and to_env",
+			source: `
+migration "multi_state" "mv_dir1_dir2" {
+	from_dir = "dir1"
+	to_dir   = "dir2"
+	from_env = {
+		AWS_PROFILE = "account1"
+	}
+	to_env = {
+		AWS_PROFILE = "account2"
+	}
+	actions = [
+		"mv null_resource.foo null_resource.foo2",
+	]
+}
+`,
+			want: &tfmigrate.MigrationConfig{
+				Type: "multi_state",
+				Name: "mv_dir1_dir2",
+				Migrator: &tfmigrate.MultiStateMigratorConfig{
+					FromDir: "dir1",
+					ToDir:   "dir2",
+					FromEnv: map[string]string{"AWS_PROFILE": "account1"},
+					ToEnv:   map[string]string{"AWS_PROFILE": "account2"},
+					Actions: []string{
+						"mv null_resource.foo null_resource.foo2",
+					},
+				},
+			},
+			ok: true,
+		},
+		{
+			desc: "state with negative retries",
+			source: `
+migration "state" "test" {
+	retries = -1
+	actions = [
+		"rm time_static.baz",
+	]
+}
+`,
+			want: nil,
+			ok:   false,
+		},
 		{
 			desc: "state with force",
 			source: `
@@ -263,6 +399,45 @@ migration "multi_state" "mv_dir1_dir2" {
 			},
 			ok: true,
 		},
+		{
+			desc: "move between workspaces",
+			source: `
+migration "move_between_workspaces" "staging_to_prod" {
+	dir            = "dir1"
+	from_workspace = "staging"
+	to_workspace   = "prod"
+	actions = [
+		"mv null_resource.foo null_resource.foo",
+	]
+}
+`,
+			want: &tfmigrate.MigrationConfig{
+				Type: "move_between_workspaces",
+				Name: "staging_to_prod",
+				Migrator: &tfmigrate.MoveBetweenWorkspacesMigratorConfig{
+					Dir:           "dir1",
+					FromWorkspace: "staging",
+					ToWorkspace:   "prod",
+					Actions: []string{
+						"mv null_resource.foo null_resource.foo",
+					},
+				},
+			},
+			ok: true,
+		},
+		{
+			desc: "move between workspaces without to_workspace",
+			source: `
+migration "move_between_workspaces" "staging_to_prod" {
+	from_workspace = "staging"
+	actions = [
+		"mv null_resource.foo null_resource.foo",
+	]
+}
+`,
+			want: nil,
+			ok:   false,
+		},
 		{
 			desc: "unknown migration type",
 			source: `
@@ -445,3 +620,388 @@ func TestParseMigrationFileWithJsonSyntax(t *testing.T) {
 		})
 	}
 }
+
+func TestParseMigrationFileWithYAMLSyntax(t *testing.T) {
+	cases := []struct {
+		desc     string
+		filename string
+		source   string
+		want     *tfmigrate.MigrationConfig
+		ok       bool
+	}{
+		{
+			desc:     "state with dir",
+			filename: "test.yaml",
+			source: `
+migration:
+  state:
+    test:
+      dir: dir1
+      actions:
+        - mv null_resource.foo null_resource.foo2
+        - rm time_static.baz
+        - import time_static.qux 2006-01-02T15:04:05Z
+`,
+			want: &tfmigrate.MigrationConfig{
+				Type: "state",
+				Name: "test",
+				Migrator: &tfmigrate.StateMigratorConfig{
+					Dir: "dir1",
+					Actions: []string{
+						"mv null_resource.foo null_resource.foo2",
+						"rm time_static.baz",
+						"import time_static.qux 2006-01-02T15:04:05Z",
+					},
+				},
+			},
+			ok: true,
+		},
+		{
+			desc:     "yml extension",
+			filename: "test.yml",
+			source: `
+migration:
+  state:
+    test:
+      actions:
+        - rm time_static.baz
+`,
+			want: &tfmigrate.MigrationConfig{
+				Type: "state",
+				Name: "test",
+				Migrator: &tfmigrate.StateMigratorConfig{
+					Actions: []string{
+						"rm time_static.baz",
+					},
+				},
+			},
+			ok: true,
+		},
+		{
+			desc:     "invalid yaml",
+			filename: "test.yaml",
+			source: `
+migration:
+  state: [
+`,
+			want: nil,
+			ok:   false,
+		},
+		{
+			desc:     "unknown attribute",
+			filename: "test.yaml",
+			source: `
+migration:
+  state:
+    test:
+      foo: bar
+      actions:
+        - rm time_static.baz
+`,
+			want: nil,
+			ok:   false,
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.desc, func(t *testing.T) {
+			got, err := ParseMigrationFile(tc.filename, []byte(tc.source))
+			if tc.ok && err != nil {
+				t.Fatalf("unexpected err: %s", err)
+			}
+			if !tc.ok && err == nil {
+				t.Fatalf("expected to return an error, but no error, got: %#v", got)
+			}
+			if tc.ok {
+				if !reflect.DeepEqual(got, tc.want) {
+					t.Errorf("got: %#v, want: %#v", got, tc.want)
+				}
+			}
+		})
+	}
+}
+
+func TestParseMigrationFileSyntaxEquivalence(t *testing.T) {
+	cases := []struct {
+		desc string
+		hcl  string
+		json string
+		yaml string
+	}{
+		{
+			desc: "state",
+			hcl: `
+migration "state" "test" {
+  dir   = "dir1"
+  force = true
+  actions = [
+    "mv null_resource.foo null_resource.foo2",
+    "import time_static.qux 2006-01-02T15:04:05Z",
+  ]
+}
+`,
+			json: `
+{
+  "migration": {
+    "state": {
+      "test": {
+        "dir": "dir1",
+        "force": true,
+        "actions": [
+          "mv null_resource.foo null_resource.foo2",
+          "import time_static.qux 2006-01-02T15:04:05Z"
+        ]
+      }
+    }
+  }
+}
+`,
+			yaml: `
+migration:
+  state:
+    test:
+      dir: dir1
+      force: true
+      actions:
+        - mv null_resource.foo null_resource.foo2
+        - import time_static.qux 2006-01-02T15:04:05Z
+`,
+		},
+		{
+			desc: "multi_state",
+			hcl: `
+migration "multi_state" "mv_dir1_dir2" {
+  from_dir = "dir1"
+  to_dir   = "dir2"
+  actions = [
+    "mv aws_security_group.foo aws_security_group.foo2",
+  ]
+}
+`,
+			json: `
+{
+  "migration": {
+    "multi_state": {
+      "mv_dir1_dir2": {
+        "from_dir": "dir1",
+        "to_dir": "dir2",
+        "actions": [
+          "mv aws_security_group.foo aws_security_group.foo2"
+        ]
+      }
+    }
+  }
+}
+`,
+			yaml: `
+migration:
+  multi_state:
+    mv_dir1_dir2:
+      from_dir: dir1
+      to_dir: dir2
+      actions:
+        - mv aws_security_group.foo aws_security_group.foo2
+`,
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.desc, func(t *testing.T) {
+			want, err := ParseMigrationFile("test.hcl", []byte(tc.hcl))
+			if err != nil {
+				t.Fatalf("failed to parse hcl: %s", err)
+			}
+
+			gotJSON, err := ParseMigrationFile("test.json", []byte(tc.json))
+			if err != nil {
+				t.Fatalf("failed to parse json: %s", err)
+			}
+			if !reflect.DeepEqual(gotJSON, want) {
+				t.Errorf("json: got: %#v, want: %#v", gotJSON, want)
+			}
+
+			gotYAML, err := ParseMigrationFile("test.yaml", []byte(tc.yaml))
+			if err != nil {
+				t.Fatalf("failed to parse yaml: %s", err)
+			}
+			if !reflect.DeepEqual(gotYAML, want) {
+				t.Errorf("yaml: got: %#v, want: %#v", gotYAML, want)
+			}
+		})
+	}
+}
+
+func TestParseMigrationFileWithLocals(t *testing.T) {
+	cases := []struct {
+		desc   string
+		locals map[string]cty.Value
+		source string
+		want   *tfmigrate.MigrationConfig
+		ok     bool
+	}{
+		{
+			desc: "reference locals",
+			locals: map[string]cty.Value{
+				"dir":    cty.StringVal("env/prod"),
+				"bucket": cty.StringVal("tfstate-prod"),
+			},
+			source: `
+migration "state" "test" {
+  dir = local.dir
+  actions = [
+    "import aws_s3_bucket.foo ${local.bucket}",
+  ]
+}
+`,
+			want: &tfmigrate.MigrationConfig{
+				Type: "state",
+				Name: "test",
+				Migrator: &tfmigrate.StateMigratorConfig{
+					Dir: "env/prod",
+					Actions: []string{
+						"import aws_s3_bucket.foo tfstate-prod",
+					},
+				},
+			},
+			ok: true,
+		},
+		{
+			desc: "undefined local",
+			locals: map[string]cty.Value{
+				"dir": cty.StringVal("env/prod"),
+			},
+			source: `
+migration "state" "test" {
+  dir = local.foo
+  actions = []
+}
+`,
+			want: nil,
+			ok:   false,
+		},
+		{
+			desc:   "no locals",
+			locals: nil,
+			source: `
+migration "state" "test" {
+  dir = local.dir
+  actions = []
+}
+`,
+			want: nil,
+			ok:   false,
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.desc, func(t *testing.T) {
+			got, err := ParseMigrationFileWithLocals("test.hcl", []byte(tc.source), tc.locals)
+			if tc.ok && err != nil {
+				t.Fatalf("unexpected err: %s", err)
+			}
+			if !tc.ok && err == nil {
+				t.Fatalf("expected to return an error, but no error, got: %#v", got)
+			}
+			if tc.ok {
+				if !reflect.DeepEqual(got, tc.want) {
+					t.Errorf("got: %#v, want: %#v", got, tc.want)
+				}
+			}
+		})
+	}
+}
+
+func TestParseMigrationFileHeader(t *testing.T) {
+	cases := []struct {
+		desc     string
+		filename string
+		source   string
+		wantType string
+		wantName string
+		ok       bool
+	}{
+		{
+			desc:     "native syntax",
+			filename: "test.hcl",
+			source: `
+migration "state" "test" {
+  dir = local.dir
+  actions = [
+    "mv aws_security_group.foo ${remote_state.network.outputs.name}",
+  ]
+}
+
+remote_state "network" {
+  dir = "network"
+}
+`,
+			wantType: "state",
+			wantName: "test",
+			ok:       true,
+		},
+		{
+			desc:     "json syntax",
+			filename: "test.json",
+			source: `{
+  "migration": {
+    "state": {
+      "test": {
+        "dir": "${local.dir}",
+        "actions": []
+      }
+    }
+  }
+}`,
+			wantType: "state",
+			wantName: "test",
+			ok:       true,
+		},
+		{
+			desc:     "yaml syntax",
+			filename: "test.yaml",
+			source: `
+migration:
+  state:
+    test:
+      dir: ${local.dir}
+      actions: []
+`,
+			wantType: "state",
+			wantName: "test",
+			ok:       true,
+		},
+		{
+			desc:     "no migration block",
+			filename: "test.hcl",
+			source: `
+remote_state "network" {
+  dir = "network"
+}
+`,
+			ok: false,
+		},
+		{
+			desc:     "syntax error",
+			filename: "test.hcl",
+			source: `
+migration "state" "test" {
+`,
+			ok: false,
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.desc, func(t *testing.T) {
+			gotType, gotName, err := ParseMigrationFileHeader(tc.filename, []byte(tc.source))
+			if tc.ok && err != nil {
+				t.Fatalf("unexpected err: %s", err)
+			}
+			if !tc.ok && err == nil {
+				t.Fatalf("expected to return an error, but no error, got: %s %s", gotType, gotName)
+			}
+			if tc.ok && (gotType != tc.wantType || gotName != tc.wantName) {
+				t.Errorf("got: %s %s, want: %s %s", gotType, gotName, tc.wantType, tc.wantName)
+			}
+		})
+	}
+}
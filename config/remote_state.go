@@ -0,0 +1,95 @@
+package config
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+
+	"github.com/zclconf/go-cty/cty"
+	ctyjson "github.com/zclconf/go-cty/cty/json"
+
+	"github.com/minamijoyo/tfmigrate/tfexec"
+)
+
+// RemoteStateBlock represents a remote_state block in a migration file.
+// Outputs of the current remote state of another dir can be referenced via
+// `remote_state.<name>.outputs.<output>` in the migration block.
+type RemoteStateBlock struct {
+	// Name is a name to reference the remote state.
+	Name string `hcl:"name,label"`
+	// Dir is a working directory of the remote state.
+	Dir string `hcl:"dir"`
+	// Workspace is a terraform workspace of the remote state.
+	// Default to `default`.
+	Workspace string `hcl:"workspace,optional"`
+}
+
+// RemoteStateReader returns the current remote state of a given dir and
+// workspace. It's used to resolve references to remote_state blocks.
+type RemoteStateReader func(dir string, workspace string) (*tfexec.State, error)
+
+// parseRemoteStateBlocks reads remote states of given remote_state blocks
+// with a given reader, and returns a value which is referenced as
+// `remote_state` in the migration block.
+func parseRemoteStateBlocks(blocks []RemoteStateBlock, reader RemoteStateReader) (cty.Value, error) {
+	remoteStates := make(map[string]cty.Value, len(blocks))
+	for _, b := range blocks {
+		if _, ok := remoteStates[b.Name]; ok {
+			return cty.NilVal, fmt.Errorf("duplicate remote_state block: %s", b.Name)
+		}
+		if reader == nil {
+			return cty.NilVal, fmt.Errorf("remote_state block is not supported in this context: %s", b.Name)
+		}
+
+		workspace := b.Workspace
+		if len(workspace) == 0 {
+			workspace = "default"
+		}
+		state, err := reader(b.Dir, workspace)
+		if err != nil {
+			return cty.NilVal, fmt.Errorf("failed to read remote_state %s in %s (workspace %s): %s", b.Name, b.Dir, workspace, err)
+		}
+		outputs, err := stateOutputs(state)
+		if err != nil {
+			return cty.NilVal, fmt.Errorf("failed to read remote_state %s in %s (workspace %s): %s", b.Name, b.Dir, workspace, err)
+		}
+		remoteStates[b.Name] = cty.ObjectVal(map[string]cty.Value{
+			"outputs": outputs,
+		})
+	}
+	return cty.ObjectVal(remoteStates), nil
+}
+
+// stateOutputs parses outputs of the root module in a given tfstate and
+// returns them as an object. It returns an error if the state is empty,
+// because a reference to it is most likely a mistake of dir or workspace.
+func stateOutputs(state *tfexec.State) (cty.Value, error) {
+	b := bytes.TrimSpace(state.Bytes())
+	if len(b) == 0 {
+		return cty.NilVal, fmt.Errorf("the state is empty")
+	}
+
+	var s struct {
+		Outputs map[string]struct {
+			Value json.RawMessage `json:"value"`
+			Type  json.RawMessage `json:"type"`
+		} `json:"outputs"`
+	}
+	if err := json.Unmarshal(b, &s); err != nil {
+		return cty.NilVal, fmt.Errorf("failed to parse tfstate: %s", err)
+	}
+
+	outputs := make(map[string]cty.Value, len(s.Outputs))
+	for name, o := range s.Outputs {
+		ty, err := ctyjson.UnmarshalType(o.Type)
+		if err != nil {
+			return cty.NilVal, fmt.Errorf("failed to parse the type of output %s: %s", name, err)
+		}
+		v, err := ctyjson.Unmarshal(o.Value, ty)
+		if err != nil {
+			return cty.NilVal, fmt.Errorf("failed to parse the value of output %s: %s", name, err)
+		}
+		outputs[name] = v
+	}
+	return cty.ObjectVal(outputs), nil
+}
@@ -0,0 +1,209 @@
+package config
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+
+	"github.com/minamijoyo/tfmigrate/tfexec"
+	"github.com/minamijoyo/tfmigrate/tfmigrate"
+)
+
+func TestParseMigrationFileWithRemoteState(t *testing.T) {
+	networkState := `{
+  "version": 4,
+  "terraform_version": "1.5.7",
+  "serial": 1,
+  "lineage": "3d2b7e0c-0e3a-4d3b-9f5e-1a0c7e6d2f10",
+  "outputs": {
+    "vpc_id": {
+      "value": "vpc-0123",
+      "type": "string"
+    },
+    "subnet_ids": {
+      "value": ["subnet-a", "subnet-b"],
+      "type": ["list", "string"]
+    }
+  },
+  "resources": []
+}`
+	states := map[string]string{
+		"network/default": networkState,
+		"network/prod":    "",
+	}
+	reader := func(dir string, workspace string) (*tfexec.State, error) {
+		s, ok := states[dir+"/"+workspace]
+		if !ok {
+			return nil, fmt.Errorf("no such dir: %s", dir)
+		}
+		return tfexec.NewState([]byte(s)), nil
+	}
+
+	cases := []struct {
+		desc   string
+		reader RemoteStateReader
+		source string
+		want   *tfmigrate.MigrationConfig
+		ok     bool
+	}{
+		{
+			desc:   "reference outputs",
+			reader: reader,
+			source: `
+remote_state "network" {
+  dir = "network"
+}
+
+migration "state" "test" {
+  dir = "dir1"
+  actions = [
+    "import aws_vpc.foo ${remote_state.network.outputs.vpc_id}",
+    "import aws_subnet.foo ${remote_state.network.outputs.subnet_ids[1]}",
+  ]
+}
+`,
+			want: &tfmigrate.MigrationConfig{
+				Type: "state",
+				Name: "test",
+				Migrator: &tfmigrate.StateMigratorConfig{
+					Dir: "dir1",
+					Actions: []string{
+						"import aws_vpc.foo vpc-0123",
+						"import aws_subnet.foo subnet-b",
+					},
+				},
+			},
+			ok: true,
+		},
+		{
+			desc:   "undefined output",
+			reader: reader,
+			source: `
+remote_state "network" {
+  dir = "network"
+}
+
+migration "state" "test" {
+  dir = "dir1"
+  actions = [
+    "import aws_vpc.foo ${remote_state.network.outputs.foo}",
+  ]
+}
+`,
+			want: nil,
+			ok:   false,
+		},
+		{
+			desc:   "undefined remote_state",
+			reader: reader,
+			source: `
+remote_state "network" {
+  dir = "network"
+}
+
+migration "state" "test" {
+  dir = "dir1"
+  actions = [
+    "import aws_vpc.foo ${remote_state.foo.outputs.vpc_id}",
+  ]
+}
+`,
+			want: nil,
+			ok:   false,
+		},
+		{
+			desc:   "empty state",
+			reader: reader,
+			source: `
+remote_state "network" {
+  dir       = "network"
+  workspace = "prod"
+}
+
+migration "state" "test" {
+  dir = "dir1"
+  actions = [
+    "import aws_vpc.foo ${remote_state.network.outputs.vpc_id}",
+  ]
+}
+`,
+			want: nil,
+			ok:   false,
+		},
+		{
+			desc:   "failed to read",
+			reader: reader,
+			source: `
+remote_state "network" {
+  dir = "foo"
+}
+
+migration "state" "test" {
+  dir = "dir1"
+  actions = [
+    "import aws_vpc.foo ${remote_state.network.outputs.vpc_id}",
+  ]
+}
+`,
+			want: nil,
+			ok:   false,
+		},
+		{
+			desc:   "duplicate remote_state",
+			reader: reader,
+			source: `
+remote_state "network" {
+  dir = "network"
+}
+
+remote_state "network" {
+  dir = "network"
+}
+
+migration "state" "test" {
+  dir = "dir1"
+  actions = [
+    "import aws_vpc.foo ${remote_state.network.outputs.vpc_id}",
+  ]
+}
+`,
+			want: nil,
+			ok:   false,
+		},
+		{
+			desc:   "no reader",
+			reader: nil,
+			source: `
+remote_state "network" {
+  dir = "network"
+}
+
+migration "state" "test" {
+  dir = "dir1"
+  actions = [
+    "import aws_vpc.foo ${remote_state.network.outputs.vpc_id}",
+  ]
+}
+`,
+			want: nil,
+			ok:   false,
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.desc, func(t *testing.T) {
+			got, err := ParseMigrationFileWithRemoteState("test.hcl", []byte(tc.source), nil, tc.reader)
+			if tc.ok && err != nil {
+				t.Fatalf("unexpected err: %s", err)
+			}
+			if !tc.ok && err == nil {
+				t.Fatalf("expected to return an error, but no error, got: %#v", got)
+			}
+			if tc.ok {
+				if !reflect.DeepEqual(got, tc.want) {
+					t.Errorf("got: %#v, want: %#v", got, tc.want)
+				}
+			}
+		})
+	}
+}
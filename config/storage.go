@@ -9,6 +9,7 @@ import (
 	"github.com/minamijoyo/tfmigrate/storage/gcs"
 	"github.com/minamijoyo/tfmigrate/storage/local"
 	"github.com/minamijoyo/tfmigrate/storage/mock"
+	"github.com/minamijoyo/tfmigrate/storage/pg"
 	"github.com/minamijoyo/tfmigrate/storage/s3"
 )
 
@@ -19,6 +20,9 @@ type StorageBlock struct {
 	// - mock
 	// - local
 	// - s3
+	// - gcs
+	// - pg
+	// A custom type registered by RegisterStorageType is also valid.
 	Type string `hcl:"type,label"`
 	// Remain is a body of storage block.
 	// We first decode only a block header and then decode schema depending on
@@ -41,8 +45,15 @@ func parseStorageBlock(b StorageBlock) (storage.Config, error) {
 	case "gcs":
 		return parseGCSStorageBlock(b)
 
+	case "pg":
+		return parsePGStorageBlock(b)
+
 	default:
-		return nil, fmt.Errorf("unknown history storage type: %s", b.Type)
+		config, ok, err := parseCustomStorageBlock(b)
+		if !ok {
+			return nil, fmt.Errorf("unknown history storage type: %s", b.Type)
+		}
+		return config, err
 	}
 }
 
@@ -88,3 +99,14 @@ func parseGCSStorageBlock(b StorageBlock) (storage.Config, error) {
 
 	return &config, nil
 }
+
+// parsePGStorageBlock parses a storage block for pg and returns a storage.Config.
+func parsePGStorageBlock(b StorageBlock) (storage.Config, error) {
+	var config pg.Config
+	diags := gohcl.DecodeBody(b.Remain, nil, &config)
+	if diags.HasErrors() {
+		return nil, diags
+	}
+
+	return &config, nil
+}
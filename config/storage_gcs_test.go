@@ -33,6 +33,26 @@ tfmigrate {
 			},
 			ok: true,
 		},
+		{
+			desc: "valid (with optional)",
+			source: `
+tfmigrate {
+  history {
+    storage "gcs" {
+      bucket            = "tfmigrate-test"
+      name              = "tfmigrate/history.json"
+      conditional_write = true
+    }
+  }
+}
+`,
+			want: &gcs.Config{
+				Bucket:           "tfmigrate-test",
+				Name:             "tfmigrate/history.json",
+				ConditionalWrite: true,
+			},
+			ok: true,
+		},
 		{
 			desc: "missing required attribute (bucket)",
 			source: `
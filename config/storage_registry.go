@@ -0,0 +1,77 @@
+package config
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/hashicorp/hcl/v2/gohcl"
+	"github.com/minamijoyo/tfmigrate/storage"
+)
+
+// builtinStorageTypes is a set of storage types implemented in tfmigrate.
+// They cannot be overridden by RegisterStorageType.
+var builtinStorageTypes = map[string]bool{
+	"mock":  true,
+	"local": true,
+	"s3":    true,
+	"gcs":   true,
+	"pg":    true,
+}
+
+// customStorageTypes is a registry of storage types registered by
+// RegisterStorageType. It's a map of a type to a factory of storage.Config.
+var customStorageTypes = struct {
+	mu        sync.RWMutex
+	factories map[string]func() storage.Config
+}{
+	factories: map[string]func() storage.Config{},
+}
+
+// RegisterStorageType registers a custom storage type, so that it can be
+// referenced by name in a storage block, such as `storage "foo" {}`.
+// It's intended for embedding tfmigrate with a custom implementation of
+// storage.Storage. A given factory must return a pointer to a new struct
+// implementing storage.Config, which is decoded from the body of the storage
+// block with hcl struct tags as the same as the built-in types.
+// The storage must follow the contract of storage.Storage. In particular,
+// its Read must return empty bytes and a nil error when no history exists.
+// It returns an error if the type is empty, built-in or already registered.
+func RegisterStorageType(typ string, factory func() storage.Config) error {
+	if len(typ) == 0 {
+		return fmt.Errorf("storage type must not be empty")
+	}
+	if factory == nil {
+		return fmt.Errorf("storage factory must not be nil: %s", typ)
+	}
+	if builtinStorageTypes[typ] {
+		return fmt.Errorf("cannot override a built-in storage type: %s", typ)
+	}
+
+	customStorageTypes.mu.Lock()
+	defer customStorageTypes.mu.Unlock()
+	if _, ok := customStorageTypes.factories[typ]; ok {
+		return fmt.Errorf("storage type has already been registered: %s", typ)
+	}
+	customStorageTypes.factories[typ] = factory
+	return nil
+}
+
+// parseCustomStorageBlock parses a storage block for a custom storage type
+// registered by RegisterStorageType and returns a storage.Config.
+// It returns false if the type is not registered.
+func parseCustomStorageBlock(b StorageBlock) (storage.Config, bool, error) {
+	customStorageTypes.mu.RLock()
+	factory, ok := customStorageTypes.factories[b.Type]
+	customStorageTypes.mu.RUnlock()
+	if !ok {
+		return nil, false, nil
+	}
+
+	config := factory()
+	diags := gohcl.DecodeBody(b.Remain, nil, config)
+	if diags.HasErrors() {
+		return nil, true, diags
+	}
+
+	return config, true, nil
+}
@@ -50,6 +50,7 @@ tfmigrate {
       skip_credentials_validation = true
       skip_metadata_api_check     = true
       force_path_style            = true
+      conditional_write           = true
     }
   }
 }
@@ -65,6 +66,7 @@ tfmigrate {
 				SkipCredentialsValidation: true,
 				SkipMetadataAPICheck:      true,
 				ForcePathStyle:            true,
+				ConditionalWrite:          true,
 			},
 			ok: true,
 		},
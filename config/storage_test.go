@@ -77,3 +77,112 @@ tfmigrate {
 		})
 	}
 }
+
+// testCustomStorageConfig is a storage.Config for testing a custom storage
+// type registered by RegisterStorageType.
+type testCustomStorageConfig struct {
+	Endpoint string `hcl:"endpoint"`
+}
+
+func (c *testCustomStorageConfig) NewStorage() (storage.Storage, error) {
+	return nil, nil
+}
+
+// unregisterStorageType removes a custom storage type for testing.
+func unregisterStorageType(typ string) {
+	customStorageTypes.mu.Lock()
+	defer customStorageTypes.mu.Unlock()
+	delete(customStorageTypes.factories, typ)
+}
+
+func TestRegisterStorageType(t *testing.T) {
+	factory := func() storage.Config { return &testCustomStorageConfig{} }
+	if err := RegisterStorageType("test_custom", factory); err != nil {
+		t.Fatalf("failed to register a storage type: %s", err)
+	}
+	t.Cleanup(func() { unregisterStorageType("test_custom") })
+
+	cases := []struct {
+		desc   string
+		source string
+		want   storage.Config
+		ok     bool
+	}{
+		{
+			desc: "custom",
+			source: `
+tfmigrate {
+  history {
+    storage "test_custom" {
+      endpoint = "https://example.com/history"
+    }
+  }
+}
+`,
+			want: &testCustomStorageConfig{
+				Endpoint: "https://example.com/history",
+			},
+			ok: true,
+		},
+		{
+			desc: "custom with unknown attribute",
+			source: `
+tfmigrate {
+  history {
+    storage "test_custom" {
+      endpoint = "https://example.com/history"
+      foo      = "bar"
+    }
+  }
+}
+`,
+			want: nil,
+			ok:   false,
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.desc, func(t *testing.T) {
+			config, err := ParseConfigurationFile("test.hcl", []byte(tc.source))
+			if tc.ok && err != nil {
+				t.Fatalf("unexpected err: %s", err)
+			}
+			if !tc.ok && err == nil {
+				t.Fatalf("expected to return an error, but no error, got: %#v", config)
+			}
+			if tc.ok {
+				got := config.History.Storage
+				if !reflect.DeepEqual(got, tc.want) {
+					t.Errorf("got: %#v, want: %#v", got, tc.want)
+				}
+			}
+		})
+	}
+}
+
+func TestRegisterStorageTypeInvalid(t *testing.T) {
+	factory := func() storage.Config { return &testCustomStorageConfig{} }
+	if err := RegisterStorageType("test_duplicate", factory); err != nil {
+		t.Fatalf("failed to register a storage type: %s", err)
+	}
+	t.Cleanup(func() { unregisterStorageType("test_duplicate") })
+
+	cases := []struct {
+		desc    string
+		typ     string
+		factory func() storage.Config
+	}{
+		{desc: "empty", typ: "", factory: factory},
+		{desc: "nil factory", typ: "test_nil", factory: nil},
+		{desc: "built-in", typ: "s3", factory: factory},
+		{desc: "duplicate", typ: "test_duplicate", factory: factory},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.desc, func(t *testing.T) {
+			if err := RegisterStorageType(tc.typ, tc.factory); err == nil {
+				t.Fatal("expected to return an error, but no error")
+			}
+		})
+	}
+}
@@ -3,9 +3,17 @@ package config
 import (
 	"fmt"
 	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
 
+	"github.com/hashicorp/hcl/v2"
 	"github.com/hashicorp/hcl/v2/hclsimple"
+	"github.com/minamijoyo/tfmigrate/guard"
 	"github.com/minamijoyo/tfmigrate/history"
+	"github.com/minamijoyo/tfmigrate/tfexec"
+	"github.com/zclconf/go-cty/cty"
 )
 
 // ConfigurationFile represents a file for CLI settings in HCL.
@@ -20,25 +28,216 @@ type TfmigrateBlock struct {
 	// MigrationDir is a path to directory where migration files are stored.
 	// Default to `.` (current directory).
 	MigrationDir string `hcl:"migration_dir,optional"`
+	// MigrationArchiveSHA256 is a hex-encoded SHA-256 checksum of an archive
+	// given as the migration_dir. If set, the archive is verified before read.
+	MigrationArchiveSHA256 string `hcl:"migration_archive_sha256,optional"`
+	// DefaultDir is a default working directory for migrations which don't
+	// set the dir attribute.
+	DefaultDir string `hcl:"default_dir,optional"`
 	// IsBackendTerraformCloud is a boolean indicating whether a backend is
 	// stored remotely in Terraform Cloud. Defaults to false.
 	IsBackendTerraformCloud bool `hcl:"is_backend_terraform_cloud,optional"`
+	// TerraformVersionPaths is a map of terraform version to a path of binary.
+	// It's used for resolving a terraform_version in a migration block.
+	TerraformVersionPaths map[string]string `hcl:"terraform_version_paths,optional"`
+	// RedactPatterns is a list of regular expressions for secrets to be
+	// masked in log and error output in addition to the default patterns.
+	RedactPatterns []string `hcl:"redact_patterns,optional"`
+	// LogLevel is a minimum log level of tfmigrate.
+	// Valid values are TRACE, DEBUG, INFO, WARN and ERROR.
+	LogLevel string `hcl:"log_level,optional"`
+	// TmpDir is a directory where intermediate state and plan files are
+	// written. Default to the default directory for temporary files.
+	TmpDir string `hcl:"tmp_dir,optional"`
+	// PluginCacheDir is a directory of terraform plugin cache shared across
+	// migrations. It's passed to terraform as TF_PLUGIN_CACHE_DIR.
+	PluginCacheDir string `hcl:"plugin_cache_dir,optional"`
+	// Validate runs terraform validate before plan for all migrations.
+	// Defaults to false.
+	Validate bool `hcl:"validate,optional"`
+	// WarningsAsErrors fails a migration if terraform plan for verification
+	// reports any warnings. Defaults to false.
+	WarningsAsErrors bool `hcl:"warnings_as_errors,optional"`
+	// AllowedWarnings is a list of summaries of warnings ignored by
+	// warnings_as_errors and state_mv_warnings_as_errors.
+	AllowedWarnings []string `hcl:"allowed_warnings,optional"`
+	// StateMvWarningsAsErrors fails a migration if terraform state mv reports
+	// any warnings such as a missing provider configuration. Defaults to false.
+	StateMvWarningsAsErrors bool `hcl:"state_mv_warnings_as_errors,optional"`
+	// StreamState streams a pulled state to a temporary file instead of
+	// holding it in memory. It's useful for a large state. Defaults to false.
+	StreamState bool `hcl:"stream_state,optional"`
+	// InitArgs is a list of extra arguments passed to terraform init such as
+	// `-upgrade` and `-reconfigure`.
+	InitArgs []string `hcl:"init_args,optional"`
+	// InitTimeout is a timeout for each terraform init such as `5m`.
+	InitTimeout string `hcl:"init_timeout,optional"`
+	// PlanTimeout is a timeout for each terraform plan such as `30m`.
+	PlanTimeout string `hcl:"plan_timeout,optional"`
+	// PushTimeout is a timeout for each terraform state push such as `5m`.
+	PushTimeout string `hcl:"push_timeout,optional"`
+	// Terraform is a block for selecting a terraform binary per working dir.
+	Terraform *TerraformBlock `hcl:"terraform,block"`
+	// Hook is a block for commands run before and after a run.
+	Hook *HookBlock `hcl:"hook,block"`
+	// LockRetry is a block for retries when the state is locked.
+	LockRetry *LockRetryBlock `hcl:"lock_retry,block"`
+	// AccountGuard is a block for verifying the active cloud account before
+	// a new state is pushed.
+	AccountGuard *AccountGuardBlock `hcl:"account_guard,block"`
+	// Locals is a block for values shared across migration files.
+	Locals *LocalsBlock `hcl:"locals,block"`
 	// History is a block for migration history management.
 	History *HistoryBlock `hcl:"history,block"`
 }
 
+// TerraformBlock represents a block for selecting a terraform binary per
+// working dir in HCL.
+type TerraformBlock struct {
+	// DefaultExecPath is used when no dir block matches a working directory.
+	DefaultExecPath string `hcl:"default_exec_path,optional"`
+	// Dirs is a list of dir blocks. The first matched block is used.
+	Dirs []TerraformDirBlock `hcl:"dir,block"`
+}
+
+// TerraformDirBlock represents a block which maps a working directory to a
+// terraform binary in HCL.
+type TerraformDirBlock struct {
+	// Pattern is a glob pattern of a working directory.
+	Pattern string `hcl:"pattern,label"`
+	// ExecPath is a string how terraform command is executed.
+	ExecPath string `hcl:"exec_path"`
+}
+
+// LockRetryBlock represents a block for retries with backoff of terraform
+// state pull and push when the state is locked by another process in HCL.
+// It's intended for busy CI where applies overlap.
+type LockRetryBlock struct {
+	// Attempts is a maximum number of retries.
+	Attempts int `hcl:"attempts"`
+	// Interval is an initial interval between retries such as `5s`, which
+	// doubles on every retry. Default to `5s`.
+	Interval string `hcl:"interval,optional"`
+	// MaxInterval is a maximum interval between retries such as `1m`.
+	// Default to no limit.
+	MaxInterval string `hcl:"max_interval,optional"`
+}
+
+// AccountGuardBlock represents a block for verifying that the active cloud
+// credentials target the expected account before a new state is pushed in
+// HCL. It's intended to prevent running a migration against a wrong account.
+type AccountGuardBlock struct {
+	// AWSAccountID is an expected AWS account ID for the s3 backend.
+	AWSAccountID string `hcl:"aws_account_id,optional"`
+	// AWSProfile is a name of AWS profile used for looking up the account.
+	AWSProfile string `hcl:"aws_profile,optional"`
+	// GCPProject is an expected GCP project ID for the gcs backend.
+	GCPProject string `hcl:"gcp_project,optional"`
+}
+
+// HookBlock represents a block for commands run once before and after a run
+// of plan or apply in HCL. It's intended for setting up connectivity to
+// backends such as an SSH tunnel through a bastion.
+type HookBlock struct {
+	// PreRun is a command run before any migrations in the form of a list of
+	// a program and its arguments.
+	PreRun []string `hcl:"pre_run,optional"`
+	// PostRun is a command run after all migrations in the form of a list of
+	// a program and its arguments.
+	PostRun []string `hcl:"post_run,optional"`
+}
+
+// LocalsBlock represents a block for values shared across migration files in
+// HCL. Its attributes are arbitrary names of values, which can be referenced
+// in migration files via `local.<name>`.
+type LocalsBlock struct {
+	// Remain is a body of locals block. Attributes are decoded dynamically.
+	Remain hcl.Body `hcl:",remain"`
+}
+
 // TfmigrateConfig is a config for top-level CLI settings.
 // TfmigrateBlock is just used for parsing HCL and
 // TfmigrateConfig is used for building application logic.
 type TfmigrateConfig struct {
 	// MigrationDir is a path to directory where migration files are stored.
 	// Default to `.` (current directory).
+	// It can also be a path to a .tar.gz or .tgz archive of migration files.
 	MigrationDir string
+	// MigrationArchiveSHA256 is a hex-encoded SHA-256 checksum of an archive
+	// given as the MigrationDir. If set, the archive is verified before read.
+	MigrationArchiveSHA256 string
+	// MigrationArchive is a path to an archive given as the MigrationDir.
+	// It's set when the archive is extracted and the MigrationDir is replaced
+	// with a temporary directory. It's empty if the MigrationDir is a plain
+	// directory.
+	MigrationArchive string
+	// DefaultDir is a default working directory for migrations which don't
+	// set the dir attribute. If empty, default to `.` (current directory).
+	DefaultDir string
 	// IsBackendTerraformCloud is a boolean representing whether the remote
 	// backend is TerraformCloud. Defaults to a value of false.
 	IsBackendTerraformCloud bool
+	// TerraformVersionPaths is a map of terraform version to a path of binary.
+	// It's used for resolving a terraform_version in a migration block.
+	TerraformVersionPaths map[string]string
+	// RedactPatterns is a list of regular expressions for secrets to be
+	// masked in log and error output in addition to the default patterns.
+	RedactPatterns []string
+	// LogLevel is a minimum log level of tfmigrate.
+	// It's empty if not set in the config file.
+	LogLevel string
+	// TmpDir is a directory where intermediate state and plan files are
+	// written. Default to the default directory for temporary files.
+	TmpDir string
+	// PluginCacheDir is a directory of terraform plugin cache shared across
+	// migrations. If empty, TF_PLUGIN_CACHE_DIR is inherited as it is.
+	PluginCacheDir string
+	// Validate runs terraform validate before plan for all migrations.
+	Validate bool
+	// WarningsAsErrors fails a migration if terraform plan for verification
+	// reports any warnings not allowed by AllowedWarnings.
+	WarningsAsErrors bool
+	// AllowedWarnings is a list of summaries of warnings ignored by
+	// WarningsAsErrors and StateMvWarningsAsErrors.
+	AllowedWarnings []string
+	// StateMvWarningsAsErrors fails a migration if terraform state mv reports
+	// any warnings not allowed by AllowedWarnings.
+	StateMvWarningsAsErrors bool
+	// StreamState streams a pulled state to a temporary file instead of
+	// holding it in memory.
+	StreamState bool
+	// InitArgs is a list of extra arguments passed to terraform init for
+	// working dirs.
+	InitArgs []string
+	// InitTimeout is a timeout for each terraform init.
+	// A zero value means no timeout.
+	InitTimeout time.Duration
+	// PlanTimeout is a timeout for each terraform plan.
+	// A zero value means no timeout.
+	PlanTimeout time.Duration
+	// PushTimeout is a timeout for each terraform state push.
+	// A zero value means no timeout.
+	PushTimeout time.Duration
+	// ExecPathResolver selects a terraform binary per working directory.
+	ExecPathResolver *tfexec.ExecPathResolver
+	// LockRetry is a setting of retries when the state is locked.
+	// A zero value means no retry.
+	LockRetry tfexec.LockRetry
+	// PreRunHook is a command run once before any migrations of plan or apply.
+	// It's a list of a program and its arguments. Empty means no hook.
+	PreRunHook []string
+	// PostRunHook is a command run once after all migrations of plan or apply,
+	// even if they failed. It's a list of a program and its arguments.
+	// Empty means no hook.
+	PostRunHook []string
+	// AccountGuard is a config for verifying the active cloud account before
+	// a new state is pushed. It's nil if not set.
+	AccountGuard *guard.Config
 	// History is a config for migration history management.
 	History *history.Config
+	// Locals is a map of values shared across migration files.
+	// They are referenced in migration files via `local.<name>`.
+	Locals map[string]cty.Value
 }
 
 // LoadConfigurationFile is a helper function which reads and parses a given configuration file.
@@ -67,9 +266,102 @@ func ParseConfigurationFile(filename string, source []byte) (*TfmigrateConfig, e
 	if len(f.Tfmigrate.MigrationDir) > 0 {
 		config.MigrationDir = f.Tfmigrate.MigrationDir
 	}
+	if len(f.Tfmigrate.MigrationArchiveSHA256) > 0 {
+		if !IsMigrationArchive(config.MigrationDir) {
+			return nil, fmt.Errorf("migration_archive_sha256 requires migration_dir to be a .tar.gz or .tgz archive: %s", config.MigrationDir)
+		}
+		if !sha256Re.MatchString(f.Tfmigrate.MigrationArchiveSHA256) {
+			return nil, fmt.Errorf("migration_archive_sha256 must be a hex-encoded SHA-256 checksum: %s", f.Tfmigrate.MigrationArchiveSHA256)
+		}
+		config.MigrationArchiveSHA256 = strings.ToLower(f.Tfmigrate.MigrationArchiveSHA256)
+	}
+	config.DefaultDir = f.Tfmigrate.DefaultDir
 	if f.Tfmigrate.IsBackendTerraformCloud {
 		config.IsBackendTerraformCloud = f.Tfmigrate.IsBackendTerraformCloud
 	}
+	if len(f.Tfmigrate.TerraformVersionPaths) > 0 {
+		config.TerraformVersionPaths = f.Tfmigrate.TerraformVersionPaths
+	}
+	for _, p := range f.Tfmigrate.RedactPatterns {
+		if _, err := regexp.Compile(p); err != nil {
+			return nil, fmt.Errorf("failed to compile redact_patterns: %s, err: %s", p, err)
+		}
+	}
+	config.RedactPatterns = f.Tfmigrate.RedactPatterns
+	if len(f.Tfmigrate.LogLevel) > 0 {
+		level, err := ParseLogLevel(f.Tfmigrate.LogLevel)
+		if err != nil {
+			return nil, err
+		}
+		config.LogLevel = level
+	}
+
+	config.TmpDir = f.Tfmigrate.TmpDir
+	config.PluginCacheDir = f.Tfmigrate.PluginCacheDir
+	config.Validate = f.Tfmigrate.Validate
+	config.WarningsAsErrors = f.Tfmigrate.WarningsAsErrors
+	config.AllowedWarnings = f.Tfmigrate.AllowedWarnings
+	config.StateMvWarningsAsErrors = f.Tfmigrate.StateMvWarningsAsErrors
+	config.StreamState = f.Tfmigrate.StreamState
+	for _, arg := range f.Tfmigrate.InitArgs {
+		if !strings.HasPrefix(arg, "-") {
+			return nil, fmt.Errorf("init_args must be options starting with `-`: %s", arg)
+		}
+	}
+	config.InitArgs = f.Tfmigrate.InitArgs
+
+	if config.InitTimeout, err = parseTimeout("init_timeout", f.Tfmigrate.InitTimeout); err != nil {
+		return nil, err
+	}
+	if config.PlanTimeout, err = parseTimeout("plan_timeout", f.Tfmigrate.PlanTimeout); err != nil {
+		return nil, err
+	}
+	if config.PushTimeout, err = parseTimeout("push_timeout", f.Tfmigrate.PushTimeout); err != nil {
+		return nil, err
+	}
+
+	if f.Tfmigrate.Terraform != nil {
+		resolver, err := parseTerraformBlock(*f.Tfmigrate.Terraform)
+		if err != nil {
+			return nil, err
+		}
+		config.ExecPathResolver = resolver
+	}
+
+	if f.Tfmigrate.LockRetry != nil {
+		lockRetry, err := parseLockRetryBlock(*f.Tfmigrate.LockRetry)
+		if err != nil {
+			return nil, err
+		}
+		config.LockRetry = lockRetry
+	}
+
+	if f.Tfmigrate.Hook != nil {
+		if err := validateHookCommand("pre_run", f.Tfmigrate.Hook.PreRun); err != nil {
+			return nil, err
+		}
+		if err := validateHookCommand("post_run", f.Tfmigrate.Hook.PostRun); err != nil {
+			return nil, err
+		}
+		config.PreRunHook = f.Tfmigrate.Hook.PreRun
+		config.PostRunHook = f.Tfmigrate.Hook.PostRun
+	}
+
+	if f.Tfmigrate.AccountGuard != nil {
+		accountGuard, err := parseAccountGuardBlock(*f.Tfmigrate.AccountGuard)
+		if err != nil {
+			return nil, err
+		}
+		config.AccountGuard = accountGuard
+	}
+
+	if f.Tfmigrate.Locals != nil {
+		locals, err := parseLocalsBlock(*f.Tfmigrate.Locals)
+		if err != nil {
+			return nil, err
+		}
+		config.Locals = locals
+	}
 
 	if f.Tfmigrate.History != nil {
 		history, err := parseHistoryBlock(*f.Tfmigrate.History)
@@ -82,6 +374,122 @@ func ParseConfigurationFile(filename string, source []byte) (*TfmigrateConfig, e
 	return config, nil
 }
 
+// sha256Re is a pattern of a hex-encoded SHA-256 checksum.
+var sha256Re = regexp.MustCompile(`^[0-9a-fA-F]{64}$`)
+
+// IsMigrationArchive returns true if a given migration dir is a path to an
+// archive of migration files.
+func IsMigrationArchive(migrationDir string) bool {
+	return strings.HasSuffix(migrationDir, ".tar.gz") || strings.HasSuffix(migrationDir, ".tgz")
+}
+
+// LogLevels is a list of valid log levels in order of verbosity.
+var LogLevels = []string{"TRACE", "DEBUG", "INFO", "WARN", "ERROR"}
+
+// ParseLogLevel validates a given log level and returns it in upper case.
+func ParseLogLevel(level string) (string, error) {
+	upper := strings.ToUpper(level)
+	for _, l := range LogLevels {
+		if upper == l {
+			return upper, nil
+		}
+	}
+	return "", fmt.Errorf("invalid log level: %s, valid values are %s", level, strings.Join(LogLevels, ", "))
+}
+
+// parseTerraformBlock parses a terraform block and returns an ExecPathResolver.
+func parseTerraformBlock(b TerraformBlock) (*tfexec.ExecPathResolver, error) {
+	resolver := &tfexec.ExecPathResolver{
+		DefaultExecPath: b.DefaultExecPath,
+	}
+	for _, d := range b.Dirs {
+		if _, err := filepath.Match(d.Pattern, ""); err != nil {
+			return nil, fmt.Errorf("invalid pattern of dir block in terraform block: %s, err: %s", d.Pattern, err)
+		}
+		resolver.Rules = append(resolver.Rules, tfexec.ExecPathRule{
+			Pattern:  d.Pattern,
+			ExecPath: d.ExecPath,
+		})
+	}
+	return resolver, nil
+}
+
+// parseLocalsBlock evaluates attributes of a locals block and returns a map of
+// values. Environment variables can be referenced via `env.<name>`.
+// Note that a local value cannot reference other local values.
+func parseLocalsBlock(b LocalsBlock) (map[string]cty.Value, error) {
+	attrs, diags := b.Remain.JustAttributes()
+	if diags.HasErrors() {
+		return nil, diags
+	}
+
+	ctx := &hcl.EvalContext{
+		Variables: map[string]cty.Value{
+			"env": envVarMap(),
+		},
+	}
+	locals := make(map[string]cty.Value, len(attrs))
+	for name, attr := range attrs {
+		v, diags := attr.Expr.Value(ctx)
+		if diags.HasErrors() {
+			return nil, diags
+		}
+		locals[name] = v
+	}
+	return locals, nil
+}
+
+// parseLockRetryBlock parses a lock_retry block and returns a tfexec.LockRetry.
+func parseLockRetryBlock(b LockRetryBlock) (tfexec.LockRetry, error) {
+	if b.Attempts < 0 {
+		return tfexec.LockRetry{}, fmt.Errorf("attempts in lock_retry block must not be negative: %d", b.Attempts)
+	}
+	interval, err := parseTimeout("interval in lock_retry block", b.Interval)
+	if err != nil {
+		return tfexec.LockRetry{}, err
+	}
+	maxInterval, err := parseTimeout("max_interval in lock_retry block", b.MaxInterval)
+	if err != nil {
+		return tfexec.LockRetry{}, err
+	}
+	return tfexec.LockRetry{
+		Attempts:    b.Attempts,
+		Interval:    interval,
+		MaxInterval: maxInterval,
+	}, nil
+}
+
+// awsAccountIDRe is a pattern of an AWS account ID.
+var awsAccountIDRe = regexp.MustCompile(`^[0-9]{12}$`)
+
+// parseAccountGuardBlock parses an account_guard block and returns a
+// guard.Config.
+func parseAccountGuardBlock(b AccountGuardBlock) (*guard.Config, error) {
+	if len(b.AWSAccountID) == 0 && len(b.GCPProject) == 0 {
+		return nil, fmt.Errorf("account_guard block must set aws_account_id or gcp_project")
+	}
+	if len(b.AWSAccountID) > 0 && !awsAccountIDRe.MatchString(b.AWSAccountID) {
+		return nil, fmt.Errorf("aws_account_id in account_guard block must be a 12-digit AWS account ID: %s", b.AWSAccountID)
+	}
+	if len(b.AWSProfile) > 0 && len(b.AWSAccountID) == 0 {
+		return nil, fmt.Errorf("aws_profile in account_guard block requires aws_account_id")
+	}
+	return &guard.Config{
+		AWSAccountID: b.AWSAccountID,
+		AWSProfile:   b.AWSProfile,
+		GCPProject:   b.GCPProject,
+	}, nil
+}
+
+// validateHookCommand returns an error if a given hook command is set but
+// its program is empty.
+func validateHookCommand(name string, command []string) error {
+	if len(command) > 0 && len(command[0]) == 0 {
+		return fmt.Errorf("%s in hook block must start with a program: %v", name, command)
+	}
+	return nil
+}
+
 // NewDefaultConfig returns a new instance of TfmigrateConfig.
 func NewDefaultConfig() *TfmigrateConfig {
 	return &TfmigrateConfig{
@@ -89,3 +497,19 @@ func NewDefaultConfig() *TfmigrateConfig {
 		IsBackendTerraformCloud: false,
 	}
 }
+
+// parseTimeout parses a timeout attribute such as `30m`.
+// It returns zero if a given value is empty, which means no timeout.
+func parseTimeout(name string, v string) (time.Duration, error) {
+	if len(v) == 0 {
+		return 0, nil
+	}
+	d, err := time.ParseDuration(v)
+	if err != nil {
+		return 0, fmt.Errorf("failed to parse %s: %s", name, err)
+	}
+	if d < 0 {
+		return 0, fmt.Errorf("%s must not be negative: %s", name, v)
+	}
+	return d, nil
+}
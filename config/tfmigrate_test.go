@@ -3,9 +3,13 @@ package config
 import (
 	"reflect"
 	"testing"
+	"time"
 
+	"github.com/minamijoyo/tfmigrate/guard"
 	"github.com/minamijoyo/tfmigrate/history"
 	"github.com/minamijoyo/tfmigrate/storage/local"
+	"github.com/minamijoyo/tfmigrate/tfexec"
+	"github.com/zclconf/go-cty/cty"
 )
 
 func TestParseConfigurationFile(t *testing.T) {
@@ -70,6 +74,481 @@ tfmigrate {
 			},
 			ok: true,
 		},
+		{
+			desc: "with terraform_version_paths",
+			source: `
+tfmigrate {
+  terraform_version_paths = {
+    "1.5.7" = "/opt/terraform/1.5.7/terraform"
+    "1.9.0" = "/opt/terraform/1.9.0/terraform"
+  }
+}
+`,
+			want: &TfmigrateConfig{
+				MigrationDir: ".",
+				TerraformVersionPaths: map[string]string{
+					"1.5.7": "/opt/terraform/1.5.7/terraform",
+					"1.9.0": "/opt/terraform/1.9.0/terraform",
+				},
+			},
+			ok: true,
+		},
+		{
+			desc: "with redact_patterns",
+			source: `
+tfmigrate {
+  redact_patterns = ["(token=)\\S+"]
+}
+`,
+			want: &TfmigrateConfig{
+				MigrationDir:   ".",
+				RedactPatterns: []string{`(token=)\S+`},
+			},
+			ok: true,
+		},
+		{
+			desc: "invalid redact_patterns",
+			source: `
+tfmigrate {
+  redact_patterns = ["("]
+}
+`,
+			want: nil,
+			ok:   false,
+		},
+		{
+			desc: "with log_level",
+			source: `
+tfmigrate {
+  log_level = "debug"
+}
+`,
+			want: &TfmigrateConfig{
+				MigrationDir: ".",
+				LogLevel:     "DEBUG",
+			},
+			ok: true,
+		},
+		{
+			desc: "with default_dir",
+			source: `
+tfmigrate {
+  default_dir = "env/prod"
+}
+`,
+			want: &TfmigrateConfig{
+				MigrationDir: ".",
+				DefaultDir:   "env/prod",
+			},
+			ok: true,
+		},
+		{
+			desc: "with migration archive",
+			source: `
+tfmigrate {
+  migration_dir            = "migrations.tar.gz"
+  migration_archive_sha256 = "E3B0C44298FC1C149AFBF4C8996FB92427AE41E4649B934CA495991B7852B855"
+}
+`,
+			want: &TfmigrateConfig{
+				MigrationDir:           "migrations.tar.gz",
+				MigrationArchiveSHA256: "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855",
+			},
+			ok: true,
+		},
+		{
+			desc: "migration_archive_sha256 without archive",
+			source: `
+tfmigrate {
+  migration_dir            = "tfmigrate"
+  migration_archive_sha256 = "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855"
+}
+`,
+			want: nil,
+			ok:   false,
+		},
+		{
+			desc: "invalid migration_archive_sha256",
+			source: `
+tfmigrate {
+  migration_dir            = "migrations.tgz"
+  migration_archive_sha256 = "foo"
+}
+`,
+			want: nil,
+			ok:   false,
+		},
+		{
+			desc: "with tmp_dir",
+			source: `
+tfmigrate {
+  tmp_dir = "/var/tmp/tfmigrate"
+}
+`,
+			want: &TfmigrateConfig{
+				MigrationDir: ".",
+				TmpDir:       "/var/tmp/tfmigrate",
+			},
+			ok: true,
+		},
+		{
+			desc: "with plugin_cache_dir",
+			source: `
+tfmigrate {
+  plugin_cache_dir = "/var/tmp/terraform-plugin-cache"
+}
+`,
+			want: &TfmigrateConfig{
+				MigrationDir:   ".",
+				PluginCacheDir: "/var/tmp/terraform-plugin-cache",
+			},
+			ok: true,
+		},
+		{
+			desc: "with validate",
+			source: `
+tfmigrate {
+  validate = true
+}
+`,
+			want: &TfmigrateConfig{
+				MigrationDir: ".",
+				Validate:     true,
+			},
+			ok: true,
+		},
+		{
+			desc: "with warnings_as_errors",
+			source: `
+tfmigrate {
+  warnings_as_errors = true
+  allowed_warnings   = ["Argument is deprecated"]
+}
+`,
+			want: &TfmigrateConfig{
+				MigrationDir:     ".",
+				WarningsAsErrors: true,
+				AllowedWarnings:  []string{"Argument is deprecated"},
+			},
+			ok: true,
+		},
+		{
+			desc: "with state_mv_warnings_as_errors",
+			source: `
+tfmigrate {
+  state_mv_warnings_as_errors = true
+}
+`,
+			want: &TfmigrateConfig{
+				MigrationDir:            ".",
+				StateMvWarningsAsErrors: true,
+			},
+			ok: true,
+		},
+		{
+			desc: "with stream_state",
+			source: `
+tfmigrate {
+  stream_state = true
+}
+`,
+			want: &TfmigrateConfig{
+				MigrationDir: ".",
+				StreamState:  true,
+			},
+			ok: true,
+		},
+		{
+			desc: "with init_args",
+			source: `
+tfmigrate {
+  init_args = ["-upgrade", "-reconfigure"]
+}
+`,
+			want: &TfmigrateConfig{
+				MigrationDir: ".",
+				InitArgs:     []string{"-upgrade", "-reconfigure"},
+			},
+			ok: true,
+		},
+		{
+			desc: "invalid init_args",
+			source: `
+tfmigrate {
+  init_args = ["upgrade"]
+}
+`,
+			want: nil,
+			ok:   false,
+		},
+		{
+			desc: "with timeouts",
+			source: `
+tfmigrate {
+  init_timeout = "5m"
+  plan_timeout = "30m"
+  push_timeout = "90s"
+}
+`,
+			want: &TfmigrateConfig{
+				MigrationDir: ".",
+				InitTimeout:  5 * time.Minute,
+				PlanTimeout:  30 * time.Minute,
+				PushTimeout:  90 * time.Second,
+			},
+			ok: true,
+		},
+		{
+			desc: "invalid timeout",
+			source: `
+tfmigrate {
+  plan_timeout = "30"
+}
+`,
+			want: nil,
+			ok:   false,
+		},
+		{
+			desc: "negative timeout",
+			source: `
+tfmigrate {
+  init_timeout = "-5m"
+}
+`,
+			want: nil,
+			ok:   false,
+		},
+		{
+			desc: "invalid log_level",
+			source: `
+tfmigrate {
+  log_level = "verbose"
+}
+`,
+			want: nil,
+			ok:   false,
+		},
+		{
+			desc: "with terraform block",
+			source: `
+tfmigrate {
+  terraform {
+    default_exec_path = "terraform"
+    dir "legacy/*" {
+      exec_path = "/opt/terraform/0.12/terraform"
+    }
+    dir "opentofu/*" {
+      exec_path = "tofu"
+    }
+  }
+}
+`,
+			want: &TfmigrateConfig{
+				MigrationDir: ".",
+				ExecPathResolver: &tfexec.ExecPathResolver{
+					Rules: []tfexec.ExecPathRule{
+						{Pattern: "legacy/*", ExecPath: "/opt/terraform/0.12/terraform"},
+						{Pattern: "opentofu/*", ExecPath: "tofu"},
+					},
+					DefaultExecPath: "terraform",
+				},
+			},
+			ok: true,
+		},
+		{
+			desc: "invalid pattern in terraform block",
+			source: `
+tfmigrate {
+  terraform {
+    dir "legacy/[" {
+      exec_path = "/opt/terraform/0.12/terraform"
+    }
+  }
+}
+`,
+			want: nil,
+			ok:   false,
+		},
+		{
+			desc: "with hook block",
+			source: `
+tfmigrate {
+  hook {
+    pre_run  = ["ssh", "-fN", "-M", "-S", "/tmp/bastion.sock", "-L", "5432:db:5432", "bastion"]
+    post_run = ["ssh", "-S", "/tmp/bastion.sock", "-O", "exit", "bastion"]
+  }
+}
+`,
+			want: &TfmigrateConfig{
+				MigrationDir: ".",
+				PreRunHook:   []string{"ssh", "-fN", "-M", "-S", "/tmp/bastion.sock", "-L", "5432:db:5432", "bastion"},
+				PostRunHook:  []string{"ssh", "-S", "/tmp/bastion.sock", "-O", "exit", "bastion"},
+			},
+			ok: true,
+		},
+		{
+			desc: "with lock_retry block",
+			source: `
+tfmigrate {
+  lock_retry {
+    attempts     = 3
+    interval     = "10s"
+    max_interval = "1m"
+  }
+}
+`,
+			want: &TfmigrateConfig{
+				MigrationDir: ".",
+				LockRetry: tfexec.LockRetry{
+					Attempts:    3,
+					Interval:    10 * time.Second,
+					MaxInterval: time.Minute,
+				},
+			},
+			ok: true,
+		},
+		{
+			desc: "lock_retry block with attempts only",
+			source: `
+tfmigrate {
+  lock_retry {
+    attempts = 3
+  }
+}
+`,
+			want: &TfmigrateConfig{
+				MigrationDir: ".",
+				LockRetry: tfexec.LockRetry{
+					Attempts: 3,
+				},
+			},
+			ok: true,
+		},
+		{
+			desc: "negative attempts in lock_retry block",
+			source: `
+tfmigrate {
+  lock_retry {
+    attempts = -1
+  }
+}
+`,
+			want: nil,
+			ok:   false,
+		},
+		{
+			desc: "invalid interval in lock_retry block",
+			source: `
+tfmigrate {
+  lock_retry {
+    attempts = 3
+    interval = "foo"
+  }
+}
+`,
+			want: nil,
+			ok:   false,
+		},
+		{
+			desc: "with account_guard block",
+			source: `
+tfmigrate {
+  account_guard {
+    aws_account_id = "123456789012"
+    aws_profile    = "prod"
+    gcp_project    = "my-project"
+  }
+}
+`,
+			want: &TfmigrateConfig{
+				MigrationDir: ".",
+				AccountGuard: &guard.Config{
+					AWSAccountID: "123456789012",
+					AWSProfile:   "prod",
+					GCPProject:   "my-project",
+				},
+			},
+			ok: true,
+		},
+		{
+			desc: "empty account_guard block",
+			source: `
+tfmigrate {
+  account_guard {}
+}
+`,
+			want: nil,
+			ok:   false,
+		},
+		{
+			desc: "invalid aws_account_id in account_guard block",
+			source: `
+tfmigrate {
+  account_guard {
+    aws_account_id = "prod"
+  }
+}
+`,
+			want: nil,
+			ok:   false,
+		},
+		{
+			desc: "aws_profile without aws_account_id in account_guard block",
+			source: `
+tfmigrate {
+  account_guard {
+    aws_profile = "prod"
+    gcp_project = "my-project"
+  }
+}
+`,
+			want: nil,
+			ok:   false,
+		},
+		{
+			desc: "empty program in hook block",
+			source: `
+tfmigrate {
+  hook {
+    pre_run = ["", "foo"]
+  }
+}
+`,
+			want: nil,
+			ok:   false,
+		},
+		{
+			desc: "with locals block",
+			source: `
+tfmigrate {
+  locals {
+    bucket = "tfstate-prod"
+    prefix = "${env.TFMIGRATE_TEST_LOCALS_ACCOUNT}/"
+  }
+}
+`,
+			want: &TfmigrateConfig{
+				MigrationDir: ".",
+				Locals: map[string]cty.Value{
+					"bucket": cty.StringVal("tfstate-prod"),
+					"prefix": cty.StringVal("123456789012/"),
+				},
+			},
+			ok: true,
+		},
+		{
+			desc: "reference other locals in locals block",
+			source: `
+tfmigrate {
+  locals {
+    bucket = "tfstate-prod"
+    key    = "${local.bucket}/history.json"
+  }
+}
+`,
+			want: nil,
+			ok:   false,
+		},
 		{
 			desc: "unknown block",
 			source: `
@@ -89,6 +568,7 @@ foo {
 
 	for _, tc := range cases {
 		t.Run(tc.desc, func(t *testing.T) {
+			t.Setenv("TFMIGRATE_TEST_LOCALS_ACCOUNT", "123456789012")
 			got, err := ParseConfigurationFile("test.hcl", []byte(tc.source))
 			if tc.ok && err != nil {
 				t.Fatalf("unexpected err: %s", err)
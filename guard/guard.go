@@ -0,0 +1,122 @@
+package guard
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+)
+
+// Config is a config for the account guard, which verifies that the active
+// cloud credentials target the expected account before a new state is pushed.
+// It's intended to prevent running a migration against a wrong account.
+type Config struct {
+	// AWSAccountID is an expected AWS account ID of the active credentials.
+	// It's empty if not checked.
+	AWSAccountID string
+	// AWSProfile is a name of AWS profile used for looking up the account.
+	// If empty, the default credential chain is used as terraform does.
+	AWSProfile string
+	// GCPProject is an expected GCP project ID of the active credentials.
+	// It's empty if not checked.
+	GCPProject string
+}
+
+// IdentityResolver is an abstraction layer for looking up the active cloud
+// account of credentials.
+// It is intended to be replaced with a mock for testing.
+type IdentityResolver interface {
+	// AWSAccountID returns an AWS account ID of the active credentials.
+	AWSAccountID(ctx context.Context, profile string) (string, error)
+	// GCPProject returns a GCP project ID of the active credentials.
+	GCPProject(ctx context.Context) (string, error)
+}
+
+// Check verifies that the active credentials target the expected accounts.
+// Only the accounts set in the config are checked. It returns an error
+// showing the expected and actual accounts on any mismatch.
+func (c *Config) Check(ctx context.Context, r IdentityResolver) error {
+	mismatches := []string{}
+
+	if len(c.AWSAccountID) > 0 {
+		actual, err := r.AWSAccountID(ctx, c.AWSProfile)
+		if err != nil {
+			return fmt.Errorf("account guard: failed to look up the active AWS account: %s", err)
+		}
+		log.Printf("[INFO] [guard] the active AWS account: %s\n", actual)
+		if actual != c.AWSAccountID {
+			mismatches = append(mismatches, fmt.Sprintf("AWS account expected: %s, actual: %s", c.AWSAccountID, actual))
+		}
+	}
+
+	if len(c.GCPProject) > 0 {
+		actual, err := r.GCPProject(ctx)
+		if err != nil {
+			return fmt.Errorf("account guard: failed to look up the active GCP project: %s", err)
+		}
+		log.Printf("[INFO] [guard] the active GCP project: %s\n", actual)
+		if actual != c.GCPProject {
+			mismatches = append(mismatches, fmt.Sprintf("GCP project expected: %s, actual: %s", c.GCPProject, actual))
+		}
+	}
+
+	if len(mismatches) > 0 {
+		return fmt.Errorf("account guard: the active credentials target a wrong account, abort to prevent migrating it: %s", strings.Join(mismatches, ", "))
+	}
+	return nil
+}
+
+// awsCredentialEnvs is a list of environment variables which change the AWS
+// account of credentials used by terraform.
+var awsCredentialEnvs = []string{
+	"AWS_PROFILE",
+	"AWS_DEFAULT_PROFILE",
+	"AWS_ACCESS_KEY_ID",
+	"AWS_SECRET_ACCESS_KEY",
+	"AWS_SESSION_TOKEN",
+	"AWS_ROLE_ARN",
+	"AWS_ROLE_SESSION_NAME",
+	"AWS_WEB_IDENTITY_TOKEN_FILE",
+	"AWS_CONFIG_FILE",
+	"AWS_SHARED_CREDENTIALS_FILE",
+}
+
+// gcpCredentialEnvs is a list of environment variables which change the GCP
+// credentials used by terraform, in addition to the ones for a project.
+var gcpCredentialEnvs = []string{
+	"GOOGLE_CREDENTIALS",
+	"GOOGLE_CLOUD_KEYFILE_JSON",
+	"GCLOUD_KEYFILE_JSON",
+	"GOOGLE_APPLICATION_CREDENTIALS",
+	"GOOGLE_OAUTH_ACCESS_TOKEN",
+	"GOOGLE_IMPERSONATE_SERVICE_ACCOUNT",
+}
+
+// CheckEnv returns an error if a given map of environment variables passed
+// to terraform changes the credentials checked by the guard.
+// The guard looks up the account of the credentials of the current process,
+// so it cannot verify the account which terraform actually uses if they are
+// overridden, such as by the env attribute of a migration.
+func (c *Config) CheckEnv(env map[string]string) error {
+	names := []string{}
+	if len(c.AWSAccountID) > 0 {
+		names = append(names, awsCredentialEnvs...)
+	}
+	if len(c.GCPProject) > 0 {
+		names = append(names, gcpCredentialEnvs...)
+		names = append(names, gcpProjectEnvs...)
+	}
+
+	found := []string{}
+	for _, name := range names {
+		if _, ok := env[name]; ok {
+			found = append(found, name)
+		}
+	}
+	if len(found) > 0 {
+		sort.Strings(found)
+		return fmt.Errorf("account guard: the credentials cannot be verified because they are overridden by environment variables for terraform: %s", strings.Join(found, ", "))
+	}
+	return nil
+}
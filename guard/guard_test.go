@@ -0,0 +1,167 @@
+package guard
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+)
+
+// mockIdentityResolver is a mock implementation of the IdentityResolver.
+type mockIdentityResolver struct {
+	awsAccountID string
+	gcpProject   string
+	err          error
+	// profile is a profile given to AWSAccountID.
+	profile string
+}
+
+func (r *mockIdentityResolver) AWSAccountID(_ context.Context, profile string) (string, error) {
+	r.profile = profile
+	return r.awsAccountID, r.err
+}
+
+func (r *mockIdentityResolver) GCPProject(_ context.Context) (string, error) {
+	return r.gcpProject, r.err
+}
+
+func TestConfigCheck(t *testing.T) {
+	cases := []struct {
+		desc     string
+		config   *Config
+		resolver *mockIdentityResolver
+		ok       bool
+		wantErr  string
+	}{
+		{
+			desc:     "aws match",
+			config:   &Config{AWSAccountID: "123456789012"},
+			resolver: &mockIdentityResolver{awsAccountID: "123456789012"},
+			ok:       true,
+		},
+		{
+			desc:     "aws mismatch",
+			config:   &Config{AWSAccountID: "123456789012"},
+			resolver: &mockIdentityResolver{awsAccountID: "210987654321"},
+			ok:       false,
+			wantErr:  "AWS account expected: 123456789012, actual: 210987654321",
+		},
+		{
+			desc:     "gcp match",
+			config:   &Config{GCPProject: "prod"},
+			resolver: &mockIdentityResolver{gcpProject: "prod"},
+			ok:       true,
+		},
+		{
+			desc:     "both mismatch",
+			config:   &Config{AWSAccountID: "123456789012", GCPProject: "prod"},
+			resolver: &mockIdentityResolver{awsAccountID: "210987654321", gcpProject: "dev"},
+			ok:       false,
+			wantErr:  "AWS account expected: 123456789012, actual: 210987654321, GCP project expected: prod, actual: dev",
+		},
+		{
+			desc:     "gcp not checked",
+			config:   &Config{AWSAccountID: "123456789012"},
+			resolver: &mockIdentityResolver{awsAccountID: "123456789012", gcpProject: "dev"},
+			ok:       true,
+		},
+		{
+			desc:     "lookup error",
+			config:   &Config{GCPProject: "prod"},
+			resolver: &mockIdentityResolver{err: errors.New("no credentials")},
+			ok:       false,
+			wantErr:  "failed to look up the active GCP project: no credentials",
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.desc, func(t *testing.T) {
+			err := tc.config.Check(context.Background(), tc.resolver)
+			if tc.ok && err != nil {
+				t.Fatalf("unexpected err: %s", err)
+			}
+			if !tc.ok && err == nil {
+				t.Fatal("expected to return an error, but no error")
+			}
+			if !tc.ok && !strings.Contains(err.Error(), tc.wantErr) {
+				t.Errorf("expected an error to contain %q, but got: %s", tc.wantErr, err)
+			}
+		})
+	}
+}
+
+func TestConfigCheckWithAWSProfile(t *testing.T) {
+	config := &Config{AWSAccountID: "123456789012", AWSProfile: "prod"}
+	resolver := &mockIdentityResolver{awsAccountID: "123456789012"}
+	if err := config.Check(context.Background(), resolver); err != nil {
+		t.Fatalf("unexpected err: %s", err)
+	}
+	if resolver.profile != "prod" {
+		t.Errorf("got profile: %s, want: prod", resolver.profile)
+	}
+}
+
+func TestConfigCheckEnv(t *testing.T) {
+	cases := []struct {
+		desc    string
+		config  *Config
+		env     map[string]string
+		ok      bool
+		wantErr string
+	}{
+		{
+			desc:   "no env",
+			config: &Config{AWSAccountID: "123456789012"},
+			env:    nil,
+			ok:     true,
+		},
+		{
+			desc:   "unrelated env",
+			config: &Config{AWSAccountID: "123456789012"},
+			env:    map[string]string{"TF_LOG": "DEBUG"},
+			ok:     true,
+		},
+		{
+			desc:    "aws profile",
+			config:  &Config{AWSAccountID: "123456789012"},
+			env:     map[string]string{"AWS_PROFILE": "dev", "AWS_ROLE_ARN": "arn:aws:iam::210987654321:role/foo"},
+			ok:      false,
+			wantErr: "AWS_PROFILE, AWS_ROLE_ARN",
+		},
+		{
+			desc:   "aws env not checked",
+			config: &Config{GCPProject: "prod"},
+			env:    map[string]string{"AWS_PROFILE": "dev"},
+			ok:     true,
+		},
+		{
+			desc:    "gcp project",
+			config:  &Config{GCPProject: "prod"},
+			env:     map[string]string{"GOOGLE_PROJECT": "dev"},
+			ok:      false,
+			wantErr: "GOOGLE_PROJECT",
+		},
+		{
+			desc:    "gcp credentials",
+			config:  &Config{GCPProject: "prod"},
+			env:     map[string]string{"GOOGLE_APPLICATION_CREDENTIALS": "/path/to/key.json"},
+			ok:      false,
+			wantErr: "GOOGLE_APPLICATION_CREDENTIALS",
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.desc, func(t *testing.T) {
+			err := tc.config.CheckEnv(tc.env)
+			if tc.ok && err != nil {
+				t.Fatalf("unexpected err: %s", err)
+			}
+			if !tc.ok && err == nil {
+				t.Fatal("expected to return an error, but no error")
+			}
+			if !tc.ok && !strings.Contains(err.Error(), tc.wantErr) {
+				t.Errorf("expected an error to contain %q, but got: %s", tc.wantErr, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,69 @@
+package guard
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+
+	awsbase "github.com/hashicorp/aws-sdk-go-base/v2"
+	"golang.org/x/oauth2/google"
+)
+
+// gcpProjectEnvs is a list of environment variables for a GCP project in
+// order of precedence, which is the same as the terraform google provider.
+var gcpProjectEnvs = []string{
+	"GOOGLE_PROJECT",
+	"GOOGLE_CLOUD_PROJECT",
+	"GCLOUD_PROJECT",
+	"CLOUDSDK_CORE_PROJECT",
+}
+
+// identityResolver is a real implementation of the IdentityResolver.
+type identityResolver struct{}
+
+var _ IdentityResolver = (*identityResolver)(nil)
+
+// NewIdentityResolver returns a new instance of IdentityResolver.
+func NewIdentityResolver() IdentityResolver {
+	return &identityResolver{}
+}
+
+// AWSAccountID returns an AWS account ID of the active credentials by calling
+// STS GetCallerIdentity.
+func (r *identityResolver) AWSAccountID(ctx context.Context, profile string) (string, error) {
+	cfg := &awsbase.Config{
+		Profile: profile,
+	}
+
+	ctx, awsConfig, awsDiags := awsbase.GetAwsConfig(ctx, cfg)
+	if awsDiags.HasError() {
+		return "", fmt.Errorf("failed to load aws config: %#v", awsDiags)
+	}
+
+	accountID, _, awsDiags := awsbase.GetAwsAccountIDAndPartition(ctx, awsConfig, cfg)
+	if awsDiags.HasError() {
+		return "", fmt.Errorf("failed to get caller identity: %#v", awsDiags)
+	}
+	return accountID, nil
+}
+
+// GCPProject returns a GCP project ID of the active credentials.
+// The environment variables for a project take precedence over the project
+// of the application default credentials as the terraform google provider.
+func (r *identityResolver) GCPProject(ctx context.Context) (string, error) {
+	for _, env := range gcpProjectEnvs {
+		if v := os.Getenv(env); len(v) > 0 {
+			return v, nil
+		}
+	}
+
+	creds, err := google.FindDefaultCredentials(ctx)
+	if err != nil {
+		return "", err
+	}
+	if len(creds.ProjectID) == 0 {
+		return "", errors.New("no project found in the environment variables nor the application default credentials")
+	}
+	return creds.ProjectID, nil
+}
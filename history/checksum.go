@@ -0,0 +1,88 @@
+package history
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"hash"
+	"strings"
+)
+
+const (
+	// checksumSHA256 is a prefix of a checksum in SHA-256.
+	checksumSHA256 = "sha256"
+	// checksumHMACSHA256 is a prefix of a checksum in HMAC-SHA256.
+	checksumHMACSHA256 = "hmac-sha256"
+)
+
+// computeChecksum returns a checksum of a given history file in the form of
+// `<algorithm>:<hex>`. If a key is not empty, it's HMAC-SHA256 with the key.
+// Otherwise, it's SHA-256. The checksum attribute itself is excluded, and the
+// rest is encoded in compact JSON, whose keys of records are sorted, so that
+// the checksum doesn't depend on the indentation of the stored file.
+func computeChecksum(f FileV1, key []byte) (string, error) {
+	f.Checksum = ""
+	b, err := json.Marshal(f)
+	if err != nil {
+		return "", err
+	}
+
+	algorithm := checksumSHA256
+	var h hash.Hash
+	if len(key) > 0 {
+		algorithm = checksumHMACSHA256
+		h = hmac.New(sha256.New, key)
+	} else {
+		h = sha256.New()
+	}
+	// Write to a hash never returns an error.
+	_, _ = h.Write(b)
+	return algorithm + ":" + hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// verifyChecksum returns an error if a given history file doesn't have a
+// checksum or it doesn't match the contents.
+func verifyChecksum(f FileV1, key []byte) error {
+	if len(f.Checksum) == 0 {
+		return fmt.Errorf("the history has no checksum")
+	}
+
+	algorithm, _, _ := strings.Cut(f.Checksum, ":")
+	if algorithm == checksumHMACSHA256 && len(key) == 0 {
+		return fmt.Errorf("the history has an HMAC checksum, but no key is given")
+	}
+	if algorithm == checksumSHA256 && len(key) > 0 {
+		// A key is given, but the history has a plain checksum, which anyone can
+		// recompute after editing it.
+		return fmt.Errorf("the history has a checksum without HMAC, but a key is given")
+	}
+
+	want, err := computeChecksum(f, key)
+	if err != nil {
+		return err
+	}
+	if !hmac.Equal([]byte(f.Checksum), []byte(want)) {
+		return fmt.Errorf("checksum mismatch of the history, it may be corrupted or tampered")
+	}
+	return nil
+}
+
+// verifyHistoryFileChecksum parses a given history file and verifies its
+// checksum.
+func verifyHistoryFileChecksum(b []byte, key []byte) error {
+	version, err := detectHistoryFileVersion(b)
+	if err != nil {
+		return err
+	}
+	if version != 1 {
+		return fmt.Errorf("unknown history file version: %d", version)
+	}
+
+	var f FileV1
+	if err := json.Unmarshal(b, &f); err != nil {
+		return err
+	}
+	return verifyChecksum(f, key)
+}
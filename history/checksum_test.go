@@ -0,0 +1,117 @@
+package history
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/minamijoyo/tfmigrate/storage/mock"
+)
+
+func TestChecksumSaveAndLoad(t *testing.T) {
+	cases := []struct {
+		desc    string
+		saveKey []byte
+		loadKey []byte
+		tamper  func(data string) string
+		ignore  bool
+		ok      bool
+	}{
+		{
+			desc: "sha256",
+			ok:   true,
+		},
+		{
+			desc:    "hmac-sha256",
+			saveKey: []byte("secret"),
+			loadKey: []byte("secret"),
+			ok:      true,
+		},
+		{
+			desc:    "hmac-sha256 with a wrong key",
+			saveKey: []byte("secret"),
+			loadKey: []byte("wrong"),
+			ok:      false,
+		},
+		{
+			desc:    "hmac-sha256 without a key",
+			saveKey: []byte("secret"),
+			ok:      false,
+		},
+		{
+			desc:    "sha256 with a key",
+			loadKey: []byte("secret"),
+			ok:      false,
+		},
+		{
+			desc: "tampered",
+			tamper: func(data string) string {
+				return strings.Replace(data, `"foo"`, `"bar"`, 1)
+			},
+			ok: false,
+		},
+		{
+			desc: "no checksum",
+			tamper: func(data string) string {
+				return `{"version": 1, "records": {}}`
+			},
+			ok: false,
+		},
+		{
+			desc: "ignore checksum error",
+			tamper: func(data string) string {
+				return `{"version": 1, "records": {}}`
+			},
+			ignore: true,
+			ok:     true,
+		},
+		{
+			desc: "indentation doesn't matter",
+			tamper: func(data string) string {
+				return strings.ReplaceAll(data, "    ", "  ")
+			},
+			ok: true,
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.desc, func(t *testing.T) {
+			h := newEmptyHistory()
+			h.Add("20201012010101_foo.hcl", Record{
+				Type:      "state",
+				Name:      "foo",
+				AppliedAt: time.Date(2020, 10, 13, 1, 2, 3, 0, time.UTC),
+			})
+			s := &mock.Config{}
+			c := &Controller{
+				history: *h,
+				config: Config{
+					Storage:     s,
+					Checksum:    true,
+					ChecksumKey: tc.saveKey,
+				},
+			}
+			if err := c.Save(context.Background()); err != nil {
+				t.Fatalf("failed to save history: %s", err)
+			}
+
+			data := s.Storage().Data()
+			if tc.tamper != nil {
+				data = tc.tamper(data)
+			}
+			config := &Config{
+				Checksum:            true,
+				ChecksumKey:         tc.loadKey,
+				IgnoreChecksumError: tc.ignore,
+			}
+			_, err := loadHistory(context.Background(), &mock.Config{Data: data}, config)
+			if tc.ok && err != nil {
+				t.Fatalf("unexpected err: %s", err)
+			}
+			if !tc.ok && err == nil {
+				t.Fatal("expected to return an error, but no error")
+			}
+		})
+	}
+}
@@ -10,4 +10,48 @@ type Config struct {
 	MigrationDir string
 	// Storage is an interface of factory method for Storage
 	Storage storage.Config
+	// BaseStorages is a list of interfaces of factory method for read-only
+	// Storage. Migrations recorded in any of them are treated as applied, but
+	// new records are written only to the Storage. This allows us to
+	// consolidate multiple histories incrementally.
+	BaseStorages []storage.Config
+	// Dependencies is a map of migration file name to a list of migration file
+	// names which must be applied before it. Migrations are sorted by the file
+	// name by default, and this allows us to override the order.
+	Dependencies map[string][]string
+	// Order is a strategy to order migration files.
+	// Valid values are `lexical`, `numeric-prefix` and `mtime`.
+	// Default to `lexical`.
+	Order string
+	// StrictNaming requires all migration file names to have a numeric prefix
+	// followed by an underscore such as `20201012010101_foo.hcl`.
+	StrictNaming bool
+	// StrictMissingFiles fails a history-aware runner if any migration file
+	// recorded as applied in history doesn't exist in the migration dir.
+	// Otherwise, it's logged as a warning.
+	StrictMissingFiles bool
+	// Checksum enables writing a checksum into the history file on save and
+	// verifying it on load to detect corruption or tampering.
+	// Base storages are not verified.
+	Checksum bool
+	// ChecksumKey is a key for HMAC-SHA256 of the checksum.
+	// If empty, the checksum is SHA-256, which only detects corruption.
+	ChecksumKey []byte
+	// IgnoreChecksumError logs a checksum error as a warning instead of
+	// failing. It's intended for reading a history which is about to be
+	// overwritten or just dumped.
+	IgnoreChecksumError bool
+	// ReadOnly refuses any write to the storage.
+	ReadOnly bool
 }
+
+const (
+	// OrderLexical orders migration files by the file name.
+	OrderLexical = "lexical"
+	// OrderNumericPrefix orders migration files by the numeric value of the
+	// prefix of the file name, and then by the file name.
+	OrderNumericPrefix = "numeric-prefix"
+	// OrderMtime orders migration files by the modification time, and then by
+	// the file name.
+	OrderMtime = "mtime"
+)
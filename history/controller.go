@@ -2,9 +2,11 @@ package history
 
 import (
 	"context"
+	"fmt"
 	"log"
 	"os"
 	"path/filepath"
+	"regexp"
 	"sort"
 	"strings"
 	"time"
@@ -20,12 +22,23 @@ type Controller struct {
 	// We simply use the file name for identification to avoid parsing all files.
 	// If a migration file format changes, it doesn't make sense that parsing
 	// errors occur in old format files which have been already applied.
-	// The list is sorted alphabetically.
+	// The list is ordered by the configured strategy, which defaults to
+	// alphabetical, and then topologically sorted by dependencies if any.
+	// Only files directly under the migration dir are listed, so that the
+	// file name is unique and used as a key of history.
 	migrations []string
 	// history is a list of applied migration logs which is persisted to a storage.
 	history History
+	// base is a union of applied migration logs loaded from read-only base
+	// storages. It's consulted to check whether a migration has been applied,
+	// but never persisted.
+	base History
 	// config customizes behavior of history management.
 	config Config
+	// storage is a storage which the history was read from.
+	// It's reused to write the history, so that the storage can detect a
+	// concurrent modification since it was read.
+	storage storage.Storage
 }
 
 // NewController returns a new Controller instance.
@@ -36,8 +49,33 @@ func NewController(ctx context.Context, migrationDir string, config *Config) (*C
 		return nil, err
 	}
 
+	if config.StrictNaming {
+		if err := validateMigrationFileNames(migrations); err != nil {
+			return nil, err
+		}
+	}
+
+	migrations, err = orderMigrations(migrationDir, migrations, config.Order)
+	if err != nil {
+		return nil, err
+	}
+
+	migrations, err = sortMigrations(migrations, config.Dependencies)
+	if err != nil {
+		return nil, err
+	}
+
 	log.Print("[DEBUG] [history] load history\n")
-	h, err := loadHistory(ctx, config.Storage)
+	s, err := config.Storage.NewStorage()
+	if err != nil {
+		return nil, err
+	}
+	h, err := readHistory(ctx, s, config)
+	if err != nil {
+		return nil, err
+	}
+
+	base, err := loadBaseHistory(ctx, config.BaseStorages)
 	if err != nil {
 		return nil, err
 	}
@@ -46,12 +84,20 @@ func NewController(ctx context.Context, migrationDir string, config *Config) (*C
 		migrationDir: migrationDir,
 		migrations:   migrations,
 		history:      *h,
+		base:         *base,
 		config:       *config,
+		storage:      s,
 	}
 
 	return c, nil
 }
 
+// MigrationFileNames lists migration files in a given migration dir
+// regardless of history. The returned slice is sorted alphabetically.
+func MigrationFileNames(dir string) ([]string, error) {
+	return loadMigrationFileNames(dir)
+}
+
 // loadMigrationDir loads a migration directory and lists migration files from local.
 // The returned slice is sorted alphabetically.
 func loadMigrationFileNames(dir string) ([]string, error) {
@@ -63,9 +109,9 @@ func loadMigrationFileNames(dir string) ([]string, error) {
 	}
 
 	for _, f := range files {
-		// skip a file without .hcl or .json extension.
+		// skip a file without .hcl, .json, .yaml or .yml extension.
 		ext := filepath.Ext(f.Name())
-		if !(ext == ".hcl" || ext == ".json") {
+		if !(ext == ".hcl" || ext == ".json" || ext == ".yaml" || ext == ".yml") {
 			continue
 		}
 		// skip a hidden file such as .tfmigrate.hcl or .terraform.lock.hcl.
@@ -81,14 +127,170 @@ func loadMigrationFileNames(dir string) ([]string, error) {
 	return migrations, nil
 }
 
+// numericPrefixRe is a pattern of a migration file name with a numeric prefix.
+var numericPrefixRe = regexp.MustCompile(`^([0-9]+)_`)
+
+// validateMigrationFileNames returns an error if any of given migration file
+// names doesn't have a numeric prefix followed by an underscore.
+func validateMigrationFileNames(migrations []string) error {
+	invalid := []string{}
+	for _, m := range migrations {
+		if !numericPrefixRe.MatchString(m) {
+			invalid = append(invalid, m)
+		}
+	}
+	if len(invalid) != 0 {
+		return fmt.Errorf("migration file names must have a numeric prefix followed by an underscore such as 20201012010101_foo.hcl in strict naming mode: %v", invalid)
+	}
+	return nil
+}
+
+// orderMigrations orders a list of migration file names sorted alphabetically
+// by a given strategy. Ties are broken by the file name so that the
+// result is deterministic.
+func orderMigrations(dir string, migrations []string, order string) ([]string, error) {
+	ordered := append([]string{}, migrations...)
+
+	switch order {
+	case "", OrderLexical:
+		// already sorted by the file name.
+
+	case OrderNumericPrefix:
+		// Files without a numeric prefix are placed after numbered ones.
+		sort.SliceStable(ordered, func(i, j int) bool {
+			return compareNumericPrefix(ordered[i], ordered[j]) < 0
+		})
+
+	case OrderMtime:
+		mtimes := make(map[string]time.Time, len(ordered))
+		for _, m := range ordered {
+			info, err := os.Stat(filepath.Join(dir, m))
+			if err != nil {
+				return nil, err
+			}
+			mtimes[m] = info.ModTime()
+		}
+		sort.SliceStable(ordered, func(i, j int) bool {
+			return mtimes[ordered[i]].Before(mtimes[ordered[j]])
+		})
+
+	default:
+		return nil, fmt.Errorf("unknown migration order: %s, valid values are %s, %s and %s", order, OrderLexical, OrderNumericPrefix, OrderMtime)
+	}
+
+	return ordered, nil
+}
+
+// compareNumericPrefix compares numeric prefixes of given migration file
+// names. It returns a negative value if a is less than b, a positive value if
+// a is greater than b, and zero if they are equal or neither has a prefix.
+// A file name without a numeric prefix is greater than one with it.
+// The prefix is compared as an arbitrary-precision number, so that it works
+// with a long timestamp such as 20201012010101.
+func compareNumericPrefix(a string, b string) int {
+	am := numericPrefixRe.FindStringSubmatch(a)
+	bm := numericPrefixRe.FindStringSubmatch(b)
+	switch {
+	case am == nil && bm == nil:
+		return 0
+	case am == nil:
+		return 1
+	case bm == nil:
+		return -1
+	}
+
+	an := strings.TrimLeft(am[1], "0")
+	bn := strings.TrimLeft(bm[1], "0")
+	if len(an) != len(bn) {
+		return len(an) - len(bn)
+	}
+	return strings.Compare(an, bn)
+}
+
+// sortMigrations sorts a list of migration file names topologically by given
+// dependencies. A migration is placed after all migrations it depends on.
+// Among migrations whose dependencies are satisfied, the original order is
+// kept, so the result is the same as the input if there are no dependencies.
+// A dependency on a migration not in the list, such as one which has been
+// applied and removed or archived, is treated as satisfied with a warning, and
+// so are dependencies of an unknown migration. It returns an error if
+// dependencies contain a cycle.
+func sortMigrations(migrations []string, dependencies map[string][]string) ([]string, error) {
+	if len(dependencies) == 0 {
+		return migrations, nil
+	}
+
+	index := make(map[string]int, len(migrations))
+	for i, m := range migrations {
+		index[m] = i
+	}
+
+	// count unsatisfied dependencies for each migration.
+	inDegree := make([]int, len(migrations))
+	dependents := make([][]int, len(migrations))
+	for m, deps := range dependencies {
+		i, ok := index[m]
+		if !ok {
+			log.Printf("[WARN] [history] ignore dependencies of an unknown migration: %s\n", m)
+			continue
+		}
+		for _, d := range deps {
+			j, ok := index[d]
+			if !ok {
+				log.Printf("[WARN] [history] a dependency of %s is not found, which is assumed to be satisfied: %s\n", m, d)
+				continue
+			}
+			inDegree[i]++
+			dependents[j] = append(dependents[j], i)
+		}
+	}
+
+	sorted := make([]string, 0, len(migrations))
+	done := make([]bool, len(migrations))
+	for len(sorted) < len(migrations) {
+		// pick the first ready migration in the original order.
+		next := -1
+		for i := range migrations {
+			if !done[i] && inDegree[i] == 0 {
+				next = i
+				break
+			}
+		}
+		if next == -1 {
+			cycle := []string{}
+			for i, m := range migrations {
+				if !done[i] {
+					cycle = append(cycle, m)
+				}
+			}
+			return nil, fmt.Errorf("dependencies contain a cycle: %v", cycle)
+		}
+
+		done[next] = true
+		sorted = append(sorted, migrations[next])
+		for _, i := range dependents[next] {
+			inDegree[i]--
+		}
+	}
+
+	return sorted, nil
+}
+
 // loadHistory loads a history file from a storage.
 // If a given history is not found, create a new one.
-func loadHistory(ctx context.Context, c storage.Config) (*History, error) {
+// If the checksum is enabled in a given config, it's verified. The config is
+// nil for base storages, which are not verified.
+func loadHistory(ctx context.Context, c storage.Config, config *Config) (*History, error) {
 	s, err := c.NewStorage()
 	if err != nil {
 		return nil, err
 	}
+	return readHistory(ctx, s, config)
+}
 
+// readHistory reads a history file from a given storage instance.
+// It's the same as loadHistory, but the storage is given by the caller.
+func readHistory(ctx context.Context, s storage.Storage, config *Config) (*History, error) {
 	log.Printf("[DEBUG] [history] read storage %#v\n", s)
 	b, err := s.Read(ctx)
 	if err != nil {
@@ -103,6 +305,15 @@ func loadHistory(ctx context.Context, c storage.Config) (*History, error) {
 		return newEmptyHistory(), nil
 	}
 
+	if config != nil && config.Checksum {
+		if err := verifyHistoryFileChecksum(b, config.ChecksumKey); err != nil {
+			if !config.IgnoreChecksumError {
+				return nil, fmt.Errorf("failed to verify history: %s", err)
+			}
+			log.Printf("[WARN] [history] failed to verify history, ignoring: %s\n", err)
+		}
+	}
+
 	h, err := ParseHistoryFile(b)
 	if err != nil {
 		return nil, err
@@ -111,14 +322,47 @@ func loadHistory(ctx context.Context, c storage.Config) (*History, error) {
 	return h, nil
 }
 
+// loadBaseHistory loads history files from read-only base storages and
+// returns a union of their records.
+// If the same migration is recorded in more than one of them, the record in
+// the former one takes precedence.
+func loadBaseHistory(ctx context.Context, configs []storage.Config) (*History, error) {
+	base := newEmptyHistory()
+	for i, c := range configs {
+		log.Printf("[DEBUG] [history] load base history[%d]\n", i)
+		h, err := loadHistory(ctx, c, nil)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load base history[%d]: %s", i, err)
+		}
+		if h.Length() == 0 {
+			// A base history is read-only, so an empty one is probably a typo.
+			log.Printf("[WARN] [history] base history[%d] is empty or not found\n", i)
+		}
+		base.merge(h)
+	}
+
+	return base, nil
+}
+
 // Save persists a current state of historyFile to storage.
 func (c *Controller) Save(ctx context.Context) error {
-	s, err := c.config.Storage.NewStorage()
-	if err != nil {
-		return err
+	var err error
+	s := c.storage
+	if s == nil {
+		if s, err = c.config.Storage.NewStorage(); err != nil {
+			return err
+		}
+	}
+	if c.config.ReadOnly {
+		s = storage.NewReadOnlyStorage(s)
 	}
 
 	f := newFileV1(c.history)
+	if c.config.Checksum {
+		if f.Checksum, err = computeChecksum(*f, c.config.ChecksumKey); err != nil {
+			return err
+		}
+	}
 	b, err := f.Serialize()
 	if err != nil {
 		return err
@@ -135,11 +379,11 @@ func (c *Controller) Migrations() []string {
 }
 
 // UnappliedMigrations returns a list of migration file names which have not
-// been applied yet.
+// been applied yet. Planned but not applied migrations are also included.
 func (c *Controller) UnappliedMigrations() []string {
 	unapplied := []string{}
 	for _, m := range c.migrations {
-		if !c.history.Contains(m) {
+		if !c.applied(m) {
 			unapplied = append(unapplied, m)
 		}
 	}
@@ -147,20 +391,93 @@ func (c *Controller) UnappliedMigrations() []string {
 	return unapplied
 }
 
+// PlannedMigrations returns a list of migration file names which have been
+// marked as planned but not applied yet.
+func (c *Controller) PlannedMigrations() []string {
+	planned := []string{}
+	for _, m := range c.migrations {
+		if r, ok := c.history.Get(m); ok && !r.Applied() && !c.applied(m) {
+			planned = append(planned, m)
+		}
+	}
+
+	return planned
+}
+
+// MissingMigrations returns a list of migration file names which are recorded
+// as applied in history but don't exist in the migration dir, which may be
+// deleted accidentally. The list is sorted by the file name.
+// Note that records in base histories are not checked, because they may be
+// consolidated from other migration dirs.
+func (c *Controller) MissingMigrations() []string {
+	exists := make(map[string]bool, len(c.migrations))
+	for _, m := range c.migrations {
+		exists[m] = true
+	}
+
+	missing := []string{}
+	for _, filename := range c.history.AppliedFilenames() {
+		if !exists[filename] {
+			missing = append(missing, filename)
+		}
+	}
+
+	return missing
+}
+
 // HistoryLength returns a number of records in history.
+// Note that records in base histories are not counted.
 func (c *Controller) HistoryLength() int {
 	return c.history.Length()
 }
 
+// AppliedHistoryLength returns a number of applied records in history.
+// Note that planned records and records in base histories are not counted.
+func (c *Controller) AppliedHistoryLength() int {
+	return c.history.AppliedLength()
+}
+
+// RecordsBetween returns records in history applied in a given time range
+// [from, to) sorted by the applied timestamp. A zero value of from or to
+// means the range is unbounded on that side.
+// Note that records in base histories are not included.
+func (c *Controller) RecordsBetween(from time.Time, to time.Time) []NamedRecord {
+	return c.history.Between(from, to)
+}
+
 // AlreadyApplied returns true if a given migration file has already been applied.
 func (c *Controller) AlreadyApplied(filename string) bool {
-	return c.history.Contains(filename)
+	return c.applied(filename)
+}
+
+// applied returns true if a given migration file is recorded as applied in
+// history or any of base histories.
+func (c *Controller) applied(filename string) bool {
+	if r, ok := c.history.Get(filename); ok && r.Applied() {
+		return true
+	}
+	r, ok := c.base.Get(filename)
+	return ok && r.Applied()
+}
+
+// FindRecord returns a record of a given migration file in history or any of
+// base histories. The history takes precedence over base histories.
+// The second return value is false if the migration is not recorded.
+// Note that the record may be planned but not applied yet.
+func (c *Controller) FindRecord(filename string) (Record, bool) {
+	if r, ok := c.history.Get(filename); ok {
+		return r, true
+	}
+	return c.base.Get(filename)
 }
 
 // AddRecord adds a record to history.
+// The record is never added to base histories.
 // This method doesn't persist history. Call Save() to save the history.
 // If appliedAt is nil, a timestamp is automatically set to time.Now().
-func (c *Controller) AddRecord(filename string, migrationType string, name string, appliedAt *time.Time) {
+// The duration is how long the migration took to apply, which is zero if
+// unknown.
+func (c *Controller) AddRecord(filename string, migrationType string, name string, appliedAt *time.Time, duration time.Duration) {
 	timestamp := appliedAt
 	if timestamp == nil {
 		now := time.Now()
@@ -170,7 +487,54 @@ func (c *Controller) AddRecord(filename string, migrationType string, name strin
 		Type:      migrationType,
 		Name:      name,
 		AppliedAt: *timestamp,
+		Duration:  duration,
+	}
+
+	c.history.Add(filename, r)
+}
+
+// AddPlannedRecord adds a record to history which marks a given migration as
+// planned without applying it. It's replaced with an applied record by
+// AddRecord when the migration is applied.
+// This method doesn't persist history. Call Save() to save the history.
+// If plannedAt is nil, a timestamp is automatically set to time.Now().
+func (c *Controller) AddPlannedRecord(filename string, migrationType string, name string, plannedAt *time.Time) {
+	timestamp := plannedAt
+	if timestamp == nil {
+		now := time.Now()
+		timestamp = &now
+	}
+	r := Record{
+		Type:      migrationType,
+		Name:      name,
+		AppliedAt: *timestamp,
+		Status:    RecordStatusPlanned,
 	}
 
 	c.history.Add(filename, r)
 }
+
+// Dump returns the current history serialized in the latest history file
+// format. It's intended for debugging and scripting.
+func (c *Controller) Dump() ([]byte, error) {
+	f := newFileV1(c.history)
+	return f.Serialize()
+}
+
+// Load replaces the current history with a given history file.
+// The history file is validated strictly, so that an invalid one never
+// overwrites the current history.
+// This method doesn't persist history. Call Save() to save the history.
+func (c *Controller) Load(b []byte) error {
+	if err := validateHistoryFileV1(b); err != nil {
+		return fmt.Errorf("failed to validate history: %s", err)
+	}
+
+	h, err := ParseHistoryFile(b)
+	if err != nil {
+		return fmt.Errorf("failed to load history: %s", err)
+	}
+
+	c.history = *h
+	return nil
+}
@@ -2,9 +2,11 @@ package history
 
 import (
 	"context"
+	"errors"
 	"os"
 	"path/filepath"
 	"reflect"
+	"strings"
 	"testing"
 	"time"
 
@@ -45,6 +47,20 @@ func TestLoadMigrationFileNames(t *testing.T) {
 			},
 			ok: true,
 		},
+		{
+			desc: "yaml",
+			files: []string{
+				"20201012010101_foo.hcl",
+				"20201012020202_foo.yaml",
+				"20201012030303_foo.yml",
+			},
+			want: []string{
+				"20201012010101_foo.hcl",
+				"20201012020202_foo.yaml",
+				"20201012030303_foo.yml",
+			},
+			ok: true,
+		},
 		{
 			desc: "ignore hidden files",
 			files: []string{
@@ -112,6 +128,251 @@ func TestLoadMigrationFileNames(t *testing.T) {
 	}
 }
 
+func TestValidateMigrationFileNames(t *testing.T) {
+	cases := []struct {
+		desc       string
+		migrations []string
+		ok         bool
+	}{
+		{
+			desc: "valid",
+			migrations: []string{
+				"20201012010101_foo.hcl",
+				"2_bar.json",
+			},
+			ok: true,
+		},
+		{
+			desc: "no prefix",
+			migrations: []string{
+				"20201012010101_foo.hcl",
+				"bar.hcl",
+			},
+			ok: false,
+		},
+		{
+			desc: "no underscore",
+			migrations: []string{
+				"20201012010101foo.hcl",
+			},
+			ok: false,
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.desc, func(t *testing.T) {
+			err := validateMigrationFileNames(tc.migrations)
+			if tc.ok && err != nil {
+				t.Fatalf("unexpected err: %s", err)
+			}
+			if !tc.ok && err == nil {
+				t.Fatal("expected to return an error, but no error")
+			}
+		})
+	}
+}
+
+func TestOrderMigrations(t *testing.T) {
+	// The files are sorted alphabetically as loadMigrationFileNames returns.
+	// The mtimes are older in the order of files below.
+	files := []string{
+		"10_c.hcl",
+		"2_b.hcl",
+		"2_a.hcl",
+		"foo.hcl",
+		"01_d.hcl",
+	}
+	migrations := []string{
+		"01_d.hcl",
+		"10_c.hcl",
+		"2_a.hcl",
+		"2_b.hcl",
+		"foo.hcl",
+	}
+
+	cases := []struct {
+		desc  string
+		order string
+		want  []string
+		ok    bool
+	}{
+		{
+			desc:  "default",
+			order: "",
+			want:  migrations,
+			ok:    true,
+		},
+		{
+			desc:  "lexical",
+			order: OrderLexical,
+			want:  migrations,
+			ok:    true,
+		},
+		{
+			desc:  "numeric prefix",
+			order: OrderNumericPrefix,
+			want: []string{
+				"01_d.hcl",
+				"2_a.hcl",
+				"2_b.hcl",
+				"10_c.hcl",
+				"foo.hcl",
+			},
+			ok: true,
+		},
+		{
+			desc:  "mtime",
+			order: OrderMtime,
+			want:  files,
+			ok:    true,
+		},
+		{
+			desc:  "unknown",
+			order: "foo",
+			want:  nil,
+			ok:    false,
+		},
+	}
+
+	migrationDir := t.TempDir()
+	base := time.Date(2020, 10, 12, 1, 1, 1, 0, time.UTC)
+	for i, filename := range files {
+		path := filepath.Join(migrationDir, filename)
+		if err := os.WriteFile(path, []byte{}, 0600); err != nil {
+			t.Fatalf("failed to write dummy migration file: %s", err)
+		}
+		mtime := base.Add(time.Duration(i) * time.Minute)
+		if err := os.Chtimes(path, mtime, mtime); err != nil {
+			t.Fatalf("failed to change mtime of dummy migration file: %s", err)
+		}
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.desc, func(t *testing.T) {
+			got, err := orderMigrations(migrationDir, migrations, tc.order)
+			if tc.ok && err != nil {
+				t.Fatalf("unexpected err: %s", err)
+			}
+			if !tc.ok && err == nil {
+				t.Fatalf("expected to return an error, but no error, got: %#v", got)
+			}
+			if tc.ok {
+				if diff := cmp.Diff(got, tc.want); diff != "" {
+					t.Errorf("got = %#v, want = %#v, diff = %s", got, tc.want, diff)
+				}
+			}
+		})
+	}
+}
+
+func TestSortMigrations(t *testing.T) {
+	migrations := []string{
+		"20201012010101_a.hcl",
+		"20201012020202_b.hcl",
+		"20201012030303_c.hcl",
+		"20201012040404_d.hcl",
+	}
+
+	cases := []struct {
+		desc         string
+		dependencies map[string][]string
+		want         []string
+		ok           bool
+	}{
+		{
+			desc:         "no dependencies",
+			dependencies: nil,
+			want:         migrations,
+			ok:           true,
+		},
+		{
+			desc: "single dependency",
+			dependencies: map[string][]string{
+				"20201012010101_a.hcl": {"20201012030303_c.hcl"},
+			},
+			want: []string{
+				"20201012020202_b.hcl",
+				"20201012030303_c.hcl",
+				"20201012010101_a.hcl",
+				"20201012040404_d.hcl",
+			},
+			ok: true,
+		},
+		{
+			desc: "dependency graph",
+			// d -> b -> c, a -> c, a -> d
+			dependencies: map[string][]string{
+				"20201012040404_d.hcl": {"20201012020202_b.hcl"},
+				"20201012020202_b.hcl": {"20201012030303_c.hcl"},
+				"20201012010101_a.hcl": {"20201012030303_c.hcl", "20201012040404_d.hcl"},
+			},
+			want: []string{
+				"20201012030303_c.hcl",
+				"20201012020202_b.hcl",
+				"20201012040404_d.hcl",
+				"20201012010101_a.hcl",
+			},
+			ok: true,
+		},
+		{
+			desc: "cycle",
+			dependencies: map[string][]string{
+				"20201012010101_a.hcl": {"20201012020202_b.hcl"},
+				"20201012020202_b.hcl": {"20201012030303_c.hcl"},
+				"20201012030303_c.hcl": {"20201012010101_a.hcl"},
+			},
+			want: nil,
+			ok:   false,
+		},
+		{
+			desc: "unknown migration",
+			dependencies: map[string][]string{
+				"20201012010101_a.hcl": {"20201012050505_e.hcl"},
+			},
+			want: migrations,
+			ok:   true,
+		},
+		{
+			desc: "unknown dependent",
+			dependencies: map[string][]string{
+				"20201012050505_e.hcl": {"20201012010101_a.hcl"},
+			},
+			want: migrations,
+			ok:   true,
+		},
+		{
+			desc: "unknown migration with a known one",
+			dependencies: map[string][]string{
+				"20201012010101_a.hcl": {"20201012050505_e.hcl", "20201012030303_c.hcl"},
+			},
+			want: []string{
+				"20201012020202_b.hcl",
+				"20201012030303_c.hcl",
+				"20201012010101_a.hcl",
+				"20201012040404_d.hcl",
+			},
+			ok: true,
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.desc, func(t *testing.T) {
+			got, err := sortMigrations(migrations, tc.dependencies)
+			if tc.ok && err != nil {
+				t.Fatalf("unexpected err: %s", err)
+			}
+			if !tc.ok && err == nil {
+				t.Fatalf("expected to return an error, but no error, got: %#v", got)
+			}
+			if tc.ok {
+				if diff := cmp.Diff(got, tc.want); diff != "" {
+					t.Errorf("got: %v, want: %v, diff: %s", got, tc.want, diff)
+				}
+			}
+		})
+	}
+}
+
 func TestLoadHistory(t *testing.T) {
 	cases := []struct {
 		desc   string
@@ -190,7 +451,101 @@ func TestLoadHistory(t *testing.T) {
 
 	for _, tc := range cases {
 		t.Run(tc.desc, func(t *testing.T) {
-			got, err := loadHistory(context.Background(), tc.config)
+			got, err := loadHistory(context.Background(), tc.config, nil)
+			if tc.ok && err != nil {
+				t.Fatalf("unexpected err: %#v", err)
+			}
+			if !tc.ok && err == nil {
+				t.Fatal("expected to return an error, but no error")
+			}
+
+			if tc.ok {
+				if diff := cmp.Diff(*got, *tc.want, cmp.AllowUnexported(*got)); diff != "" {
+					t.Errorf("got = %#v, want = %#v, diff = %s", got, tc.want, diff)
+				}
+			}
+		})
+	}
+}
+
+func TestLoadBaseHistory(t *testing.T) {
+	cases := []struct {
+		desc    string
+		configs []storage.Config
+		want    *History
+		ok      bool
+	}{
+		{
+			desc: "union",
+			configs: []storage.Config{
+				&mock.Config{
+					Data: `{
+    "version": 1,
+    "records": {
+        "20201012010101_foo.hcl": {
+            "type": "state",
+            "name": "foo",
+            "applied_at": "2020-10-13T01:02:03Z"
+        }
+    }
+}`,
+				},
+				&mock.Config{
+					Data: `{
+    "version": 1,
+    "records": {
+        "20201012010101_foo.hcl": {
+            "type": "state",
+            "name": "dup",
+            "applied_at": "2020-10-14T01:02:03Z"
+        },
+        "20201012020202_foo.hcl": {
+            "type": "state",
+            "name": "bar",
+            "applied_at": "2020-10-13T04:05:06Z"
+        }
+    }
+}`,
+				},
+			},
+			want: &History{
+				records: map[string]Record{
+					"20201012010101_foo.hcl": Record{
+						Type:      "state",
+						Name:      "foo",
+						AppliedAt: time.Date(2020, 10, 13, 1, 2, 3, 0, time.UTC),
+					},
+					"20201012020202_foo.hcl": Record{
+						Type:      "state",
+						Name:      "bar",
+						AppliedAt: time.Date(2020, 10, 13, 4, 5, 6, 0, time.UTC),
+					},
+				},
+			},
+			ok: true,
+		},
+		{
+			desc:    "no base",
+			configs: nil,
+			want:    newEmptyHistory(),
+			ok:      true,
+		},
+		{
+			desc: "read error",
+			configs: []storage.Config{
+				&mock.Config{
+					Data:      "",
+					ReadError: true,
+				},
+			},
+			want: nil,
+			ok:   false,
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.desc, func(t *testing.T) {
+			got, err := loadBaseHistory(context.Background(), tc.configs)
 			if tc.ok && err != nil {
 				t.Fatalf("unexpected err: %#v", err)
 			}
@@ -271,11 +626,92 @@ func TestControllerSave(t *testing.T) {
 	}
 }
 
+func TestControllerSaveReadOnly(t *testing.T) {
+	config := &mock.Config{
+		Data: `{
+    "version": 1,
+    "records": {}
+}`,
+		WriteError: false,
+		ReadError:  false,
+	}
+	c := &Controller{
+		history: History{
+			records: map[string]Record{
+				"20201012010101_foo.hcl": {
+					Type:      "state",
+					Name:      "foo",
+					AppliedAt: time.Date(2020, 10, 13, 1, 2, 3, 0, time.UTC),
+				},
+			},
+		},
+		config: Config{
+			Storage:  config,
+			ReadOnly: true,
+		},
+	}
+
+	err := c.Save(context.Background())
+	if !errors.Is(err, storage.ErrReadOnly) {
+		t.Fatalf("expected to return ErrReadOnly, but got: %v", err)
+	}
+
+	if got := config.Storage().Data(); got != config.Data {
+		t.Errorf("expected the storage not to be written, but got: %s", got)
+	}
+}
+
+func TestControllerWithStaticStorage(t *testing.T) {
+	migrationDir := t.TempDir()
+	for _, filename := range []string{"20201012010101_foo.hcl", "20201012020202_bar.hcl"} {
+		if err := os.WriteFile(filepath.Join(migrationDir, filename), []byte{}, 0600); err != nil {
+			t.Fatalf("failed to write dummy migration file: %s", err)
+		}
+	}
+
+	s, err := mock.NewStorage(&mock.Config{
+		Data: `{
+    "version": 1,
+    "records": {
+        "20201012010101_foo.hcl": {
+            "type": "state",
+            "name": "foo",
+            "applied_at": "2020-10-13T01:02:03Z"
+        }
+    }
+}`,
+	})
+	if err != nil {
+		t.Fatalf("failed to create a mock storage: %s", err)
+	}
+
+	config := &Config{
+		Storage: storage.NewStaticConfig(s),
+	}
+	c, err := NewController(context.Background(), migrationDir, config)
+	if err != nil {
+		t.Fatalf("unexpected err: %s", err)
+	}
+	if got := c.UnappliedMigrations(); !reflect.DeepEqual(got, []string{"20201012020202_bar.hcl"}) {
+		t.Errorf("got: %v, want: [20201012020202_bar.hcl]", got)
+	}
+
+	appliedAt := time.Date(2020, 10, 13, 2, 3, 4, 0, time.UTC)
+	c.AddRecord("20201012020202_bar.hcl", "state", "bar", &appliedAt, time.Second)
+	if err := c.Save(context.Background()); err != nil {
+		t.Fatalf("failed to save: %s", err)
+	}
+	if !strings.Contains(s.Data(), "20201012020202_bar.hcl") {
+		t.Errorf("expected the pre-constructed storage to be written, but got: %s", s.Data())
+	}
+}
+
 func TestUnappliedMigrations(t *testing.T) {
 	cases := []struct {
 		desc       string
 		migrations []string
 		history    History
+		base       History
 		want       []string
 	}{
 		{
@@ -353,6 +789,67 @@ func TestUnappliedMigrations(t *testing.T) {
 				"20201012040404_foo.hcl",
 			},
 		},
+		{
+			desc: "with base",
+			migrations: []string{
+				"20201012010101_foo.hcl",
+				"20201012020202_foo.hcl",
+				"20201012030303_foo.hcl",
+				"20201012040404_foo.hcl",
+			},
+			history: History{
+				records: map[string]Record{
+					"20201012020202_foo.hcl": Record{
+						Type:      "state",
+						Name:      "bar",
+						AppliedAt: time.Date(2020, 10, 13, 4, 5, 6, 0, time.UTC),
+					},
+				},
+			},
+			base: History{
+				records: map[string]Record{
+					"20201012010101_foo.hcl": Record{
+						Type:      "state",
+						Name:      "foo",
+						AppliedAt: time.Date(2020, 10, 13, 1, 2, 3, 0, time.UTC),
+					},
+					"20201012030303_foo.hcl": Record{
+						Type:      "state",
+						Name:      "baz",
+						AppliedAt: time.Date(2020, 10, 13, 7, 8, 9, 0, time.UTC),
+					},
+				},
+			},
+			want: []string{
+				"20201012040404_foo.hcl",
+			},
+		},
+		{
+			desc: "planned but not applied",
+			migrations: []string{
+				"20201012010101_foo.hcl",
+				"20201012020202_foo.hcl",
+			},
+			history: History{
+				records: map[string]Record{
+					"20201012010101_foo.hcl": Record{
+						Type:      "state",
+						Name:      "foo",
+						AppliedAt: time.Date(2020, 10, 13, 1, 2, 3, 0, time.UTC),
+						Status:    RecordStatusApplied,
+					},
+					"20201012020202_foo.hcl": Record{
+						Type:      "state",
+						Name:      "bar",
+						AppliedAt: time.Date(2020, 10, 13, 4, 5, 6, 0, time.UTC),
+						Status:    RecordStatusPlanned,
+					},
+				},
+			},
+			want: []string{
+				"20201012020202_foo.hcl",
+			},
+		},
 	}
 
 	for _, tc := range cases {
@@ -360,6 +857,7 @@ func TestUnappliedMigrations(t *testing.T) {
 			c := &Controller{
 				migrations: tc.migrations,
 				history:    tc.history,
+				base:       tc.base,
 			}
 
 			got := c.UnappliedMigrations()
@@ -370,6 +868,98 @@ func TestUnappliedMigrations(t *testing.T) {
 	}
 }
 
+func TestPlannedMigrations(t *testing.T) {
+	c := &Controller{
+		migrations: []string{
+			"20201012010101_foo.hcl",
+			"20201012020202_foo.hcl",
+			"20201012030303_foo.hcl",
+			"20201012040404_foo.hcl",
+		},
+		history: History{
+			records: map[string]Record{
+				"20201012010101_foo.hcl": Record{
+					Type:      "state",
+					Name:      "foo",
+					AppliedAt: time.Date(2020, 10, 13, 1, 2, 3, 0, time.UTC),
+				},
+				"20201012020202_foo.hcl": Record{
+					Type:      "state",
+					Name:      "bar",
+					AppliedAt: time.Date(2020, 10, 13, 4, 5, 6, 0, time.UTC),
+					Status:    RecordStatusPlanned,
+				},
+				"20201012030303_foo.hcl": Record{
+					Type:      "state",
+					Name:      "baz",
+					AppliedAt: time.Date(2020, 10, 13, 7, 8, 9, 0, time.UTC),
+					Status:    RecordStatusPlanned,
+				},
+			},
+		},
+		base: History{
+			records: map[string]Record{
+				"20201012030303_foo.hcl": Record{
+					Type:      "state",
+					Name:      "baz",
+					AppliedAt: time.Date(2020, 10, 13, 7, 8, 9, 0, time.UTC),
+				},
+			},
+		},
+	}
+
+	got := c.PlannedMigrations()
+	want := []string{"20201012020202_foo.hcl"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got = %#v, want = %#v", got, want)
+	}
+	if c.AlreadyApplied("20201012020202_foo.hcl") {
+		t.Error("expected a planned migration not to be applied")
+	}
+	if !c.AlreadyApplied("20201012030303_foo.hcl") {
+		t.Error("expected a migration applied in base history to be applied")
+	}
+	if got := c.AppliedHistoryLength(); got != 1 {
+		t.Errorf("got = %d, want = %d", got, 1)
+	}
+}
+
+func TestControllerAddPlannedRecord(t *testing.T) {
+	c := &Controller{
+		migrations: []string{"20201012010101_foo.hcl"},
+		history:    *newEmptyHistory(),
+	}
+	plannedAt := time.Date(2020, 10, 13, 1, 2, 3, 0, time.UTC)
+	c.AddPlannedRecord("20201012010101_foo.hcl", "state", "foo", &plannedAt)
+
+	want := Record{
+		Type:      "state",
+		Name:      "foo",
+		AppliedAt: plannedAt,
+		Status:    RecordStatusPlanned,
+	}
+	got, ok := c.FindRecord("20201012010101_foo.hcl")
+	if !ok {
+		t.Fatal("expected to find a planned record, but not found")
+	}
+	if diff := cmp.Diff(got, want); diff != "" {
+		t.Errorf("got = %#v, want = %#v, diff = %s", got, want, diff)
+	}
+	if got := c.UnappliedMigrations(); !reflect.DeepEqual(got, []string{"20201012010101_foo.hcl"}) {
+		t.Errorf("expected a planned migration to be unapplied, got = %#v", got)
+	}
+
+	// apply transitions it to applied.
+	appliedAt := time.Date(2020, 10, 14, 1, 2, 3, 0, time.UTC)
+	c.AddRecord("20201012010101_foo.hcl", "state", "foo", &appliedAt, 0)
+	if !c.AlreadyApplied("20201012010101_foo.hcl") {
+		t.Error("expected a migration to be applied")
+	}
+	if got := c.PlannedMigrations(); len(got) != 0 {
+		t.Errorf("expected no planned migrations, got = %#v", got)
+	}
+}
+
 func TestControllerHistoryLength(t *testing.T) {
 	cases := []struct {
 		desc       string
@@ -477,6 +1067,175 @@ func TestControllerAlreadApplied(t *testing.T) {
 	}
 }
 
+func TestControllerMissingMigrations(t *testing.T) {
+	cases := []struct {
+		desc       string
+		migrations []string
+		history    History
+		base       History
+		want       []string
+	}{
+		{
+			desc: "no missing",
+			migrations: []string{
+				"20201012010101_foo.hcl",
+				"20201012020202_foo.hcl",
+			},
+			history: History{
+				records: map[string]Record{
+					"20201012010101_foo.hcl": Record{
+						Type:      "state",
+						Name:      "foo",
+						AppliedAt: time.Date(2020, 10, 13, 1, 2, 3, 0, time.UTC),
+					},
+				},
+			},
+			base: *newEmptyHistory(),
+			want: []string{},
+		},
+		{
+			desc: "missing",
+			migrations: []string{
+				"20201012030303_foo.hcl",
+			},
+			history: History{
+				records: map[string]Record{
+					"20201012020202_foo.hcl": Record{
+						Type:      "state",
+						Name:      "bar",
+						AppliedAt: time.Date(2020, 10, 13, 4, 5, 6, 0, time.UTC),
+					},
+					"20201012010101_foo.hcl": Record{
+						Type:      "state",
+						Name:      "foo",
+						AppliedAt: time.Date(2020, 10, 13, 1, 2, 3, 0, time.UTC),
+					},
+				},
+			},
+			base: *newEmptyHistory(),
+			want: []string{
+				"20201012010101_foo.hcl",
+				"20201012020202_foo.hcl",
+			},
+		},
+		{
+			desc:       "planned and base records are not checked",
+			migrations: []string{},
+			history: History{
+				records: map[string]Record{
+					"20201012010101_foo.hcl": Record{
+						Type:      "state",
+						Name:      "foo",
+						AppliedAt: time.Date(2020, 10, 13, 1, 2, 3, 0, time.UTC),
+						Status:    RecordStatusPlanned,
+					},
+				},
+			},
+			base: History{
+				records: map[string]Record{
+					"20201012020202_foo.hcl": Record{
+						Type:      "state",
+						Name:      "bar",
+						AppliedAt: time.Date(2020, 10, 13, 4, 5, 6, 0, time.UTC),
+					},
+				},
+			},
+			want: []string{},
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.desc, func(t *testing.T) {
+			c := &Controller{
+				migrations: tc.migrations,
+				history:    tc.history,
+				base:       tc.base,
+			}
+
+			got := c.MissingMigrations()
+			if !reflect.DeepEqual(got, tc.want) {
+				t.Errorf("got = %#v, want = %#v", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestControllerFindRecord(t *testing.T) {
+	history := History{
+		records: map[string]Record{
+			"20201012010101_foo.hcl": Record{
+				Type:      "state",
+				Name:      "foo",
+				AppliedAt: time.Date(2020, 10, 13, 1, 2, 3, 0, time.UTC),
+			},
+		},
+	}
+	base := History{
+		records: map[string]Record{
+			"20201012010101_foo.hcl": Record{
+				Type:      "state",
+				Name:      "base_foo",
+				AppliedAt: time.Date(2020, 10, 12, 1, 2, 3, 0, time.UTC),
+			},
+			"20201012000000_bar.hcl": Record{
+				Type:      "multi_state",
+				Name:      "bar",
+				AppliedAt: time.Date(2020, 10, 12, 0, 0, 0, 0, time.UTC),
+			},
+		},
+	}
+	cases := []struct {
+		desc     string
+		filename string
+		want     Record
+		ok       bool
+	}{
+		{
+			desc:     "history takes precedence over base",
+			filename: "20201012010101_foo.hcl",
+			want: Record{
+				Type:      "state",
+				Name:      "foo",
+				AppliedAt: time.Date(2020, 10, 13, 1, 2, 3, 0, time.UTC),
+			},
+			ok: true,
+		},
+		{
+			desc:     "base",
+			filename: "20201012000000_bar.hcl",
+			want: Record{
+				Type:      "multi_state",
+				Name:      "bar",
+				AppliedAt: time.Date(2020, 10, 12, 0, 0, 0, 0, time.UTC),
+			},
+			ok: true,
+		},
+		{
+			desc:     "not found",
+			filename: "20201012030303_foo.hcl",
+			want:     Record{},
+			ok:       false,
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.desc, func(t *testing.T) {
+			c := &Controller{
+				history: history,
+				base:    base,
+			}
+
+			got, ok := c.FindRecord(tc.filename)
+			if ok != tc.ok {
+				t.Fatalf("got ok = %t, want = %t", ok, tc.ok)
+			}
+			if diff := cmp.Diff(got, tc.want); diff != "" {
+				t.Errorf("got: %#v, want: %#v, diff: %s", got, tc.want, diff)
+			}
+		})
+	}
+}
+
 func TestControllerAddRecord(t *testing.T) {
 	migrations := []string{
 		"20201012010101_foo.hcl",
@@ -547,7 +1306,7 @@ func TestControllerAddRecord(t *testing.T) {
 				history:    tc.history,
 			}
 
-			c.AddRecord(tc.filename, tc.migrationType, currentTC.name, &currentTC.appliedAt)
+			c.AddRecord(tc.filename, tc.migrationType, currentTC.name, &currentTC.appliedAt, 0)
 			got := tc.history
 			if diff := cmp.Diff(got, tc.want, cmp.AllowUnexported(got)); diff != "" {
 				t.Errorf("got = %#v, want = %#v, diff = %s", got, tc.want, diff)
@@ -555,3 +1314,41 @@ func TestControllerAddRecord(t *testing.T) {
 		})
 	}
 }
+
+func TestControllerDumpAndLoad(t *testing.T) {
+	source := `{
+    "version": 1,
+    "records": {
+        "20201109000001_test1.hcl": {
+            "type": "state",
+            "name": "test1",
+            "applied_at": "2020-11-10T00:00:01Z"
+        }
+    }
+}`
+	c := &Controller{
+		history: *newEmptyHistory(),
+	}
+
+	if err := c.Load([]byte(`{"version": 1, "records": {"foo.hcl": {}}}`)); err == nil {
+		t.Fatal("expected to return an error, but no error")
+	}
+	if c.HistoryLength() != 0 {
+		t.Fatalf("expected not to overwrite history with an invalid one, but got: %d records", c.HistoryLength())
+	}
+
+	if err := c.Load([]byte(source)); err != nil {
+		t.Fatalf("unexpected err: %s", err)
+	}
+	if !c.AlreadyApplied("20201109000001_test1.hcl") {
+		t.Errorf("expected to load a record")
+	}
+
+	got, err := c.Dump()
+	if err != nil {
+		t.Fatalf("unexpected err: %s", err)
+	}
+	if string(got) != source {
+		t.Errorf("got: %s, want: %s", string(got), source)
+	}
+}
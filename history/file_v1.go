@@ -1,7 +1,9 @@
 package history
 
 import (
+	"bytes"
 	"encoding/json"
+	"fmt"
 	"time"
 )
 
@@ -17,6 +19,10 @@ type FileV1 struct {
 	// We record only the file name not to invalidate history when the migration
 	// directory is moved.
 	Records map[string]RecordV1 `json:"records"`
+	// Checksum is a checksum of the history file in the form of
+	// `<algorithm>:<hex>` to detect corruption or tampering.
+	// It's written only if the checksum is enabled in the config.
+	Checksum string `json:"checksum,omitempty"`
 }
 
 // RecordV1 represents an applied migration log.
@@ -28,6 +34,14 @@ type RecordV1 struct {
 	// AppliedAt is a timestamp when the migration was applied.
 	// Note that we only record it when the migration was succeed.
 	AppliedAt time.Time `json:"applied_at"`
+	// Duration is how long the migration took to apply in the format of
+	// time.Duration such as `1m2.5s`.
+	// It's omitted for records written by older versions.
+	Duration string `json:"duration,omitempty"`
+	// Status is a status of the migration, either applied or planned.
+	// It's omitted for applied records for backward compatibility, and a
+	// record without status is treated as applied.
+	Status string `json:"status,omitempty"`
 }
 
 // newFileV1 converts a History to a FileV1 instance.
@@ -46,7 +60,18 @@ func newFileV1(h History) *FileV1 {
 
 // newRecordV1 converts a Record to a RecordV1 instance.
 func newRecordV1(r Record) RecordV1 {
-	return RecordV1(r)
+	v := RecordV1{
+		Type:      r.Type,
+		Name:      r.Name,
+		AppliedAt: r.AppliedAt,
+	}
+	if r.Duration != 0 {
+		v.Duration = r.Duration.String()
+	}
+	if !r.Applied() {
+		v.Status = r.Status
+	}
+	return v
 }
 
 // Serialize encodes a FileV1 instance to bytes.
@@ -81,6 +106,59 @@ func (f *FileV1) toHistory() History {
 }
 
 // toRecord converts a RecordV1 to a Record instance.
+// An invalid duration is ignored for backward compatibility, because it's
+// just informational.
 func (r RecordV1) toRecord() Record {
-	return Record(r)
+	var d time.Duration
+	if len(r.Duration) != 0 {
+		// ignore an error and leave it zero.
+		d, _ = time.ParseDuration(r.Duration)
+	}
+	return Record{
+		Type:      r.Type,
+		Name:      r.Name,
+		AppliedAt: r.AppliedAt,
+		Duration:  d,
+		Status:    r.Status,
+	}
+}
+
+// validateHistoryFileV1 validates bytes strictly as a history file format v1.
+// It's intended for validating a history file edited by hand before loading
+// it, and is stricter than parseHistoryFileV1, which is lenient for reading.
+func validateHistoryFileV1(b []byte) error {
+	var f FileV1
+
+	d := json.NewDecoder(bytes.NewReader(b))
+	d.DisallowUnknownFields()
+	if err := d.Decode(&f); err != nil {
+		return err
+	}
+
+	if f.Version != 1 {
+		return fmt.Errorf("unknown history file version: %d", f.Version)
+	}
+	if f.Records == nil {
+		return fmt.Errorf("records is required")
+	}
+	for k, r := range f.Records {
+		if len(k) == 0 {
+			return fmt.Errorf("migration file name of a record is empty")
+		}
+		if len(r.Type) == 0 || len(r.Name) == 0 || r.AppliedAt.IsZero() {
+			return fmt.Errorf("type, name and applied_at are required in a record: %s", k)
+		}
+		if len(r.Duration) != 0 {
+			d, err := time.ParseDuration(r.Duration)
+			if err != nil || d < 0 {
+				return fmt.Errorf("invalid duration in a record: %s: %s", k, r.Duration)
+			}
+		}
+		switch r.Status {
+		case "", RecordStatusApplied, RecordStatusPlanned:
+		default:
+			return fmt.Errorf("invalid status in a record: %s: %s", k, r.Status)
+		}
+	}
+	return nil
 }
@@ -45,6 +45,41 @@ func TestNewFileV1(t *testing.T) {
 				},
 			},
 		},
+		{
+			desc: "with status",
+			h: History{
+				records: map[string]Record{
+					"20201012010101_foo.hcl": Record{
+						Type:      "state",
+						Name:      "foo",
+						AppliedAt: time.Date(2020, 10, 13, 1, 2, 3, 0, time.UTC),
+						Status:    RecordStatusApplied,
+					},
+					"20201012020202_foo.hcl": Record{
+						Type:      "state",
+						Name:      "bar",
+						AppliedAt: time.Date(2020, 10, 13, 4, 5, 6, 0, time.UTC),
+						Status:    RecordStatusPlanned,
+					},
+				},
+			},
+			want: &FileV1{
+				Version: 1,
+				Records: map[string]RecordV1{
+					"20201012010101_foo.hcl": RecordV1{
+						Type:      "state",
+						Name:      "foo",
+						AppliedAt: time.Date(2020, 10, 13, 1, 2, 3, 0, time.UTC),
+					},
+					"20201012020202_foo.hcl": RecordV1{
+						Type:      "state",
+						Name:      "bar",
+						AppliedAt: time.Date(2020, 10, 13, 4, 5, 6, 0, time.UTC),
+						Status:    "planned",
+					},
+				},
+			},
+		},
 	}
 
 	for _, tc := range cases {
@@ -132,7 +167,8 @@ func TestParseHistoryFileV1(t *testing.T) {
         "20201012020202_foo.hcl": {
             "type": "state",
             "name": "bar",
-            "applied_at": "2020-10-13T04:05:06Z"
+            "applied_at": "2020-10-13T04:05:06Z",
+            "duration": "1m2.5s"
         }
     }
 }`),
@@ -147,6 +183,56 @@ func TestParseHistoryFileV1(t *testing.T) {
 						Type:      "state",
 						Name:      "bar",
 						AppliedAt: time.Date(2020, 10, 13, 4, 5, 6, 0, time.UTC),
+						Duration:  62500 * time.Millisecond,
+					},
+				},
+			},
+			ok: true,
+		},
+		{
+			desc: "invalid duration is ignored",
+			b: []byte(`{
+    "version": 1,
+    "records": {
+        "20201012010101_foo.hcl": {
+            "type": "state",
+            "name": "foo",
+            "applied_at": "2020-10-13T01:02:03Z",
+            "duration": "foo"
+        }
+    }
+}`),
+			want: &History{
+				records: map[string]Record{
+					"20201012010101_foo.hcl": Record{
+						Type:      "state",
+						Name:      "foo",
+						AppliedAt: time.Date(2020, 10, 13, 1, 2, 3, 0, time.UTC),
+					},
+				},
+			},
+			ok: true,
+		},
+		{
+			desc: "with status",
+			b: []byte(`{
+    "version": 1,
+    "records": {
+        "20201012010101_foo.hcl": {
+            "type": "state",
+            "name": "foo",
+            "applied_at": "2020-10-13T01:02:03Z",
+            "status": "planned"
+        }
+    }
+}`),
+			want: &History{
+				records: map[string]Record{
+					"20201012010101_foo.hcl": Record{
+						Type:      "state",
+						Name:      "foo",
+						AppliedAt: time.Date(2020, 10, 13, 1, 2, 3, 0, time.UTC),
+						Status:    RecordStatusPlanned,
 					},
 				},
 			},
@@ -183,3 +269,136 @@ func TestParseHistoryFileV1(t *testing.T) {
 		})
 	}
 }
+
+func TestValidateHistoryFileV1(t *testing.T) {
+	cases := []struct {
+		desc   string
+		source string
+		ok     bool
+	}{
+		{
+			desc: "valid",
+			source: `{
+    "version": 1,
+    "records": {
+        "20201109000001_test1.hcl": {
+            "type": "state",
+            "name": "test1",
+            "applied_at": "2020-11-10T00:00:01Z"
+        }
+    }
+}`,
+			ok: true,
+		},
+		{
+			desc:   "empty records",
+			source: `{"version": 1, "records": {}}`,
+			ok:     true,
+		},
+		{
+			desc:   "no records",
+			source: `{"version": 1}`,
+			ok:     false,
+		},
+		{
+			desc:   "unknown version",
+			source: `{"version": 2, "records": {}}`,
+			ok:     false,
+		},
+		{
+			desc:   "unknown field",
+			source: `{"version": 1, "records": {}, "foo": "bar"}`,
+			ok:     false,
+		},
+		{
+			desc: "missing applied_at",
+			source: `{
+    "version": 1,
+    "records": {
+        "20201109000001_test1.hcl": {
+            "type": "state",
+            "name": "test1"
+        }
+    }
+}`,
+			ok: false,
+		},
+		{
+			desc: "with duration",
+			source: `{
+    "version": 1,
+    "records": {
+        "20201109000001_test1.hcl": {
+            "type": "state",
+            "name": "test1",
+            "applied_at": "2020-11-10T00:00:01Z",
+            "duration": "1m2.5s"
+        }
+    }
+}`,
+			ok: true,
+		},
+		{
+			desc: "invalid duration",
+			source: `{
+    "version": 1,
+    "records": {
+        "20201109000001_test1.hcl": {
+            "type": "state",
+            "name": "test1",
+            "applied_at": "2020-11-10T00:00:01Z",
+            "duration": "foo"
+        }
+    }
+}`,
+			ok: false,
+		},
+		{
+			desc: "with status",
+			source: `{
+    "version": 1,
+    "records": {
+        "20201109000001_test1.hcl": {
+            "type": "state",
+            "name": "test1",
+            "applied_at": "2020-11-10T00:00:01Z",
+            "status": "planned"
+        }
+    }
+}`,
+			ok: true,
+		},
+		{
+			desc: "invalid status",
+			source: `{
+    "version": 1,
+    "records": {
+        "20201109000001_test1.hcl": {
+            "type": "state",
+            "name": "test1",
+            "applied_at": "2020-11-10T00:00:01Z",
+            "status": "foo"
+        }
+    }
+}`,
+			ok: false,
+		},
+		{
+			desc:   "invalid json",
+			source: `{`,
+			ok:     false,
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.desc, func(t *testing.T) {
+			err := validateHistoryFileV1([]byte(tc.source))
+			if tc.ok && err != nil {
+				t.Fatalf("unexpected err: %s", err)
+			}
+			if !tc.ok && err == nil {
+				t.Fatal("expected to return an error, but no error")
+			}
+		})
+	}
+}
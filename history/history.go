@@ -1,6 +1,9 @@
 package history
 
-import "time"
+import (
+	"sort"
+	"time"
+)
 
 // History records applied migration logs.
 type History struct {
@@ -12,6 +15,16 @@ type History struct {
 	records map[string]Record
 }
 
+// Valid values of Record.Status.
+const (
+	// RecordStatusApplied means the migration has been applied.
+	// An empty status is also treated as applied for backward compatibility.
+	RecordStatusApplied = "applied"
+	// RecordStatusPlanned means the migration is planned to be applied, but
+	// has not been applied yet.
+	RecordStatusPlanned = "planned"
+)
+
 // Record represents an applied migration log.
 type Record struct {
 	// Type is a migration type.
@@ -20,7 +33,27 @@ type Record struct {
 	Name string
 	// AppliedAt is a timestamp when the migration was applied.
 	// Note that we only record it when the migration was succeed.
+	// For a planned record, it's a timestamp when the migration was marked as
+	// planned.
 	AppliedAt time.Time
+	// Duration is how long the migration took to apply.
+	// It's zero for records written by older versions.
+	Duration time.Duration
+	// Status is a status of the migration, either applied or planned.
+	// It's empty for records written by older versions, which means applied.
+	Status string
+}
+
+// Applied returns true if the record represents an applied migration.
+func (r Record) Applied() bool {
+	return r.Status != RecordStatusPlanned
+}
+
+// NamedRecord is a Record with its migration file name.
+type NamedRecord struct {
+	// Filename is a migration file name.
+	Filename string
+	Record
 }
 
 // newEmptyHistory initializes a new History.
@@ -37,12 +70,29 @@ func (h *History) Add(filename string, r Record) {
 	h.records[filename] = r
 }
 
+// merge adds records in a given history which don't exist yet.
+// Existing records take precedence.
+func (h *History) merge(other *History) {
+	for filename, r := range other.records {
+		if _, ok := h.records[filename]; !ok {
+			h.records[filename] = r
+		}
+	}
+}
+
 // Contains returns true if a given migration has been applied.
 func (h *History) Contains(filename string) bool {
 	_, ok := h.records[filename]
 	return ok
 }
 
+// Get returns a record of a given migration.
+// The second return value is false if the migration has not been applied.
+func (h *History) Get(filename string) (Record, bool) {
+	r, ok := h.records[filename]
+	return r, ok
+}
+
 // Delete deletes a record from history.
 // If a given filename doesn't exist, no-op.
 func (h *History) Delete(filename string) {
@@ -54,7 +104,60 @@ func (h *History) Clear() {
 	h.records = make(map[string]Record)
 }
 
+// Between returns records applied in a given time range [from, to) sorted by
+// the applied timestamp and then the file name.
+// A zero value of from or to means the range is unbounded on that side.
+// Planned records are not included because they have not been applied yet.
+func (h *History) Between(from time.Time, to time.Time) []NamedRecord {
+	records := []NamedRecord{}
+	for filename, r := range h.records {
+		if !r.Applied() {
+			continue
+		}
+		if !from.IsZero() && r.AppliedAt.Before(from) {
+			continue
+		}
+		if !to.IsZero() && !r.AppliedAt.Before(to) {
+			continue
+		}
+		records = append(records, NamedRecord{Filename: filename, Record: r})
+	}
+
+	sort.Slice(records, func(i, j int) bool {
+		if !records[i].AppliedAt.Equal(records[j].AppliedAt) {
+			return records[i].AppliedAt.Before(records[j].AppliedAt)
+		}
+		return records[i].Filename < records[j].Filename
+	})
+	return records
+}
+
+// AppliedFilenames returns migration file names of applied records sorted by
+// the file name. Planned records are not included.
+func (h *History) AppliedFilenames() []string {
+	filenames := []string{}
+	for filename, r := range h.records {
+		if r.Applied() {
+			filenames = append(filenames, filename)
+		}
+	}
+	sort.Strings(filenames)
+	return filenames
+}
+
 // Length returns a number of records in history.
 func (h *History) Length() int {
 	return len(h.records)
 }
+
+// AppliedLength returns a number of applied records in history.
+// Planned records are not counted.
+func (h *History) AppliedLength() int {
+	n := 0
+	for _, r := range h.records {
+		if r.Applied() {
+			n++
+		}
+	}
+	return n
+}
@@ -276,3 +276,77 @@ func TestHistoryLength(t *testing.T) {
 		})
 	}
 }
+
+func TestHistoryBetween(t *testing.T) {
+	h := History{
+		records: map[string]Record{
+			"20201012010101_foo.hcl": Record{
+				Type:      "state",
+				Name:      "foo",
+				AppliedAt: time.Date(2020, 10, 13, 1, 2, 3, 0, time.UTC),
+			},
+			"20201012020202_bar.hcl": Record{
+				Type:      "multi_state",
+				Name:      "bar",
+				AppliedAt: time.Date(2020, 10, 14, 4, 5, 6, 0, time.UTC),
+			},
+			"20201012030303_baz.hcl": Record{
+				Type:      "state",
+				Name:      "baz",
+				AppliedAt: time.Date(2020, 10, 12, 7, 8, 9, 0, time.UTC),
+			},
+			// A planned record is never included.
+			"20201012040404_qux.hcl": Record{
+				Type:      "state",
+				Name:      "qux",
+				AppliedAt: time.Date(2020, 10, 13, 10, 11, 12, 0, time.UTC),
+				Status:    RecordStatusPlanned,
+			},
+		},
+	}
+	foo := NamedRecord{Filename: "20201012010101_foo.hcl", Record: h.records["20201012010101_foo.hcl"]}
+	bar := NamedRecord{Filename: "20201012020202_bar.hcl", Record: h.records["20201012020202_bar.hcl"]}
+	baz := NamedRecord{Filename: "20201012030303_baz.hcl", Record: h.records["20201012030303_baz.hcl"]}
+
+	cases := []struct {
+		desc string
+		from time.Time
+		to   time.Time
+		want []NamedRecord
+	}{
+		{
+			desc: "unbounded",
+			want: []NamedRecord{baz, foo, bar},
+		},
+		{
+			desc: "from only (inclusive)",
+			from: time.Date(2020, 10, 13, 1, 2, 3, 0, time.UTC),
+			want: []NamedRecord{foo, bar},
+		},
+		{
+			desc: "to only (exclusive)",
+			to:   time.Date(2020, 10, 13, 1, 2, 3, 0, time.UTC),
+			want: []NamedRecord{baz},
+		},
+		{
+			desc: "from and to",
+			from: time.Date(2020, 10, 13, 0, 0, 0, 0, time.UTC),
+			to:   time.Date(2020, 10, 14, 0, 0, 0, 0, time.UTC),
+			want: []NamedRecord{foo},
+		},
+		{
+			desc: "empty",
+			from: time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC),
+			want: []NamedRecord{},
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.desc, func(t *testing.T) {
+			got := h.Between(tc.from, tc.to)
+			if diff := cmp.Diff(got, tc.want); diff != "" {
+				t.Errorf("got: %#v, want: %#v, diff: %s", got, tc.want, diff)
+			}
+		})
+	}
+}
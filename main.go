@@ -9,6 +9,8 @@ import (
 
 	"github.com/hashicorp/logutils"
 	"github.com/minamijoyo/tfmigrate/command"
+	"github.com/minamijoyo/tfmigrate/config"
+	"github.com/minamijoyo/tfmigrate/redact"
 	"github.com/mitchellh/cli"
 )
 
@@ -16,14 +18,17 @@ import (
 var version = "0.4.0"
 
 func main() {
-	log.SetOutput(logOutput())
+	// mask secrets in both log and error output.
+	redactor := redact.NewRedactor()
+	logFilter := logOutput()
+	log.SetOutput(redactor.Writer(logFilter))
 	log.Printf("[DEBUG] [main] start: %s", strings.Join(os.Args, " "))
 	log.Printf("[DEBUG] [main] tfmigrate version: %s", version)
 
 	ui := &cli.BasicUi{
-		Writer: os.Stdout,
+		Writer: redactor.Writer(os.Stdout),
 	}
-	commands := initCommands(ui)
+	commands := initCommands(ui, redactor, logFilter)
 
 	args := os.Args[1:]
 
@@ -39,12 +44,16 @@ func main() {
 	if err != nil {
 		ui.Error(fmt.Sprintf("Failed to execute CLI: %s", err))
 	}
+	command.Cleanup()
 
 	os.Exit(exitStatus)
 }
 
-func logOutput() io.Writer {
-	levels := []logutils.LogLevel{"TRACE", "DEBUG", "INFO", "WARN", "ERROR"}
+func logOutput() *logutils.LevelFilter {
+	levels := []logutils.LogLevel{}
+	for _, l := range config.LogLevels {
+		levels = append(levels, logutils.LogLevel(l))
+	}
 	minLevel := os.Getenv("TFMIGRATE_LOG")
 	if len(minLevel) == 0 {
 		minLevel = "INFO" // default log level
@@ -65,9 +74,11 @@ func logOutput() io.Writer {
 	return filter
 }
 
-func initCommands(ui cli.Ui) map[string]cli.CommandFactory {
+func initCommands(ui cli.Ui, redactor *redact.Redactor, logFilter *logutils.LevelFilter) map[string]cli.CommandFactory {
 	meta := command.Meta{
-		UI: ui,
+		UI:        ui,
+		Redactor:  redactor,
+		LogFilter: logFilter,
 	}
 
 	commands := map[string]cli.CommandFactory{
@@ -81,11 +92,66 @@ func initCommands(ui cli.Ui) map[string]cli.CommandFactory {
 				Meta: meta,
 			}, nil
 		},
+		"expand": func() (cli.Command, error) {
+			return &command.ExpandCommand{
+				Meta: meta,
+			}, nil
+		},
+		"generate-moved": func() (cli.Command, error) {
+			return &command.GenerateMovedCommand{
+				Meta: meta,
+			}, nil
+		},
+		"import": func() (cli.Command, error) {
+			return &command.ImportCommand{
+				Meta: meta,
+			}, nil
+		},
+		"push": func() (cli.Command, error) {
+			return &command.PushCommand{
+				Meta: meta,
+			}, nil
+		},
 		"list": func() (cli.Command, error) {
 			return &command.ListCommand{
 				Meta: meta,
 			}, nil
 		},
+		"history": func() (cli.Command, error) {
+			return &command.HistoryCommand{
+				Meta: meta,
+			}, nil
+		},
+		"history changelog": func() (cli.Command, error) {
+			return &command.HistoryChangelogCommand{
+				Meta: meta,
+			}, nil
+		},
+		"history dump": func() (cli.Command, error) {
+			return &command.HistoryDumpCommand{
+				Meta: meta,
+			}, nil
+		},
+		"history load": func() (cli.Command, error) {
+			return &command.HistoryLoadCommand{
+				Meta: meta,
+			}, nil
+		},
+		"history mark-planned": func() (cli.Command, error) {
+			return &command.HistoryMarkPlannedCommand{
+				Meta: meta,
+			}, nil
+		},
+		"history show": func() (cli.Command, error) {
+			return &command.HistoryShowCommand{
+				Meta: meta,
+			}, nil
+		},
+		"verify": func() (cli.Command, error) {
+			return &command.VerifyCommand{
+				Meta: meta,
+			}, nil
+		},
 	}
 
 	return commands
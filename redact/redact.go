@@ -0,0 +1,125 @@
+package redact
+
+import (
+	"io"
+	"regexp"
+	"sync"
+)
+
+// Mask is a string which replaces a secret.
+const Mask = "***"
+
+// DefaultPatterns is a list of regular expressions for common secrets.
+// If a pattern has a capturing group, the first group is kept as a prefix and
+// only the rest of the match is masked.
+var DefaultPatterns = []string{
+	// AWS access key ID
+	`\b(?:A3T[A-Z0-9]|AKIA|ASIA|AGPA|AIDA|AROA|AIPA|ANPA|ANVA)[A-Z0-9]{16}\b`,
+	// AWS secret access key
+	`(?i)(aws_secret_access_key\s*[=:]\s*["']?)[A-Za-z0-9/+=]{40}`,
+	// Bearer token
+	`(?i)(bearer\s+)[A-Za-z0-9\-._~+/]+=*`,
+	// Basic auth credentials in URL
+	`([a-z][a-z0-9+.-]*://[^:/\s]+:)[^@/\s]+`,
+}
+
+// Func is a function which redacts secrets in a given string.
+// It can be added to a Redactor as a hook for custom redaction logic.
+type Func func(s string) string
+
+// Redactor masks secrets in log and error output.
+// It's safe for concurrent use and patterns can be added after writers have
+// been created, because a configuration file is loaded after the log output
+// has been set up.
+type Redactor struct {
+	mu       sync.RWMutex
+	patterns []*regexp.Regexp
+	funcs    []Func
+}
+
+// NewRedactor returns a new Redactor instance with DefaultPatterns.
+func NewRedactor() *Redactor {
+	r := &Redactor{}
+	for _, p := range DefaultPatterns {
+		r.patterns = append(r.patterns, regexp.MustCompile(p))
+	}
+	return r
+}
+
+// AddPatterns compiles and adds a list of regular expressions.
+// It's a no-op if the Redactor is nil.
+func (r *Redactor) AddPatterns(patterns []string) error {
+	if r == nil {
+		return nil
+	}
+
+	compiled := make([]*regexp.Regexp, 0, len(patterns))
+	for _, p := range patterns {
+		re, err := regexp.Compile(p)
+		if err != nil {
+			return err
+		}
+		compiled = append(compiled, re)
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.patterns = append(r.patterns, compiled...)
+	return nil
+}
+
+// AddFunc adds a custom redaction function.
+// It's a no-op if the Redactor is nil.
+func (r *Redactor) AddFunc(f Func) {
+	if r == nil {
+		return
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.funcs = append(r.funcs, f)
+}
+
+// Redact returns a string with secrets masked.
+func (r *Redactor) Redact(s string) string {
+	if r == nil {
+		return s
+	}
+
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	for _, re := range r.patterns {
+		repl := Mask
+		if re.NumSubexp() > 0 {
+			repl = "${1}" + Mask
+		}
+		s = re.ReplaceAllString(s, repl)
+	}
+	for _, f := range r.funcs {
+		s = f(s)
+	}
+	return s
+}
+
+// Writer returns an io.Writer which redacts secrets before writing to w.
+// Each Write call is redacted independently, so the caller should write a
+// whole message at once as the log package and cli.Ui do.
+func (r *Redactor) Writer(w io.Writer) io.Writer {
+	return &writer{r: r, w: w}
+}
+
+// writer implements the io.Writer interface.
+type writer struct {
+	r *Redactor
+	w io.Writer
+}
+
+// Write writes a redacted p to the underlying writer.
+// It returns len(p) on success even if the redacted length differs, because
+// callers treat a short write as an error.
+func (w *writer) Write(p []byte) (int, error) {
+	if _, err := w.w.Write([]byte(w.r.Redact(string(p)))); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
@@ -5,3 +5,25 @@ type Config interface {
 	// NewStorage returns a new instance of Storage.
 	NewStorage() (Storage, error)
 }
+
+// staticConfig is a Config which always returns a pre-constructed Storage.
+type staticConfig struct {
+	// s is a pre-constructed storage to be returned.
+	s Storage
+}
+
+var _ Config = (*staticConfig)(nil)
+
+// NewStaticConfig returns a Config which always returns a given Storage.
+// It allows us to plug in a pre-constructed Storage, such as a custom
+// implementation, to history.Config when embedding tfmigrate.
+// Note that NewStorage may be called more than once, and the same instance
+// is shared across calls.
+func NewStaticConfig(s Storage) Config {
+	return &staticConfig{s: s}
+}
+
+// NewStorage returns the pre-constructed Storage.
+func (c *staticConfig) NewStorage() (Storage, error) {
+	return c.s, nil
+}
@@ -17,6 +17,18 @@ type Client interface {
 	Write(ctx context.Context, p []byte) error
 }
 
+// A minimal interface to mock conditional writes of GCS client.
+// It's used only if the conditional_write is enabled.
+type ConditionalClient interface {
+	// Read an object and its generation from a GCS bucket.
+	ReadWithGeneration(ctx context.Context) ([]byte, int64, error)
+
+	// Write an object onto a GCS bucket only if its generation matches a
+	// given one, and return a new generation. A zero generation means that
+	// the object must not exist.
+	WriteIfGenerationMatch(ctx context.Context, p []byte, generation int64) (int64, error)
+}
+
 // An implementation of Client that delegates actual operation to gcsStorage.Client.
 type Adapter struct {
 	// A config to specify which bucket and object we handle.
@@ -39,6 +51,35 @@ func (a Adapter) Read(ctx context.Context) ([]byte, error) {
 	return body, nil
 }
 
+func (a Adapter) ReadWithGeneration(ctx context.Context) ([]byte, int64, error) {
+	r, err := a.client.Bucket(a.config.Bucket).Object(a.config.Name).NewReader(ctx)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer r.Close()
+
+	body, err := io.ReadAll(r)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed reading from gcs://%s/%s: %w", a.config.Bucket, a.config.Name, err)
+	}
+	return body, r.Attrs.Generation, nil
+}
+
+func (a Adapter) WriteIfGenerationMatch(ctx context.Context, p []byte, generation int64) (int64, error) {
+	cond := gcStorage.Conditions{GenerationMatch: generation}
+	if generation == 0 {
+		cond = gcStorage.Conditions{DoesNotExist: true}
+	}
+	w := a.client.Bucket(a.config.Bucket).Object(a.config.Name).If(cond).NewWriter(ctx)
+	if _, err := w.Write(p); err != nil {
+		return 0, fmt.Errorf("failed writing to gcs://%s/%s: %w", a.config.Bucket, a.config.Name, err)
+	}
+	if err := w.Close(); err != nil {
+		return 0, err
+	}
+	return w.Attrs().Generation, nil
+}
+
 func (a Adapter) Write(ctx context.Context, p []byte) error {
 	w := a.client.Bucket(a.config.Bucket).Object(a.config.Name).NewWriter(ctx)
 	_, err := w.Write(p)
@@ -12,6 +12,9 @@ type Config struct {
 	Bucket string `hcl:"bucket"`
 	// Path to the migration history file.
 	Name string `hcl:"name"`
+	// Write the history only if it hasn't been modified since it was read,
+	// by comparing the generation of the object.
+	ConditionalWrite bool `hcl:"conditional_write,optional"`
 }
 
 // Config implements a storage.Config.
@@ -2,9 +2,13 @@ package gcs
 
 import (
 	"context"
+	"errors"
+	"fmt"
+	"net/http"
 
 	gcStorage "cloud.google.com/go/storage"
 	"github.com/minamijoyo/tfmigrate/storage"
+	"google.golang.org/api/googleapi"
 )
 
 // An implementation of [storage.Storage] interface.
@@ -15,6 +19,11 @@ type Storage struct {
 	// It is intended to be replaced with a mock for testing.
 	// https://pkg.go.dev/cloud.google.com/go/storage#Client
 	client Client
+	// read is true if the object has been read with conditional_write.
+	read bool
+	// generation is a generation of the object when it was read or written
+	// last. It's zero if the object didn't exist.
+	generation int64
 }
 
 var _ storage.Storage = (*Storage)(nil)
@@ -34,7 +43,24 @@ func (s *Storage) Write(ctx context.Context, b []byte) error {
 		return err
 	}
 
-	return s.client.Write(ctx, b)
+	if !s.config.ConditionalWrite || !s.read {
+		return s.client.Write(ctx, b)
+	}
+
+	c, err := s.conditionalClient()
+	if err != nil {
+		return err
+	}
+	generation, err := c.WriteIfGenerationMatch(ctx, b, s.generation)
+	if err != nil {
+		var gerr *googleapi.Error
+		if errors.As(err, &gerr) && gerr.Code == http.StatusPreconditionFailed {
+			return fmt.Errorf("%w: gcs://%s/%s: %s", storage.ErrConflict, s.config.Bucket, s.config.Name, err)
+		}
+		return err
+	}
+	s.generation = generation
+	return nil
 }
 
 func (s *Storage) Read(ctx context.Context) ([]byte, error) {
@@ -43,6 +69,10 @@ func (s *Storage) Read(ctx context.Context) ([]byte, error) {
 		return nil, err
 	}
 
+	if s.config.ConditionalWrite {
+		return s.readWithGeneration(ctx)
+	}
+
 	r, err := s.client.Read(ctx)
 	if err == gcStorage.ErrObjectNotExist {
 		return []byte{}, nil
@@ -52,6 +82,36 @@ func (s *Storage) Read(ctx context.Context) ([]byte, error) {
 	return r, nil
 }
 
+// readWithGeneration reads the object and remembers its generation for a
+// conditional write.
+func (s *Storage) readWithGeneration(ctx context.Context) ([]byte, error) {
+	c, err := s.conditionalClient()
+	if err != nil {
+		return nil, err
+	}
+
+	r, generation, err := c.ReadWithGeneration(ctx)
+	if err == gcStorage.ErrObjectNotExist {
+		s.read = true
+		s.generation = 0
+		return []byte{}, nil
+	} else if err != nil {
+		return nil, err
+	}
+	s.read = true
+	s.generation = generation
+	return r, nil
+}
+
+// conditionalClient returns the client as a ConditionalClient.
+func (s *Storage) conditionalClient() (ConditionalClient, error) {
+	c, ok := s.client.(ConditionalClient)
+	if !ok {
+		return nil, fmt.Errorf("the client doesn't support conditional_write: %T", s.client)
+	}
+	return c, nil
+}
+
 func (s *Storage) init(ctx context.Context) error {
 	if s.client == nil {
 		client, err := gcStorage.NewClient(ctx)
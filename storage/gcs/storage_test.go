@@ -2,9 +2,13 @@ package gcs
 
 import (
 	"context"
+	"errors"
+	"net/http"
 	"testing"
 
 	gcStorage "cloud.google.com/go/storage"
+	"github.com/minamijoyo/tfmigrate/storage"
+	"google.golang.org/api/googleapi"
 )
 
 // mockClient is a mock implementation for testing.
@@ -142,3 +146,100 @@ func TestStorageRead(t *testing.T) {
 		})
 	}
 }
+
+// mockConditionalClient is a mock implementation of ConditionalClient.
+type mockConditionalClient struct {
+	mockClient
+	generation int64
+	readErr    error
+	writeErr   error
+
+	// gotGeneration records a generation given to the last write.
+	gotGeneration int64
+}
+
+func (c *mockConditionalClient) ReadWithGeneration(_ context.Context) ([]byte, int64, error) {
+	return c.dataToRead, c.generation, c.readErr
+}
+
+func (c *mockConditionalClient) WriteIfGenerationMatch(_ context.Context, _ []byte, generation int64) (int64, error) {
+	c.gotGeneration = generation
+	if c.writeErr != nil {
+		return 0, c.writeErr
+	}
+	return generation + 1, nil
+}
+
+func TestStorageConditionalWrite(t *testing.T) {
+	cases := []struct {
+		desc           string
+		client         Client
+		wantGeneration int64
+		wantConflict   bool
+		ok             bool
+	}{
+		{
+			desc: "generation match",
+			client: &mockConditionalClient{
+				mockClient: mockClient{dataToRead: []byte("foo")},
+				generation: 3,
+			},
+			wantGeneration: 3,
+			ok:             true,
+		},
+		{
+			desc: "object does not exist",
+			client: &mockConditionalClient{
+				readErr: gcStorage.ErrObjectNotExist,
+			},
+			wantGeneration: 0,
+			ok:             true,
+		},
+		{
+			desc: "conflict",
+			client: &mockConditionalClient{
+				mockClient: mockClient{dataToRead: []byte("foo")},
+				generation: 3,
+				writeErr:   &googleapi.Error{Code: http.StatusPreconditionFailed},
+			},
+			wantGeneration: 3,
+			wantConflict:   true,
+			ok:             false,
+		},
+		{
+			desc:   "not supported",
+			client: &mockClient{},
+			ok:     false,
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.desc, func(t *testing.T) {
+			config := &Config{
+				Bucket:           "tfmigrate-test",
+				Name:             "tfmigrate/history.json",
+				ConditionalWrite: true,
+			}
+			s, err := NewStorage(config, tc.client)
+			if err != nil {
+				t.Fatalf("failed to NewStorage: %s", err)
+			}
+			_, err = s.Read(context.Background())
+			if err == nil {
+				err = s.Write(context.Background(), []byte("bar"))
+			}
+			if tc.ok && err != nil {
+				t.Fatalf("unexpected err: %s", err)
+			}
+			if !tc.ok && err == nil {
+				t.Fatal("expected to return an error, but no error")
+			}
+			if got := errors.Is(err, storage.ErrConflict); got != tc.wantConflict {
+				t.Errorf("got conflict: %t, want: %t, err: %v", got, tc.wantConflict, err)
+			}
+			if c, ok := tc.client.(*mockConditionalClient); ok && c.gotGeneration != tc.wantGeneration {
+				t.Errorf("got generation: %d, want: %d", c.gotGeneration, tc.wantGeneration)
+			}
+		})
+	}
+}
@@ -0,0 +1,104 @@
+package pg
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"github.com/lib/pq"
+)
+
+// Client is an abstraction layer for PostgreSQL.
+// It is intended to be replaced with a mock for testing.
+type Client interface {
+	// Read reads a migration history record from a table.
+	// If the record does not exist, it returns sql.ErrNoRows.
+	Read(ctx context.Context) ([]byte, error)
+	// Write writes a migration history record to a table in a transaction.
+	Write(ctx context.Context, b []byte) error
+}
+
+// client is a real implementation of the Client.
+type client struct {
+	// config is a storage config for pg.
+	config Config
+	// db is a handle of the database.
+	db *sql.DB
+}
+
+// newClient returns a new instance of Client.
+func newClient(config Config) (Client, error) {
+	db, err := sql.Open("postgres", config.ConnStr)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open postgres connection: %w", err)
+	}
+
+	return &client{
+		config: config,
+		db:     db,
+	}, nil
+}
+
+// table returns a quoted name of the table for migration history.
+func (c *client) table() string {
+	return pq.QuoteIdentifier(c.config.SchemaName) + "." + pq.QuoteIdentifier(tableName)
+}
+
+// Read reads a migration history record from a table.
+// If the record does not exist, it returns sql.ErrNoRows.
+func (c *client) Read(ctx context.Context) ([]byte, error) {
+	var data []byte
+	query := fmt.Sprintf("SELECT data FROM %s WHERE name = $1", c.table())
+	err := c.db.QueryRowContext(ctx, query, c.config.Name).Scan(&data)
+	if err != nil {
+		var pqErr *pq.Error
+		// 42P01: undefined_table, 3F000: invalid_schema_name
+		if errors.As(err, &pqErr) && (pqErr.Code == "42P01" || pqErr.Code == "3F000") {
+			return nil, sql.ErrNoRows
+		}
+		return nil, err
+	}
+	return data, nil
+}
+
+// Write writes a migration history record to a table in a transaction.
+// The schema and table are created if they don't exist.
+// The row for the record is locked with SELECT ... FOR UPDATE before updating
+// it, so that concurrent writes are serialized. Note that the lock is not held
+// since Read, so a change since then is not detected and the last writer wins.
+func (c *client) Write(ctx context.Context, b []byte) (err error) {
+	tx, err := c.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer func() {
+		if err != nil {
+			err = errors.Join(err, tx.Rollback())
+		}
+	}()
+
+	stmts := []string{
+		fmt.Sprintf("CREATE SCHEMA IF NOT EXISTS %s", pq.QuoteIdentifier(c.config.SchemaName)),
+		fmt.Sprintf("CREATE TABLE IF NOT EXISTS %s (name TEXT PRIMARY KEY, data BYTEA NOT NULL, updated_at TIMESTAMPTZ NOT NULL DEFAULT now())", c.table()),
+	}
+	for _, stmt := range stmts {
+		if _, err = tx.ExecContext(ctx, stmt); err != nil {
+			return err
+		}
+	}
+
+	lock := fmt.Sprintf("SELECT name FROM %s WHERE name = $1 FOR UPDATE", c.table())
+	var name string
+	err = tx.QueryRowContext(ctx, lock, c.config.Name).Scan(&name)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return err
+	}
+
+	upsert := fmt.Sprintf("INSERT INTO %s (name, data, updated_at) VALUES ($1, $2, now()) ON CONFLICT (name) DO UPDATE SET data = EXCLUDED.data, updated_at = EXCLUDED.updated_at", c.table())
+	if _, err = tx.ExecContext(ctx, upsert, c.config.Name, b); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
@@ -0,0 +1,28 @@
+package pg
+
+import "github.com/minamijoyo/tfmigrate/storage"
+
+// Config is a config for PostgreSQL storage.
+// This is expected to have almost the same options as Terraform pg backend.
+// https://developer.hashicorp.com/terraform/language/settings/backends/pg
+// However, it has many minor options and it's a pain to test all options from
+// first, so we added only options we need for now.
+type Config struct {
+	// Postgres connection string; a postgres:// URL.
+	ConnStr string `hcl:"conn_str"`
+	// Name of the automatically-managed Postgres schema.
+	// Default to `tfmigrate_history`.
+	SchemaName string `hcl:"schema_name,optional"`
+	// Name of the migration history record stored in the table.
+	// It allows us to share a single table with multiple histories.
+	// Default to `default`.
+	Name string `hcl:"name,optional"`
+}
+
+// Config implements a storage.Config.
+var _ storage.Config = (*Config)(nil)
+
+// NewStorage returns a new instance of storage.Storage.
+func (c *Config) NewStorage() (storage.Storage, error) {
+	return NewStorage(c, nil)
+}
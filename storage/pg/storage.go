@@ -0,0 +1,79 @@
+package pg
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+
+	"github.com/minamijoyo/tfmigrate/storage"
+)
+
+const (
+	// defaultSchemaName is a default name of the schema.
+	defaultSchemaName = "tfmigrate_history"
+	// defaultName is a default name of the history record.
+	defaultName = "default"
+	// tableName is a name of the table for migration history.
+	tableName = "history"
+)
+
+// Storage is a storage.Storage implementation for PostgreSQL.
+// A migration history is stored as a row in a table, and Write is done in a
+// transaction, which gives us atomicity and row-level locking that object
+// stores lack.
+type Storage struct {
+	// config is a storage config for pg.
+	config *Config
+	// client is an instance of Client interface to call API.
+	// It is intended to be replaced with a mock for testing.
+	client Client
+}
+
+var _ storage.Storage = (*Storage)(nil)
+
+// NewStorage returns a new instance of Storage.
+func NewStorage(config *Config, client Client) (*Storage, error) {
+	c := *config
+	if len(c.SchemaName) == 0 {
+		c.SchemaName = defaultSchemaName
+	}
+	if len(c.Name) == 0 {
+		c.Name = defaultName
+	}
+
+	if client == nil {
+		var err error
+		client, err = newClient(c)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	s := &Storage{
+		config: &c,
+		client: client,
+	}
+
+	return s, nil
+}
+
+// Write writes migration history data to storage.
+func (s *Storage) Write(ctx context.Context, b []byte) error {
+	return s.client.Write(ctx, b)
+}
+
+// Read reads migration history data from storage.
+// If the record does not exist, it is assumed to be uninitialized and returns
+// an empty array instead of an error.
+func (s *Storage) Read(ctx context.Context) ([]byte, error) {
+	b, err := s.client.Read(ctx)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			// If the record does not exist
+			return []byte{}, nil
+		}
+		// unexpected error
+		return nil, err
+	}
+	return b, nil
+}
@@ -0,0 +1,184 @@
+package pg
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"testing"
+)
+
+// mockClient is a mock implementation for testing.
+type mockClient struct {
+	dataToRead []byte
+	err        error
+}
+
+func (c *mockClient) Read(_ context.Context) ([]byte, error) {
+	return c.dataToRead, c.err
+}
+
+func (c *mockClient) Write(_ context.Context, _ []byte) error {
+	return c.err
+}
+
+func TestNewStorage(t *testing.T) {
+	cases := []struct {
+		desc   string
+		config *Config
+		want   Config
+	}{
+		{
+			desc: "defaults",
+			config: &Config{
+				ConnStr: "postgres://localhost/tfmigrate",
+			},
+			want: Config{
+				ConnStr:    "postgres://localhost/tfmigrate",
+				SchemaName: "tfmigrate_history",
+				Name:       "default",
+			},
+		},
+		{
+			desc: "custom",
+			config: &Config{
+				ConnStr:    "postgres://localhost/tfmigrate",
+				SchemaName: "foo",
+				Name:       "bar",
+			},
+			want: Config{
+				ConnStr:    "postgres://localhost/tfmigrate",
+				SchemaName: "foo",
+				Name:       "bar",
+			},
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.desc, func(t *testing.T) {
+			s, err := NewStorage(tc.config, &mockClient{})
+			if err != nil {
+				t.Fatalf("failed to NewStorage: %s", err)
+			}
+			if *s.config != tc.want {
+				t.Errorf("got: %#v, want: %#v", *s.config, tc.want)
+			}
+		})
+	}
+}
+
+func TestStorageWrite(t *testing.T) {
+	cases := []struct {
+		desc     string
+		config   *Config
+		client   Client
+		contents []byte
+		ok       bool
+	}{
+		{
+			desc: "simple",
+			config: &Config{
+				ConnStr: "postgres://localhost/tfmigrate",
+			},
+			client: &mockClient{
+				err: nil,
+			},
+			contents: []byte("foo"),
+			ok:       true,
+		},
+		{
+			desc: "connection refused",
+			config: &Config{
+				ConnStr: "postgres://localhost/tfmigrate",
+			},
+			client: &mockClient{
+				err: errors.New("dial tcp: connection refused"),
+			},
+			contents: []byte("foo"),
+			ok:       false,
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.desc, func(t *testing.T) {
+			s, err := NewStorage(tc.config, tc.client)
+			if err != nil {
+				t.Fatalf("failed to NewStorage: %s", err)
+			}
+			err = s.Write(context.Background(), tc.contents)
+			if tc.ok && err != nil {
+				t.Fatalf("unexpected err: %s", err)
+			}
+			if !tc.ok && err == nil {
+				t.Fatal("expected to return an error, but no error")
+			}
+		})
+	}
+}
+
+func TestStorageRead(t *testing.T) {
+	cases := []struct {
+		desc     string
+		config   *Config
+		client   Client
+		contents []byte
+		ok       bool
+	}{
+		{
+			desc: "simple",
+			config: &Config{
+				ConnStr: "postgres://localhost/tfmigrate",
+			},
+			client: &mockClient{
+				dataToRead: []byte("foo"),
+				err:        nil,
+			},
+			contents: []byte("foo"),
+			ok:       true,
+		},
+		{
+			desc: "connection refused",
+			config: &Config{
+				ConnStr: "postgres://localhost/tfmigrate",
+			},
+			client: &mockClient{
+				dataToRead: nil,
+				err:        errors.New("dial tcp: connection refused"),
+			},
+			contents: nil,
+			ok:       false,
+		},
+		{
+			desc: "record does not exist",
+			config: &Config{
+				ConnStr: "postgres://localhost/tfmigrate",
+			},
+			client: &mockClient{
+				err: sql.ErrNoRows,
+			},
+			contents: []byte{},
+			ok:       true,
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.desc, func(t *testing.T) {
+			s, err := NewStorage(tc.config, tc.client)
+			if err != nil {
+				t.Fatalf("failed to NewStorage: %s", err)
+			}
+			got, err := s.Read(context.Background())
+			if tc.ok && err != nil {
+				t.Fatalf("unexpected err: %s", err)
+			}
+			if !tc.ok && err == nil {
+				t.Fatal("expected to return an error, but no error")
+			}
+
+			if tc.ok {
+				if string(got) != string(tc.contents) {
+					t.Errorf("got: %s, want: %s", string(got), string(tc.contents))
+				}
+			}
+		})
+	}
+}
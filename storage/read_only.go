@@ -0,0 +1,28 @@
+package storage
+
+import (
+	"context"
+	"errors"
+)
+
+// ErrReadOnly is an error returned when writing to a read-only storage.
+var ErrReadOnly = errors.New("refused to write to storage in read-only mode")
+
+// readOnlyStorage wraps a Storage and refuses any write.
+type readOnlyStorage struct {
+	// Storage is a wrapped storage to be read.
+	Storage
+}
+
+var _ Storage = (*readOnlyStorage)(nil)
+
+// NewReadOnlyStorage returns a Storage which reads from a given storage but
+// refuses any write with ErrReadOnly.
+func NewReadOnlyStorage(s Storage) Storage {
+	return &readOnlyStorage{Storage: s}
+}
+
+// Write refuses to write and always returns ErrReadOnly.
+func (s *readOnlyStorage) Write(_ context.Context, _ []byte) error {
+	return ErrReadOnly
+}
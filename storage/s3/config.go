@@ -34,6 +34,10 @@ type Config struct {
 	ForcePathStyle bool `hcl:"force_path_style,optional"`
 	// SSE KMS Key Id for optional server-side encryption enablement
 	KmsKeyID string `hcl:"kms_key_id,optional"`
+	// Write the history only if it hasn't been modified since it was read,
+	// by comparing the ETag of the object.
+	// Note that some S3 compatible storages don't support it.
+	ConditionalWrite bool `hcl:"conditional_write,optional"`
 }
 
 // Config implements a storage.Config.
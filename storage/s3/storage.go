@@ -4,10 +4,13 @@ import (
 	"bytes"
 	"context"
 	"errors"
+	"fmt"
 
 	"github.com/aws/aws-sdk-go-v2/aws"
 	"github.com/aws/aws-sdk-go-v2/service/s3"
 	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+	"github.com/aws/smithy-go"
+	smithyhttp "github.com/aws/smithy-go/transport/http"
 	"github.com/minamijoyo/tfmigrate/storage"
 )
 
@@ -18,6 +21,11 @@ type Storage struct {
 	// client is an instance of S3Client interface to call API.
 	// It is intended to be replaced with a mock for testing.
 	client Client
+	// read is true if the object has been read.
+	read bool
+	// etag is an ETag of the object when it was read or written last.
+	// It's nil if the object didn't exist.
+	etag *string
 }
 
 var _ storage.Storage = (*Storage)(nil)
@@ -52,9 +60,39 @@ func (s *Storage) Write(ctx context.Context, b []byte) error {
 		input.ServerSideEncryption = types.ServerSideEncryptionAwsKms
 	}
 
-	_, err := s.client.PutObject(ctx, input)
+	optFns := []func(*s3.Options){}
+	if s.config.ConditionalWrite && s.read {
+		if s.etag != nil {
+			// The SDK version we use doesn't have a field for If-Match yet.
+			optFns = append(optFns, s3.WithAPIOptions(smithyhttp.SetHeaderValue("If-Match", *s.etag)))
+		} else {
+			input.IfNoneMatch = aws.String("*")
+		}
+	}
+
+	output, err := s.client.PutObject(ctx, input, optFns...)
+	if err != nil {
+		if isConflictError(err) {
+			return fmt.Errorf("%w: s3://%s/%s: %s", storage.ErrConflict, s.config.Bucket, s.config.Key, err)
+		}
+		return err
+	}
 
-	return err
+	if s.config.ConditionalWrite && s.read {
+		s.etag = output.ETag
+	}
+	return nil
+}
+
+// isConflictError returns true if a given error of PutObject means that the
+// condition of the write is not met.
+func isConflictError(err error) bool {
+	var apiErr smithy.APIError
+	if !errors.As(err, &apiErr) {
+		return false
+	}
+	code := apiErr.ErrorCode()
+	return code == "PreconditionFailed" || code == "ConditionalRequestConflict"
 }
 
 // Read reads migration history data from storage.
@@ -71,6 +109,8 @@ func (s *Storage) Read(ctx context.Context) ([]byte, error) {
 		var nsk *types.NoSuchKey
 		if errors.As(err, &nsk) {
 			// If the key does not exist
+			s.read = true
+			s.etag = nil
 			return []byte{}, nil
 		}
 		// unexpected error
@@ -85,5 +125,8 @@ func (s *Storage) Read(ctx context.Context) ([]byte, error) {
 		return nil, err
 	}
 
+	s.read = true
+	s.etag = output.ETag
+
 	return buf.Bytes(), nil
 }
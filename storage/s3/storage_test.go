@@ -2,6 +2,7 @@ package s3
 
 import (
 	"context"
+	"errors"
 	"io"
 	"strings"
 	"testing"
@@ -9,6 +10,8 @@ import (
 	"github.com/aws/aws-sdk-go-v2/aws"
 	"github.com/aws/aws-sdk-go-v2/service/s3"
 	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+	"github.com/aws/smithy-go"
+	"github.com/minamijoyo/tfmigrate/storage"
 )
 
 // mockClient is a mock implementation for testing.
@@ -16,15 +19,31 @@ type mockClient struct {
 	putOutput *s3.PutObjectOutput
 	getOutput *s3.GetObjectOutput
 	err       error
+	// getErr and putErr are errors of GetObject and PutObject respectively.
+	// If nil, err is used instead.
+	getErr error
+	putErr error
+
+	// putInput and putOptFns record the last call of PutObject.
+	putInput  *s3.PutObjectInput
+	putOptFns int
 }
 
 // PutObjectWithContext returns a mocked response.
-func (c *mockClient) PutObject(_ context.Context, _ *s3.PutObjectInput, _ ...func(*s3.Options)) (*s3.PutObjectOutput, error) {
+func (c *mockClient) PutObject(_ context.Context, params *s3.PutObjectInput, optFns ...func(*s3.Options)) (*s3.PutObjectOutput, error) {
+	c.putInput = params
+	c.putOptFns = len(optFns)
+	if c.putErr != nil {
+		return nil, c.putErr
+	}
 	return c.putOutput, c.err
 }
 
 // GetObjectWithContext returns a mocked response.
 func (c *mockClient) GetObject(_ context.Context, _ *s3.GetObjectInput, _ ...func(*s3.Options)) (*s3.GetObjectOutput, error) {
+	if c.getErr != nil {
+		return nil, c.getErr
+	}
 	return c.getOutput, c.err
 }
 
@@ -154,3 +173,98 @@ func TestStorageRead(t *testing.T) {
 		})
 	}
 }
+
+func TestStorageConditionalWrite(t *testing.T) {
+	cases := []struct {
+		desc             string
+		conditionalWrite bool
+		client           *mockClient
+		wantIfMatch      bool
+		wantIfNoneMatch  bool
+		wantConflict     bool
+		ok               bool
+	}{
+		{
+			desc:             "disabled",
+			conditionalWrite: false,
+			client: &mockClient{
+				getOutput: &s3.GetObjectOutput{
+					Body: io.NopCloser(strings.NewReader("foo")),
+					ETag: aws.String(`"etag1"`),
+				},
+				putOutput: &s3.PutObjectOutput{},
+			},
+			ok: true,
+		},
+		{
+			desc:             "if match",
+			conditionalWrite: true,
+			client: &mockClient{
+				getOutput: &s3.GetObjectOutput{
+					Body: io.NopCloser(strings.NewReader("foo")),
+					ETag: aws.String(`"etag1"`),
+				},
+				putOutput: &s3.PutObjectOutput{ETag: aws.String(`"etag2"`)},
+			},
+			wantIfMatch: true,
+			ok:          true,
+		},
+		{
+			desc:             "if none match",
+			conditionalWrite: true,
+			client: &mockClient{
+				getErr:    &types.NoSuchKey{Message: aws.String("The specified key does not exist.")},
+				putOutput: &s3.PutObjectOutput{ETag: aws.String(`"etag1"`)},
+			},
+			wantIfNoneMatch: true,
+			ok:              true,
+		},
+		{
+			desc:             "conflict",
+			conditionalWrite: true,
+			client: &mockClient{
+				getOutput: &s3.GetObjectOutput{
+					Body: io.NopCloser(strings.NewReader("foo")),
+					ETag: aws.String(`"etag1"`),
+				},
+				putErr: &smithy.GenericAPIError{Code: "PreconditionFailed", Message: "At least one of the pre-conditions you specified did not hold"},
+			},
+			wantIfMatch:  true,
+			wantConflict: true,
+			ok:           false,
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.desc, func(t *testing.T) {
+			config := &Config{
+				Bucket:           "tfmigrate-test",
+				Key:              "tfmigrate/history.json",
+				ConditionalWrite: tc.conditionalWrite,
+			}
+			s, err := NewStorage(config, tc.client)
+			if err != nil {
+				t.Fatalf("failed to NewStorage: %s", err)
+			}
+			if _, err := s.Read(context.Background()); err != nil {
+				t.Fatalf("failed to read: %s", err)
+			}
+			err = s.Write(context.Background(), []byte("bar"))
+			if tc.ok && err != nil {
+				t.Fatalf("unexpected err: %s", err)
+			}
+			if !tc.ok && err == nil {
+				t.Fatal("expected to return an error, but no error")
+			}
+			if got := errors.Is(err, storage.ErrConflict); got != tc.wantConflict {
+				t.Errorf("got conflict: %t, want: %t, err: %v", got, tc.wantConflict, err)
+			}
+			if got := tc.client.putOptFns == 1; got != tc.wantIfMatch {
+				t.Errorf("got If-Match: %t, want: %t", got, tc.wantIfMatch)
+			}
+			if got := tc.client.putInput.IfNoneMatch != nil; got != tc.wantIfNoneMatch {
+				t.Errorf("got If-None-Match: %t, want: %t", got, tc.wantIfNoneMatch)
+			}
+		})
+	}
+}
@@ -1,17 +1,43 @@
 package storage
 
-import "context"
+import (
+	"context"
+	"errors"
+)
 
 // Storage is an abstraction layer for migration history data store.
 // As you know, this is the equivalent of Terraform's backend, but we have
 // implemented it by ourselves not to depend on Terraform internals directly.
 // To support multiple cloud storages, write and read operations are limited to
 // simple byte operations and a domain specific logic should not be included.
+//
+// A custom implementation can be plugged in when embedding tfmigrate, and it
+// must satisfy the following contract:
+//   - The data is an opaque blob of a whole history file. Read must return
+//     exactly the bytes of the last successful Write.
+//   - If no history exists yet, such as on the first run, Read must return
+//     empty bytes and a nil error, which is treated as an empty history.
+//     Returning an error instead, such as a not found error of the underlying
+//     store, fails the command.
+//   - Write replaces the whole data, not appends to it. It's called once at
+//     the end of apply if any migration has been applied, so it should be
+//     atomic. A partially written history cannot be parsed on the next Read.
+//   - tfmigrate doesn't lock the storage. Running concurrently against the
+//     same storage is not supported unless the implementation handles it.
+//     A Write follows a Read on the same instance, so that an implementation
+//     can remember a version of the data at Read and write it conditionally.
+//     It should return an error wrapping ErrConflict if the data has been
+//     modified since it was read.
+//   - An error of Read or Write fails the command.
 type Storage interface {
 	// Write writes migration history data to storage.
 	Write(ctx context.Context, b []byte) error
 	// Read reads migration history data from storage.
-	// If the key does not exist, it is assumed to be uninitialized and returns
-	// an empty array instead of an error.
+	// If the key does not exist, it is assumed to be uninitialized and must
+	// return empty bytes and a nil error instead of an error.
 	Read(ctx context.Context) ([]byte, error)
 }
+
+// ErrConflict is an error returned by Write if the data has been modified
+// by someone else since it was read.
+var ErrConflict = errors.New("the history has been modified concurrently since it was read")
@@ -0,0 +1,59 @@
+package tfexec
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// defaultDataDir is a default directory where terraform init writes its
+// working data such as the backend configuration.
+const defaultDataDir = ".terraform"
+
+// backendStateFile is a file name of the backend configuration written by
+// terraform init in the data dir.
+const backendStateFile = "terraform.tfstate"
+
+// DetectBackendType returns a type of backend configured in a given working
+// directory, such as s3, gcs, consul, etcdv3 or http.
+// It reads the backend configuration written by terraform init, so it should
+// be called after init. The data dir can be overridden by the TF_DATA_DIR
+// environment variable as well as terraform.
+// It returns `local` if no backend is configured.
+func DetectBackendType(dir string) (string, error) {
+	b, err := os.ReadFile(filepath.Join(resolveDataDir(dir), backendStateFile))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return "local", nil
+		}
+		return "", fmt.Errorf("failed to read the backend configuration: %s", err)
+	}
+
+	var f struct {
+		Backend *struct {
+			Type string `json:"type"`
+		} `json:"backend"`
+	}
+	if err := json.Unmarshal(b, &f); err != nil {
+		return "", fmt.Errorf("failed to parse the backend configuration: %s", err)
+	}
+	if f.Backend == nil || len(f.Backend.Type) == 0 {
+		return "local", nil
+	}
+	return f.Backend.Type, nil
+}
+
+// resolveDataDir returns a path of the data dir of terraform in a given
+// working directory. It can be overridden by the TF_DATA_DIR environment
+// variable as well as terraform.
+func resolveDataDir(dir string) string {
+	dataDir := os.Getenv("TF_DATA_DIR")
+	if len(dataDir) == 0 {
+		dataDir = defaultDataDir
+	}
+	if !filepath.IsAbs(dataDir) {
+		dataDir = filepath.Join(dir, dataDir)
+	}
+	return dataDir
+}
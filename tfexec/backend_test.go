@@ -0,0 +1,98 @@
+package tfexec
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestDetectBackendType(t *testing.T) {
+	cases := []struct {
+		desc    string
+		dataDir string
+		content string
+		want    string
+		ok      bool
+	}{
+		{
+			desc:    "consul",
+			dataDir: ".terraform",
+			content: `{"version": 3, "serial": 1, "backend": {"type": "consul", "config": {"path": "tfstate/foo"}, "hash": 1}}`,
+			want:    "consul",
+			ok:      true,
+		},
+		{
+			desc:    "http",
+			dataDir: ".terraform",
+			content: `{"version": 3, "serial": 1, "backend": {"type": "http", "config": {}, "hash": 1}}`,
+			want:    "http",
+			ok:      true,
+		},
+		{
+			desc:    "not initialized",
+			dataDir: "",
+			want:    "local",
+			ok:      true,
+		},
+		{
+			desc:    "no backend",
+			dataDir: ".terraform",
+			content: `{"version": 3, "serial": 1}`,
+			want:    "local",
+			ok:      true,
+		},
+		{
+			desc:    "broken",
+			dataDir: ".terraform",
+			content: `{`,
+			want:    "",
+			ok:      false,
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.desc, func(t *testing.T) {
+			dir := t.TempDir()
+			if len(tc.dataDir) != 0 {
+				if err := os.MkdirAll(filepath.Join(dir, tc.dataDir), 0755); err != nil {
+					t.Fatalf("failed to create data dir: %s", err)
+				}
+				if err := os.WriteFile(filepath.Join(dir, tc.dataDir, "terraform.tfstate"), []byte(tc.content), 0600); err != nil {
+					t.Fatalf("failed to write backend configuration: %s", err)
+				}
+			}
+
+			got, err := DetectBackendType(dir)
+			if tc.ok && err != nil {
+				t.Fatalf("unexpected err: %s", err)
+			}
+			if !tc.ok && err == nil {
+				t.Fatalf("expected to return an error, but no error, got: %s", got)
+			}
+			if got != tc.want {
+				t.Errorf("got: %s, want: %s", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestDetectBackendTypeWithDataDir(t *testing.T) {
+	dir := t.TempDir()
+	dataDir := filepath.Join(dir, "data")
+	if err := os.MkdirAll(dataDir, 0755); err != nil {
+		t.Fatalf("failed to create data dir: %s", err)
+	}
+	content := `{"version": 3, "serial": 1, "backend": {"type": "etcdv3", "config": {}, "hash": 1}}`
+	if err := os.WriteFile(filepath.Join(dataDir, "terraform.tfstate"), []byte(content), 0600); err != nil {
+		t.Fatalf("failed to write backend configuration: %s", err)
+	}
+	t.Setenv("TF_DATA_DIR", "data")
+
+	got, err := DetectBackendType(dir)
+	if err != nil {
+		t.Fatalf("unexpected err: %s", err)
+	}
+	if got != "etcdv3" {
+		t.Errorf("got: %s, want: etcdv3", got)
+	}
+}
@@ -2,6 +2,8 @@ package tfexec
 
 import (
 	"bytes"
+	"context"
+	"io"
 	"os/exec"
 )
 
@@ -28,6 +30,8 @@ type command struct {
 	stdout *bytes.Buffer
 	// stderr is a buffer for stderr.
 	stderr *bytes.Buffer
+	// outStream is a stream where the stdout is copied. It's nil if not set.
+	outStream io.Writer
 }
 
 var _ Command = (*command)(nil)
@@ -51,3 +55,33 @@ func (c *command) Stderr() string {
 func (c *command) Args() []string {
 	return c.osExecCmd.Args
 }
+
+// stdoutRedirector is implemented by commands whose stdout can be written to
+// a given writer instead of being captured in memory.
+type stdoutRedirector interface {
+	// redirectStdout writes the stdout to a given writer instead of capturing.
+	redirectStdout(w io.Writer)
+}
+
+var _ stdoutRedirector = (*command)(nil)
+
+// redirectStdout writes the stdout to a given writer instead of capturing it.
+// Note that the stdout is still copied to the output stream if set.
+func (c *command) redirectStdout(w io.Writer) {
+	if c.outStream == nil {
+		c.osExecCmd.Stdout = w
+		return
+	}
+	c.osExecCmd.Stdout = io.MultiWriter(w, c.outStream)
+}
+
+// stdoutWriterKey is a context key for a writer where the stdout of a command
+// is written instead of being captured in memory.
+type stdoutWriterKey struct{}
+
+// withStdoutWriter returns a new context which tells Run to write the stdout
+// of a command to a given writer. If the command doesn't support it, the
+// stdout is captured as usual.
+func withStdoutWriter(ctx context.Context, w io.Writer) context.Context {
+	return context.WithValue(ctx, stdoutWriterKey{}, w)
+}
@@ -0,0 +1,102 @@
+package tfexec
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"regexp"
+	"strings"
+)
+
+// Diagnostic is a warning or an error reported by terraform.
+// It's a part of the machine-readable UI output of terraform with the -json
+// option. We intentionally parse only a few attributes we need.
+// https://developer.hashicorp.com/terraform/internals/machine-readable-ui#diagnostic
+type Diagnostic struct {
+	// Severity is either "warning" or "error".
+	Severity string `json:"severity"`
+	// Summary is a short description of the diagnostic.
+	Summary string `json:"summary"`
+	// Detail is a detailed description of the diagnostic.
+	Detail string `json:"detail"`
+	// Address is an address of the resource related to the diagnostic if any.
+	Address string `json:"address"`
+}
+
+// uiMessage is a line of the machine-readable UI output.
+type uiMessage struct {
+	// Type is a type of the message such as "diagnostic".
+	Type string `json:"type"`
+	// Diagnostic is set if the type is "diagnostic".
+	Diagnostic *Diagnostic `json:"diagnostic"`
+}
+
+// ParseDiagnostics parses diagnostics from the machine-readable UI output of
+// terraform, which is a stream of JSON objects separated by newlines.
+// Lines which are not diagnostics or not valid JSON are ignored.
+func ParseDiagnostics(b []byte) []Diagnostic {
+	diags := []Diagnostic{}
+	scanner := bufio.NewScanner(bytes.NewReader(b))
+	// A diagnostic can contain a long detail.
+	scanner.Buffer(make([]byte, 0, 64*1024), 10*1024*1024)
+	for scanner.Scan() {
+		var m uiMessage
+		if err := json.Unmarshal(scanner.Bytes(), &m); err != nil {
+			continue
+		}
+		if m.Type != "diagnostic" || m.Diagnostic == nil {
+			continue
+		}
+		diags = append(diags, *m.Diagnostic)
+	}
+	return diags
+}
+
+// textDiagnosticRegex matches the first line of a diagnostic in the
+// human-readable output of terraform, such as `Warning: summary`.
+var textDiagnosticRegex = regexp.MustCompile(`^(Warning|Error): (.+)$`)
+
+// ansiEscapeRegex matches an ANSI escape sequence for colored output.
+var ansiEscapeRegex = regexp.MustCompile("\x1b\\[[0-9;]*m")
+
+// ParseTextDiagnostics parses diagnostics from the human-readable output of
+// terraform for commands which don't support the -json option, such as
+// terraform state mv. Both the boxed format of Terraform v0.15+ and the plain
+// format of older versions are supported. A detail is the following lines up
+// to the end of the box or the next diagnostic. The address is not available.
+func ParseTextDiagnostics(s string) []Diagnostic {
+	diags := []Diagnostic{}
+	var current *Diagnostic
+	detail := []string{}
+	flush := func() {
+		if current == nil {
+			return
+		}
+		current.Detail = strings.TrimSpace(strings.Join(detail, "\n"))
+		diags = append(diags, *current)
+		current = nil
+		detail = []string{}
+	}
+
+	for _, line := range strings.Split(ansiEscapeRegex.ReplaceAllString(s, ""), "\n") {
+		line = strings.TrimRight(line, " \r")
+		if strings.HasPrefix(line, "╷") || strings.HasPrefix(line, "╵") {
+			flush()
+			continue
+		}
+		line = strings.TrimPrefix(strings.TrimPrefix(line, "│"), " ")
+		if matched := textDiagnosticRegex.FindStringSubmatch(line); matched != nil {
+			flush()
+			current = &Diagnostic{
+				Severity: strings.ToLower(matched[1]),
+				Summary:  matched[2],
+			}
+			continue
+		}
+		if current != nil {
+			detail = append(detail, line)
+		}
+	}
+	flush()
+	return diags
+}
@@ -0,0 +1,265 @@
+package tfexec
+
+import (
+	"context"
+	"os"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestParseDiagnostics(t *testing.T) {
+	cases := []struct {
+		desc string
+		out  string
+		want []Diagnostic
+	}{
+		{
+			desc: "warnings and errors",
+			out: `{"@level":"info","@message":"Terraform 1.5.7","type":"version","terraform":"1.5.7","ui":"1.1"}
+{"@level":"warn","@message":"Warning: Argument is deprecated","type":"diagnostic","diagnostic":{"severity":"warning","summary":"Argument is deprecated","detail":"Use foo instead.","address":"aws_s3_bucket.foo"}}
+{"@level":"error","@message":"Error: Invalid reference","type":"diagnostic","diagnostic":{"severity":"error","summary":"Invalid reference","detail":""}}
+{"@level":"info","@message":"Plan: 0 to add, 0 to change, 0 to destroy.","type":"change_summary","changes":{"add":0,"change":0,"remove":0,"operation":"plan"}}
+`,
+			want: []Diagnostic{
+				{
+					Severity: "warning",
+					Summary:  "Argument is deprecated",
+					Detail:   "Use foo instead.",
+					Address:  "aws_s3_bucket.foo",
+				},
+				{
+					Severity: "error",
+					Summary:  "Invalid reference",
+				},
+			},
+		},
+		{
+			desc: "no diagnostics",
+			out: `{"@level":"info","@message":"Terraform 1.5.7","type":"version","terraform":"1.5.7","ui":"1.1"}
+`,
+			want: []Diagnostic{},
+		},
+		{
+			desc: "invalid lines are ignored",
+			out: `foo
+{"@level":"warn","@message":"Warning: bar","type":"diagnostic","diagnostic":{"severity":"warning","summary":"bar","detail":""}}
+`,
+			want: []Diagnostic{
+				{
+					Severity: "warning",
+					Summary:  "bar",
+				},
+			},
+		},
+		{
+			desc: "empty",
+			out:  "",
+			want: []Diagnostic{},
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.desc, func(t *testing.T) {
+			got := ParseDiagnostics([]byte(tc.out))
+			if diff := cmp.Diff(got, tc.want); diff != "" {
+				t.Errorf("got: %#v, want: %#v, diff: %s", got, tc.want, diff)
+			}
+		})
+	}
+}
+
+func TestTerraformCLIPlanWithDiagnostics(t *testing.T) {
+	// mock writing plan to a temporary file.
+	plan := NewPlan([]byte("dummy plan"))
+	runFunc := func(args ...string) error {
+		for _, arg := range args {
+			if strings.HasPrefix(arg, "-out=") {
+				planFile := arg[len("-out="):]
+				return os.WriteFile(planFile, plan.Bytes(), 0600)
+			}
+		}
+		return nil
+	}
+	jsonStdout := `{"@level":"warn","@message":"Warning: foo","type":"diagnostic","diagnostic":{"severity":"warning","summary":"foo","detail":"","address":"null_resource.foo"}}
+`
+	stdout := "No changes. Your infrastructure matches the configuration.\n" +
+		"╷\n" +
+		"│ Warning: foo\n" +
+		"│ \n" +
+		"│ bar\n" +
+		"╵\n"
+	stderr := "╷\n" +
+		"│ Error: baz\n" +
+		"╵\n"
+
+	cases := []struct {
+		desc         string
+		mockCommands []*mockCommand
+		opts         []string
+		want         []Diagnostic
+		ok           bool
+	}{
+		{
+			desc: "human-readable output",
+			mockCommands: []*mockCommand{
+				{
+					args:     []string{"terraform", "plan", "-out=/path/to/planfile", "-input=false"},
+					argsRe:   regexp.MustCompile(`^terraform plan -out=.+ -input=false$`),
+					runFunc:  runFunc,
+					stdout:   stdout,
+					exitCode: 0,
+				},
+			},
+			opts: []string{"-input=false"},
+			want: []Diagnostic{{Severity: "warning", Summary: "foo", Detail: "bar"}},
+			ok:   true,
+		},
+		{
+			desc: "-json is set",
+			mockCommands: []*mockCommand{
+				{
+					args:     []string{"terraform", "plan", "-out=/path/to/planfile", "-json"},
+					argsRe:   regexp.MustCompile(`^terraform plan -out=.+ -json$`),
+					runFunc:  runFunc,
+					stdout:   jsonStdout,
+					exitCode: 0,
+				},
+			},
+			opts: []string{"-json"},
+			want: []Diagnostic{{Severity: "warning", Summary: "foo", Address: "null_resource.foo"}},
+			ok:   true,
+		},
+		{
+			desc: "diagnostics are returned even if failed",
+			mockCommands: []*mockCommand{
+				{
+					args:     []string{"terraform", "plan", "-out=/path/to/planfile", "-detailed-exitcode"},
+					argsRe:   regexp.MustCompile(`^terraform plan -out=.+ -detailed-exitcode$`),
+					runFunc:  runFunc,
+					stdout:   stdout,
+					stderr:   stderr,
+					exitCode: 1,
+				},
+			},
+			opts: []string{"-detailed-exitcode"},
+			want: []Diagnostic{{Severity: "warning", Summary: "foo", Detail: "bar"}, {Severity: "error", Summary: "baz"}},
+			ok:   false,
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.desc, func(t *testing.T) {
+			e := NewMockExecutor(tc.mockCommands)
+			terraformCLI := NewTerraformCLI(e)
+			terraformCLI.SetExecPath("terraform")
+			_, got, err := terraformCLI.PlanWithDiagnostics(context.Background(), nil, tc.opts...)
+			if tc.ok && err != nil {
+				t.Fatalf("unexpected err: %s", err)
+			}
+			if !tc.ok && err == nil {
+				t.Fatal("expected to return an error, but no error")
+			}
+			if diff := cmp.Diff(got, tc.want); diff != "" {
+				t.Errorf("got: %#v, want: %#v, diff: %s", got, tc.want, diff)
+			}
+		})
+	}
+}
+
+func TestParseTextDiagnostics(t *testing.T) {
+	cases := []struct {
+		desc string
+		out  string
+		want []Diagnostic
+	}{
+		{
+			desc: "boxed",
+			out: "Move \"null_resource.foo\" to \"module.foo.null_resource.foo\"\n" +
+				"Successfully moved 1 object(s).\n" +
+				"\n" +
+				"\x1b[33m╷\x1b[0m\n" +
+				"\x1b[33m│\x1b[0m \x1b[1m\x1b[33mWarning: \x1b[0m\x1b[0m\x1b[1mMissing provider configuration\x1b[0m\n" +
+				"\x1b[33m│\x1b[0m\n" +
+				"\x1b[33m│\x1b[0m The provider configuration for module.foo is not found.\n" +
+				"\x1b[33m│\x1b[0m Add a provider block.\n" +
+				"\x1b[33m╵\x1b[0m\n" +
+				"╷\n" +
+				"│ Error: Invalid target address\n" +
+				"╵\n",
+			want: []Diagnostic{
+				{
+					Severity: "warning",
+					Summary:  "Missing provider configuration",
+					Detail:   "The provider configuration for module.foo is not found.\nAdd a provider block.",
+				},
+				{
+					Severity: "error",
+					Summary:  "Invalid target address",
+				},
+			},
+		},
+		{
+			desc: "plain",
+			out: `
+Warning: Missing provider configuration
+
+The provider configuration for module.foo is not found.
+
+Warning: Deprecated
+`,
+			want: []Diagnostic{
+				{
+					Severity: "warning",
+					Summary:  "Missing provider configuration",
+					Detail:   "The provider configuration for module.foo is not found.",
+				},
+				{
+					Severity: "warning",
+					Summary:  "Deprecated",
+				},
+			},
+		},
+		{
+			desc: "no diagnostics",
+			out: `Move "null_resource.foo" to "null_resource.bar"
+Successfully moved 1 object(s).
+`,
+			want: []Diagnostic{},
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.desc, func(t *testing.T) {
+			got := ParseTextDiagnostics(tc.out)
+			if diff := cmp.Diff(got, tc.want); diff != "" {
+				t.Errorf("got: %#v, want: %#v, diff: %s", got, tc.want, diff)
+			}
+		})
+	}
+}
+
+func TestTerraformCLIStateMvWithDiagnostics(t *testing.T) {
+	mockCommands := []*mockCommand{
+		{
+			args:     []string{"terraform", "state", "mv", "null_resource.foo", "module.foo.null_resource.foo"},
+			argsRe:   regexp.MustCompile(`^terraform state mv null_resource.foo module.foo.null_resource.foo$`),
+			stdout:   "Successfully moved 1 object(s).\n",
+			stderr:   "╷\n│ Warning: Missing provider configuration\n│\n│ foo\n╵\n",
+			exitCode: 0,
+		},
+	}
+	e := NewMockExecutor(mockCommands)
+	terraformCLI := NewTerraformCLI(e)
+	terraformCLI.SetExecPath("terraform")
+	_, _, got, err := terraformCLI.StateMvWithDiagnostics(context.Background(), nil, nil, "null_resource.foo", "module.foo.null_resource.foo")
+	if err != nil {
+		t.Fatalf("unexpected err: %s", err)
+	}
+	want := []Diagnostic{{Severity: "warning", Summary: "Missing provider configuration", Detail: "foo"}}
+	if diff := cmp.Diff(got, want); diff != "" {
+		t.Errorf("got: %#v, want: %#v, diff: %s", got, want, diff)
+	}
+}
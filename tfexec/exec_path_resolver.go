@@ -0,0 +1,48 @@
+package tfexec
+
+import (
+	"fmt"
+	"path/filepath"
+)
+
+// ExecPathRule maps a working directory to a terraform binary.
+type ExecPathRule struct {
+	// Pattern is a glob pattern of a working directory.
+	// The syntax is the same as filepath.Match.
+	Pattern string
+	// ExecPath is a string how terraform command is executed.
+	ExecPath string
+}
+
+// ExecPathResolver selects a terraform binary based on a working directory.
+// It's intended to be used in a monorepo where directories are pinned to
+// different terraform binaries.
+type ExecPathResolver struct {
+	// Rules is a list of rules. The first matched rule is used.
+	Rules []ExecPathRule
+	// DefaultExecPath is used when no rule matches a working directory.
+	DefaultExecPath string
+}
+
+// Resolve returns an exec path for a given working directory.
+// Both the directory and patterns are compared after being cleaned, so
+// `./foo` and `foo` are the same. It returns an error if no rule matches
+// the directory and the default is not set.
+func (r *ExecPathResolver) Resolve(dir string) (string, error) {
+	cleanDir := filepath.Clean(dir)
+	for _, rule := range r.Rules {
+		matched, err := filepath.Match(filepath.Clean(rule.Pattern), cleanDir)
+		if err != nil {
+			return "", fmt.Errorf("invalid pattern of terraform binary for dir: %s, err: %s", rule.Pattern, err)
+		}
+		if matched {
+			return rule.ExecPath, nil
+		}
+	}
+
+	if len(r.DefaultExecPath) == 0 {
+		return "", fmt.Errorf("no terraform binary matches dir: %s. Add a dir block or set default_exec_path in the terraform block", dir)
+	}
+
+	return r.DefaultExecPath, nil
+}
@@ -0,0 +1,99 @@
+package tfexec
+
+import "testing"
+
+func TestExecPathResolverResolve(t *testing.T) {
+	cases := []struct {
+		desc     string
+		resolver *ExecPathResolver
+		dir      string
+		want     string
+		ok       bool
+	}{
+		{
+			desc: "exact match",
+			resolver: &ExecPathResolver{
+				Rules: []ExecPathRule{
+					{Pattern: "legacy/foo", ExecPath: "/opt/terraform/0.12/terraform"},
+				},
+				DefaultExecPath: "terraform",
+			},
+			dir:  "legacy/foo",
+			want: "/opt/terraform/0.12/terraform",
+			ok:   true,
+		},
+		{
+			desc: "glob match",
+			resolver: &ExecPathResolver{
+				Rules: []ExecPathRule{
+					{Pattern: "legacy/*", ExecPath: "/opt/terraform/0.12/terraform"},
+				},
+				DefaultExecPath: "terraform",
+			},
+			dir:  "./legacy/foo",
+			want: "/opt/terraform/0.12/terraform",
+			ok:   true,
+		},
+		{
+			desc: "first match wins",
+			resolver: &ExecPathResolver{
+				Rules: []ExecPathRule{
+					{Pattern: "legacy/bar", ExecPath: "/opt/terraform/0.13/terraform"},
+					{Pattern: "legacy/*", ExecPath: "/opt/terraform/0.12/terraform"},
+				},
+				DefaultExecPath: "terraform",
+			},
+			dir:  "legacy/bar",
+			want: "/opt/terraform/0.13/terraform",
+			ok:   true,
+		},
+		{
+			desc: "fallback to default",
+			resolver: &ExecPathResolver{
+				Rules: []ExecPathRule{
+					{Pattern: "legacy/*", ExecPath: "/opt/terraform/0.12/terraform"},
+				},
+				DefaultExecPath: "tofu",
+			},
+			dir:  "modern/foo",
+			want: "tofu",
+			ok:   true,
+		},
+		{
+			desc: "no match without default",
+			resolver: &ExecPathResolver{
+				Rules: []ExecPathRule{
+					{Pattern: "legacy/*", ExecPath: "/opt/terraform/0.12/terraform"},
+				},
+			},
+			dir: "modern/foo",
+			ok:  false,
+		},
+		{
+			desc: "invalid pattern",
+			resolver: &ExecPathResolver{
+				Rules: []ExecPathRule{
+					{Pattern: "legacy/[", ExecPath: "/opt/terraform/0.12/terraform"},
+				},
+				DefaultExecPath: "terraform",
+			},
+			dir: "legacy/foo",
+			ok:  false,
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.desc, func(t *testing.T) {
+			got, err := tc.resolver.Resolve(tc.dir)
+			if tc.ok && err != nil {
+				t.Fatalf("unexpected err: %s", err)
+			}
+			if !tc.ok && err == nil {
+				t.Fatalf("expected to return an error, but no error, got: %s", got)
+			}
+			if tc.ok && got != tc.want {
+				t.Errorf("got: %s, want: %s", got, tc.want)
+			}
+		})
+	}
+}
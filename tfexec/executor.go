@@ -3,15 +3,21 @@ package tfexec
 import (
 	"bytes"
 	"context"
+	"fmt"
 	"io"
 	"log"
 	"os"
 	"os/exec"
 	"strings"
+	"time"
 
 	"github.com/davecgh/go-spew/spew"
 )
 
+// commandWaitDelay is a duration to wait for a command to exit after it has
+// been interrupted on cancellation of the context, before it's killed.
+const commandWaitDelay = 30 * time.Second
+
 // Executor abstracts the os command execution layer.
 type Executor interface {
 	// NewCommandContext builds and returns an instance of Command.
@@ -22,14 +28,25 @@ type Executor interface {
 	Dir() string
 	// AppendEnv appends an environment variable.
 	AppendEnv(key string, value string)
+	// SetOutput sets writers where the stdout and stderr of commands are
+	// copied while running, in addition to being captured.
+	SetOutput(stdout io.Writer, stderr io.Writer)
+	// SetCommandLog sets a writer where each command line is written before
+	// execution, in addition to the DEBUG log.
+	SetCommandLog(w io.Writer)
 }
 
 // executor implements the Executor interface.
 type executor struct {
-	// outStream is the stdout stream.
+	// outStream is a stream where the stdout of a command is copied.
+	// If nil, the stdout is only captured.
 	outStream io.Writer
-	// errStream is the stderr stream.
+	// errStream is a stream where the stderr of a command is copied.
+	// If nil, the stderr is only captured.
 	errStream io.Writer
+	// commandLog is a stream where each command line is written before
+	// execution. If nil, the command line is only logged at DEBUG level.
+	commandLog io.Writer
 
 	// a working directory where a command is executed.
 	dir string
@@ -42,20 +59,28 @@ var _ Executor = (*executor)(nil)
 // NewExecutor returns a default executor for real environments.
 func NewExecutor(dir string, env []string) Executor {
 	return &executor{
-		outStream: os.Stdout,
-		errStream: os.Stderr,
-		dir:       dir,
-		env:       env,
+		dir: dir,
+		env: env,
 	}
 }
 
 // NewCommandContext builds and returns an instance of Command.
 func (e *executor) NewCommandContext(ctx context.Context, name string, args ...string) (Command, error) {
 	osExecCmd := exec.CommandContext(ctx, name, args...)
+	// Interrupt the command instead of killing it on cancellation, so that
+	// terraform can shut down gracefully, such as finishing a state push and
+	// releasing the state lock. It's killed if it doesn't exit in time.
+	osExecCmd.Cancel = func() error {
+		if err := osExecCmd.Process.Signal(os.Interrupt); err != nil {
+			return osExecCmd.Process.Kill()
+		}
+		return nil
+	}
+	osExecCmd.WaitDelay = commandWaitDelay
 	stdout := &bytes.Buffer{}
 	stderr := &bytes.Buffer{}
-	osExecCmd.Stdout = stdout
-	osExecCmd.Stderr = stderr
+	osExecCmd.Stdout = teeWriter(stdout, e.outStream)
+	osExecCmd.Stderr = teeWriter(stderr, e.errStream)
 	osExecCmd.Dir = e.dir
 	osExecCmd.Env = e.env
 
@@ -63,12 +88,19 @@ func (e *executor) NewCommandContext(ctx context.Context, name string, args ...s
 		osExecCmd: osExecCmd,
 		stdout:    stdout,
 		stderr:    stderr,
+		outStream: e.outStream,
 	}, nil
 }
 
 // Run executes a command.
 func (e *executor) Run(cmd Command) error {
-	log.Printf("[DEBUG] [executor@%s]$ %s", e.dir, strings.Join(cmd.Args(), " "))
+	line := fmt.Sprintf("[DEBUG] [executor@%s]$ %s", e.dir, strings.Join(cmd.Args(), " "))
+	log.Print(line)
+	if e.commandLog != nil {
+		if _, err := fmt.Fprintln(e.commandLog, line); err != nil {
+			log.Printf("[ERROR] [executor@%s] failed to write command log: %s", e.dir, err)
+		}
+	}
 	err := cmd.Run()
 	log.Printf("[TRACE] [executor@%s] cmd=%s ", e.dir, spew.Sdump(cmd))
 	if err != nil {
@@ -93,3 +125,26 @@ func (e *executor) Dir() string {
 func (e *executor) AppendEnv(key string, value string) {
 	e.env = append(e.env, key+"="+value)
 }
+
+// SetOutput sets writers where the stdout and stderr of commands are copied
+// while running, in addition to being captured. A nil writer disables copying.
+func (e *executor) SetOutput(stdout io.Writer, stderr io.Writer) {
+	e.outStream = stdout
+	e.errStream = stderr
+}
+
+// SetCommandLog sets a writer where each command line is written before
+// execution. It's intended for showing terraform commands to users without
+// changing the log level. A nil writer disables it.
+func (e *executor) SetCommandLog(w io.Writer) {
+	e.commandLog = w
+}
+
+// teeWriter returns a writer which writes to a given buffer and a stream.
+// If the stream is nil, it returns the buffer as it is.
+func teeWriter(buf *bytes.Buffer, stream io.Writer) io.Writer {
+	if stream == nil {
+		return buf
+	}
+	return io.MultiWriter(buf, stream)
+}
@@ -1,11 +1,15 @@
 package tfexec
 
 import (
+	"bytes"
 	"context"
 	"fmt"
 	"os"
+	"os/signal"
+	"runtime"
 	"strings"
 	"testing"
+	"time"
 )
 
 // mock functions
@@ -20,11 +24,24 @@ func mockFalse(_ ...string) int {
 	return 1
 }
 
+func mockInterruptible(_ ...string) int {
+	c := make(chan os.Signal, 1)
+	signal.Notify(c, os.Interrupt)
+	select {
+	case <-c:
+		fmt.Println("interrupted")
+		return 130
+	case <-time.After(10 * time.Second):
+		return 0
+	}
+}
+
 // TestMain customizes initialization of tests for mock.
 func TestMain(m *testing.M) {
 	mockFunctions := map[string]mockFunc{
-		"echo":  mockEcho,
-		"false": mockFalse,
+		"echo":          mockEcho,
+		"false":         mockFalse,
+		"interruptible": mockInterruptible,
 	}
 
 	// if test is called with mock mode, behave as a mock.
@@ -102,6 +119,99 @@ func TestExecutorRun(t *testing.T) {
 	}
 }
 
+func TestExecutorRunInterruptOnCancel(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("os.Interrupt is not supported on Windows")
+	}
+
+	e := NewExecutor(".", []string{"GO_MOCK_COMMAND=interruptible"})
+	ctx, cancel := context.WithTimeout(context.Background(), 500*time.Millisecond)
+	defer cancel()
+	cmd, err := e.NewCommandContext(ctx, os.Args[0])
+	if err != nil {
+		t.Fatalf("failed to NewCommandContext: %s", err)
+	}
+
+	err = e.Run(cmd)
+	if err == nil {
+		t.Fatal("expected to return an error, but no error")
+	}
+	if got, want := cmd.Stdout(), "interrupted\n"; got != want {
+		t.Errorf("expected the command to be interrupted. got: %s, want: %s", got, want)
+	}
+}
+
+func TestExecutorSetOutput(t *testing.T) {
+	e := NewExecutor(".", []string{"GO_MOCK_COMMAND=echo"})
+	var stdout, stderr bytes.Buffer
+	e.SetOutput(&stdout, &stderr)
+	cmd, err := e.NewCommandContext(context.Background(), os.Args[0], "foo", "bar")
+	if err != nil {
+		t.Fatalf("failed to NewCommandContext: %s", err)
+	}
+
+	err = e.Run(cmd)
+	if err != nil {
+		t.Fatalf("unexpected err: %s", err)
+	}
+	want := "foo bar\n"
+	if got := cmd.Stdout(); got != want {
+		t.Errorf("unexpected captured stdout. got: %s, want: %s", got, want)
+	}
+	if got := stdout.String(); got != want {
+		t.Errorf("unexpected copied stdout. got: %s, want: %s", got, want)
+	}
+	if got := stderr.String(); got != "" {
+		t.Errorf("unexpected copied stderr. got: %s, want: %s", got, "")
+	}
+}
+
+func TestCommandRedirectStdout(t *testing.T) {
+	e := NewExecutor(".", []string{"GO_MOCK_COMMAND=echo"})
+	var stream bytes.Buffer
+	e.SetOutput(&stream, nil)
+	cmd, err := e.NewCommandContext(context.Background(), os.Args[0], "foo", "bar")
+	if err != nil {
+		t.Fatalf("failed to NewCommandContext: %s", err)
+	}
+
+	var w bytes.Buffer
+	cmd.(stdoutRedirector).redirectStdout(&w)
+	err = e.Run(cmd)
+	if err != nil {
+		t.Fatalf("unexpected err: %s", err)
+	}
+	want := "foo bar\n"
+	if got := w.String(); got != want {
+		t.Errorf("unexpected redirected stdout. got: %s, want: %s", got, want)
+	}
+	if got := cmd.Stdout(); got != "" {
+		t.Errorf("unexpected captured stdout. got: %s, want: %s", got, "")
+	}
+	if got := stream.String(); got != want {
+		t.Errorf("unexpected copied stdout. got: %s, want: %s", got, want)
+	}
+}
+
+func TestExecutorSetCommandLog(t *testing.T) {
+	e := NewExecutor(".", []string{"GO_MOCK_COMMAND=echo"})
+	var commandLog bytes.Buffer
+	e.SetCommandLog(&commandLog)
+	cmd, err := e.NewCommandContext(context.Background(), os.Args[0], "foo", "bar")
+	if err != nil {
+		t.Fatalf("failed to NewCommandContext: %s", err)
+	}
+
+	err = e.Run(cmd)
+	if err != nil {
+		t.Fatalf("unexpected err: %s", err)
+	}
+	want := "[DEBUG] [executor@.]$ " + os.Args[0] + " foo bar\n"
+	if got := commandLog.String(); got != want {
+		t.Errorf("unexpected command log. got: %s, want: %s", got, want)
+	}
+}
+
 func TestExecutorEnv(t *testing.T) {
 	cases := []struct {
 		desc        string
@@ -0,0 +1,98 @@
+package tfexec
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// LockRetry is a setting of retries with backoff for terraform state pull and
+// push when the state is locked by another process.
+type LockRetry struct {
+	// Attempts is a maximum number of retries. Zero means no retry.
+	Attempts int
+	// Interval is an initial interval between retries, which doubles on every
+	// retry up to MaxInterval. Default to defaultLockRetryInterval.
+	Interval time.Duration
+	// MaxInterval is a maximum interval between retries.
+	// A zero value means no limit.
+	MaxInterval time.Duration
+}
+
+// defaultLockRetryInterval is a default initial interval between retries.
+const defaultLockRetryInterval = 5 * time.Second
+
+// stateLockErrorMessage is a part of an error message of terraform when it
+// fails to acquire the state lock. OpenTofu reports the same message.
+const stateLockErrorMessage = "Error acquiring the state lock"
+
+// lockInfoRe matches a line of the lock info reported with the state lock
+// error, such as `  Who:  foo@example.com`.
+var lockInfoRe = regexp.MustCompile(`(?m)^\s*(ID|Operation|Who|Created):\s+(\S.*?)\s*$`)
+
+// isStateLockError returns true if a given stderr of terraform says that it
+// failed to acquire the state lock.
+func isStateLockError(stderr string) bool {
+	return strings.Contains(stderr, stateLockErrorMessage)
+}
+
+// stateLockHolder returns who holds the state lock in a given stderr of
+// terraform in the form of `Who: foo, ID: bar, ...`.
+// It returns an empty string if the lock info is not reported.
+func stateLockHolder(stderr string) string {
+	info := []string{}
+	for _, m := range lockInfoRe.FindAllStringSubmatch(stderr, -1) {
+		info = append(info, m[1]+": "+m[2])
+	}
+	return strings.Join(info, ", ")
+}
+
+// runWithLockRetry runs an arbitrary terraform command with a given timeout,
+// and retries it with backoff if the state is locked by another process.
+// It gives up waiting if the context is canceled.
+func (c *terraformCLI) runWithLockRetry(ctx context.Context, timeout time.Duration, args ...string) (string, string, error) {
+	interval := c.lockRetry.Interval
+	if interval == 0 {
+		interval = defaultLockRetryInterval
+	}
+
+	for retries := 0; ; retries++ {
+		stdout, stderr, err := c.runWithTimeout(ctx, timeout, args...)
+		if err == nil || !isStateLockError(stderr) {
+			return stdout, stderr, err
+		}
+
+		holder := stateLockHolder(stderr)
+		if retries >= c.lockRetry.Attempts {
+			return stdout, stderr, stateLockError(err, holder, retries)
+		}
+
+		log.Printf("[WARN] [executor@%s] the state is locked by another process (%s), retry terraform %s in %s (%d/%d)\n", c.Dir(), holder, strings.Join(args[:2], " "), interval, retries+1, c.lockRetry.Attempts)
+		select {
+		case <-ctx.Done():
+			return stdout, stderr, fmt.Errorf("canceled while waiting for the state lock: %s", stateLockError(err, holder, retries))
+		case <-time.After(interval):
+		}
+
+		interval *= 2
+		if c.lockRetry.MaxInterval > 0 && interval > c.lockRetry.MaxInterval {
+			interval = c.lockRetry.MaxInterval
+		}
+	}
+}
+
+// stateLockError annotates a given error of the state lock with who holds the
+// lock and the number of retries.
+func stateLockError(err error, holder string, retries int) error {
+	msg := "the state is locked by another process"
+	if len(holder) > 0 {
+		msg += fmt.Sprintf(" (%s)", holder)
+	}
+	if retries > 0 {
+		msg += fmt.Sprintf(", gave up after %d retries", retries)
+	}
+	return fmt.Errorf("%s: %w", msg, err)
+}
@@ -0,0 +1,160 @@
+package tfexec
+
+import (
+	"context"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+)
+
+var stateLockStderr = `
+Error: Error acquiring the state lock
+
+Error message: ConditionalCheckFailedException: The conditional request failed
+Lock Info:
+  ID:        26abd3c6-e51c-d2b8-2b41-e9c7e8e8d2fe
+  Path:      tfstate-test/terraform.tfstate
+  Operation: OperationTypeApply
+  Who:       foo@example.com
+  Version:   1.9.8
+  Created:   2024-11-01 00:00:00.000000000 +0000 UTC
+  Info:
+
+
+Terraform acquires a state lock to protect the state from being written
+by multiple users at the same time. Please resolve the issue above and try
+again. For most commands, you can disable locking with the "-lock=false"
+flag, but this is not recommended.
+`
+
+func TestStateLockHolder(t *testing.T) {
+	cases := []struct {
+		desc   string
+		stderr string
+		want   string
+	}{
+		{
+			desc:   "lock info",
+			stderr: stateLockStderr,
+			want:   "ID: 26abd3c6-e51c-d2b8-2b41-e9c7e8e8d2fe, Operation: OperationTypeApply, Who: foo@example.com, Created: 2024-11-01 00:00:00.000000000 +0000 UTC",
+		},
+		{
+			desc:   "no lock info",
+			stderr: "Error: Error acquiring the state lock\n",
+			want:   "",
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.desc, func(t *testing.T) {
+			got := stateLockHolder(tc.stderr)
+			if got != tc.want {
+				t.Errorf("got: %s, want: %s", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestTerraformCLIStatePullLockRetry(t *testing.T) {
+	locked := func() *mockCommand {
+		return &mockCommand{
+			args:     []string{"terraform", "state", "pull"},
+			stderr:   stateLockStderr,
+			exitCode: 1,
+		}
+	}
+	pulled := &mockCommand{
+		args:     []string{"terraform", "state", "pull"},
+		stdout:   "dummy state",
+		exitCode: 0,
+	}
+
+	cases := []struct {
+		desc         string
+		mockCommands []*mockCommand
+		lockRetry    LockRetry
+		want         string
+		ok           bool
+	}{
+		{
+			desc:         "no retry",
+			mockCommands: []*mockCommand{locked()},
+			lockRetry:    LockRetry{},
+			want:         "the state is locked by another process (ID: 26abd3c6-e51c-d2b8-2b41-e9c7e8e8d2fe, Operation: OperationTypeApply, Who: foo@example.com, Created: 2024-11-01 00:00:00.000000000 +0000 UTC)",
+			ok:           false,
+		},
+		{
+			desc:         "unlocked after retries",
+			mockCommands: []*mockCommand{locked(), locked(), pulled},
+			lockRetry:    LockRetry{Attempts: 3, Interval: time.Millisecond, MaxInterval: 2 * time.Millisecond},
+			ok:           true,
+		},
+		{
+			desc:         "gave up",
+			mockCommands: []*mockCommand{locked(), locked(), locked()},
+			lockRetry:    LockRetry{Attempts: 2, Interval: time.Millisecond},
+			want:         "gave up after 2 retries",
+			ok:           false,
+		},
+		{
+			desc: "not a lock error",
+			mockCommands: []*mockCommand{
+				{
+					args:     []string{"terraform", "state", "pull"},
+					stderr:   "Error: Failed to load state",
+					exitCode: 1,
+				},
+			},
+			lockRetry: LockRetry{Attempts: 3, Interval: time.Millisecond},
+			ok:        false,
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.desc, func(t *testing.T) {
+			e := NewMockExecutor(tc.mockCommands)
+			terraformCLI := NewTerraformCLI(e)
+			terraformCLI.SetExecPath("terraform")
+			terraformCLI.SetLockRetry(tc.lockRetry)
+			got, err := terraformCLI.StatePull(context.Background())
+			if tc.ok && err != nil {
+				t.Fatalf("unexpected err: %s", err)
+			}
+			if !tc.ok && err == nil {
+				t.Fatalf("expected to return an error, but no error, got: %s", got.Bytes())
+			}
+			if !tc.ok && !strings.Contains(err.Error(), tc.want) {
+				t.Errorf("got: %s, want to contain: %s", err, tc.want)
+			}
+			if calls := e.(*mockExecutor).runCalls; calls != len(tc.mockCommands) {
+				t.Errorf("got %d calls, want: %d", calls, len(tc.mockCommands))
+			}
+		})
+	}
+}
+
+func TestTerraformCLIStatePushLockRetryCanceled(t *testing.T) {
+	mockCommands := []*mockCommand{
+		{
+			args:     []string{"terraform", "state", "push", "/path/to/tempfile"},
+			argsRe:   regexp.MustCompile(`^terraform state push \S+$`),
+			stderr:   stateLockStderr,
+			exitCode: 1,
+		},
+	}
+	e := NewMockExecutor(mockCommands)
+	terraformCLI := NewTerraformCLI(e)
+	terraformCLI.SetExecPath("terraform")
+	terraformCLI.SetLockRetry(LockRetry{Attempts: 3, Interval: time.Hour})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	err := terraformCLI.StatePush(ctx, NewState([]byte("dummy state")))
+	if err == nil {
+		t.Fatal("expected to return an error, but no error")
+	}
+	if !strings.Contains(err.Error(), "canceled while waiting for the state lock") {
+		t.Errorf("unexpected err: %s", err)
+	}
+}
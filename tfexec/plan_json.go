@@ -0,0 +1,162 @@
+package tfexec
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"sort"
+)
+
+// PlanJSON is a machine-readable representation of a plan.
+// It's an output of terraform show -json <planfile>.
+// We intentionally parse only a few attributes we need.
+// https://developer.hashicorp.com/terraform/internals/json-format#plan-representation
+type PlanJSON struct {
+	// ResourceChanges is a list of planned changes for each resource instance.
+	ResourceChanges []ResourceChange `json:"resource_changes"`
+	// OutputChanges is a map of planned changes for each root module output
+	// keyed by the output name.
+	OutputChanges map[string]Change `json:"output_changes"`
+}
+
+// ResourceChange is a planned change for a resource instance.
+type ResourceChange struct {
+	// Address is an absolute address of the resource instance.
+	Address string `json:"address"`
+	// Change is a description of the planned change.
+	Change Change `json:"change"`
+}
+
+// Change is a description of the planned change for a resource instance.
+type Change struct {
+	// Actions is a list of actions such as "no-op", "create", "read",
+	// "update", "delete".
+	Actions []string `json:"actions"`
+}
+
+// ParsePlanJSON parses an output of terraform show -json <planfile>.
+func ParsePlanJSON(b []byte) (*PlanJSON, error) {
+	var p PlanJSON
+	if err := json.Unmarshal(b, &p); err != nil {
+		return nil, fmt.Errorf("failed to parse plan json: %s", err)
+	}
+	return &p, nil
+}
+
+// HasChange returns true if the plan has any change of resources or outputs.
+func (p *PlanJSON) HasChange() bool {
+	return len(p.ChangedAddresses()) > 0 || len(p.ChangedOutputs()) > 0
+}
+
+// ChangedAddresses returns a list of resource addresses which have any change.
+// The "no-op" and "read" actions are not considered as a change.
+func (p *PlanJSON) ChangedAddresses() []string {
+	addrs := []string{}
+	for _, rc := range p.ResourceChanges {
+		if rc.Change.isChange() {
+			addrs = append(addrs, rc.Address)
+		}
+	}
+	return addrs
+}
+
+// ChangedOutputs returns a sorted list of root module outputs which have any
+// change in the form of `output.<name>`.
+// The "no-op" action is not considered as a change.
+func (p *PlanJSON) ChangedOutputs() []string {
+	addrs := []string{}
+	for name, c := range p.OutputChanges {
+		if c.isChange() {
+			addrs = append(addrs, "output."+name)
+		}
+	}
+	sort.Strings(addrs)
+	return addrs
+}
+
+// DeletedAddresses returns a list of resource addresses which would be
+// destroyed, including ones which would be replaced.
+func (p *PlanJSON) DeletedAddresses() []string {
+	addrs := []string{}
+	for _, rc := range p.ResourceChanges {
+		if rc.Change.isDelete() {
+			addrs = append(addrs, rc.Address)
+		}
+	}
+	return addrs
+}
+
+// isDelete returns true if the actions contain a delete.
+func (c Change) isDelete() bool {
+	for _, a := range c.Actions {
+		if a == "delete" {
+			return true
+		}
+	}
+	return false
+}
+
+// isChange returns true if the actions contain any change.
+func (c Change) isChange() bool {
+	for _, a := range c.Actions {
+		if a != "no-op" && a != "read" {
+			return true
+		}
+	}
+	return false
+}
+
+// planHashKeys is a list of top-level attributes of a plan representation
+// which are included in a normalized hash of the plan. Others such as
+// timestamp, terraform_version and prior_state are run-specific and ignored.
+var planHashKeys = []string{"resource_changes", "resource_drift", "output_changes"}
+
+// NormalizedPlanHash returns a stable hash of an output of
+// terraform show -json <planfile> in the form of `sha256:<hex>`.
+// The hash is computed only from planned changes in a canonical form, so that
+// it's the same across runs unless the plan changes. It's intended to detect
+// non-determinism, such as a provider which produces a different plan for the
+// same configuration and state.
+func NormalizedPlanHash(b []byte) (string, error) {
+	var plan map[string]interface{}
+	if err := json.Unmarshal(b, &plan); err != nil {
+		return "", fmt.Errorf("failed to parse plan json: %s", err)
+	}
+
+	normalized := map[string]interface{}{}
+	for _, k := range planHashKeys {
+		v, ok := plan[k]
+		if !ok {
+			continue
+		}
+		if changes, ok := v.([]interface{}); ok {
+			sortByAddress(changes)
+		}
+		normalized[k] = v
+	}
+
+	// json.Marshal sorts keys of a map, which makes it canonical.
+	canonical, err := json.Marshal(normalized)
+	if err != nil {
+		return "", fmt.Errorf("failed to normalize plan json: %s", err)
+	}
+	sum := sha256.Sum256(canonical)
+	return "sha256:" + hex.EncodeToString(sum[:]), nil
+}
+
+// sortByAddress sorts a given list of changes by the address attribute in
+// place, so that a hash doesn't depend on the order of changes.
+func sortByAddress(changes []interface{}) {
+	address := func(v interface{}) string {
+		m, ok := v.(map[string]interface{})
+		if !ok {
+			return ""
+		}
+		addr, _ := m["address"].(string)
+		return addr
+	}
+	sort.SliceStable(changes, func(i, j int) bool {
+		return address(changes[i]) < address(changes[j])
+	})
+}
@@ -0,0 +1,206 @@
+package tfexec
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestParsePlanJSON(t *testing.T) {
+	cases := []struct {
+		desc string
+		json string
+		want []string
+		ok   bool
+	}{
+		{
+			desc: "no changes",
+			json: `{
+  "format_version": "1.2",
+  "resource_changes": [
+    {"address": "null_resource.foo", "change": {"actions": ["no-op"]}},
+    {"address": "data.null_data_source.bar", "change": {"actions": ["read"]}}
+  ]
+}`,
+			want: []string{},
+			ok:   true,
+		},
+		{
+			desc: "with changes",
+			json: `{
+  "format_version": "1.2",
+  "resource_changes": [
+    {"address": "null_resource.foo", "change": {"actions": ["no-op"]}},
+    {"address": "null_resource.bar", "change": {"actions": ["create"]}},
+    {"address": "null_resource.baz", "change": {"actions": ["delete", "create"]}}
+  ]
+}`,
+			want: []string{"null_resource.bar", "null_resource.baz"},
+			ok:   true,
+		},
+		{
+			desc: "empty",
+			json: `{}`,
+			want: []string{},
+			ok:   true,
+		},
+		{
+			desc: "invalid json",
+			json: `foo`,
+			want: nil,
+			ok:   false,
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.desc, func(t *testing.T) {
+			got, err := ParsePlanJSON([]byte(tc.json))
+			if tc.ok && err != nil {
+				t.Fatalf("unexpected err: %s", err)
+			}
+			if !tc.ok && err == nil {
+				t.Fatalf("expected to return an error, but no error, got: %#v", got)
+			}
+			if tc.ok {
+				if diff := cmp.Diff(got.ChangedAddresses(), tc.want); diff != "" {
+					t.Errorf("got: %#v, want: %#v, diff: %s", got.ChangedAddresses(), tc.want, diff)
+				}
+				if got.HasChange() != (len(tc.want) > 0) {
+					t.Errorf("got: HasChange() = %t, want: %t", got.HasChange(), len(tc.want) > 0)
+				}
+			}
+		})
+	}
+}
+
+func TestPlanJSONChangedOutputs(t *testing.T) {
+	b := []byte(`{
+  "format_version": "1.2",
+  "resource_changes": [
+    {"address": "null_resource.foo", "change": {"actions": ["no-op"]}}
+  ],
+  "output_changes": {
+    "foo": {"actions": ["no-op"]},
+    "qux": {"actions": ["update"]},
+    "bar": {"actions": ["create"]},
+    "baz": {"actions": ["delete"]}
+  }
+}`)
+	got, err := ParsePlanJSON(b)
+	if err != nil {
+		t.Fatalf("unexpected err: %s", err)
+	}
+	want := []string{"output.bar", "output.baz", "output.qux"}
+	if diff := cmp.Diff(got.ChangedOutputs(), want); diff != "" {
+		t.Errorf("got: %#v, want: %#v, diff: %s", got.ChangedOutputs(), want, diff)
+	}
+	if len(got.ChangedAddresses()) != 0 {
+		t.Errorf("expected no changed resources, but got: %#v", got.ChangedAddresses())
+	}
+	if !got.HasChange() {
+		t.Error("expected to have changes of outputs")
+	}
+}
+
+func TestPlanJSONDeletedAddresses(t *testing.T) {
+	b := []byte(`{
+  "format_version": "1.2",
+  "resource_changes": [
+    {"address": "null_resource.foo", "change": {"actions": ["no-op"]}},
+    {"address": "null_resource.bar", "change": {"actions": ["update"]}},
+    {"address": "null_resource.baz", "change": {"actions": ["delete", "create"]}},
+    {"address": "null_resource.qux", "change": {"actions": ["create", "delete"]}},
+    {"address": "null_resource.quux", "change": {"actions": ["delete"]}}
+  ]
+}`)
+	p, err := ParsePlanJSON(b)
+	if err != nil {
+		t.Fatalf("unexpected err: %s", err)
+	}
+
+	got := p.DeletedAddresses()
+	want := []string{"null_resource.baz", "null_resource.qux", "null_resource.quux"}
+	if diff := cmp.Diff(got, want); diff != "" {
+		t.Errorf("got: %#v, want: %#v, diff: %s", got, want, diff)
+	}
+}
+
+func TestNormalizedPlanHash(t *testing.T) {
+	base := `{
+  "format_version": "1.2",
+  "terraform_version": "1.9.8",
+  "timestamp": "2024-11-01T00:00:00Z",
+  "resource_changes": [
+    {"address": "null_resource.bar", "change": {"actions": ["no-op"], "before": {"id": "2"}, "after": {"id": "2"}}},
+    {"address": "null_resource.foo", "change": {"actions": ["no-op"], "before": {"id": "1"}, "after": {"id": "1"}}}
+  ],
+  "prior_state": {"serial": 1}
+}`
+	cases := []struct {
+		desc string
+		json string
+		same bool
+		ok   bool
+	}{
+		{
+			desc: "same",
+			json: base,
+			same: true,
+			ok:   true,
+		},
+		{
+			desc: "run-specific fields and key order",
+			json: `{
+  "timestamp": "2024-11-02T00:00:00Z",
+  "terraform_version": "1.10.0",
+  "resource_changes": [
+    {"change": {"before": {"id": "1"}, "after": {"id": "1"}, "actions": ["no-op"]}, "address": "null_resource.foo"},
+    {"change": {"actions": ["no-op"], "before": {"id": "2"}, "after": {"id": "2"}}, "address": "null_resource.bar"}
+  ],
+  "prior_state": {"serial": 2}
+}`,
+			same: true,
+			ok:   true,
+		},
+		{
+			desc: "different change",
+			json: `{
+  "resource_changes": [
+    {"address": "null_resource.bar", "change": {"actions": ["no-op"], "before": {"id": "2"}, "after": {"id": "2"}}},
+    {"address": "null_resource.foo", "change": {"actions": ["update"], "before": {"id": "1"}, "after": {"id": "3"}}}
+  ]
+}`,
+			same: false,
+			ok:   true,
+		},
+		{
+			desc: "invalid json",
+			json: `{`,
+			ok:   false,
+		},
+	}
+
+	want, err := NormalizedPlanHash([]byte(base))
+	if err != nil {
+		t.Fatalf("failed to compute a base hash: %s", err)
+	}
+	if !strings.HasPrefix(want, "sha256:") {
+		t.Fatalf("unexpected hash format: %s", want)
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.desc, func(t *testing.T) {
+			got, err := NormalizedPlanHash([]byte(tc.json))
+			if tc.ok && err != nil {
+				t.Fatalf("unexpected err: %s", err)
+			}
+			if !tc.ok && err == nil {
+				t.Fatalf("expected to return an error, but no error, got: %s", got)
+			}
+			if tc.ok && (got == want) != tc.same {
+				t.Errorf("got: %s, base: %s, want same: %t", got, want, tc.same)
+			}
+		})
+	}
+}
@@ -0,0 +1,100 @@
+package tfexec
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+
+	"github.com/hashicorp/hcl/v2"
+	"github.com/hashicorp/hcl/v2/gohcl"
+	"github.com/hashicorp/hcl/v2/hclparse"
+)
+
+// lockFile is a file name of the dependency lock file written by terraform
+// init in the working directory.
+const lockFile = ".terraform.lock.hcl"
+
+// lockFileSchema is a schema of the dependency lock file.
+// We parse only the address and version of providers, and ignore the rest
+// such as constraints and hashes.
+type lockFileSchema struct {
+	Providers []struct {
+		Address string   `hcl:"address,label"`
+		Version string   `hcl:"version,optional"`
+		Remain  hcl.Body `hcl:",remain"`
+	} `hcl:"provider,block"`
+}
+
+// LockedProviders parses the dependency lock file in a given working
+// directory and returns a map of provider source addresses to the locked
+// versions. It returns nil if the lock file doesn't exist, such as a
+// configuration for terraform older than v0.14.
+func LockedProviders(dir string) (map[string]string, error) {
+	path := filepath.Join(dir, lockFile)
+	b, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to read the dependency lock file: %s", err)
+	}
+
+	file, diags := hclparse.NewParser().ParseHCL(b, path)
+	if diags.HasErrors() {
+		return nil, fmt.Errorf("failed to parse the dependency lock file: %s", diags)
+	}
+	var f lockFileSchema
+	if diags := gohcl.DecodeBody(file.Body, nil, &f); diags.HasErrors() {
+		return nil, fmt.Errorf("failed to parse the dependency lock file: %s", diags)
+	}
+
+	providers := make(map[string]string, len(f.Providers))
+	for _, p := range f.Providers {
+		providers[p.Address] = p.Version
+	}
+	return providers, nil
+}
+
+// IsProviderInstalled returns true if a given version of provider is
+// installed in the data dir of a given working directory by terraform init.
+func IsProviderInstalled(dir string, address string, version string) bool {
+	path := filepath.Join(resolveDataDir(dir), "providers", filepath.FromSlash(address), version)
+	_, err := os.Stat(path)
+	return err == nil
+}
+
+// stateProviderRe matches a provider source address in a provider
+// configuration address recorded in tfstate, such as
+// `provider["registry.terraform.io/hashicorp/aws"].alias` or
+// `module.foo.provider["registry.terraform.io/hashicorp/aws"]`.
+var stateProviderRe = regexp.MustCompile(`provider\["([^"]+)"\]`)
+
+// ProviderAddresses parses tfstate and returns a sorted list of unique
+// source addresses of providers required by resources in the state.
+// A provider address in a legacy format without a source address is ignored.
+func (s *State) ProviderAddresses() ([]string, error) {
+	var st struct {
+		Resources []struct {
+			Provider string `json:"provider"`
+		} `json:"resources"`
+	}
+	if err := json.Unmarshal(s.Bytes(), &st); err != nil {
+		return nil, fmt.Errorf("failed to parse tfstate: %s", err)
+	}
+
+	seen := map[string]bool{}
+	addrs := []string{}
+	for _, r := range st.Resources {
+		m := stateProviderRe.FindStringSubmatch(r.Provider)
+		if m == nil || seen[m[1]] {
+			continue
+		}
+		seen[m[1]] = true
+		addrs = append(addrs, m[1])
+	}
+	sort.Strings(addrs)
+	return addrs, nil
+}
@@ -0,0 +1,159 @@
+package tfexec
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestLockedProviders(t *testing.T) {
+	cases := []struct {
+		desc   string
+		source string
+		want   map[string]string
+		ok     bool
+	}{
+		{
+			desc: "simple",
+			source: `
+# This file is maintained automatically by "terraform init".
+# Manual edits may be lost in future updates.
+
+provider "registry.terraform.io/hashicorp/null" {
+  version     = "3.2.2"
+  constraints = "~> 3.0"
+  hashes = [
+    "h1:IMVAUHKoydFrlPrl9OzasDnw/8ntZFerCC9iXw1rXQY=",
+  ]
+}
+
+provider "registry.terraform.io/hashicorp/time" {
+  version = "0.11.1"
+}
+`,
+			want: map[string]string{
+				"registry.terraform.io/hashicorp/null": "3.2.2",
+				"registry.terraform.io/hashicorp/time": "0.11.1",
+			},
+			ok: true,
+		},
+		{
+			desc:   "no lock file",
+			source: "",
+			want:   nil,
+			ok:     true,
+		},
+		{
+			desc:   "invalid",
+			source: `provider "registry.terraform.io/hashicorp/null" {`,
+			want:   nil,
+			ok:     false,
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.desc, func(t *testing.T) {
+			dir := t.TempDir()
+			if len(tc.source) > 0 {
+				if err := os.WriteFile(filepath.Join(dir, lockFile), []byte(tc.source), 0600); err != nil {
+					t.Fatalf("failed to write the lock file: %s", err)
+				}
+			}
+
+			got, err := LockedProviders(dir)
+			if tc.ok && err != nil {
+				t.Fatalf("unexpected err: %s", err)
+			}
+			if !tc.ok && err == nil {
+				t.Fatalf("expected to return an error, but no error, got: %#v", got)
+			}
+			if tc.ok {
+				if diff := cmp.Diff(got, tc.want); diff != "" {
+					t.Errorf("got: %#v, want: %#v, diff: %s", got, tc.want, diff)
+				}
+			}
+		})
+	}
+}
+
+func TestIsProviderInstalled(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv("TF_DATA_DIR", "")
+	installed := filepath.Join(dir, ".terraform", "providers", "registry.terraform.io", "hashicorp", "null", "3.2.2")
+	if err := os.MkdirAll(installed, 0700); err != nil {
+		t.Fatalf("failed to create a provider dir: %s", err)
+	}
+
+	if !IsProviderInstalled(dir, "registry.terraform.io/hashicorp/null", "3.2.2") {
+		t.Error("expected to be installed, but not")
+	}
+	if IsProviderInstalled(dir, "registry.terraform.io/hashicorp/null", "3.2.1") {
+		t.Error("expected not to be installed, but installed")
+	}
+	if IsProviderInstalled(dir, "registry.terraform.io/hashicorp/time", "0.11.1") {
+		t.Error("expected not to be installed, but installed")
+	}
+}
+
+func TestStateProviderAddresses(t *testing.T) {
+	cases := []struct {
+		desc  string
+		state string
+		want  []string
+		ok    bool
+	}{
+		{
+			desc: "simple",
+			state: `{
+  "version": 4,
+  "resources": [
+    {"mode": "managed", "type": "time_static", "name": "foo", "provider": "provider[\"registry.terraform.io/hashicorp/time\"]"},
+    {"mode": "managed", "type": "null_resource", "name": "foo", "provider": "provider[\"registry.terraform.io/hashicorp/null\"].alias1"},
+    {"module": "module.foo", "mode": "managed", "type": "null_resource", "name": "bar", "provider": "module.foo.provider[\"registry.terraform.io/hashicorp/null\"]"}
+  ]
+}`,
+			want: []string{
+				"registry.terraform.io/hashicorp/null",
+				"registry.terraform.io/hashicorp/time",
+			},
+			ok: true,
+		},
+		{
+			desc:  "no resources",
+			state: `{"version": 4, "resources": []}`,
+			want:  []string{},
+			ok:    true,
+		},
+		{
+			desc:  "legacy format",
+			state: `{"version": 4, "resources": [{"provider": "provider.null"}]}`,
+			want:  []string{},
+			ok:    true,
+		},
+		{
+			desc:  "invalid",
+			state: `foo`,
+			want:  nil,
+			ok:    false,
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.desc, func(t *testing.T) {
+			got, err := NewState([]byte(tc.state)).ProviderAddresses()
+			if tc.ok && err != nil {
+				t.Fatalf("unexpected err: %s", err)
+			}
+			if !tc.ok && err == nil {
+				t.Fatalf("expected to return an error, but no error, got: %#v", got)
+			}
+			if tc.ok {
+				if diff := cmp.Diff(got, tc.want); diff != "" {
+					t.Errorf("got: %#v, want: %#v, diff: %s", got, tc.want, diff)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,61 @@
+package tfexec
+
+import (
+	"fmt"
+	"strings"
+)
+
+// readOnlyRefusedCommands is a list of terraform subcommands which may
+// mutate remote state or real resources. They are always refused in
+// read-only mode.
+var readOnlyRefusedCommands = [][]string{
+	{"apply"},
+	{"destroy"},
+	{"refresh"},
+	{"taint"},
+	{"untaint"},
+	{"force-unlock"},
+	{"state", "push"},
+	{"workspace", "new"},
+	{"workspace", "delete"},
+}
+
+// readOnlyLocalStateCommands is a list of terraform subcommands which mutate
+// state. They are allowed in read-only mode only if they operate on a local
+// state file given by the -state= option, which is how tfmigrate computes a
+// new state in a temporary file.
+var readOnlyLocalStateCommands = [][]string{
+	{"import"},
+	{"state", "mv"},
+	{"state", "rm"},
+	{"state", "replace-provider"},
+}
+
+// checkReadOnly returns an error if given arguments of terraform command may
+// mutate remote state or real resources.
+func checkReadOnly(args []string) error {
+	for _, c := range readOnlyRefusedCommands {
+		if hasSubcommand(args, c) {
+			return fmt.Errorf("refused to run terraform %s in read-only mode", strings.Join(c, " "))
+		}
+	}
+	for _, c := range readOnlyLocalStateCommands {
+		if hasSubcommand(args, c) && !hasPrefixOptions(args, "-state=") {
+			return fmt.Errorf("refused to run terraform %s without a local state file in read-only mode", strings.Join(c, " "))
+		}
+	}
+	return nil
+}
+
+// hasSubcommand returns true if given arguments start with a given subcommand.
+func hasSubcommand(args []string, subcommand []string) bool {
+	if len(args) < len(subcommand) {
+		return false
+	}
+	for i, s := range subcommand {
+		if args[i] != s {
+			return false
+		}
+	}
+	return true
+}
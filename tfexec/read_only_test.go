@@ -0,0 +1,106 @@
+package tfexec
+
+import (
+	"context"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+func TestCheckReadOnly(t *testing.T) {
+	cases := []struct {
+		desc string
+		args []string
+		ok   bool
+	}{
+		{
+			desc: "plan",
+			args: []string{"plan", "-input=false", "-detailed-exitcode"},
+			ok:   true,
+		},
+		{
+			desc: "state pull",
+			args: []string{"state", "pull"},
+			ok:   true,
+		},
+		{
+			desc: "state list",
+			args: []string{"state", "list"},
+			ok:   true,
+		},
+		{
+			desc: "state push",
+			args: []string{"state", "push", "/tmp/tmp.tfstate"},
+			ok:   false,
+		},
+		{
+			desc: "apply",
+			args: []string{"apply", "-auto-approve"},
+			ok:   false,
+		},
+		{
+			desc: "workspace new",
+			args: []string{"workspace", "new", "foo"},
+			ok:   false,
+		},
+		{
+			desc: "state mv with a local state",
+			args: []string{"state", "mv", "-state=/tmp/tmp.tfstate", "null_resource.foo", "null_resource.bar"},
+			ok:   true,
+		},
+		{
+			desc: "state mv without a local state",
+			args: []string{"state", "mv", "null_resource.foo", "null_resource.bar"},
+			ok:   false,
+		},
+		{
+			desc: "state rm without a local state",
+			args: []string{"state", "rm", "null_resource.foo"},
+			ok:   false,
+		},
+		{
+			desc: "import with a local state",
+			args: []string{"import", "-state=/tmp/tmp.tfstate", "time_static.foo", "2006-01-02T15:04:05Z"},
+			ok:   true,
+		},
+		{
+			desc: "import without a local state",
+			args: []string{"import", "time_static.foo", "2006-01-02T15:04:05Z"},
+			ok:   false,
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.desc, func(t *testing.T) {
+			err := checkReadOnly(tc.args)
+			if tc.ok && err != nil {
+				t.Fatalf("unexpected err: %s", err)
+			}
+			if !tc.ok && err == nil {
+				t.Fatal("expected to return an error, but no error")
+			}
+		})
+	}
+}
+
+func TestTerraformCLIStatePushReadOnly(t *testing.T) {
+	e := NewMockExecutor([]*mockCommand{
+		{
+			args:     []string{"terraform", "state", "push", "dummy"},
+			argsRe:   regexp.MustCompile(`^terraform state push \S+$`),
+			exitCode: 0,
+		},
+	})
+	terraformCLI := NewTerraformCLI(e)
+	terraformCLI.SetExecPath("terraform")
+	terraformCLI.SetReadOnly(true)
+
+	state := NewState([]byte("dummy state"))
+	err := terraformCLI.StatePush(context.Background(), state)
+	if err == nil {
+		t.Fatal("expected to return an error, but no error")
+	}
+	if !strings.Contains(err.Error(), "read-only") {
+		t.Errorf("expected an error of read-only mode, but got: %s", err)
+	}
+}
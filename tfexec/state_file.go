@@ -0,0 +1,313 @@
+package tfexec
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+)
+
+// NewStateFromReader writes a content of tfstate read from a given reader to
+// a temporary file in a given dir and returns a State backed by the file.
+// If the dir is empty, the default directory for temporary files is used.
+// It's intended for a large tfstate which we don't want to hold in memory.
+// The file is removed as soon as it's written and kept open, so that it's
+// cleaned up automatically when the State is garbage-collected.
+func NewStateFromReader(dir string, r io.Reader) (*State, error) {
+	f, err := os.CreateTemp(dir, "tfstate")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create temporary file: %s", err)
+	}
+
+	if _, err := io.Copy(f, r); err != nil {
+		f.Close()
+		os.Remove(f.Name())
+		return nil, fmt.Errorf("failed to write temporary file: %s", err)
+	}
+
+	return newStateFromTempFile(f)
+}
+
+// newStateFromTempFile returns a State backed by a given temporary file.
+// The State takes ownership of the file. The file is removed immediately and
+// its content is read via the open file descriptor.
+// On platforms where an open file cannot be removed such as Windows, the file
+// is left in the temporary directory.
+func newStateFromTempFile(f *os.File) (*State, error) {
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		os.Remove(f.Name())
+		return nil, fmt.Errorf("failed to stat temporary file: %s", err)
+	}
+
+	if err := os.Remove(f.Name()); err != nil {
+		log.Printf("[DEBUG] [executor] failed to remove a state file while it's open, leave it: %s\n", err)
+	}
+
+	return &State{f: f, size: info.Size()}, nil
+}
+
+// writeStateTempFile writes a given state to a temporary file and returns
+// its file. Unlike writeTempFile, it streams the content, so that a state
+// backed by a file is not loaded into memory.
+// The caller is responsible for removing it.
+// If it fails, the temporary file is removed and it returns nil.
+func (c *terraformCLI) writeStateTempFile(state *State) (*os.File, error) {
+	tmpfile, err := c.createTempFile("tmp")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create temporary file: %s", err)
+	}
+
+	if _, err := io.Copy(tmpfile, state.Reader()); err != nil {
+		tmpfile.Close()
+		os.Remove(tmpfile.Name())
+		return nil, fmt.Errorf("failed to write temporary file: %s", err)
+	}
+
+	if err := tmpfile.Close(); err != nil {
+		os.Remove(tmpfile.Name())
+		return nil, fmt.Errorf("failed to close temporary file: %s", err)
+	}
+
+	return tmpfile, nil
+}
+
+// readStateFile reads a state file written by terraform and returns a State.
+// If stream_state is enabled, the returned State is backed by the file, which
+// is removed immediately. Otherwise, it's read into memory.
+func (c *terraformCLI) readStateFile(path string) (*State, error) {
+	if !c.streamState {
+		b, err := os.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		return NewState(b), nil
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	return newStateFromTempFile(f)
+}
+
+// ReplaceAttributes returns a new State with given top-level attributes of
+// tfstate replaced, such as the serial and lineage. An attribute which is not
+// found is added. The other attributes are copied as they are.
+// If the State is backed by a file, the new State is written to a temporary
+// file in the same directory as a stream, so that a large tfstate is not
+// loaded into memory.
+func (s *State) ReplaceAttributes(attrs map[string]any) (*State, error) {
+	if s.f == nil {
+		var buf bytes.Buffer
+		if err := s.writeReplacedAttributes(&buf, attrs); err != nil {
+			return nil, err
+		}
+		return NewState(buf.Bytes()), nil
+	}
+
+	f, err := os.CreateTemp(filepath.Dir(s.f.Name()), "tfstate")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create temporary file: %s", err)
+	}
+	if err := s.writeReplacedAttributes(f, attrs); err != nil {
+		f.Close()
+		os.Remove(f.Name())
+		return nil, err
+	}
+	return newStateFromTempFile(f)
+}
+
+// writeReplacedAttributes writes the tfstate with given top-level attributes
+// replaced to a given writer. The resources are copied one by one, so that
+// only a single resource is held in memory at a time.
+func (s *State) writeReplacedAttributes(w io.Writer, attrs map[string]any) error {
+	bw := bufio.NewWriter(w)
+	n := 0
+	writeKey := func(key string) error {
+		if n > 0 {
+			bw.WriteString(",")
+		}
+		n++
+		k, err := json.Marshal(key)
+		if err != nil {
+			return err
+		}
+		bw.WriteString("\n  ")
+		bw.Write(k)
+		bw.WriteString(": ")
+		return nil
+	}
+
+	bw.WriteString("{")
+	replaced := map[string]bool{}
+	err := walkStateAttributes(s.Reader(), func(dec *json.Decoder, key string) (bool, error) {
+		if err := writeKey(key); err != nil {
+			return false, err
+		}
+		if v, ok := attrs[key]; ok {
+			replaced[key] = true
+			b, err := json.Marshal(v)
+			if err != nil {
+				return false, err
+			}
+			bw.Write(b)
+			return false, skipJSONValue(dec)
+		}
+		if key == "resources" {
+			return false, copyJSONArray(dec, bw)
+		}
+		var raw json.RawMessage
+		if err := dec.Decode(&raw); err != nil {
+			return false, err
+		}
+		bw.Write(raw)
+		return false, nil
+	})
+	if err != nil {
+		return fmt.Errorf("failed to parse tfstate: %s", err)
+	}
+
+	keys := []string{}
+	for key := range attrs {
+		if !replaced[key] {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		if err := writeKey(key); err != nil {
+			return err
+		}
+		b, err := json.Marshal(attrs[key])
+		if err != nil {
+			return err
+		}
+		bw.Write(b)
+	}
+	bw.WriteString("\n}\n")
+	if err := bw.Flush(); err != nil {
+		return fmt.Errorf("failed to write tfstate: %s", err)
+	}
+	return nil
+}
+
+// copyJSONArray copies an array at the current position of a given decoder
+// to a given writer element by element.
+func copyJSONArray(dec *json.Decoder, w *bufio.Writer) error {
+	if err := expectDelim(dec, '['); err != nil {
+		return err
+	}
+	w.WriteString("[")
+	for i := 0; dec.More(); i++ {
+		var raw json.RawMessage
+		if err := dec.Decode(&raw); err != nil {
+			return err
+		}
+		if i > 0 {
+			w.WriteString(",")
+		}
+		w.WriteString("\n    ")
+		w.Write(raw)
+	}
+	if err := expectDelim(dec, ']'); err != nil {
+		return err
+	}
+	w.WriteString("\n  ]")
+	return nil
+}
+
+// walkStateAttributes reads top-level attributes of a JSON object from a given
+// reader as a stream and calls a given function with a decoder positioned at
+// each value. The function must consume the value and returns true to stop
+// walking. It returns an error if the JSON is not an object or invalid.
+func walkStateAttributes(r io.Reader, f func(dec *json.Decoder, key string) (bool, error)) error {
+	dec := json.NewDecoder(r)
+	if err := expectDelim(dec, '{'); err != nil {
+		return err
+	}
+
+	for dec.More() {
+		t, err := dec.Token()
+		if err != nil {
+			return err
+		}
+		key, ok := t.(string)
+		if !ok {
+			return fmt.Errorf("unexpected token: %v", t)
+		}
+		stop, err := f(dec, key)
+		if err != nil {
+			return err
+		}
+		if stop {
+			return nil
+		}
+	}
+
+	if err := expectDelim(dec, '}'); err != nil {
+		return err
+	}
+	if _, err := dec.Token(); !errors.Is(err, io.EOF) {
+		return errors.New("unexpected data after the top-level object")
+	}
+	return nil
+}
+
+// skipJSONValue skips a value at the current position of a given decoder
+// without holding it in memory.
+func skipJSONValue(dec *json.Decoder) error {
+	depth := 0
+	for {
+		t, err := dec.Token()
+		if err != nil {
+			return err
+		}
+		switch t {
+		case json.Delim('{'), json.Delim('['):
+			depth++
+		case json.Delim('}'), json.Delim(']'):
+			depth--
+		}
+		if depth == 0 {
+			return nil
+		}
+	}
+}
+
+// expectDelim reads a next token from a given decoder and returns an error if
+// it's not a given delimiter.
+func expectDelim(dec *json.Decoder, delim json.Delim) error {
+	t, err := dec.Token()
+	if err != nil {
+		return err
+	}
+	if t != delim {
+		return fmt.Errorf("expected %s, but got: %v", delim, t)
+	}
+	return nil
+}
+
+// isEmptyJSON returns true if a given reader has only whitespaces.
+// Otherwise, the reader is rewound to the first non-whitespace character.
+func isEmptyJSON(r *bufio.Reader) bool {
+	for {
+		c, err := r.ReadByte()
+		if err != nil {
+			return true
+		}
+		switch c {
+		case ' ', '\t', '\r', '\n':
+			continue
+		}
+		_ = r.UnreadByte()
+		return false
+	}
+}
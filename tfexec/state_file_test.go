@@ -0,0 +1,245 @@
+package tfexec
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestStateReplaceAttributes(t *testing.T) {
+	content := `{
+  "version": 4,
+  "serial": 3,
+  "resources": [{"type": "null_resource", "name": "foo"}, {"type": "null_resource", "name": "bar"}],
+  "outputs": {"foo": {"value": "bar"}}
+}`
+	attrs := map[string]any{
+		"serial":  4,
+		"lineage": "foo",
+	}
+	want := &StateMeta{Version: 4, Serial: 4, Lineage: "foo"}
+	wantRest := map[string]any{
+		"resources": []any{
+			map[string]any{"type": "null_resource", "name": "foo"},
+			map[string]any{"type": "null_resource", "name": "bar"},
+		},
+		"outputs": map[string]any{"foo": map[string]any{"value": "bar"}},
+	}
+
+	cases := []struct {
+		desc  string
+		state func(t *testing.T) *State
+	}{
+		{
+			desc: "memory",
+			state: func(_ *testing.T) *State {
+				return NewState([]byte(content))
+			},
+		},
+		{
+			desc: "stream",
+			state: func(t *testing.T) *State {
+				state, err := NewStateFromReader(t.TempDir(), strings.NewReader(content))
+				if err != nil {
+					t.Fatalf("unexpected err: %s", err)
+				}
+				return state
+			},
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.desc, func(t *testing.T) {
+			got, err := tc.state(t).ReplaceAttributes(attrs)
+			if err != nil {
+				t.Fatalf("unexpected err: %s", err)
+			}
+
+			meta, err := got.Meta()
+			if err != nil {
+				t.Fatalf("failed to parse the new state: %s", err)
+			}
+			if *meta != *want {
+				t.Errorf("got: %#v, want: %#v", meta, want)
+			}
+
+			var rest map[string]any
+			if err := json.Unmarshal(got.Bytes(), &rest); err != nil {
+				t.Fatalf("failed to parse the new state: %s, got: %s", err, string(got.Bytes()))
+			}
+			for _, key := range []string{"version", "serial", "lineage"} {
+				delete(rest, key)
+			}
+			if !reflect.DeepEqual(rest, wantRest) {
+				t.Errorf("got: %#v, want: %#v", rest, wantRest)
+			}
+		})
+	}
+}
+
+func TestNewStateFromReader(t *testing.T) {
+	cases := []struct {
+		desc      string
+		content   string
+		want      *StateMeta
+		encrypted bool
+		ok        bool
+	}{
+		{
+			desc: "meta after resources",
+			content: `{
+  "resources": [{"type": "null_resource", "name": "foo", "instances": [{"attributes": {"id": "1"}}]}],
+  "version": 4,
+  "terraform_version": "1.9.8",
+  "serial": 3,
+  "lineage": "foo"
+}`,
+			want: &StateMeta{
+				Version:          4,
+				TerraformVersion: "1.9.8",
+				Serial:           3,
+				Lineage:          "foo",
+			},
+			encrypted: false,
+			ok:        true,
+		},
+		{
+			desc:      "encrypted by OpenTofu",
+			content:   `{"serial": 3, "lineage": "foo", "encrypted_data": "Zm9v", "encryption_version": "v0"}`,
+			want:      &StateMeta{Serial: 3, Lineage: "foo"},
+			encrypted: true,
+			ok:        true,
+		},
+		{
+			desc:      "invalid",
+			content:   "foo",
+			want:      nil,
+			encrypted: true,
+			ok:        false,
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.desc, func(t *testing.T) {
+			dir := t.TempDir()
+			state, err := NewStateFromReader(dir, strings.NewReader(tc.content))
+			if err != nil {
+				t.Fatalf("unexpected err: %s", err)
+			}
+
+			if state.Size() != int64(len(tc.content)) {
+				t.Errorf("got size: %d, want: %d", state.Size(), len(tc.content))
+			}
+			if string(state.Bytes()) != tc.content {
+				t.Errorf("got: %s, want: %s", string(state.Bytes()), tc.content)
+			}
+			if got := state.IsEncrypted(); got != tc.encrypted {
+				t.Errorf("got encrypted: %t, want: %t", got, tc.encrypted)
+			}
+
+			got, err := state.Meta()
+			if tc.ok && err != nil {
+				t.Fatalf("unexpected err: %s", err)
+			}
+			if !tc.ok && err == nil {
+				t.Fatalf("expected to return an error, but no error, got: %#v", got)
+			}
+			if tc.ok && *got != *tc.want {
+				t.Errorf("got: %#v, want: %#v", got, tc.want)
+			}
+
+			// The backing file should be removed while it's open.
+			entries, err := os.ReadDir(dir)
+			if err != nil {
+				t.Fatalf("failed to read dir: %s", err)
+			}
+			if len(entries) != 0 {
+				t.Errorf("expected the temporary file to be removed, but got: %v", entries)
+			}
+		})
+	}
+}
+
+// newBenchmarkState returns a content of a large tfstate with a given number
+// of resources. The metadata is placed at the end so that it's not found
+// until the whole resources are read.
+func newBenchmarkState(n int) []byte {
+	var buf bytes.Buffer
+	buf.WriteString(`{"resources": [`)
+	for i := 0; i < n; i++ {
+		if i > 0 {
+			buf.WriteString(",")
+		}
+		fmt.Fprintf(&buf, `{"mode": "managed", "type": "null_resource", "name": "r%d", "instances": [{"attributes": {"id": "%d", "triggers": {"foo": "%s"}}}]}`, i, i, strings.Repeat("x", 256))
+	}
+	buf.WriteString(`], "version": 4, "terraform_version": "1.9.8", "serial": 1, "lineage": "foo"}`)
+	return buf.Bytes()
+}
+
+// BenchmarkStateApply compares memory usage of the apply path of a large
+// tfstate pulled into memory with the one streamed to a temporary file.
+// It pulls the state, reads the metadata, normalizes the serial and lineage
+// as tfmigrate does before push, and writes it to a temporary file for
+// terraform state push.
+// Run it with `go test -run ^$ -bench BenchmarkStateApply ./tfexec`.
+func BenchmarkStateApply(b *testing.B) {
+	content := newBenchmarkState(100000)
+
+	apply := func(b *testing.B, c *terraformCLI, state *State) {
+		meta, err := state.Meta()
+		if err != nil {
+			b.Fatalf("unexpected err: %s", err)
+		}
+		if state.IsEncrypted() {
+			b.Fatal("unexpected encrypted state")
+		}
+		next, err := state.ReplaceAttributes(map[string]any{
+			"lineage": meta.Lineage,
+			"serial":  meta.Serial + 1,
+		})
+		if err != nil {
+			b.Fatalf("unexpected err: %s", err)
+		}
+		f, err := c.writeStateTempFile(next)
+		if err != nil {
+			b.Fatalf("unexpected err: %s", err)
+		}
+		os.Remove(f.Name())
+		if err := next.Close(); err != nil {
+			b.Fatalf("unexpected err: %s", err)
+		}
+	}
+
+	b.Run("memory", func(b *testing.B) {
+		c := &terraformCLI{tmpDir: b.TempDir()}
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			// Simulate capturing an output of terraform state pull.
+			var buf bytes.Buffer
+			if _, err := io.Copy(&buf, bytes.NewReader(content)); err != nil {
+				b.Fatalf("unexpected err: %s", err)
+			}
+			apply(b, c, NewState(buf.Bytes()))
+		}
+	})
+
+	b.Run("stream", func(b *testing.B) {
+		c := &terraformCLI{tmpDir: b.TempDir()}
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			state, err := NewStateFromReader(c.tmpDir, bytes.NewReader(content))
+			if err != nil {
+				b.Fatalf("unexpected err: %s", err)
+			}
+			apply(b, c, state)
+			if err := state.Close(); err != nil {
+				b.Fatalf("unexpected err: %s", err)
+			}
+		}
+	})
+}
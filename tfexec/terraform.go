@@ -1,31 +1,151 @@
 package tfexec
 
 import (
+	"archive/zip"
+	"bufio"
+	"bytes"
 	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
 	"log"
 	"os"
 	"path/filepath"
 	"strings"
+	"time"
 
 	"github.com/hashicorp/go-version"
 	"github.com/mattn/go-shellwords"
 )
 
-// State is a named type for tfstate.
+// State is a type for tfstate.
 // We don't parse contents of tfstate to avoid depending on internal details,
-// but we define it as a named type to clarify interface.
-type State []byte
+// but we define it as a type to clarify interface.
+// The content is held in memory, or backed by a temporary file to reduce
+// memory usage for a large tfstate. See NewStateFromReader.
+type State struct {
+	// b is the content of tfstate held in memory.
+	// It's nil if the content is backed by a file.
+	b []byte
+	// f is a file which backs the content. It's nil if held in memory.
+	f *os.File
+	// size is a size of the file which backs the content.
+	size int64
+}
 
 // Bytes returns raw contents of tfstate as []byte.
+// If the content is backed by a file, it reads the whole file into memory.
+// Use Reader to avoid it if possible.
 func (s *State) Bytes() []byte {
-	return []byte(*s)
+	if s.f == nil {
+		return s.b
+	}
+
+	b, err := io.ReadAll(s.Reader())
+	if err != nil {
+		// It should not happen because the file is owned by the State.
+		log.Printf("[ERROR] [executor] failed to read a state file: %s\n", err)
+		return nil
+	}
+	return b
+}
+
+// Reader returns a new reader of raw contents of tfstate.
+// It doesn't read the whole content into memory if backed by a file.
+func (s *State) Reader() io.Reader {
+	if s.f == nil {
+		return bytes.NewReader(s.b)
+	}
+	return io.NewSectionReader(s.f, 0, s.size)
+}
+
+// Size returns a size of raw contents of tfstate in bytes.
+func (s *State) Size() int64 {
+	if s.f == nil {
+		return int64(len(s.b))
+	}
+	return s.size
+}
+
+// Close releases the file which backs the content if any.
+// It's optional because the file is closed when the State is
+// garbage-collected, but it's useful to release it early.
+// The State must not be used after it's closed.
+func (s *State) Close() error {
+	if s.f == nil {
+		return nil
+	}
+	return s.f.Close()
 }
 
-// NewState returns a new State instance with a given content of tfstate.
+// NewStatereturns a new State instance with a given content of tfstate.
 func NewState(b []byte) *State {
-	s := State(b)
-	return &s
+	return &State{b: b}
+}
+
+// StateMeta is a set of metadata in the header of tfstate.
+// We intentionally parse only a few top-level attributes which are stable
+// across terraform versions to avoid depending on internal details.
+type StateMeta struct {
+	// Version is a format version of tfstate.
+	Version int `json:"version"`
+	// TerraformVersion is a version of terraform which wrote the tfstate.
+	TerraformVersion string `json:"terraform_version"`
+	// Serial is incremented on every write of tfstate.
+	Serial uint64 `json:"serial"`
+	// Lineage is a unique ID assigned to a tfstate when it's created.
+	Lineage string `json:"lineage"`
+}
+
+// Meta parses the header of tfstate and returns a StateMeta.
+// It reads the tfstate as a stream and stops as soon as all the attributes
+// are found, so that it doesn't load a large tfstate into memory.
+func (s *State) Meta() (*StateMeta, error) {
+	var meta StateMeta
+	fields := map[string]any{
+		"version":           &meta.Version,
+		"terraform_version": &meta.TerraformVersion,
+		"serial":            &meta.Serial,
+		"lineage":           &meta.Lineage,
+	}
+	err := walkStateAttributes(s.Reader(), func(dec *json.Decoder, key string) (bool, error) {
+		v, ok := fields[key]
+		if !ok {
+			return false, skipJSONValue(dec)
+		}
+		if err := dec.Decode(v); err != nil {
+			return false, err
+		}
+		delete(fields, key)
+		return len(fields) == 0, nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse tfstate: %s", err)
+	}
+	return &meta, nil
+}
+
+// IsEncrypted returns true if the tfstate looks encrypted.
+// An encrypted state by OpenTofu has the encrypted_data attribute instead of
+// resources. A state which is not a JSON object is also considered encrypted,
+// because it may be encrypted by a wrapper. An empty state is not encrypted.
+// We cannot apply any state operation to an encrypted state, and pushing it
+// back may corrupt the remote state.
+func (s *State) IsEncrypted() bool {
+	r := bufio.NewReader(s.Reader())
+	if isEmptyJSON(r) {
+		return false
+	}
+
+	encrypted := false
+	err := walkStateAttributes(r, func(dec *json.Decoder, key string) (bool, error) {
+		if key == "encrypted_data" {
+			encrypted = true
+		}
+		return false, skipJSONValue(dec)
+	})
+	return err != nil || encrypted
 }
 
 // Plan is a named type for tfplan.
@@ -44,6 +164,38 @@ func NewPlan(b []byte) *Plan {
 	return &p
 }
 
+// planPriorStateFileName is a name of prior state file in a tfplan archive.
+const planPriorStateFileName = "tfstate"
+
+// PriorState returns a prior state embedded in a tfplan.
+// A tfplan file is a zip archive which contains the state used for planning.
+// It's intended for checking if the saved plan file is still applicable.
+func (p *Plan) PriorState() (*State, error) {
+	b := p.Bytes()
+	r, err := zip.NewReader(bytes.NewReader(b), int64(len(b)))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read plan file: %s", err)
+	}
+
+	for _, f := range r.File {
+		if f.Name != planPriorStateFileName {
+			continue
+		}
+		rc, err := f.Open()
+		if err != nil {
+			return nil, fmt.Errorf("failed to open prior state in plan file: %s", err)
+		}
+		defer rc.Close()
+		state, err := io.ReadAll(rc)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read prior state in plan file: %s", err)
+		}
+		return NewState(state), nil
+	}
+
+	return nil, fmt.Errorf("prior state not found in plan file")
+}
+
 // TerraformCLI is an interface for executing the terraform command.
 // The main results of the terraform command are generally side effects, and the
 // stdout functionality may not be useful. In addition, the interfaces of state
@@ -64,10 +216,18 @@ type TerraformCLI interface {
 	// Init initializes the current work directory.
 	Init(ctx context.Context, opts ...string) error
 
+	// Validate validates the configuration files in the current work directory.
+	Validate(ctx context.Context, opts ...string) error
+
 	// Plan computes expected changes.
 	// If a state is given, use it for the input state.
 	Plan(ctx context.Context, state *State, opts ...string) (*Plan, error)
 
+	// PlanWithDiagnostics computes expected changes and returns diagnostics
+	// such as warnings reported by terraform plan.
+	// If a state is given, use it for the input state.
+	PlanWithDiagnostics(ctx context.Context, state *State, opts ...string) (*Plan, []Diagnostic, error)
+
 	// Apply applies changes.
 	// If a plan is given, use it for the input plan.
 	Apply(ctx context.Context, plan *Plan, opts ...string) error
@@ -79,6 +239,10 @@ type TerraformCLI interface {
 	// If a state is given, use it for the input state.
 	Import(ctx context.Context, state *State, address string, id string, opts ...string) (*State, error)
 
+	// Show shows a human-readable or machine-readable output from a plan file.
+	// If a plan is given, use it for the input plan.
+	Show(ctx context.Context, plan *Plan, opts ...string) (string, error)
+
 	// Providers shows a tree of modules in the referenced configuration annotated with
 	// their provider requirements.
 	Providers(ctx context.Context) (string, error)
@@ -96,6 +260,10 @@ type TerraformCLI interface {
 	// It returns updated the given state and the stateOut.
 	StateMv(ctx context.Context, state *State, stateOut *State, source string, destination string, opts ...string) (*State, *State, error)
 
+	// StateMvWithDiagnostics is the same as StateMv, but also returns
+	// diagnostics such as warnings reported by terraform state mv.
+	StateMvWithDiagnostics(ctx context.Context, state *State, stateOut *State, source string, destination string, opts ...string) (*State, *State, []Diagnostic, error)
+
 	// StateRm removes resources from state.
 	// If a state is given, use it for the input state and return a new state.
 	// Note that if the input state is not given, always return nil state,
@@ -109,6 +277,12 @@ type TerraformCLI interface {
 	// command doesn't support a -state-out option; it only supports the -state option.
 	StateReplaceProvider(ctx context.Context, state *State, source string, destination string, opts ...string) (*State, error)
 
+	// RunStateCommand runs an arbitrary terraform subcommand which updates a
+	// state in-place, such as `state rm`, `taint` and `untaint`.
+	// If a state is given, use it for the input state and return a new state.
+	// Note that if the input state is not given, always return nil state.
+	RunStateCommand(ctx context.Context, state *State, subcommand []string, opts ...string) (*State, error)
+
 	// StatePush pushes a given State to remote.
 	StatePush(ctx context.Context, state *State, opts ...string) error
 
@@ -131,6 +305,29 @@ type TerraformCLI interface {
 	// It's intended to inject a wrapper command such as direnv.
 	SetExecPath(execPath string)
 
+	// SetTmpDir sets a directory where intermediate state and plan files are
+	// written. Default to the default directory for temporary files.
+	SetTmpDir(dir string)
+
+	// SetTimeouts sets timeouts for terraform commands which may take long.
+	// Default to no timeout.
+	SetTimeouts(timeouts Timeouts)
+
+	// SetReadOnly refuses terraform commands which may mutate remote state or
+	// real resources such as state push. Commands which operate on a local
+	// temporary state file are still allowed. Default to false.
+	SetReadOnly(readOnly bool)
+
+	// SetLockRetry sets retries with backoff for terraform state pull and
+	// push when the state is locked by another process. Default to no retry.
+	SetLockRetry(lockRetry LockRetry)
+
+	// SetStreamState streams the output of terraform state pull to a
+	// temporary file instead of holding it in memory, and keeps states
+	// updated by state subcommands in temporary files. It's intended for
+	// reducing memory usage for a large state. Default to false.
+	SetStreamState(streamState bool)
+
 	// OverrideBackendToLocal switches the backend to local and returns a function
 	// to switch it back to remote with defer.
 	// The -state flag for terraform command is not valid for remote state,
@@ -155,6 +352,35 @@ type terraformCLI struct {
 	// execPath is a string which executes the terraform command.
 	// Default to terraform. To use OpenTofu, set this to `tofu`.
 	execPath string
+
+	// tmpDir is a directory where intermediate state and plan files are
+	// written. Default to the default directory for temporary files.
+	tmpDir string
+
+	// timeouts is a set of timeouts for terraform commands.
+	timeouts Timeouts
+
+	// readOnly refuses terraform commands which may mutate remote state or
+	// real resources.
+	readOnly bool
+
+	// lockRetry is a setting of retries when the state is locked.
+	lockRetry LockRetry
+
+	// streamState keeps states in temporary files instead of memory.
+	streamState bool
+}
+
+// Timeouts is a set of timeouts for terraform commands which may take long.
+// We have separate timeouts for each phase, because a plan can legitimately
+// take much longer than others. A zero value means no timeout.
+type Timeouts struct {
+	// Init is a timeout for each terraform init.
+	Init time.Duration
+	// Plan is a timeout for each terraform plan.
+	Plan time.Duration
+	// Push is a timeout for each terraform state push.
+	Push time.Duration
 }
 
 var _ TerraformCLI = (*terraformCLI)(nil)
@@ -177,6 +403,12 @@ func NewTerraformCLI(e Executor) TerraformCLI {
 
 // Run is a low-level generic method for running an arbitrary terraform command.
 func (c *terraformCLI) Run(ctx context.Context, args ...string) (string, string, error) {
+	if c.readOnly {
+		if err := checkReadOnly(args); err != nil {
+			return "", "", err
+		}
+	}
+
 	name := c.execPath
 	// If execPath is customized
 	if name != "terraform" {
@@ -198,6 +430,11 @@ func (c *terraformCLI) Run(ctx context.Context, args ...string) (string, string,
 	if err != nil {
 		return "", "", err
 	}
+	if w, ok := ctx.Value(stdoutWriterKey{}).(io.Writer); ok {
+		if r, ok := cmd.(stdoutRedirector); ok {
+			r.redirectStdout(w)
+		}
+	}
 
 	err = c.Executor.Run(cmd)
 
@@ -215,6 +452,52 @@ func (c *terraformCLI) SetExecPath(execPath string) {
 	c.execPath = execPath
 }
 
+// SetTmpDir sets a directory where intermediate state and plan files are
+// written. Default to the default directory for temporary files.
+func (c *terraformCLI) SetTmpDir(dir string) {
+	c.tmpDir = dir
+}
+
+// SetTimeouts sets timeouts for terraform commands which may take long.
+func (c *terraformCLI) SetTimeouts(timeouts Timeouts) {
+	c.timeouts = timeouts
+}
+
+// SetReadOnly refuses terraform commands which may mutate remote state or
+// real resources such as state push.
+func (c *terraformCLI) SetReadOnly(readOnly bool) {
+	c.readOnly = readOnly
+}
+
+// SetLockRetry sets retries with backoff for terraform state pull and push
+// when the state is locked by another process.
+func (c *terraformCLI) SetLockRetry(lockRetry LockRetry) {
+	c.lockRetry = lockRetry
+}
+
+// SetStreamState streams the output of terraform state pull to a temporary
+// file and keeps states updated by state subcommands in temporary files.
+func (c *terraformCLI) SetStreamState(streamState bool) {
+	c.streamState = streamState
+}
+
+// runWithTimeout runs an arbitrary terraform command with a given timeout.
+// If the timeout is zero, it's the same as Run.
+func (c *terraformCLI) runWithTimeout(ctx context.Context, timeout time.Duration, args ...string) (string, string, error) {
+	if timeout == 0 {
+		return c.Run(ctx, args...)
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	stdout, stderr, err := c.Run(ctx, args...)
+	if err != nil && errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		return stdout, stderr, fmt.Errorf("terraform %s timed out after %s: %s", args[0], timeout, err)
+	}
+	return stdout, stderr, err
+}
+
 // OverrideBackendToLocal switches the backend to local and returns a function
 // that will switch it back to remote with defer.
 // The -state flag for terraform command is not valid for remote state,
@@ -287,7 +570,9 @@ terraform {
 			args = append(args, "-reconfigure")
 		}
 
-		err = c.Init(ctx, args...)
+		// Switch back even if the ctx has been canceled or timed out, otherwise
+		// the working dir is left with the local backend.
+		err = c.Init(context.WithoutCancel(ctx), args...)
 		if err != nil {
 			if supportsStateReplaceProvider && strings.Contains(err.Error(), AcceptableLegacyStateInitError) {
 				log.Printf("[INFO] [migrator@%s] ignoring error '%s'; the error is expected when using Terraform with a legacy Terraform state\n", c.Dir(), AcceptableLegacyStateInitError)
@@ -333,19 +618,31 @@ func mergeOptions(a []string, b []string) []string {
 	return uniq
 }
 
+// createTempFile creates a new temporary file in the tmpDir and returns its
+// file. The caller is responsible for removing it.
+// If the tmpDir is empty, the default directory for temporary files is used.
+func (c *terraformCLI) createTempFile(pattern string) (*os.File, error) {
+	return os.CreateTemp(c.tmpDir, pattern)
+}
+
 // writeTempFile writes content to a temporary file and return its file.
-func writeTempFile(content []byte) (*os.File, error) {
-	tmpfile, err := os.CreateTemp("", "tmp")
+// The caller is responsible for removing it.
+// If it fails, the temporary file is removed and it returns nil.
+func (c *terraformCLI) writeTempFile(content []byte) (*os.File, error) {
+	tmpfile, err := c.createTempFile("tmp")
 	if err != nil {
-		return tmpfile, fmt.Errorf("failed to create temporary file: %s", err)
+		return nil, fmt.Errorf("failed to create temporary file: %s", err)
 	}
 
 	if _, err := tmpfile.Write(content); err != nil {
-		return tmpfile, fmt.Errorf("failed to write temporary file: %s", err)
+		tmpfile.Close()
+		os.Remove(tmpfile.Name())
+		return nil, fmt.Errorf("failed to write temporary file: %s", err)
 	}
 
 	if err := tmpfile.Close(); err != nil {
-		return tmpfile, fmt.Errorf("failed to close temporary file: %s", err)
+		os.Remove(tmpfile.Name())
+		return nil, fmt.Errorf("failed to close temporary file: %s", err)
 	}
 
 	return tmpfile, nil
@@ -12,11 +12,11 @@ func (c *terraformCLI) Apply(ctx context.Context, plan *Plan, opts ...string) er
 	args = append(args, opts...)
 
 	if plan != nil {
-		tmpPlan, err := writeTempFile(plan.Bytes())
-		defer os.Remove(tmpPlan.Name())
+		tmpPlan, err := c.writeTempFile(plan.Bytes())
 		if err != nil {
 			return err
 		}
+		defer os.Remove(tmpPlan.Name())
 		args = append(args, tmpPlan.Name())
 	}
 
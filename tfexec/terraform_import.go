@@ -15,11 +15,11 @@ func (c *terraformCLI) Import(ctx context.Context, state *State, address string,
 		if hasPrefixOptions(opts, "-state=") {
 			return nil, fmt.Errorf("failed to build options. The state argument (!= nil) and the -state= option cannot be set at the same time: state=%v, opts=%v", state, opts)
 		}
-		tmpState, err := writeTempFile(state.Bytes())
-		defer os.Remove(tmpState.Name())
+		tmpState, err := c.writeStateTempFile(state)
 		if err != nil {
 			return nil, err
 		}
+		defer os.Remove(tmpState.Name())
 		args = append(args, "-state="+tmpState.Name())
 	}
 
@@ -28,7 +28,7 @@ func (c *terraformCLI) Import(ctx context.Context, state *State, address string,
 		return nil, fmt.Errorf("failed to build options. The -state-out= option is not allowed. Read a return value: %v", opts)
 	}
 
-	tmpStateOut, err := os.CreateTemp("", "tfstate")
+	tmpStateOut, err := c.createTempFile("tfstate")
 	if err != nil {
 		return nil, fmt.Errorf("failed to create temporary state out file: %s", err)
 	}
@@ -47,9 +47,5 @@ func (c *terraformCLI) Import(ctx context.Context, state *State, address string,
 		return nil, err
 	}
 
-	stateOut, err := os.ReadFile(tmpStateOut.Name())
-	if err != nil {
-		return nil, err
-	}
-	return NewState(stateOut), nil
+	return c.readStateFile(tmpStateOut.Name())
 }
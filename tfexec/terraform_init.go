@@ -8,6 +8,6 @@ import (
 func (c *terraformCLI) Init(ctx context.Context, opts ...string) error {
 	args := []string{"init"}
 	args = append(args, opts...)
-	_, _, err := c.Run(ctx, args...)
+	_, _, err := c.runWithTimeout(ctx, c.timeouts.Init, args...)
 	return err
 }
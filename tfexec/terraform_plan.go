@@ -9,17 +9,40 @@ import (
 // Plan computes expected changes.
 // If a state is given, use it for the input state.
 func (c *terraformCLI) Plan(ctx context.Context, state *State, opts ...string) (*Plan, error) {
+	plan, _, _, err := c.plan(ctx, state, opts...)
+	return plan, err
+}
+
+// PlanWithDiagnostics computes expected changes and returns diagnostics such
+// as warnings reported by terraform plan.
+// The diagnostics are read from the human-readable output, so that the output
+// shown to users doesn't change. If the -json option is given, they are read
+// from the machine-readable UI output instead, which has their addresses.
+// If a state is given, use it for the input state.
+func (c *terraformCLI) PlanWithDiagnostics(ctx context.Context, state *State, opts ...string) (*Plan, []Diagnostic, error) {
+	plan, stdout, stderr, err := c.plan(ctx, state, opts...)
+	if hasPrefixOptions(opts, "-json") {
+		return plan, ParseDiagnostics([]byte(stdout)), err
+	}
+	// Warnings are written to stdout and errors to stderr.
+	diags := append(ParseTextDiagnostics(stdout), ParseTextDiagnostics(stderr)...)
+	return plan, diags, err
+}
+
+// plan is a common implementation of Plan and PlanWithDiagnostics.
+// It also returns stdout and stderr of terraform plan.
+func (c *terraformCLI) plan(ctx context.Context, state *State, opts ...string) (*Plan, string, string, error) {
 	args := []string{"plan"}
 
 	if state != nil {
 		if hasPrefixOptions(opts, "-state=") {
-			return nil, fmt.Errorf("failed to build options. The state argument (!= nil) and the -state= option cannot be set at the same time: state=%v, opts=%v", state, opts)
+			return nil, "", "", fmt.Errorf("failed to build options. The state argument (!= nil) and the -state= option cannot be set at the same time: state=%v, opts=%v", state, opts)
 		}
-		tmpState, err := writeTempFile(state.Bytes())
-		defer os.Remove(tmpState.Name())
+		tmpState, err := c.writeStateTempFile(state)
 		if err != nil {
-			return nil, err
+			return nil, "", "", err
 		}
+		defer os.Remove(tmpState.Name())
 		args = append(args, "-state="+tmpState.Name())
 	}
 
@@ -29,26 +52,26 @@ func (c *terraformCLI) Plan(ctx context.Context, state *State, opts ...string) (
 	if hasPrefixOptions(opts, "-out=") {
 		planOut = getOptionValue(opts, "-out=")
 	} else {
-		tmpPlan, err := os.CreateTemp("", "tfplan")
+		tmpPlan, err := c.createTempFile("tfplan")
 		if err != nil {
-			return nil, fmt.Errorf("failed to create temporary plan file: %s", err)
+			return nil, "", "", fmt.Errorf("failed to create temporary plan file: %s", err)
 		}
 		planOut = tmpPlan.Name()
 		defer os.Remove(planOut)
 
 		if err := tmpPlan.Close(); err != nil {
-			return nil, fmt.Errorf("failed to close temporary plan file: %s", err)
+			return nil, "", "", fmt.Errorf("failed to close temporary plan file: %s", err)
 		}
 		args = append(args, "-out="+planOut)
 	}
 
 	args = append(args, opts...)
 
-	_, _, err := c.Run(ctx, args...)
+	stdout, stderr, err := c.runWithTimeout(ctx, c.timeouts.Plan, args...)
 
 	// terraform plan -detailed-exitcode returns 2 if there is a diff.
 	// So we intentionally ignore an error of read the plan file and returns the
 	// original error of terraform plan command.
 	plan, _ := os.ReadFile(planOut)
-	return NewPlan(plan), err
+	return NewPlan(plan), stdout, stderr, err
 }
@@ -127,6 +127,88 @@ func TestTerraformCLIPlan(t *testing.T) {
 	}
 }
 
+func TestTerraformCLIPlanWithTmpDir(t *testing.T) {
+	state := NewState([]byte("dummy state"))
+	plan := NewPlan([]byte("dummy plan"))
+
+	cases := []struct {
+		desc     string
+		exitCode int
+		ok       bool
+	}{
+		{
+			desc:     "success",
+			exitCode: 0,
+			ok:       true,
+		},
+		{
+			desc:     "failure",
+			exitCode: 1,
+			ok:       false,
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.desc, func(t *testing.T) {
+			tmpDir := t.TempDir()
+			dir := regexp.QuoteMeta(tmpDir + string(filepath.Separator))
+			// check that intermediate files exist in the tmpDir while running.
+			runFunc := func(args ...string) error {
+				for _, arg := range args {
+					if strings.HasPrefix(arg, "-state=") {
+						if _, err := os.Stat(arg[len("-state="):]); err != nil {
+							return fmt.Errorf("failed to find a state file: %s", err)
+						}
+					}
+					if strings.HasPrefix(arg, "-out=") {
+						return os.WriteFile(arg[len("-out="):], plan.Bytes(), 0600)
+					}
+				}
+				return fmt.Errorf("failed to find -out= option: %v", args)
+			}
+			mockCommands := []*mockCommand{
+				{
+					args:     []string{"terraform", "plan", "-state=/path/to/tempfile", "-out=/path/to/planfile"},
+					argsRe:   regexp.MustCompile(`^terraform plan -state=` + dir + `[^/]+ -out=` + dir + `[^/]+$`),
+					runFunc:  runFunc,
+					exitCode: tc.exitCode,
+				},
+			}
+			e := NewMockExecutor(mockCommands)
+			terraformCLI := NewTerraformCLI(e)
+			terraformCLI.SetExecPath("terraform")
+			terraformCLI.SetTmpDir(tmpDir)
+			_, err := terraformCLI.Plan(context.Background(), state)
+			if tc.ok && err != nil {
+				t.Fatalf("unexpected err: %s", err)
+			}
+			if !tc.ok && err == nil {
+				t.Fatal("expected to return an error, but no error")
+			}
+
+			// check that intermediate files are cleaned up.
+			entries, err := os.ReadDir(tmpDir)
+			if err != nil {
+				t.Fatalf("failed to read tmpDir: %s", err)
+			}
+			if len(entries) != 0 {
+				t.Errorf("expected intermediate files to be cleaned up, but got: %v", entries)
+			}
+		})
+	}
+}
+
+func TestTerraformCLIPlanWithInvalidTmpDir(t *testing.T) {
+	e := NewMockExecutor([]*mockCommand{})
+	terraformCLI := NewTerraformCLI(e)
+	terraformCLI.SetExecPath("terraform")
+	terraformCLI.SetTmpDir(filepath.Join(t.TempDir(), "not_found"))
+	_, err := terraformCLI.Plan(context.Background(), NewState([]byte("dummy state")))
+	if err == nil {
+		t.Fatal("expected to return an error, but no error")
+	}
+}
+
 func TestAccTerraformCLIPlan(t *testing.T) {
 	SkipUnlessAcceptanceTestEnabled(t)
 
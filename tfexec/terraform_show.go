@@ -0,0 +1,29 @@
+package tfexec
+
+import (
+	"context"
+	"os"
+)
+
+// Show shows a human-readable or machine-readable output from a plan file.
+// If a plan is given, use it for the input plan.
+func (c *terraformCLI) Show(ctx context.Context, plan *Plan, opts ...string) (string, error) {
+	args := []string{"show"}
+	args = append(args, opts...)
+
+	if plan != nil {
+		tmpPlan, err := c.writeTempFile(plan.Bytes())
+		if err != nil {
+			return "", err
+		}
+		defer os.Remove(tmpPlan.Name())
+		args = append(args, tmpPlan.Name())
+	}
+
+	stdout, _, err := c.Run(ctx, args...)
+	if err != nil {
+		return "", err
+	}
+
+	return stdout, nil
+}
@@ -0,0 +1,78 @@
+package tfexec
+
+import (
+	"context"
+	"regexp"
+	"testing"
+)
+
+func TestTerraformCLIShow(t *testing.T) {
+	plan := NewPlan([]byte("dummy plan"))
+	stdout := `{"format_version":"1.2"}`
+
+	cases := []struct {
+		desc         string
+		mockCommands []*mockCommand
+		plan         *Plan
+		opts         []string
+		want         string
+		ok           bool
+	}{
+		{
+			desc: "no opts",
+			mockCommands: []*mockCommand{
+				{
+					args:     []string{"terraform", "show"},
+					stdout:   "No state.\n",
+					exitCode: 0,
+				},
+			},
+			want: "No state.\n",
+			ok:   true,
+		},
+		{
+			desc: "failed to run terraform show",
+			mockCommands: []*mockCommand{
+				{
+					args:     []string{"terraform", "show"},
+					exitCode: 1,
+				},
+			},
+			want: "",
+			ok:   false,
+		},
+		{
+			desc: "with plan",
+			mockCommands: []*mockCommand{
+				{
+					args:     []string{"terraform", "show", "-json", "-no-color", "/path/to/planfile"},
+					argsRe:   regexp.MustCompile(`^terraform show -json -no-color \S+$`),
+					stdout:   stdout,
+					exitCode: 0,
+				},
+			},
+			plan: plan,
+			opts: []string{"-json", "-no-color"},
+			want: stdout,
+			ok:   true,
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.desc, func(t *testing.T) {
+			e := NewMockExecutor(tc.mockCommands)
+			terraformCLI := NewTerraformCLI(e)
+			terraformCLI.SetExecPath("terraform")
+			got, err := terraformCLI.Show(context.Background(), tc.plan, tc.opts...)
+			if tc.ok && err != nil {
+				t.Fatalf("unexpected err: %s", err)
+			}
+			if !tc.ok && err == nil {
+				t.Fatalf("expected to return an error, but no error, got = %s", got)
+			}
+			if tc.ok && got != tc.want {
+				t.Errorf("got: %s, want: %s", got, tc.want)
+			}
+		})
+	}
+}
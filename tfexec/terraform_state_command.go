@@ -0,0 +1,48 @@
+package tfexec
+
+import (
+	"context"
+	"fmt"
+	"os"
+)
+
+// RunStateCommand runs an arbitrary terraform subcommand which updates a
+// state in-place, such as `state rm`, `taint` and `untaint`.
+// A subcommand is given as a list of words (e.g. []string{"state", "rm"})
+// and opts are passed as is after it.
+// If a state is given, use it for the input state and return a new state.
+// Note that if the input state is not given, always return nil state.
+func (c *terraformCLI) RunStateCommand(ctx context.Context, state *State, subcommand []string, opts ...string) (*State, error) {
+	if len(subcommand) == 0 {
+		return nil, fmt.Errorf("failed to run a state command: subcommand is empty")
+	}
+
+	args := append([]string{}, subcommand...)
+
+	var tmpState *os.File
+	var err error
+	if state != nil {
+		if hasPrefixOptions(opts, "-state=") || hasPrefixOptions(opts, "-state-out=") {
+			return nil, fmt.Errorf("failed to build options. The state argument (!= nil) and the -state= or -state-out= option cannot be set at the same time: state=%v, opts=%v", state, opts)
+		}
+		tmpState, err = c.writeStateTempFile(state)
+		if err != nil {
+			return nil, err
+		}
+		defer os.Remove(tmpState.Name())
+		args = append(args, "-state="+tmpState.Name())
+	}
+
+	args = append(args, opts...)
+
+	_, _, err = c.Run(ctx, args...)
+	if err != nil {
+		return nil, err
+	}
+
+	// Read the updated state in-place as well as StateRm.
+	if state != nil {
+		return c.readStateFile(tmpState.Name())
+	}
+	return nil, nil
+}
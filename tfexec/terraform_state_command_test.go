@@ -0,0 +1,171 @@
+package tfexec
+
+import (
+	"context"
+	"os"
+	"reflect"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+func TestTerraformCLIRunStateCommand(t *testing.T) {
+	state := NewState([]byte("dummy state"))
+	stateOut := NewState([]byte("dummy state out"))
+
+	// mock writing state to a temporary file.
+	runFunc := func(args ...string) error {
+		for _, arg := range args {
+			if strings.HasPrefix(arg, "-state=") {
+				stateFile := arg[len("-state="):]
+				return os.WriteFile(stateFile, stateOut.Bytes(), 0600)
+			}
+		}
+		// if the -state option is not set, nothing to do.
+		return nil
+	}
+
+	cases := []struct {
+		desc         string
+		mockCommands []*mockCommand
+		state        *State
+		subcommand   []string
+		opts         []string
+		want         *State
+		ok           bool
+	}{
+		{
+			desc: "no state",
+			mockCommands: []*mockCommand{
+				{
+					args:     []string{"terraform", "taint", "time_static.foo"},
+					argsRe:   regexp.MustCompile(`^terraform taint time_static.foo$`),
+					runFunc:  runFunc,
+					exitCode: 0,
+				},
+			},
+			state:      nil,
+			subcommand: []string{"taint"},
+			opts:       []string{"time_static.foo"},
+			want:       nil,
+			ok:         true,
+		},
+		{
+			desc: "failed to run terraform command",
+			mockCommands: []*mockCommand{
+				{
+					args:     []string{"terraform", "taint", "time_static.foo"},
+					argsRe:   regexp.MustCompile(`^terraform taint time_static.foo$`),
+					runFunc:  runFunc,
+					exitCode: 1,
+				},
+			},
+			state:      nil,
+			subcommand: []string{"taint"},
+			opts:       []string{"time_static.foo"},
+			want:       nil,
+			ok:         false,
+		},
+		{
+			desc: "with state",
+			mockCommands: []*mockCommand{
+				{
+					args:     []string{"terraform", "state", "rm", "-state=/path/to/tempfile", "-backup=/dev/null", "time_static.foo"},
+					argsRe:   regexp.MustCompile(`^terraform state rm -state=.+ -backup=/dev/null time_static.foo$`),
+					runFunc:  runFunc,
+					exitCode: 0,
+				},
+			},
+			state:      state,
+			subcommand: []string{"state", "rm"},
+			opts:       []string{"-backup=/dev/null", "time_static.foo"},
+			want:       stateOut,
+			ok:         true,
+		},
+		{
+			desc:         "with state and -state-out= (conflict error)",
+			mockCommands: []*mockCommand{},
+			state:        state,
+			subcommand:   []string{"untaint"},
+			opts:         []string{"-state-out=foo.tfstate", "time_static.foo"},
+			want:         nil,
+			ok:           false,
+		},
+		{
+			desc:         "empty subcommand",
+			mockCommands: []*mockCommand{},
+			state:        state,
+			subcommand:   []string{},
+			opts:         []string{"time_static.foo"},
+			want:         nil,
+			ok:           false,
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.desc, func(t *testing.T) {
+			e := NewMockExecutor(tc.mockCommands)
+			terraformCLI := NewTerraformCLI(e)
+			terraformCLI.SetExecPath("terraform")
+			got, err := terraformCLI.RunStateCommand(context.Background(), tc.state, tc.subcommand, tc.opts...)
+			if tc.ok && err != nil {
+				t.Fatalf("unexpected err: %s", err)
+			}
+			if !tc.ok && err == nil {
+				t.Fatal("expected to return an error, but no error")
+			}
+			if tc.ok {
+				if tc.want != nil {
+					if !reflect.DeepEqual(got.Bytes(), tc.want.Bytes()) {
+						t.Errorf("got: %v, want: %v", got, tc.want)
+					}
+				} else { // tc.want == nil
+					if got != nil {
+						t.Errorf("got: %v, want: %v", got, tc.want)
+					}
+				}
+			}
+		})
+	}
+}
+
+func TestAccTerraformCLIRunStateCommand(t *testing.T) {
+	SkipUnlessAcceptanceTestEnabled(t)
+
+	source := `
+resource "null_resource" "foo" {}
+resource "null_resource" "bar" {}
+`
+	e := SetupTestAcc(t, source)
+	terraformCLI := NewTerraformCLI(e)
+
+	err := terraformCLI.Init(context.Background(), "-input=false", "-no-color")
+	if err != nil {
+		t.Fatalf("failed to run terraform init: %s", err)
+	}
+
+	err = terraformCLI.Apply(context.Background(), nil, "-input=false", "-no-color", "-auto-approve")
+	if err != nil {
+		t.Fatalf("failed to run terraform apply: %s", err)
+	}
+
+	state, err := terraformCLI.StatePull(context.Background())
+	if err != nil {
+		t.Fatalf("failed to run terraform state pull: %s", err)
+	}
+
+	updatedState, err := terraformCLI.RunStateCommand(context.Background(), state, []string{"state", "rm"}, "null_resource.foo")
+	if err != nil {
+		t.Fatalf("failed to run terraform state rm: %s", err)
+	}
+
+	got, err := terraformCLI.StateList(context.Background(), updatedState, nil)
+	if err != nil {
+		t.Fatalf("failed to run terraform state list: %s", err)
+	}
+
+	want := []string{"null_resource.bar"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got: %v, want: %v", got, want)
+	}
+}
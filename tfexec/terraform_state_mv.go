@@ -11,6 +11,20 @@ import (
 // If a stateOut argument is given, move resources from state to stateOut.
 // It returns updated the given state and the stateOut.
 func (c *terraformCLI) StateMv(ctx context.Context, state *State, stateOut *State, source string, destination string, opts ...string) (*State, *State, error) {
+	updatedState, updatedStateOut, _, err := c.stateMv(ctx, state, stateOut, source, destination, opts...)
+	return updatedState, updatedStateOut, err
+}
+
+// StateMvWithDiagnostics is the same as StateMv, but also returns
+// diagnostics such as warnings reported by terraform state mv.
+// Since the terraform state mv command doesn't support the -json option,
+// diagnostics are parsed from the human-readable output.
+func (c *terraformCLI) StateMvWithDiagnostics(ctx context.Context, state *State, stateOut *State, source string, destination string, opts ...string) (*State, *State, []Diagnostic, error) {
+	return c.stateMv(ctx, state, stateOut, source, destination, opts...)
+}
+
+// stateMv is a common implementation of StateMv and StateMvWithDiagnostics.
+func (c *terraformCLI) stateMv(ctx context.Context, state *State, stateOut *State, source string, destination string, opts ...string) (*State, *State, []Diagnostic, error) {
 	args := []string{"state", "mv"}
 
 	var tmpState *os.File
@@ -19,55 +33,54 @@ func (c *terraformCLI) StateMv(ctx context.Context, state *State, stateOut *Stat
 
 	if state != nil {
 		if hasPrefixOptions(opts, "-state=") {
-			return nil, nil, fmt.Errorf("failed to build options. The state argument (!= nil) and the -state= option cannot be set at the same time: state=%v, opts=%v", state, opts)
+			return nil, nil, nil, fmt.Errorf("failed to build options. The state argument (!= nil) and the -state= option cannot be set at the same time: state=%v, opts=%v", state, opts)
 		}
-		tmpState, err = writeTempFile(state.Bytes())
-		defer os.Remove(tmpState.Name())
+		tmpState, err = c.writeStateTempFile(state)
 		if err != nil {
-			return nil, nil, err
+			return nil, nil, nil, err
 		}
+		defer os.Remove(tmpState.Name())
 		args = append(args, "-state="+tmpState.Name())
 	}
 
 	if stateOut != nil {
 		if hasPrefixOptions(opts, "-state-out=") {
-			return nil, nil, fmt.Errorf("failed to build options. The stateOut argument (!= nil) and the -state-out= option cannot be set at the same time: stateOut=%v, opts=%v", stateOut, opts)
+			return nil, nil, nil, fmt.Errorf("failed to build options. The stateOut argument (!= nil) and the -state-out= option cannot be set at the same time: stateOut=%v, opts=%v", stateOut, opts)
 		}
-		tmpStateOut, err = writeTempFile(stateOut.Bytes())
-		defer os.Remove(tmpStateOut.Name())
+		tmpStateOut, err = c.writeStateTempFile(stateOut)
 		if err != nil {
-			return nil, nil, err
+			return nil, nil, nil, err
 		}
+		defer os.Remove(tmpStateOut.Name())
 		args = append(args, "-state-out="+tmpStateOut.Name())
 	}
 
 	args = append(args, opts...)
 	args = append(args, source, destination)
 
-	_, _, err = c.Run(ctx, args...)
+	stdout, stderr, err := c.Run(ctx, args...)
 	if err != nil {
-		return nil, nil, err
+		return nil, nil, nil, err
 	}
+	diags := ParseTextDiagnostics(stdout + "\n" + stderr)
 
 	// Read updated states
 	var updatedState *State
 	var updatedStateOut *State
 
 	if state != nil {
-		bytes, err := os.ReadFile(tmpState.Name())
+		updatedState, err = c.readStateFile(tmpState.Name())
 		if err != nil {
-			return nil, nil, err
+			return nil, nil, nil, err
 		}
-		updatedState = NewState(bytes)
 	}
 
 	if stateOut != nil {
-		bytes, err := os.ReadFile(tmpStateOut.Name())
+		updatedStateOut, err = c.readStateFile(tmpStateOut.Name())
 		if err != nil {
-			return nil, nil, err
+			return nil, nil, nil, err
 		}
-		updatedStateOut = NewState(bytes)
 	}
 
-	return updatedState, updatedStateOut, nil
+	return updatedState, updatedStateOut, diags, nil
 }
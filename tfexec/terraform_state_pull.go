@@ -1,6 +1,21 @@
 package tfexec
 
-import "context"
+import (
+	"context"
+	"fmt"
+	"os"
+	"regexp"
+
+	"github.com/hashicorp/go-version"
+)
+
+// maxStateFormatVersion is the latest format version of tfstate we know.
+// It has been 4 since Terraform v0.12.
+const maxStateFormatVersion = 4
+
+// stateTooNewRe is a pattern to parse an error of terraform which refuses to
+// read a state written by a newer version of terraform.
+var stateTooNewRe = regexp.MustCompile(`created by (?:Terraform|OpenTofu) v(\S+), which is newer than current v([^\s;]+)`)
 
 // StatePull returns the current tfstate from remote.
 func (c *terraformCLI) StatePull(ctx context.Context, opts ...string) (*State, error) {
@@ -9,10 +24,81 @@ func (c *terraformCLI) StatePull(ctx context.Context, opts ...string) (*State, e
 	// It's a room for future extensions not to break the interface.
 	args = append(args, opts...)
 
-	stdout, _, err := c.Run(ctx, args...)
+	var tmpState *os.File
+	if c.streamState {
+		var err error
+		tmpState, err = c.createTempFile("tfstate")
+		if err != nil {
+			return nil, fmt.Errorf("failed to create temporary file: %s", err)
+		}
+		ctx = withStdoutWriter(ctx, tmpState)
+	}
+
+	stdout, stderr, err := c.runWithLockRetry(ctx, 0, args...)
 	if err != nil {
+		if tmpState != nil {
+			tmpState.Close()
+			os.Remove(tmpState.Name())
+		}
+		if matched := stateTooNewRe.FindStringSubmatch(stderr); matched != nil {
+			return nil, fmt.Errorf("%s: %w", stateVersionMismatchMessage(matched[1], matched[2]), err)
+		}
 		return nil, err
 	}
 
-	return NewState([]byte(stdout)), nil
+	if tmpState == nil {
+		return NewState([]byte(stdout)), nil
+	}
+
+	// The stdout is captured as usual if the command doesn't support writing
+	// it to the file.
+	if _, err := tmpState.WriteString(stdout); err != nil {
+		tmpState.Close()
+		os.Remove(tmpState.Name())
+		return nil, fmt.Errorf("failed to write temporary file: %s", err)
+	}
+	return newStateFromTempFile(tmpState)
+}
+
+// CheckStateVersion returns an error if a given tfstate cannot be handled by
+// a given version of terraform, that is, the format version of the tfstate
+// is unknown or it was written by a newer version of terraform.
+// The error tells which version of terraform is needed.
+// A tfstate which doesn't look like JSON such as an empty or encrypted one is
+// ignored, because it doesn't have the header to compare.
+func CheckStateVersion(state *State, v *version.Version) error {
+	meta, err := state.Meta()
+	if err != nil {
+		return nil
+	}
+
+	if meta.Version > maxStateFormatVersion {
+		return fmt.Errorf("the state format version %d is not supported by terraform v%s, which supports up to %d. The state may have been written by a newer version of terraform v%s. Upgrade terraform or set terraform_version in the migration", meta.Version, v, maxStateFormatVersion, meta.TerraformVersion)
+	}
+
+	if len(meta.TerraformVersion) == 0 {
+		return nil
+	}
+	stateVersion, err := version.NewVersion(meta.TerraformVersion)
+	if err != nil {
+		return nil
+	}
+	current, err := truncatePreReleaseVersion(v)
+	if err != nil {
+		return err
+	}
+	written, err := truncatePreReleaseVersion(stateVersion)
+	if err != nil {
+		return err
+	}
+	if written.GreaterThan(current) {
+		return fmt.Errorf("%s", stateVersionMismatchMessage(stateVersion.String(), v.String()))
+	}
+	return nil
+}
+
+// stateVersionMismatchMessage returns an actionable message for a state
+// written by a newer version of terraform than the current one.
+func stateVersionMismatchMessage(stateVersion string, currentVersion string) string {
+	return fmt.Sprintf("the state was written by terraform v%s, which is newer than the current v%s. Use terraform v%s or later, for example, set terraform_version in the migration", stateVersion, currentVersion, stateVersion)
 }
@@ -2,8 +2,12 @@ package tfexec
 
 import (
 	"context"
+	"os"
 	"reflect"
+	"strings"
 	"testing"
+
+	"github.com/hashicorp/go-version"
 )
 
 func TestTerraformCLIStatePull(t *testing.T) {
@@ -38,6 +42,18 @@ func TestTerraformCLIStatePull(t *testing.T) {
 			want: nil,
 			ok:   false,
 		},
+		{
+			desc: "state written by a newer version",
+			mockCommands: []*mockCommand{
+				{
+					args:     []string{"terraform", "state", "pull"},
+					stderr:   "Error: state snapshot was created by Terraform v1.6.0, which is newer than current v1.3.0; upgrade to Terraform v1.6.0 or greater to work with this state",
+					exitCode: 1,
+				},
+			},
+			want: nil,
+			ok:   false,
+		},
 		{
 			desc: "with opts", // there is no valid option for now, just pass a dummy for testing.
 			mockCommands: []*mockCommand{
@@ -63,10 +79,140 @@ func TestTerraformCLIStatePull(t *testing.T) {
 				t.Fatalf("unexpected err: %s", err)
 			}
 			if !tc.ok && err == nil {
-				t.Fatalf("expected to return an error, but no error, got = %s", got)
+				t.Fatalf("expected to return an error, but no error, got = %s", got.Bytes())
 			}
 			if tc.ok && !reflect.DeepEqual(got.Bytes(), tc.want.Bytes()) {
-				t.Errorf("got: %s, want: %s", got, tc.want)
+				t.Errorf("got: %s, want: %s", got.Bytes(), tc.want.Bytes())
+			}
+		})
+	}
+}
+
+func TestTerraformCLIStatePullWithStreamState(t *testing.T) {
+	stdout := `{"version": 4, "serial": 3, "lineage": "foo", "resources": []}`
+	mockCommands := []*mockCommand{
+		{
+			args:     []string{"terraform", "state", "pull"},
+			stdout:   stdout,
+			exitCode: 0,
+		},
+	}
+	e := NewMockExecutor(mockCommands)
+	terraformCLI := NewTerraformCLI(e)
+	terraformCLI.SetExecPath("terraform")
+	tmpDir := t.TempDir()
+	terraformCLI.SetTmpDir(tmpDir)
+	terraformCLI.SetStreamState(true)
+
+	got, err := terraformCLI.StatePull(context.Background())
+	if err != nil {
+		t.Fatalf("unexpected err: %s", err)
+	}
+	if got.Size() != int64(len(stdout)) || string(got.Bytes()) != stdout {
+		t.Errorf("got: %s, want: %s", got.Bytes(), stdout)
+	}
+	meta, err := got.Meta()
+	if err != nil {
+		t.Fatalf("failed to parse meta: %s", err)
+	}
+	if meta.Serial != 3 {
+		t.Errorf("got serial: %d, want: 3", meta.Serial)
+	}
+
+	// The temporary file should be removed while the state is alive.
+	entries, err := os.ReadDir(tmpDir)
+	if err != nil {
+		t.Fatalf("failed to read tmp dir: %s", err)
+	}
+	if len(entries) != 0 {
+		t.Errorf("expected the temporary file to be removed, but got: %v", entries)
+	}
+}
+
+func TestTerraformCLIStatePullNewerVersion(t *testing.T) {
+	mockCommands := []*mockCommand{
+		{
+			args:     []string{"terraform", "state", "pull"},
+			stderr:   "Error: state snapshot was created by Terraform v1.6.0, which is newer than current v1.3.0; upgrade to Terraform v1.6.0 or greater to work with this state",
+			exitCode: 1,
+		},
+	}
+	e := NewMockExecutor(mockCommands)
+	terraformCLI := NewTerraformCLI(e)
+	terraformCLI.SetExecPath("terraform")
+	_, err := terraformCLI.StatePull(context.Background())
+	if err == nil {
+		t.Fatal("expected to return an error, but no error")
+	}
+	want := "the state was written by terraform v1.6.0, which is newer than the current v1.3.0. Use terraform v1.6.0 or later"
+	if !strings.Contains(err.Error(), want) {
+		t.Errorf("got: %s, want to contain: %s", err, want)
+	}
+}
+
+func TestCheckStateVersion(t *testing.T) {
+	cases := []struct {
+		desc  string
+		state *State
+		v     string
+		ok    bool
+	}{
+		{
+			desc:  "same version",
+			state: NewState([]byte(`{"version": 4, "terraform_version": "1.5.7"}`)),
+			v:     "1.5.7",
+			ok:    true,
+		},
+		{
+			desc:  "older state",
+			state: NewState([]byte(`{"version": 4, "terraform_version": "0.12.31"}`)),
+			v:     "1.5.7",
+			ok:    true,
+		},
+		{
+			desc:  "newer state",
+			state: NewState([]byte(`{"version": 4, "terraform_version": "1.6.0"}`)),
+			v:     "1.5.7",
+			ok:    false,
+		},
+		{
+			desc:  "pre-release",
+			state: NewState([]byte(`{"version": 4, "terraform_version": "1.6.0-rc1"}`)),
+			v:     "1.6.0",
+			ok:    true,
+		},
+		{
+			desc:  "unknown format version",
+			state: NewState([]byte(`{"version": 5, "terraform_version": "2.0.0"}`)),
+			v:     "2.0.0",
+			ok:    false,
+		},
+		{
+			desc:  "empty state",
+			state: NewState([]byte("")),
+			v:     "1.5.7",
+			ok:    true,
+		},
+		{
+			desc:  "no terraform_version",
+			state: NewState([]byte(`{"version": 4}`)),
+			v:     "1.5.7",
+			ok:    true,
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.desc, func(t *testing.T) {
+			v, err := version.NewVersion(tc.v)
+			if err != nil {
+				t.Fatalf("failed to parse version: %s", err)
+			}
+			err = CheckStateVersion(tc.state, v)
+			if tc.ok && err != nil {
+				t.Fatalf("unexpected err: %s", err)
+			}
+			if !tc.ok && err == nil {
+				t.Fatal("expected to return an error, but no error")
 			}
 		})
 	}
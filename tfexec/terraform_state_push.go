@@ -10,13 +10,13 @@ func (c *terraformCLI) StatePush(ctx context.Context, state *State, opts ...stri
 	args := []string{"state", "push"}
 	args = append(args, opts...)
 
-	tmpState, err := writeTempFile(state.Bytes())
-	defer os.Remove(tmpState.Name())
+	tmpState, err := c.writeStateTempFile(state)
 	if err != nil {
 		return err
 	}
+	defer os.Remove(tmpState.Name())
 
 	args = append(args, tmpState.Name())
-	_, _, err = c.Run(ctx, args...)
+	_, _, err = c.runWithLockRetry(ctx, c.timeouts.Push, args...)
 	return err
 }
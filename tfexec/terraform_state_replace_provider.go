@@ -67,11 +67,11 @@ func (c *terraformCLI) StateReplaceProvider(ctx context.Context, state *State, s
 		if hasPrefixOptions(opts, "-state=") {
 			return nil, fmt.Errorf("failed to build options. The state argument (!= nil) and the -state= option cannot be set at the same time: state=%v, opts=%v", state, opts)
 		}
-		tmpState, err = writeTempFile(state.Bytes())
-		defer os.Remove(tmpState.Name())
+		tmpState, err = c.writeStateTempFile(state)
 		if err != nil {
 			return nil, err
 		}
+		defer os.Remove(tmpState.Name())
 		args = append(args, "-state="+tmpState.Name())
 	}
 
@@ -87,11 +87,10 @@ func (c *terraformCLI) StateReplaceProvider(ctx context.Context, state *State, s
 	var updatedState *State
 
 	if state != nil {
-		bytes, err := os.ReadFile(tmpState.Name())
+		updatedState, err = c.readStateFile(tmpState.Name())
 		if err != nil {
 			return nil, err
 		}
-		updatedState = NewState(bytes)
 	}
 
 	return updatedState, nil
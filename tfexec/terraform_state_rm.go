@@ -19,11 +19,11 @@ func (c *terraformCLI) StateRm(ctx context.Context, state *State, addresses []st
 		if hasPrefixOptions(opts, "-state=") {
 			return nil, fmt.Errorf("failed to build options. The state argument (!= nil) and the -state= option cannot be set at the same time: state=%v, opts=%v", state, opts)
 		}
-		tmpState, err = writeTempFile(state.Bytes())
-		defer os.Remove(tmpState.Name())
+		tmpState, err = c.writeStateTempFile(state)
 		if err != nil {
 			return nil, err
 		}
+		defer os.Remove(tmpState.Name())
 		args = append(args, "-state="+tmpState.Name())
 	}
 
@@ -43,11 +43,7 @@ func (c *terraformCLI) StateRm(ctx context.Context, state *State, addresses []st
 	// The interface is a bit inconsistency against the terraform command,
 	// but we prefer returning a new state to updating the argument in-place.
 	if state != nil {
-		stateOut, err := os.ReadFile(tmpState.Name())
-		if err != nil {
-			return nil, err
-		}
-		return NewState(stateOut), nil
+		return c.readStateFile(tmpState.Name())
 	}
 	// If state == nil, it updates the current default state,
 	// we can read it with calling the state pull command,
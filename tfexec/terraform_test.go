@@ -1,11 +1,15 @@
 package tfexec
 
 import (
+	"archive/zip"
+	"bytes"
 	"context"
 	"fmt"
 	"os"
 	"path/filepath"
+	"strings"
 	"testing"
+	"time"
 )
 
 func TestTerraformCLIRun(t *testing.T) {
@@ -389,3 +393,236 @@ func TestGetOptionValue(t *testing.T) {
 		})
 	}
 }
+
+func TestStateMeta(t *testing.T) {
+	cases := []struct {
+		desc  string
+		state *State
+		want  *StateMeta
+		ok    bool
+	}{
+		{
+			desc: "simple",
+			state: NewState([]byte(`{
+  "version": 4,
+  "terraform_version": "1.9.8",
+  "serial": 3,
+  "lineage": "3d2b8a6c-7c4b-4f5e-9c6a-0e7f7e0b9b1a",
+  "outputs": {},
+  "resources": []
+}`)),
+			want: &StateMeta{
+				Version:          4,
+				TerraformVersion: "1.9.8",
+				Serial:           3,
+				Lineage:          "3d2b8a6c-7c4b-4f5e-9c6a-0e7f7e0b9b1a",
+			},
+			ok: true,
+		},
+		{
+			desc:  "invalid",
+			state: NewState([]byte("foo")),
+			want:  nil,
+			ok:    false,
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.desc, func(t *testing.T) {
+			got, err := tc.state.Meta()
+			if tc.ok && err != nil {
+				t.Fatalf("unexpected err: %s", err)
+			}
+			if !tc.ok && err == nil {
+				t.Fatalf("expected to return an error, but no error, got: %#v", got)
+			}
+			if tc.ok && *got != *tc.want {
+				t.Errorf("got: %#v, want: %#v", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestStateIsEncrypted(t *testing.T) {
+	cases := []struct {
+		desc  string
+		state *State
+		want  bool
+	}{
+		{
+			desc:  "plain",
+			state: NewState([]byte(`{"version": 4, "serial": 3, "lineage": "foo", "resources": []}`)),
+			want:  false,
+		},
+		{
+			desc:  "empty",
+			state: NewState([]byte("")),
+			want:  false,
+		},
+		{
+			desc:  "encrypted by OpenTofu",
+			state: NewState([]byte(`{"serial": 3, "lineage": "foo", "meta": {"key_provider.pbkdf2.foo": "e30="}, "encrypted_data": "Zm9v", "encryption_version": "v0"}`)),
+			want:  true,
+		},
+		{
+			desc:  "not a JSON object",
+			state: NewState([]byte("\x00\x01encrypted")),
+			want:  true,
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.desc, func(t *testing.T) {
+			got := tc.state.IsEncrypted()
+			if got != tc.want {
+				t.Errorf("got: %t, want: %t", got, tc.want)
+			}
+		})
+	}
+}
+
+// newTestPlan builds a dummy plan file which contains given files.
+func newTestPlan(t *testing.T, files map[string]string) *Plan {
+	t.Helper()
+	buf := &bytes.Buffer{}
+	w := zip.NewWriter(buf)
+	for name, contents := range files {
+		f, err := w.Create(name)
+		if err != nil {
+			t.Fatalf("failed to create a file in zip: %s", err)
+		}
+		if _, err := f.Write([]byte(contents)); err != nil {
+			t.Fatalf("failed to write a file in zip: %s", err)
+		}
+	}
+	if err := w.Close(); err != nil {
+		t.Fatalf("failed to close zip: %s", err)
+	}
+	return NewPlan(buf.Bytes())
+}
+
+func TestPlanPriorState(t *testing.T) {
+	cases := []struct {
+		desc string
+		plan *Plan
+		want string
+		ok   bool
+	}{
+		{
+			desc: "simple",
+			plan: newTestPlan(t, map[string]string{
+				"tfplan":  "dummy plan",
+				"tfstate": "dummy state",
+			}),
+			want: "dummy state",
+			ok:   true,
+		},
+		{
+			desc: "prior state not found",
+			plan: newTestPlan(t, map[string]string{
+				"tfplan": "dummy plan",
+			}),
+			want: "",
+			ok:   false,
+		},
+		{
+			desc: "not a zip archive",
+			plan: NewPlan([]byte("foo")),
+			want: "",
+			ok:   false,
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.desc, func(t *testing.T) {
+			got, err := tc.plan.PriorState()
+			if tc.ok && err != nil {
+				t.Fatalf("unexpected err: %s", err)
+			}
+			if !tc.ok && err == nil {
+				t.Fatalf("expected to return an error, but no error, got: %#v", got)
+			}
+			if tc.ok && string(got.Bytes()) != tc.want {
+				t.Errorf("got: %s, want: %s", string(got.Bytes()), tc.want)
+			}
+		})
+	}
+}
+
+func TestTerraformCLIRunWithTimeout(t *testing.T) {
+	cases := []struct {
+		desc     string
+		timeouts Timeouts
+		runFunc  mockRunFunc
+		want     string
+		ok       bool
+	}{
+		{
+			desc:     "no timeout",
+			timeouts: Timeouts{},
+			want:     "",
+			ok:       true,
+		},
+		{
+			desc:     "within timeout",
+			timeouts: Timeouts{Plan: time.Minute},
+			want:     "",
+			ok:       true,
+		},
+		{
+			desc:     "timed out",
+			timeouts: Timeouts{Plan: 10 * time.Millisecond},
+			runFunc: func(_ ...string) error {
+				// Simulate a command killed on the deadline.
+				time.Sleep(50 * time.Millisecond)
+				return fmt.Errorf("signal: killed")
+			},
+			want: "terraform plan timed out after 10ms",
+			ok:   false,
+		},
+		{
+			desc:     "failed within timeout",
+			timeouts: Timeouts{Plan: time.Minute},
+			runFunc: func(_ ...string) error {
+				return fmt.Errorf("failed")
+			},
+			want: "failed",
+			ok:   false,
+		},
+		{
+			desc:     "timeout for other commands",
+			timeouts: Timeouts{Init: 10 * time.Millisecond, Push: 10 * time.Millisecond},
+			runFunc: func(_ ...string) error {
+				time.Sleep(50 * time.Millisecond)
+				return nil
+			},
+			want: "",
+			ok:   true,
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.desc, func(t *testing.T) {
+			mockCommands := []*mockCommand{
+				{
+					args:    []string{"terraform", "plan", "-out=foo.tfplan"},
+					runFunc: tc.runFunc,
+				},
+			}
+			e := NewMockExecutor(mockCommands)
+			terraformCLI := NewTerraformCLI(e)
+			terraformCLI.SetExecPath("terraform")
+			terraformCLI.SetTimeouts(tc.timeouts)
+			_, err := terraformCLI.Plan(context.Background(), nil, "-out=foo.tfplan")
+			if tc.ok && err != nil {
+				t.Fatalf("unexpected err: %s", err)
+			}
+			if !tc.ok && err == nil {
+				t.Fatal("expected to return an error, but no error")
+			}
+			if !tc.ok && !strings.Contains(err.Error(), tc.want) {
+				t.Errorf("expected an error to contain %s, but got: %s", tc.want, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,13 @@
+package tfexec
+
+import (
+	"context"
+)
+
+// Validate validates the configuration files in the current work directory.
+func (c *terraformCLI) Validate(ctx context.Context, opts ...string) error {
+	args := []string{"validate"}
+	args = append(args, opts...)
+	_, _, err := c.Run(ctx, args...)
+	return err
+}
@@ -0,0 +1,103 @@
+package tfexec
+
+import (
+	"context"
+	"testing"
+)
+
+func TestTerraformCLIValidate(t *testing.T) {
+	cases := []struct {
+		desc         string
+		mockCommands []*mockCommand
+		opts         []string
+		ok           bool
+	}{
+		{
+			desc: "no opts",
+			mockCommands: []*mockCommand{
+				{
+					args:     []string{"terraform", "validate"},
+					exitCode: 0,
+				},
+			},
+			ok: true,
+		},
+		{
+			desc: "failed to run terraform validate",
+			mockCommands: []*mockCommand{
+				{
+					args:     []string{"terraform", "validate"},
+					exitCode: 1,
+				},
+			},
+			ok: false,
+		},
+		{
+			desc: "with opts",
+			mockCommands: []*mockCommand{
+				{
+					args:     []string{"terraform", "validate", "-no-color"},
+					exitCode: 0,
+				},
+			},
+			opts: []string{"-no-color"},
+			ok:   true,
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.desc, func(t *testing.T) {
+			e := NewMockExecutor(tc.mockCommands)
+			terraformCLI := NewTerraformCLI(e)
+			terraformCLI.SetExecPath("terraform")
+			err := terraformCLI.Validate(context.Background(), tc.opts...)
+			if tc.ok && err != nil {
+				t.Fatalf("unexpected err: %s", err)
+			}
+			if !tc.ok && err == nil {
+				t.Fatal("expected to return an error, but no error")
+			}
+		})
+	}
+}
+
+func TestAccTerraformCLIValidate(t *testing.T) {
+	SkipUnlessAcceptanceTestEnabled(t)
+
+	cases := []struct {
+		desc   string
+		source string
+		ok     bool
+	}{
+		{
+			desc:   "valid",
+			source: `resource "null_resource" "foo" {}`,
+			ok:     true,
+		},
+		{
+			desc:   "invalid",
+			source: `resource "null_resource" "foo" { foo = "bar" }`,
+			ok:     false,
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.desc, func(t *testing.T) {
+			e := SetupTestAcc(t, tc.source)
+			terraformCLI := NewTerraformCLI(e)
+
+			err := terraformCLI.Init(context.Background(), "-input=false", "-no-color")
+			if err != nil {
+				t.Fatalf("failed to run terraform init: %s", err)
+			}
+
+			err = terraformCLI.Validate(context.Background(), "-no-color")
+			if tc.ok && err != nil {
+				t.Fatalf("unexpected err: %s", err)
+			}
+			if !tc.ok && err == nil {
+				t.Fatal("expected to return an error, but no error")
+			}
+		})
+	}
+}
@@ -3,6 +3,7 @@ package tfexec
 import (
 	"context"
 	"fmt"
+	"io"
 	"os"
 	"path"
 	"path/filepath"
@@ -84,6 +85,16 @@ func (e *mockExecutor) AppendEnv(_ string, _ string) {
 	// no op.
 }
 
+// SetOutput sets writers where the stdout and stderr of commands are copied.
+func (e *mockExecutor) SetOutput(_ io.Writer, _ io.Writer) {
+	// no op.
+}
+
+// SetCommandLog sets a writer where each command line is written.
+func (e *mockExecutor) SetCommandLog(_ io.Writer) {
+	// no op.
+}
+
 // mockRunFunc is a type for callback of mockCommand.Run() to allow us to cause side effects.
 type mockRunFunc func(args ...string) error
 
@@ -0,0 +1,78 @@
+package tfexec
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/mattn/go-shellwords"
+)
+
+// versionManager is a set of settings for a version manager of terraform or
+// OpenTofu such as tfenv and tofuenv.
+type versionManager struct {
+	// name is a name of the version manager.
+	name string
+	// binary is a name of the binary installed by the version manager.
+	binary string
+	// rootEnv is an environment variable which overrides the root directory.
+	rootEnv string
+}
+
+var (
+	// tfenv is a version manager for terraform.
+	// https://github.com/tfutils/tfenv
+	tfenv = versionManager{name: "tfenv", binary: "terraform", rootEnv: "TFENV_ROOT"}
+	// tofuenv is a version manager for OpenTofu.
+	// https://github.com/tofuutils/tofuenv
+	tofuenv = versionManager{name: "tofuenv", binary: "tofu", rootEnv: "TOFUENV_ROOT"}
+)
+
+// ResolveExecPathForVersion returns a path of terraform binary for a given version.
+// If the paths map contains the version, return its path.
+// Otherwise, look for the binary installed by a version manager.
+// We use tofuenv if the execPath refers OpenTofu, otherwise tfenv.
+// The root directory of the version manager defaults to ~/.tfenv or
+// ~/.tofuenv, and can be overridden by TFENV_ROOT or TOFUENV_ROOT.
+// It returns an error if the requested version isn't installed.
+func ResolveExecPathForVersion(execPath string, v string, paths map[string]string) (string, error) {
+	if p, ok := paths[v]; ok {
+		return p, nil
+	}
+
+	vm := tfenv
+	if isOpenTofuExecPath(execPath) {
+		vm = tofuenv
+	}
+
+	root := os.Getenv(vm.rootEnv)
+	if len(root) == 0 {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return "", fmt.Errorf("failed to find a home directory for %s: %s", vm.name, err)
+		}
+		root = filepath.Join(home, "."+vm.name)
+	}
+
+	path := filepath.Join(root, "versions", v, vm.binary)
+	if _, err := os.Stat(path); err != nil {
+		return "", fmt.Errorf("%s version %s is not installed: not found in terraform_version_paths nor %s (%s). Try `%s install %s`", vm.binary, v, vm.name, path, vm.name, v)
+	}
+
+	return path, nil
+}
+
+// isOpenTofuExecPath returns true if a given execPath refers OpenTofu.
+// The execPath may contain a wrapper command such as `direnv exec . tofu`.
+func isOpenTofuExecPath(execPath string) bool {
+	parts, err := shellwords.Parse(execPath)
+	if err != nil {
+		return false
+	}
+	for _, p := range parts {
+		if filepath.Base(p) == "tofu" {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,86 @@
+package tfexec
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestResolveExecPathForVersion(t *testing.T) {
+	tfenvRoot := t.TempDir()
+	tofuenvRoot := t.TempDir()
+	for _, p := range []string{
+		filepath.Join(tfenvRoot, "versions", "1.5.7", "terraform"),
+		filepath.Join(tofuenvRoot, "versions", "1.6.2", "tofu"),
+	} {
+		if err := os.MkdirAll(filepath.Dir(p), 0700); err != nil {
+			t.Fatalf("failed to create dir: %s", err)
+		}
+		if err := os.WriteFile(p, []byte{}, 0600); err != nil {
+			t.Fatalf("failed to write file: %s", err)
+		}
+	}
+	t.Setenv("TFENV_ROOT", tfenvRoot)
+	t.Setenv("TOFUENV_ROOT", tofuenvRoot)
+
+	cases := []struct {
+		desc     string
+		execPath string
+		version  string
+		paths    map[string]string
+		want     string
+		ok       bool
+	}{
+		{
+			desc:     "paths map takes precedence",
+			execPath: "terraform",
+			version:  "1.5.7",
+			paths:    map[string]string{"1.5.7": "/opt/terraform/1.5.7/terraform"},
+			want:     "/opt/terraform/1.5.7/terraform",
+			ok:       true,
+		},
+		{
+			desc:     "tfenv",
+			execPath: "terraform",
+			version:  "1.5.7",
+			want:     filepath.Join(tfenvRoot, "versions", "1.5.7", "terraform"),
+			ok:       true,
+		},
+		{
+			desc:     "tofuenv",
+			execPath: "direnv exec . tofu",
+			version:  "1.6.2",
+			want:     filepath.Join(tofuenvRoot, "versions", "1.6.2", "tofu"),
+			ok:       true,
+		},
+		{
+			desc:     "not installed",
+			execPath: "terraform",
+			version:  "0.12.31",
+			want:     "",
+			ok:       false,
+		},
+		{
+			desc:     "not installed for OpenTofu",
+			execPath: "tofu",
+			version:  "1.5.7",
+			want:     "",
+			ok:       false,
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.desc, func(t *testing.T) {
+			got, err := ResolveExecPathForVersion(tc.execPath, tc.version, tc.paths)
+			if tc.ok && err != nil {
+				t.Fatalf("unexpected err: %s", err)
+			}
+			if !tc.ok && err == nil {
+				t.Fatalf("expected to return an error, but no error, got: %s", got)
+			}
+			if tc.ok && got != tc.want {
+				t.Errorf("got: %s, want: %s", got, tc.want)
+			}
+		})
+	}
+}
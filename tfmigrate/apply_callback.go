@@ -0,0 +1,52 @@
+package tfmigrate
+
+import (
+	"context"
+	"log"
+)
+
+// ApplySummary is a structured summary of a migration which has been applied
+// successfully. It's passed to an ApplyCallback.
+type ApplySummary struct {
+	// StateLists is a list of resource addresses in each remote state before
+	// and after the migration. A multi_state migration has two results, one
+	// for the from state and one for the to state.
+	StateLists []StateListResult
+	// PlanResults is a summary of terraform plan for each working directory.
+	// It's empty if the plan was skipped or a saved plan file was verified.
+	PlanResults []PlanResult
+}
+
+// ApplyCallback is a function called after a migration has been applied
+// successfully. It's intended to hook post-migration processing, such as
+// notifications or audit logs, when embedding tfmigrate as a library.
+// It's not called on dry-run or on failure.
+type ApplyCallback func(ctx context.Context, summary ApplySummary)
+
+// notifyApplied calls a given callback with a summary built from given
+// collectors. It's a no-op if the callback is nil.
+// A panic in the callback is recovered and logged, because the migration has
+// already been applied and its outcome should not be changed.
+func notifyApplied(ctx context.Context, cb ApplyCallback, planResults *PlanResultCollector, stateLists *StateListCollector) {
+	if cb == nil {
+		return
+	}
+
+	summary := ApplySummary{
+		StateLists:  []StateListResult{},
+		PlanResults: []PlanResult{},
+	}
+	if planResults != nil {
+		summary.PlanResults = planResults.Results()
+	}
+	if stateLists != nil {
+		summary.StateLists = stateLists.Results()
+	}
+
+	defer func() {
+		if r := recover(); r != nil {
+			log.Printf("[ERROR] [migrator] apply callback panicked: %v\n", r)
+		}
+	}()
+	cb(ctx, summary)
+}
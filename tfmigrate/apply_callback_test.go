@@ -0,0 +1,72 @@
+package tfmigrate
+
+import (
+	"context"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestNotifyApplied(t *testing.T) {
+	planResults := NewPlanResultCollector()
+	planResults.Add(PlanResult{Dir: "dir1", ChangedAddresses: []string{}})
+	stateLists := NewStateListCollector(false)
+	stateLists.Add(StateListResult{Dir: "dir1", Workspace: "default", Before: []string{"null_resource.foo"}, After: []string{"null_resource.bar"}})
+
+	cases := []struct {
+		desc        string
+		planResults *PlanResultCollector
+		stateLists  *StateListCollector
+		want        ApplySummary
+	}{
+		{
+			desc:        "with results",
+			planResults: planResults,
+			stateLists:  stateLists,
+			want: ApplySummary{
+				StateLists:  []StateListResult{{Dir: "dir1", Workspace: "default", Before: []string{"null_resource.foo"}, After: []string{"null_resource.bar"}}},
+				PlanResults: []PlanResult{{Dir: "dir1", ChangedAddresses: []string{}}},
+			},
+		},
+		{
+			desc:        "no collectors",
+			planResults: nil,
+			stateLists:  nil,
+			want: ApplySummary{
+				StateLists:  []StateListResult{},
+				PlanResults: []PlanResult{},
+			},
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.desc, func(t *testing.T) {
+			called := 0
+			var got ApplySummary
+			cb := func(_ context.Context, summary ApplySummary) {
+				called++
+				got = summary
+			}
+			notifyApplied(context.Background(), cb, tc.planResults, tc.stateLists)
+			if called != 1 {
+				t.Fatalf("got %d calls, want: 1", called)
+			}
+			if diff := cmp.Diff(got, tc.want); diff != "" {
+				t.Errorf("got: %#v, want: %#v, diff: %s", got, tc.want, diff)
+			}
+		})
+	}
+}
+
+func TestNotifyAppliedNoCallback(t *testing.T) {
+	// should not panic.
+	notifyApplied(context.Background(), nil, NewPlanResultCollector(), NewStateListCollector(false))
+}
+
+func TestNotifyAppliedRecoversPanic(t *testing.T) {
+	cb := func(_ context.Context, _ ApplySummary) {
+		panic("boom")
+	}
+	// a panic in the callback should not be propagated.
+	notifyApplied(context.Background(), cb, nil, nil)
+}
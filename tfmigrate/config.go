@@ -1,5 +1,16 @@
 package tfmigrate
 
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/minamijoyo/tfmigrate/tfexec"
+	"go.opentelemetry.io/otel/trace"
+)
+
 // MigrationConfig is a config for a migration.
 type MigrationConfig struct {
 	// Type is a type for migration.
@@ -9,6 +20,28 @@ type MigrationConfig struct {
 	Name string
 	// Migrator is an interface of factory method for Migrator.
 	Migrator MigratorConfig
+	// Timeout is a timeout for the whole migration.
+	// A zero value means no timeout.
+	Timeout time.Duration
+	// Retries is a maximum number of retries when the state is locked, which
+	// overrides the attempts of LockRetry in MigratorOption.
+	// A zero value means not overridden.
+	Retries int
+	// Env is a map of environment variables passed to terraform command in
+	// the migration, which is merged into the Env of MigratorOption.
+	Env map[string]string
+}
+
+// NewMigrator returns a new instance of Migrator with the settings of the
+// migration, which take precedence over the ones in a given MigratorOption.
+func (c *MigrationConfig) NewMigrator(o *MigratorOption) (Migrator, error) {
+	if c.Retries > 0 {
+		o = withLockRetryAttempts(o, c.Retries)
+	}
+	if len(c.Env) > 0 {
+		o = withEnv(o, c.Env)
+	}
+	return c.Migrator.NewMigrator(o)
 }
 
 // MigratorConfig is an interface of factory method for Migrator.
@@ -29,9 +62,320 @@ type MigratorOption struct {
 	// PlanOut is a path to plan file to be saved.
 	PlanOut string
 
+	// PlanFile is a path to a plan file previously saved with PlanOut.
+	// If set, Apply verifies the saved plan instead of running a new plan,
+	// which guarantees that what we reviewed is what we verify against.
+	// It's only supported for a single state migration.
+	PlanFile string
+
+	// Only is a list of address patterns to apply a migration partially.
+	// If set, only actions which change addresses matching any of them are
+	// applied, and changes of the other actions are allowed in plan.
+	// It's only supported for a single state migration.
+	Only []string
+
+	// Idempotent skips the actions if the current state already reflects
+	// the migration, that is, no moves are needed. The plan for verification
+	// runs against the current state, and nothing is pushed. It's intended
+	// for a migration which has been applied manually.
+	// It's only supported for a single state migration.
+	Idempotent bool
+
 	// IsBackendTerraformCloud is a boolean indicating if the remote backend is Terraform Cloud
 	IsBackendTerraformCloud bool
 
 	// BackendConfig is a -backend-config option for remote state
 	BackendConfig []string
+
+	// TerraformVersionPaths is a map of terraform version to a path of binary.
+	// It's used for resolving a terraform_version in a migration block.
+	// If a version is not found in the map, look for a binary installed by
+	// tfenv or tofuenv.
+	TerraformVersionPaths map[string]string
+
+	// ExecPathResolver selects a terraform binary based on a working directory.
+	// If set, it takes precedence over the ExecPath.
+	ExecPathResolver *tfexec.ExecPathResolver
+
+	// PlanResultCollector collects a summary of terraform plan for each
+	// working directory. If nil, the summary is not collected.
+	PlanResultCollector *PlanResultCollector
+
+	// DiagnosticsCollector collects diagnostics such as warnings reported by
+	// terraform plan for each working directory. If nil, the diagnostics are
+	// not collected.
+	DiagnosticsCollector *DiagnosticsCollector
+
+	// WarningsAsErrors fails a migration if terraform plan for verification
+	// reports any warnings, such as deprecations, not allowed by the
+	// AllowedWarnings.
+	WarningsAsErrors bool
+
+	// AllowedWarnings is a list of summaries of warnings which are ignored
+	// by WarningsAsErrors and StateMvWarningsAsErrors.
+	AllowedWarnings []string
+
+	// StateMvWarningsAsErrors fails a migration if terraform state mv reports
+	// any warnings, such as a missing provider configuration for a
+	// destination module, not allowed by the AllowedWarnings.
+	StateMvWarningsAsErrors bool
+
+	// StreamState streams a state pulled by terraform state pull to a
+	// temporary file instead of holding it in memory, and the temporary
+	// states written by terraform state commands as well. It reduces memory
+	// usage for a large state.
+	StreamState bool
+
+	// StateListCollector collects a list of resource addresses in a remote
+	// state after push for each working directory. If nil, the list is not
+	// collected.
+	StateListCollector *StateListCollector
+
+	// ApplyCallback is called with a summary of a migration after it has been
+	// applied successfully. If nil, nothing is called.
+	ApplyCallback ApplyCallback
+
+	// StateEncryption is a configuration of OpenTofu state encryption.
+	// It's passed to terraform command as the TF_ENCRYPTION environment
+	// variable. If empty, the environment variable is inherited as it is.
+	StateEncryption string
+
+	// Env is a map of environment variables passed to terraform command in
+	// addition to the ones of the current process. It's intended for
+	// credentials of a backend and providers such as AWS_PROFILE. Note that
+	// it's never used for a history storage, which has its own credentials.
+	Env map[string]string
+
+	// DryRun skips pushing new states to remote on apply.
+	// The new states are saved to a scratch directory instead.
+	DryRun bool
+
+	// DryRunDir is a directory where new states are saved on apply with DryRun
+	// for inspection. Each run creates a new directory under it. If empty,
+	// new states are not saved, because they contain secrets as the same as
+	// remote states.
+	DryRunDir string
+
+	// StateOut is a path to write a new state to on apply with DryRun instead
+	// of the DryRunDir. The state is ready to push by PushStateFile.
+	// It's only supported for a single state migration.
+	StateOut string
+
+	// ReadOnly refuses any terraform command which may mutate remote state or
+	// real resources at the lowest level, such as state push.
+	ReadOnly bool
+
+	// LockRetry is a setting of retries with backoff for terraform state pull
+	// and push when the state is locked by another process.
+	LockRetry tfexec.LockRetry
+
+	// SkipInit assumes working dirs are already initialized and skips
+	// terraform init. It's intended for pipelines which run init separately.
+	SkipInit bool
+
+	// Tracer is an OpenTelemetry tracer to start spans of a run, migrations
+	// and their phases. If nil, the global TracerProvider is used, which is a
+	// no-op unless it's set by a program embedding tfmigrate.
+	Tracer trace.Tracer
+
+	// TmpDir is a directory where intermediate state and plan files are
+	// written. It's also used for backups of states.
+	// Default to the default directory for temporary files.
+	TmpDir string
+
+	// PluginCacheDir is a directory of terraform plugin cache shared across
+	// migrations to avoid downloading providers on every init. It's passed to
+	// terraform command as the TF_PLUGIN_CACHE_DIR environment variable.
+	// If empty, the environment variable is inherited as it is.
+	PluginCacheDir string
+
+	// Validate runs terraform validate after init and before plan, so that
+	// an invalid configuration fails fast apart from a wrong migration.
+	Validate bool
+
+	// DefaultDir is a default working directory for migrations which don't
+	// set the dir attribute. If empty, default to `.` (current directory).
+	// Note that it's not applied to from_dir and to_dir of multi_state
+	// migrations, because they are required.
+	DefaultDir string
+
+	// WorkDir is a base directory where terraform commands are executed.
+	// If set, a relative dir in a migration is resolved against it instead of
+	// the current directory. It allows us to run migrations against a
+	// generated config tree while keeping migration files in source.
+	WorkDir string
+
+	// InitTimeout is a timeout for each terraform init.
+	// A zero value means no timeout.
+	InitTimeout time.Duration
+
+	// PlanTimeout is a timeout for each terraform plan.
+	// A zero value means no timeout.
+	PlanTimeout time.Duration
+
+	// PushTimeout is a timeout for each terraform state push on apply.
+	// A zero value means no timeout.
+	PushTimeout time.Duration
+
+	// InitArgs is a list of extra arguments passed to terraform init for
+	// working dirs such as `-upgrade` and `-reconfigure`. It's not passed to
+	// terraform init for switching the backend.
+	InitArgs []string
+
+	// Parallelism limits the number of concurrent operations of terraform
+	// plan for verification. It's passed as the -parallelism option.
+	// A zero value means the default of terraform.
+	// Note that it's not a parallelism of migrations.
+	Parallelism int
+
+	// Stdout is a writer where the stdout of terraform commands is copied
+	// while running. It's intended to stream live output when embedding
+	// tfmigrate as a library. Note that it includes the output of terraform
+	// state pull, which may contain sensitive values. If nil, the output is
+	// only captured for parsing and error messages.
+	Stdout io.Writer
+
+	// Stderr is a writer where the stderr of terraform commands is copied
+	// while running. If nil, the output is only captured.
+	Stderr io.Writer
+
+	// CommandLog is a writer where each terraform command line is written
+	// before execution. It's intended for the --verbose flag. Note that it's
+	// not redacted, so wrap it with a redactor if needed. If nil, command
+	// lines are only logged at DEBUG level.
+	CommandLog io.Writer
+}
+
+// timeouts returns a set of timeouts for terraform commands.
+func (o *MigratorOption) timeouts() tfexec.Timeouts {
+	return tfexec.Timeouts{
+		Init: o.InitTimeout,
+		Plan: o.PlanTimeout,
+		Push: o.PushTimeout,
+	}
+}
+
+// initOptions returns options of terraform init for working dirs.
+func (o *MigratorOption) initOptions() []string {
+	opts := []string{"-input=false", "-no-color"}
+	return append(opts, o.InitArgs...)
+}
+
+// planOptions returns options of terraform plan for verification.
+func (o *MigratorOption) planOptions() []string {
+	opts := []string{"-input=false", "-no-color", "-detailed-exitcode"}
+	if o.PlanOut != "" {
+		opts = append(opts, "-out="+o.PlanOut)
+	}
+	if o.Parallelism > 0 {
+		opts = append(opts, fmt.Sprintf("-parallelism=%d", o.Parallelism))
+	}
+	return opts
+}
+
+// migrationDir returns a working directory of a migration for a given dir
+// attribute. If the dir is empty, it falls back to the DefaultDir in a given
+// MigratorOption, and then to `.` (current directory).
+func migrationDir(o *MigratorOption, dir string) string {
+	if len(dir) > 0 {
+		return dir
+	}
+	if o != nil && len(o.DefaultDir) > 0 {
+		return o.DefaultDir
+	}
+	return "."
+}
+
+// resolveWorkDir returns a directory where terraform commands are executed
+// for a given dir in a migration. Note that the dir in a migration is still
+// used as it is for anything else, such as selecting a terraform binary.
+func resolveWorkDir(o *MigratorOption, dir string) string {
+	if o == nil || len(o.WorkDir) == 0 || filepath.IsAbs(dir) {
+		return dir
+	}
+	return filepath.Join(o.WorkDir, dir)
+}
+
+// withExecPathForDir returns a copy of a given MigratorOption whose ExecPath
+// is resolved for a given working directory by the ExecPathResolver.
+// If the ExecPathResolver is not set, return the option as is.
+func withExecPathForDir(o *MigratorOption, dir string) (*MigratorOption, error) {
+	if o == nil || o.ExecPathResolver == nil {
+		return o, nil
+	}
+
+	execPath, err := o.ExecPathResolver.Resolve(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	newOption := *o
+	newOption.ExecPath = execPath
+	return &newOption, nil
+}
+
+// withValidate returns a copy of a given MigratorOption with Validate enabled.
+func withValidate(o *MigratorOption) *MigratorOption {
+	newOption := &MigratorOption{}
+	if o != nil {
+		*newOption = *o
+	}
+	newOption.Validate = true
+	return newOption
+}
+
+// withLockRetryAttempts returns a copy of a given MigratorOption whose
+// attempts of LockRetry are set to a given value.
+// The original option is not modified because it's shared across migrations.
+func withLockRetryAttempts(o *MigratorOption, attempts int) *MigratorOption {
+	newOption := &MigratorOption{}
+	if o != nil {
+		*newOption = *o
+	}
+	newOption.LockRetry.Attempts = attempts
+	return newOption
+}
+
+// withEnv returns a copy of a given MigratorOption whose Env is merged with a
+// given map of environment variables, which take precedence over the
+// original ones. The original option is not modified because it's shared
+// across migrations.
+func withEnv(o *MigratorOption, env map[string]string) *MigratorOption {
+	newOption := &MigratorOption{}
+	if o != nil {
+		*newOption = *o
+	}
+	newOption.Env = make(map[string]string, len(newOption.Env)+len(env))
+	if o != nil {
+		for k, v := range o.Env {
+			newOption.Env[k] = v
+		}
+	}
+	for k, v := range env {
+		newOption.Env[k] = v
+	}
+	return newOption
+}
+
+// withTerraformVersion returns a copy of a given MigratorOption whose ExecPath
+// is set to a terraform binary for a given version.
+// The original option is not modified because it's shared across migrations.
+func withTerraformVersion(o *MigratorOption, v string) (*MigratorOption, error) {
+	newOption := &MigratorOption{}
+	if o != nil {
+		*newOption = *o
+	}
+
+	execPath := newOption.ExecPath
+	if len(execPath) == 0 {
+		execPath = os.Getenv("TFMIGRATE_EXEC_PATH")
+	}
+
+	path, err := tfexec.ResolveExecPathForVersion(execPath, v, newOption.TerraformVersionPaths)
+	if err != nil {
+		return nil, fmt.Errorf("failed to resolve terraform_version: %s", err)
+	}
+	newOption.ExecPath = path
+
+	return newOption, nil
 }
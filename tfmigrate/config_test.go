@@ -0,0 +1,212 @@
+package tfmigrate
+
+import (
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/minamijoyo/tfmigrate/tfexec"
+)
+
+func TestResolveWorkDir(t *testing.T) {
+	cases := []struct {
+		desc string
+		o    *MigratorOption
+		dir  string
+		want string
+	}{
+		{
+			desc: "nil option",
+			o:    nil,
+			dir:  "dir1",
+			want: "dir1",
+		},
+		{
+			desc: "work dir not set",
+			o:    &MigratorOption{},
+			dir:  "dir1",
+			want: "dir1",
+		},
+		{
+			desc: "relative dir",
+			o:    &MigratorOption{WorkDir: "/tmp/rendered"},
+			dir:  "dir1",
+			want: "/tmp/rendered/dir1",
+		},
+		{
+			desc: "current dir",
+			o:    &MigratorOption{WorkDir: "rendered"},
+			dir:  ".",
+			want: "rendered",
+		},
+		{
+			desc: "absolute dir",
+			o:    &MigratorOption{WorkDir: "/tmp/rendered"},
+			dir:  "/src/dir1",
+			want: "/src/dir1",
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.desc, func(t *testing.T) {
+			got := resolveWorkDir(tc.o, tc.dir)
+			if got != tc.want {
+				t.Errorf("got: %s, want: %s", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestMigrationDir(t *testing.T) {
+	cases := []struct {
+		desc string
+		o    *MigratorOption
+		dir  string
+		want string
+	}{
+		{
+			desc: "nil option",
+			o:    nil,
+			dir:  "",
+			want: ".",
+		},
+		{
+			desc: "default dir not set",
+			o:    &MigratorOption{},
+			dir:  "",
+			want: ".",
+		},
+		{
+			desc: "inherit default dir",
+			o:    &MigratorOption{DefaultDir: "env/prod"},
+			dir:  "",
+			want: "env/prod",
+		},
+		{
+			desc: "override default dir",
+			o:    &MigratorOption{DefaultDir: "env/prod"},
+			dir:  "env/stg",
+			want: "env/stg",
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.desc, func(t *testing.T) {
+			got := migrationDir(tc.o, tc.dir)
+			if got != tc.want {
+				t.Errorf("got: %s, want: %s", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestMigratorOptionPlanOptions(t *testing.T) {
+	cases := []struct {
+		desc string
+		o    *MigratorOption
+		want []string
+	}{
+		{
+			desc: "default",
+			o:    &MigratorOption{},
+			want: []string{"-input=false", "-no-color", "-detailed-exitcode"},
+		},
+		{
+			desc: "with plan out",
+			o:    &MigratorOption{PlanOut: "foo.tfplan"},
+			want: []string{"-input=false", "-no-color", "-detailed-exitcode", "-out=foo.tfplan"},
+		},
+		{
+			desc: "with parallelism",
+			o:    &MigratorOption{Parallelism: 5},
+			want: []string{"-input=false", "-no-color", "-detailed-exitcode", "-parallelism=5"},
+		},
+		{
+			desc: "with plan out and parallelism",
+			o:    &MigratorOption{PlanOut: "foo.tfplan", Parallelism: 1},
+			want: []string{"-input=false", "-no-color", "-detailed-exitcode", "-out=foo.tfplan", "-parallelism=1"},
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.desc, func(t *testing.T) {
+			got := tc.o.planOptions()
+			if diff := cmp.Diff(got, tc.want); diff != "" {
+				t.Errorf("got: %v, want: %v, diff: %s", got, tc.want, diff)
+			}
+		})
+	}
+}
+
+// optionRecorder is a MigratorConfig which records a given MigratorOption.
+type optionRecorder struct {
+	o *MigratorOption
+}
+
+func (c *optionRecorder) NewMigrator(o *MigratorOption) (Migrator, error) {
+	c.o = o
+	return NewMockMigrator(false, false), nil
+}
+
+func TestMigrationConfigNewMigrator(t *testing.T) {
+	cases := []struct {
+		desc    string
+		retries int
+		o       *MigratorOption
+		want    tfexec.LockRetry
+	}{
+		{
+			desc:    "not overridden",
+			retries: 0,
+			o:       &MigratorOption{LockRetry: tfexec.LockRetry{Attempts: 3, Interval: time.Second}},
+			want:    tfexec.LockRetry{Attempts: 3, Interval: time.Second},
+		},
+		{
+			desc:    "overridden",
+			retries: 10,
+			o:       &MigratorOption{LockRetry: tfexec.LockRetry{Attempts: 3, Interval: time.Second}},
+			want:    tfexec.LockRetry{Attempts: 10, Interval: time.Second},
+		},
+		{
+			desc:    "nil option",
+			retries: 10,
+			o:       nil,
+			want:    tfexec.LockRetry{Attempts: 10},
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.desc, func(t *testing.T) {
+			var orig MigratorOption
+			if tc.o != nil {
+				orig = *tc.o
+			}
+			recorder := &optionRecorder{}
+			mc := &MigrationConfig{Migrator: recorder, Retries: tc.retries}
+			if _, err := mc.NewMigrator(tc.o); err != nil {
+				t.Fatalf("unexpected err: %s", err)
+			}
+			if recorder.o == nil {
+				t.Fatal("the option was not passed to the migrator")
+			}
+			if diff := cmp.Diff(recorder.o.LockRetry, tc.want); diff != "" {
+				t.Errorf("got: %v, want: %v, diff: %s", recorder.o.LockRetry, tc.want, diff)
+			}
+			if tc.o != nil && tc.o.LockRetry != orig.LockRetry {
+				t.Errorf("the original option was modified: %v", tc.o.LockRetry)
+			}
+		})
+	}
+}
+
+func TestWithEnv(t *testing.T) {
+	o := &MigratorOption{Env: map[string]string{"AWS_PROFILE": "dev", "AWS_REGION": "ap-northeast-1"}}
+	got := withEnv(o, map[string]string{"AWS_PROFILE": "prod"})
+	want := map[string]string{"AWS_PROFILE": "prod", "AWS_REGION": "ap-northeast-1"}
+	if diff := cmp.Diff(got.Env, want); diff != "" {
+		t.Errorf("got: %v, want: %v, diff: %s", got.Env, want, diff)
+	}
+	if o.Env["AWS_PROFILE"] != "dev" {
+		t.Errorf("the original option was modified: %v", o.Env)
+	}
+}
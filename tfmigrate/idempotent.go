@@ -0,0 +1,182 @@
+package tfmigrate
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/minamijoyo/tfmigrate/tfexec"
+)
+
+// stateMigrated returns true if a given state already reflects all actions of
+// the migration, which is checked for the --idempotent flag.
+func (m *StateMigrator) stateMigrated(ctx context.Context, state *tfexec.State) (bool, error) {
+	stateList, err := m.tf.StateList(ctx, state, nil)
+	if err != nil {
+		return false, err
+	}
+	return actionsAppliedTo(m.actions, stateList), nil
+}
+
+// actionsAppliedTo returns true if all given actions have already been
+// applied to a given state list, that is, no moves are needed.
+// A mv action has been applied if the source is absent and the destination is
+// present. An xmv action without wildcards is the same as mv. An xmv action
+// with wildcards has been applied if it matches no source and the state has
+// at least one destination which could have been moved from the source, and
+// the number of such destinations equals --expect-matches if given.
+// Note that a broad destination may also match resources which have not been
+// moved by the xmv, but the plan for verification still guards against it.
+// It returns false if any other action is contained, because we cannot tell
+// whether it has been applied or not.
+func actionsAppliedTo(actions []StateAction, stateList []string) bool {
+	for i, action := range actions {
+		switch a := action.(type) {
+		case *StateMvAction:
+			if !a.appliedTo(stateList) {
+				log.Printf("[INFO] [migrator] idempotent: the action #%d has not been applied yet: mv %s %s\n", i, a.source, a.destination)
+				return false
+			}
+
+		case *StateXmvAction:
+			e := newXmvExpander(a)
+			if !e.hasPattern() {
+				if !NewStateMvAction(a.source, a.destination).appliedTo(stateList) {
+					log.Printf("[INFO] [migrator] idempotent: the action #%d has not been applied yet: xmv %s %s\n", i, a.source, a.destination)
+					return false
+				}
+				continue
+			}
+			sources, err := e.getMatchingSourcesFromState(stateList)
+			if err != nil {
+				// Leave the error to the actions for reporting it canonically.
+				log.Printf("[INFO] [migrator] idempotent: failed to expand the action #%d: %s\n", i, err)
+				return false
+			}
+			if len(sources) > 0 {
+				log.Printf("[INFO] [migrator] idempotent: the action #%d has not been applied yet, because it still matches a source: xmv %s %s, source: %s\n", i, a.source, a.destination, sources[0])
+				return false
+			}
+			destinations, err := e.getMatchingDestinationsFromState(stateList)
+			if err != nil {
+				log.Printf("[INFO] [migrator] idempotent: the action #%d cannot be checked whether it has been applied: %s\n", i, err)
+				return false
+			}
+			if len(destinations) == 0 {
+				log.Printf("[INFO] [migrator] idempotent: the action #%d cannot be checked whether it has been applied, because it matches neither a source nor a destination: xmv %s %s\n", i, a.source, a.destination)
+				return false
+			}
+			if err := e.checkExpectMatches(len(destinations)); err != nil {
+				log.Printf("[INFO] [migrator] idempotent: the action #%d cannot be checked whether it has been applied: %s\n", i, err)
+				return false
+			}
+
+		default:
+			log.Printf("[INFO] [migrator] idempotent: the action #%d cannot be checked whether it has been applied, only mv and xmv actions are supported: %#v\n", i, action)
+			return false
+		}
+	}
+	return true
+}
+
+// getMatchingDestinationsFromState looks into the state and finds addresses
+// which could have been moved by the wildcard xmv action, that is, they match
+// the destination, and the sources restored from them match the source.
+// It's the reverse of expand. The captured values of placeholders in the
+// destination are substituted for the wildcards in the source.
+// It returns an error if the action cannot be reversed, that is, it's in
+// regex mode, or any wildcard in the source is not referenced by its ordinal
+// number in the destination.
+func (e *xmvExpander) getMatchingDestinationsFromState(stateList []string) ([]string, error) {
+	if e.action.regex {
+		return nil, fmt.Errorf("an xmv action with %s cannot be reversed: xmv %s %s", regexFlag, e.action.source, e.action.destination)
+	}
+
+	literals, wildcards := splitSourcePattern(e.action.source, e.action.singleSegment)
+
+	// Build a regex for the destination in which each placeholder captures a
+	// value matching the corresponding wildcard in the source.
+	var b strings.Builder
+	b.WriteString("^")
+	// refs[i] is an ordinal number of a wildcard captured by the group i+1.
+	refs := []int{}
+	dst := e.action.destination
+	last := 0
+	for _, m := range destinationPlaceholderRegex.FindAllStringSubmatchIndex(dst, -1) {
+		b.WriteString(regexp.QuoteMeta(dst[last:m[0]]))
+		last = m[1]
+		var name string
+		switch {
+		case m[2] != -1:
+			name = dst[m[2]:m[3]]
+		case m[4] != -1:
+			name = dst[m[4]:m[5]]
+		default:
+			// an escaped dollar sign
+			b.WriteString(regexp.QuoteMeta("$"))
+			continue
+		}
+		n, err := strconv.Atoi(name)
+		if err != nil || n < 1 || n > len(wildcards) {
+			return nil, fmt.Errorf("the destination %s refers to an unknown wildcard: %s", dst, name)
+		}
+		b.WriteString(wildcards[n-1])
+		refs = append(refs, n)
+	}
+	b.WriteString(regexp.QuoteMeta(dst[last:]))
+	b.WriteString("$")
+
+	for n := 1; n <= len(wildcards); n++ {
+		if !slices.Contains(refs, n) {
+			return nil, fmt.Errorf("the destination %s doesn't refer to the wildcard $%d in the source %s", dst, n, e.action.source)
+		}
+	}
+
+	re, err := regexp.Compile(b.String())
+	if err != nil {
+		return nil, fmt.Errorf("could not make pattern out of %s (%s) due to %s", dst, b.String(), err)
+	}
+
+	var matchingDestinations []string
+	for _, s := range stateList {
+		m := re.FindStringSubmatch(s)
+		if m == nil {
+			continue
+		}
+		// A wildcard referenced more than once must capture the same value.
+		values := make([]string, len(wildcards))
+		consistent := true
+		for i, n := range refs {
+			if slices.Contains(refs[:i], n) && values[n-1] != m[i+1] {
+				consistent = false
+				break
+			}
+			values[n-1] = m[i+1]
+		}
+		if !consistent {
+			continue
+		}
+
+		var source strings.Builder
+		for i, literal := range literals {
+			source.WriteString(literal)
+			if i < len(values) {
+				source.WriteString(values[i])
+			}
+		}
+		// Apply the same filters as the sources, such as exclude patterns.
+		sources, err := e.getMatchingSourcesFromState([]string{source.String()})
+		if err != nil {
+			return nil, err
+		}
+		if len(sources) == 0 {
+			continue
+		}
+		matchingDestinations = append(matchingDestinations, s)
+	}
+	return matchingDestinations, nil
+}
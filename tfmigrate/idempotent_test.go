@@ -0,0 +1,167 @@
+package tfmigrate
+
+import (
+	"testing"
+)
+
+func TestActionsAppliedTo(t *testing.T) {
+	stateList := []string{
+		"aws_instance.foo2",
+		"aws_instance.bar2",
+		"module.app.aws_instance.baz",
+		"aws_instance.new_x",
+		"aws_instance.new_y",
+		"data.aws_ami.new_z",
+	}
+
+	cases := []struct {
+		desc    string
+		actions []StateAction
+		want    bool
+	}{
+		{
+			desc: "all mv applied",
+			actions: []StateAction{
+				NewStateMvAction("aws_instance.foo", "aws_instance.foo2"),
+				NewStateMvAction("aws_instance.bar", "aws_instance.bar2"),
+			},
+			want: true,
+		},
+		{
+			desc: "partially applied",
+			actions: []StateAction{
+				NewStateMvAction("aws_instance.foo", "aws_instance.foo2"),
+				NewStateMvAction("module.app.aws_instance.baz", "aws_instance.baz"),
+			},
+			want: false,
+		},
+		{
+			desc: "destination absent",
+			actions: []StateAction{
+				NewStateMvAction("aws_instance.qux", "aws_instance.qux2"),
+			},
+			want: false,
+		},
+		{
+			desc: "xmv without wildcards applied",
+			actions: []StateAction{
+				NewStateXmvAction("aws_instance.foo", "aws_instance.foo2"),
+			},
+			want: true,
+		},
+		{
+			desc: "xmv with wildcards applied",
+			actions: []StateAction{
+				NewStateXmvAction("aws_instance.old_*", "aws_instance.new_$1"),
+			},
+			want: true,
+		},
+		{
+			desc: "xmv with wildcards matching no sources nor destinations",
+			actions: []StateAction{
+				NewStateXmvAction("aws_instance.old_*", "aws_instance.renamed_$1"),
+			},
+			want: false,
+		},
+		{
+			desc: "xmv with multiple wildcards applied",
+			actions: []StateAction{
+				NewStateXmvAction("*.old_*", "${1}.new_${2}"),
+			},
+			want: true,
+		},
+		{
+			desc: "xmv with a wildcard referenced twice inconsistently",
+			actions: []StateAction{
+				NewStateXmvAction("aws_instance.old_*", "aws_instance.$1_$1"),
+			},
+			want: false,
+		},
+		{
+			desc: "xmv with a wildcard not referenced in the destination",
+			actions: []StateAction{
+				NewStateXmvAction("aws_instance.*_old", "aws_instance.foo2"),
+			},
+			want: false,
+		},
+		{
+			desc: "xmv with exclude matching all destinations",
+			actions: []StateAction{
+				&StateXmvAction{
+					source:      "aws_instance.old_*",
+					destination: "aws_instance.new_$1",
+					excludes:    []string{"aws_instance.old_*"},
+				},
+			},
+			want: false,
+		},
+		{
+			desc: "xmv in regex mode",
+			actions: []StateAction{
+				&StateXmvAction{
+					source:      `aws_instance\.old_(.*)`,
+					destination: "aws_instance.new_$1",
+					regex:       true,
+				},
+			},
+			want: false,
+		},
+		{
+			desc: "xmv with expect matches applied",
+			actions: []StateAction{
+				&StateXmvAction{
+					source:        "aws_instance.old_*",
+					destination:   "aws_instance.new_$1",
+					expectMatches: intPtr(2),
+				},
+			},
+			want: true,
+		},
+		{
+			desc: "xmv with expect matches matching no sources",
+			actions: []StateAction{
+				&StateXmvAction{
+					source:        "aws_instance.old_*",
+					destination:   "aws_instance.$1",
+					expectMatches: intPtr(0),
+				},
+			},
+			want: false,
+		},
+		{
+			desc: "xmv with wildcards matching sources",
+			actions: []StateAction{
+				NewStateXmvAction("module.app.*", "$1"),
+			},
+			want: false,
+		},
+		{
+			desc: "xmv with expect matches differing from destinations",
+			actions: []StateAction{
+				&StateXmvAction{
+					source:        "aws_instance.old_*",
+					destination:   "aws_instance.new_$1",
+					expectMatches: intPtr(1),
+				},
+			},
+			want: false,
+		},
+		{
+			desc: "rm",
+			actions: []StateAction{
+				NewStateMvAction("aws_instance.foo", "aws_instance.foo2"),
+				NewStateRmAction([]string{"aws_instance.qux"}),
+			},
+			want: false,
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.desc, func(t *testing.T) {
+			got := actionsAppliedTo(tc.actions, stateList)
+			if got != tc.want {
+				t.Errorf("got: %t, want: %t", got, tc.want)
+			}
+		})
+	}
+}
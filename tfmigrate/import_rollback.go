@@ -0,0 +1,69 @@
+package tfmigrate
+
+import (
+	"context"
+
+	"github.com/minamijoyo/tfmigrate/tfexec"
+)
+
+// importedAddresses returns addresses imported by given actions.
+func importedAddresses(actions []StateAction) []string {
+	addrs := []string{}
+	for _, action := range actions {
+		a, ok := action.(importEntriesGetter)
+		if !ok {
+			continue
+		}
+		for _, e := range a.importEntries() {
+			addrs = append(addrs, e.Address)
+		}
+	}
+	return addrs
+}
+
+// rollbackTargets returns addresses in imported which are also in deleted,
+// that is, imported resources which would be destroyed or replaced.
+// The order of imported is preserved.
+func rollbackTargets(imported []string, deleted []string) []string {
+	targets := []string{}
+	for _, addr := range imported {
+		for _, d := range deleted {
+			if addr == d {
+				targets = append(targets, addr)
+				break
+			}
+		}
+	}
+	return targets
+}
+
+// rollbackImports removes resources imported by given actions from a given
+// state if they would be destroyed or replaced in a given plan, which means
+// the import id was wrong. It returns a new state and the removed addresses.
+// If nothing to be removed, it returns the given state as is.
+func rollbackImports(ctx context.Context, tf tfexec.TerraformCLI, state *tfexec.State, plan *tfexec.Plan, actions []StateAction) (*tfexec.State, []string, error) {
+	imported := importedAddresses(actions)
+	if len(imported) == 0 {
+		return state, nil, nil
+	}
+
+	out, err := tf.Show(ctx, plan, "-json", "-no-color")
+	if err != nil {
+		return nil, nil, err
+	}
+	planJSON, err := tfexec.ParsePlanJSON([]byte(out))
+	if err != nil {
+		return nil, nil, err
+	}
+
+	targets := rollbackTargets(imported, planJSON.DeletedAddresses())
+	if len(targets) == 0 {
+		return state, nil, nil
+	}
+
+	newState, err := tf.StateRm(ctx, state, targets, "-backup=/dev/null")
+	if err != nil {
+		return nil, nil, err
+	}
+	return newState, targets, nil
+}
@@ -0,0 +1,62 @@
+package tfmigrate
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestImportedAddresses(t *testing.T) {
+	actions := []StateAction{
+		NewStateMvAction("null_resource.foo", "null_resource.foo2"),
+		NewStateImportAction("time_static.bar", "2006-01-02T15:04:05Z"),
+		NewStateImportBatchAction([]StateImportEntry{
+			{Address: "time_static.baz", ID: "2006-01-02T15:04:05Z"},
+			{Address: "time_static.qux", ID: "2006-01-02T15:04:05Z"},
+		}),
+		NewStateRmAction([]string{"time_static.quux"}),
+	}
+
+	got := importedAddresses(actions)
+	want := []string{"time_static.bar", "time_static.baz", "time_static.qux"}
+	if diff := cmp.Diff(got, want); diff != "" {
+		t.Errorf("got: %v, want: %v, diff: %s", got, want, diff)
+	}
+}
+
+func TestRollbackTargets(t *testing.T) {
+	cases := []struct {
+		desc     string
+		imported []string
+		deleted  []string
+		want     []string
+	}{
+		{
+			desc:     "no deleted",
+			imported: []string{"time_static.foo"},
+			deleted:  []string{},
+			want:     []string{},
+		},
+		{
+			desc:     "deleted but not imported",
+			imported: []string{"time_static.foo"},
+			deleted:  []string{"time_static.bar"},
+			want:     []string{},
+		},
+		{
+			desc:     "imported and deleted",
+			imported: []string{"time_static.foo", "time_static.bar", "time_static.baz"},
+			deleted:  []string{"null_resource.qux", "time_static.baz", "time_static.foo"},
+			want:     []string{"time_static.foo", "time_static.baz"},
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.desc, func(t *testing.T) {
+			got := rollbackTargets(tc.imported, tc.deleted)
+			if diff := cmp.Diff(got, tc.want); diff != "" {
+				t.Errorf("got: %v, want: %v, diff: %s", got, tc.want, diff)
+			}
+		})
+	}
+}
@@ -2,9 +2,18 @@ package tfmigrate
 
 import (
 	"context"
+	"fmt"
+	"io"
 	"log"
+	"os"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"sort"
 	"strings"
+	"sync"
 
+	"github.com/hashicorp/go-version"
 	"github.com/minamijoyo/tfmigrate/tfexec"
 )
 
@@ -23,34 +32,141 @@ type Migrator interface {
 
 // setupWorkDir is a common helper function to set up work dir and returns the
 // current state and a switch back function.
-func setupWorkDir(ctx context.Context, tf tfexec.TerraformCLI, workspace string, isBackendTerraformCloud bool, backendConfig []string, ignoreLegacyStateInitErr bool) (*tfexec.State, func() error, error) {
+func setupWorkDir(ctx context.Context, tf tfexec.TerraformCLI, workspace string, isBackendTerraformCloud bool, backendConfig []string, initOpts []string, ignoreLegacyStateInitErr bool, skipInit bool) (*tfexec.State, func() error, error) {
+	execType, version, err := initWorkDir(ctx, tf, initOpts, ignoreLegacyStateInitErr, skipInit)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	currentState, err := pullWorkspaceState(ctx, tf, workspace, execType, version)
+	if err != nil {
+		return nil, nil, skipInitError(err, tf.Dir(), skipInit)
+	}
+
+	// override backend to local
+	log.Printf("[INFO] [migrator@%s] override backend to local\n", tf.Dir())
+	switchBackToRemoteFunc, err := tf.OverrideBackendToLocal(ctx, "_tfmigrate_override.tf", workspace, isBackendTerraformCloud, backendConfig, ignoreLegacyStateInitErr)
+	if err != nil {
+		return nil, nil, err
+	}
+	return currentState, switchBackToRemoteFunc, nil
+}
+
+// setupWorkDirForWorkspaces is a variant of setupWorkDir for moving resources
+// across workspaces within the same work dir. It pulls the current states of
+// both workspaces before switching the backend to local, and creates a local
+// workspace for each of them so that we can select a workspace for plan.
+// The fromWorkspace is selected on return.
+func setupWorkDirForWorkspaces(ctx context.Context, tf tfexec.TerraformCLI, fromWorkspace string, toWorkspace string, isBackendTerraformCloud bool, backendConfig []string, initOpts []string, skipInit bool) (*tfexec.State, *tfexec.State, func() error, error) {
+	execType, version, err := initWorkDir(ctx, tf, initOpts, false, skipInit)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	toState, err := pullWorkspaceState(ctx, tf, toWorkspace, execType, version)
+	if err != nil {
+		return nil, nil, nil, skipInitError(err, tf.Dir(), skipInit)
+	}
+	fromState, err := pullWorkspaceState(ctx, tf, fromWorkspace, execType, version)
+	if err != nil {
+		return nil, nil, nil, skipInitError(err, tf.Dir(), skipInit)
+	}
+
+	// create a local workspace for the toWorkspace.
+	// The one for the fromWorkspace is created by OverrideBackendToLocal.
+	toWorkspaceStatePath := filepath.Join(tf.Dir(), "terraform.tfstate.d", toWorkspace)
+	if err := os.MkdirAll(toWorkspaceStatePath, os.ModePerm); err != nil {
+		return nil, nil, nil, fmt.Errorf("failed to create local workspace state directory: %s", err)
+	}
+
+	// override backend to local
+	log.Printf("[INFO] [migrator@%s] override backend to local\n", tf.Dir())
+	switchBackToRemoteFunc, err := tf.OverrideBackendToLocal(ctx, "_tfmigrate_override.tf", fromWorkspace, isBackendTerraformCloud, backendConfig, false)
+	if err != nil {
+		os.Remove(toWorkspaceStatePath)
+		return nil, nil, nil, err
+	}
+
+	// The switch back function removes the parent directory of local
+	// workspaces, so we need to remove the one for the toWorkspace first.
+	return fromState, toState, func() error {
+		if err := os.Remove(toWorkspaceStatePath); err != nil {
+			log.Printf("[ERROR] [migrator@%s] failed to remove local workspace state directory: %s\n", tf.Dir(), err)
+		}
+		return switchBackToRemoteFunc()
+	}, nil
+}
+
+// initMu serializes terraform init within a process, because the plugin cache
+// of terraform is not safe for concurrent installation of providers.
+var initMu sync.Mutex
+
+// initWorkDir is a common helper function to check the terraform command and
+// initialize the work dir with given options of terraform init. It returns the
+// type and version of terraform command. If skipInit is true, it assumes the
+// work dir is already initialized and doesn't run terraform init.
+func initWorkDir(ctx context.Context, tf tfexec.TerraformCLI, initOpts []string, ignoreLegacyStateInitErr bool, skipInit bool) (_ string, _ *version.Version, err error) {
+	ctx, span := startPhaseSpan(ctx, tf, "init")
+	defer func() { EndSpan(span, err) }()
+
 	// check if terraform command is available.
 	execType, version, err := tf.Version(ctx)
 	if err != nil {
-		return nil, nil, err
+		return "", nil, err
 	}
 	log.Printf("[INFO] [migrator@%s] %s version: %s\n", tf.Dir(), execType, version)
 
+	if skipInit {
+		log.Printf("[INFO] [migrator@%s] skip initializing work dir as skip-init option is true\n", tf.Dir())
+		return execType, version, nil
+	}
+
 	supportsStateReplaceProvider, constraints, err := tf.SupportsStateReplaceProvider(ctx)
 	if err != nil {
-		return nil, nil, err
+		return "", nil, err
 	}
 
 	// init folder
 	log.Printf("[INFO] [migrator@%s] initialize work dir\n", tf.Dir())
-	err = tf.Init(ctx, "-input=false", "-no-color")
+	initMu.Lock()
+	err = tf.Init(ctx, initOpts...)
+	initMu.Unlock()
 	if err != nil {
 		if supportsStateReplaceProvider && ignoreLegacyStateInitErr && strings.Contains(err.Error(), tfexec.AcceptableLegacyStateInitError) {
 			log.Printf("[INFO] [migrator@%s] ignoring error '%s' initilizing work dir; the error is expected when using Terraform %s with a legacy Terraform state\n", tf.Dir(), tfexec.AcceptableLegacyStateInitError, constraints)
 		} else {
-			return nil, nil, err
+			return "", nil, err
 		}
 	}
 
+	if backendType, err := tfexec.DetectBackendType(tf.Dir()); err == nil {
+		log.Printf("[INFO] [migrator@%s] backend type: %s\n", tf.Dir(), backendType)
+	} else {
+		log.Printf("[WARN] [migrator@%s] failed to detect the backend type: %s\n", tf.Dir(), err)
+	}
+	return execType, version, nil
+}
+
+// skipInitError annotates a given error of pulling a state with a hint if
+// init was skipped, because the work dir may not be initialized.
+func skipInitError(err error, dir string, skipInit bool) error {
+	if !skipInit {
+		return err
+	}
+	return fmt.Errorf("failed to pull the state in %s with the --skip-init flag. The work dir may not be initialized. Run terraform init before tfmigrate, or remove the --skip-init flag: %s", dir, err)
+}
+
+// pullWorkspaceState is a common helper function to switch to a given
+// workspace and pull the current remote state.
+// It checks the pulled state can be handled by a given version of terraform.
+func pullWorkspaceState(ctx context.Context, tf tfexec.TerraformCLI, workspace string, execType string, version *version.Version) (_ *tfexec.State, err error) {
+	ctx, span := startPhaseSpan(ctx, tf, "pull")
+	defer func() { EndSpan(span, err) }()
+
 	// check current workspace
 	currentWorkspace, err := tf.WorkspaceShow(ctx)
 	if err != nil {
-		return nil, nil, err
+		return nil, err
 	}
 	log.Printf("[DEBUG] [migrator@%s] currentWorkspace = %s, workspace = %s\n", tf.Dir(), currentWorkspace, workspace)
 	if currentWorkspace != workspace {
@@ -58,7 +174,7 @@ func setupWorkDir(ctx context.Context, tf tfexec.TerraformCLI, workspace string,
 		log.Printf("[INFO] [migrator@%s] switch to remote workspace %s\n", tf.Dir(), workspace)
 		err = tf.WorkspaceSelect(ctx, workspace)
 		if err != nil {
-			return nil, nil, err
+			return nil, err
 		}
 	}
 
@@ -66,13 +182,392 @@ func setupWorkDir(ctx context.Context, tf tfexec.TerraformCLI, workspace string,
 	log.Printf("[INFO] [migrator@%s] get the current remote state\n", tf.Dir())
 	currentState, err := tf.StatePull(ctx)
 	if err != nil {
-		return nil, nil, err
+		return nil, err
 	}
-	// override backend to local
-	log.Printf("[INFO] [migrator@%s] override backend to local\n", tf.Dir())
-	switchBackToRemoteFunc, err := tf.OverrideBackendToLocal(ctx, "_tfmigrate_override.tf", workspace, isBackendTerraformCloud, backendConfig, ignoreLegacyStateInitErr)
+	if err := checkStateEncryption(currentState, execType); err != nil {
+		return nil, fmt.Errorf("failed to pull the state in %s: %s", tf.Dir(), err)
+	}
+	if err := tfexec.CheckStateVersion(currentState, version); err != nil {
+		return nil, fmt.Errorf("failed to pull the state in %s: %s", tf.Dir(), err)
+	}
+	return currentState, nil
+}
+
+// checkStateEncryption returns an error if a given pulled state is still
+// encrypted. OpenTofu decrypts the state on pull and encrypts it on push when
+// the encryption is configured, so that encryption is transparent to us.
+// Otherwise, we cannot apply any state operation to it.
+func checkStateEncryption(state *tfexec.State, execType string) error {
+	if !state.IsEncrypted() {
+		return nil
+	}
+
+	if execType != "opentofu" {
+		return fmt.Errorf("the state is encrypted, but %s doesn't support state encryption. Use OpenTofu with the encryption configured", execType)
+	}
+	return fmt.Errorf("the state is encrypted and OpenTofu couldn't decrypt it. Configure the encryption in the terraform block or the TF_ENCRYPTION environment variable")
+}
+
+// validateWorkDir is a common helper function to run terraform validate in
+// the work dir. It's intended to separate an invalid configuration from a
+// wrong migration.
+func validateWorkDir(ctx context.Context, tf tfexec.TerraformCLI) error {
+	log.Printf("[INFO] [migrator@%s] validate the configuration\n", tf.Dir())
+	if err := tf.Validate(ctx, "-no-color"); err != nil {
+		log.Printf("[ERROR] [migrator@%s] invalid configuration\n", tf.Dir())
+		return fmt.Errorf("the configuration in %s is invalid, fix it before migration: %s", tf.Dir(), err)
+	}
+	return nil
+}
+
+// pushState is a common helper function to push a given state to remote.
+// It refuses to push an encrypted-looking state, because pushing it as is
+// would corrupt the remote state.
+// tfmigrate doesn't depend on a particular backend, because it pulls and
+// pushes states via terraform. If the push fails, the error contains the type
+// of backend to make it clear which backend refused it.
+func pushState(ctx context.Context, tf tfexec.TerraformCLI, state *tfexec.State, opts ...string) (err error) {
+	ctx, span := startPhaseSpan(ctx, tf, "push")
+	defer func() { EndSpan(span, err) }()
+
+	if state.IsEncrypted() {
+		return fmt.Errorf("refusing to push an encrypted state in %s", tf.Dir())
+	}
+	err = tf.StatePush(ctx, state, opts...)
 	if err != nil {
-		return nil, nil, err
+		backendType, berr := tfexec.DetectBackendType(tf.Dir())
+		if berr != nil {
+			log.Printf("[WARN] [migrator@%s] failed to detect the backend type: %s\n", tf.Dir(), berr)
+			return err
+		}
+		return pushStateError(err, tf.Dir(), backendType)
 	}
-	return currentState, switchBackToRemoteFunc, nil
+	return nil
+}
+
+// unsupportedPushRe matches an error message of terraform state push when
+// the backend doesn't support writing a state. The message depends on the
+// backend, such as an http backend which doesn't allow the method.
+var unsupportedPushRe = regexp.MustCompile(`(?i)not supported|not implemented|does not support|doesn't support|method not allowed|read-only`)
+
+// pushStateError returns an error of terraform state push annotated with a
+// given type of backend.
+func pushStateError(err error, dir string, backendType string) error {
+	msg := fmt.Sprintf("failed to push the state to the %s backend in %s", backendType, dir)
+	if unsupportedPushRe.MatchString(err.Error()) {
+		msg += fmt.Sprintf(". The %s backend doesn't seem to support terraform state push, which is required by tfmigrate", backendType)
+	}
+	return fmt.Errorf("%s: %s", msg, err)
+}
+
+// migratorEnv returns environment variables passed to terraform command.
+// If the StateEncryption is set, it's passed as TF_ENCRYPTION so that OpenTofu
+// decrypts the state on pull and encrypts it on push.
+// If the PluginCacheDir is set, it's passed as TF_PLUGIN_CACHE_DIR. A relative
+// path is resolved against the current directory instead of each working dir.
+// The Env is appended at last, so that it takes precedence over the others.
+func migratorEnv(o *MigratorOption) []string {
+	env := os.Environ()
+	if o != nil && len(o.StateEncryption) > 0 {
+		env = append(env, "TF_ENCRYPTION="+o.StateEncryption)
+	}
+	if o != nil && len(o.PluginCacheDir) > 0 {
+		dir, err := filepath.Abs(o.PluginCacheDir)
+		if err != nil {
+			// Abs fails only if the current directory cannot be determined.
+			dir = o.PluginCacheDir
+		}
+		env = append(env, "TF_PLUGIN_CACHE_DIR="+dir)
+	}
+	if o != nil && len(o.Env) > 0 {
+		keys := make([]string, 0, len(o.Env))
+		for k := range o.Env {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			env = append(env, k+"="+o.Env[k])
+		}
+	}
+	return env
+}
+
+// newExecutor returns a new Executor for a given dir in a migration with
+// settings in a given MigratorOption.
+func newExecutor(o *MigratorOption, dir string) tfexec.Executor {
+	e := tfexec.NewExecutor(resolveWorkDir(o, dir), migratorEnv(o))
+	if o != nil {
+		e.SetOutput(o.Stdout, o.Stderr)
+		e.SetCommandLog(o.CommandLog)
+	}
+	return e
+}
+
+// newTerraformCLI returns a new TerraformCLI for a given dir in a migration
+// with settings in a given MigratorOption.
+func newTerraformCLI(o *MigratorOption, dir string) tfexec.TerraformCLI {
+	tf := tfexec.NewTerraformCLI(newExecutor(o, dir))
+	if o != nil && len(o.ExecPath) > 0 {
+		// While NewTerraformCLI reads the environment variable TFMIGRATE_EXEC_PATH
+		// at initialization, the MigratorOption takes precedence over it.
+		tf.SetExecPath(o.ExecPath)
+	}
+	if o != nil && len(o.TmpDir) > 0 {
+		tf.SetTmpDir(o.TmpDir)
+	}
+	if o != nil {
+		tf.SetTimeouts(o.timeouts())
+		tf.SetReadOnly(o.ReadOnly)
+		tf.SetLockRetry(o.LockRetry)
+		tf.SetStreamState(o.StreamState)
+	}
+	return newStateMvWarningsCLI(tf, o)
+}
+
+// verifyPlanFile is a common helper function to verify a saved plan file
+// instead of running a new plan. It checks that the saved plan is still
+// applicable to a given state and has no changes except ones allowed by
+// given patterns.
+func verifyPlanFile(ctx context.Context, tf tfexec.TerraformCLI, state *tfexec.State, planFile string, force bool, allowChanges []*regexp.Regexp) (err error) {
+	ctx, span := startPhaseSpan(ctx, tf, "plan")
+	defer func() { EndSpan(span, err) }()
+
+	log.Printf("[INFO] [migrator@%s] verify the saved plan file: %s\n", tf.Dir(), planFile)
+	b, err := os.ReadFile(planFile)
+	if err != nil {
+		return fmt.Errorf("failed to read the saved plan file: %s", err)
+	}
+	plan := tfexec.NewPlan(b)
+
+	if err := checkPlanApplicable(plan, state); err != nil {
+		return err
+	}
+
+	out, err := tf.Show(ctx, plan, "-json", "-no-color")
+	if err != nil {
+		return err
+	}
+	planJSON, err := tfexec.ParsePlanJSON([]byte(out))
+	if err != nil {
+		return err
+	}
+
+	changed := planChanges(planJSON)
+	if len(changed) > 0 && len(allowChanges) > 0 {
+		changed = disallowedAddresses(changed, allowChanges)
+		if len(changed) == 0 {
+			log.Printf("[INFO] [migrator@%s] all diffs are allowed by plan_allow_changes\n", tf.Dir())
+		}
+	}
+	if len(changed) > 0 {
+		if !force {
+			log.Printf("[ERROR] [migrator@%s] unexpected diffs\n", tf.Dir())
+			return fmt.Errorf("the saved plan file contains unexpected diffs: %v", changed)
+		}
+		log.Printf("[INFO] [migrator@%s] unexpected diffs, ignoring as force option is true: %v", tf.Dir(), changed)
+	}
+
+	return nil
+}
+
+// checkPlanApplicable returns an error if a given plan was not created from a
+// given state. The lineage and serial of the prior state embedded in the plan
+// must match the state, otherwise the remote state has changed since planned.
+func checkPlanApplicable(plan *tfexec.Plan, state *tfexec.State) error {
+	prior, err := plan.PriorState()
+	if err != nil {
+		return err
+	}
+	priorMeta, err := prior.Meta()
+	if err != nil {
+		return err
+	}
+	currentMeta, err := state.Meta()
+	if err != nil {
+		return err
+	}
+
+	if priorMeta.Lineage != currentMeta.Lineage {
+		return fmt.Errorf("the saved plan file is not applicable: lineage mismatch: plan = %s, state = %s", priorMeta.Lineage, currentMeta.Lineage)
+	}
+	if priorMeta.Serial != currentMeta.Serial {
+		return fmt.Errorf("the saved plan file is stale: serial mismatch: plan = %d, state = %d", priorMeta.Serial, currentMeta.Serial)
+	}
+	return nil
+}
+
+// saveStateBackup writes a given state to a file in a backup directory and
+// returns the path. It can be restored by terraform state push -force.
+func saveStateBackup(dir string, name string, state *tfexec.State) (string, error) {
+	path := filepath.Join(dir, name)
+	if err := writeStateFile(path, state); err != nil {
+		return "", fmt.Errorf("failed to save a backup of the state: %s", err)
+	}
+	return path, nil
+}
+
+// writeStateFile writes a given state to a file at a given path.
+// The state is streamed, so that a state backed by a file is not loaded into
+// memory.
+func writeStateFile(path string, state *tfexec.State) error {
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0600)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(f, state.Reader()); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// saveDryRunStates is a common helper function to save new states to a new
+// directory in a given parent directory instead of pushing them to remote on
+// dry-run. It returns the path of the directory.
+func saveDryRunStates(parentDir string, states map[string]*tfexec.State) (string, error) {
+	dir, err := os.MkdirTemp(parentDir, "tfmigrate-dry-run-")
+	if err != nil {
+		return "", fmt.Errorf("failed to create a scratch directory for dry-run: %s", err)
+	}
+
+	for name, state := range states {
+		if state.IsEncrypted() {
+			return "", fmt.Errorf("refusing to save an encrypted state: %s", name)
+		}
+		if _, err := saveStateBackup(dir, name, state); err != nil {
+			return "", err
+		}
+	}
+	return dir, nil
+}
+
+// PushedError is an error which occurs after a new state has been pushed to
+// remote, such as on verifying the pushed state. It tells that the remote
+// state has been changed even though the migration failed, so that a caller
+// can report it. The message is the same as the cause.
+type PushedError struct {
+	// Err is a cause of the failure.
+	Err error
+}
+
+// Error returns a message of the cause.
+func (e *PushedError) Error() string {
+	return e.Err.Error()
+}
+
+// Unwrap returns the cause.
+func (e *PushedError) Unwrap() error {
+	return e.Err
+}
+
+// verifyStatePushed is a common helper function to verify that a given state
+// has been pushed to remote. It pulls the remote state again and compares it
+// with the pushed one. It should be called after every push.
+func verifyStatePushed(ctx context.Context, tf tfexec.TerraformCLI, pushed *tfexec.State) error {
+	pulled, err := tf.StatePull(ctx)
+	if err != nil {
+		return err
+	}
+
+	if err := checkStatePushed(pushed, pulled); err != nil {
+		return err
+	}
+
+	pushedList, err := tf.StateList(ctx, pushed, nil)
+	if err != nil {
+		return err
+	}
+	pulledList, err := tf.StateList(ctx, pulled, nil)
+	if err != nil {
+		return err
+	}
+	if !reflect.DeepEqual(pushedList, pulledList) {
+		return fmt.Errorf("resources mismatch: pushed = %v, pulled = %v", pushedList, pulledList)
+	}
+	return nil
+}
+
+// checkStatePushed returns an error if a pulled state is not derived from a
+// pushed state. The lineage must match and the serial must not go backwards,
+// because the backend may increment the serial on write. Note that the serial
+// of the pushed state is already checked by nextState before push, and a
+// concurrent write by someone else is detected by comparing resources.
+func checkStatePushed(pushed *tfexec.State, pulled *tfexec.State) error {
+	pushedMeta, err := pushed.Meta()
+	if err != nil {
+		return err
+	}
+	pulledMeta, err := pulled.Meta()
+	if err != nil {
+		return err
+	}
+
+	if pushedMeta.Lineage != pulledMeta.Lineage {
+		return fmt.Errorf("lineage mismatch: pushed = %s, pulled = %s", pushedMeta.Lineage, pulledMeta.Lineage)
+	}
+	if pulledMeta.Serial < pushedMeta.Serial {
+		return fmt.Errorf("serial mismatch: pushed = %d, pulled = %d", pushedMeta.Serial, pulledMeta.Serial)
+	}
+	return nil
+}
+
+// nextState returns a new state to be pushed with the serial set to exactly
+// one greater than the original state pulled from remote and the lineage of
+// the original state preserved.
+// The serial of the new state may be incremented more than once by terraform
+// state commands or may not be incremented at all if the state is
+// constructed directly, so we normalize it here to guard against state
+// corruption. It returns a given new state as is if the original state is
+// empty or the new state is encrypted, because there is nothing to compare.
+func nextState(original *tfexec.State, state *tfexec.State) (*tfexec.State, error) {
+	if original.Size() == 0 || state.IsEncrypted() {
+		return state, nil
+	}
+
+	originalMeta, err := original.Meta()
+	if err != nil {
+		return nil, err
+	}
+	meta, err := state.Meta()
+	if err != nil {
+		return nil, err
+	}
+	if len(meta.Lineage) != 0 && meta.Lineage != originalMeta.Lineage {
+		return nil, fmt.Errorf("lineage mismatch: original = %s, new = %s", originalMeta.Lineage, meta.Lineage)
+	}
+
+	// The new state is streamed if it's backed by a file.
+	next, err := state.ReplaceAttributes(map[string]any{
+		"lineage": originalMeta.Lineage,
+		"serial":  originalMeta.Serial + 1,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if err := checkNextState(original, next); err != nil {
+		return nil, err
+	}
+	return next, nil
+}
+
+// checkNextState returns an error if the serial of a new state is not
+// exactly one greater than the original state or the lineage doesn't match.
+func checkNextState(original *tfexec.State, next *tfexec.State) error {
+	originalMeta, err := original.Meta()
+	if err != nil {
+		return err
+	}
+	nextMeta, err := next.Meta()
+	if err != nil {
+		return err
+	}
+
+	if nextMeta.Lineage != originalMeta.Lineage {
+		return fmt.Errorf("lineage mismatch: original = %s, new = %s", originalMeta.Lineage, nextMeta.Lineage)
+	}
+	if nextMeta.Serial != originalMeta.Serial+1 {
+		return fmt.Errorf("serial mismatch: expected %d, but got %d", originalMeta.Serial+1, nextMeta.Serial)
+	}
+	return nil
 }
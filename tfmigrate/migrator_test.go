@@ -0,0 +1,570 @@
+package tfmigrate
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/hashicorp/go-version"
+	"github.com/minamijoyo/tfmigrate/tfexec"
+)
+
+// newTestPlan builds a dummy plan file which contains a given prior state.
+func newTestPlan(t *testing.T, priorState string) *tfexec.Plan {
+	t.Helper()
+	buf := &bytes.Buffer{}
+	w := zip.NewWriter(buf)
+	f, err := w.Create("tfstate")
+	if err != nil {
+		t.Fatalf("failed to create a file in zip: %s", err)
+	}
+	if _, err := f.Write([]byte(priorState)); err != nil {
+		t.Fatalf("failed to write a file in zip: %s", err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatalf("failed to close zip: %s", err)
+	}
+	return tfexec.NewPlan(buf.Bytes())
+}
+
+func TestCheckPlanApplicable(t *testing.T) {
+	state := `{"version": 4, "serial": 3, "lineage": "foo"}`
+	cases := []struct {
+		desc  string
+		plan  *tfexec.Plan
+		state *tfexec.State
+		ok    bool
+	}{
+		{
+			desc:  "applicable",
+			plan:  newTestPlan(t, state),
+			state: tfexec.NewState([]byte(state)),
+			ok:    true,
+		},
+		{
+			desc:  "serial mismatch",
+			plan:  newTestPlan(t, `{"version": 4, "serial": 2, "lineage": "foo"}`),
+			state: tfexec.NewState([]byte(state)),
+			ok:    false,
+		},
+		{
+			desc:  "lineage mismatch",
+			plan:  newTestPlan(t, `{"version": 4, "serial": 3, "lineage": "bar"}`),
+			state: tfexec.NewState([]byte(state)),
+			ok:    false,
+		},
+		{
+			desc:  "invalid plan",
+			plan:  tfexec.NewPlan([]byte("foo")),
+			state: tfexec.NewState([]byte(state)),
+			ok:    false,
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.desc, func(t *testing.T) {
+			err := checkPlanApplicable(tc.plan, tc.state)
+			if tc.ok && err != nil {
+				t.Fatalf("unexpected err: %s", err)
+			}
+			if !tc.ok && err == nil {
+				t.Fatal("expected to return an error, but no error")
+			}
+		})
+	}
+}
+
+func TestCheckStatePushed(t *testing.T) {
+	pushed := `{"version": 4, "serial": 3, "lineage": "foo"}`
+	cases := []struct {
+		desc   string
+		pushed *tfexec.State
+		pulled *tfexec.State
+		ok     bool
+	}{
+		{
+			desc:   "same",
+			pushed: tfexec.NewState([]byte(pushed)),
+			pulled: tfexec.NewState([]byte(pushed)),
+			ok:     true,
+		},
+		{
+			desc:   "serial incremented by backend",
+			pushed: tfexec.NewState([]byte(pushed)),
+			pulled: tfexec.NewState([]byte(`{"version": 4, "serial": 4, "lineage": "foo"}`)),
+			ok:     true,
+		},
+		{
+			desc:   "serial mismatch",
+			pushed: tfexec.NewState([]byte(pushed)),
+			pulled: tfexec.NewState([]byte(`{"version": 4, "serial": 2, "lineage": "foo"}`)),
+			ok:     false,
+		},
+		{
+			desc:   "lineage mismatch",
+			pushed: tfexec.NewState([]byte(pushed)),
+			pulled: tfexec.NewState([]byte(`{"version": 4, "serial": 3, "lineage": "bar"}`)),
+			ok:     false,
+		},
+		{
+			desc:   "invalid state",
+			pushed: tfexec.NewState([]byte(pushed)),
+			pulled: tfexec.NewState([]byte("foo")),
+			ok:     false,
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.desc, func(t *testing.T) {
+			err := checkStatePushed(tc.pushed, tc.pulled)
+			if tc.ok && err != nil {
+				t.Fatalf("unexpected err: %s", err)
+			}
+			if !tc.ok && err == nil {
+				t.Fatal("expected to return an error, but no error")
+			}
+		})
+	}
+}
+
+func TestSaveStateBackup(t *testing.T) {
+	dir := t.TempDir()
+	state := tfexec.NewState([]byte(`{"version": 4, "serial": 3, "lineage": "foo"}`))
+
+	path, err := saveStateBackup(dir, "to.tfstate", state)
+	if err != nil {
+		t.Fatalf("unexpected err: %s", err)
+	}
+
+	got, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("failed to read a backup: %s", err)
+	}
+	if string(got) != string(state.Bytes()) {
+		t.Errorf("got: %s, want: %s", string(got), string(state.Bytes()))
+	}
+}
+
+func TestSaveDryRunStatesInDryRunDir(t *testing.T) {
+	dryRunDir := t.TempDir()
+	state := tfexec.NewState([]byte(`{"version": 4, "serial": 3, "lineage": "foo"}`))
+
+	dir, err := saveDryRunStates(dryRunDir, map[string]*tfexec.State{"new.tfstate": state})
+	if err != nil {
+		t.Fatalf("unexpected err: %s", err)
+	}
+	if filepath.Dir(dir) != dryRunDir {
+		t.Errorf("expected to save states in %s, but got: %s", dryRunDir, dir)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "new.tfstate")); err != nil {
+		t.Errorf("failed to find a saved state: %s", err)
+	}
+}
+
+func TestMigratorEnv(t *testing.T) {
+	cases := []struct {
+		desc string
+		o    *MigratorOption
+		want string
+	}{
+		{
+			desc: "nil option",
+			o:    nil,
+			want: "",
+		},
+		{
+			desc: "no state encryption",
+			o:    &MigratorOption{},
+			want: "",
+		},
+		{
+			desc: "with state encryption",
+			o: &MigratorOption{
+				StateEncryption: `{"key_provider": {}}`,
+			},
+			want: `TF_ENCRYPTION={"key_provider": {}}`,
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.desc, func(t *testing.T) {
+			env := migratorEnv(tc.o)
+			got := ""
+			for _, e := range env {
+				if strings.HasPrefix(e, "TF_ENCRYPTION=") {
+					got = e
+				}
+			}
+			if got != tc.want {
+				t.Errorf("got: %s, want: %s", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestMigratorEnvWithEnv(t *testing.T) {
+	o := &MigratorOption{
+		StateEncryption: `{"key_provider": {}}`,
+		Env: map[string]string{
+			"TF_ENCRYPTION": "overridden",
+			"AWS_PROFILE":   "dev",
+		},
+	}
+	env := migratorEnv(o)
+	want := []string{`TF_ENCRYPTION={"key_provider": {}}`, "AWS_PROFILE=dev", "TF_ENCRYPTION=overridden"}
+	got := env[len(env)-len(want):]
+	if diff := cmp.Diff(got, want); diff != "" {
+		t.Errorf("got: %v, want: %v, diff: %s", got, want, diff)
+	}
+}
+
+func TestMigratorEnvPluginCacheDir(t *testing.T) {
+	// unset the environment variable inherited from the test runner.
+	t.Setenv("TF_PLUGIN_CACHE_DIR", "")
+	if err := os.Unsetenv("TF_PLUGIN_CACHE_DIR"); err != nil {
+		t.Fatalf("failed to unset TF_PLUGIN_CACHE_DIR: %s", err)
+	}
+
+	cwd, err := os.Getwd()
+	if err != nil {
+		t.Fatalf("failed to get the current directory: %s", err)
+	}
+
+	cases := []struct {
+		desc string
+		o    *MigratorOption
+		want string
+	}{
+		{
+			desc: "no plugin cache dir",
+			o:    &MigratorOption{},
+			want: "",
+		},
+		{
+			desc: "absolute path",
+			o: &MigratorOption{
+				PluginCacheDir: "/var/tmp/plugin-cache",
+			},
+			want: "TF_PLUGIN_CACHE_DIR=/var/tmp/plugin-cache",
+		},
+		{
+			desc: "relative path",
+			o: &MigratorOption{
+				PluginCacheDir: "tmp/plugin-cache",
+			},
+			want: "TF_PLUGIN_CACHE_DIR=" + filepath.Join(cwd, "tmp/plugin-cache"),
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.desc, func(t *testing.T) {
+			env := migratorEnv(tc.o)
+			got := ""
+			for _, e := range env {
+				if strings.HasPrefix(e, "TF_PLUGIN_CACHE_DIR=") {
+					got = e
+				}
+			}
+			if got != tc.want {
+				t.Errorf("got: %s, want: %s", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestCheckStateEncryption(t *testing.T) {
+	plain := tfexec.NewState([]byte(`{"version": 4, "serial": 3, "lineage": "foo", "resources": []}`))
+	encrypted := tfexec.NewState([]byte(`{"serial": 3, "lineage": "foo", "encrypted_data": "Zm9v", "encryption_version": "v0"}`))
+	cases := []struct {
+		desc     string
+		state    *tfexec.State
+		execType string
+		ok       bool
+	}{
+		{
+			desc:     "plain (terraform)",
+			state:    plain,
+			execType: "terraform",
+			ok:       true,
+		},
+		{
+			desc:     "plain (opentofu)",
+			state:    plain,
+			execType: "opentofu",
+			ok:       true,
+		},
+		{
+			desc:     "encrypted (terraform)",
+			state:    encrypted,
+			execType: "terraform",
+			ok:       false,
+		},
+		{
+			desc:     "encrypted (opentofu)",
+			state:    encrypted,
+			execType: "opentofu",
+			ok:       false,
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.desc, func(t *testing.T) {
+			err := checkStateEncryption(tc.state, tc.execType)
+			if tc.ok && err != nil {
+				t.Fatalf("unexpected err: %s", err)
+			}
+			if !tc.ok && err == nil {
+				t.Fatal("expected to return an error, but no error")
+			}
+		})
+	}
+}
+
+func TestNextState(t *testing.T) {
+	original := `{"version": 4, "serial": 3, "lineage": "foo", "resources": []}`
+	cases := []struct {
+		desc     string
+		original *tfexec.State
+		state    *tfexec.State
+		want     *tfexec.StateMeta
+		ok       bool
+	}{
+		{
+			desc:     "serial incremented once",
+			original: tfexec.NewState([]byte(original)),
+			state:    tfexec.NewState([]byte(`{"version": 4, "serial": 4, "lineage": "foo", "resources": []}`)),
+			want:     &tfexec.StateMeta{Version: 4, Serial: 4, Lineage: "foo"},
+			ok:       true,
+		},
+		{
+			desc:     "serial incremented more than once",
+			original: tfexec.NewState([]byte(original)),
+			state:    tfexec.NewState([]byte(`{"version": 4, "serial": 6, "lineage": "foo", "resources": []}`)),
+			want:     &tfexec.StateMeta{Version: 4, Serial: 4, Lineage: "foo"},
+			ok:       true,
+		},
+		{
+			desc:     "serial not incremented",
+			original: tfexec.NewState([]byte(original)),
+			state:    tfexec.NewState([]byte(original)),
+			want:     &tfexec.StateMeta{Version: 4, Serial: 4, Lineage: "foo"},
+			ok:       true,
+		},
+		{
+			desc:     "lineage missing",
+			original: tfexec.NewState([]byte(original)),
+			state:    tfexec.NewState([]byte(`{"version": 4, "serial": 3, "resources": []}`)),
+			want:     &tfexec.StateMeta{Version: 4, Serial: 4, Lineage: "foo"},
+			ok:       true,
+		},
+		{
+			desc:     "lineage mismatch",
+			original: tfexec.NewState([]byte(original)),
+			state:    tfexec.NewState([]byte(`{"version": 4, "serial": 4, "lineage": "bar", "resources": []}`)),
+			want:     nil,
+			ok:       false,
+		},
+		{
+			desc:     "empty original",
+			original: tfexec.NewState([]byte{}),
+			state:    tfexec.NewState([]byte(`{"version": 4, "serial": 1, "lineage": "bar", "resources": []}`)),
+			want:     &tfexec.StateMeta{Version: 4, Serial: 1, Lineage: "bar"},
+			ok:       true,
+		},
+		{
+			desc:     "invalid original",
+			original: tfexec.NewState([]byte("foo")),
+			state:    tfexec.NewState([]byte(original)),
+			want:     nil,
+			ok:       false,
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.desc, func(t *testing.T) {
+			got, err := nextState(tc.original, tc.state)
+			if tc.ok && err != nil {
+				t.Fatalf("unexpected err: %s", err)
+			}
+			if !tc.ok && err == nil {
+				t.Fatalf("expected to return an error, but no error, got: %s", string(got.Bytes()))
+			}
+			if tc.ok {
+				meta, err := got.Meta()
+				if err != nil {
+					t.Fatalf("failed to parse the new state: %s", err)
+				}
+				if diff := cmp.Diff(meta, tc.want); diff != "" {
+					t.Errorf("got: %#v, want: %#v, diff: %s", meta, tc.want, diff)
+				}
+			}
+		})
+	}
+}
+
+func TestCheckNextState(t *testing.T) {
+	original := tfexec.NewState([]byte(`{"version": 4, "serial": 3, "lineage": "foo"}`))
+	cases := []struct {
+		desc string
+		next *tfexec.State
+		ok   bool
+	}{
+		{
+			desc: "valid",
+			next: tfexec.NewState([]byte(`{"version": 4, "serial": 4, "lineage": "foo"}`)),
+			ok:   true,
+		},
+		{
+			desc: "same serial",
+			next: tfexec.NewState([]byte(`{"version": 4, "serial": 3, "lineage": "foo"}`)),
+			ok:   false,
+		},
+		{
+			desc: "serial incremented twice",
+			next: tfexec.NewState([]byte(`{"version": 4, "serial": 5, "lineage": "foo"}`)),
+			ok:   false,
+		},
+		{
+			desc: "lineage mismatch",
+			next: tfexec.NewState([]byte(`{"version": 4, "serial": 4, "lineage": "bar"}`)),
+			ok:   false,
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.desc, func(t *testing.T) {
+			err := checkNextState(original, tc.next)
+			if tc.ok && err != nil {
+				t.Fatalf("unexpected err: %s", err)
+			}
+			if !tc.ok && err == nil {
+				t.Fatal("expected to return an error, but no error")
+			}
+		})
+	}
+}
+
+func TestPushStateError(t *testing.T) {
+	cases := []struct {
+		desc        string
+		err         error
+		backendType string
+		want        string
+	}{
+		{
+			desc:        "generic error",
+			err:         fmt.Errorf("failed to run command (exited 1): terraform state push: Error acquiring the state lock"),
+			backendType: "consul",
+			want:        "failed to push the state to the consul backend in foo: failed to run command (exited 1): terraform state push: Error acquiring the state lock",
+		},
+		{
+			desc:        "unsupported",
+			err:         fmt.Errorf("failed to run command (exited 1): terraform state push: HTTP error: 405 Method Not Allowed"),
+			backendType: "http",
+			want:        "failed to push the state to the http backend in foo. The http backend doesn't seem to support terraform state push, which is required by tfmigrate: failed to run command (exited 1): terraform state push: HTTP error: 405 Method Not Allowed",
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.desc, func(t *testing.T) {
+			got := pushStateError(tc.err, "foo", tc.backendType)
+			if got.Error() != tc.want {
+				t.Errorf("got: %s, want: %s", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestSkipInitError(t *testing.T) {
+	cases := []struct {
+		desc     string
+		skipInit bool
+		want     string
+	}{
+		{
+			desc:     "init",
+			skipInit: false,
+			want:     "failed to run command (exited 1): terraform state pull: Backend initialization required",
+		},
+		{
+			desc:     "skip init",
+			skipInit: true,
+			want:     "failed to pull the state in foo with the --skip-init flag. The work dir may not be initialized. Run terraform init before tfmigrate, or remove the --skip-init flag: failed to run command (exited 1): terraform state pull: Backend initialization required",
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.desc, func(t *testing.T) {
+			err := fmt.Errorf("failed to run command (exited 1): terraform state pull: Backend initialization required")
+			got := skipInitError(err, "foo", tc.skipInit)
+			if got.Error() != tc.want {
+				t.Errorf("got: %s, want: %s", got, tc.want)
+			}
+		})
+	}
+}
+
+// initRecorder is a TerraformCLI which records arguments of terraform init.
+// Other methods than ones called by initWorkDir are not implemented.
+type initRecorder struct {
+	tfexec.TerraformCLI
+	dir  string
+	args []string
+}
+
+func (r *initRecorder) Version(_ context.Context) (string, *version.Version, error) {
+	return "terraform", version.Must(version.NewVersion("1.9.0")), nil
+}
+
+func (r *initRecorder) SupportsStateReplaceProvider(_ context.Context) (bool, version.Constraints, error) {
+	return true, nil, nil
+}
+
+func (r *initRecorder) Init(_ context.Context, opts ...string) error {
+	r.args = opts
+	return nil
+}
+
+func (r *initRecorder) Dir() string {
+	return r.dir
+}
+
+func TestInitWorkDirWithInitArgs(t *testing.T) {
+	cases := []struct {
+		desc string
+		o    *MigratorOption
+		want []string
+	}{
+		{
+			desc: "default",
+			o:    &MigratorOption{},
+			want: []string{"-input=false", "-no-color"},
+		},
+		{
+			desc: "with init args",
+			o: &MigratorOption{
+				InitArgs: []string{"-upgrade", "-reconfigure"},
+			},
+			want: []string{"-input=false", "-no-color", "-upgrade", "-reconfigure"},
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.desc, func(t *testing.T) {
+			tf := &initRecorder{dir: t.TempDir()}
+			_, _, err := initWorkDir(context.Background(), tf, tc.o.initOptions(), false, false)
+			if err != nil {
+				t.Fatalf("unexpected err: %s", err)
+			}
+			if diff := cmp.Diff(tf.args, tc.want); diff != "" {
+				t.Errorf("got: %v, want: %v, diff: %s", tf.args, tc.want, diff)
+			}
+		})
+	}
+}
@@ -14,6 +14,8 @@ type MockMigratorConfig struct {
 	PlanError bool `hcl:"plan_error"`
 	// ApplyError is a flag to return an error on Apply().
 	ApplyError bool `hcl:"apply_error"`
+	// Drift is a flag to return a discrepancy on Verify().
+	Drift bool `hcl:"drift,optional"`
 }
 
 // MockMigratorConfig implements a MigratorConfig.
@@ -21,7 +23,9 @@ var _ MigratorConfig = (*MockMigratorConfig)(nil)
 
 // NewMigrator returns a new instance of MockMigrator.
 func (c *MockMigratorConfig) NewMigrator(_ *MigratorOption) (Migrator, error) {
-	return NewMockMigrator(c.PlanError, c.ApplyError), nil
+	m := NewMockMigrator(c.PlanError, c.ApplyError)
+	m.drift = c.Drift
+	return m, nil
 }
 
 // MockMigrator implements the Migrator interface for testing.
@@ -31,9 +35,12 @@ type MockMigrator struct {
 	planError bool
 	// applyError is a flag to return an error on Apply().
 	applyError bool
+	// drift is a flag to return a discrepancy on Verify().
+	drift bool
 }
 
 var _ Migrator = (*MockMigrator)(nil)
+var _ Verifier = (*MockMigrator)(nil)
 
 // NewMockMigrator returns a new MockMigrator instance.
 func NewMockMigrator(planError bool, applyError bool) *MockMigrator {
@@ -80,3 +87,13 @@ func (m *MockMigrator) Apply(ctx context.Context) error {
 	log.Printf("[INFO] [migrator] state migrator apply success!\n")
 	return nil
 }
+
+// Verify re-validates an applied migration against the current remote state.
+// It does nothing, but can return a discrepancy.
+func (m *MockMigrator) Verify(_ context.Context, _ []Migrator) (*VerifyResult, error) {
+	r := &VerifyResult{Discrepancies: []string{}, Unchecked: []string{}}
+	if m.drift {
+		r.Discrepancies = append(r.Discrepancies, fmt.Sprintf("mock migrator drifted: drift = %t", m.drift))
+	}
+	return r, nil
+}
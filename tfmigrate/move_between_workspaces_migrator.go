@@ -0,0 +1,73 @@
+package tfmigrate
+
+import (
+	"fmt"
+)
+
+// MoveBetweenWorkspacesMigratorConfig is a config for moving resources across
+// workspaces within the same working directory.
+// It's a shorthand for a multi_state migration whose from_dir and to_dir are
+// the same, and builds a MultiStateMigrator.
+type MoveBetweenWorkspacesMigratorConfig struct {
+	// Dir is a working directory for executing terraform command.
+	// Default to `.` (current directory).
+	Dir string `hcl:"dir,optional"`
+	// FromWorkspace is a workspace where states of resources move from.
+	FromWorkspace string `hcl:"from_workspace"`
+	// ToWorkspace is a workspace where states of resources move to.
+	// It must differ from the FromWorkspace.
+	ToWorkspace string `hcl:"to_workspace"`
+	// FromSkipPlan controls whether or not to run and analyze Terraform plan
+	// within the from_workspace.
+	FromSkipPlan bool `hcl:"from_skip_plan,optional"`
+	// ToSkipPlan controls whether or not to run and analyze Terraform plan
+	// within the to_workspace.
+	ToSkipPlan bool `hcl:"to_skip_plan,optional"`
+	// Actions is a list of multi state action.
+	// Each action is a plain text for state operation.
+	// Valid formats are the following.
+	// "mv <source> <destination>"
+	// "xmv <source> <destination>"
+	Actions []string `hcl:"actions"`
+	// Force option controls behaviour in case of unexpected diff in plan.
+	// When set forces applying even if plan shows diff.
+	Force bool `hcl:"force,optional"`
+	// TerraformVersion is a version of terraform used for the migration.
+	// If set, a binary for the version is selected via terraform_version_paths
+	// in the config file or a version manager such as tfenv/tofuenv.
+	TerraformVersion string `hcl:"terraform_version,optional"`
+	// Validate runs terraform validate before plan.
+	// If the validate in the config file is true, it's always enabled.
+	Validate bool `hcl:"validate,optional"`
+}
+
+// MoveBetweenWorkspacesMigratorConfig implements a MigratorConfig.
+var _ MigratorConfig = (*MoveBetweenWorkspacesMigratorConfig)(nil)
+
+// NewMigrator returns a new instance of MultiStateMigrator which moves
+// resources across workspaces within the same dir.
+func (c *MoveBetweenWorkspacesMigratorConfig) NewMigrator(o *MigratorOption) (Migrator, error) {
+	// default working directory
+	dir := migrationDir(o, c.Dir)
+
+	if len(c.FromWorkspace) == 0 || len(c.ToWorkspace) == 0 {
+		return nil, fmt.Errorf("failed to NewMigrator with empty workspaces: from_workspace = %q, to_workspace = %q", c.FromWorkspace, c.ToWorkspace)
+	}
+	if c.FromWorkspace == c.ToWorkspace {
+		return nil, fmt.Errorf("from_workspace and to_workspace must differ: %s", c.FromWorkspace)
+	}
+
+	mc := &MultiStateMigratorConfig{
+		FromDir:          dir,
+		FromSkipPlan:     c.FromSkipPlan,
+		ToDir:            dir,
+		ToSkipPlan:       c.ToSkipPlan,
+		FromWorkspace:    c.FromWorkspace,
+		ToWorkspace:      c.ToWorkspace,
+		Actions:          c.Actions,
+		Force:            c.Force,
+		TerraformVersion: c.TerraformVersion,
+		Validate:         c.Validate,
+	}
+	return mc.NewMigrator(o)
+}
@@ -0,0 +1,209 @@
+package tfmigrate
+
+import (
+	"context"
+	"reflect"
+	"sort"
+	"testing"
+
+	"github.com/minamijoyo/tfmigrate/tfexec"
+)
+
+func TestMoveBetweenWorkspacesMigratorConfigNewMigrator(t *testing.T) {
+	cases := []struct {
+		desc   string
+		config *MoveBetweenWorkspacesMigratorConfig
+		o      *MigratorOption
+		ok     bool
+	}{
+		{
+			desc: "valid",
+			config: &MoveBetweenWorkspacesMigratorConfig{
+				Dir:           "dir1",
+				FromWorkspace: "staging",
+				ToWorkspace:   "prod",
+				Actions: []string{
+					"mv null_resource.foo null_resource.foo",
+				},
+			},
+			o:  nil,
+			ok: true,
+		},
+		{
+			desc: "default dir",
+			config: &MoveBetweenWorkspacesMigratorConfig{
+				FromWorkspace: "staging",
+				ToWorkspace:   "prod",
+				Actions: []string{
+					"mv null_resource.foo null_resource.foo",
+				},
+			},
+			o:  nil,
+			ok: true,
+		},
+		{
+			desc: "same workspace",
+			config: &MoveBetweenWorkspacesMigratorConfig{
+				Dir:           "dir1",
+				FromWorkspace: "prod",
+				ToWorkspace:   "prod",
+				Actions: []string{
+					"mv null_resource.foo null_resource.foo",
+				},
+			},
+			o:  nil,
+			ok: false,
+		},
+		{
+			desc: "empty workspace",
+			config: &MoveBetweenWorkspacesMigratorConfig{
+				Dir:           "dir1",
+				FromWorkspace: "staging",
+				Actions: []string{
+					"mv null_resource.foo null_resource.foo",
+				},
+			},
+			o:  nil,
+			ok: false,
+		},
+		{
+			desc: "no actions",
+			config: &MoveBetweenWorkspacesMigratorConfig{
+				Dir:           "dir1",
+				FromWorkspace: "staging",
+				ToWorkspace:   "prod",
+				Actions:       []string{},
+			},
+			o:  nil,
+			ok: false,
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.desc, func(t *testing.T) {
+			got, err := tc.config.NewMigrator(tc.o)
+			if tc.ok && err != nil {
+				t.Fatalf("unexpected err: %s", err)
+			}
+			if !tc.ok && err == nil {
+				t.Fatalf("expected to return an error, but no error, got: %#v", got)
+			}
+			if tc.ok {
+				m := got.(*MultiStateMigrator)
+				if !m.sameDir {
+					t.Errorf("expected to move resources within the same dir, but got: %#v", m)
+				}
+			}
+		})
+	}
+}
+
+func TestAccMoveBetweenWorkspacesMigratorApply(t *testing.T) {
+	tfexec.SkipUnlessAcceptanceTestEnabled(t)
+	ctx := context.Background()
+
+	// setup the initial files and states in the same dir with two workspaces.
+	backend := tfexec.GetTestAccBackendS3Config(t.Name())
+	source := `
+resource "null_resource" "foo" { count = terraform.workspace == "staging" ? 1 : 0 }
+resource "null_resource" "qux" { count = terraform.workspace == "prod" ? 1 : 0 }
+`
+	fromWorkspace := "staging"
+	tf := tfexec.SetupTestAccWithApply(t, fromWorkspace, backend+source)
+
+	toWorkspace := "prod"
+	err := tf.WorkspaceNew(ctx, toWorkspace)
+	if err != nil {
+		t.Fatalf("failed to run terraform workspace new %s: %s", toWorkspace, err)
+	}
+	err = tf.Apply(ctx, nil, "-input=false", "-no-color", "-auto-approve")
+	if err != nil {
+		t.Fatalf("failed to run terraform apply: %s", err)
+	}
+	t.Cleanup(func() {
+		if err := tf.WorkspaceSelect(ctx, toWorkspace); err != nil {
+			t.Fatalf("failed to run terraform workspace select %s: %s", toWorkspace, err)
+		}
+		if err := tf.Destroy(ctx, "-input=false", "-no-color", "-auto-approve"); err != nil {
+			t.Fatalf("failed to run terraform destroy: %s", err)
+		}
+		if err := tf.WorkspaceSelect(ctx, fromWorkspace); err != nil {
+			t.Fatalf("failed to run terraform workspace select %s: %s", fromWorkspace, err)
+		}
+	})
+
+	// update terraform resource files for migration
+	updatedSource := `
+resource "null_resource" "foo" { count = terraform.workspace == "prod" ? 1 : 0 }
+resource "null_resource" "qux" { count = terraform.workspace == "prod" ? 1 : 0 }
+`
+	tfexec.UpdateTestAccSource(t, tf, backend+updatedSource)
+
+	// perform state migration with the to workspace selected
+	// to verify the original workspace is restored.
+	err = tf.WorkspaceSelect(ctx, toWorkspace)
+	if err != nil {
+		t.Fatalf("failed to run terraform workspace select %s: %s", toWorkspace, err)
+	}
+	config := &MoveBetweenWorkspacesMigratorConfig{
+		Dir:           tf.Dir(),
+		FromWorkspace: fromWorkspace,
+		ToWorkspace:   toWorkspace,
+		Actions: []string{
+			"mv null_resource.foo[0] null_resource.foo[0]",
+		},
+	}
+	m, err := config.NewMigrator(&MigratorOption{})
+	if err != nil {
+		t.Fatalf("failed to new migrator: %s", err)
+	}
+
+	err = m.Plan(ctx)
+	if err != nil {
+		t.Fatalf("failed to run migrator plan: %s", err)
+	}
+
+	err = m.Apply(ctx)
+	if err != nil {
+		t.Fatalf("failed to run migrator apply: %s", err)
+	}
+
+	got, err := tf.WorkspaceShow(ctx)
+	if err != nil {
+		t.Fatalf("failed to run terraform workspace show: %s", err)
+	}
+	if got != toWorkspace {
+		t.Errorf("got workspace: %s, want workspace: %s", got, toWorkspace)
+	}
+
+	// verify state migration results
+	cases := []struct {
+		workspace string
+		want      []string
+	}{
+		{
+			workspace: fromWorkspace,
+			want:      []string{},
+		},
+		{
+			workspace: toWorkspace,
+			want:      []string{"null_resource.foo[0]", "null_resource.qux[0]"},
+		},
+	}
+	for _, tc := range cases {
+		err = tf.WorkspaceSelect(ctx, tc.workspace)
+		if err != nil {
+			t.Fatalf("failed to run terraform workspace select %s: %s", tc.workspace, err)
+		}
+		got, err := tf.StateList(ctx, nil, nil)
+		if err != nil {
+			t.Fatalf("failed to run terraform state list in %s: %s", tc.workspace, err)
+		}
+		sort.Strings(got)
+		if len(got) != 0 || len(tc.want) != 0 {
+			if !reflect.DeepEqual(got, tc.want) {
+				t.Errorf("got state: %v, want state: %v in %s", got, tc.want, tc.workspace)
+			}
+		}
+	}
+}
@@ -0,0 +1,108 @@
+package tfmigrate
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+)
+
+// MovedBlock is a pair of addresses of a terraform moved block.
+type MovedBlock struct {
+	// From is an address to be moved.
+	From string
+	// To is a new address to move.
+	To string
+}
+
+// MovedBlockGenerator is implemented by migrators whose moves can be
+// expressed as terraform moved blocks in configuration. It's read-only and
+// never pushes any state.
+type MovedBlockGenerator interface {
+	// MovedBlocks returns a list of moved blocks equivalent to the migration
+	// in order. It fails if the migration contains an action which cannot be
+	// expressed as a moved block.
+	MovedBlocks(ctx context.Context) ([]MovedBlock, error)
+}
+
+var _ MovedBlockGenerator = (*StateMigrator)(nil)
+
+// MovedBlocks implements the MovedBlockGenerator interface.
+// Only mv and xmv actions are supported. The current remote state is read
+// only if an xmv action contains wildcards, and the wildcards are expanded
+// against addresses in the state as the preceding moves are applied.
+// Moves across states are not supported because a moved block cannot move
+// a resource to another state.
+func (m *StateMigrator) MovedBlocks(ctx context.Context) ([]MovedBlock, error) {
+	var stateList []string
+	if m.hasXmvWildcards() {
+		var err error
+		stateList, err = pullStateList(ctx, m.tf, m.workspace, m.o.initOptions(), m.o.SkipInit)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	blocks := []MovedBlock{}
+	for i, action := range m.actions {
+		var moves []*StateMvAction
+		switch a := action.(type) {
+		case *StateMvAction:
+			moves = []*StateMvAction{a}
+		case *StateXmvAction:
+			var err error
+			moves, err = newXmvExpander(a).expand(stateList)
+			if err != nil {
+				return nil, err
+			}
+		default:
+			return nil, fmt.Errorf("the action #%d cannot be expressed as a moved block, only mv and xmv actions are supported", i+1)
+		}
+
+		for _, mv := range moves {
+			if isDataAddress(mv.source) || isDataAddress(mv.destination) {
+				return nil, fmt.Errorf("a data source cannot be moved by a moved block: %s -> %s", mv.source, mv.destination)
+			}
+			log.Printf("[DEBUG] [migrator@%s] moved block from %s to %s\n", m.tf.Dir(), mv.source, mv.destination)
+			blocks = append(blocks, MovedBlock{From: mv.source, To: mv.destination})
+			stateList = renameAddresses(stateList, mv.source, mv.destination)
+		}
+	}
+	return blocks, nil
+}
+
+// hasXmvWildcards returns true if any xmv action of the migrator contains
+// wildcards or a regex, which need the current remote state to be expanded.
+func (m *StateMigrator) hasXmvWildcards() bool {
+	for _, action := range m.actions {
+		if a, ok := action.(*StateXmvAction); ok && newXmvExpander(a).hasPattern() {
+			return true
+		}
+	}
+	return false
+}
+
+// renameAddresses returns a new list of addresses in which a given source and
+// the addresses in it are renamed to a given destination as terraform state
+// mv does.
+// (e.g.) `module.foo.aws_instance.bar` is renamed to
+// `module.baz.aws_instance.bar` by a move from `module.foo` to `module.baz`.
+func renameAddresses(stateList []string, source string, destination string) []string {
+	ret := make([]string, 0, len(stateList))
+	for _, s := range stateList {
+		if s == source || strings.HasPrefix(s, source+".") || strings.HasPrefix(s, source+"[") {
+			s = destination + strings.TrimPrefix(s, source)
+		}
+		ret = append(ret, s)
+	}
+	return ret
+}
+
+// FormatMovedBlocks returns given moved blocks in HCL.
+func FormatMovedBlocks(blocks []MovedBlock) string {
+	hcl := make([]string, len(blocks))
+	for i, b := range blocks {
+		hcl[i] = fmt.Sprintf("moved {\n  from = %s\n  to   = %s\n}\n", b.From, b.To)
+	}
+	return strings.Join(hcl, "\n")
+}
@@ -0,0 +1,189 @@
+package tfmigrate
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"github.com/minamijoyo/tfmigrate/tfexec"
+)
+
+func TestRenameAddresses(t *testing.T) {
+	stateList := []string{
+		"aws_instance.foo",
+		"aws_instance.foo[0]",
+		"aws_instance.foobar",
+		"module.foo.aws_instance.bar",
+		`module.foo["a"].aws_instance.bar`,
+	}
+
+	cases := []struct {
+		desc        string
+		source      string
+		destination string
+		want        []string
+	}{
+		{
+			desc:        "resource",
+			source:      "aws_instance.foo",
+			destination: "aws_instance.baz",
+			want: []string{
+				"aws_instance.baz",
+				"aws_instance.baz[0]",
+				"aws_instance.foobar",
+				"module.foo.aws_instance.bar",
+				`module.foo["a"].aws_instance.bar`,
+			},
+		},
+		{
+			desc:        "module",
+			source:      "module.foo",
+			destination: "module.baz",
+			want: []string{
+				"aws_instance.foo",
+				"aws_instance.foo[0]",
+				"aws_instance.foobar",
+				"module.baz.aws_instance.bar",
+				`module.baz["a"].aws_instance.bar`,
+			},
+		},
+		{
+			desc:        "not found",
+			source:      "aws_instance.qux",
+			destination: "aws_instance.baz",
+			want:        stateList,
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.desc, func(t *testing.T) {
+			got := renameAddresses(stateList, tc.source, tc.destination)
+			if !reflect.DeepEqual(got, tc.want) {
+				t.Errorf("got: %#v, want: %#v", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestStateMigratorMovedBlocksWithoutWildcards(t *testing.T) {
+	cases := []struct {
+		desc    string
+		actions []StateAction
+		want    []MovedBlock
+		ok      bool
+	}{
+		{
+			desc: "mv and xmv",
+			actions: []StateAction{
+				NewStateMvAction("aws_security_group.foo", "aws_security_group.foo2"),
+				NewStateXmvAction("module.foo", `module.bar["a"]`),
+			},
+			want: []MovedBlock{
+				{From: "aws_security_group.foo", To: "aws_security_group.foo2"},
+				{From: "module.foo", To: `module.bar["a"]`},
+			},
+			ok: true,
+		},
+		{
+			desc: "rm",
+			actions: []StateAction{
+				NewStateMvAction("aws_security_group.foo", "aws_security_group.foo2"),
+				NewStateRmAction([]string{"aws_security_group.bar"}),
+			},
+			want: nil,
+			ok:   false,
+		},
+		{
+			desc: "data source",
+			actions: []StateAction{
+				NewStateMvAction("data.aws_ami.foo", "data.aws_ami.foo2"),
+			},
+			want: nil,
+			ok:   false,
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.desc, func(t *testing.T) {
+			m := NewStateMigrator("dir1", "default", tc.actions, &MigratorOption{}, StateMigratorOptions{})
+			got, err := m.MovedBlocks(context.Background())
+			if tc.ok && err != nil {
+				t.Fatalf("unexpected err: %s", err)
+			}
+			if !tc.ok && err == nil {
+				t.Fatalf("expected to return an error, but no error, got: %#v", got)
+			}
+			if tc.ok && !reflect.DeepEqual(got, tc.want) {
+				t.Errorf("got: %#v, want: %#v", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestFormatMovedBlocks(t *testing.T) {
+	blocks := []MovedBlock{
+		{From: "aws_security_group.foo", To: "aws_security_group.foo2"},
+		{From: `module.foo["a"]`, To: "module.bar"},
+	}
+	got := FormatMovedBlocks(blocks)
+	want := `moved {
+  from = aws_security_group.foo
+  to   = aws_security_group.foo2
+}
+
+moved {
+  from = module.foo["a"]
+  to   = module.bar
+}
+`
+	if got != want {
+		t.Errorf("got:\n%s\nwant:\n%s", got, want)
+	}
+}
+
+func TestAccStateMigratorMovedBlocks(t *testing.T) {
+	tfexec.SkipUnlessAcceptanceTestEnabled(t)
+
+	backend := tfexec.GetTestAccBackendS3Config(t.Name())
+
+	source := `
+resource "null_resource" "foo" {}
+resource "null_resource" "bar" {}
+resource "null_resource" "baz" {}
+`
+
+	workspace := "default"
+	tf := tfexec.SetupTestAccWithApply(t, workspace, backend+source)
+	ctx := context.Background()
+
+	actions := []StateAction{
+		NewStateMvAction("null_resource.baz", "null_resource.qux"),
+		NewStateXmvAction("null_resource.*", "null_resource.${1}2"),
+	}
+
+	m := NewStateMigrator(tf.Dir(), workspace, actions, &MigratorOption{}, StateMigratorOptions{})
+	got, err := m.MovedBlocks(ctx)
+	if err != nil {
+		t.Fatalf("failed to generate moved blocks: %s", err)
+	}
+
+	// The xmv is expanded against the state after the preceding mv.
+	want := []MovedBlock{
+		{From: "null_resource.baz", To: "null_resource.qux"},
+		{From: "null_resource.bar", To: "null_resource.bar2"},
+		{From: "null_resource.qux", To: "null_resource.qux2"},
+		{From: "null_resource.foo", To: "null_resource.foo2"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got: %#v, want: %#v", got, want)
+	}
+
+	// The remote state should not be changed.
+	stateList, err := tf.StateList(ctx, nil, nil)
+	if err != nil {
+		t.Fatalf("failed to run terraform state list: %s", err)
+	}
+	if len(stateList) != 3 || !containsAddress(stateList, "null_resource.baz") {
+		t.Errorf("unexpected state list: %#v", stateList)
+	}
+}
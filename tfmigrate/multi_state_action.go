@@ -20,7 +20,7 @@ type MultiStateAction interface {
 // This method is useful to build an action from terraform state command.
 // Valid formats are the following.
 // "mv <source> <destination>"
-// "xmv <source> <destination>"
+// "xmv [--case-insensitive] [--include-data] [--exclude=<pattern>]... [--expect-matches=<n>] <source> <destination>"
 func NewMultiStateActionFromString(cmdStr string) (MultiStateAction, error) {
 	args, err := splitStateAction(cmdStr)
 	if err != nil {
@@ -44,12 +44,22 @@ func NewMultiStateActionFromString(cmdStr string) (MultiStateAction, error) {
 		action = NewMultiStateMvAction(src, dst)
 
 	case "xmv":
-		if len(args) != 3 {
+		src, dst, flags, ok := parseXmvArgs(args[1:])
+		if !ok {
 			return nil, fmt.Errorf("multi state xmv action is invalid: %s", cmdStr)
 		}
-		src := args[1]
-		dst := args[2]
-		action = NewMultiStateXmvAction(src, dst)
+		a := NewMultiStateXmvAction(src, dst)
+		a.caseInsensitive = flags.caseInsensitive
+		a.excludes = flags.excludes
+		a.includeData = flags.includeData
+		a.expectMatches = flags.expectMatches
+		a.withinModule = flags.withinModule
+		a.regex = flags.regex
+		a.singleSegment = flags.singleSegment
+		if err := newXmvExpander(a.toStateXmvAction()).validateRegex(); err != nil {
+			return nil, fmt.Errorf("multi state xmv action is invalid: %s, err: %s", cmdStr, err)
+		}
+		action = a
 
 	default:
 		return nil, fmt.Errorf("unknown multi state action type: %s", cmdStr)
@@ -48,6 +48,125 @@ func TestNewMultiStateActionFromString(t *testing.T) {
 			},
 			ok: true,
 		},
+		{
+			desc:   "xmv action with case-insensitive (valid)",
+			cmdStr: "xmv --case-insensitive aws_instance.Web* aws_instance.$1",
+			want: &MultiStateXmvAction{
+				source:          "aws_instance.Web*",
+				destination:     "aws_instance.$1",
+				caseInsensitive: true,
+			},
+			ok: true,
+		},
+		{
+			desc:   "xmv action with excludes (valid)",
+			cmdStr: "xmv --exclude=aws_instance.bastion --case-insensitive --exclude=aws_instance.nat* aws_instance.* module.app.aws_instance.$1",
+			want: &MultiStateXmvAction{
+				source:          "aws_instance.*",
+				destination:     "module.app.aws_instance.$1",
+				caseInsensitive: true,
+				excludes:        []string{"aws_instance.bastion", "aws_instance.nat*"},
+			},
+			ok: true,
+		},
+		{
+			desc:   "xmv action with include-data (valid)",
+			cmdStr: "xmv --include-data * module.app.$1",
+			want: &MultiStateXmvAction{
+				source:      "*",
+				destination: "module.app.$1",
+				includeData: true,
+			},
+			ok: true,
+		},
+		{
+			desc:   "xmv action with expect-matches (valid)",
+			cmdStr: "xmv --expect-matches=5 aws_instance.* module.app.aws_instance.$1",
+			want: &MultiStateXmvAction{
+				source:        "aws_instance.*",
+				destination:   "module.app.aws_instance.$1",
+				expectMatches: intPtr(5),
+			},
+			ok: true,
+		},
+		{
+			desc:   "xmv action with invalid expect-matches",
+			cmdStr: "xmv --expect-matches=foo aws_instance.* module.app.aws_instance.$1",
+			want:   nil,
+			ok:     false,
+		},
+		{
+			desc:   "xmv action with negative expect-matches",
+			cmdStr: "xmv --expect-matches=-1 aws_instance.* module.app.aws_instance.$1",
+			want:   nil,
+			ok:     false,
+		},
+		{
+			desc:   "xmv action with regex (valid)",
+			cmdStr: `xmv --regex 'aws_instance\.web_(?P<index>[0-9]+)' 'module.web.aws_instance.this[${index}]'`,
+			want: &MultiStateXmvAction{
+				source:      `aws_instance\.web_(?P<index>[0-9]+)`,
+				destination: "module.web.aws_instance.this[${index}]",
+				regex:       true,
+			},
+			ok: true,
+		},
+		{
+			desc:   "xmv action with single-segment",
+			cmdStr: "xmv --single-segment module.*.aws_instance.* module.$1.aws_instance.${2}_new",
+			want: &MultiStateXmvAction{
+				source:        "module.*.aws_instance.*",
+				destination:   "module.$1.aws_instance.${2}_new",
+				singleSegment: true,
+			},
+			ok: true,
+		},
+		{
+			desc:   "xmv action with invalid regex",
+			cmdStr: "xmv --regex 'aws_instance.(foo' module.app.aws_instance.$1",
+			want:   nil,
+			ok:     false,
+		},
+		{
+			desc:   "xmv action with regex and an undefined capture group",
+			cmdStr: "xmv --regex 'aws_instance.(?P<name>.+)' 'module.app.aws_instance.${nmae}'",
+			want:   nil,
+			ok:     false,
+		},
+		{
+			desc:   "xmv action with within-module (valid)",
+			cmdStr: `xmv '--within-module=module.network["a"]' 'module.network["a"].*' module.vpc.$1`,
+			want: &MultiStateXmvAction{
+				source:       `module.network["a"].*`,
+				destination:  "module.vpc.$1",
+				withinModule: `module.network["a"]`,
+			},
+			ok: true,
+		},
+		{
+			desc:   "xmv action with invalid within-module",
+			cmdStr: "xmv --within-module=aws_instance.foo aws_instance.* module.app.aws_instance.$1",
+			want:   nil,
+			ok:     false,
+		},
+		{
+			desc:   "xmv action with empty exclude",
+			cmdStr: "xmv --exclude= aws_instance.* module.app.aws_instance.$1",
+			want:   nil,
+			ok:     false,
+		},
+		{
+			desc:   "xmv action with unknown flag",
+			cmdStr: "xmv --foo aws_instance.* module.app.aws_instance.$1",
+			want:   nil,
+			ok:     false,
+		},
+		{
+			desc:   "xmv action with case-insensitive (1 arg)",
+			cmdStr: "xmv --case-insensitive aws_instance.Web*",
+			want:   nil,
+			ok:     false,
+		},
 		{
 			desc:   "xmv action (no args)",
 			cmdStr: "xmv",
@@ -6,6 +6,7 @@ import (
 	"fmt"
 	"log"
 	"os"
+	"path/filepath"
 
 	"github.com/minamijoyo/tfmigrate/tfexec"
 )
@@ -25,6 +26,9 @@ type MultiStateMigratorConfig struct {
 	// FromWorkspace is a workspace within FromDir
 	FromWorkspace string `hcl:"from_workspace,optional"`
 	// ToWorkspace is a workspace within ToDir
+	// If the ToDir is the same as the FromDir, resources are moved across
+	// workspaces within the same backend, and it must differ from the
+	// FromWorkspace.
 	ToWorkspace string `hcl:"to_workspace,optional"`
 	// Actions is a list of multi state action.
 	// Each action is a plain text for state operation.
@@ -34,6 +38,20 @@ type MultiStateMigratorConfig struct {
 	// Force option controls behaviour in case of unexpected diff in plan.
 	// When set forces applying even if plan shows diff.
 	Force bool `hcl:"force,optional"`
+	// TerraformVersion is a version of terraform used for the migration.
+	// If set, a binary for the version is selected via terraform_version_paths
+	// in the config file or a version manager such as tfenv/tofuenv.
+	TerraformVersion string `hcl:"terraform_version,optional"`
+	// Validate runs terraform validate before plan in both directories.
+	// If the validate in the config file is true, it's always enabled.
+	Validate bool `hcl:"validate,optional"`
+	// FromEnv is a map of environment variables passed to terraform command
+	// only in the from_dir, in addition to the env of the migration.
+	// It's intended for credentials of a backend in a different account.
+	FromEnv map[string]string `hcl:"from_env,optional"`
+	// ToEnv is a map of environment variables passed to terraform command
+	// only in the to_dir, in addition to the env of the migration.
+	ToEnv map[string]string `hcl:"to_env,optional"`
 }
 
 // MultiStateMigratorConfig implements a MigratorConfig.
@@ -45,6 +63,22 @@ func (c *MultiStateMigratorConfig) NewMigrator(o *MigratorOption) (Migrator, err
 		return nil, fmt.Errorf("failed to NewMigrator with no actions")
 	}
 
+	if o != nil && len(o.PlanFile) > 0 {
+		return nil, fmt.Errorf("a saved plan file is not supported for multi_state migration")
+	}
+
+	if o != nil && len(o.Only) > 0 {
+		return nil, fmt.Errorf("--only is not supported for multi_state migration")
+	}
+
+	if o != nil && o.Idempotent {
+		return nil, fmt.Errorf("--idempotent is not supported for multi_state migration")
+	}
+
+	if o != nil && len(o.StateOut) > 0 {
+		return nil, fmt.Errorf("--state-out is not supported for multi_state migration")
+	}
+
 	// build actions from config.
 	actions := []MultiStateAction{}
 	for _, cmdStr := range c.Actions {
@@ -62,8 +96,54 @@ func (c *MultiStateMigratorConfig) NewMigrator(o *MigratorOption) (Migrator, err
 	if len(c.ToWorkspace) == 0 {
 		c.ToWorkspace = "default"
 	}
+	if filepath.Clean(c.FromDir) == filepath.Clean(c.ToDir) && c.FromWorkspace == c.ToWorkspace {
+		return nil, fmt.Errorf("from_workspace and to_workspace must differ when from_dir and to_dir are the same: %s", c.FromWorkspace)
+	}
+
+	if c.Validate {
+		o = withValidate(o)
+	}
+
+	// The from_dir and the to_dir may be pinned to different terraform
+	// binaries, so that each of them is set up by its own binary.
+	fromOption, err := c.optionForDir(o, c.FromDir)
+	if err != nil {
+		return nil, err
+	}
+	toOption, err := c.optionForDir(o, c.ToDir)
+	if err != nil {
+		return nil, err
+	}
+
+	m := NewMultiStateMigrator(c.FromDir, c.ToDir, c.FromWorkspace, c.ToWorkspace, actions, o, c.Force, c.FromSkipPlan, c.ToSkipPlan)
+	if len(c.FromEnv) > 0 || len(c.ToEnv) > 0 {
+		if m.sameDir {
+			// Both workspaces are set up in the same working dir.
+			return nil, fmt.Errorf("from_env and to_env are not supported when from_dir and to_dir are the same")
+		}
+		fromOption = withEnv(fromOption, c.FromEnv)
+		toOption = withEnv(toOption, c.ToEnv)
+	}
+	m.fromTf = newTerraformCLI(fromOption, c.FromDir)
+	m.toTf = newTerraformCLI(toOption, c.ToDir)
+	return m, nil
+}
+
+// optionForDir returns a copy of a given MigratorOption whose ExecPath is
+// resolved for a given dir and the terraform_version.
+func (c *MultiStateMigratorConfig) optionForDir(o *MigratorOption, dir string) (*MigratorOption, error) {
+	o, err := withExecPathForDir(o, dir)
+	if err != nil {
+		return nil, err
+	}
 
-	return NewMultiStateMigrator(c.FromDir, c.ToDir, c.FromWorkspace, c.ToWorkspace, actions, o, c.Force, c.FromSkipPlan, c.ToSkipPlan), nil
+	if len(c.TerraformVersion) > 0 {
+		o, err = withTerraformVersion(o, c.TerraformVersion)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return o, nil
 }
 
 // MultiStateMigrator implements the Migrator interface.
@@ -87,6 +167,14 @@ type MultiStateMigrator struct {
 	o *MigratorOption
 	// force operation in case of unexpected diff
 	force bool
+	// sameDir is true if the fromDir and the toDir are the same.
+	// In this case, resources are moved across workspaces within the same
+	// backend and we need to select a workspace before each operation.
+	sameDir bool
+	// planResults and stateLists collect a summary of the migration for
+	// the ApplyCallback. They are nil unless the callback is set.
+	planResults *PlanResultCollector
+	stateLists  *StateListCollector
 }
 
 var _ Migrator = (*MultiStateMigrator)(nil)
@@ -94,14 +182,8 @@ var _ Migrator = (*MultiStateMigrator)(nil)
 // NewMultiStateMigrator returns a new MultiStateMigrator instance.
 func NewMultiStateMigrator(fromDir string, toDir string, fromWorkspace string, toWorkspace string,
 	actions []MultiStateAction, o *MigratorOption, force bool, fromSkipPlan bool, toSkipPlan bool) *MultiStateMigrator {
-	fromTf := tfexec.NewTerraformCLI(tfexec.NewExecutor(fromDir, os.Environ()))
-	toTf := tfexec.NewTerraformCLI(tfexec.NewExecutor(toDir, os.Environ()))
-	if o != nil && len(o.ExecPath) > 0 {
-		// While NewTerraformCLI reads the environment variable TFMIGRATE_EXEC_PATH
-		// at initialization, the MigratorOption takes precedence over it.
-		fromTf.SetExecPath(o.ExecPath)
-		toTf.SetExecPath(o.ExecPath)
-	}
+	fromTf := newTerraformCLI(o, fromDir)
+	toTf := newTerraformCLI(o, toDir)
 
 	return &MultiStateMigrator{
 		fromTf:        fromTf,
@@ -113,70 +195,105 @@ func NewMultiStateMigrator(fromDir string, toDir string, fromWorkspace string, t
 		actions:       actions,
 		o:             o,
 		force:         force,
+		sameDir:       filepath.Clean(fromDir) == filepath.Clean(toDir),
 	}
 }
 
-// plan computes new states by applying multi state migration operations to temporary states.
-// It will fail if terraform plan detects any diffs with at least one new state.
-// We intentionally make this method private to avoid exposing internal states and unify
-// the Migrator interface between a single and multi state migrator.
-func (m *MultiStateMigrator) plan(ctx context.Context) (fromCurrentState *tfexec.State, toCurrentState *tfexec.State, err error) {
+// selectWorkspace switches to a given workspace if the fromDir and the toDir
+// are the same. Otherwise, it's a no-op because each dir keeps its workspace.
+func (m *MultiStateMigrator) selectWorkspace(ctx context.Context, tf tfexec.TerraformCLI, workspace string) error {
+	if !m.sameDir {
+		return nil
+	}
+	log.Printf("[INFO] [migrator@%s] switch to workspace %s\n", tf.Dir(), workspace)
+	return tf.WorkspaceSelect(ctx, workspace)
+}
+
+// setupWorkDirs sets up the fromDir and the toDir and returns the current
+// states and switch back functions.
+func (m *MultiStateMigrator) setupWorkDirs(ctx context.Context) (fromState *tfexec.State, toState *tfexec.State, switchBackToRemoteFuncs []func() error, err error) {
+	if m.sameDir {
+		// setup a dir shared by both workspaces.
+		fromState, toState, switchBackToRemoteFunc, err := setupWorkDirForWorkspaces(ctx, m.fromTf, m.fromWorkspace, m.toWorkspace, m.o.IsBackendTerraformCloud, m.o.BackendConfig, m.o.initOptions(), m.o.SkipInit)
+		if err != nil {
+			return nil, nil, nil, err
+		}
+		return fromState, toState, []func() error{switchBackToRemoteFunc}, nil
+	}
+
 	// setup fromDir.
-	fromCurrentState, fromSwitchBackToRemoteFunc, err := setupWorkDir(ctx, m.fromTf, m.fromWorkspace, m.o.IsBackendTerraformCloud, m.o.BackendConfig, false)
+	fromState, fromSwitchBackToRemoteFunc, err := setupWorkDir(ctx, m.fromTf, m.fromWorkspace, m.o.IsBackendTerraformCloud, m.o.BackendConfig, m.o.initOptions(), false, m.o.SkipInit)
 	if err != nil {
-		return nil, nil, err
+		return nil, nil, nil, err
 	}
-	// switch back it to remote on exit.
-	defer func() {
-		err = errors.Join(err, fromSwitchBackToRemoteFunc())
-	}()
+	switchBackToRemoteFuncs = append(switchBackToRemoteFuncs, fromSwitchBackToRemoteFunc)
 
 	// setup toDir.
-	toCurrentState, toSwitchBackToRemoteFunc, err := setupWorkDir(ctx, m.toTf, m.toWorkspace, m.o.IsBackendTerraformCloud, m.o.BackendConfig, false)
+	toState, toSwitchBackToRemoteFunc, err := setupWorkDir(ctx, m.toTf, m.toWorkspace, m.o.IsBackendTerraformCloud, m.o.BackendConfig, m.o.initOptions(), false, m.o.SkipInit)
 	if err != nil {
-		return nil, nil, err
+		return nil, nil, switchBackToRemoteFuncs, err
 	}
-	// switch back it to remote on exit.
+	switchBackToRemoteFuncs = append(switchBackToRemoteFuncs, toSwitchBackToRemoteFunc)
+
+	return fromState, toState, switchBackToRemoteFuncs, nil
+}
+
+// plan computes new states by applying multi state migration operations to temporary states.
+// It will fail if terraform plan detects any diffs with at least one new state.
+// We intentionally make this method private to avoid exposing internal states and unify
+// the Migrator interface between a single and multi state migrator.
+// It also returns the original states pulled from remote, which are used for
+// backup and rollback on apply.
+func (m *MultiStateMigrator) plan(ctx context.Context) (fromOriginalState *tfexec.State, toOriginalState *tfexec.State, fromCurrentState *tfexec.State, toCurrentState *tfexec.State, err error) {
+	// setup fromDir and toDir.
+	fromCurrentState, toCurrentState, switchBackToRemoteFuncs, err := m.setupWorkDirs(ctx)
+	// switch back them to remote on exit in reverse order.
 	defer func() {
-		err = errors.Join(err, toSwitchBackToRemoteFunc())
+		for i := len(switchBackToRemoteFuncs) - 1; i >= 0; i-- {
+			err = errors.Join(err, switchBackToRemoteFuncs[i]())
+		}
 	}()
+	if err != nil {
+		return nil, nil, nil, nil, err
+	}
 
-	// computes new states by applying state migration operations to temporary states.
-	log.Printf("[INFO] [migrator] compute new states (%s => %s)\n", m.fromTf.Dir(), m.toTf.Dir())
-	var fromNewState, toNewState *tfexec.State
-	for _, action := range m.actions {
-		fromNewState, toNewState, err = action.MultiStateUpdate(ctx, m.fromTf, m.toTf, fromCurrentState, toCurrentState)
-		if err != nil {
-			return nil, nil, err
+	fromOriginalState = fromCurrentState
+	toOriginalState = toCurrentState
+
+	if m.o.Validate {
+		if err = validateWorkDir(ctx, m.fromTf); err != nil {
+			return nil, nil, nil, nil, err
+		}
+		if !m.sameDir {
+			if err = validateWorkDir(ctx, m.toTf); err != nil {
+				return nil, nil, nil, nil, err
+			}
 		}
-		fromCurrentState = tfexec.NewState(fromNewState.Bytes())
-		toCurrentState = tfexec.NewState(toNewState.Bytes())
 	}
 
-	// build plan options
-	planOpts := []string{"-input=false", "-no-color", "-detailed-exitcode"}
-	if m.o.PlanOut != "" {
-		planOpts = append(planOpts, "-out="+m.o.PlanOut)
+	// computes new states by applying state migration operations to temporary states.
+	fromCurrentState, toCurrentState, err = m.applyActions(ctx, fromCurrentState, toCurrentState)
+	if err != nil {
+		return nil, nil, nil, nil, err
 	}
+	m.logMovedResources(fromOriginalState, fromCurrentState, toOriginalState, toCurrentState)
+
+	// build plan options
+	planOpts := m.o.planOptions()
 
+	// Run plans in both dirs even if the one in fromDir has diffs, so that we
+	// can see diffs on both sides at once. Moving resources may affect both.
+	diffErrs := []error{}
 	if m.fromSkipPlan {
 		log.Printf("[INFO] [migrator@%s] skipping check diffs\n", m.fromTf.Dir())
 	} else {
 		// check if a plan in fromDir has no changes.
-		log.Printf("[INFO] [migrator@%s] check diffs\n", m.fromTf.Dir())
-		_, err = m.fromTf.Plan(ctx, fromCurrentState, planOpts...)
-		if err != nil {
-			if exitErr, ok := err.(tfexec.ExitError); ok && exitErr.ExitCode() == 2 {
-				if !m.force {
-					log.Printf("[ERROR] [migrator@%s] unexpected diffs\n", m.fromTf.Dir())
-					return nil, nil, fmt.Errorf("terraform plan command returns unexpected diffs in %s from_dir: %s", m.fromTf.Dir(), err)
-				}
-				log.Printf("[INFO] [migrator@%s] unexpected diffs, ignoring as force option is true: %s", m.fromTf.Dir(), err)
-				// reset err to nil to intentionally ignore unexpected diffs.
-				err = nil
-			} else {
-				return nil, nil, err
-			}
+		diffErr, perr := m.checkDiffs(ctx, m.fromTf, m.fromWorkspace, fromCurrentState, "from_dir", planOpts)
+		if perr != nil {
+			return nil, nil, nil, nil, perr
+		}
+		if diffErr != nil {
+			diffErrs = append(diffErrs, diffErr)
 		}
 	}
 
@@ -184,31 +301,121 @@ func (m *MultiStateMigrator) plan(ctx context.Context) (fromCurrentState *tfexec
 		log.Printf("[INFO] [migrator@%s] skipping check diffs\n", m.toTf.Dir())
 	} else {
 		// check if a plan in toDir has no changes.
-		log.Printf("[INFO] [migrator@%s] check diffs\n", m.toTf.Dir())
-		_, err = m.toTf.Plan(ctx, toCurrentState, planOpts...)
+		diffErr, perr := m.checkDiffs(ctx, m.toTf, m.toWorkspace, toCurrentState, "to_dir", planOpts)
+		if perr != nil {
+			return nil, nil, nil, nil, perr
+		}
+		if diffErr != nil {
+			diffErrs = append(diffErrs, diffErr)
+		}
+	}
+
+	if len(diffErrs) > 0 {
+		return nil, nil, nil, nil, errors.Join(diffErrs...)
+	}
+
+	return fromOriginalState, toOriginalState, fromCurrentState, toCurrentState, err
+}
+
+// checkDiffs runs terraform plan in a given dir with a given new state, and
+// checks it has no changes. A given side is either from_dir or to_dir, which
+// labels logs and errors. It returns an error of unexpected diffs as diffErr,
+// so that the caller can check the other side before failing.
+// Diffs are ignored if the force option is true. Any other error is returned
+// as err.
+func (m *MultiStateMigrator) checkDiffs(ctx context.Context, tf tfexec.TerraformCLI, workspace string, state *tfexec.State, side string, planOpts []string) (diffErr error, err error) {
+	log.Printf("[INFO] [migrator@%s] check diffs in %s\n", tf.Dir(), side)
+	if err := m.selectWorkspace(ctx, tf, workspace); err != nil {
+		return nil, err
+	}
+	plan, err := runPlan(ctx, tf, state, m.o, planOpts...)
+	collectPlanResult(ctx, tf, plan, m.o.PlanResultCollector, m.planResults)
+	if err == nil {
+		log.Printf("[INFO] [migrator@%s] no diffs in %s\n", tf.Dir(), side)
+		return nil, nil
+	}
+
+	if exitErr, ok := err.(tfexec.ExitError); !ok || exitErr.ExitCode() != 2 {
+		return nil, err
+	}
+	if m.force {
+		log.Printf("[INFO] [migrator@%s] unexpected diffs in %s, ignoring as force option is true: %s", tf.Dir(), side, err)
+		return nil, nil
+	}
+	log.Printf("[ERROR] [migrator@%s] unexpected diffs in %s\n", tf.Dir(), side)
+	return fmt.Errorf("terraform plan command returns unexpected diffs in %s %s: %s", tf.Dir(), side, err), nil
+}
+
+// keepWorkspace returns a function which restores the currently selected
+// workspace if the fromDir and the toDir are the same, because we select each
+// workspace in turn. Otherwise, it returns a no-op function.
+func (m *MultiStateMigrator) keepWorkspace(ctx context.Context) (func() error, error) {
+	if !m.sameDir {
+		return func() error { return nil }, nil
+	}
+	workspace, err := m.fromTf.WorkspaceShow(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get the current workspace in %s: %s", m.fromTf.Dir(), err)
+	}
+	return func() error {
+		log.Printf("[INFO] [migrator@%s] restore the original workspace %s\n", m.fromTf.Dir(), workspace)
+		if err := m.fromTf.WorkspaceSelect(ctx, workspace); err != nil {
+			log.Printf("[ERROR] [migrator@%s] failed to restore the original workspace: %s\n", m.fromTf.Dir(), err)
+			log.Printf("[ERROR] [migrator@%s] please run terraform workspace select %s\n", m.fromTf.Dir(), workspace)
+			return err
+		}
+		return nil
+	}, nil
+}
+
+// applyActions computes new states by applying multi state migration
+// operations to given temporary states.
+func (m *MultiStateMigrator) applyActions(ctx context.Context, fromCurrentState *tfexec.State, toCurrentState *tfexec.State) (_ *tfexec.State, _ *tfexec.State, err error) {
+	ctx, span := startPhaseSpan(ctx, m.fromTf, "actions")
+	defer func() { EndSpan(span, err) }()
+
+	log.Printf("[INFO] [migrator] compute new states (%s => %s)\n", m.fromTf.Dir(), m.toTf.Dir())
+	fromInitialState, toInitialState := fromCurrentState, toCurrentState
+	var fromNewState, toNewState *tfexec.State
+	for _, action := range m.actions {
+		if err = checkMultiStateDestinationConflicts(action, fromCurrentState, toCurrentState); err != nil {
+			return nil, nil, err
+		}
+		fromNewState, toNewState, err = action.MultiStateUpdate(ctx, m.fromTf, m.toTf, fromCurrentState, toCurrentState)
 		if err != nil {
-			if exitErr, ok := err.(tfexec.ExitError); ok && exitErr.ExitCode() == 2 {
-				if !m.force {
-					log.Printf("[ERROR] [migrator@%s] unexpected diffs\n", m.toTf.Dir())
-					return nil, nil, fmt.Errorf("terraform plan command returns unexpected diffs in %s to_dir: %s", m.toTf.Dir(), err)
-				}
-				log.Printf("[INFO] [migrator@%s] unexpected diffs, ignoring as force option is true: %s", m.toTf.Dir(), err)
-				// reset err to nil to intentionally ignore unexpected diffs.
-				err = nil
-			} else {
-				return nil, nil, err
-			}
+			return nil, nil, err
 		}
+		if err = checkMultiStateActionIntegrity(action, fromCurrentState, toCurrentState, fromNewState, toNewState); err != nil {
+			return nil, nil, err
+		}
+		// Release intermediate states early, which may be backed by files.
+		// The initial states are owned by the caller.
+		if fromCurrentState != fromInitialState && fromCurrentState != fromNewState {
+			fromCurrentState.Close()
+		}
+		if toCurrentState != toInitialState && toCurrentState != toNewState {
+			toCurrentState.Close()
+		}
+		fromCurrentState, toCurrentState = fromNewState, toNewState
 	}
 
-	return fromCurrentState, toCurrentState, err
+	return fromCurrentState, toCurrentState, nil
 }
 
 // Plan computes new states by applying multi state migration operations to temporary states.
 // It will fail if terraform plan detects any diffs with at least one new state.
-func (m *MultiStateMigrator) Plan(ctx context.Context) error {
+func (m *MultiStateMigrator) Plan(ctx context.Context) (err error) {
+	// restore the original workspace on exit.
+	restoreWorkspaceFunc, err := m.keepWorkspace(ctx)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		err = errors.Join(err, restoreWorkspaceFunc())
+	}()
+
 	log.Printf("[INFO] [migrator] multi start state migrator plan\n")
-	_, _, err := m.plan(ctx)
+	_, _, _, _, err = m.plan(ctx)
 	if err != nil {
 		return err
 	}
@@ -220,29 +427,168 @@ func (m *MultiStateMigrator) Plan(ctx context.Context) error {
 // It will fail if terraform plan detects any diffs with at least one new state.
 // We are intended to this is used for state refactoring.
 // Any state migration operations should not break any real resources.
-func (m *MultiStateMigrator) Apply(ctx context.Context) error {
+//
+// The new states are pushed in two phases to avoid losing resources from state
+// tracking. The original states are saved to a backup directory before push
+// so that an operator can recover them manually at any step. Since the backups
+// contain secrets, the directory is removed unless the apply phase fails.
+//
+//  1. Push the new toState, which has the added resources, and verify it.
+//     If it fails, the toState is rolled back to the original one.
+//  2. Push the new fromState, which has the removed resources.
+//     If it fails, the toState is rolled back to the original one.
+//
+// There are still failure windows which cannot be recovered automatically.
+// If a rollback of the toState fails, the resources are tracked in both
+// states. If the fromState is partially written by the backend, the remote
+// state may be inconsistent. In these cases, the error message contains the
+// paths of the backups.
+//
+// If the fromDir and the toDir are the same, the originally selected workspace
+// is restored on exit.
+func (m *MultiStateMigrator) Apply(ctx context.Context) (err error) {
+	// restore the original workspace on exit.
+	restoreWorkspaceFunc, err := m.keepWorkspace(ctx)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		err = errors.Join(err, restoreWorkspaceFunc())
+	}()
+
 	// Check if new states don't have any diffs compared to real resources
 	// before push new states to remote.
 	log.Printf("[INFO] [migrator] start multi state migrator plan phase for apply\n")
-	fromState, toState, err := m.plan(ctx)
+	if m.o.ApplyCallback != nil {
+		m.planResults = NewPlanResultCollector()
+		m.stateLists = NewStateListCollector(false)
+	}
+	fromOriginalState, toOriginalState, fromState, toState, err := m.plan(ctx)
 	if err != nil {
 		return err
 	}
 
+	if m.o.DryRun {
+		if len(m.o.DryRunDir) == 0 {
+			log.Printf("[INFO] [migrator] dry-run: skip pushing the new states to remote\n")
+			log.Printf("[INFO] [migrator] multi state migrator apply (dry-run) success!\n")
+			return nil
+		}
+		dir, err := saveDryRunStates(m.o.DryRunDir, map[string]*tfexec.State{"from.tfstate": fromState, "to.tfstate": toState})
+		if err != nil {
+			return err
+		}
+		log.Printf("[INFO] [migrator] dry-run: skip pushing the new states to remote. saved them to %s\n", dir)
+		log.Printf("[INFO] [migrator] multi state migrator apply (dry-run) success!\n")
+		return nil
+	}
+
+	// save the original states for manual recovery.
+	backupDir, err := os.MkdirTemp(m.o.TmpDir, "tfmigrate-backup-")
+	if err != nil {
+		return fmt.Errorf("failed to create a backup directory: %s", err)
+	}
+	// The backups are kept only if the apply phase fails, whose error tells
+	// the user where they are.
+	keepBackup := false
+	defer func() {
+		if err != nil && keepBackup {
+			return
+		}
+		if rerr := os.RemoveAll(backupDir); rerr != nil {
+			log.Printf("[WARN] [migrator] failed to remove the backup directory %s: %s\n", backupDir, rerr)
+		}
+	}()
+	fromBackup, err := saveStateBackup(backupDir, "from.tfstate", fromOriginalState)
+	if err != nil {
+		return err
+	}
+	toBackup, err := saveStateBackup(backupDir, "to.tfstate", toOriginalState)
+	if err != nil {
+		return err
+	}
+	log.Printf("[INFO] [migrator] save the original states to %s\n", backupDir)
+	keepBackup = true
+
 	// push the new states to remote.
 	// We push toState before fromState, because when moving resources across
 	// states, write them to new state first and then remove them from old one.
 	log.Printf("[INFO] [migrator] start multi state migrator apply phase\n")
+	toState, err = nextState(toOriginalState, toState)
+	if err != nil {
+		return fmt.Errorf("failed to prepare the new state in %s to_dir: %s (backups: from=%s, to=%s)", m.toTf.Dir(), err, fromBackup, toBackup)
+	}
+	fromState, err = nextState(fromOriginalState, fromState)
+	if err != nil {
+		return fmt.Errorf("failed to prepare the new state in %s from_dir: %s (backups: from=%s, to=%s)", m.fromTf.Dir(), err, fromBackup, toBackup)
+	}
 	log.Printf("[INFO] [migrator@%s] push the new state to remote\n", m.toTf.Dir())
-	err = m.toTf.StatePush(ctx, toState)
+	if err = m.selectWorkspace(ctx, m.toTf, m.toWorkspace); err != nil {
+		return fmt.Errorf("failed to select the workspace in %s to_dir: %s (backups: from=%s, to=%s)", m.toTf.Dir(), err, fromBackup, toBackup)
+	}
+	err = pushState(ctx, m.toTf, toState)
 	if err != nil {
-		return err
+		return fmt.Errorf("failed to push the new state in %s to_dir: %s (backups: from=%s, to=%s)", m.toTf.Dir(), err, fromBackup, toBackup)
 	}
+
+	log.Printf("[INFO] [migrator@%s] verify the pushed state\n", m.toTf.Dir())
+	err = verifyStatePushed(ctx, m.toTf, toState)
+	if err != nil {
+		return m.rollbackToState(ctx, toOriginalState, fmt.Errorf("failed to verify the pushed state in %s to_dir: %s", m.toTf.Dir(), err), fromBackup, toBackup)
+	}
+
 	log.Printf("[INFO] [migrator@%s] push the new state to remote\n", m.fromTf.Dir())
-	err = m.fromTf.StatePush(ctx, fromState)
+	if err = m.selectWorkspace(ctx, m.fromTf, m.fromWorkspace); err != nil {
+		return m.rollbackToState(ctx, toOriginalState, fmt.Errorf("failed to select the workspace in %s from_dir: %s", m.fromTf.Dir(), err), fromBackup, toBackup)
+	}
+	err = pushState(ctx, m.fromTf, fromState)
 	if err != nil {
-		return err
+		return m.rollbackToState(ctx, toOriginalState, fmt.Errorf("failed to push the new state in %s from_dir: %s", m.fromTf.Dir(), err), fromBackup, toBackup)
+	}
+
+	// Don't rollback the toState here, because the fromState may have been
+	// written and the resources would be lost from both states.
+	log.Printf("[INFO] [migrator@%s] verify the pushed state\n", m.fromTf.Dir())
+	err = verifyStatePushed(ctx, m.fromTf, fromState)
+	if err != nil {
+		return &PushedError{Err: fmt.Errorf("failed to verify the pushed state in %s from_dir: %s. Check both states and restore them manually from backups if needed: from=%s, to=%s", m.fromTf.Dir(), err, fromBackup, toBackup)}
 	}
+	m.collectStateLists(ctx, fromOriginalState, toOriginalState)
 	log.Printf("[INFO] [migrator] multi state migrator apply success!\n")
+	notifyApplied(ctx, m.o.ApplyCallback, m.planResults, m.stateLists)
 	return nil
 }
+
+// collectStateLists collects lists of resource addresses in both remote
+// states after push. It's a no-op if no collector is set.
+func (m *MultiStateMigrator) collectStateLists(ctx context.Context, fromOriginalState *tfexec.State, toOriginalState *tfexec.State) {
+	if m.o.StateListCollector == nil && m.stateLists == nil {
+		return
+	}
+	// The fromWorkspace has already been selected for push.
+	collectStateList(ctx, m.fromTf, m.fromWorkspace, fromOriginalState, m.o.StateListCollector, m.stateLists)
+	if err := m.selectWorkspace(ctx, m.toTf, m.toWorkspace); err != nil {
+		log.Printf("[WARN] [migrator@%s] failed to collect a state list: %s\n", m.toTf.Dir(), err)
+		return
+	}
+	collectStateList(ctx, m.toTf, m.toWorkspace, toOriginalState, m.o.StateListCollector, m.stateLists)
+}
+
+// rollbackToState restores the original toState after a failure in the apply
+// phase and returns an error which wraps a given cause.
+// The -force flag is required because the remote serial has been incremented.
+// The rollback runs even if a given ctx has been canceled or timed out, because
+// the cause may be the timeout of the migration.
+func (m *MultiStateMigrator) rollbackToState(ctx context.Context, toOriginalState *tfexec.State, cause error, fromBackup string, toBackup string) error {
+	log.Printf("[ERROR] [migrator@%s] rollback the state: %s\n", m.toTf.Dir(), cause)
+	ctx = context.WithoutCancel(ctx)
+	err := m.selectWorkspace(ctx, m.toTf, m.toWorkspace)
+	if err == nil {
+		err = pushState(ctx, m.toTf, toOriginalState, "-force")
+	}
+	if err != nil {
+		log.Printf("[ERROR] [migrator@%s] failed to rollback the state: %s\n", m.toTf.Dir(), err)
+		return fmt.Errorf("%s, and failed to rollback the state in %s to_dir: %s. The resources may be tracked in both states. Restore them manually from backups: from=%s, to=%s", cause, m.toTf.Dir(), err, fromBackup, toBackup)
+	}
+	return fmt.Errorf("%s, and the state in %s to_dir has been rolled back (backups: from=%s, to=%s)", cause, m.toTf.Dir(), fromBackup, toBackup)
+}
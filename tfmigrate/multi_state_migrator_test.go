@@ -143,6 +143,171 @@ func TestMultiStateMigratorConfigNewMigrator(t *testing.T) {
 			o:  nil,
 			ok: true,
 		},
+		{
+			desc: "saved plan file is not supported",
+			config: &MultiStateMigratorConfig{
+				FromDir: "dir1",
+				ToDir:   "dir2",
+				Actions: []string{
+					"mv null_resource.foo null_resource.foo2",
+				},
+			},
+			o: &MigratorOption{
+				PlanFile: "foo.tfplan",
+			},
+			ok: false,
+		},
+		{
+			desc: "state out is not supported",
+			config: &MultiStateMigratorConfig{
+				FromDir: "dir1",
+				ToDir:   "dir2",
+				Actions: []string{
+					"mv null_resource.foo null_resource.foo2",
+				},
+			},
+			o: &MigratorOption{
+				DryRun:   true,
+				StateOut: "new.tfstate",
+			},
+			ok: false,
+		},
+		{
+			desc: "idempotent is not supported",
+			config: &MultiStateMigratorConfig{
+				FromDir: "dir1",
+				ToDir:   "dir2",
+				Actions: []string{
+					"mv null_resource.foo null_resource.foo2",
+				},
+			},
+			o: &MigratorOption{
+				Idempotent: true,
+			},
+			ok: false,
+		},
+		{
+			desc: "with exec path resolver",
+			config: &MultiStateMigratorConfig{
+				FromDir: "legacy/foo",
+				ToDir:   "legacy/bar",
+				Actions: []string{
+					"mv null_resource.foo null_resource.foo2",
+				},
+			},
+			o: &MigratorOption{
+				ExecPathResolver: &tfexec.ExecPathResolver{
+					Rules: []tfexec.ExecPathRule{
+						{Pattern: "legacy/*", ExecPath: "/opt/terraform/0.12/terraform"},
+					},
+					DefaultExecPath: "terraform",
+				},
+			},
+			ok: true,
+		},
+		{
+			desc: "with exec path resolver (different binaries)",
+			config: &MultiStateMigratorConfig{
+				FromDir: "legacy/foo",
+				ToDir:   "modern/bar",
+				Actions: []string{
+					"mv null_resource.foo null_resource.foo2",
+				},
+			},
+			o: &MigratorOption{
+				ExecPathResolver: &tfexec.ExecPathResolver{
+					Rules: []tfexec.ExecPathRule{
+						{Pattern: "legacy/*", ExecPath: "/opt/terraform/0.12/terraform"},
+					},
+					DefaultExecPath: "terraform",
+				},
+			},
+			ok: true,
+		},
+		{
+			desc: "with terraform_version in terraform_version_paths",
+			config: &MultiStateMigratorConfig{
+				FromDir: "dir1",
+				ToDir:   "dir2",
+				Actions: []string{
+					"mv null_resource.foo null_resource.foo2",
+				},
+				TerraformVersion: "1.5.7",
+			},
+			o: &MigratorOption{
+				TerraformVersionPaths: map[string]string{
+					"1.5.7": "/opt/terraform/1.5.7/terraform",
+				},
+			},
+			ok: true,
+		},
+		{
+			desc: "same dir and different workspaces",
+			config: &MultiStateMigratorConfig{
+				FromDir:       "dir1",
+				ToDir:         "./dir1",
+				FromWorkspace: "staging",
+				ToWorkspace:   "prod",
+				Actions: []string{
+					"mv null_resource.foo null_resource.foo",
+				},
+			},
+			o:  nil,
+			ok: true,
+		},
+		{
+			desc: "same dir and same workspace",
+			config: &MultiStateMigratorConfig{
+				FromDir: "dir1",
+				ToDir:   "dir1",
+				Actions: []string{
+					"mv null_resource.foo null_resource.foo",
+				},
+			},
+			o:  nil,
+			ok: false,
+		},
+		{
+			desc: "with from_env and to_env",
+			config: &MultiStateMigratorConfig{
+				FromDir: "dir1",
+				ToDir:   "dir2",
+				Actions: []string{
+					"mv null_resource.foo null_resource.foo2",
+				},
+				FromEnv: map[string]string{"AWS_PROFILE": "account1"},
+				ToEnv:   map[string]string{"AWS_PROFILE": "account2"},
+			},
+			o:  nil,
+			ok: true,
+		},
+		{
+			desc: "with from_env in the same dir",
+			config: &MultiStateMigratorConfig{
+				FromDir:     "dir1",
+				ToDir:       "dir1",
+				ToWorkspace: "work1",
+				Actions: []string{
+					"mv null_resource.foo null_resource.foo",
+				},
+				FromEnv: map[string]string{"AWS_PROFILE": "account1"},
+			},
+			o:  nil,
+			ok: false,
+		},
+		{
+			desc: "with terraform_version not installed",
+			config: &MultiStateMigratorConfig{
+				FromDir: "dir1",
+				ToDir:   "dir2",
+				Actions: []string{
+					"mv null_resource.foo null_resource.foo2",
+				},
+				TerraformVersion: "0.0.0-not-installed",
+			},
+			o:  nil,
+			ok: false,
+		},
 	}
 
 	for _, tc := range cases {
@@ -161,6 +326,41 @@ func TestMultiStateMigratorConfigNewMigrator(t *testing.T) {
 	}
 }
 
+func TestMultiStateMigratorConfigOptionForDir(t *testing.T) {
+	config := &MultiStateMigratorConfig{
+		FromDir: "legacy/foo",
+		ToDir:   "modern/bar",
+	}
+	o := &MigratorOption{
+		ExecPathResolver: &tfexec.ExecPathResolver{
+			Rules: []tfexec.ExecPathRule{
+				{Pattern: "legacy/*", ExecPath: "/opt/terraform/0.12/terraform"},
+			},
+			DefaultExecPath: "terraform",
+		},
+	}
+
+	cases := []struct {
+		dir  string
+		want string
+	}{
+		{dir: config.FromDir, want: "/opt/terraform/0.12/terraform"},
+		{dir: config.ToDir, want: "terraform"},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.dir, func(t *testing.T) {
+			got, err := config.optionForDir(o, tc.dir)
+			if err != nil {
+				t.Fatalf("unexpected err: %s", err)
+			}
+			if got.ExecPath != tc.want {
+				t.Errorf("got: %s, want: %s", got.ExecPath, tc.want)
+			}
+		})
+	}
+}
+
 func TestAccMultiStateMigratorApplySimple(t *testing.T) {
 	tfexec.SkipUnlessAcceptanceTestEnabled(t)
 	ctx := context.Background()
@@ -216,7 +416,8 @@ resource "null_resource" "qux" {}
 		NewMultiStateMvAction("null_resource.foo", "null_resource.foo"),
 		NewMultiStateMvAction("null_resource.bar", "null_resource.bar2"),
 	}
-	o := &MigratorOption{}
+	tmpDir := t.TempDir()
+	o := &MigratorOption{TmpDir: tmpDir}
 	force := false
 	m := NewMultiStateMigrator(fromTf.Dir(), toTf.Dir(), fromWorkspace, toWorkspace, actions, o, force, false, false)
 	err = m.Plan(ctx)
@@ -229,6 +430,15 @@ resource "null_resource" "qux" {}
 		t.Fatalf("failed to run migrator apply: %s", err)
 	}
 
+	// The backups of the original states should be removed on success.
+	backups, err := filepath.Glob(filepath.Join(tmpDir, "tfmigrate-backup-*"))
+	if err != nil {
+		t.Fatalf("failed to glob backups: %s", err)
+	}
+	if len(backups) != 0 {
+		t.Errorf("expected the backups to be removed, but got: %v", backups)
+	}
+
 	// verify state migration results
 	fromGot, err := fromTf.StateList(ctx, nil, nil)
 	if err != nil {
@@ -723,6 +933,161 @@ resource "null_resource" "qux" {}
 	}
 }
 
+func TestAccMultiStateMigratorApplyWithSameDir(t *testing.T) {
+	tfexec.SkipUnlessAcceptanceTestEnabled(t)
+	ctx := context.Background()
+
+	// setup the initial files and states in the same dir with two workspaces.
+	backend := tfexec.GetTestAccBackendS3Config(t.Name())
+	source := `
+resource "null_resource" "foo" { count = terraform.workspace == "staging" ? 1 : 0 }
+resource "null_resource" "qux" { count = terraform.workspace == "prod" ? 1 : 0 }
+`
+	fromWorkspace := "staging"
+	tf := tfexec.SetupTestAccWithApply(t, fromWorkspace, backend+source)
+
+	toWorkspace := "prod"
+	err := tf.WorkspaceNew(ctx, toWorkspace)
+	if err != nil {
+		t.Fatalf("failed to run terraform workspace new %s: %s", toWorkspace, err)
+	}
+	err = tf.Apply(ctx, nil, "-input=false", "-no-color", "-auto-approve")
+	if err != nil {
+		t.Fatalf("failed to run terraform apply: %s", err)
+	}
+	t.Cleanup(func() {
+		if err := tf.WorkspaceSelect(ctx, toWorkspace); err != nil {
+			t.Fatalf("failed to run terraform workspace select %s: %s", toWorkspace, err)
+		}
+		if err := tf.Destroy(ctx, "-input=false", "-no-color", "-auto-approve"); err != nil {
+			t.Fatalf("failed to run terraform destroy: %s", err)
+		}
+		if err := tf.WorkspaceSelect(ctx, fromWorkspace); err != nil {
+			t.Fatalf("failed to run terraform workspace select %s: %s", fromWorkspace, err)
+		}
+	})
+
+	// update terraform resource files for migration
+	updatedSource := `
+resource "null_resource" "foo" { count = terraform.workspace == "prod" ? 1 : 0 }
+resource "null_resource" "qux" { count = terraform.workspace == "prod" ? 1 : 0 }
+`
+	tfexec.UpdateTestAccSource(t, tf, backend+updatedSource)
+
+	// perform state migration
+	actions := []MultiStateAction{
+		NewMultiStateMvAction("null_resource.foo[0]", "null_resource.foo[0]"),
+	}
+	o := &MigratorOption{}
+	force := false
+	m := NewMultiStateMigrator(tf.Dir(), tf.Dir(), fromWorkspace, toWorkspace, actions, o, force, false, false)
+	err = m.Plan(ctx)
+	if err != nil {
+		t.Fatalf("failed to run migrator plan: %s", err)
+	}
+
+	err = m.Apply(ctx)
+	if err != nil {
+		t.Fatalf("failed to run migrator apply: %s", err)
+	}
+
+	// verify state migration results
+	cases := []struct {
+		workspace string
+		want      []string
+	}{
+		{
+			workspace: fromWorkspace,
+			want:      []string{},
+		},
+		{
+			workspace: toWorkspace,
+			want:      []string{"null_resource.foo[0]", "null_resource.qux[0]"},
+		},
+	}
+	for _, tc := range cases {
+		err = tf.WorkspaceSelect(ctx, tc.workspace)
+		if err != nil {
+			t.Fatalf("failed to run terraform workspace select %s: %s", tc.workspace, err)
+		}
+		got, err := tf.StateList(ctx, nil, nil)
+		if err != nil {
+			t.Fatalf("failed to run terraform state list in %s: %s", tc.workspace, err)
+		}
+		sort.Strings(got)
+		if len(got) != 0 || len(tc.want) != 0 {
+			if !reflect.DeepEqual(got, tc.want) {
+				t.Errorf("got state: %v, want state: %v in %s", got, tc.want, tc.workspace)
+			}
+		}
+
+		changed, err := tf.PlanHasChange(ctx, nil)
+		if err != nil {
+			t.Fatalf("failed to run PlanHasChange in %s: %s", tc.workspace, err)
+		}
+		if changed {
+			t.Errorf("expect not to have changes in %s", tc.workspace)
+		}
+	}
+}
+
+func TestAccMultiStateMigratorPlanWithDiffsInBothDirs(t *testing.T) {
+	tfexec.SkipUnlessAcceptanceTestEnabled(t)
+	ctx := context.Background()
+
+	// setup the initial files and states
+	fromBackend := tfexec.GetTestAccBackendS3Config(t.Name() + "/fromDir")
+	fromSource := `
+resource "null_resource" "foo" {}
+resource "null_resource" "baz" {}
+`
+	fromWorkspace := "default"
+	fromTf := tfexec.SetupTestAccWithApply(t, fromWorkspace, fromBackend+fromSource)
+
+	toBackend := tfexec.GetTestAccBackendS3Config(t.Name() + "/toDir")
+	toSource := `
+resource "null_resource" "qux" {}
+`
+	toWorkspace := "default"
+	toTf := tfexec.SetupTestAccWithApply(t, toWorkspace, toBackend+toSource)
+
+	// update terraform resource files for migration
+	// Note that null_resource.baz2 and null_resource.qux2 will be added
+	fromUpdatedSource := `
+resource "null_resource" "baz" {}
+resource "null_resource" "baz2" {}
+`
+	tfexec.UpdateTestAccSource(t, fromTf, fromBackend+fromUpdatedSource)
+
+	toUpdatedSource := `
+resource "null_resource" "foo" {}
+resource "null_resource" "qux" {}
+resource "null_resource" "qux2" {}
+`
+	tfexec.UpdateTestAccSource(t, toTf, toBackend+toUpdatedSource)
+
+	actions := []MultiStateAction{
+		NewMultiStateMvAction("null_resource.foo", "null_resource.foo"),
+	}
+	o := &MigratorOption{}
+	force := false
+	m := NewMultiStateMigrator(fromTf.Dir(), toTf.Dir(), fromWorkspace, toWorkspace, actions, o, force, false, false)
+	err := m.Plan(ctx)
+	if err == nil {
+		t.Fatal("expected to return an error, but no error")
+	}
+
+	// both dirs should be reported at once.
+	for _, want := range []string{
+		"unexpected diffs in " + fromTf.Dir() + " from_dir",
+		"unexpected diffs in " + toTf.Dir() + " to_dir",
+	} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("expected an error to contain %q, but got: %s", want, err)
+		}
+	}
+}
+
 func TestAccMultiStateMigratorApplyWithForce(t *testing.T) {
 	tfexec.SkipUnlessAcceptanceTestEnabled(t)
 	ctx := context.Background()
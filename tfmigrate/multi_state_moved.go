@@ -0,0 +1,74 @@
+package tfmigrate
+
+import (
+	"log"
+	"sort"
+
+	"github.com/minamijoyo/tfmigrate/tfexec"
+)
+
+// stateInstanceAddresses returns a sorted list of resource instance addresses
+// in a given state. It returns nil if the state cannot be compared, such as
+// an encrypted state.
+func stateInstanceAddresses(state *tfexec.State) ([]string, error) {
+	if state.Size() != 0 && state.IsEncrypted() {
+		return nil, nil
+	}
+	addrs := []string{}
+	_, err := walkStateResources(state, func(r stateResource) error {
+		addrs = append(addrs, r.instanceAddresses()...)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(addrs)
+	return addrs, nil
+}
+
+// stateAddressDiff returns addresses added and removed between given states.
+// Both are nil if either of the states cannot be compared.
+func stateAddressDiff(before *tfexec.State, after *tfexec.State) (added []string, removed []string, err error) {
+	beforeAddrs, err := stateInstanceAddresses(before)
+	if err != nil {
+		return nil, nil, err
+	}
+	afterAddrs, err := stateInstanceAddresses(after)
+	if err != nil {
+		return nil, nil, err
+	}
+	if beforeAddrs == nil || afterAddrs == nil {
+		return nil, nil, nil
+	}
+	return subtractAddresses(afterAddrs, beforeAddrs), subtractAddresses(beforeAddrs, afterAddrs), nil
+}
+
+// logMovedResources logs resources removed from the source state and added to
+// the destination state by the actions, so that reviewers can see exactly
+// which addresses leave the source state, which is not obvious in the plan.
+// Since they are compared with the pulled states, wildcards of xmv are
+// already expanded. A failure of comparison is logged and ignored, because
+// the enumeration is just informational.
+func (m *MultiStateMigrator) logMovedResources(fromOriginalState *tfexec.State, fromCurrentState *tfexec.State, toOriginalState *tfexec.State, toCurrentState *tfexec.State) {
+	_, removed, err := stateAddressDiff(fromOriginalState, fromCurrentState)
+	logAddresses(m.fromTf.Dir(), "removed from the source state", removed, err)
+	added, _, err := stateAddressDiff(toOriginalState, toCurrentState)
+	logAddresses(m.toTf.Dir(), "added to the destination state", added, err)
+}
+
+// logAddresses logs a given list of addresses with a description.
+// A nil list means that the addresses are unknown.
+func logAddresses(dir string, desc string, addrs []string, err error) {
+	if err != nil {
+		log.Printf("[WARN] [migrator@%s] failed to list resources %s: %s\n", dir, desc, err)
+		return
+	}
+	if addrs == nil {
+		log.Printf("[INFO] [migrator@%s] unable to list resources %s, because the state cannot be parsed\n", dir, desc)
+		return
+	}
+	log.Printf("[INFO] [migrator@%s] %d resource(s) to be %s\n", dir, len(addrs), desc)
+	for _, addr := range addrs {
+		log.Printf("[INFO] [migrator@%s]   %s\n", dir, addr)
+	}
+}
@@ -0,0 +1,77 @@
+package tfmigrate
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/minamijoyo/tfmigrate/tfexec"
+)
+
+func TestStateAddressDiff(t *testing.T) {
+	cases := []struct {
+		desc        string
+		before      string
+		after       string
+		wantAdded   []string
+		wantRemoved []string
+		ok          bool
+	}{
+		{
+			desc: "removed from source",
+			before: `{"version": 4, "resources": [
+  {"mode": "managed", "type": "null_resource", "name": "foo", "instances": [{"index_key": "a", "attributes": {"id": "1"}}, {"index_key": "b", "attributes": {"id": "2"}}]},
+  {"mode": "managed", "type": "null_resource", "name": "bar", "instances": [{"attributes": {"id": "3"}}]}
+]}`,
+			after: `{"version": 4, "resources": [
+  {"mode": "managed", "type": "null_resource", "name": "bar", "instances": [{"attributes": {"id": "3"}}]}
+]}`,
+			wantAdded:   []string{},
+			wantRemoved: []string{`null_resource.foo["a"]`, `null_resource.foo["b"]`},
+			ok:          true,
+		},
+		{
+			desc:   "added to destination",
+			before: `{"version": 4, "resources": []}`,
+			after: `{"version": 4, "resources": [
+  {"module": "module.baz", "mode": "managed", "type": "null_resource", "name": "foo", "instances": [{"attributes": {"id": "1"}}]}
+]}`,
+			wantAdded:   []string{"module.baz.null_resource.foo"},
+			wantRemoved: []string{},
+			ok:          true,
+		},
+		{
+			desc:        "encrypted",
+			before:      `{"serial": 1, "lineage": "foo", "meta": {"key_provider.pbkdf2.mykey": "eyJ="}, "encrypted_data": "ZXhhbXBsZQ==", "encryption_version": "v0"}`,
+			after:       `{"version": 4, "resources": []}`,
+			wantAdded:   nil,
+			wantRemoved: nil,
+			ok:          true,
+		},
+		{
+			desc:   "invalid",
+			before: `{"version": 4, "resources": {}}`,
+			after:  `{"version": 4, "resources": []}`,
+			ok:     false,
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.desc, func(t *testing.T) {
+			added, removed, err := stateAddressDiff(tfexec.NewState([]byte(tc.before)), tfexec.NewState([]byte(tc.after)))
+			if tc.ok && err != nil {
+				t.Fatalf("unexpected err: %s", err)
+			}
+			if !tc.ok && err == nil {
+				t.Fatalf("expected to return an error, but no error, added: %#v, removed: %#v", added, removed)
+			}
+			if tc.ok {
+				if diff := cmp.Diff(added, tc.wantAdded); diff != "" {
+					t.Errorf("got added: %#v, want: %#v, diff: %s", added, tc.wantAdded, diff)
+				}
+				if diff := cmp.Diff(removed, tc.wantRemoved); diff != "" {
+					t.Errorf("got removed: %#v, want: %#v, diff: %s", removed, tc.wantRemoved, diff)
+				}
+			}
+		})
+	}
+}
@@ -14,6 +14,28 @@ type MultiStateXmvAction struct {
 	source string
 	// destination is a new address of resource or module to move which can contain placeholders.
 	destination string
+	// caseInsensitive matches the source against the state case-insensitively.
+	caseInsensitive bool
+	// excludes is a list of patterns of sources to be skipped.
+	// They have the same wildcard grammar as the source.
+	excludes []string
+	// includeData allows wildcards to match data sources even if the source
+	// doesn't explicitly target them.
+	includeData bool
+	// expectMatches is an expected number of matched sources.
+	// If set, it fails before any move if the number differs. It's nil if not set.
+	expectMatches *int
+	// withinModule is a module address which matched sources must be directly
+	// in. It prevents wildcards from matching resources in the other modules
+	// or its child modules. It's empty if not set.
+	withinModule string
+	// regex interprets the source and excludes as regular expressions, whose
+	// named capture groups can be referenced in the destination.
+	regex bool
+	// singleSegment makes a single wildcard in the source and excludes match
+	// only one segment of the resource path. A deep wildcard is still
+	// available to match across dots.
+	singleSegment bool
 }
 
 var _ MultiStateAction = (*MultiStateXmvAction)(nil)
@@ -46,18 +68,26 @@ func (a *MultiStateXmvAction) MultiStateUpdate(ctx context.Context, fromTf tfexe
 
 // generateMvActions uses an xmv and use the state to determine the corresponding mv actions.
 func (a *MultiStateXmvAction) generateMvActions(ctx context.Context, fromTf tfexec.TerraformCLI, fromState *tfexec.State) ([]*MultiStateMvAction, error) {
-	stateList, err := fromTf.StateList(ctx, fromState, nil)
-	if err != nil {
-		return nil, err
-	}
-
 	// create a temporary single state mv actions.
 	// It may look a bit strange as a type.
 	// This is only because sharing the logic while maintaining consistency.
-	stateXmv := NewStateXmvAction(a.source, a.destination)
+	stateXmv := a.toStateXmvAction()
 
 	e := newXmvExpander(stateXmv)
-	stateMvActions, err := e.expand(stateList)
+	stateList, err := fromTf.StateList(ctx, fromState, e.stateListAddresses())
+	if err != nil {
+		return nil, err
+	}
+
+	return a.expandMvActions(stateList)
+}
+
+// expandMvActions expands the xmv against a given list of addresses in the
+// fromState and returns the corresponding mv actions.
+// The destination of each action can differ from the source, so that the
+// resource is renamed during the move across states.
+func (a *MultiStateXmvAction) expandMvActions(stateList []string) ([]*MultiStateMvAction, error) {
+	stateMvActions, err := newXmvExpander(a.toStateXmvAction()).expand(stateList)
 	if err != nil {
 		return nil, err
 	}
@@ -70,3 +100,17 @@ func (a *MultiStateXmvAction) generateMvActions(ctx context.Context, fromTf tfex
 
 	return multiStateMvActions, nil
 }
+
+// toStateXmvAction returns a single state xmv action with the same source,
+// destination and flags for sharing the logic of wildcard expansion.
+func (a *MultiStateXmvAction) toStateXmvAction() *StateXmvAction {
+	stateXmv := NewStateXmvAction(a.source, a.destination)
+	stateXmv.caseInsensitive = a.caseInsensitive
+	stateXmv.excludes = a.excludes
+	stateXmv.includeData = a.includeData
+	stateXmv.expectMatches = a.expectMatches
+	stateXmv.withinModule = a.withinModule
+	stateXmv.regex = a.regex
+	stateXmv.singleSegment = a.singleSegment
+	return stateXmv
+}
@@ -2,6 +2,8 @@ package tfmigrate
 
 import (
 	"context"
+	"reflect"
+	"sort"
 	"testing"
 
 	"github.com/minamijoyo/tfmigrate/tfexec"
@@ -73,4 +75,97 @@ resource "null_resource" "qux" {}
 	if err != nil {
 		t.Fatalf("failed to run migrator plan: %s", err)
 	}
+
+	fromGot, err := fromTf.StateList(ctx, nil, nil)
+	if err != nil {
+		t.Fatalf("failed to run terraform state list in fromDir: %s", err)
+	}
+	fromWant := []string{
+		"time_static.foo",
+	}
+	sort.Strings(fromGot)
+	sort.Strings(fromWant)
+	if !reflect.DeepEqual(fromGot, fromWant) {
+		t.Errorf("got state: %v, want state: %v in fromDir", fromGot, fromWant)
+	}
+
+	toGot, err := toTf.StateList(ctx, nil, nil)
+	if err != nil {
+		t.Fatalf("failed to run terraform state list in toDir: %s", err)
+	}
+	toWant := []string{
+		"null_resource.foo2",
+		"null_resource.bar2",
+		"null_resource.qux",
+	}
+	sort.Strings(toGot)
+	sort.Strings(toWant)
+	if !reflect.DeepEqual(toGot, toWant) {
+		t.Errorf("got state: %v, want state: %v in toDir", toGot, toWant)
+	}
+}
+
+func TestMultiStateXmvActionExpandMvActions(t *testing.T) {
+	stateList := []string{
+		"aws_instance.old",
+		"aws_instance.web",
+		"aws_security_group.foo",
+		"module.a.aws_instance.bar",
+	}
+	cases := []struct {
+		desc   string
+		action *MultiStateXmvAction
+		want   []*MultiStateMvAction
+	}{
+		{
+			desc:   "same address",
+			action: NewMultiStateXmvAction("aws_instance.*", "aws_instance.$1"),
+			want: []*MultiStateMvAction{
+				NewMultiStateMvAction("aws_instance.old", "aws_instance.old"),
+				NewMultiStateMvAction("aws_instance.web", "aws_instance.web"),
+			},
+		},
+		{
+			desc:   "rename",
+			action: NewMultiStateXmvAction("aws_instance.*", "aws_instance.${1}_new"),
+			want: []*MultiStateMvAction{
+				NewMultiStateMvAction("aws_instance.old", "aws_instance.old_new"),
+				NewMultiStateMvAction("aws_instance.web", "aws_instance.web_new"),
+			},
+		},
+		{
+			desc:   "rename into a module",
+			action: NewMultiStateXmvAction("aws_instance.*", "module.app.aws_instance.$1"),
+			want: []*MultiStateMvAction{
+				NewMultiStateMvAction("aws_instance.old", "module.app.aws_instance.old"),
+				NewMultiStateMvAction("aws_instance.web", "module.app.aws_instance.web"),
+			},
+		},
+		{
+			desc:   "rename out of a module",
+			action: NewMultiStateXmvAction("module.**.aws_instance.*", "aws_instance.${1}_$2"),
+			want: []*MultiStateMvAction{
+				NewMultiStateMvAction("module.a.aws_instance.bar", "aws_instance.a_bar"),
+			},
+		},
+		{
+			desc:   "without wildcards",
+			action: NewMultiStateXmvAction("aws_instance.old", "aws_instance.new"),
+			want: []*MultiStateMvAction{
+				NewMultiStateMvAction("aws_instance.old", "aws_instance.new"),
+			},
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.desc, func(t *testing.T) {
+			got, err := tc.action.expandMvActions(stateList)
+			if err != nil {
+				t.Fatalf("unexpected err: %s", err)
+			}
+			if !reflect.DeepEqual(got, tc.want) {
+				t.Errorf("got: %#v, want: %#v", got, tc.want)
+			}
+		})
+	}
 }
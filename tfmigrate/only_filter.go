@@ -0,0 +1,41 @@
+package tfmigrate
+
+import (
+	"regexp"
+
+	"github.com/minamijoyo/tfmigrate/tfexec"
+)
+
+// compileOnly compiles address patterns of the Only in MigratorOption.
+// The patterns have the same wildcard grammar as the source of xmv.
+func compileOnly(patterns []string) ([]*regexp.Regexp, error) {
+	return compilePlanAllowChanges(patterns)
+}
+
+// selectedByOnly returns true if any of addresses which a given action
+// changes in a given state matches any of given patterns. It also returns the
+// addresses of the action. An action which doesn't know addresses it changes,
+// such as replace-provider and raw actions, is never selected.
+func selectedByOnly(action StateAction, state *tfexec.State, patterns []*regexp.Regexp) (bool, []string, error) {
+	s, ok := action.(stateScoper)
+	if !ok {
+		return false, nil, nil
+	}
+	scope, err := s.stateScope(state)
+	if err != nil {
+		return false, nil, err
+	}
+	return len(disallowedAddresses(scope, patterns)) < len(scope), scope, nil
+}
+
+// excludeScope returns addresses which are not in a given scope.
+// The order of addresses is preserved.
+func excludeScope(addrs []string, scope []string) []string {
+	ret := []string{}
+	for _, addr := range addrs {
+		if !inStateScope(addr, scope) {
+			ret = append(ret, addr)
+		}
+	}
+	return ret
+}
@@ -0,0 +1,88 @@
+package tfmigrate
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/minamijoyo/tfmigrate/tfexec"
+)
+
+func TestSelectedByOnly(t *testing.T) {
+	cases := []struct {
+		desc      string
+		action    StateAction
+		patterns  []string
+		want      bool
+		wantScope []string
+	}{
+		{
+			desc:      "mv source matched",
+			action:    NewStateMvAction("null_resource.foo", "null_resource.foo2"),
+			patterns:  []string{"null_resource.foo"},
+			want:      true,
+			wantScope: []string{"null_resource.foo", "null_resource.foo2"},
+		},
+		{
+			desc:      "mv destination matched by wildcard",
+			action:    NewStateMvAction("null_resource.foo", "module.qux.null_resource.foo"),
+			patterns:  []string{"module.qux.*"},
+			want:      true,
+			wantScope: []string{"null_resource.foo", "module.qux.null_resource.foo"},
+		},
+		{
+			desc:      "rm not matched",
+			action:    NewStateRmAction([]string{"null_resource.bar"}),
+			patterns:  []string{"null_resource.foo"},
+			want:      false,
+			wantScope: []string{"null_resource.bar"},
+		},
+		{
+			desc:      "xmv expanded and matched",
+			action:    NewStateXmvAction("null_resource.*", "module.new.null_resource.$1"),
+			patterns:  []string{"module.new.null_resource.bar[1]"},
+			want:      true,
+			wantScope: []string{"null_resource.foo", "null_resource.bar[0]", "null_resource.bar[1]", "module.new.null_resource.foo", "module.new.null_resource.bar[0]", "module.new.null_resource.bar[1]"},
+		},
+		{
+			desc:      "raw is never selected",
+			action:    NewStateRawAction([]string{"replace-provider"}, []string{"hashicorp/null", "example.com/null"}),
+			patterns:  []string{"*"},
+			want:      false,
+			wantScope: nil,
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.desc, func(t *testing.T) {
+			patterns, err := compileOnly(tc.patterns)
+			if err != nil {
+				t.Fatalf("failed to compile patterns: %s", err)
+			}
+			got, scope, err := selectedByOnly(tc.action, tfexec.NewState([]byte(integrityTestState)), patterns)
+			if err != nil {
+				t.Fatalf("unexpected err: %s", err)
+			}
+			if got != tc.want {
+				t.Errorf("got: %t, want: %t", got, tc.want)
+			}
+			if diff := cmp.Diff(scope, tc.wantScope); diff != "" {
+				t.Errorf("got scope: %v, want: %v, diff: %s", scope, tc.wantScope, diff)
+			}
+		})
+	}
+}
+
+func TestExcludeScope(t *testing.T) {
+	addrs := []string{
+		"null_resource.foo",
+		"null_resource.bar[0]",
+		`module.baz["a"].null_resource.qux`,
+		"null_resource.qux",
+	}
+	scope := []string{"null_resource.bar", "module.baz"}
+	got := excludeScope(addrs, scope)
+	want := []string{"null_resource.foo", "null_resource.qux"}
+	if diff := cmp.Diff(got, want); diff != "" {
+		t.Errorf("got: %v, want: %v, diff: %s", got, want, diff)
+	}
+}
@@ -0,0 +1,64 @@
+package tfmigrate
+
+import (
+	"context"
+	"regexp"
+
+	"github.com/minamijoyo/tfmigrate/tfexec"
+)
+
+// compilePlanAllowChanges compiles a list of address patterns of changes
+// allowed in plan. The patterns have the same wildcard grammar as the source
+// of xmv action.
+func compilePlanAllowChanges(patterns []string) ([]*regexp.Regexp, error) {
+	res := make([]*regexp.Regexp, 0, len(patterns))
+	for _, p := range patterns {
+		re, err := makeSrcRegex(p, false, false)
+		if err != nil {
+			return nil, err
+		}
+		res = append(res, re)
+	}
+	return res, nil
+}
+
+// disallowedAddresses returns addresses which don't match any of given
+// patterns. The order of addresses is preserved.
+func disallowedAddresses(addrs []string, patterns []*regexp.Regexp) []string {
+	ret := []string{}
+	for _, addr := range addrs {
+		allowed := false
+		for _, re := range patterns {
+			if re.MatchString(addr) {
+				allowed = true
+				break
+			}
+		}
+		if !allowed {
+			ret = append(ret, addr)
+		}
+	}
+	return ret
+}
+
+// disallowedPlanChanges returns changed addresses in a given plan which don't
+// match any of given patterns. It reads the plan in JSON via terraform show.
+// Changes of root module outputs are included, because they make terraform
+// plan return diffs as well.
+func disallowedPlanChanges(ctx context.Context, tf tfexec.TerraformCLI, plan *tfexec.Plan, patterns []*regexp.Regexp) ([]string, error) {
+	out, err := tf.Show(ctx, plan, "-json", "-no-color")
+	if err != nil {
+		return nil, err
+	}
+	planJSON, err := tfexec.ParsePlanJSON([]byte(out))
+	if err != nil {
+		return nil, err
+	}
+	return disallowedAddresses(planChanges(planJSON), patterns), nil
+}
+
+// planChanges returns changed addresses of resources and root module outputs
+// in a given plan. The outputs are in the form of `output.<name>`.
+func planChanges(planJSON *tfexec.PlanJSON) []string {
+	return append(planJSON.ChangedAddresses(), planJSON.ChangedOutputs()...)
+}
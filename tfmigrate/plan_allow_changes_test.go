@@ -0,0 +1,84 @@
+package tfmigrate
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/minamijoyo/tfmigrate/tfexec"
+)
+
+func TestDisallowedAddresses(t *testing.T) {
+	cases := []struct {
+		desc     string
+		addrs    []string
+		patterns []string
+		want     []string
+	}{
+		{
+			desc:     "no patterns",
+			addrs:    []string{"null_resource.foo", "time_static.bar"},
+			patterns: []string{},
+			want:     []string{"null_resource.foo", "time_static.bar"},
+		},
+		{
+			desc:     "all allowed",
+			addrs:    []string{"time_static.foo", "module.a.module.b.time_static.bar"},
+			patterns: []string{"time_static.*", "module.**.time_static.*"},
+			want:     []string{},
+		},
+		{
+			desc:     "partially allowed",
+			addrs:    []string{"null_resource.foo", "time_static.bar"},
+			patterns: []string{"time_static.*"},
+			want:     []string{"null_resource.foo"},
+		},
+		{
+			desc:     "match whole address",
+			addrs:    []string{"module.foo.time_static.bar"},
+			patterns: []string{"time_static.*"},
+			want:     []string{"module.foo.time_static.bar"},
+		},
+		{
+			desc:     "exact address",
+			addrs:    []string{"time_static.foo[0]", "time_static.foo[1]"},
+			patterns: []string{"time_static.foo[0]"},
+			want:     []string{"time_static.foo[1]"},
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.desc, func(t *testing.T) {
+			patterns, err := compilePlanAllowChanges(tc.patterns)
+			if err != nil {
+				t.Fatalf("unexpected err: %s", err)
+			}
+			got := disallowedAddresses(tc.addrs, patterns)
+			if diff := cmp.Diff(got, tc.want); diff != "" {
+				t.Errorf("got: %v, want: %v, diff: %s", got, tc.want, diff)
+			}
+		})
+	}
+}
+
+func TestPlanChanges(t *testing.T) {
+	planJSON, err := tfexec.ParsePlanJSON([]byte(`{
+  "format_version": "1.2",
+  "resource_changes": [
+    {"address": "null_resource.foo", "change": {"actions": ["no-op"]}},
+    {"address": "null_resource.bar", "change": {"actions": ["create"]}}
+  ],
+  "output_changes": {
+    "foo": {"actions": ["no-op"]},
+    "bar": {"actions": ["update"]}
+  }
+}`))
+	if err != nil {
+		t.Fatalf("failed to parse plan json: %s", err)
+	}
+
+	got := planChanges(planJSON)
+	want := []string{"null_resource.bar", "output.bar"}
+	if diff := cmp.Diff(got, want); diff != "" {
+		t.Errorf("got: %#v, want: %#v, diff: %s", got, want, diff)
+	}
+}
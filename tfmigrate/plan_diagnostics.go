@@ -0,0 +1,134 @@
+package tfmigrate
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+
+	"github.com/minamijoyo/tfmigrate/tfexec"
+)
+
+// PlanDiagnostics is a set of diagnostics reported by terraform plan in a
+// working directory.
+type PlanDiagnostics struct {
+	// Dir is a working directory where terraform plan was executed.
+	Dir string
+	// Diagnostics is a list of warnings and errors reported by terraform plan.
+	Diagnostics []tfexec.Diagnostic
+}
+
+// DiagnosticsCollector collects PlanDiagnostics across migrators.
+// It's intended to be shared via the MigratorOption and read by a runner
+// after each migration to report a summary.
+type DiagnosticsCollector struct {
+	mu      sync.Mutex
+	results []PlanDiagnostics
+}
+
+// NewDiagnosticsCollector returns a new DiagnosticsCollector instance.
+func NewDiagnosticsCollector() *DiagnosticsCollector {
+	return &DiagnosticsCollector{}
+}
+
+// Add appends a given PlanDiagnostics.
+func (c *DiagnosticsCollector) Add(d PlanDiagnostics) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.results = append(c.results, d)
+}
+
+// Results returns collected PlanDiagnostics.
+func (c *DiagnosticsCollector) Results() []PlanDiagnostics {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return append([]PlanDiagnostics{}, c.results...)
+}
+
+// Reset clears collected PlanDiagnostics.
+func (c *DiagnosticsCollector) Reset() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.results = nil
+}
+
+// runPlan is a common helper function to run terraform plan for verification.
+// If the DiagnosticsCollector in a given option is not nil, it captures
+// diagnostics of the plan and adds them to the collector regardless of
+// whether the plan succeeds or not.
+// If the WarningsAsErrors in a given option is true, it returns an error if
+// the plan reports any warnings not allowed by the AllowedWarnings.
+func runPlan(ctx context.Context, tf tfexec.TerraformCLI, state *tfexec.State, o *MigratorOption, opts ...string) (_ *tfexec.Plan, err error) {
+	ctx, span := startPhaseSpan(ctx, tf, "plan")
+	defer func() { EndSpan(span, err) }()
+
+	var c *DiagnosticsCollector
+	warningsAsErrors := false
+	if o != nil {
+		c = o.DiagnosticsCollector
+		warningsAsErrors = o.WarningsAsErrors
+	}
+	if c == nil && !warningsAsErrors {
+		return tf.Plan(ctx, state, opts...)
+	}
+
+	plan, diags, err := tf.PlanWithDiagnostics(ctx, state, opts...)
+	if c != nil {
+		c.Add(PlanDiagnostics{
+			Dir:         tf.Dir(),
+			Diagnostics: diags,
+		})
+	}
+	if !warningsAsErrors {
+		return plan, err
+	}
+
+	// Check warnings only if the plan has completed, that is, no error or
+	// diffs. Note that an error of warnings cannot be ignored by force.
+	if exitErr, ok := err.(tfexec.ExitError); err != nil && (!ok || exitErr.ExitCode() != 2) {
+		return plan, err
+	}
+	if werr := checkWarnings(tf.Dir(), diags, o.AllowedWarnings); werr != nil {
+		return plan, errors.Join(werr, err)
+	}
+	return plan, err
+}
+
+// checkWarnings returns an error if given diagnostics contain any warnings
+// whose summary is not in a given list of allowed summaries.
+func checkWarnings(dir string, diags []tfexec.Diagnostic, allowed []string) error {
+	warnings := unallowedWarnings(dir, diags, allowed)
+	if len(warnings) == 0 {
+		return nil
+	}
+	return fmt.Errorf("terraform plan reported %d warning(s) in %s, which are treated as errors by warnings_as_errors: %s", len(warnings), dir, strings.Join(warnings, ", "))
+}
+
+// unallowedWarnings returns warnings in given diagnostics whose summary is not
+// in a given list of allowed summaries. A warning is formatted as its summary
+// with the address if any.
+func unallowedWarnings(dir string, diags []tfexec.Diagnostic, allowed []string) []string {
+	allowedSet := make(map[string]bool, len(allowed))
+	for _, a := range allowed {
+		allowedSet[a] = true
+	}
+
+	warnings := []string{}
+	for _, d := range diags {
+		if d.Severity != "warning" {
+			continue
+		}
+		if allowedSet[d.Summary] {
+			log.Printf("[INFO] [migrator@%s] ignore a warning allowed by allowed_warnings: %s\n", dir, d.Summary)
+			continue
+		}
+		w := d.Summary
+		if len(d.Address) != 0 {
+			w += fmt.Sprintf(" (address=%s)", d.Address)
+		}
+		warnings = append(warnings, w)
+	}
+	return warnings
+}
@@ -0,0 +1,73 @@
+package tfmigrate
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/minamijoyo/tfmigrate/tfexec"
+)
+
+func TestCheckWarnings(t *testing.T) {
+	cases := []struct {
+		desc    string
+		diags   []tfexec.Diagnostic
+		allowed []string
+		want    string
+		ok      bool
+	}{
+		{
+			desc:  "no diagnostics",
+			diags: nil,
+			ok:    true,
+		},
+		{
+			desc: "errors only",
+			diags: []tfexec.Diagnostic{
+				{Severity: "error", Summary: "Invalid reference"},
+			},
+			ok: true,
+		},
+		{
+			desc: "warnings",
+			diags: []tfexec.Diagnostic{
+				{Severity: "warning", Summary: "Argument is deprecated", Address: "aws_s3_bucket.foo"},
+				{Severity: "warning", Summary: "Deprecated attribute"},
+			},
+			want: "terraform plan reported 2 warning(s) in dir1, which are treated as errors by warnings_as_errors: Argument is deprecated (address=aws_s3_bucket.foo), Deprecated attribute",
+			ok:   false,
+		},
+		{
+			desc: "all warnings allowed",
+			diags: []tfexec.Diagnostic{
+				{Severity: "warning", Summary: "Argument is deprecated"},
+			},
+			allowed: []string{"Argument is deprecated"},
+			ok:      true,
+		},
+		{
+			desc: "some warnings allowed",
+			diags: []tfexec.Diagnostic{
+				{Severity: "warning", Summary: "Argument is deprecated"},
+				{Severity: "warning", Summary: "Deprecated attribute"},
+			},
+			allowed: []string{"Argument is deprecated"},
+			want:    "terraform plan reported 1 warning(s) in dir1, which are treated as errors by warnings_as_errors: Deprecated attribute",
+			ok:      false,
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.desc, func(t *testing.T) {
+			err := checkWarnings("dir1", tc.diags, tc.allowed)
+			if tc.ok && err != nil {
+				t.Fatalf("unexpected err: %s", err)
+			}
+			if !tc.ok && err == nil {
+				t.Fatal("expected to return an error, but no error")
+			}
+			if !tc.ok && !strings.Contains(err.Error(), tc.want) {
+				t.Errorf("got: %s, want: %s", err, tc.want)
+			}
+		})
+	}
+}
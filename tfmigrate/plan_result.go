@@ -0,0 +1,102 @@
+package tfmigrate
+
+import (
+	"context"
+	"log"
+	"sync"
+
+	"github.com/minamijoyo/tfmigrate/tfexec"
+)
+
+// PlanResult is a summary of terraform plan in a working directory.
+type PlanResult struct {
+	// Dir is a working directory where terraform plan was executed.
+	Dir string
+	// ChangedAddresses is a list of resource addresses which have any change.
+	ChangedAddresses []string
+	// Hash is a normalized hash of the plan, which is stable across runs
+	// unless the plan changes. It's empty if failed to compute.
+	Hash string
+}
+
+// PlanResultCollector collects PlanResults across migrators.
+// It's intended to be shared via the MigratorOption and read by a runner
+// after each migration to report a summary.
+type PlanResultCollector struct {
+	mu      sync.Mutex
+	results []PlanResult
+}
+
+// NewPlanResultCollector returns a new PlanResultCollector instance.
+func NewPlanResultCollector() *PlanResultCollector {
+	return &PlanResultCollector{}
+}
+
+// Add appends a given PlanResult.
+func (c *PlanResultCollector) Add(r PlanResult) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.results = append(c.results, r)
+}
+
+// Results returns collected PlanResults.
+func (c *PlanResultCollector) Results() []PlanResult {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return append([]PlanResult{}, c.results...)
+}
+
+// Reset clears collected PlanResults.
+func (c *PlanResultCollector) Reset() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.results = nil
+}
+
+// collectPlanResult is a common helper function to add a summary of a given
+// plan to collectors. A nil collector is ignored, and it's a no-op if all
+// collectors are nil.
+// A failure of collecting is logged and ignored, because the summary is just
+// informational and should not change a result of migration.
+func collectPlanResult(ctx context.Context, tf tfexec.TerraformCLI, plan *tfexec.Plan, cs ...*PlanResultCollector) {
+	if !anyPlanResultCollector(cs) || plan == nil || len(plan.Bytes()) == 0 {
+		return
+	}
+
+	out, err := tf.Show(ctx, plan, "-json", "-no-color")
+	if err != nil {
+		log.Printf("[WARN] [migrator@%s] failed to collect a plan result: %s\n", tf.Dir(), err)
+		return
+	}
+	planJSON, err := tfexec.ParsePlanJSON([]byte(out))
+	if err != nil {
+		log.Printf("[WARN] [migrator@%s] failed to collect a plan result: %s\n", tf.Dir(), err)
+		return
+	}
+
+	hash, err := tfexec.NormalizedPlanHash([]byte(out))
+	if err != nil {
+		log.Printf("[WARN] [migrator@%s] failed to compute a plan hash: %s\n", tf.Dir(), err)
+	}
+
+	r := PlanResult{
+		Dir:              tf.Dir(),
+		ChangedAddresses: planJSON.ChangedAddresses(),
+		Hash:             hash,
+	}
+	for _, c := range cs {
+		if c != nil {
+			c.Add(r)
+		}
+	}
+}
+
+// anyPlanResultCollector returns true if any of given collectors is not nil.
+func anyPlanResultCollector(cs []*PlanResultCollector) bool {
+	for _, c := range cs {
+		if c != nil {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,26 @@
+package tfmigrate
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestPlanResultCollector(t *testing.T) {
+	c := NewPlanResultCollector()
+	c.Add(PlanResult{Dir: "dir1", ChangedAddresses: []string{"null_resource.foo"}})
+	c.Add(PlanResult{Dir: "dir2", ChangedAddresses: []string{}})
+
+	want := []PlanResult{
+		{Dir: "dir1", ChangedAddresses: []string{"null_resource.foo"}},
+		{Dir: "dir2", ChangedAddresses: []string{}},
+	}
+	if diff := cmp.Diff(c.Results(), want); diff != "" {
+		t.Errorf("got: %#v, want: %#v, diff: %s", c.Results(), want, diff)
+	}
+
+	c.Reset()
+	if got := c.Results(); len(got) != 0 {
+		t.Errorf("expected to be reset, but got: %#v", got)
+	}
+}
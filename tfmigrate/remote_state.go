@@ -0,0 +1,38 @@
+package tfmigrate
+
+import (
+	"context"
+
+	"github.com/minamijoyo/tfmigrate/tfexec"
+)
+
+// PullRemoteState initializes a given dir and returns the current remote
+// state of a given workspace. It's intended for reading outputs of another
+// state referenced in a migration file. Unlike setupWorkDir, it doesn't
+// override the backend because nothing is pushed.
+func PullRemoteState(ctx context.Context, dir string, workspace string, o *MigratorOption) (*tfexec.State, error) {
+	if o == nil {
+		o = &MigratorOption{}
+	}
+	o, err := withExecPathForDir(o, dir)
+	if err != nil {
+		return nil, err
+	}
+	tf := newTerraformCLI(o, dir)
+	return pullRemoteState(ctx, tf, workspace, o.initOptions(), o.SkipInit)
+}
+
+// pullRemoteState is a helper function to initialize the work dir and returns
+// the current remote state of a given workspace.
+func pullRemoteState(ctx context.Context, tf tfexec.TerraformCLI, workspace string, initOpts []string, skipInit bool) (*tfexec.State, error) {
+	execType, version, err := initWorkDir(ctx, tf, initOpts, false, skipInit)
+	if err != nil {
+		return nil, err
+	}
+
+	state, err := pullWorkspaceState(ctx, tf, workspace, execType, version)
+	if err != nil {
+		return nil, skipInitError(err, tf.Dir(), skipInit)
+	}
+	return state, nil
+}
@@ -0,0 +1,83 @@
+package tfmigrate
+
+import (
+	"fmt"
+)
+
+// validateResumableActions returns an error if a given list of actions
+// contains an action which cannot be resumed.
+// An xmv action with --expect-matches cannot be resumed, because the sources
+// which have already been moved no longer match, and so the number of
+// matches differs from the expected one on resume.
+func validateResumableActions(actions []StateAction) error {
+	for _, action := range actions {
+		if a, ok := action.(*StateXmvAction); ok && a.expectMatches != nil {
+			return fmt.Errorf("resumable is not supported for an xmv action with %s: xmv %s %s", expectMatchesFlagPrefix, a.source, a.destination)
+		}
+	}
+	return nil
+}
+
+// resumeAction returns an action which applies the rest of a given action
+// to a state with a given state list. It's used for resuming a partially
+// applied migration.
+// It returns nil if the action has already been applied, that is,
+//   - mv: the source is absent and the destination is present.
+//   - xmv without wildcards: the same as mv. An xmv with wildcards is returned
+//     as is, because it matches only the sources which have not been moved.
+//   - rm: all the addresses are absent. Otherwise, it removes only the present ones.
+//   - import: all the addresses are present. Otherwise, it imports only the absent ones.
+//
+// Other actions are returned as is, because we cannot tell whether they have
+// been applied or not.
+func resumeAction(action StateAction, stateList []string) StateAction {
+	switch a := action.(type) {
+	case *StateMvAction:
+		if a.appliedTo(stateList) {
+			return nil
+		}
+
+	case *StateXmvAction:
+		if !newXmvExpander(a).hasPattern() && NewStateMvAction(a.source, a.destination).appliedTo(stateList) {
+			return nil
+		}
+
+	case *StateRmAction:
+		addresses := []string{}
+		for _, address := range a.addresses {
+			if containsAddress(stateList, address) {
+				addresses = append(addresses, address)
+			}
+		}
+		switch len(addresses) {
+		case 0:
+			return nil
+		case len(a.addresses):
+			return a
+		default:
+			return NewStateRmAction(addresses)
+		}
+
+	case *StateImportAction:
+		if containsAddress(stateList, a.address) {
+			return nil
+		}
+
+	case *StateImportBatchAction:
+		entries := []StateImportEntry{}
+		for _, e := range a.entries {
+			if !containsAddress(stateList, e.Address) {
+				entries = append(entries, e)
+			}
+		}
+		switch len(entries) {
+		case 0:
+			return nil
+		case len(a.entries):
+			return a
+		default:
+			return NewStateImportBatchAction(entries)
+		}
+	}
+	return action
+}
@@ -0,0 +1,97 @@
+package tfmigrate
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestResumeAction(t *testing.T) {
+	stateList := []string{
+		"aws_instance.foo2",
+		"aws_instance.bar",
+		"module.app.aws_instance.baz",
+	}
+
+	cases := []struct {
+		desc   string
+		action StateAction
+		want   StateAction
+	}{
+		{
+			desc:   "mv applied",
+			action: NewStateMvAction("aws_instance.foo", "aws_instance.foo2"),
+			want:   nil,
+		},
+		{
+			desc:   "mv not applied",
+			action: NewStateMvAction("aws_instance.bar", "aws_instance.bar2"),
+			want:   NewStateMvAction("aws_instance.bar", "aws_instance.bar2"),
+		},
+		{
+			desc:   "xmv without wildcards applied",
+			action: NewStateXmvAction("aws_instance.foo", "aws_instance.foo2"),
+			want:   nil,
+		},
+		{
+			desc:   "xmv with wildcards",
+			action: NewStateXmvAction("aws_instance.*", "module.app.aws_instance.$1"),
+			want:   NewStateXmvAction("aws_instance.*", "module.app.aws_instance.$1"),
+		},
+		{
+			desc:   "rm applied",
+			action: NewStateRmAction([]string{"aws_instance.qux", "module.old"}),
+			want:   nil,
+		},
+		{
+			desc:   "rm partially applied",
+			action: NewStateRmAction([]string{"aws_instance.qux", "module.app"}),
+			want:   NewStateRmAction([]string{"module.app"}),
+		},
+		{
+			desc:   "rm not applied",
+			action: NewStateRmAction([]string{"aws_instance.bar"}),
+			want:   NewStateRmAction([]string{"aws_instance.bar"}),
+		},
+		{
+			desc:   "import applied",
+			action: NewStateImportAction("aws_instance.bar", "i-1234"),
+			want:   nil,
+		},
+		{
+			desc:   "import not applied",
+			action: NewStateImportAction("aws_instance.qux", "i-1234"),
+			want:   NewStateImportAction("aws_instance.qux", "i-1234"),
+		},
+		{
+			desc: "import-batch partially applied",
+			action: NewStateImportBatchAction([]StateImportEntry{
+				{Address: "aws_instance.bar", ID: "i-1"},
+				{Address: "aws_instance.qux", ID: "i-2"},
+			}),
+			want: NewStateImportBatchAction([]StateImportEntry{
+				{Address: "aws_instance.qux", ID: "i-2"},
+			}),
+		},
+		{
+			desc: "import-batch applied",
+			action: NewStateImportBatchAction([]StateImportEntry{
+				{Address: "aws_instance.bar", ID: "i-1"},
+			}),
+			want: nil,
+		},
+		{
+			desc:   "replace-provider",
+			action: NewStateReplaceProviderAction("registry.terraform.io/-/null", "registry.terraform.io/hashicorp/null"),
+			want:   NewStateReplaceProviderAction("registry.terraform.io/-/null", "registry.terraform.io/hashicorp/null"),
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.desc, func(t *testing.T) {
+			got := resumeAction(tc.action, stateList)
+			if !reflect.DeepEqual(got, tc.want) {
+				t.Errorf("got: %#v, want: %#v", got, tc.want)
+			}
+		})
+	}
+}
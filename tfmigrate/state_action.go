@@ -14,6 +14,27 @@ type StateAction interface {
 	StateUpdate(ctx context.Context, tf tfexec.TerraformCLI, state *tfexec.State) (*tfexec.State, error)
 }
 
+// ActionError is an error of a state action which failed in a migration.
+// It tells which action failed, so that a caller can report it, such as a
+// row of a CSV file in the import command. The message is the same as the
+// cause.
+type ActionError struct {
+	// Index is a 0-based index of the failed action in the migration.
+	Index int
+	// Err is a cause of the failure.
+	Err error
+}
+
+// Error returns a message of the cause.
+func (e *ActionError) Error() string {
+	return e.Err.Error()
+}
+
+// Unwrap returns the cause.
+func (e *ActionError) Unwrap() error {
+	return e.Err
+}
+
 // NewStateActionFromString is a factory method which returns a new StateAction
 // from a given string.
 // cmdStr is a plain text for state operation.
@@ -22,7 +43,10 @@ type StateAction interface {
 // "mv <source> <destination>"
 // "rm <addresses>...
 // "import <address> <id>"
-// "xmv <source> <destination>"
+// "import-batch <address> <id> [<address> <id>]..."
+// "ximport [--keys-file=<path>]... <address> <id> [<key>...]"
+// "xmv [--case-insensitive] [--include-data] [--exclude=<pattern>]... [--expect-matches=<n>] <source> <destination>"
+// "raw <subcommand> <args>..."
 func NewStateActionFromString(cmdStr string) (StateAction, error) {
 	args, err := splitStateAction(cmdStr)
 	if err != nil {
@@ -54,12 +78,22 @@ func NewStateActionFromString(cmdStr string) (StateAction, error) {
 		action = NewStateReplaceProviderAction(src, dst)
 
 	case "xmv":
-		if len(args) != 3 {
+		src, dst, flags, ok := parseXmvArgs(args[1:])
+		if !ok {
 			return nil, fmt.Errorf("state xmv action is invalid: %s", cmdStr)
 		}
-		src := args[1]
-		dst := args[2]
-		action = NewStateXmvAction(src, dst)
+		a := NewStateXmvAction(src, dst)
+		a.caseInsensitive = flags.caseInsensitive
+		a.excludes = flags.excludes
+		a.includeData = flags.includeData
+		a.expectMatches = flags.expectMatches
+		a.withinModule = flags.withinModule
+		a.regex = flags.regex
+		a.singleSegment = flags.singleSegment
+		if err := newXmvExpander(a).validateRegex(); err != nil {
+			return nil, fmt.Errorf("state xmv action is invalid: %s, err: %s", cmdStr, err)
+		}
+		action = a
 
 	case "rm":
 		if len(args) < 2 {
@@ -74,8 +108,45 @@ func NewStateActionFromString(cmdStr string) (StateAction, error) {
 		}
 		addr := args[1]
 		id := args[2]
+		if err := validateImportAddress(addr); err != nil {
+			return nil, fmt.Errorf("state import action is invalid: %s, err: %s", cmdStr, err)
+		}
+		if err := validateImportID(addr, id); err != nil {
+			return nil, fmt.Errorf("state import action is invalid: %s, err: %s", cmdStr, err)
+		}
 		action = NewStateImportAction(addr, id)
 
+	case "import-batch":
+		if len(args) < 3 || len(args)%2 != 1 {
+			return nil, fmt.Errorf("state import-batch action is invalid: %s", cmdStr)
+		}
+		entries := []StateImportEntry{}
+		for i := 1; i < len(args); i += 2 {
+			if err := validateImportAddress(args[i]); err != nil {
+				return nil, fmt.Errorf("state import-batch action is invalid: %s, err: %s", cmdStr, err)
+			}
+			if err := validateImportID(args[i], args[i+1]); err != nil {
+				return nil, fmt.Errorf("state import-batch action is invalid: %s, err: %s", cmdStr, err)
+			}
+			entries = append(entries, StateImportEntry{Address: args[i], ID: args[i+1]})
+		}
+		action = NewStateImportBatchAction(entries)
+
+	case "ximport":
+		// An ximport action is expanded to an import-batch action on parse.
+		entries, err := parseXimportArgs(args[1:])
+		if err != nil {
+			return nil, fmt.Errorf("state ximport action is invalid: %s, err: %s", cmdStr, err)
+		}
+		action = NewStateImportBatchAction(entries)
+
+	case "raw":
+		subcommand, rawArgs, err := parseRawActionArgs(args[1:])
+		if err != nil {
+			return nil, fmt.Errorf("state raw action is invalid: %s, err: %s", cmdStr, err)
+		}
+		action = NewStateRawAction(subcommand, rawArgs)
+
 	default:
 		return nil, fmt.Errorf("unknown state action type: %s", cmdStr)
 	}
@@ -75,6 +75,125 @@ func TestNewStateActionFromString(t *testing.T) {
 			},
 			ok: true,
 		},
+		{
+			desc:   "xmv action with case-insensitive (valid)",
+			cmdStr: "xmv --case-insensitive aws_instance.Web* aws_instance.$1",
+			want: &StateXmvAction{
+				source:          "aws_instance.Web*",
+				destination:     "aws_instance.$1",
+				caseInsensitive: true,
+			},
+			ok: true,
+		},
+		{
+			desc:   "xmv action with excludes (valid)",
+			cmdStr: "xmv --exclude=aws_instance.bastion --case-insensitive --exclude=aws_instance.nat* aws_instance.* module.app.aws_instance.$1",
+			want: &StateXmvAction{
+				source:          "aws_instance.*",
+				destination:     "module.app.aws_instance.$1",
+				caseInsensitive: true,
+				excludes:        []string{"aws_instance.bastion", "aws_instance.nat*"},
+			},
+			ok: true,
+		},
+		{
+			desc:   "xmv action with include-data (valid)",
+			cmdStr: "xmv --include-data * module.app.$1",
+			want: &StateXmvAction{
+				source:      "*",
+				destination: "module.app.$1",
+				includeData: true,
+			},
+			ok: true,
+		},
+		{
+			desc:   "xmv action with expect-matches (valid)",
+			cmdStr: "xmv --expect-matches=5 aws_instance.* module.app.aws_instance.$1",
+			want: &StateXmvAction{
+				source:        "aws_instance.*",
+				destination:   "module.app.aws_instance.$1",
+				expectMatches: intPtr(5),
+			},
+			ok: true,
+		},
+		{
+			desc:   "xmv action with invalid expect-matches",
+			cmdStr: "xmv --expect-matches=foo aws_instance.* module.app.aws_instance.$1",
+			want:   nil,
+			ok:     false,
+		},
+		{
+			desc:   "xmv action with negative expect-matches",
+			cmdStr: "xmv --expect-matches=-1 aws_instance.* module.app.aws_instance.$1",
+			want:   nil,
+			ok:     false,
+		},
+		{
+			desc:   "xmv action with regex (valid)",
+			cmdStr: `xmv --regex 'aws_instance\.web_(?P<index>[0-9]+)' 'module.web.aws_instance.this[${index}]'`,
+			want: &StateXmvAction{
+				source:      `aws_instance\.web_(?P<index>[0-9]+)`,
+				destination: "module.web.aws_instance.this[${index}]",
+				regex:       true,
+			},
+			ok: true,
+		},
+		{
+			desc:   "xmv action with single-segment",
+			cmdStr: "xmv --single-segment module.*.aws_instance.* module.$1.aws_instance.${2}_new",
+			want: &StateXmvAction{
+				source:        "module.*.aws_instance.*",
+				destination:   "module.$1.aws_instance.${2}_new",
+				singleSegment: true,
+			},
+			ok: true,
+		},
+		{
+			desc:   "xmv action with invalid regex",
+			cmdStr: "xmv --regex 'aws_instance.(foo' module.app.aws_instance.$1",
+			want:   nil,
+			ok:     false,
+		},
+		{
+			desc:   "xmv action with regex and an undefined capture group",
+			cmdStr: "xmv --regex 'aws_instance.(?P<name>.+)' 'module.app.aws_instance.${nmae}'",
+			want:   nil,
+			ok:     false,
+		},
+		{
+			desc:   "xmv action with within-module (valid)",
+			cmdStr: `xmv '--within-module=module.network["a"]' 'module.network["a"].*' module.vpc.$1`,
+			want: &StateXmvAction{
+				source:       `module.network["a"].*`,
+				destination:  "module.vpc.$1",
+				withinModule: `module.network["a"]`,
+			},
+			ok: true,
+		},
+		{
+			desc:   "xmv action with invalid within-module",
+			cmdStr: "xmv --within-module=aws_instance.foo aws_instance.* module.app.aws_instance.$1",
+			want:   nil,
+			ok:     false,
+		},
+		{
+			desc:   "xmv action with empty exclude",
+			cmdStr: "xmv --exclude= aws_instance.* module.app.aws_instance.$1",
+			want:   nil,
+			ok:     false,
+		},
+		{
+			desc:   "xmv action with unknown flag",
+			cmdStr: "xmv --foo aws_instance.* module.app.aws_instance.$1",
+			want:   nil,
+			ok:     false,
+		},
+		{
+			desc:   "xmv action with case-insensitive (1 arg)",
+			cmdStr: "xmv --case-insensitive aws_instance.Web*",
+			want:   nil,
+			ok:     false,
+		},
 		{
 			desc:   "xmv action (no args)",
 			cmdStr: "xmv",
@@ -93,6 +212,48 @@ func TestNewStateActionFromString(t *testing.T) {
 			want:   nil,
 			ok:     false,
 		},
+		{
+			desc:   "raw action (valid)",
+			cmdStr: "raw taint time_static.foo",
+			want: &StateRawAction{
+				subcommand: []string{"taint"},
+				args:       []string{"time_static.foo"},
+			},
+			ok: true,
+		},
+		{
+			desc:   "raw action with a state subcommand (valid)",
+			cmdStr: "raw state replace-provider -lock=false registry.terraform.io/-/null registry.terraform.io/hashicorp/null",
+			want: &StateRawAction{
+				subcommand: []string{"state", "replace-provider"},
+				args:       []string{"-lock=false", "registry.terraform.io/-/null", "registry.terraform.io/hashicorp/null"},
+			},
+			ok: true,
+		},
+		{
+			desc:   "raw action (no args)",
+			cmdStr: "raw taint",
+			want:   nil,
+			ok:     false,
+		},
+		{
+			desc:   "raw action (not allowed subcommand)",
+			cmdStr: "raw apply -auto-approve",
+			want:   nil,
+			ok:     false,
+		},
+		{
+			desc:   "raw action (not allowed state subcommand)",
+			cmdStr: "raw state push foo.tfstate",
+			want:   nil,
+			ok:     false,
+		},
+		{
+			desc:   "raw action (-state option)",
+			cmdStr: "raw untaint -state=foo.tfstate time_static.foo",
+			want:   nil,
+			ok:     false,
+		},
 		{
 			desc:   "rm action (valid)",
 			cmdStr: "rm time_static.foo",
@@ -124,6 +285,27 @@ func TestNewStateActionFromString(t *testing.T) {
 			},
 			ok: true,
 		},
+		{
+			desc:   "import action (module instance)",
+			cmdStr: `import 'module.foo["a b"].aws_instance.bar[0]' i-1234567890abcdef0`,
+			want: &StateImportAction{
+				address: `module.foo["a b"].aws_instance.bar[0]`,
+				id:      "i-1234567890abcdef0",
+			},
+			ok: true,
+		},
+		{
+			desc:   "import action (invalid address)",
+			cmdStr: `import 'module.foo[a].aws_instance.bar' i-1234567890abcdef0`,
+			want:   nil,
+			ok:     false,
+		},
+		{
+			desc:   "import-batch action (invalid address)",
+			cmdStr: `import-batch time_static.foo 2006-01-02T15:04:05Z time_static 2006-01-02T15:04:05Z`,
+			want:   nil,
+			ok:     false,
+		},
 		{
 			desc:   "import action (no args)",
 			cmdStr: "import",
@@ -142,6 +324,82 @@ func TestNewStateActionFromString(t *testing.T) {
 			want:   nil,
 			ok:     false,
 		},
+		{
+			desc:   "import action (empty id)",
+			cmdStr: `import time_static.foo ""`,
+			want:   nil,
+			ok:     false,
+		},
+		{
+			desc:   "import action (blank id)",
+			cmdStr: `import time_static.foo " "`,
+			want:   nil,
+			ok:     false,
+		},
+		{
+			desc:   "import-batch action (empty id)",
+			cmdStr: `import-batch time_static.foo 2006-01-02T15:04:05Z time_static.bar ""`,
+			want:   nil,
+			ok:     false,
+		},
+		{
+			desc:   "import-batch action (valid)",
+			cmdStr: "import-batch time_static.foo 2006-01-02T15:04:05Z time_static.bar 2006-01-02T15:04:05Z",
+			want: &StateImportBatchAction{
+				entries: []StateImportEntry{
+					{Address: "time_static.foo", ID: "2006-01-02T15:04:05Z"},
+					{Address: "time_static.bar", ID: "2006-01-02T15:04:05Z"},
+				},
+			},
+			ok: true,
+		},
+		{
+			desc:   "import-batch action (no args)",
+			cmdStr: "import-batch",
+			want:   nil,
+			ok:     false,
+		},
+		{
+			desc:   "import-batch action (1 arg)",
+			cmdStr: "import-batch time_static.foo",
+			want:   nil,
+			ok:     false,
+		},
+		{
+			desc:   "import-batch action (3 args)",
+			cmdStr: "import-batch time_static.foo foo time_static.bar",
+			want:   nil,
+			ok:     false,
+		},
+		{
+			desc:   "ximport action (valid)",
+			cmdStr: `ximport 'aws_iam_role.this["*"]' arn:aws:iam::123456789012:role/$1 foo bar`,
+			want: &StateImportBatchAction{
+				entries: []StateImportEntry{
+					{Address: `aws_iam_role.this["foo"]`, ID: "arn:aws:iam::123456789012:role/foo"},
+					{Address: `aws_iam_role.this["bar"]`, ID: "arn:aws:iam::123456789012:role/bar"},
+				},
+			},
+			ok: true,
+		},
+		{
+			desc:   "ximport action (no keys)",
+			cmdStr: "ximport aws_iam_role.* $1",
+			want:   nil,
+			ok:     false,
+		},
+		{
+			desc:   "ximport action (1 arg)",
+			cmdStr: "ximport aws_iam_role.*",
+			want:   nil,
+			ok:     false,
+		},
+		{
+			desc:   "ximport action (unknown flag)",
+			cmdStr: "ximport --foo aws_iam_role.* $1 foo",
+			want:   nil,
+			ok:     false,
+		},
 		{
 			desc:   "duplicated white spaces",
 			cmdStr: " mv  null_resource.foo    null_resource.foo2 ",
@@ -0,0 +1,225 @@
+package tfmigrate
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/minamijoyo/tfmigrate/tfexec"
+)
+
+// stateMove is a pair of a source and a destination address of a move.
+type stateMove struct {
+	source      string
+	destination string
+}
+
+// stateMover is implemented by actions which move resources or modules.
+type stateMover interface {
+	// stateMoves returns a list of moves which an action applies to a given
+	// state.
+	stateMoves(state *tfexec.State) ([]stateMove, error)
+}
+
+// stateMoves implements the stateMover interface.
+func (a *StateMvAction) stateMoves(_ *tfexec.State) ([]stateMove, error) {
+	return []stateMove{{source: a.source, destination: a.destination}}, nil
+}
+
+// stateMoves implements the stateMover interface.
+// The wildcards are expanded against addresses in a given state.
+func (a *StateXmvAction) stateMoves(state *tfexec.State) ([]stateMove, error) {
+	sources, destinations, err := expandXmvScope(a, state)
+	if err != nil {
+		return nil, err
+	}
+	moves := make([]stateMove, 0, len(sources))
+	for i := range sources {
+		moves = append(moves, stateMove{source: sources[i], destination: destinations[i]})
+	}
+	return moves, nil
+}
+
+// instanceKeyRegex matches an instance key in an address.
+// (e.g.) `[0]`, `["a"]`
+var instanceKeyRegex = regexp.MustCompile(`\[[^\]]*\]`)
+
+// configAddress returns an address without instance keys, which is the form
+// of addresses in the dependencies of instances in tfstate.
+// (e.g.) `module.foo["a"].aws_instance.bar[0]` => `module.foo.aws_instance.bar`
+func configAddress(address string) string {
+	return instanceKeyRegex.ReplaceAllString(address, "")
+}
+
+// rewriteActionDependencies rewrites stale dependencies in a given after state
+// of an action which moves resources or modules. The moves are computed from
+// a given before state of the action. It returns the after state as it is if
+// the action doesn't implement the stateMover interface.
+func rewriteActionDependencies(action StateAction, before *tfexec.State, after *tfexec.State) (*tfexec.State, []string, error) {
+	m, ok := action.(stateMover)
+	if !ok {
+		return after, nil, nil
+	}
+	moves, err := m.stateMoves(before)
+	if err != nil {
+		return nil, nil, err
+	}
+	return rewriteStateDependencies(after, moves)
+}
+
+// rewriteStateDependencies rewrites references to sources of given moves in
+// the dependencies of instances in a given state to point at the
+// destinations. Terraform doesn't update them on state mv, and they are fixed
+// on the next apply, but a plan in between may be noisy.
+// A reference is rewritten only if it's stale, that is, no resource exists at
+// the address in the state any more. It returns a new state and a list of
+// rewritten references in the form of `<instance>: <old> => <new>`.
+// If nothing is rewritten, the given state is returned as it is.
+func rewriteStateDependencies(state *tfexec.State, moves []stateMove) (*tfexec.State, []string, error) {
+	// The state is read as a stream twice, first to collect existing addresses
+	// and then to rewrite dependencies, so that it's not loaded into memory
+	// unless anything is rewritten.
+	exists := map[string]bool{}
+	ok, err := walkStateResources(state, func(r stateResource) error {
+		exists[configAddress(r.address())] = true
+		return nil
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+	if !ok || len(exists) == 0 {
+		// empty, encrypted or unsupported state.
+		return state, nil, nil
+	}
+
+	rewritten := []string{}
+	// changed is a map of rewritten instances keyed by the index of resources.
+	changed := map[int]json.RawMessage{}
+	i := 0
+	_, err = walkStateResources(state, func(r stateResource) error {
+		defer func() { i++ }()
+		instances, logs, err := rewriteResourceDependencies(r, moves, exists)
+		if err != nil || len(logs) == 0 {
+			return err
+		}
+		changed[i] = instances
+		rewritten = append(rewritten, logs...)
+		return nil
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if len(rewritten) == 0 {
+		return state, nil, nil
+	}
+
+	resources, err := parseStateResources(state)
+	if err != nil {
+		return nil, nil, err
+	}
+	for i, instances := range changed {
+		resources[i]["instances"] = instances
+	}
+	var root map[string]json.RawMessage
+	if err := json.NewDecoder(state.Reader()).Decode(&root); err != nil {
+		return nil, nil, fmt.Errorf("failed to parse tfstate: %s", err)
+	}
+	if root["resources"], err = json.Marshal(resources); err != nil {
+		return nil, nil, err
+	}
+	b, err := json.MarshalIndent(root, "", "  ")
+	if err != nil {
+		return nil, nil, err
+	}
+	return tfexec.NewState(append(b, '\n')), rewritten, nil
+}
+
+// rewriteResourceDependencies rewrites stale references in the dependencies
+// of instances of a given resource. It returns the rewritten instances and
+// logs of rewritten references in the form of `<instance>: <old> => <new>`.
+// If nothing is rewritten, the logs are empty.
+func rewriteResourceDependencies(r stateResource, moves []stateMove, exists map[string]bool) (json.RawMessage, []string, error) {
+	var instances []map[string]json.RawMessage
+	if raw, ok := r["instances"]; ok {
+		if err := json.Unmarshal(raw, &instances); err != nil {
+			return nil, nil, fmt.Errorf("failed to parse instances of %s in tfstate: %s", r.address(), err)
+		}
+	}
+
+	rewritten := []string{}
+	addrs := r.instanceAddresses()
+	for i, instance := range instances {
+		raw, ok := instance["dependencies"]
+		if !ok {
+			continue
+		}
+		var deps []string
+		if err := json.Unmarshal(raw, &deps); err != nil {
+			return nil, nil, fmt.Errorf("failed to parse dependencies of %s in tfstate: %s", r.address(), err)
+		}
+
+		newDeps, logs := rewriteDependencies(deps, moves, exists)
+		if len(logs) == 0 {
+			continue
+		}
+		b, err := json.Marshal(newDeps)
+		if err != nil {
+			return nil, nil, err
+		}
+		instance["dependencies"] = b
+		for _, l := range logs {
+			rewritten = append(rewritten, addrs[i]+": "+l)
+		}
+	}
+	if len(rewritten) == 0 {
+		return nil, rewritten, nil
+	}
+
+	b, err := json.Marshal(instances)
+	if err != nil {
+		return nil, nil, err
+	}
+	return b, rewritten, nil
+}
+
+// rewriteDependencies rewrites stale references in a given list of
+// dependencies. A reference to a moved resource or to a resource in a moved
+// module is replaced with its destination. The result is sorted and
+// deduplicated as terraform does. It also returns logs of rewritten
+// references in the form of `<old> => <new>`.
+func rewriteDependencies(deps []string, moves []stateMove, exists map[string]bool) ([]string, []string) {
+	logs := []string{}
+	seen := map[string]bool{}
+	newDeps := []string{}
+	for _, dep := range deps {
+		newDep := dep
+		if !exists[dep] {
+			for _, mv := range moves {
+				src := configAddress(mv.source)
+				dst := configAddress(mv.destination)
+				if src == dst {
+					continue
+				}
+				if dep == src || strings.HasPrefix(dep, src+".") {
+					newDep = dst + strings.TrimPrefix(dep, src)
+					break
+				}
+			}
+		}
+		if newDep != dep {
+			logs = append(logs, dep+" => "+newDep)
+		}
+		if !seen[newDep] {
+			seen[newDep] = true
+			newDeps = append(newDeps, newDep)
+		}
+	}
+	if len(logs) == 0 {
+		return deps, logs
+	}
+	sort.Strings(newDeps)
+	return newDeps, logs
+}
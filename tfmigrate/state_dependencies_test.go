@@ -0,0 +1,204 @@
+package tfmigrate
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/minamijoyo/tfmigrate/tfexec"
+)
+
+// testDependenciesState is a state which has resources depending on others.
+// It's intended to be a state after moves, that is, null_resource.foo has
+// been moved to null_resource.foo2 and module.a has been moved to module.b,
+// but dependencies still refer to the old addresses.
+const testDependenciesState = `{
+  "version": 4,
+  "terraform_version": "1.9.8",
+  "serial": 3,
+  "lineage": "a6dd2e3c-5f2d-4b6f-9d8d-4e3bd9c1fb7c",
+  "outputs": {},
+  "resources": [
+    {
+      "mode": "managed",
+      "type": "null_resource",
+      "name": "foo2",
+      "provider": "provider[\"registry.terraform.io/hashicorp/null\"]",
+      "instances": [{"schema_version": 0, "attributes": {"id": "1"}}]
+    },
+    {
+      "mode": "managed",
+      "type": "null_resource",
+      "name": "bar",
+      "provider": "provider[\"registry.terraform.io/hashicorp/null\"]",
+      "instances": [
+        {"index_key": 0, "schema_version": 0, "attributes": {"id": "2"}, "dependencies": ["module.a.null_resource.baz", "null_resource.foo", "null_resource.qux"]},
+        {"index_key": 1, "schema_version": 0, "attributes": {"id": "3"}, "dependencies": ["null_resource.qux"]}
+      ]
+    },
+    {
+      "module": "module.b[\"x\"]",
+      "mode": "managed",
+      "type": "null_resource",
+      "name": "baz",
+      "provider": "provider[\"registry.terraform.io/hashicorp/null\"]",
+      "instances": [{"schema_version": 0, "attributes": {"id": "4"}, "dependencies": ["null_resource.foo"]}]
+    },
+    {
+      "mode": "managed",
+      "type": "null_resource",
+      "name": "qux",
+      "provider": "provider[\"registry.terraform.io/hashicorp/null\"]",
+      "instances": [{"schema_version": 0, "attributes": {"id": "5"}}]
+    }
+  ]
+}
+`
+
+// dependenciesOf returns dependencies of each instance in a given state.
+func dependenciesOf(t *testing.T, state *tfexec.State) map[string][]string {
+	t.Helper()
+	resources, err := parseStateResources(state)
+	if err != nil {
+		t.Fatalf("failed to parse resources: %s", err)
+	}
+	got := map[string][]string{}
+	for _, r := range resources {
+		var instances []map[string]json.RawMessage
+		if err := json.Unmarshal(r["instances"], &instances); err != nil {
+			t.Fatalf("failed to parse instances: %s", err)
+		}
+		for i, addr := range r.instanceAddresses() {
+			var deps []string
+			if raw, ok := instances[i]["dependencies"]; ok {
+				if err := json.Unmarshal(raw, &deps); err != nil {
+					t.Fatalf("failed to parse dependencies: %s", err)
+				}
+			}
+			got[addr] = deps
+		}
+	}
+	return got
+}
+
+func TestRewriteStateDependencies(t *testing.T) {
+	cases := []struct {
+		desc          string
+		moves         []stateMove
+		want          map[string][]string
+		wantRewritten []string
+	}{
+		{
+			desc: "resource and module",
+			moves: []stateMove{
+				{source: "null_resource.foo", destination: "null_resource.foo2"},
+				{source: `module.a["x"]`, destination: `module.b["x"]`},
+			},
+			want: map[string][]string{
+				"null_resource.foo2":              nil,
+				"null_resource.bar[0]":            {"module.b.null_resource.baz", "null_resource.foo2", "null_resource.qux"},
+				"null_resource.bar[1]":            {"null_resource.qux"},
+				`module.b["x"].null_resource.baz`: {"null_resource.foo2"},
+				"null_resource.qux":               nil,
+			},
+			wantRewritten: []string{
+				"null_resource.bar[0]: module.a.null_resource.baz => module.b.null_resource.baz",
+				"null_resource.bar[0]: null_resource.foo => null_resource.foo2",
+				`module.b["x"].null_resource.baz: null_resource.foo => null_resource.foo2`,
+			},
+		},
+		{
+			desc: "not stale",
+			moves: []stateMove{
+				{source: "null_resource.qux", destination: "null_resource.qux2"},
+			},
+			want: map[string][]string{
+				"null_resource.foo2":              nil,
+				"null_resource.bar[0]":            {"module.a.null_resource.baz", "null_resource.foo", "null_resource.qux"},
+				"null_resource.bar[1]":            {"null_resource.qux"},
+				`module.b["x"].null_resource.baz`: {"null_resource.foo"},
+				"null_resource.qux":               nil,
+			},
+			wantRewritten: nil,
+		},
+		{
+			desc: "instance key only",
+			moves: []stateMove{
+				{source: "null_resource.foo[0]", destination: "null_resource.foo[1]"},
+			},
+			want: map[string][]string{
+				"null_resource.foo2":              nil,
+				"null_resource.bar[0]":            {"module.a.null_resource.baz", "null_resource.foo", "null_resource.qux"},
+				"null_resource.bar[1]":            {"null_resource.qux"},
+				`module.b["x"].null_resource.baz`: {"null_resource.foo"},
+				"null_resource.qux":               nil,
+			},
+			wantRewritten: nil,
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.desc, func(t *testing.T) {
+			state := tfexec.NewState([]byte(testDependenciesState))
+			got, rewritten, err := rewriteStateDependencies(state, tc.moves)
+			if err != nil {
+				t.Fatalf("unexpected err: %s", err)
+			}
+			if diff := cmp.Diff(dependenciesOf(t, got), tc.want); diff != "" {
+				t.Errorf("got: %#v, want: %#v, diff: %s", dependenciesOf(t, got), tc.want, diff)
+			}
+			if diff := cmp.Diff(rewritten, tc.wantRewritten); diff != "" {
+				t.Errorf("got rewritten: %#v, want: %#v, diff: %s", rewritten, tc.wantRewritten, diff)
+			}
+			if tc.wantRewritten == nil && got != state {
+				t.Errorf("expected to return the given state as it is")
+			}
+		})
+	}
+}
+
+func TestRewriteStateDependenciesPreservesState(t *testing.T) {
+	got, _, err := rewriteStateDependencies(tfexec.NewState([]byte(testDependenciesState)), []stateMove{
+		{source: "null_resource.foo", destination: "null_resource.foo2"},
+	})
+	if err != nil {
+		t.Fatalf("unexpected err: %s", err)
+	}
+
+	var s map[string]interface{}
+	if err := json.Unmarshal(got.Bytes(), &s); err != nil {
+		t.Fatalf("failed to parse state: %s", err)
+	}
+	if s["serial"] != float64(3) {
+		t.Errorf("unexpected serial: %v", s["serial"])
+	}
+	if s["lineage"] != "a6dd2e3c-5f2d-4b6f-9d8d-4e3bd9c1fb7c" {
+		t.Errorf("unexpected lineage: %v", s["lineage"])
+	}
+	resources, err := parseStateResources(got)
+	if err != nil {
+		t.Fatalf("failed to parse resources: %s", err)
+	}
+	if p := resources[1].getString("provider"); p != `provider["registry.terraform.io/hashicorp/null"]` {
+		t.Errorf("unexpected provider: %s", p)
+	}
+}
+
+func TestStateXmvActionStateMoves(t *testing.T) {
+	state := tfexec.NewState([]byte(`{"version": 4, "resources": [
+  {"mode": "managed", "type": "null_resource", "name": "foo", "instances": [{"attributes": {"id": "1"}}]},
+  {"mode": "managed", "type": "null_resource", "name": "bar", "instances": [{"attributes": {"id": "2"}}]}
+]}`))
+	a := NewStateXmvAction("null_resource.*", "module.a.null_resource.$1")
+	got, err := a.stateMoves(state)
+	if err != nil {
+		t.Fatalf("unexpected err: %s", err)
+	}
+	want := []stateMove{
+		{source: "null_resource.foo", destination: "module.a.null_resource.foo"},
+		{source: "null_resource.bar", destination: "module.a.null_resource.bar"},
+	}
+	if diff := cmp.Diff(got, want, cmp.AllowUnexported(stateMove{})); diff != "" {
+		t.Errorf("got: %#v, want: %#v, diff: %s", got, want, diff)
+	}
+}
@@ -2,6 +2,9 @@ package tfmigrate
 
 import (
 	"context"
+	"fmt"
+	"regexp"
+	"strings"
 
 	"github.com/minamijoyo/tfmigrate/tfexec"
 )
@@ -34,5 +37,114 @@ func NewStateImportAction(address string, id string) *StateImportAction {
 func (a *StateImportAction) StateUpdate(ctx context.Context, tf tfexec.TerraformCLI, state *tfexec.State) (*tfexec.State, error) {
 	// Disable unnecessary state backup here,
 	// because we never restore state from the backup generated by each state action.
-	return tf.Import(ctx, state, a.address, a.id, "-input=false", "-no-color", "-backup=/dev/null")
+	newState, err := tf.Import(ctx, state, a.address, a.id, "-input=false", "-no-color", "-backup=/dev/null")
+	if err != nil {
+		return nil, explainImportError(ctx, tf, state, a.address, err)
+	}
+	return newState, nil
+}
+
+// importEntries returns a list of addresses and ids to be imported.
+func (a *StateImportAction) importEntries() []StateImportEntry {
+	return []StateImportEntry{{Address: a.address, ID: a.id}}
+}
+
+// importEntriesGetter is implemented by actions which import resources.
+// It's used for validating import ids.
+type importEntriesGetter interface {
+	importEntries() []StateImportEntry
+}
+
+// importIDPlaceholders is a list of strings which look like an unsubstituted
+// placeholder in an import id.
+var importIDPlaceholders = []string{"${", "TODO"}
+
+// importAddressRegex matches a resource instance address to import to.
+// An instance key is either a number or a quoted string.
+// (e.g.) `aws_instance.foo`, `module.foo["a"].aws_instance.bar[0]`
+var importAddressRegex = regexp.MustCompile(`^((?:module\.[A-Za-z0-9_-]+(?:\[(?:[0-9]+|"(?:[^"\\]|\\.)*")\])?\.)*)[A-Za-z0-9_-]+\.[A-Za-z0-9_-]+(?:\[(?:[0-9]+|"(?:[^"\\]|\\.)*")\])?$`)
+
+// validateImportAddress returns an error if a given address is not a valid
+// resource instance address to import to.
+func validateImportAddress(address string) error {
+	// A module address itself is not importable, though it looks like a
+	// resource address whose type is module.
+	if !importAddressRegex.MatchString(address) || moduleAddressRegex.MatchString(address) {
+		return fmt.Errorf("import address is invalid: %s", address)
+	}
+	return nil
+}
+
+// importModuleInstance returns a module instance address of a given address
+// to import to if any of its modules has an instance key, that is, the module
+// is expanded by for_each or count. Otherwise, it returns an empty string.
+// (e.g.) `module.foo["a"].aws_instance.bar` => `module.foo["a"]`
+func importModuleInstance(address string) string {
+	matched := importAddressRegex.FindStringSubmatch(address)
+	if matched == nil {
+		return ""
+	}
+	module := strings.TrimSuffix(matched[1], ".")
+	if !strings.Contains(module, "[") {
+		return ""
+	}
+	return module
+}
+
+// missingModuleInstance returns a module instance address of a given address
+// to import to if it has an instance key and no resource in a given state
+// list belongs to it. Otherwise, it returns an empty string.
+func missingModuleInstance(address string, stateList []string) string {
+	module := importModuleInstance(address)
+	if len(module) == 0 {
+		return ""
+	}
+	for _, r := range stateList {
+		if strings.HasPrefix(r, module+".") {
+			return ""
+		}
+	}
+	return module
+}
+
+// explainImportError returns an error with a hint if terraform import failed
+// for an address in a module instance which doesn't exist in state yet.
+// Terraform cannot import to a module instance unless it knows the instance
+// exists, and the original error is confusing in this case.
+// If the cause is something else, it returns the original error as is.
+func explainImportError(ctx context.Context, tf tfexec.TerraformCLI, state *tfexec.State, address string, err error) error {
+	if len(importModuleInstance(address)) == 0 {
+		return err
+	}
+
+	stateList, lerr := tf.StateList(ctx, state, nil)
+	if lerr != nil {
+		return err
+	}
+
+	module := missingModuleInstance(address, stateList)
+	if len(module) == 0 {
+		return err
+	}
+	return fmt.Errorf("failed to import %s: the module instance %s is not found in state. Apply the module instance first, or use config-driven import with an import block instead: %s", address, module, err)
+}
+
+// validateImportID returns an error if a given import id is empty.
+// An empty id is never valid and terraform would import garbage.
+func validateImportID(address string, id string) error {
+	if len(strings.TrimSpace(id)) == 0 {
+		return fmt.Errorf("import id for %s is empty", address)
+	}
+	return nil
+}
+
+// hasImportIDPlaceholder returns true if a given import id looks like an
+// unsubstituted placeholder such as `${foo}` or `TODO`.
+func hasImportIDPlaceholder(id string) bool {
+	for _, p := range importIDPlaceholders {
+		if strings.Contains(id, p) {
+			return true
+		}
+	}
+	return false
 }
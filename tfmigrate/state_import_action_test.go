@@ -2,6 +2,7 @@ package tfmigrate
 
 import (
 	"context"
+	"reflect"
 	"testing"
 
 	"github.com/minamijoyo/tfmigrate/tfexec"
@@ -40,7 +41,7 @@ resource "time_static" "baz" { triggers = {} }
 		NewStateImportAction("time_static.baz", "2006-01-02T15:04:05Z"),
 	}
 
-	m := NewStateMigrator(tf.Dir(), workspace, actions, &MigratorOption{}, false, false)
+	m := NewStateMigrator(tf.Dir(), workspace, actions, &MigratorOption{}, StateMigratorOptions{})
 	err = m.Plan(ctx)
 	if err != nil {
 		t.Fatalf("failed to run migrator plan: %s", err)
@@ -51,3 +52,174 @@ resource "time_static" "baz" { triggers = {} }
 		t.Fatalf("failed to run migrator apply: %s", err)
 	}
 }
+
+func TestWarnImportIDPlaceholders(t *testing.T) {
+	cases := []struct {
+		desc   string
+		action StateAction
+		want   []string
+	}{
+		{
+			desc:   "valid id",
+			action: NewStateImportAction("aws_instance.foo", "i-1234567890abcdef0"),
+			want:   []string{},
+		},
+		{
+			desc:   "dollar sign without brace",
+			action: NewStateImportAction("aws_instance.foo", "foo$bar"),
+			want:   []string{},
+		},
+		{
+			desc:   "template placeholder",
+			action: NewStateImportAction("aws_instance.foo", "${instance_id}"),
+			want:   []string{"aws_instance.foo"},
+		},
+		{
+			desc:   "TODO",
+			action: NewStateImportAction("aws_instance.foo", "TODO"),
+			want:   []string{"aws_instance.foo"},
+		},
+		{
+			desc: "import-batch",
+			action: NewStateImportBatchAction([]StateImportEntry{
+				{Address: "aws_instance.foo", ID: "i-1234567890abcdef0"},
+				{Address: "aws_instance.bar", ID: "i-TODO"},
+			}),
+			want: []string{"aws_instance.bar"},
+		},
+		{
+			desc:   "not import",
+			action: NewStateMvAction("aws_instance.foo", "aws_instance.bar"),
+			want:   nil,
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.desc, func(t *testing.T) {
+			got := warnImportIDPlaceholders(tc.action)
+			if !reflect.DeepEqual(got, tc.want) {
+				t.Errorf("got: %v, want: %v", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestValidateImportAddress(t *testing.T) {
+	cases := []struct {
+		desc    string
+		address string
+		ok      bool
+	}{
+		{
+			desc:    "resource",
+			address: "aws_instance.foo",
+			ok:      true,
+		},
+		{
+			desc:    "resource with a numeric key",
+			address: "aws_instance.foo[0]",
+			ok:      true,
+		},
+		{
+			desc:    "resource with a string key",
+			address: `aws_instance.foo["a b"]`,
+			ok:      true,
+		},
+		{
+			desc:    "module instance with a string key",
+			address: `module.foo["a"].aws_instance.bar`,
+			ok:      true,
+		},
+		{
+			desc:    "nested module instances",
+			address: `module.foo["a"].module.bar[0].aws_instance.baz["b"]`,
+			ok:      true,
+		},
+		{
+			desc:    "string key with an escaped quote",
+			address: `module.foo["a\"b"].aws_instance.bar`,
+			ok:      true,
+		},
+		{
+			desc:    "unquoted string key",
+			address: `module.foo[a].aws_instance.bar`,
+			ok:      false,
+		},
+		{
+			desc:    "module only",
+			address: `module.foo["a"]`,
+			ok:      false,
+		},
+		{
+			desc:    "missing name",
+			address: "aws_instance",
+			ok:      false,
+		},
+		{
+			desc:    "unclosed key",
+			address: `module.foo["a].aws_instance.bar`,
+			ok:      false,
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.desc, func(t *testing.T) {
+			err := validateImportAddress(tc.address)
+			if tc.ok && err != nil {
+				t.Fatalf("unexpected err: %s", err)
+			}
+			if !tc.ok && err == nil {
+				t.Fatal("expected to return an error, but no error")
+			}
+		})
+	}
+}
+
+func TestMissingModuleInstance(t *testing.T) {
+	cases := []struct {
+		desc      string
+		address   string
+		stateList []string
+		want      string
+	}{
+		{
+			desc:      "root module",
+			address:   "aws_instance.foo",
+			stateList: []string{},
+			want:      "",
+		},
+		{
+			desc:      "module without key",
+			address:   "module.foo.aws_instance.bar",
+			stateList: []string{},
+			want:      "",
+		},
+		{
+			desc:      "module instance exists",
+			address:   `module.foo["a"].aws_instance.bar`,
+			stateList: []string{`module.foo["a"].aws_instance.baz`},
+			want:      "",
+		},
+		{
+			desc:      "module instance not found",
+			address:   `module.foo["a"].aws_instance.bar`,
+			stateList: []string{`module.foo["b"].aws_instance.bar`, `module.foo["ab"].aws_instance.bar`},
+			want:      `module.foo["a"]`,
+		},
+		{
+			desc:      "nested module instance not found",
+			address:   `module.foo["a"].module.bar.aws_instance.baz`,
+			stateList: []string{`module.foo["a"].aws_instance.qux`},
+			want:      `module.foo["a"].module.bar`,
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.desc, func(t *testing.T) {
+			got := missingModuleInstance(tc.address, tc.stateList)
+			if got != tc.want {
+				t.Errorf("got: %s, want: %s", got, tc.want)
+			}
+		})
+	}
+}
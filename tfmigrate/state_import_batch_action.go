@@ -0,0 +1,106 @@
+package tfmigrate
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+
+	"github.com/minamijoyo/tfmigrate/tfexec"
+)
+
+// StateImportEntry is a pair of an address and an id to be imported.
+type StateImportEntry struct {
+	// Address is an address to import resource to.
+	Address string
+	// ID is a resource identifier to be imported.
+	ID string
+}
+
+// StateImportBatchAction implements the StateAction interface.
+// StateImportBatchAction imports multiple existing resources to state.
+// Some providers import child resources as a side effect of importing a
+// parent resource. To give visibility for them, it lists the state after
+// importing and reports all resources which are newly added to the state.
+type StateImportBatchAction struct {
+	// entries is a list of addresses and ids to be imported.
+	entries []StateImportEntry
+}
+
+var _ StateAction = (*StateImportBatchAction)(nil)
+
+// NewStateImportBatchAction returns a new StateImportBatchAction instance.
+func NewStateImportBatchAction(entries []StateImportEntry) *StateImportBatchAction {
+	return &StateImportBatchAction{
+		entries: entries,
+	}
+}
+
+// StateUpdate updates a given state and returns a new state.
+// It imports existing resources to state in order.
+// If one of them fails, it returns an error and discards the resources
+// imported so far. Since each import is applied to a copy of the state, the
+// given state is left as is, that is, nothing is partially imported.
+func (a *StateImportBatchAction) StateUpdate(ctx context.Context, tf tfexec.TerraformCLI, state *tfexec.State) (*tfexec.State, error) {
+	before, err := tf.StateList(ctx, state, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	newState := state
+	for i, e := range a.entries {
+		// Disable unnecessary state backup here,
+		// because we never restore state from the backup generated by each state action.
+		s, err := tf.Import(ctx, newState, e.Address, e.ID, "-input=false", "-no-color", "-backup=/dev/null")
+		if err != nil {
+			err = explainImportError(ctx, tf, newState, e.Address, err)
+			return nil, fmt.Errorf("failed to import %s (%d/%d), rolled back imported resources: %s, err: %s",
+				e.Address, i+1, len(a.entries), strings.Join(a.addresses()[:i], ", "), err)
+		}
+		newState = s
+	}
+
+	after, err := tf.StateList(ctx, newState, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	imported := diffStateList(before, after)
+	log.Printf("[INFO] [action@%s] imported resources: %s\n", tf.Dir(), strings.Join(imported, ", "))
+	if children := diffStateList(a.addresses(), imported); len(children) > 0 {
+		log.Printf("[INFO] [action@%s] resources imported as a side effect: %s\n", tf.Dir(), strings.Join(children, ", "))
+	}
+
+	return newState, nil
+}
+
+// importEntries returns a list of addresses and ids to be imported.
+func (a *StateImportBatchAction) importEntries() []StateImportEntry {
+	return a.entries
+}
+
+// addresses returns a list of addresses to be imported.
+func (a *StateImportBatchAction) addresses() []string {
+	addrs := make([]string, 0, len(a.entries))
+	for _, e := range a.entries {
+		addrs = append(addrs, e.Address)
+	}
+	return addrs
+}
+
+// diffStateList returns addresses which are in after but not in before.
+// The order of after is preserved.
+func diffStateList(before []string, after []string) []string {
+	exists := make(map[string]bool, len(before))
+	for _, addr := range before {
+		exists[addr] = true
+	}
+
+	diff := []string{}
+	for _, addr := range after {
+		if !exists[addr] {
+			diff = append(diff, addr)
+		}
+	}
+	return diff
+}
@@ -0,0 +1,85 @@
+package tfmigrate
+
+import (
+	"context"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/minamijoyo/tfmigrate/tfexec"
+)
+
+func TestAccStateImportBatchAction(t *testing.T) {
+	tfexec.SkipUnlessAcceptanceTestEnabled(t)
+
+	backend := tfexec.GetTestAccBackendS3Config(t.Name())
+
+	source := `
+resource "time_static" "foo" { triggers = {} }
+resource "time_static" "bar" { triggers = {} }
+resource "time_static" "baz" { triggers = {} }
+`
+
+	workspace := "default"
+	tf := tfexec.SetupTestAccWithApply(t, workspace, backend+source)
+	ctx := context.Background()
+
+	_, err := tf.StateRm(ctx, nil, []string{"time_static.foo", "time_static.baz"})
+	if err != nil {
+		t.Fatalf("failed to run terraform state rm: %s", err)
+	}
+
+	actions := []StateAction{
+		NewStateImportBatchAction([]StateImportEntry{
+			{Address: "time_static.foo", ID: "2006-01-02T15:04:05Z"},
+			{Address: "time_static.baz", ID: "2006-01-02T15:04:05Z"},
+		}),
+	}
+
+	m := NewStateMigrator(tf.Dir(), workspace, actions, &MigratorOption{}, StateMigratorOptions{})
+	err = m.Plan(ctx)
+	if err != nil {
+		t.Fatalf("failed to run migrator plan: %s", err)
+	}
+
+	err = m.Apply(ctx)
+	if err != nil {
+		t.Fatalf("failed to run migrator apply: %s", err)
+	}
+}
+
+func TestDiffStateList(t *testing.T) {
+	cases := []struct {
+		desc   string
+		before []string
+		after  []string
+		want   []string
+	}{
+		{
+			desc:   "no change",
+			before: []string{"aws_vpc.foo"},
+			after:  []string{"aws_vpc.foo"},
+			want:   []string{},
+		},
+		{
+			desc:   "imported with children",
+			before: []string{"aws_vpc.foo"},
+			after:  []string{"aws_vpc.foo", "aws_security_group.bar", "aws_security_group_rule.bar[0]"},
+			want:   []string{"aws_security_group.bar", "aws_security_group_rule.bar[0]"},
+		},
+		{
+			desc:   "empty before",
+			before: nil,
+			after:  []string{"aws_vpc.foo"},
+			want:   []string{"aws_vpc.foo"},
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.desc, func(t *testing.T) {
+			got := diffStateList(tc.before, tc.after)
+			if diff := cmp.Diff(got, tc.want); diff != "" {
+				t.Errorf("got: %v, want: %v, diff: %s", got, tc.want, diff)
+			}
+		})
+	}
+}
@@ -0,0 +1,475 @@
+package tfmigrate
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+
+	"github.com/minamijoyo/tfmigrate/tfexec"
+)
+
+// Since terraform state push is whole-state, a state action could mutate
+// resources unintentionally. To catch such accidental mutations, we assert
+// that only addresses intended by each action differ before and after it.
+
+// stateScoper is implemented by actions which know addresses they change.
+// Actions which don't implement it, such as replace-provider and raw actions,
+// are not checked because they can change any resource.
+type stateScoper interface {
+	// stateScope returns a list of addresses of resources or modules which an
+	// action is intended to change in a given state.
+	stateScope(state *tfexec.State) ([]string, error)
+}
+
+// resourceAdder is implemented by actions which may add resources out of
+// their scope. Some providers import child resources as a side effect of
+// importing a resource, so import actions are allowed to add new addresses.
+// Existing resources out of the scope must still be identical.
+type resourceAdder interface {
+	// addsResources returns true if the action may add resources out of its
+	// scope.
+	addsResources() bool
+}
+
+// addsResources implements the resourceAdder interface.
+func (a *StateImportAction) addsResources() bool {
+	return true
+}
+
+// addsResources implements the resourceAdder interface.
+func (a *StateImportBatchAction) addsResources() bool {
+	return true
+}
+
+// multiStateScoper is a multi state version of stateScoper.
+type multiStateScoper interface {
+	// multiStateScope returns lists of addresses of resources or modules which
+	// an action is intended to change in a given from state and to state.
+	multiStateScope(fromState *tfexec.State) ([]string, []string, error)
+}
+
+// stateScope implements the stateScoper interface.
+func (a *StateMvAction) stateScope(_ *tfexec.State) ([]string, error) {
+	return []string{a.source, a.destination}, nil
+}
+
+// stateScope implements the stateScoper interface.
+func (a *StateRmAction) stateScope(_ *tfexec.State) ([]string, error) {
+	return a.addresses, nil
+}
+
+// stateScope implements the stateScoper interface.
+func (a *StateImportAction) stateScope(_ *tfexec.State) ([]string, error) {
+	return []string{a.address}, nil
+}
+
+// stateScope implements the stateScoper interface.
+func (a *StateImportBatchAction) stateScope(_ *tfexec.State) ([]string, error) {
+	return a.addresses(), nil
+}
+
+// stateScope implements the stateScoper interface.
+// The wildcards are expanded against addresses in a given state.
+func (a *StateXmvAction) stateScope(state *tfexec.State) ([]string, error) {
+	sources, destinations, err := expandXmvScope(a, state)
+	if err != nil {
+		return nil, err
+	}
+	return append(sources, destinations...), nil
+}
+
+// multiStateScope implements the multiStateScoper interface.
+func (a *MultiStateMvAction) multiStateScope(_ *tfexec.State) ([]string, []string, error) {
+	return []string{a.source}, []string{a.destination}, nil
+}
+
+// multiStateScope implements the multiStateScoper interface.
+// The wildcards are expanded against addresses in a given from state.
+func (a *MultiStateXmvAction) multiStateScope(fromState *tfexec.State) ([]string, []string, error) {
+	return expandXmvScope(a.toStateXmvAction(), fromState)
+}
+
+// expandXmvScope returns sources and destinations of moves expanded from a
+// given xmv action against addresses in a given state.
+func expandXmvScope(a *StateXmvAction, state *tfexec.State) ([]string, []string, error) {
+	addresses := []string{}
+	_, err := walkStateResources(state, func(r stateResource) error {
+		addresses = append(addresses, r.instanceAddresses()...)
+		return nil
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+
+	actions, err := newXmvExpander(a).expand(addresses)
+	if err != nil {
+		return nil, nil, err
+	}
+	sources := []string{}
+	destinations := []string{}
+	for _, action := range actions {
+		sources = append(sources, action.source)
+		destinations = append(destinations, action.destination)
+	}
+	return sources, destinations, nil
+}
+
+// parseStateResources returns resources in a given state.
+// It returns nil if the state is empty or encrypted, or the state is not the
+// version 4 format, because there is nothing we can compare.
+// It holds all the resources in memory. Use walkStateResources instead if
+// each resource can be processed independently.
+func parseStateResources(state *tfexec.State) ([]stateResource, error) {
+	resources := []stateResource{}
+	ok, err := walkStateResources(state, func(r stateResource) error {
+		resources = append(resources, r)
+		return nil
+	})
+	if err != nil || !ok {
+		return nil, err
+	}
+	return resources, nil
+}
+
+// walkStateResources calls a given function with each resource in a given
+// state in order. The state is read as a stream and only a single resource is
+// held in memory at a time, which matters for a large state.
+// It returns false if the state is empty or encrypted, or the state is not
+// the version 4 format, because there is nothing we can compare.
+func walkStateResources(state *tfexec.State, f func(r stateResource) error) (bool, error) {
+	if state.Size() == 0 || state.IsEncrypted() {
+		return false, nil
+	}
+	meta, err := state.Meta()
+	if err != nil {
+		return false, err
+	}
+	if meta.Version != 4 {
+		return false, nil
+	}
+
+	dec := json.NewDecoder(state.Reader())
+	if err := expectJSONDelim(dec, '{'); err != nil {
+		return false, fmt.Errorf("failed to parse tfstate: %s", err)
+	}
+	for dec.More() {
+		t, err := dec.Token()
+		if err != nil {
+			return false, fmt.Errorf("failed to parse tfstate: %s", err)
+		}
+		if t != "resources" {
+			// The other attributes are small enough to read at once.
+			var raw json.RawMessage
+			if err := dec.Decode(&raw); err != nil {
+				return false, fmt.Errorf("failed to parse tfstate: %s", err)
+			}
+			continue
+		}
+
+		if err := expectJSONDelim(dec, '['); err != nil {
+			return false, fmt.Errorf("failed to parse resources in tfstate: %s", err)
+		}
+		for dec.More() {
+			var r stateResource
+			if err := dec.Decode(&r); err != nil {
+				return false, fmt.Errorf("failed to parse resources in tfstate: %s", err)
+			}
+			if err := f(r); err != nil {
+				return false, err
+			}
+		}
+		if err := expectJSONDelim(dec, ']'); err != nil {
+			return false, fmt.Errorf("failed to parse resources in tfstate: %s", err)
+		}
+	}
+	return true, nil
+}
+
+// expectJSONDelim reads a next token from a given decoder and returns an
+// error if it's not a given delimiter.
+func expectJSONDelim(dec *json.Decoder, delim json.Delim) error {
+	t, err := dec.Token()
+	if err != nil {
+		return err
+	}
+	if t != delim {
+		return fmt.Errorf("expected %s, but got: %v", delim, t)
+	}
+	return nil
+}
+
+// address returns an address of the resource without instance keys.
+// (e.g.) `module.foo["a"].aws_instance.bar`
+func (r stateResource) address() string {
+	addr := r.getString("type") + "." + r.getString("name")
+	if r.getString("mode") == "data" {
+		addr = "data." + addr
+	}
+	if m := r.getString("module"); len(m) != 0 {
+		addr = m + "." + addr
+	}
+	return addr
+}
+
+// instanceAddresses returns addresses of instances of the resource as
+// terraform state list does.
+// (e.g.) `aws_instance.foo[0]`, `aws_instance.foo["a"]`
+func (r stateResource) instanceAddresses() []string {
+	var instances []map[string]json.RawMessage
+	if raw, ok := r["instances"]; ok {
+		// instances which cannot be parsed are treated as empty.
+		_ = json.Unmarshal(raw, &instances)
+	}
+
+	addr := r.address()
+	addrs := []string{}
+	for _, i := range instances {
+		key, ok := i["index_key"]
+		if !ok {
+			addrs = append(addrs, addr)
+			continue
+		}
+		// A string key is quoted in JSON as is in the address.
+		addrs = append(addrs, addr+"["+string(key)+"]")
+	}
+	return addrs
+}
+
+// checkStateActionIntegrity returns an error if a given action changes any
+// resources out of its scope. It's a no-op if the action doesn't implement
+// the stateScoper interface.
+func checkStateActionIntegrity(action StateAction, before *tfexec.State, after *tfexec.State) error {
+	s, ok := action.(stateScoper)
+	if !ok {
+		return nil
+	}
+	scope, err := s.stateScope(before)
+	if err != nil {
+		return err
+	}
+	allowAdded := false
+	if r, ok := action.(resourceAdder); ok {
+		allowAdded = r.addsResources()
+	}
+	if err := checkStateIntegrity(scope, before, after, allowAdded); err != nil {
+		return fmt.Errorf("failed to check integrity of the new state: %s", err)
+	}
+	return nil
+}
+
+// checkMultiStateActionIntegrity is a multi state version of
+// checkStateActionIntegrity.
+func checkMultiStateActionIntegrity(action MultiStateAction, fromBefore *tfexec.State, toBefore *tfexec.State, fromAfter *tfexec.State, toAfter *tfexec.State) error {
+	s, ok := action.(multiStateScoper)
+	if !ok {
+		return nil
+	}
+	fromScope, toScope, err := s.multiStateScope(fromBefore)
+	if err != nil {
+		return err
+	}
+	if err := checkStateIntegrity(fromScope, fromBefore, fromAfter, false); err != nil {
+		return fmt.Errorf("failed to check integrity of the new state in from_dir: %s", err)
+	}
+	if err := checkStateIntegrity(toScope, toBefore, toAfter, false); err != nil {
+		return fmt.Errorf("failed to check integrity of the new state in to_dir: %s", err)
+	}
+	return nil
+}
+
+// checkMultiStateDestinationConflicts returns an error if a given to state
+// already contains any resources at addresses which a given action moves in.
+// It's intended to run before the action to catch re-runs and mistakes early,
+// because moving a resource to an existing address duplicates it or fails.
+// It's a no-op if the action doesn't implement the multiStateScoper interface,
+// or the to state cannot be compared, e.g. it's encrypted.
+func checkMultiStateDestinationConflicts(action MultiStateAction, fromState *tfexec.State, toState *tfexec.State) error {
+	s, ok := action.(multiStateScoper)
+	if !ok {
+		return nil
+	}
+	_, toScope, err := s.multiStateScope(fromState)
+	if err != nil {
+		return err
+	}
+	conflicts := []string{}
+	_, err = walkStateResources(toState, func(r stateResource) error {
+		for _, addr := range r.instanceAddresses() {
+			if inStateScope(addr, toScope) {
+				conflicts = append(conflicts, addr)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	if len(conflicts) != 0 {
+		sort.Strings(conflicts)
+		return fmt.Errorf("the destination state in to_dir already contains resources to be moved in %v: %v. The migration may have already been applied", toScope, conflicts)
+	}
+	return nil
+}
+
+// checkStateIntegrity returns an error if any resources out of a given scope
+// differ between a state before and after an action.
+// If allowAdded is true, resources which are newly added out of the scope are
+// allowed, and they are logged.
+func checkStateIntegrity(scope []string, before *tfexec.State, after *tfexec.State, allowAdded bool) error {
+	changed, added, err := changedResources(before, after)
+	if err != nil {
+		return err
+	}
+
+	unintended := []string{}
+	for _, addr := range changed {
+		if !inStateScope(addr, scope) {
+			unintended = append(unintended, addr)
+		}
+	}
+	for _, addr := range added {
+		if inStateScope(addr, scope) {
+			continue
+		}
+		if allowAdded {
+			log.Printf("[INFO] [migrator] allow a resource added out of the scope of the action %v: %s\n", scope, addr)
+			continue
+		}
+		unintended = append(unintended, addr)
+	}
+	sort.Strings(unintended)
+	if len(unintended) != 0 {
+		return fmt.Errorf("unintended changes to resources out of the scope of the action %v: %v", scope, unintended)
+	}
+	return nil
+}
+
+// changedResources returns addresses of resources which are removed or
+// changed, and ones which are added between given two states.
+// If either of the states cannot be compared, it returns nil.
+func changedResources(before *tfexec.State, after *tfexec.State) ([]string, []string, error) {
+	beforeDigests, ok, err := stateResourceDigests(before)
+	if err != nil || !ok {
+		return nil, nil, err
+	}
+	afterDigests, ok, err := stateResourceDigests(after)
+	if err != nil || !ok {
+		return nil, nil, err
+	}
+
+	changed := []string{}
+	for addr, b := range beforeDigests {
+		if a, ok := afterDigests[addr]; !ok || a != b {
+			changed = append(changed, addr)
+		}
+	}
+	added := []string{}
+	for addr := range afterDigests {
+		if _, ok := beforeDigests[addr]; !ok {
+			added = append(added, addr)
+		}
+	}
+	sort.Strings(changed)
+	sort.Strings(added)
+	return changed, added, nil
+}
+
+// stateResourceDigests returns a map of digests of normalized resources in a
+// given state keyed by their address. Comparing digests instead of decoded
+// resources keeps memory usage small for a large state.
+// It returns false if the state cannot be compared.
+func stateResourceDigests(state *tfexec.State) (map[string]string, bool, error) {
+	m := make(map[string]string)
+	ok, err := walkStateResources(state, func(r stateResource) error {
+		d, err := normalizedResourceDigest(r)
+		if err != nil {
+			return err
+		}
+		m[r.address()] = d
+		return nil
+	})
+	return m, ok, err
+}
+
+// normalizedResourceDigest returns a digest of a given resource.
+// The resource is decoded to compare it regardless of formatting, and empty
+// values are removed, because terraform may add or omit them when it rewrites
+// a state. Since json.Marshal sorts keys of maps, the digest is stable.
+func normalizedResourceDigest(r stateResource) (string, error) {
+	b, err := json.Marshal(r)
+	if err != nil {
+		return "", err
+	}
+	var v interface{}
+	if err := json.Unmarshal(b, &v); err != nil {
+		return "", err
+	}
+	b, err = json.Marshal(pruneEmptyValues(v))
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(b)
+	return hex.EncodeToString(sum[:]), nil
+}
+
+// pruneEmptyValues removes null, empty arrays and empty objects in objects
+// recursively.
+func pruneEmptyValues(v interface{}) interface{} {
+	switch t := v.(type) {
+	case map[string]interface{}:
+		for k, e := range t {
+			e = pruneEmptyValues(e)
+			if isEmptyValue(e) {
+				delete(t, k)
+				continue
+			}
+			t[k] = e
+		}
+		return t
+	case []interface{}:
+		for i, e := range t {
+			t[i] = pruneEmptyValues(e)
+		}
+		return t
+	default:
+		return v
+	}
+}
+
+// isEmptyValue returns true if a given value is null, an empty array or an
+// empty object.
+func isEmptyValue(v interface{}) bool {
+	switch t := v.(type) {
+	case nil:
+		return true
+	case map[string]interface{}:
+		return len(t) == 0
+	case []interface{}:
+		return len(t) == 0
+	default:
+		return false
+	}
+}
+
+// inStateScope returns true if a given resource address is in a given scope.
+// An address in the scope can be a resource, an instance of a resource or a
+// module containing resources.
+func inStateScope(addr string, scope []string) bool {
+	for _, s := range scope {
+		switch {
+		case addr == s:
+			return true
+		// a resource in a module or an instance of a module
+		case strings.HasPrefix(addr, s+".") || strings.HasPrefix(addr, s+"["):
+			return true
+		// an instance of a resource
+		case strings.HasPrefix(s, addr+"["):
+			return true
+		}
+	}
+	return false
+}
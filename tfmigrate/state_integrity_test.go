@@ -0,0 +1,442 @@
+package tfmigrate
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"runtime"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/minamijoyo/tfmigrate/tfexec"
+)
+
+const integrityTestState = `{
+  "version": 4,
+  "serial": 1,
+  "lineage": "foo",
+  "resources": [
+    {
+      "mode": "managed",
+      "type": "null_resource",
+      "name": "foo",
+      "instances": [{"attributes": {"id": "1"}}]
+    },
+    {
+      "mode": "managed",
+      "type": "null_resource",
+      "name": "bar",
+      "instances": [{"index_key": 0, "attributes": {"id": "2"}}, {"index_key": 1, "attributes": {"id": "3"}}]
+    },
+    {
+      "module": "module.baz[\"a\"]",
+      "mode": "data",
+      "type": "null_data_source",
+      "name": "qux",
+      "instances": [{"attributes": {"id": "4"}}]
+    }
+  ]
+}
+`
+
+func TestStateResourceInstanceAddresses(t *testing.T) {
+	resources, err := parseStateResources(tfexec.NewState([]byte(integrityTestState)))
+	if err != nil {
+		t.Fatalf("failed to parse resources: %s", err)
+	}
+
+	got := []string{}
+	for _, r := range resources {
+		got = append(got, r.instanceAddresses()...)
+	}
+	want := []string{
+		"null_resource.foo",
+		"null_resource.bar[0]",
+		"null_resource.bar[1]",
+		`module.baz["a"].data.null_data_source.qux`,
+	}
+	if diff := cmp.Diff(got, want); diff != "" {
+		t.Errorf("got: %#v, want: %#v, diff: %s", got, want, diff)
+	}
+}
+
+func TestCheckStateIntegrity(t *testing.T) {
+	cases := []struct {
+		desc       string
+		scope      []string
+		before     string
+		after      string
+		allowAdded bool
+		ok         bool
+	}{
+		{
+			desc:   "no changes",
+			scope:  []string{},
+			before: integrityTestState,
+			after:  integrityTestState,
+			ok:     true,
+		},
+		{
+			desc:  "move a resource in scope",
+			scope: []string{"null_resource.foo", "null_resource.foo2"},
+			before: `{"version": 4, "resources": [
+  {"mode": "managed", "type": "null_resource", "name": "foo", "instances": [{"attributes": {"id": "1"}}]},
+  {"mode": "managed", "type": "null_resource", "name": "bar", "instances": [{"attributes": {"id": "2"}}]}
+]}`,
+			after: `{"version": 4, "resources": [
+  {"mode": "managed", "type": "null_resource", "name": "foo2", "instances": [{"attributes": {"id": "1"}}]},
+  {"mode": "managed", "type": "null_resource", "name": "bar", "instances": [{"attributes": {"id": "2"}}]}
+]}`,
+			ok: true,
+		},
+		{
+			desc:  "mutate a resource out of scope",
+			scope: []string{"null_resource.foo", "null_resource.foo2"},
+			before: `{"version": 4, "resources": [
+  {"mode": "managed", "type": "null_resource", "name": "foo", "instances": [{"attributes": {"id": "1"}}]},
+  {"mode": "managed", "type": "null_resource", "name": "bar", "instances": [{"attributes": {"id": "2"}}]}
+]}`,
+			after: `{"version": 4, "resources": [
+  {"mode": "managed", "type": "null_resource", "name": "foo2", "instances": [{"attributes": {"id": "1"}}]},
+  {"mode": "managed", "type": "null_resource", "name": "bar", "instances": [{"attributes": {"id": "3"}}]}
+]}`,
+			ok: false,
+		},
+		{
+			desc:  "remove a resource out of scope",
+			scope: []string{"null_resource.foo"},
+			before: `{"version": 4, "resources": [
+  {"mode": "managed", "type": "null_resource", "name": "foo", "instances": [{"attributes": {"id": "1"}}]},
+  {"mode": "managed", "type": "null_resource", "name": "bar", "instances": [{"attributes": {"id": "2"}}]}
+]}`,
+			after: `{"version": 4, "resources": []}`,
+			ok:    false,
+		},
+		{
+			desc:  "move an instance and a module in scope",
+			scope: []string{"null_resource.bar[1]", "null_resource.bar2", `module.baz["a"]`, "module.baz2"},
+			before: `{"version": 4, "resources": [
+  {"mode": "managed", "type": "null_resource", "name": "bar", "instances": [{"index_key": 0, "attributes": {"id": "2"}}, {"index_key": 1, "attributes": {"id": "3"}}]},
+  {"module": "module.baz[\"a\"]", "mode": "managed", "type": "null_resource", "name": "qux", "instances": [{"attributes": {"id": "4"}}]}
+]}`,
+			after: `{"version": 4, "resources": [
+  {"mode": "managed", "type": "null_resource", "name": "bar", "instances": [{"index_key": 0, "attributes": {"id": "2"}}]},
+  {"mode": "managed", "type": "null_resource", "name": "bar2", "instances": [{"attributes": {"id": "3"}}]},
+  {"module": "module.baz2", "mode": "managed", "type": "null_resource", "name": "qux", "instances": [{"attributes": {"id": "4"}}]}
+]}`,
+			ok: true,
+		},
+		{
+			desc:  "formatting and empty values are ignored",
+			scope: []string{},
+			before: `{"version": 4, "resources": [
+  {"mode": "managed", "type": "null_resource", "name": "foo", "instances": [{"attributes": {"id": "1"}}]}
+]}`,
+			after: `{"version": 4, "resources": [{"name": "foo", "type": "null_resource", "mode": "managed",
+  "instances": [{"attributes": {"id": "1"}, "sensitive_attributes": [], "dependencies": null}]}
+]}`,
+			ok: true,
+		},
+		{
+			desc:   "empty state is not checked",
+			scope:  []string{},
+			before: "",
+			after:  integrityTestState,
+			ok:     true,
+		},
+		{
+			desc:   "add a resource out of scope",
+			scope:  []string{"null_resource.foo"},
+			before: `{"version": 4, "resources": []}`,
+			after: `{"version": 4, "resources": [
+  {"mode": "managed", "type": "null_resource", "name": "foo", "instances": [{"attributes": {"id": "1"}}]},
+  {"mode": "managed", "type": "null_resource", "name": "bar", "instances": [{"attributes": {"id": "2"}}]}
+]}`,
+			allowAdded: false,
+			ok:         false,
+		},
+		{
+			desc:   "add a resource out of scope with allowAdded",
+			scope:  []string{"null_resource.foo"},
+			before: `{"version": 4, "resources": []}`,
+			after: `{"version": 4, "resources": [
+  {"mode": "managed", "type": "null_resource", "name": "foo", "instances": [{"attributes": {"id": "1"}}]},
+  {"mode": "managed", "type": "null_resource", "name": "bar", "instances": [{"attributes": {"id": "2"}}]}
+]}`,
+			allowAdded: true,
+			ok:         true,
+		},
+		{
+			desc:  "mutate a resource out of scope with allowAdded",
+			scope: []string{"null_resource.foo"},
+			before: `{"version": 4, "resources": [
+  {"mode": "managed", "type": "null_resource", "name": "bar", "instances": [{"attributes": {"id": "2"}}]}
+]}`,
+			after: `{"version": 4, "resources": [
+  {"mode": "managed", "type": "null_resource", "name": "foo", "instances": [{"attributes": {"id": "1"}}]},
+  {"mode": "managed", "type": "null_resource", "name": "bar", "instances": [{"attributes": {"id": "3"}}]}
+]}`,
+			allowAdded: true,
+			ok:         false,
+		},
+		{
+			desc:   "invalid resources",
+			scope:  []string{},
+			before: integrityTestState,
+			after:  `{"version": 4, "resources": "foo"}`,
+			ok:     false,
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.desc, func(t *testing.T) {
+			err := checkStateIntegrity(tc.scope, tfexec.NewState([]byte(tc.before)), tfexec.NewState([]byte(tc.after)), tc.allowAdded)
+			if tc.ok && err != nil {
+				t.Fatalf("unexpected err: %s", err)
+			}
+			if !tc.ok && err == nil {
+				t.Fatal("expected to return an error, but no error")
+			}
+		})
+	}
+}
+
+func TestCheckStateActionIntegrityWithImportChildren(t *testing.T) {
+	before := tfexec.NewState([]byte(`{"version": 4, "resources": [
+  {"mode": "managed", "type": "null_resource", "name": "bar", "instances": [{"attributes": {"id": "2"}}]}
+]}`))
+	// Importing a security group also imports its rules as a side effect.
+	after := tfexec.NewState([]byte(`{"version": 4, "resources": [
+  {"mode": "managed", "type": "null_resource", "name": "bar", "instances": [{"attributes": {"id": "2"}}]},
+  {"mode": "managed", "type": "aws_security_group", "name": "foo", "instances": [{"attributes": {"id": "sg-1"}}]},
+  {"mode": "managed", "type": "aws_security_group_rule", "name": "foo", "instances": [{"attributes": {"id": "sgr-1"}}]},
+  {"mode": "managed", "type": "aws_security_group_rule", "name": "foo-1", "instances": [{"attributes": {"id": "sgr-2"}}]}
+]}`))
+
+	cases := []struct {
+		desc   string
+		action StateAction
+		ok     bool
+	}{
+		{
+			desc:   "import",
+			action: NewStateImportAction("aws_security_group.foo", "sg-1"),
+			ok:     true,
+		},
+		{
+			desc: "import batch",
+			action: NewStateImportBatchAction([]StateImportEntry{
+				{Address: "aws_security_group.foo", ID: "sg-1"},
+			}),
+			ok: true,
+		},
+		{
+			desc:   "rm",
+			action: NewStateRmAction([]string{"aws_security_group.foo"}),
+			ok:     false,
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.desc, func(t *testing.T) {
+			err := checkStateActionIntegrity(tc.action, before, after)
+			if tc.ok && err != nil {
+				t.Fatalf("unexpected err: %s", err)
+			}
+			if !tc.ok && err == nil {
+				t.Fatal("expected to return an error, but no error")
+			}
+		})
+	}
+}
+
+func TestStateXmvActionStateScope(t *testing.T) {
+	cases := []struct {
+		desc   string
+		action *StateXmvAction
+		want   []string
+	}{
+		{
+			desc:   "resource",
+			action: NewStateXmvAction("null_resource.*", "module.foo.null_resource.$1"),
+			want: []string{
+				"null_resource.foo",
+				"null_resource.bar[0]",
+				"null_resource.bar[1]",
+				"module.foo.null_resource.foo",
+				"module.foo.null_resource.bar[0]",
+				"module.foo.null_resource.bar[1]",
+			},
+		},
+		{
+			desc:   "no match",
+			action: NewStateXmvAction("aws_instance.*", "aws_instance.$1_new"),
+			want:   []string{},
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.desc, func(t *testing.T) {
+			got, err := tc.action.stateScope(tfexec.NewState([]byte(integrityTestState)))
+			if err != nil {
+				t.Fatalf("unexpected err: %s", err)
+			}
+			if diff := cmp.Diff(got, tc.want); diff != "" {
+				t.Errorf("got: %#v, want: %#v, diff: %s", got, tc.want, diff)
+			}
+		})
+	}
+}
+
+func TestCheckMultiStateDestinationConflicts(t *testing.T) {
+	cases := []struct {
+		desc    string
+		action  MultiStateAction
+		toState string
+		ok      bool
+	}{
+		{
+			desc:    "no conflicts",
+			action:  NewMultiStateMvAction("null_resource.foo", "null_resource.foo"),
+			toState: `{"version": 4, "resources": []}`,
+			ok:      true,
+		},
+		{
+			desc:    "a resource already exists",
+			action:  NewMultiStateMvAction("null_resource.foo", "null_resource.foo"),
+			toState: integrityTestState,
+			ok:      false,
+		},
+		{
+			desc:    "an instance of a resource already exists",
+			action:  NewMultiStateMvAction("null_resource.foo", "null_resource.bar"),
+			toState: integrityTestState,
+			ok:      false,
+		},
+		{
+			desc:    "a different instance",
+			action:  NewMultiStateMvAction("null_resource.foo", "null_resource.bar[2]"),
+			toState: integrityTestState,
+			ok:      true,
+		},
+		{
+			desc:    "a resource in a module already exists",
+			action:  NewMultiStateMvAction("null_resource.foo", `module.baz["a"]`),
+			toState: integrityTestState,
+			ok:      false,
+		},
+		{
+			desc:    "xmv",
+			action:  NewMultiStateXmvAction("null_resource.*", "null_resource.$1"),
+			toState: integrityTestState,
+			ok:      false,
+		},
+		{
+			desc:    "empty",
+			action:  NewMultiStateMvAction("null_resource.foo", "null_resource.foo"),
+			toState: "",
+			ok:      true,
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.desc, func(t *testing.T) {
+			err := checkMultiStateDestinationConflicts(tc.action, tfexec.NewState([]byte(integrityTestState)), tfexec.NewState([]byte(tc.toState)))
+			if tc.ok && err != nil {
+				t.Fatalf("unexpected err: %s", err)
+			}
+			if !tc.ok && err == nil {
+				t.Fatal("expected to return an error, but no error")
+			}
+		})
+	}
+}
+
+// newBenchmarkState returns a content of a large tfstate with a given number
+// of resources, each of which depends on the previous one.
+func newBenchmarkState(n int) []byte {
+	var buf bytes.Buffer
+	buf.WriteString(`{"version": 4, "terraform_version": "1.9.8", "serial": 1, "lineage": "foo", "resources": [`)
+	for i := 0; i < n; i++ {
+		if i > 0 {
+			buf.WriteString(",")
+		}
+		fmt.Fprintf(&buf, `{"mode": "managed", "type": "null_resource", "name": "r%d", "instances": [{"attributes": {"id": "%d", "triggers": {"foo": "%s"}}, "dependencies": ["null_resource.r%d"]}]}`, i, i, strings.Repeat("x", 256), i-1)
+	}
+	buf.WriteString(`]}`)
+	return buf.Bytes()
+}
+
+// BenchmarkStateApplyChecks compares memory usage of the checks run for each
+// action on apply and the normalization before push of a large tfstate held
+// in memory with the one streamed to a temporary file, that is, the
+// stream_state option. Since B/op is the total of allocations, the peak of
+// live heap sampled between steps is also reported as peak-heap-B.
+// Run it with `go test -run ^$ -bench BenchmarkStateApplyChecks ./tfmigrate`.
+func BenchmarkStateApplyChecks(b *testing.B) {
+	content := newBenchmarkState(20000)
+	action := NewStateMvAction("null_resource.r0", "null_resource.s0")
+
+	var peak uint64
+	sample := func() {
+		var m runtime.MemStats
+		runtime.GC()
+		runtime.ReadMemStats(&m)
+		if m.HeapAlloc > peak {
+			peak = m.HeapAlloc
+		}
+	}
+
+	apply := func(b *testing.B, original *tfexec.State, state *tfexec.State) {
+		sample()
+		if err := checkStateActionIntegrity(action, original, state); err != nil {
+			b.Fatalf("unexpected err: %s", err)
+		}
+		sample()
+		if _, _, err := rewriteActionDependencies(action, original, state); err != nil {
+			b.Fatalf("unexpected err: %s", err)
+		}
+		sample()
+		next, err := nextState(original, state)
+		if err != nil {
+			b.Fatalf("unexpected err: %s", err)
+		}
+		sample()
+		if _, err := io.Copy(io.Discard, next.Reader()); err != nil {
+			b.Fatalf("unexpected err: %s", err)
+		}
+		next.Close()
+	}
+
+	b.Run("memory", func(b *testing.B) {
+		peak = 0
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			original := tfexec.NewState(bytes.Clone(content))
+			state := tfexec.NewState(bytes.Clone(content))
+			apply(b, original, state)
+		}
+		b.ReportMetric(float64(peak), "peak-heap-B")
+	})
+
+	b.Run("stream", func(b *testing.B) {
+		peak = 0
+		dir := b.TempDir()
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			original, err := tfexec.NewStateFromReader(dir, bytes.NewReader(content))
+			if err != nil {
+				b.Fatalf("unexpected err: %s", err)
+			}
+			state, err := tfexec.NewStateFromReader(dir, bytes.NewReader(content))
+			if err != nil {
+				b.Fatalf("unexpected err: %s", err)
+			}
+			apply(b, original, state)
+			original.Close()
+			state.Close()
+		}
+		b.ReportMetric(float64(peak), "peak-heap-B")
+	})
+}
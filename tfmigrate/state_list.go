@@ -0,0 +1,140 @@
+package tfmigrate
+
+import (
+	"context"
+	"log"
+	"sort"
+	"sync"
+
+	"github.com/minamijoyo/tfmigrate/tfexec"
+)
+
+// StateListResult is a list of resource addresses in a remote state after
+// a migration has been pushed.
+type StateListResult struct {
+	// Dir is a working directory of the state.
+	Dir string
+	// Workspace is a workspace of the state.
+	Workspace string
+	// Before is a list of addresses in the original state before migration.
+	// It's nil if the original state cannot be parsed, e.g. it's encrypted.
+	Before []string
+	// After is a list of addresses returned by terraform state list after
+	// pushing the new state.
+	After []string
+}
+
+// Added returns addresses which are in the After but not in the Before.
+func (r StateListResult) Added() []string {
+	return subtractAddresses(r.After, r.Before)
+}
+
+// Removed returns addresses which are in the Before but not in the After.
+func (r StateListResult) Removed() []string {
+	return subtractAddresses(r.Before, r.After)
+}
+
+// subtractAddresses returns addresses in a which are not in b.
+func subtractAddresses(a []string, b []string) []string {
+	m := make(map[string]bool, len(b))
+	for _, addr := range b {
+		m[addr] = true
+	}
+	ret := []string{}
+	for _, addr := range a {
+		if !m[addr] {
+			ret = append(ret, addr)
+		}
+	}
+	return ret
+}
+
+// StateListCollector collects StateListResults across migrators.
+// It's intended to be shared via the MigratorOption and read by a runner
+// after each migration to confirm the result.
+type StateListCollector struct {
+	mu       sync.Mutex
+	results  []StateListResult
+	diffOnly bool
+}
+
+// NewStateListCollector returns a new StateListCollector instance.
+// If diffOnly is true, a runner is expected to show only addresses which
+// differ from the original state.
+func NewStateListCollector(diffOnly bool) *StateListCollector {
+	return &StateListCollector{diffOnly: diffOnly}
+}
+
+// DiffOnly returns true if only changed addresses should be shown.
+func (c *StateListCollector) DiffOnly() bool {
+	return c.diffOnly
+}
+
+// Add appends a given StateListResult.
+func (c *StateListCollector) Add(r StateListResult) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.results = append(c.results, r)
+}
+
+// Results returns collected StateListResults.
+func (c *StateListCollector) Results() []StateListResult {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return append([]StateListResult{}, c.results...)
+}
+
+// Reset clears collected StateListResults.
+func (c *StateListCollector) Reset() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.results = nil
+}
+
+// collectStateList is a common helper function to run terraform state list
+// against a remote state after push and add the result to collectors.
+// The addresses before migration are read from a given original state.
+// A nil collector is ignored, and it's a no-op if all collectors are nil.
+// A failure of collecting is logged and ignored, because the list is just
+// informational and the migration has already been applied.
+func collectStateList(ctx context.Context, tf tfexec.TerraformCLI, workspace string, originalState *tfexec.State, cs ...*StateListCollector) {
+	if !anyStateListCollector(cs) {
+		return
+	}
+
+	after, err := tf.StateList(ctx, nil, nil)
+	if err != nil {
+		log.Printf("[WARN] [migrator@%s] failed to collect a state list: %s\n", tf.Dir(), err)
+		return
+	}
+	sort.Strings(after)
+
+	before, err := stateInstanceAddresses(originalState)
+	if err != nil {
+		log.Printf("[WARN] [migrator@%s] failed to parse the original state for a state list: %s\n", tf.Dir(), err)
+	} else if before == nil {
+		log.Printf("[INFO] [migrator@%s] the original state is encrypted, skip comparing a state list\n", tf.Dir())
+	}
+
+	r := StateListResult{
+		Dir:       tf.Dir(),
+		Workspace: workspace,
+		Before:    before,
+		After:     after,
+	}
+	for _, c := range cs {
+		if c != nil {
+			c.Add(r)
+		}
+	}
+}
+
+// anyStateListCollector returns true if any of given collectors is not nil.
+func anyStateListCollector(cs []*StateListCollector) bool {
+	for _, c := range cs {
+		if c != nil {
+			return true
+		}
+	}
+	return false
+}
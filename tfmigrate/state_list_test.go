@@ -0,0 +1,75 @@
+package tfmigrate
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestStateListCollector(t *testing.T) {
+	c := NewStateListCollector(true)
+	if !c.DiffOnly() {
+		t.Errorf("expected to be diff only")
+	}
+	c.Add(StateListResult{Dir: "dir1", Workspace: "default", Before: []string{"null_resource.foo"}, After: []string{"null_resource.bar"}})
+
+	want := []StateListResult{
+		{Dir: "dir1", Workspace: "default", Before: []string{"null_resource.foo"}, After: []string{"null_resource.bar"}},
+	}
+	if diff := cmp.Diff(c.Results(), want); diff != "" {
+		t.Errorf("got: %#v, want: %#v, diff: %s", c.Results(), want, diff)
+	}
+
+	c.Reset()
+	if got := c.Results(); len(got) != 0 {
+		t.Errorf("expected to be reset, but got: %#v", got)
+	}
+}
+
+func TestStateListResultDiff(t *testing.T) {
+	cases := []struct {
+		desc        string
+		result      StateListResult
+		wantAdded   []string
+		wantRemoved []string
+	}{
+		{
+			desc: "moved",
+			result: StateListResult{
+				Before: []string{"null_resource.bar", "null_resource.foo"},
+				After:  []string{"null_resource.bar", "null_resource.foo2"},
+			},
+			wantAdded:   []string{"null_resource.foo2"},
+			wantRemoved: []string{"null_resource.foo"},
+		},
+		{
+			desc: "unchanged",
+			result: StateListResult{
+				Before: []string{"null_resource.foo"},
+				After:  []string{"null_resource.foo"},
+			},
+			wantAdded:   []string{},
+			wantRemoved: []string{},
+		},
+		{
+			desc: "unknown before",
+			result: StateListResult{
+				Before: nil,
+				After:  []string{"null_resource.foo"},
+			},
+			wantAdded:   []string{"null_resource.foo"},
+			wantRemoved: []string{},
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.desc, func(t *testing.T) {
+			if diff := cmp.Diff(tc.result.Added(), tc.wantAdded); diff != "" {
+				t.Errorf("got added: %#v, want: %#v, diff: %s", tc.result.Added(), tc.wantAdded, diff)
+			}
+			if diff := cmp.Diff(tc.result.Removed(), tc.wantRemoved); diff != "" {
+				t.Errorf("got removed: %#v, want: %#v, diff: %s", tc.result.Removed(), tc.wantRemoved, diff)
+			}
+		})
+	}
+}
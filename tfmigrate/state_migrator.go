@@ -5,7 +5,7 @@ import (
 	"errors"
 	"fmt"
 	"log"
-	"os"
+	"regexp"
 
 	"github.com/minamijoyo/tfmigrate/tfexec"
 )
@@ -21,6 +21,7 @@ type StateMigratorConfig struct {
 	// "mv <source> <destination>"
 	// "rm <addresses>...
 	// "import <address> <id>"
+	// "raw <subcommand> <args>..."
 	// We could define strict block schema for action, but intentionally use a
 	// schema-less string to allow us to easily copy terraform state command to
 	// action.
@@ -32,6 +33,38 @@ type StateMigratorConfig struct {
 	SkipPlan bool `hcl:"to_skip_plan,optional"`
 	// Workspace is the state workspace which the migration works with.
 	Workspace string `hcl:"workspace,optional"`
+	// TerraformVersion is a version of terraform used for the migration.
+	// If set, a binary for the version is selected via terraform_version_paths
+	// in the config file or a version manager such as tfenv/tofuenv.
+	TerraformVersion string `hcl:"terraform_version,optional"`
+	// Resumable option allows us to re-run a partially applied migration.
+	// When set skips mv actions which have already been applied, that is,
+	// the source is absent and the destination is present in the state.
+	Resumable bool `hcl:"resumable,optional"`
+	// AllowPlaceholderImportIDs suppresses warnings for import ids which look
+	// like an unsubstituted placeholder such as `${foo}` or `TODO`.
+	// It's intended for providers whose ids legitimately contain them.
+	AllowPlaceholderImportIDs bool `hcl:"allow_placeholder_import_ids,optional"`
+	// Validate runs terraform validate before plan.
+	// If the validate in the config file is true, it's always enabled.
+	Validate bool `hcl:"validate,optional"`
+	// PlanAllowChanges is a list of address patterns of changes allowed in
+	// plan. If all changes in plan match any of them, the plan is treated as
+	// no changes. It's intended for resources which always have a benign diff.
+	// The patterns have the same wildcard grammar as the source of xmv.
+	PlanAllowChanges []string `hcl:"plan_allow_changes,optional"`
+	// AutoRollback removes imported resources from the new state with
+	// terraform state rm if plan shows they would be destroyed or replaced,
+	// which means the import id was wrong.
+	AutoRollback bool `hcl:"auto_rollback,optional"`
+	// VerifyProviders compares providers required by the state with the
+	// dependency lock file and the installed providers after init, and warns
+	// on mismatches before the migration proceeds.
+	VerifyProviders bool `hcl:"verify_providers,optional"`
+	// RewriteDependencies rewrites references to moved resources in the
+	// dependencies of other resources in the new state after mv and xmv
+	// actions, so that the plan for verification is not noisy.
+	RewriteDependencies bool `hcl:"rewrite_dependencies,optional"`
 }
 
 // StateMigratorConfig implements a MigratorConfig.
@@ -40,10 +73,7 @@ var _ MigratorConfig = (*StateMigratorConfig)(nil)
 // NewMigrator returns a new instance of StateMigrator.
 func (c *StateMigratorConfig) NewMigrator(o *MigratorOption) (Migrator, error) {
 	// default working directory
-	dir := "."
-	if len(c.Dir) > 0 {
-		dir = c.Dir
-	}
+	dir := migrationDir(o, c.Dir)
 
 	if len(c.Actions) == 0 {
 		return nil, fmt.Errorf("failed to NewMigrator with no actions")
@@ -57,6 +87,9 @@ func (c *StateMigratorConfig) NewMigrator(o *MigratorOption) (Migrator, error) {
 			return nil, err
 		}
 		actions = append(actions, action)
+		if !c.AllowPlaceholderImportIDs {
+			warnImportIDPlaceholders(action)
+		}
 	}
 
 	//use default workspace if not specified by user
@@ -64,7 +97,67 @@ func (c *StateMigratorConfig) NewMigrator(o *MigratorOption) (Migrator, error) {
 		c.Workspace = "default"
 	}
 
-	return NewStateMigrator(dir, c.Workspace, actions, o, c.Force, c.SkipPlan), nil
+	o, err := withExecPathForDir(o, dir)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(c.TerraformVersion) > 0 {
+		o, err = withTerraformVersion(o, c.TerraformVersion)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if c.Validate {
+		o = withValidate(o)
+	}
+
+	planAllowChanges, err := compilePlanAllowChanges(c.PlanAllowChanges)
+	if err != nil {
+		return nil, fmt.Errorf("invalid plan_allow_changes: %s", err)
+	}
+
+	if c.Resumable {
+		if err := validateResumableActions(actions); err != nil {
+			return nil, err
+		}
+	}
+
+	m := NewStateMigrator(dir, c.Workspace, actions, o, StateMigratorOptions{
+		Force:     c.Force,
+		SkipPlan:  c.SkipPlan,
+		Resumable: c.Resumable,
+	})
+	m.planAllowChanges = planAllowChanges
+	m.autoRollback = c.AutoRollback
+	m.verifyProviders = c.VerifyProviders
+	m.rewriteDependencies = c.RewriteDependencies
+	if o != nil && len(o.Only) > 0 {
+		if m.only, err = compileOnly(o.Only); err != nil {
+			return nil, fmt.Errorf("invalid --only pattern: %s", err)
+		}
+	}
+	return m, nil
+}
+
+// warnImportIDPlaceholders logs a warning if a given action imports a
+// resource with an id which looks like an unsubstituted placeholder.
+// It returns the addresses warned for testing.
+func warnImportIDPlaceholders(action StateAction) []string {
+	a, ok := action.(importEntriesGetter)
+	if !ok {
+		return nil
+	}
+
+	warned := []string{}
+	for _, e := range a.importEntries() {
+		if hasImportIDPlaceholder(e.ID) {
+			log.Printf("[WARN] [migrator] import id for %s looks like an unsubstituted placeholder: %s. Set allow_placeholder_import_ids = true if it's intended\n", e.Address, e.ID)
+			warned = append(warned, e.Address)
+		}
+	}
+	return warned
 }
 
 // StateMigrator implements the Migrator interface.
@@ -82,28 +175,59 @@ type StateMigrator struct {
 	force bool
 	// workspace is the state workspace which the migration works with.
 	workspace string
+	// resumable skips actions which have already been applied.
+	resumable bool
+	// planAllowChanges is a list of address patterns of changes allowed in plan.
+	planAllowChanges []*regexp.Regexp
+	// autoRollback rolls back wrong imports which would be destroyed or
+	// replaced in plan.
+	autoRollback bool
+	// verifyProviders warns on mismatches of providers after init.
+	verifyProviders bool
+	// rewriteDependencies rewrites stale references to moved resources in
+	// the dependencies of other resources after mv and xmv actions.
+	rewriteDependencies bool
+	// only is a list of address patterns to select actions to be applied.
+	// If empty, all actions are applied.
+	only []*regexp.Regexp
+	// skippedScope is a list of addresses of actions skipped by only.
+	// Changes at them are allowed in plan.
+	skippedScope []string
+	// migrated is true if the state already reflects the migration and the
+	// actions are skipped by the --idempotent flag.
+	migrated bool
+	// planResults and stateLists collect a summary of the migration for
+	// the ApplyCallback. They are nil unless the callback is set.
+	planResults *PlanResultCollector
+	stateLists  *StateListCollector
 }
 
 var _ Migrator = (*StateMigrator)(nil)
 
+// StateMigratorOptions is a set of options for a StateMigrator, which are
+// specific to each migration unlike the MigratorOption.
+type StateMigratorOptions struct {
+	// Force applies a migration even if plan shows diff.
+	Force bool
+	// SkipPlan skips running and analyzing Terraform plan.
+	SkipPlan bool
+	// Resumable skips actions which have already been applied.
+	Resumable bool
+}
+
 // NewStateMigrator returns a new StateMigrator instance.
 func NewStateMigrator(dir string, workspace string, actions []StateAction,
-	o *MigratorOption, force bool, skipPlan bool) *StateMigrator {
-	e := tfexec.NewExecutor(dir, os.Environ())
-	tf := tfexec.NewTerraformCLI(e)
-	if o != nil && len(o.ExecPath) > 0 {
-		// While NewTerraformCLI reads the environment variable TFMIGRATE_EXEC_PATH
-		// at initialization, the MigratorOption takes precedence over it.
-		tf.SetExecPath(o.ExecPath)
-	}
+	o *MigratorOption, opts StateMigratorOptions) *StateMigrator {
+	tf := newTerraformCLI(o, dir)
 
 	return &StateMigrator{
 		tf:        tf,
 		actions:   actions,
 		o:         o,
-		force:     force,
-		skipPlan:  skipPlan,
+		force:     opts.Force,
+		skipPlan:  opts.SkipPlan,
 		workspace: workspace,
+		resumable: opts.Resumable,
 	}
 }
 
@@ -111,7 +235,7 @@ func NewStateMigrator(dir string, workspace string, actions []StateAction,
 // It will fail if terraform plan detects any diffs with the new state.
 // We intentionally keep this method private as to not expose internal states and unify
 // the Migrator interface between a single and multi state migrator.
-func (m *StateMigrator) plan(ctx context.Context) (currentState *tfexec.State, err error) {
+func (m *StateMigrator) plan(ctx context.Context) (originalState *tfexec.State, currentState *tfexec.State, err error) {
 	ignoreLegacyStateInitErr := false
 	for _, action := range m.actions {
 		// When invoking `state replace-provider`, it's necessary to first
@@ -122,64 +246,197 @@ func (m *StateMigrator) plan(ctx context.Context) (currentState *tfexec.State, e
 			ignoreLegacyStateInitErr = true
 			break
 		}
+		if a, ok := action.(*StateRawAction); ok && a.replacesProvider() {
+			ignoreLegacyStateInitErr = true
+			break
+		}
 	}
 
 	// setup work dir.
-	currentState, switchBackToRemoteFunc, err := setupWorkDir(ctx, m.tf, m.workspace, m.o.IsBackendTerraformCloud, m.o.BackendConfig, ignoreLegacyStateInitErr)
+	currentState, switchBackToRemoteFunc, err := setupWorkDir(ctx, m.tf, m.workspace, m.o.IsBackendTerraformCloud, m.o.BackendConfig, m.o.initOptions(), ignoreLegacyStateInitErr, m.o.SkipInit)
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
+	originalState = currentState
 
 	// switch back it to remote on exit.
 	defer func() {
 		err = errors.Join(err, switchBackToRemoteFunc())
 	}()
 
-	// computes a new state by applying state migration operations to a temporary state.
-	log.Printf("[INFO] [migrator@%s] compute a new state\n", m.tf.Dir())
-	var newState *tfexec.State
-	for _, action := range m.actions {
-		newState, err = action.StateUpdate(ctx, m.tf, currentState)
+	if m.verifyProviders {
+		if err = warnProviders(m.tf, currentState); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	if m.o.Validate {
+		if err = validateWorkDir(ctx, m.tf); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	m.migrated = false
+	if m.o.Idempotent {
+		m.migrated, err = m.stateMigrated(ctx, currentState)
 		if err != nil {
-			return nil, err
+			return nil, nil, err
 		}
-		currentState = tfexec.NewState(newState.Bytes())
 	}
 
-	// build plan options
-	planOpts := []string{"-input=false", "-no-color", "-detailed-exitcode"}
-	if m.o.PlanOut != "" {
-		planOpts = append(planOpts, "-out="+m.o.PlanOut)
+	if m.migrated {
+		log.Printf("[INFO] [migrator@%s] idempotent: the state already reflects the migration, skip the actions and check diffs with the current state\n", m.tf.Dir())
+	} else {
+		// computes a new state by applying state migration operations to a temporary state.
+		currentState, err = m.applyActions(ctx, currentState)
+		if err != nil {
+			return nil, nil, err
+		}
 	}
 
+	// build plan options
+	planOpts := m.o.planOptions()
+
 	if m.skipPlan {
 		log.Printf("[INFO] [migrator@%s] skipping check diffs\n", m.tf.Dir())
+	} else if m.o.PlanFile != "" {
+		err = verifyPlanFile(ctx, m.tf, currentState, m.o.PlanFile, m.force, m.planAllowChanges)
+		if err != nil {
+			return nil, nil, err
+		}
 	} else {
 		log.Printf("[INFO] [migrator@%s] check diffs\n", m.tf.Dir())
-		_, err = m.tf.Plan(ctx, currentState, planOpts...)
+		var plan *tfexec.Plan
+		plan, err = runPlan(ctx, m.tf, currentState, m.o, planOpts...)
+		collectPlanResult(ctx, m.tf, plan, m.o.PlanResultCollector, m.planResults)
+		if exitErr, ok := err.(tfexec.ExitError); ok && exitErr.ExitCode() == 2 && (len(m.planAllowChanges) > 0 || len(m.skippedScope) > 0) {
+			// ignore diffs if all of them are allowed.
+			disallowed, derr := disallowedPlanChanges(ctx, m.tf, plan, m.planAllowChanges)
+			if derr != nil {
+				return nil, nil, derr
+			}
+			// diffs of actions skipped by --only are expected.
+			disallowed = excludeScope(disallowed, m.skippedScope)
+			if len(disallowed) == 0 {
+				log.Printf("[INFO] [migrator@%s] all diffs are allowed by plan_allow_changes or --only\n", m.tf.Dir())
+				err = nil
+			} else {
+				log.Printf("[ERROR] [migrator@%s] diffs not allowed by plan_allow_changes or --only: %v\n", m.tf.Dir(), disallowed)
+			}
+		}
 		if err != nil {
 			if exitErr, ok := err.(tfexec.ExitError); ok && exitErr.ExitCode() == 2 {
+				if m.autoRollback {
+					rolledBack, removed, rerr := rollbackImports(ctx, m.tf, currentState, plan, m.actions)
+					if rerr != nil {
+						return nil, nil, rerr
+					}
+					if len(removed) > 0 {
+						log.Printf("[WARN] [migrator@%s] auto_rollback: imported resources would be destroyed or replaced, the import id may be wrong. rolled back the import by removing them from the new state: %v\n", m.tf.Dir(), removed)
+						currentState = rolledBack
+					}
+				}
 				if !m.force {
 					log.Printf("[ERROR] [migrator@%s] unexpected diffs\n", m.tf.Dir())
-					return nil, fmt.Errorf("terraform plan command returns unexpected diffs: %s", err)
+					return nil, nil, fmt.Errorf("terraform plan command returns unexpected diffs: %s", err)
 				}
 				log.Printf("[INFO] [migrator@%s] unexpected diffs, ignoring as force option is true: %s", m.tf.Dir(), err)
 				// reset err to nil to intentionally ignore unexpected diffs.
 				err = nil
 			} else {
+				return nil, nil, err
+			}
+		}
+	}
+
+	return originalState, currentState, err
+}
+
+// applyActions computes a new state by applying state migration operations
+// to a given temporary state.
+func (m *StateMigrator) applyActions(ctx context.Context, currentState *tfexec.State) (_ *tfexec.State, err error) {
+	ctx, span := startPhaseSpan(ctx, m.tf, "actions")
+	defer func() { EndSpan(span, err) }()
+
+	log.Printf("[INFO] [migrator@%s] compute a new state\n", m.tf.Dir())
+	m.skippedScope = nil
+	if len(m.only) > 0 {
+		log.Printf("[WARN] [migrator@%s] --only applies the migration partially. Only actions which change addresses matching it are applied\n", m.tf.Dir())
+	}
+	initialState := currentState
+	var stateList []string
+	if m.resumable {
+		// Check each action against the state applied the preceding actions,
+		// because an action may depend on them, such as rm and import the same
+		// address.
+		stateList, err = m.tf.StateList(ctx, currentState, nil)
+		if err != nil {
+			return nil, err
+		}
+	}
+	var newState *tfexec.State
+	for i, action := range m.actions {
+		if len(m.only) > 0 {
+			selected, scope, err := selectedByOnly(action, currentState, m.only)
+			if err != nil {
+				return nil, err
+			}
+			if !selected {
+				log.Printf("[INFO] [migrator@%s] skipping an action not selected by --only: %#v\n", m.tf.Dir(), action)
+				m.skippedScope = append(m.skippedScope, scope...)
+				continue
+			}
+		}
+		if m.resumable {
+			remaining := resumeAction(action, stateList)
+			if remaining == nil {
+				log.Printf("[INFO] [migrator@%s] skipping an already applied action: %#v\n", m.tf.Dir(), action)
+				continue
+			}
+			if remaining != action {
+				log.Printf("[INFO] [migrator@%s] resuming a partially applied action: %#v\n", m.tf.Dir(), remaining)
+			}
+			action = remaining
+		}
+		newState, err = action.StateUpdate(ctx, m.tf, currentState)
+		if err != nil {
+			return nil, &ActionError{Index: i, Err: err}
+		}
+		if err = checkStateActionIntegrity(action, currentState, newState); err != nil {
+			return nil, &ActionError{Index: i, Err: err}
+		}
+		if m.rewriteDependencies {
+			var rewritten []string
+			newState, rewritten, err = rewriteActionDependencies(action, currentState, newState)
+			if err != nil {
+				return nil, fmt.Errorf("failed to rewrite dependencies: %s", err)
+			}
+			for _, r := range rewritten {
+				log.Printf("[INFO] [migrator@%s] rewrite a dependency of %s\n", m.tf.Dir(), r)
+			}
+		}
+		// Release an intermediate state early, which may be backed by a file.
+		// The initial state is owned by the caller.
+		if currentState != initialState && currentState != newState {
+			currentState.Close()
+		}
+		currentState = newState
+		if m.resumable && i < len(m.actions)-1 {
+			stateList, err = m.tf.StateList(ctx, currentState, nil)
+			if err != nil {
 				return nil, err
 			}
 		}
 	}
 
-	return currentState, err
+	return currentState, nil
 }
 
 // Plan computes a new state by applying state migration operations to a temporary state.
 // It will fail if terraform plan detects any diffs with the new state.
 func (m *StateMigrator) Plan(ctx context.Context) error {
 	log.Printf("[INFO] [migrator] start state migrator plan\n")
-	_, err := m.plan(ctx)
+	_, _, err := m.plan(ctx)
 	if err != nil {
 		return err
 	}
@@ -195,18 +452,64 @@ func (m *StateMigrator) Apply(ctx context.Context) error {
 	// Check if a new state does not have any diffs compared to real resources
 	// before push a new state to remote.
 	log.Printf("[INFO] [migrator] start state migrator plan phase for apply\n")
-	state, err := m.plan(ctx)
+	if m.o.ApplyCallback != nil {
+		m.planResults = NewPlanResultCollector()
+		m.stateLists = NewStateListCollector(false)
+	}
+	originalState, state, err := m.plan(ctx)
 	if err != nil {
 		return err
 	}
 
+	if m.o.DryRun && len(m.o.StateOut) > 0 {
+		if err := saveStateOut(m.o.StateOut, originalState, state); err != nil {
+			return err
+		}
+		log.Printf("[INFO] [migrator] dry-run: skip pushing the new state to remote. wrote it to %s\n", m.o.StateOut)
+		log.Printf("[INFO] [migrator] state migrator apply (dry-run) success!\n")
+		return nil
+	}
+
+	if m.o.DryRun {
+		if len(m.o.DryRunDir) == 0 {
+			log.Printf("[INFO] [migrator] dry-run: skip pushing the new state to remote\n")
+			log.Printf("[INFO] [migrator] state migrator apply (dry-run) success!\n")
+			return nil
+		}
+		dir, err := saveDryRunStates(m.o.DryRunDir, map[string]*tfexec.State{"new.tfstate": state})
+		if err != nil {
+			return err
+		}
+		log.Printf("[INFO] [migrator] dry-run: skip pushing the new state to remote. saved it to %s\n", dir)
+		log.Printf("[INFO] [migrator] state migrator apply (dry-run) success!\n")
+		return nil
+	}
+
+	if m.migrated {
+		log.Printf("[INFO] [migrator] idempotent: skip pushing the state because it already reflects the migration\n")
+		log.Printf("[INFO] [migrator] state migrator apply success!\n")
+		notifyApplied(ctx, m.o.ApplyCallback, m.planResults, m.stateLists)
+		return nil
+	}
+
 	// push the new state to remote.
 	log.Printf("[INFO] [migrator] start state migrator apply phase\n")
 	log.Printf("[INFO] [migrator] push the new state to remote\n")
-	err = m.tf.StatePush(ctx, state)
+	state, err = nextState(originalState, state)
+	if err != nil {
+		return err
+	}
+	err = pushState(ctx, m.tf, state)
 	if err != nil {
 		return err
 	}
+	log.Printf("[INFO] [migrator] verify the pushed state\n")
+	err = verifyStatePushed(ctx, m.tf, state)
+	if err != nil {
+		return &PushedError{Err: fmt.Errorf("failed to verify the pushed state in %s: %s", m.tf.Dir(), err)}
+	}
+	collectStateList(ctx, m.tf, m.workspace, originalState, m.o.StateListCollector, m.stateLists)
 	log.Printf("[INFO] [migrator] state migrator apply success!\n")
+	notifyApplied(ctx, m.o.ApplyCallback, m.planResults, m.stateLists)
 	return nil
 }
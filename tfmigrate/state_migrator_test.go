@@ -52,6 +52,65 @@ func TestStateMigratorConfigNewMigrator(t *testing.T) {
 			},
 			ok: true,
 		},
+		{
+			desc: "valid with plan_allow_changes",
+			config: &StateMigratorConfig{
+				Dir: "dir1",
+				Actions: []string{
+					"mv null_resource.foo null_resource.foo2",
+				},
+				PlanAllowChanges: []string{
+					"time_static.*",
+					"module.**.time_static.*",
+				},
+			},
+			o: &MigratorOption{
+				ExecPath: "direnv exec . terraform",
+			},
+			ok: true,
+		},
+		{
+			desc: "valid with auto_rollback",
+			config: &StateMigratorConfig{
+				Dir: "dir1",
+				Actions: []string{
+					"import time_static.qux 2006-01-02T15:04:05Z",
+				},
+				AutoRollback: true,
+			},
+			o: &MigratorOption{
+				ExecPath: "direnv exec . terraform",
+			},
+			ok: true,
+		},
+		{
+			desc: "valid with verify_providers",
+			config: &StateMigratorConfig{
+				Dir: "dir1",
+				Actions: []string{
+					"mv null_resource.foo null_resource.foo2",
+				},
+				VerifyProviders: true,
+			},
+			o: &MigratorOption{
+				ExecPath: "direnv exec . terraform",
+			},
+			ok: true,
+		},
+		{
+			desc: "valid with rewrite_dependencies",
+			config: &StateMigratorConfig{
+				Dir: "dir1",
+				Actions: []string{
+					"mv null_resource.foo null_resource.foo2",
+				},
+				RewriteDependencies: true,
+			},
+			o: &MigratorOption{
+				ExecPath: "direnv exec . terraform",
+			},
+			ok: true,
+		},
 		{
 			desc: "valid in non-default workspace",
 			config: &StateMigratorConfig{
@@ -119,6 +178,105 @@ func TestStateMigratorConfigNewMigrator(t *testing.T) {
 			o:  nil,
 			ok: true,
 		},
+		{
+			desc: "with exec path resolver",
+			config: &StateMigratorConfig{
+				Dir: "legacy/foo",
+				Actions: []string{
+					"mv null_resource.foo null_resource.foo2",
+				},
+			},
+			o: &MigratorOption{
+				ExecPathResolver: &tfexec.ExecPathResolver{
+					Rules: []tfexec.ExecPathRule{
+						{Pattern: "legacy/*", ExecPath: "/opt/terraform/0.12/terraform"},
+					},
+				},
+			},
+			ok: true,
+		},
+		{
+			desc: "with exec path resolver (no match)",
+			config: &StateMigratorConfig{
+				Dir: "modern/foo",
+				Actions: []string{
+					"mv null_resource.foo null_resource.foo2",
+				},
+			},
+			o: &MigratorOption{
+				ExecPathResolver: &tfexec.ExecPathResolver{
+					Rules: []tfexec.ExecPathRule{
+						{Pattern: "legacy/*", ExecPath: "/opt/terraform/0.12/terraform"},
+					},
+				},
+			},
+			ok: false,
+		},
+		{
+			desc: "with resumable true",
+			config: &StateMigratorConfig{
+				Dir: "dir1",
+				Actions: []string{
+					"mv null_resource.foo null_resource.foo2",
+					"mv null_resource.bar null_resource.bar2",
+				},
+				Resumable: true,
+			},
+			o:  nil,
+			ok: true,
+		},
+		{
+			desc: "with resumable true and xmv with expect matches",
+			config: &StateMigratorConfig{
+				Dir: "dir1",
+				Actions: []string{
+					"xmv --expect-matches=2 null_resource.* null_resource.${1}2",
+				},
+				Resumable: true,
+			},
+			o:  nil,
+			ok: false,
+		},
+		{
+			desc: "with terraform_version in terraform_version_paths",
+			config: &StateMigratorConfig{
+				Dir: "dir1",
+				Actions: []string{
+					"mv null_resource.foo null_resource.foo2",
+				},
+				TerraformVersion: "1.5.7",
+			},
+			o: &MigratorOption{
+				TerraformVersionPaths: map[string]string{
+					"1.5.7": "/opt/terraform/1.5.7/terraform",
+				},
+			},
+			ok: true,
+		},
+		{
+			desc: "with validate true",
+			config: &StateMigratorConfig{
+				Dir: "dir1",
+				Actions: []string{
+					"mv null_resource.foo null_resource.foo2",
+				},
+				Validate: true,
+			},
+			o:  nil,
+			ok: true,
+		},
+		{
+			desc: "with terraform_version not installed",
+			config: &StateMigratorConfig{
+				Dir: "dir1",
+				Actions: []string{
+					"mv null_resource.foo null_resource.foo2",
+				},
+				TerraformVersion: "0.0.0-not-installed",
+			},
+			o:  nil,
+			ok: false,
+		},
 	}
 
 	for _, tc := range cases {
@@ -131,7 +289,10 @@ func TestStateMigratorConfigNewMigrator(t *testing.T) {
 				t.Fatalf("expected to return an error, but no error, got: %#v", got)
 			}
 			if tc.ok {
-				_ = got.(*StateMigrator)
+				m := got.(*StateMigrator)
+				if tc.config.Validate && !m.o.Validate {
+					t.Errorf("expected to enable validate, but disabled")
+				}
 			}
 		})
 	}
@@ -181,7 +342,7 @@ resource "time_static" "qux" { triggers = {} }
 	}
 
 	force := false
-	m := NewStateMigrator(tf.Dir(), workspace, actions, &MigratorOption{}, force, false)
+	m := NewStateMigrator(tf.Dir(), workspace, actions, &MigratorOption{}, StateMigratorOptions{Force: force})
 	err = m.Plan(ctx)
 	if err != nil {
 		t.Fatalf("failed to run migrator plan: %s", err)
@@ -251,7 +412,7 @@ resource "null_resource" "bar" {}
 	}
 
 	force := false
-	m := NewStateMigrator(tf.Dir(), workspace, actions, &MigratorOption{}, force, false)
+	m := NewStateMigrator(tf.Dir(), workspace, actions, &MigratorOption{}, StateMigratorOptions{Force: force})
 	err = m.Plan(ctx)
 	if err != nil {
 		t.Fatalf("failed to run migrator plan: %s", err)
@@ -323,7 +484,7 @@ resource "null_resource" "baz" {}
 	o := &MigratorOption{}
 	o.PlanOut = "foo.tfplan"
 	force := true
-	m := NewStateMigrator(tf.Dir(), workspace, actions, o, force, false)
+	m := NewStateMigrator(tf.Dir(), workspace, actions, o, StateMigratorOptions{Force: force})
 	err = m.Plan(ctx)
 	if err != nil {
 		t.Fatalf("failed to run migrator plan: %s", err)
@@ -455,7 +616,7 @@ resource "null_resource" "bar" {}
 
 	force := false
 	skipPlan := true
-	m := NewStateMigrator(tf.Dir(), workspace, actions, &MigratorOption{}, force, skipPlan)
+	m := NewStateMigrator(tf.Dir(), workspace, actions, &MigratorOption{}, StateMigratorOptions{Force: force, SkipPlan: skipPlan})
 	err = m.Plan(ctx)
 	if err != nil {
 		t.Fatalf("failed to run migrator plan: %s", err)
@@ -490,6 +651,180 @@ resource "null_resource" "bar" {}
 	}
 }
 
+func TestAccStateMigratorApplyWithResumable(t *testing.T) {
+	tfexec.SkipUnlessAcceptanceTestEnabled(t)
+
+	backend := tfexec.GetTestAccBackendS3Config(t.Name())
+
+	source := `
+resource "null_resource" "foo" {}
+resource "null_resource" "bar" {}
+`
+
+	workspace := "default"
+	tf := tfexec.SetupTestAccWithApply(t, workspace, backend+source)
+	ctx := context.Background()
+
+	updatedSource := `
+resource "null_resource" "foo2" {}
+resource "null_resource" "bar2" {}
+`
+
+	tfexec.UpdateTestAccSource(t, tf, backend+updatedSource)
+
+	// simulate a partially applied migration.
+	_, _, err := tf.StateMv(ctx, nil, nil, "null_resource.foo", "null_resource.foo2")
+	if err != nil {
+		t.Fatalf("failed to run terraform state mv: %s", err)
+	}
+
+	actions := []StateAction{
+		NewStateMvAction("null_resource.foo", "null_resource.foo2"),
+		NewStateMvAction("null_resource.bar", "null_resource.bar2"),
+	}
+
+	force := false
+	skipPlan := false
+	resumable := true
+	m := NewStateMigrator(tf.Dir(), workspace, actions, &MigratorOption{}, StateMigratorOptions{Force: force, SkipPlan: skipPlan, Resumable: resumable})
+	err = m.Plan(ctx)
+	if err != nil {
+		t.Fatalf("failed to run migrator plan: %s", err)
+	}
+
+	err = m.Apply(ctx)
+	if err != nil {
+		t.Fatalf("failed to run migrator apply: %s", err)
+	}
+
+	got, err := tf.StateList(ctx, nil, nil)
+	if err != nil {
+		t.Fatalf("failed to run terraform state list: %s", err)
+	}
+
+	want := []string{
+		"null_resource.foo2",
+		"null_resource.bar2",
+	}
+	sort.Strings(got)
+	sort.Strings(want)
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got state: %v, want state: %v", got, want)
+	}
+}
+
+func TestAccStateMigratorApplyWithResumableRmAndImport(t *testing.T) {
+	tfexec.SkipUnlessAcceptanceTestEnabled(t)
+
+	backend := tfexec.GetTestAccBackendS3Config(t.Name())
+
+	source := `
+resource "null_resource" "foo" {}
+`
+
+	workspace := "default"
+	tf := tfexec.SetupTestAccWithApply(t, workspace, backend+source)
+	ctx := context.Background()
+
+	// The import must not be skipped as already applied, because the address
+	// is removed by the preceding action.
+	actions := []StateAction{
+		NewStateRmAction([]string{"null_resource.foo"}),
+		NewStateImportAction("null_resource.foo", "1234"),
+	}
+
+	force := false
+	skipPlan := true
+	resumable := true
+	m := NewStateMigrator(tf.Dir(), workspace, actions, &MigratorOption{}, StateMigratorOptions{Force: force, SkipPlan: skipPlan, Resumable: resumable})
+	err := m.Apply(ctx)
+	if err != nil {
+		t.Fatalf("failed to run migrator apply: %s", err)
+	}
+
+	state, err := tf.StatePull(ctx)
+	if err != nil {
+		t.Fatalf("failed to run terraform state pull: %s", err)
+	}
+	if !strings.Contains(string(state.Bytes()), `"id": "1234"`) {
+		t.Errorf("expected null_resource.foo to be imported, but got state: %s", string(state.Bytes()))
+	}
+}
+
+func TestAccStateMigratorApplyWithIdempotent(t *testing.T) {
+	tfexec.SkipUnlessAcceptanceTestEnabled(t)
+
+	backend := tfexec.GetTestAccBackendS3Config(t.Name())
+
+	source := `
+resource "null_resource" "foo" {}
+resource "null_resource" "bar" {}
+`
+
+	workspace := "default"
+	tf := tfexec.SetupTestAccWithApply(t, workspace, backend+source)
+	ctx := context.Background()
+
+	updatedSource := `
+resource "null_resource" "foo2" {}
+resource "null_resource" "bar2" {}
+`
+
+	tfexec.UpdateTestAccSource(t, tf, backend+updatedSource)
+
+	// simulate a migration applied manually.
+	_, _, err := tf.StateMv(ctx, nil, nil, "null_resource.foo", "null_resource.foo2")
+	if err != nil {
+		t.Fatalf("failed to run terraform state mv: %s", err)
+	}
+	_, _, err = tf.StateMv(ctx, nil, nil, "null_resource.bar", "null_resource.bar2")
+	if err != nil {
+		t.Fatalf("failed to run terraform state mv: %s", err)
+	}
+
+	before, err := tf.StatePull(ctx)
+	if err != nil {
+		t.Fatalf("failed to run terraform state pull: %s", err)
+	}
+
+	actions := []StateAction{
+		NewStateMvAction("null_resource.foo", "null_resource.foo2"),
+		NewStateXmvAction("null_resource.bar", "null_resource.bar2"),
+	}
+
+	o := &MigratorOption{Idempotent: true}
+	m := NewStateMigrator(tf.Dir(), workspace, actions, o, StateMigratorOptions{})
+	err = m.Apply(ctx)
+	if err != nil {
+		t.Fatalf("failed to run migrator apply: %s", err)
+	}
+
+	after, err := tf.StatePull(ctx)
+	if err != nil {
+		t.Fatalf("failed to run terraform state pull: %s", err)
+	}
+
+	// nothing should be pushed.
+	beforeMeta, err := before.Meta()
+	if err != nil {
+		t.Fatalf("failed to parse state: %s", err)
+	}
+	afterMeta, err := after.Meta()
+	if err != nil {
+		t.Fatalf("failed to parse state: %s", err)
+	}
+	if afterMeta.Serial != beforeMeta.Serial {
+		t.Errorf("expected the state not to be pushed, but the serial changed from %d to %d", beforeMeta.Serial, afterMeta.Serial)
+	}
+
+	// Without the flag, the migration fails because the sources are absent.
+	m = NewStateMigrator(tf.Dir(), workspace, actions, &MigratorOption{}, StateMigratorOptions{})
+	err = m.Plan(ctx)
+	if err == nil {
+		t.Fatalf("expected to return an error, but no error")
+	}
+}
+
 func TestAccStateMigratorPlanWithSwitchBackToRemoteFuncError(t *testing.T) {
 	tfexec.SkipUnlessAcceptanceTestEnabled(t)
 
@@ -522,7 +857,7 @@ resource "null_resource" "bar" {}
 	}
 
 	force := false
-	m := NewStateMigrator(tf.Dir(), workspace, actions, &MigratorOption{}, force, false)
+	m := NewStateMigrator(tf.Dir(), workspace, actions, &MigratorOption{}, StateMigratorOptions{Force: force})
 
 	err := m.Plan(ctx)
 	if err == nil {
@@ -560,7 +895,7 @@ resource "null_resource" "bar" {}
 	}
 
 	force := false
-	m := NewStateMigrator(tf.Dir(), workspace, actions, &MigratorOption{}, force, false)
+	m := NewStateMigrator(tf.Dir(), workspace, actions, &MigratorOption{}, StateMigratorOptions{Force: force})
 
 	err := m.Plan(ctx)
 	if err == nil {
@@ -573,6 +908,45 @@ resource "null_resource" "bar" {}
 	}
 }
 
+func TestAccStateMigratorPlanWithValidate(t *testing.T) {
+	tfexec.SkipUnlessAcceptanceTestEnabled(t)
+
+	backend := tfexec.GetTestAccBackendS3Config(t.Name())
+
+	source := `
+resource "null_resource" "foo" {}
+`
+
+	workspace := "default"
+	tf := tfexec.SetupTestAccWithApply(t, workspace, backend+source)
+	ctx := context.Background()
+
+	updatedSource := `
+resource "null_resource" "foo2" {
+  foo = "bar"
+}
+`
+
+	tfexec.UpdateTestAccSource(t, tf, backend+updatedSource)
+
+	actions := []StateAction{
+		NewStateMvAction("null_resource.foo", "null_resource.foo2"),
+	}
+
+	o := &MigratorOption{Validate: true}
+	m := NewStateMigrator(tf.Dir(), workspace, actions, o, StateMigratorOptions{})
+
+	err := m.Plan(ctx)
+	if err == nil {
+		t.Fatalf("expected migrator plan error")
+	}
+
+	expected := "is invalid"
+	if !strings.Contains(err.Error(), expected) {
+		t.Fatalf("expected migrator plan error to contain %s, got: %s", expected, err.Error())
+	}
+}
+
 func TestAccStateMigratorPlanWithInvalidMigrationAndSwitchBackToRemoteFuncError(t *testing.T) {
 	tfexec.SkipUnlessAcceptanceTestEnabled(t)
 
@@ -605,7 +979,7 @@ resource "null_resource" "bar" {}
 	}
 
 	force := false
-	m := NewStateMigrator(tf.Dir(), workspace, actions, &MigratorOption{}, force, false)
+	m := NewStateMigrator(tf.Dir(), workspace, actions, &MigratorOption{}, StateMigratorOptions{Force: force})
 
 	err := m.Plan(ctx)
 	if err == nil {
@@ -2,6 +2,7 @@ package tfmigrate
 
 import (
 	"context"
+	"strings"
 
 	"github.com/minamijoyo/tfmigrate/tfexec"
 )
@@ -35,3 +36,21 @@ func (a *StateMvAction) StateUpdate(ctx context.Context, tf tfexec.TerraformCLI,
 	newState, _, err := tf.StateMv(ctx, state, nil, a.source, a.destination, "-backup=/dev/null")
 	return newState, err
 }
+
+// appliedTo returns true if the source is absent and the destination is
+// present in a given state list.
+func (a *StateMvAction) appliedTo(stateList []string) bool {
+	return !containsAddress(stateList, a.source) && containsAddress(stateList, a.destination)
+}
+
+// containsAddress returns true if a given state list contains an address.
+// The address may refer to a module or a resource without an index, so it
+// also matches addresses nested under it.
+func containsAddress(stateList []string, address string) bool {
+	for _, s := range stateList {
+		if s == address || strings.HasPrefix(s, address+".") || strings.HasPrefix(s, address+"[") {
+			return true
+		}
+	}
+	return false
+}
@@ -43,7 +43,7 @@ resource "null_resource" "baz" {}
 		NewStateMvAction("null_resource.bar", "null_resource.bar2"),
 	}
 
-	m := NewStateMigrator(tf.Dir(), workspace, actions, &MigratorOption{}, false, false)
+	m := NewStateMigrator(tf.Dir(), workspace, actions, &MigratorOption{}, StateMigratorOptions{})
 	err = m.Plan(ctx)
 	if err != nil {
 		t.Fatalf("failed to run migrator plan: %s", err)
@@ -54,3 +54,64 @@ resource "null_resource" "baz" {}
 		t.Fatalf("failed to run migrator apply: %s", err)
 	}
 }
+
+func TestStateMvActionAppliedTo(t *testing.T) {
+	cases := []struct {
+		desc      string
+		stateList []string
+		action    *StateMvAction
+		want      bool
+	}{
+		{
+			desc:      "not applied",
+			stateList: []string{"null_resource.foo", "null_resource.bar"},
+			action:    NewStateMvAction("null_resource.foo", "null_resource.foo2"),
+			want:      false,
+		},
+		{
+			desc:      "already applied",
+			stateList: []string{"null_resource.foo2", "null_resource.bar"},
+			action:    NewStateMvAction("null_resource.foo", "null_resource.foo2"),
+			want:      true,
+		},
+		{
+			desc:      "both source and destination are absent",
+			stateList: []string{"null_resource.bar"},
+			action:    NewStateMvAction("null_resource.foo", "null_resource.foo2"),
+			want:      false,
+		},
+		{
+			desc:      "both source and destination are present",
+			stateList: []string{"null_resource.foo", "null_resource.foo2"},
+			action:    NewStateMvAction("null_resource.foo", "null_resource.foo2"),
+			want:      false,
+		},
+		{
+			desc:      "module already applied",
+			stateList: []string{"module.foo2.null_resource.bar", "module.foo2.null_resource.baz"},
+			action:    NewStateMvAction("module.foo", "module.foo2"),
+			want:      true,
+		},
+		{
+			desc:      "resource with count already applied",
+			stateList: []string{"null_resource.foo2[0]", "null_resource.foo2[1]"},
+			action:    NewStateMvAction("null_resource.foo", "null_resource.foo2"),
+			want:      true,
+		},
+		{
+			desc:      "a similar address is not a match",
+			stateList: []string{"module.foobar.null_resource.bar", "module.foo2.null_resource.bar"},
+			action:    NewStateMvAction("module.foo", "module.foo2"),
+			want:      true,
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.desc, func(t *testing.T) {
+			got := tc.action.appliedTo(tc.stateList)
+			if got != tc.want {
+				t.Errorf("got: %t, want: %t", got, tc.want)
+			}
+		})
+	}
+}
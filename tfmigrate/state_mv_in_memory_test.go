@@ -0,0 +1,285 @@
+package tfmigrate
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/minamijoyo/tfmigrate/tfexec"
+)
+
+const testInMemoryState = `{
+  "version": 4,
+  "terraform_version": "1.5.7",
+  "serial": 3,
+  "lineage": "a6dd2e3c-5f2d-4b6f-9d8d-4e3bd9c1fb7c",
+  "outputs": {},
+  "resources": [
+    {
+      "mode": "managed",
+      "type": "null_resource",
+      "name": "foo",
+      "provider": "provider[\"registry.terraform.io/hashicorp/null\"]",
+      "instances": [{"schema_version": 0, "attributes": {"id": "12345678901234567890"}}]
+    },
+    {
+      "mode": "data",
+      "type": "null_data_source",
+      "name": "foo",
+      "provider": "provider[\"registry.terraform.io/hashicorp/null\"]",
+      "instances": []
+    },
+    {
+      "module": "module.a",
+      "mode": "managed",
+      "type": "null_resource",
+      "name": "bar",
+      "provider": "provider[\"registry.terraform.io/hashicorp/null\"]",
+      "instances": []
+    },
+    {
+      "module": "module.a.module.b[\"x\"]",
+      "mode": "managed",
+      "type": "null_resource",
+      "name": "baz",
+      "provider": "provider[\"registry.terraform.io/hashicorp/null\"]",
+      "instances": []
+    },
+    {
+      "module": "module.ab",
+      "mode": "managed",
+      "type": "null_resource",
+      "name": "qux",
+      "provider": "provider[\"registry.terraform.io/hashicorp/null\"]",
+      "instances": []
+    }
+  ]
+}
+`
+
+// testInMemoryAddresses returns a list of resource addresses in a given state.
+func testInMemoryAddresses(t *testing.T, state *tfexec.State) []string {
+	t.Helper()
+	var s struct {
+		Serial    int             `json:"serial"`
+		Resources []stateResource `json:"resources"`
+	}
+	if err := json.Unmarshal(state.Bytes(), &s); err != nil {
+		t.Fatalf("failed to parse state: %s", err)
+	}
+	addrs := []string{}
+	for _, r := range s.Resources {
+		addr := r.getString("type") + "." + r.getString("name")
+		if r.getString("mode") == "data" {
+			addr = "data." + addr
+		}
+		if m := r.getString("module"); m != "" {
+			addr = m + "." + addr
+		}
+		addrs = append(addrs, addr)
+	}
+	return addrs
+}
+
+func TestStateMvInMemory(t *testing.T) {
+	cases := []struct {
+		desc        string
+		source      string
+		destination string
+		want        []string
+		unsupported bool
+		ok          bool
+	}{
+		{
+			desc:        "rename resource",
+			source:      "null_resource.foo",
+			destination: "null_resource.foo2",
+			want: []string{
+				"null_resource.foo2",
+				"data.null_data_source.foo",
+				"module.a.null_resource.bar",
+				"module.a.module.b[\"x\"].null_resource.baz",
+				"module.ab.null_resource.qux",
+			},
+			ok: true,
+		},
+		{
+			desc:        "move resource into module",
+			source:      "null_resource.foo",
+			destination: "module.c[0].null_resource.foo",
+			want: []string{
+				"module.c[0].null_resource.foo",
+				"data.null_data_source.foo",
+				"module.a.null_resource.bar",
+				"module.a.module.b[\"x\"].null_resource.baz",
+				"module.ab.null_resource.qux",
+			},
+			ok: true,
+		},
+		{
+			desc:        "move resource out of module",
+			source:      "module.a.null_resource.bar",
+			destination: "null_resource.bar",
+			want: []string{
+				"null_resource.foo",
+				"data.null_data_source.foo",
+				"null_resource.bar",
+				"module.a.module.b[\"x\"].null_resource.baz",
+				"module.ab.null_resource.qux",
+			},
+			ok: true,
+		},
+		{
+			desc:        "rename data source",
+			source:      "data.null_data_source.foo",
+			destination: "data.null_data_source.foo2",
+			want: []string{
+				"null_resource.foo",
+				"data.null_data_source.foo2",
+				"module.a.null_resource.bar",
+				"module.a.module.b[\"x\"].null_resource.baz",
+				"module.ab.null_resource.qux",
+			},
+			ok: true,
+		},
+		{
+			desc:        "move module with nested modules",
+			source:      "module.a",
+			destination: "module.c[\"y\"]",
+			want: []string{
+				"null_resource.foo",
+				"data.null_data_source.foo",
+				"module.c[\"y\"].null_resource.bar",
+				"module.c[\"y\"].module.b[\"x\"].null_resource.baz",
+				"module.ab.null_resource.qux",
+			},
+			ok: true,
+		},
+		{
+			desc:        "move nested module",
+			source:      "module.a.module.b[\"x\"]",
+			destination: "module.b",
+			want: []string{
+				"null_resource.foo",
+				"data.null_data_source.foo",
+				"module.a.null_resource.bar",
+				"module.b.null_resource.baz",
+				"module.ab.null_resource.qux",
+			},
+			ok: true,
+		},
+		{
+			desc:        "destination resource already exists",
+			source:      "null_resource.foo",
+			destination: "module.a.null_resource.bar",
+			ok:          false,
+		},
+		{
+			desc:        "destination module already exists",
+			source:      "module.a",
+			destination: "module.ab",
+			ok:          false,
+		},
+		{
+			desc:        "source not found",
+			source:      "null_resource.not_found",
+			destination: "null_resource.foo2",
+			ok:          false,
+		},
+		{
+			desc:        "resource types don't match",
+			source:      "null_resource.foo",
+			destination: "null_data_source.foo",
+			ok:          false,
+		},
+		{
+			desc:        "instance key is unsupported",
+			source:      "null_resource.foo",
+			destination: "null_resource.foo[0]",
+			unsupported: true,
+			ok:          false,
+		},
+		{
+			desc:        "module to resource is unsupported",
+			source:      "module.a",
+			destination: "null_resource.bar",
+			unsupported: true,
+			ok:          false,
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.desc, func(t *testing.T) {
+			got, err := stateMvInMemory(tfexec.NewState([]byte(testInMemoryState)), tc.source, tc.destination)
+			if tc.ok && err != nil {
+				t.Fatalf("unexpected err: %s", err)
+			}
+			if !tc.ok && err == nil {
+				t.Fatalf("expected to return an error, but no error, got: %s", string(got.Bytes()))
+			}
+			if errors.Is(err, errUnsupportedInMemoryMv) != tc.unsupported {
+				t.Fatalf("unexpected unsupported err: %v", err)
+			}
+			if tc.ok {
+				if diff := cmp.Diff(testInMemoryAddresses(t, got), tc.want); diff != "" {
+					t.Errorf("got: %v, want: %v, diff: %s", testInMemoryAddresses(t, got), tc.want, diff)
+				}
+			}
+		})
+	}
+}
+
+func TestStateMvInMemoryPreservesState(t *testing.T) {
+	got, err := stateMvInMemory(tfexec.NewState([]byte(testInMemoryState)), "null_resource.foo", "null_resource.foo2")
+	if err != nil {
+		t.Fatalf("unexpected err: %s", err)
+	}
+
+	var s map[string]interface{}
+	d := json.NewDecoder(bytes.NewReader(got.Bytes()))
+	d.UseNumber()
+	if err := d.Decode(&s); err != nil {
+		t.Fatalf("failed to parse state: %s", err)
+	}
+	if s["serial"] != json.Number("4") {
+		t.Errorf("unexpected serial: %v", s["serial"])
+	}
+	if s["lineage"] != "a6dd2e3c-5f2d-4b6f-9d8d-4e3bd9c1fb7c" {
+		t.Errorf("unexpected lineage: %v", s["lineage"])
+	}
+	r := s["resources"].([]interface{})[0].(map[string]interface{})
+	if _, ok := r["module"]; ok {
+		t.Errorf("unexpected module for root module: %v", r["module"])
+	}
+	id := r["instances"].([]interface{})[0].(map[string]interface{})["attributes"].(map[string]interface{})["id"]
+	if id != "12345678901234567890" {
+		t.Errorf("unexpected attributes: %v", id)
+	}
+}
+
+func TestStateMvInMemoryUnsupportedState(t *testing.T) {
+	cases := []struct {
+		desc  string
+		state string
+	}{
+		{
+			desc:  "legacy version",
+			state: `{"version": 3, "serial": 1, "modules": []}`,
+		},
+		{
+			desc:  "encrypted",
+			state: `{"encrypted_data": "xxx"}`,
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.desc, func(t *testing.T) {
+			_, err := stateMvInMemory(tfexec.NewState([]byte(tc.state)), "null_resource.foo", "null_resource.foo2")
+			if !errors.Is(err, errUnsupportedInMemoryMv) {
+				t.Errorf("expected unsupported err, got: %v", err)
+			}
+		})
+	}
+}
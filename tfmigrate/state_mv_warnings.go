@@ -0,0 +1,67 @@
+package tfmigrate
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+
+	"github.com/minamijoyo/tfmigrate/tfexec"
+)
+
+// stateMvWarningsCLI is a TerraformCLI which surfaces warnings reported by
+// terraform state mv, such as a missing provider configuration for a
+// destination module. Otherwise, they're captured and discarded with the
+// output of terraform. It's transparent for the other methods.
+type stateMvWarningsCLI struct {
+	tfexec.TerraformCLI
+	// asErrors fails a move if it reports any warnings not allowed.
+	asErrors bool
+	// allowed is a list of summaries of warnings ignored by asErrors.
+	allowed []string
+}
+
+var _ tfexec.TerraformCLI = (*stateMvWarningsCLI)(nil)
+
+// newStateMvWarningsCLI returns a TerraformCLI which wraps a given one to
+// surface warnings of terraform state mv with settings in a given option.
+func newStateMvWarningsCLI(tf tfexec.TerraformCLI, o *MigratorOption) tfexec.TerraformCLI {
+	c := &stateMvWarningsCLI{TerraformCLI: tf}
+	if o != nil {
+		c.asErrors = o.StateMvWarningsAsErrors
+		c.allowed = o.AllowedWarnings
+	}
+	return c
+}
+
+// stateMvWarningsAsErrors returns true if a given TerraformCLI fails a move
+// which reports warnings.
+func stateMvWarningsAsErrors(tf tfexec.TerraformCLI) bool {
+	c, ok := tf.(*stateMvWarningsCLI)
+	return ok && c.asErrors
+}
+
+// StateMv moves resources from source to destination address.
+// It logs warnings reported by terraform state mv with the move. If the
+// asErrors is true, it returns an error if any warnings are not allowed.
+func (c *stateMvWarningsCLI) StateMv(ctx context.Context, state *tfexec.State, stateOut *tfexec.State, source string, destination string, opts ...string) (*tfexec.State, *tfexec.State, error) {
+	newState, newStateOut, diags, err := c.TerraformCLI.StateMvWithDiagnostics(ctx, state, stateOut, source, destination, opts...)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	for _, d := range diags {
+		if d.Severity != "warning" {
+			continue
+		}
+		log.Printf("[WARN] [migrator@%s] terraform state mv %s %s reported a warning: %s: %s\n", c.Dir(), source, destination, d.Summary, d.Detail)
+	}
+	if !c.asErrors {
+		return newState, newStateOut, nil
+	}
+
+	if warnings := unallowedWarnings(c.Dir(), diags, c.allowed); len(warnings) > 0 {
+		return nil, nil, fmt.Errorf("terraform state mv %s %s reported %d warning(s) in %s, which are treated as errors by state_mv_warnings_as_errors: %s", source, destination, len(warnings), c.Dir(), strings.Join(warnings, ", "))
+	}
+	return newState, newStateOut, nil
+}
@@ -0,0 +1,90 @@
+package tfmigrate
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/minamijoyo/tfmigrate/tfexec"
+)
+
+// fakeStateMvCLI is a TerraformCLI whose terraform state mv reports given
+// diagnostics.
+type fakeStateMvCLI struct {
+	tfexec.TerraformCLI
+	diags []tfexec.Diagnostic
+}
+
+func (c *fakeStateMvCLI) StateMvWithDiagnostics(_ context.Context, state *tfexec.State, stateOut *tfexec.State, _ string, _ string, _ ...string) (*tfexec.State, *tfexec.State, []tfexec.Diagnostic, error) {
+	return state, stateOut, c.diags, nil
+}
+
+func TestStateMvWarningsCLIStateMv(t *testing.T) {
+	warning := tfexec.Diagnostic{Severity: "warning", Summary: "Missing provider configuration"}
+	cases := []struct {
+		desc  string
+		diags []tfexec.Diagnostic
+		o     *MigratorOption
+		want  string
+		ok    bool
+	}{
+		{
+			desc:  "no option",
+			diags: []tfexec.Diagnostic{warning},
+			o:     nil,
+			ok:    true,
+		},
+		{
+			desc:  "warnings are not errors by default",
+			diags: []tfexec.Diagnostic{warning},
+			o:     &MigratorOption{},
+			ok:    true,
+		},
+		{
+			desc:  "no warnings",
+			diags: []tfexec.Diagnostic{},
+			o:     &MigratorOption{StateMvWarningsAsErrors: true},
+			ok:    true,
+		},
+		{
+			desc:  "warnings as errors",
+			diags: []tfexec.Diagnostic{warning},
+			o:     &MigratorOption{StateMvWarningsAsErrors: true},
+			want:  "terraform state mv null_resource.foo module.foo.null_resource.foo reported 1 warning(s)",
+			ok:    false,
+		},
+		{
+			desc:  "allowed warnings",
+			diags: []tfexec.Diagnostic{warning},
+			o: &MigratorOption{
+				StateMvWarningsAsErrors: true,
+				AllowedWarnings:         []string{"Missing provider configuration"},
+			},
+			ok: true,
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.desc, func(t *testing.T) {
+			tf := &fakeStateMvCLI{
+				TerraformCLI: tfexec.NewTerraformCLI(tfexec.NewMockExecutor(nil)),
+				diags:        tc.diags,
+			}
+			state := tfexec.NewState([]byte("dummy state"))
+			cli := newStateMvWarningsCLI(tf, tc.o)
+			got, _, err := cli.StateMv(context.Background(), state, nil, "null_resource.foo", "module.foo.null_resource.foo")
+			if tc.ok && err != nil {
+				t.Fatalf("unexpected err: %s", err)
+			}
+			if !tc.ok && err == nil {
+				t.Fatal("expected to return an error, but no error")
+			}
+			if !tc.ok && !strings.Contains(err.Error(), tc.want) {
+				t.Errorf("got: %s, want: %s", err, tc.want)
+			}
+			if tc.ok && got != state {
+				t.Errorf("got: %#v, want: %#v", got, state)
+			}
+		})
+	}
+}
@@ -0,0 +1,125 @@
+package tfmigrate
+
+import (
+	"context"
+	"fmt"
+	"log"
+
+	"github.com/minamijoyo/tfmigrate/tfexec"
+)
+
+// saveStateOut writes a new state to a given path instead of pushing it to
+// remote. The state is normalized by nextState, so that it can be pushed
+// later by PushStateFile as it is.
+func saveStateOut(path string, original *tfexec.State, state *tfexec.State) error {
+	if state.IsEncrypted() {
+		return fmt.Errorf("refusing to write an encrypted state to %s", path)
+	}
+	state, err := nextState(original, state)
+	if err != nil {
+		return err
+	}
+	if err := writeStateFile(path, state); err != nil {
+		return fmt.Errorf("failed to write the new state: %s", err)
+	}
+	return nil
+}
+
+// PushStateResult is a summary of a state pushed by PushStateFile compared
+// with the current remote state.
+type PushStateResult struct {
+	// Dir is a working directory of the remote state.
+	Dir string
+	// Workspace is a workspace of the remote state.
+	Workspace string
+	// CurrentSerial is a serial of the current remote state.
+	// It's 0 if the remote state is empty.
+	CurrentSerial uint64
+	// Serial is a serial of the state to be pushed.
+	Serial uint64
+	// Added is a list of addresses which are not in the remote state.
+	// It's nil if the states cannot be compared, e.g. they're encrypted.
+	Added []string
+	// Removed is a list of addresses which are only in the remote state.
+	// It's nil if the states cannot be compared, e.g. they're encrypted.
+	Removed []string
+	// Pushed is true if the state has been pushed. It's false on DryRun.
+	Pushed bool
+}
+
+// PushStateFile pushes a given state previously written by the StateOut to
+// the remote state of a given dir and workspace, and returns a summary of it.
+// It fails if the remote state has changed since the state was written, that
+// is, the state is not exactly the next of the current remote state. If force
+// is true, the check is skipped and the state is pushed by force.
+// On DryRun, it only checks the state and returns the summary without pushing.
+func PushStateFile(ctx context.Context, dir string, workspace string, state *tfexec.State, o *MigratorOption, force bool) (*PushStateResult, error) {
+	if o == nil {
+		o = &MigratorOption{}
+	}
+	if state.IsEncrypted() {
+		return nil, fmt.Errorf("refusing to push an encrypted state")
+	}
+	meta, err := state.Meta()
+	if err != nil {
+		return nil, err
+	}
+	dir = migrationDir(o, dir)
+	if len(workspace) == 0 {
+		workspace = "default"
+	}
+	o, err = withExecPathForDir(o, dir)
+	if err != nil {
+		return nil, err
+	}
+	tf := newTerraformCLI(o, dir)
+
+	current, err := pullRemoteState(ctx, tf, workspace, o.initOptions(), o.SkipInit)
+	if err != nil {
+		return nil, err
+	}
+
+	r := &PushStateResult{
+		Dir:       tf.Dir(),
+		Workspace: workspace,
+		Serial:    meta.Serial,
+	}
+	// The remote state is empty if nothing has been applied yet.
+	if current.Size() != 0 {
+		if err := checkNextState(current, state); err != nil {
+			if !force {
+				return nil, fmt.Errorf("refusing to push the state to %s, the remote state may have changed since the state was written: %s", tf.Dir(), err)
+			}
+			log.Printf("[WARN] [migrator@%s] the state is not the next of the remote state, ignoring as force option is true: %s\n", tf.Dir(), err)
+		}
+		currentMeta, err := current.Meta()
+		if err != nil {
+			return nil, err
+		}
+		r.CurrentSerial = currentMeta.Serial
+	}
+	r.Added, r.Removed, err = stateAddressDiff(current, state)
+	if err != nil {
+		return nil, err
+	}
+
+	if o.DryRun {
+		log.Printf("[INFO] [migrator@%s] dry-run: skip pushing the state to remote\n", tf.Dir())
+		return r, nil
+	}
+
+	log.Printf("[INFO] [migrator@%s] push the state to remote\n", tf.Dir())
+	var opts []string
+	if force {
+		opts = append(opts, "-force")
+	}
+	if err := pushState(ctx, tf, state, opts...); err != nil {
+		return nil, err
+	}
+	r.Pushed = true
+	log.Printf("[INFO] [migrator@%s] verify the pushed state\n", tf.Dir())
+	if err := verifyStatePushed(ctx, tf, state); err != nil {
+		return r, fmt.Errorf("failed to verify the pushed state in %s: %s", tf.Dir(), err)
+	}
+	return r, nil
+}
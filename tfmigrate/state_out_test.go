@@ -0,0 +1,169 @@
+package tfmigrate
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"testing"
+
+	"github.com/minamijoyo/tfmigrate/tfexec"
+)
+
+func TestSaveStateOut(t *testing.T) {
+	original := tfexec.NewState([]byte(`{"version": 4, "serial": 3, "lineage": "foo"}`))
+	cases := []struct {
+		desc   string
+		state  *tfexec.State
+		serial uint64
+		ok     bool
+	}{
+		{
+			desc:   "serial is normalized",
+			state:  tfexec.NewState([]byte(`{"version": 4, "serial": 5, "lineage": "foo"}`)),
+			serial: 4,
+			ok:     true,
+		},
+		{
+			desc:  "lineage mismatch",
+			state: tfexec.NewState([]byte(`{"version": 4, "serial": 4, "lineage": "bar"}`)),
+			ok:    false,
+		},
+		{
+			desc:  "encrypted",
+			state: tfexec.NewState([]byte(`{"encrypted_data": "foo"}`)),
+			ok:    false,
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.desc, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "new.tfstate")
+			err := saveStateOut(path, original, tc.state)
+			if tc.ok && err != nil {
+				t.Fatalf("unexpected err: %s", err)
+			}
+			if !tc.ok && err == nil {
+				t.Fatal("expected to return an error, but no error")
+			}
+			if !tc.ok {
+				if _, err := os.Stat(path); err == nil {
+					t.Errorf("expected not to write a state, but found: %s", path)
+				}
+				return
+			}
+
+			b, err := os.ReadFile(path)
+			if err != nil {
+				t.Fatalf("failed to read a written state: %s", err)
+			}
+			meta, err := tfexec.NewState(b).Meta()
+			if err != nil {
+				t.Fatalf("failed to parse a written state: %s", err)
+			}
+			if meta.Serial != tc.serial || meta.Lineage != "foo" {
+				t.Errorf("got: %#v, want serial: %d, lineage: foo", meta, tc.serial)
+			}
+		})
+	}
+}
+
+func TestAccStateMigratorApplyWithStateOutAndPush(t *testing.T) {
+	tfexec.SkipUnlessAcceptanceTestEnabled(t)
+
+	backend := tfexec.GetTestAccBackendS3Config(t.Name())
+
+	source := `
+resource "null_resource" "foo" {}
+resource "null_resource" "bar" {}
+`
+
+	workspace := "default"
+	tf := tfexec.SetupTestAccWithApply(t, workspace, backend+source)
+	ctx := context.Background()
+
+	updatedSource := `
+resource "null_resource" "foo2" {}
+resource "null_resource" "bar" {}
+`
+
+	tfexec.UpdateTestAccSource(t, tf, backend+updatedSource)
+
+	actions := []StateAction{
+		NewStateMvAction("null_resource.foo", "null_resource.foo2"),
+	}
+
+	stateOut := filepath.Join(t.TempDir(), "new.tfstate")
+	o := &MigratorOption{DryRun: true, StateOut: stateOut}
+	m := NewStateMigrator(tf.Dir(), workspace, actions, o, StateMigratorOptions{})
+	err := m.Apply(ctx)
+	if err != nil {
+		t.Fatalf("failed to run migrator apply: %s", err)
+	}
+
+	// The remote state should not be changed yet.
+	got, err := tf.StateList(ctx, nil, nil)
+	if err != nil {
+		t.Fatalf("failed to run terraform state list: %s", err)
+	}
+	want := []string{"null_resource.bar", "null_resource.foo"}
+	sort.Strings(got)
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got state before push: %v, want state: %v", got, want)
+	}
+
+	b, err := os.ReadFile(stateOut)
+	if err != nil {
+		t.Fatalf("failed to read a state file: %s", err)
+	}
+
+	// On dry-run, it only checks the state file.
+	r, err := PushStateFile(ctx, tf.Dir(), workspace, tfexec.NewState(b), &MigratorOption{DryRun: true}, false)
+	if err != nil {
+		t.Fatalf("failed to check a state file: %s", err)
+	}
+	wantResult := &PushStateResult{
+		Dir:           tf.Dir(),
+		Workspace:     workspace,
+		CurrentSerial: r.Serial - 1,
+		Serial:        r.Serial,
+		Added:         []string{"null_resource.foo2"},
+		Removed:       []string{"null_resource.foo"},
+		Pushed:        false,
+	}
+	if !reflect.DeepEqual(r, wantResult) {
+		t.Errorf("got: %#v, want: %#v", r, wantResult)
+	}
+
+	r, err = PushStateFile(ctx, tf.Dir(), workspace, tfexec.NewState(b), &MigratorOption{}, false)
+	if err != nil {
+		t.Fatalf("failed to push a state file: %s", err)
+	}
+	if !r.Pushed {
+		t.Errorf("expected to be pushed, but got: %#v", r)
+	}
+
+	got, err = tf.StateList(ctx, nil, nil)
+	if err != nil {
+		t.Fatalf("failed to run terraform state list: %s", err)
+	}
+	want = []string{"null_resource.bar", "null_resource.foo2"}
+	sort.Strings(got)
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got state after push: %v, want state: %v", got, want)
+	}
+
+	// Pushing the same state again should fail because the remote state has
+	// already been changed.
+	_, err = PushStateFile(ctx, tf.Dir(), workspace, tfexec.NewState(b), &MigratorOption{}, false)
+	if err == nil {
+		t.Fatal("expected to fail to push a stale state file, but no error")
+	}
+
+	// It can be pushed by force.
+	_, err = PushStateFile(ctx, tf.Dir(), workspace, tfexec.NewState(b), &MigratorOption{}, true)
+	if err != nil {
+		t.Fatalf("failed to push a state file by force: %s", err)
+	}
+}
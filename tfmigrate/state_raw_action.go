@@ -0,0 +1,112 @@
+package tfmigrate
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+
+	"github.com/minamijoyo/tfmigrate/tfexec"
+)
+
+// rawActionSubcommands is a list of terraform subcommands allowed in raw action.
+// For safety, they are restricted to subcommands which only update a state.
+var rawActionSubcommands = [][]string{
+	{"state", "mv"},
+	{"state", "rm"},
+	{"state", "replace-provider"},
+	{"taint"},
+	{"untaint"},
+}
+
+// StateRawAction implements the StateAction interface.
+// StateRawAction runs an allowed terraform subcommand with given arguments.
+// It's an escape hatch for state operations which are not yet supported as
+// a first-class action.
+type StateRawAction struct {
+	// subcommand is a terraform subcommand such as `state rm` or `taint`.
+	subcommand []string
+	// args is a list of arguments passed to the subcommand as is.
+	args []string
+}
+
+var _ StateAction = (*StateRawAction)(nil)
+
+// NewStateRawAction returns a new StateRawAction instance.
+func NewStateRawAction(subcommand []string, args []string) *StateRawAction {
+	return &StateRawAction{
+		subcommand: subcommand,
+		args:       args,
+	}
+}
+
+// parseRawActionArgs parses arguments of raw action in the form of
+// `<subcommand> <args>...` and validates the subcommand is allowed.
+func parseRawActionArgs(args []string) ([]string, []string, error) {
+	for _, subcommand := range rawActionSubcommands {
+		if len(args) <= len(subcommand) || strings.Join(args[:len(subcommand)], " ") != strings.Join(subcommand, " ") {
+			continue
+		}
+		rest := args[len(subcommand):]
+		for _, arg := range rest {
+			if isManagedStateOption(arg) {
+				return nil, nil, fmt.Errorf("the -state and -state-out options are not allowed because they are managed by tfmigrate: %s", arg)
+			}
+		}
+		return subcommand, rest, nil
+	}
+
+	allowed := []string{}
+	for _, subcommand := range rawActionSubcommands {
+		allowed = append(allowed, strings.Join(subcommand, " "))
+	}
+	return nil, nil, fmt.Errorf("the subcommand is not allowed or has no arguments, allowed subcommands are %s: %s", strings.Join(allowed, ", "), strings.Join(args, " "))
+}
+
+// isManagedStateOption returns true if a given argument is the -state or
+// -state-out option. Since terraform accepts options with a leading `-` or
+// `--` and a value in both `-name=value` and `-name value` forms, it matches
+// the option name regardless of them.
+func isManagedStateOption(arg string) bool {
+	if !strings.HasPrefix(arg, "-") {
+		return false
+	}
+	name := strings.TrimPrefix(strings.TrimPrefix(arg, "-"), "-")
+	name, _, _ = strings.Cut(name, "=")
+	return name == "state" || name == "state-out"
+}
+
+// replacesProvider returns true if the subcommand is `state replace-provider`.
+func (a *StateRawAction) replacesProvider() bool {
+	return strings.Join(a.subcommand, " ") == "state replace-provider"
+}
+
+// StateUpdate updates a given state and returns a new state.
+// It runs the subcommand against a given state.
+func (a *StateRawAction) StateUpdate(ctx context.Context, tf tfexec.TerraformCLI, state *tfexec.State) (*tfexec.State, error) {
+	opts := []string{}
+	// Disable unnecessary state backup here,
+	// because we never restore state from the backup generated by each state action.
+	if !hasOption(a.args, "-backup") {
+		opts = append(opts, "-backup=/dev/null")
+	}
+	// The state replace-provider command asks for approval, but no one can answer it.
+	if a.replacesProvider() && !hasOption(a.args, "-auto-approve") {
+		opts = append(opts, "-auto-approve")
+	}
+	opts = append(opts, a.args...)
+
+	log.Printf("[INFO] [migrator@%s] run a raw command: %s\n", tf.Dir(), strings.Join(append(append([]string{}, a.subcommand...), opts...), " "))
+	return tf.RunStateCommand(ctx, state, a.subcommand, opts...)
+}
+
+// hasOption returns true if a given option is set in args.
+// The option matches both `-name` and `-name=value` forms.
+func hasOption(args []string, name string) bool {
+	for _, arg := range args {
+		if arg == name || strings.HasPrefix(arg, name+"=") {
+			return true
+		}
+	}
+	return false
+}
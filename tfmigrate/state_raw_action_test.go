@@ -0,0 +1,184 @@
+package tfmigrate
+
+import (
+	"context"
+	"testing"
+
+	"github.com/minamijoyo/tfmigrate/tfexec"
+)
+
+func TestHasOption(t *testing.T) {
+	cases := []struct {
+		desc string
+		args []string
+		name string
+		want bool
+	}{
+		{
+			desc: "flag",
+			args: []string{"-auto-approve", "foo"},
+			name: "-auto-approve",
+			want: true,
+		},
+		{
+			desc: "key=value",
+			args: []string{"-backup=foo.tfstate", "foo"},
+			name: "-backup",
+			want: true,
+		},
+		{
+			desc: "prefix is not matched",
+			args: []string{"-backup-dir=foo", "foo"},
+			name: "-backup",
+			want: false,
+		},
+		{
+			desc: "not found",
+			args: []string{"foo"},
+			name: "-backup",
+			want: false,
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.desc, func(t *testing.T) {
+			got := hasOption(tc.args, tc.name)
+			if got != tc.want {
+				t.Errorf("got: %t, want: %t", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestParseRawActionArgs(t *testing.T) {
+	cases := []struct {
+		desc string
+		args []string
+		ok   bool
+	}{
+		{
+			desc: "simple",
+			args: []string{"state", "rm", "null_resource.foo"},
+			ok:   true,
+		},
+		{
+			desc: "other options",
+			args: []string{"state", "rm", "-backup=foo.tfstate", "-lock=false", "null_resource.foo"},
+			ok:   true,
+		},
+		{
+			desc: "an address starts with state",
+			args: []string{"state", "rm", "state.foo"},
+			ok:   true,
+		},
+		{
+			desc: "-state=path",
+			args: []string{"state", "rm", "-state=foo.tfstate", "null_resource.foo"},
+			ok:   false,
+		},
+		{
+			desc: "-state path",
+			args: []string{"state", "rm", "-state", "foo.tfstate", "null_resource.foo"},
+			ok:   false,
+		},
+		{
+			desc: "--state=path",
+			args: []string{"state", "rm", "--state=foo.tfstate", "null_resource.foo"},
+			ok:   false,
+		},
+		{
+			desc: "--state path",
+			args: []string{"state", "rm", "--state", "foo.tfstate", "null_resource.foo"},
+			ok:   false,
+		},
+		{
+			desc: "-state-out=path",
+			args: []string{"state", "mv", "-state-out=foo.tfstate", "null_resource.foo", "null_resource.bar"},
+			ok:   false,
+		},
+		{
+			desc: "-state-out path",
+			args: []string{"state", "mv", "-state-out", "foo.tfstate", "null_resource.foo", "null_resource.bar"},
+			ok:   false,
+		},
+		{
+			desc: "--state-out=path",
+			args: []string{"state", "mv", "--state-out=foo.tfstate", "null_resource.foo", "null_resource.bar"},
+			ok:   false,
+		},
+		{
+			desc: "--state-out path",
+			args: []string{"state", "mv", "--state-out", "foo.tfstate", "null_resource.foo", "null_resource.bar"},
+			ok:   false,
+		},
+		{
+			desc: "-state for taint",
+			args: []string{"taint", "-state", "foo.tfstate", "null_resource.foo"},
+			ok:   false,
+		},
+		{
+			desc: "not allowed subcommand",
+			args: []string{"import", "null_resource.foo", "foo"},
+			ok:   false,
+		},
+		{
+			desc: "no arguments",
+			args: []string{"state", "rm"},
+			ok:   false,
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.desc, func(t *testing.T) {
+			_, _, err := parseRawActionArgs(tc.args)
+			if tc.ok && err != nil {
+				t.Fatalf("unexpected err: %s", err)
+			}
+			if !tc.ok && err == nil {
+				t.Fatal("expected to return an error, but no error")
+			}
+		})
+	}
+}
+
+func TestAccStateRawAction(t *testing.T) {
+	tfexec.SkipUnlessAcceptanceTestEnabled(t)
+
+	backend := tfexec.GetTestAccBackendS3Config(t.Name())
+
+	source := `
+resource "null_resource" "foo" {}
+resource "null_resource" "bar" {}
+resource "null_resource" "baz" {}
+`
+
+	workspace := "default"
+	tf := tfexec.SetupTestAccWithApply(t, workspace, backend+source)
+	ctx := context.Background()
+
+	updatedSource := `
+resource "null_resource" "foo2" {}
+resource "null_resource" "baz" {}
+`
+
+	tfexec.UpdateTestAccSource(t, tf, backend+updatedSource)
+
+	changed, err := tf.PlanHasChange(ctx, nil)
+	if err != nil {
+		t.Fatalf("failed to run PlanHasChange: %s", err)
+	}
+	if !changed {
+		t.Fatalf("expect to have changes")
+	}
+
+	actions := []StateAction{
+		NewStateRawAction([]string{"state", "mv"}, []string{"null_resource.foo", "null_resource.foo2"}),
+		NewStateRawAction([]string{"state", "rm"}, []string{"null_resource.bar"}),
+	}
+
+	m := NewStateMigrator(tf.Dir(), workspace, actions, &MigratorOption{}, StateMigratorOptions{})
+	err = m.Plan(ctx)
+	if err != nil {
+		t.Fatalf("failed to run migrator plan: %s", err)
+	}
+}
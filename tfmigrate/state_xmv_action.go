@@ -2,6 +2,8 @@ package tfmigrate
 
 import (
 	"context"
+	"errors"
+	"log"
 
 	"github.com/minamijoyo/tfmigrate/tfexec"
 )
@@ -14,6 +16,28 @@ type StateXmvAction struct {
 	source string
 	// destination is a new address of resource or module to move which can contain placeholders.
 	destination string
+	// caseInsensitive matches the source against the state case-insensitively.
+	caseInsensitive bool
+	// excludes is a list of patterns of sources to be skipped.
+	// They have the same wildcard grammar as the source.
+	excludes []string
+	// includeData allows wildcards to match data sources even if the source
+	// doesn't explicitly target them.
+	includeData bool
+	// expectMatches is an expected number of matched sources.
+	// If set, it fails before any move if the number differs. It's nil if not set.
+	expectMatches *int
+	// withinModule is a module address which matched sources must be directly
+	// in. It prevents wildcards from matching resources in the other modules
+	// or its child modules. It's empty if not set.
+	withinModule string
+	// regex interprets the source and excludes as regular expressions, whose
+	// named capture groups can be referenced in the destination.
+	regex bool
+	// singleSegment makes a single wildcard in the source and excludes match
+	// only one segment of the resource path. A deep wildcard is still
+	// available to match across dots.
+	singleSegment bool
 }
 
 var _ StateAction = (*StateXmvAction)(nil)
@@ -29,28 +53,49 @@ func NewStateXmvAction(source string, destination string) *StateXmvAction {
 // StateUpdate updates a given state and returns a new state.
 // Source resources have wildcards which should be matched against the tf state.
 // Each occurrence will generate a move command.
+// To avoid invoking terraform state mv for each of a lot of matched resources,
+// moves of a whole resource or module are applied to the state in memory.
+// Other moves such as a resource instance with an index key fall back to
+// terraform state mv to preserve its semantics. Since moves in memory don't
+// report warnings of terraform, all moves are run by terraform state mv if
+// state_mv_warnings_as_errors is set.
 func (a *StateXmvAction) StateUpdate(ctx context.Context, tf tfexec.TerraformCLI, state *tfexec.State) (*tfexec.State, error) {
 	stateMvActions, err := a.generateMvActions(ctx, tf, state)
 	if err != nil {
 		return nil, err
 	}
 
+	inMemory := !stateMvWarningsAsErrors(tf)
+	if !inMemory {
+		log.Printf("[INFO] [action@%s] run terraform state mv for each move to check warnings\n", tf.Dir())
+	}
 	for _, action := range stateMvActions {
+		if inMemory {
+			newState, err := stateMvInMemory(state, action.source, action.destination)
+			if err == nil {
+				state = newState
+				continue
+			}
+			if !errors.Is(err, errUnsupportedInMemoryMv) {
+				return nil, err
+			}
+			log.Printf("[INFO] [action@%s] fall back to terraform state mv, because the move is not supported in memory: %s %s\n", tf.Dir(), action.source, action.destination)
+		}
 		state, err = action.StateUpdate(ctx, tf, state)
 		if err != nil {
 			return nil, err
 		}
 	}
-	return state, err
+	return state, nil
 }
 
 // generateMvActions uses an xmv and use the state to determine the corresponding mv actions.
 func (a *StateXmvAction) generateMvActions(ctx context.Context, tf tfexec.TerraformCLI, state *tfexec.State) ([]*StateMvAction, error) {
-	stateList, err := tf.StateList(ctx, state, nil)
+	e := newXmvExpander(a)
+	stateList, err := tf.StateList(ctx, state, e.stateListAddresses())
 	if err != nil {
 		return nil, err
 	}
 
-	e := newXmvExpander(a)
 	return e.expand(stateList)
 }
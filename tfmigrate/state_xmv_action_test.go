@@ -2,6 +2,7 @@ package tfmigrate
 
 import (
 	"context"
+	"reflect"
 	"testing"
 
 	"github.com/minamijoyo/tfmigrate/tfexec"
@@ -39,7 +40,7 @@ resource "null_resource" "bar2" {}
 		NewStateXmvAction("null_resource.*", "null_resource.${1}2"),
 	}
 
-	m := NewStateMigrator(tf.Dir(), workspace, actions, &MigratorOption{}, false, false)
+	m := NewStateMigrator(tf.Dir(), workspace, actions, &MigratorOption{}, StateMigratorOptions{})
 	err = m.Plan(ctx)
 	if err != nil {
 		t.Fatalf("failed to run migrator plan: %s", err)
@@ -50,3 +51,90 @@ resource "null_resource" "bar2" {}
 		t.Fatalf("failed to run migrator apply: %s", err)
 	}
 }
+
+// fakeStateXmvCLI is a TerraformCLI which lists given addresses and records
+// moves run by terraform state mv.
+type fakeStateXmvCLI struct {
+	tfexec.TerraformCLI
+	stateList []string
+	moves     []string
+}
+
+func (c *fakeStateXmvCLI) StateList(_ context.Context, _ *tfexec.State, _ []string, _ ...string) ([]string, error) {
+	return c.stateList, nil
+}
+
+func (c *fakeStateXmvCLI) StateMvWithDiagnostics(_ context.Context, state *tfexec.State, stateOut *tfexec.State, source string, destination string, _ ...string) (*tfexec.State, *tfexec.State, []tfexec.Diagnostic, error) {
+	c.moves = append(c.moves, source+" "+destination)
+	return state, stateOut, nil, nil
+}
+
+func TestStateXmvActionStateUpdate(t *testing.T) {
+	cases := []struct {
+		desc        string
+		source      string
+		destination string
+		stateList   []string
+		o           *MigratorOption
+		want        []string
+		ok          bool
+	}{
+		{
+			desc:        "whole resources are moved in memory",
+			source:      "null_resource.*",
+			destination: "module.b.null_resource.$1",
+			stateList:   []string{"null_resource.foo"},
+			o:           &MigratorOption{},
+			want:        nil,
+			ok:          true,
+		},
+		{
+			desc:        "unsupported moves fall back to terraform state mv",
+			source:      "null_resource.*",
+			destination: "null_resource.$1[0]",
+			stateList:   []string{"null_resource.foo"},
+			o:           &MigratorOption{},
+			want:        []string{"null_resource.foo null_resource.foo[0]"},
+			ok:          true,
+		},
+		{
+			desc:        "other errors in memory are returned",
+			source:      "null_resource.*",
+			destination: "module.b.null_resource.$1",
+			stateList:   []string{"null_resource.bar"},
+			o:           &MigratorOption{},
+			want:        nil,
+			ok:          false,
+		},
+		{
+			desc:        "warnings as errors run terraform state mv",
+			source:      "null_resource.*",
+			destination: "module.b.null_resource.$1",
+			stateList:   []string{"null_resource.foo"},
+			o:           &MigratorOption{StateMvWarningsAsErrors: true},
+			want:        []string{"null_resource.foo module.b.null_resource.foo"},
+			ok:          true,
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.desc, func(t *testing.T) {
+			tf := &fakeStateXmvCLI{
+				TerraformCLI: tfexec.NewTerraformCLI(tfexec.NewMockExecutor(nil)),
+				stateList:    tc.stateList,
+			}
+			action := NewStateXmvAction(tc.source, tc.destination)
+			state := tfexec.NewState([]byte(testInMemoryState))
+			_, err := action.StateUpdate(context.Background(), newStateMvWarningsCLI(tf, tc.o), state)
+			if tc.ok && err != nil {
+				t.Fatalf("unexpected err: %s", err)
+			}
+			if !tc.ok && err == nil {
+				t.Fatal("expected to return an error, but no error")
+			}
+			if !reflect.DeepEqual(tf.moves, tc.want) {
+				t.Errorf("got: %#v, want: %#v", tf.moves, tc.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,58 @@
+package tfmigrate
+
+import (
+	"context"
+
+	"github.com/minamijoyo/tfmigrate/tfexec"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// tracerName is the instrumentation scope name of tfmigrate.
+const tracerName = "github.com/minamijoyo/tfmigrate"
+
+// Attribute keys of spans.
+const (
+	// AttrMigrationFile is a path of a migration file.
+	AttrMigrationFile = attribute.Key("tfmigrate.migration.file")
+	// AttrMigrationName is a name of a migration block.
+	AttrMigrationName = attribute.Key("tfmigrate.migration.name")
+	// AttrMigrationType is a type of a migration block.
+	AttrMigrationType = attribute.Key("tfmigrate.migration.type")
+	// AttrDir is a working directory of terraform.
+	AttrDir = attribute.Key("tfmigrate.dir")
+)
+
+// StartSpan starts a new span.
+// If a given context already has a span, the new span is its child and is
+// started by the same TracerProvider. Otherwise, it's started by the Tracer
+// in a given option. If the option has no Tracer, the global TracerProvider
+// of OpenTelemetry is used, which is a no-op unless it's set by an embedder.
+func StartSpan(ctx context.Context, o *MigratorOption, name string, attrs ...attribute.KeyValue) (context.Context, trace.Span) {
+	var tracer trace.Tracer
+	if parent := trace.SpanFromContext(ctx); parent.SpanContext().IsValid() {
+		tracer = parent.TracerProvider().Tracer(tracerName)
+	} else if o != nil && o.Tracer != nil {
+		tracer = o.Tracer
+	} else {
+		tracer = otel.Tracer(tracerName)
+	}
+	return tracer.Start(ctx, name, trace.WithAttributes(attrs...))
+}
+
+// EndSpan records a given error to a given span if any, and ends the span.
+func EndSpan(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
+
+// startPhaseSpan starts a child span for a phase of a migration in the work
+// dir of a given terraform CLI.
+func startPhaseSpan(ctx context.Context, tf tfexec.TerraformCLI, phase string) (context.Context, trace.Span) {
+	return StartSpan(ctx, nil, phase, AttrDir.String(tf.Dir()))
+}
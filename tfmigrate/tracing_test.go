@@ -0,0 +1,148 @@
+package tfmigrate
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/minamijoyo/tfmigrate/tfexec"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+	"go.opentelemetry.io/otel/trace/embedded"
+	"go.opentelemetry.io/otel/trace/noop"
+)
+
+// recordedSpan is a span recorded by a recordingTracerProvider.
+type recordedSpan struct {
+	name   string
+	parent string
+	attrs  map[string]string
+	status codes.Code
+	ended  bool
+}
+
+// recordingTracerProvider is a TracerProvider for testing which records
+// started spans.
+type recordingTracerProvider struct {
+	embedded.TracerProvider
+
+	mu    sync.Mutex
+	spans []*recordedSpan
+}
+
+func (p *recordingTracerProvider) Tracer(string, ...trace.TracerOption) trace.Tracer {
+	return &recordingTracer{provider: p}
+}
+
+// names returns names of recorded spans in the form of `parent/name`.
+func (p *recordingTracerProvider) names() []string {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	names := []string{}
+	for _, s := range p.spans {
+		names = append(names, s.parent+"/"+s.name)
+	}
+	return names
+}
+
+type recordingTracer struct {
+	embedded.Tracer
+
+	provider *recordingTracerProvider
+}
+
+func (t *recordingTracer) Start(ctx context.Context, name string, opts ...trace.SpanStartOption) (context.Context, trace.Span) {
+	cfg := trace.NewSpanStartConfig(opts...)
+	attrs := map[string]string{}
+	for _, kv := range cfg.Attributes() {
+		attrs[string(kv.Key)] = kv.Value.Emit()
+	}
+	parent := ""
+	if p, ok := trace.SpanFromContext(ctx).(*recordingSpan); ok {
+		parent = p.record.name
+	}
+
+	t.provider.mu.Lock()
+	defer t.provider.mu.Unlock()
+	r := &recordedSpan{name: name, parent: parent, attrs: attrs}
+	t.provider.spans = append(t.provider.spans, r)
+	// A valid span context is required to be a parent.
+	sc := trace.NewSpanContext(trace.SpanContextConfig{
+		TraceID: trace.TraceID{1},
+		SpanID:  trace.SpanID{byte(len(t.provider.spans))},
+	})
+	span := &recordingSpan{Span: noop.Span{}, sc: sc, provider: t.provider, record: r}
+	return trace.ContextWithSpan(ctx, span), span
+}
+
+type recordingSpan struct {
+	noop.Span
+
+	sc       trace.SpanContext
+	provider *recordingTracerProvider
+	record   *recordedSpan
+}
+
+func (s *recordingSpan) SpanContext() trace.SpanContext { return s.sc }
+
+func (s *recordingSpan) TracerProvider() trace.TracerProvider { return s.provider }
+
+func (s *recordingSpan) SetStatus(code codes.Code, _ string) { s.record.status = code }
+
+func (s *recordingSpan) End(...trace.SpanEndOption) { s.record.ended = true }
+
+func TestStartSpan(t *testing.T) {
+	provider := &recordingTracerProvider{}
+	o := &MigratorOption{
+		Tracer: provider.Tracer(tracerName),
+	}
+
+	ctx, root := StartSpan(context.Background(), o, "tfmigrate apply")
+	ctx, migration := StartSpan(ctx, nil, "migration", AttrMigrationName.String("foo"))
+	tf := tfexec.NewTerraformCLI(tfexec.NewMockExecutor(nil))
+	_, phase := startPhaseSpan(ctx, tf, "init")
+	EndSpan(phase, fmt.Errorf("failed to init"))
+	EndSpan(migration, nil)
+	EndSpan(root, nil)
+
+	want := []string{"/tfmigrate apply", "tfmigrate apply/migration", "migration/init"}
+	got := provider.names()
+	if diff := cmp.Diff(got, want); diff != "" {
+		t.Errorf("got: %v, want: %v, diff: %s", got, want, diff)
+	}
+
+	for _, s := range provider.spans {
+		if !s.ended {
+			t.Errorf("span %s is not ended", s.name)
+		}
+	}
+	if got := provider.spans[1].attrs[string(AttrMigrationName)]; got != "foo" {
+		t.Errorf("got migration name: %s, want: foo", got)
+	}
+	if _, ok := provider.spans[2].attrs[string(AttrDir)]; !ok {
+		t.Errorf("phase span has no dir attribute: %v", provider.spans[2].attrs)
+	}
+	if got := provider.spans[2].status; got != codes.Error {
+		t.Errorf("got status: %v, want: %v", got, codes.Error)
+	}
+	if got := provider.spans[1].status; got != codes.Unset {
+		t.Errorf("got status: %v, want: %v", got, codes.Unset)
+	}
+}
+
+func TestStartSpanNoop(t *testing.T) {
+	// It should be a no-op without any tracer.
+	ctx, span := StartSpan(context.Background(), nil, "tfmigrate apply", attribute.String("foo", "bar"))
+	if span.IsRecording() {
+		t.Error("expected a no-op span, but recording")
+	}
+	_, child := startPhaseSpan(ctx, tfexec.NewTerraformCLI(tfexec.NewMockExecutor(nil)), "init")
+	if child.IsRecording() {
+		t.Error("expected a no-op span, but recording")
+	}
+	EndSpan(child, nil)
+	EndSpan(span, nil)
+}
@@ -0,0 +1,232 @@
+package tfmigrate
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/minamijoyo/tfmigrate/tfexec"
+)
+
+// Verifier is implemented by migrators which can re-validate an applied
+// migration against the current remote state. It's read-only and never
+// pushes any state.
+type Verifier interface {
+	// Verify returns a result of comparing the migration with the current
+	// remote state. Given migrators applied after the migration in order are
+	// replayed, so that a destination moved again by them is followed.
+	Verify(ctx context.Context, later []Migrator) (*VerifyResult, error)
+}
+
+// VerifyResult is a result of Verify.
+type VerifyResult struct {
+	// Discrepancies is a list of discrepancies between the migration and the
+	// current remote state, such as a moved resource which doesn't exist at
+	// its destination any more. It's empty if the state is consistent.
+	Discrepancies []string
+	// Unchecked is a list of actions or destinations which cannot be checked,
+	// such as an xmv action, or a destination moved to another state or
+	// removed by a later migration. They are not discrepancies.
+	Unchecked []string
+}
+
+var _ Verifier = (*StateMigrator)(nil)
+var _ Verifier = (*MultiStateMigrator)(nil)
+
+// Verify implements the Verifier interface.
+// Only mv actions are checked, that is, the destination is present in the
+// current state. The xmv actions are reported as unchecked, because their
+// sources cannot be expanded once applied. The other actions are ignored.
+func (m *StateMigrator) Verify(ctx context.Context, later []Migrator) (*VerifyResult, error) {
+	stateList, err := pullStateList(ctx, m.tf, m.workspace, m.o.initOptions(), m.o.SkipInit)
+	if err != nil {
+		return nil, err
+	}
+
+	destinations := []string{}
+	unchecked := []string{}
+	for _, action := range m.actions {
+		switch a := action.(type) {
+		case *StateMvAction:
+			destinations = append(destinations, a.destination)
+		case *StateXmvAction:
+			unchecked = append(unchecked, uncheckedXmv(m.tf.Dir(), a.source, a.destination))
+		}
+	}
+	return verifyDestinations(m.tf.Dir(), m.workspace, stateList, destinations, unchecked, later), nil
+}
+
+// Verify implements the Verifier interface.
+// Only mv actions are checked, that is, the destination is present in the
+// current state of the toDir. The xmv actions are reported as unchecked, and
+// the other actions are ignored.
+func (m *MultiStateMigrator) Verify(ctx context.Context, later []Migrator) (*VerifyResult, error) {
+	stateList, err := pullStateList(ctx, m.toTf, m.toWorkspace, m.o.initOptions(), m.o.SkipInit)
+	if err != nil {
+		return nil, err
+	}
+
+	destinations := []string{}
+	unchecked := []string{}
+	for _, action := range m.actions {
+		switch a := action.(type) {
+		case *MultiStateMvAction:
+			destinations = append(destinations, a.destination)
+		case *MultiStateXmvAction:
+			unchecked = append(unchecked, uncheckedXmv(m.toTf.Dir(), a.source, a.destination))
+		}
+	}
+	return verifyDestinations(m.toTf.Dir(), m.toWorkspace, stateList, destinations, unchecked, later), nil
+}
+
+// uncheckedXmv returns a message of an xmv action which cannot be checked.
+func uncheckedXmv(dir string, source string, destination string) string {
+	log.Printf("[INFO] [migrator@%s] the xmv action is not checked: %s %s\n", dir, source, destination)
+	return fmt.Sprintf("%s: the xmv action is not checked, because its sources cannot be expanded once applied: xmv %s %s", dir, source, destination)
+}
+
+// verifyDestinations returns a result of checking destinations of mv actions
+// in a given state list of a dir and workspace. A destination moved again by
+// given later migrators is followed, and one moved to another state or
+// removed by them is reported as unchecked.
+func verifyDestinations(dir string, workspace string, stateList []string, destinations []string, unchecked []string, later []Migrator) *VerifyResult {
+	current := []string{}
+	for _, d := range destinations {
+		addr, ok := followAddress(dir, workspace, d, later)
+		if !ok {
+			log.Printf("[INFO] [migrator@%s] the destination of mv has been superseded by a later migration: %s\n", dir, d)
+			unchecked = append(unchecked, fmt.Sprintf("%s: the destination of mv has been moved to another state or removed by a later migration: %s", dir, d))
+			continue
+		}
+		if addr != d {
+			log.Printf("[INFO] [migrator@%s] the destination of mv has been moved by a later migration: %s => %s\n", dir, d, addr)
+		}
+		current = append(current, addr)
+	}
+	return &VerifyResult{
+		Discrepancies: missingDestinations(dir, stateList, current),
+		Unchecked:     unchecked,
+	}
+}
+
+// followAddress replays given later migrators which apply to a state of a
+// given dir and workspace, and returns an address which a given address has
+// been moved to by their mv and xmv actions. It returns false if the address
+// has been moved to another state or removed by them, that is, superseded.
+func followAddress(dir string, workspace string, address string, later []Migrator) (string, bool) {
+	for _, migrator := range later {
+		switch m := migrator.(type) {
+		case *StateMigrator:
+			if !sameState(m.tf.Dir(), m.workspace, dir, workspace) {
+				continue
+			}
+			for _, action := range m.actions {
+				switch a := action.(type) {
+				case *StateMvAction:
+					address = movedAddress(address, a.source, a.destination)
+				case *StateXmvAction:
+					if dst, ok := replayXmv(a, address); ok {
+						address = dst
+					}
+				case *StateRmAction:
+					for _, rm := range a.addresses {
+						if containsAddress([]string{address}, rm) {
+							return "", false
+						}
+					}
+				}
+			}
+
+		case *MultiStateMigrator:
+			if !sameState(m.fromTf.Dir(), m.fromWorkspace, dir, workspace) {
+				continue
+			}
+			for _, action := range m.actions {
+				switch a := action.(type) {
+				case *MultiStateMvAction:
+					if containsAddress([]string{address}, a.source) {
+						return "", false
+					}
+				case *MultiStateXmvAction:
+					if _, ok := replayXmv(a.toStateXmvAction(), address); ok {
+						return "", false
+					}
+				}
+			}
+		}
+	}
+	return address, true
+}
+
+// sameState returns true if given dirs and workspaces refer to the same state.
+func sameState(dir1 string, workspace1 string, dir2 string, workspace2 string) bool {
+	return filepath.Clean(dir1) == filepath.Clean(dir2) && workspace1 == workspace2
+}
+
+// movedAddress returns an address which a given address is moved to by a move
+// from a given source to a given destination. If the address is the source
+// or in the source, the prefix is replaced. Otherwise, it returns the address
+// as it is.
+func movedAddress(address string, source string, destination string) string {
+	if address == source {
+		return destination
+	}
+	if strings.HasPrefix(address, source+".") || strings.HasPrefix(address, source+"[") {
+		return destination + strings.TrimPrefix(address, source)
+	}
+	return address
+}
+
+// replayXmv returns a destination which a given address is moved to by a
+// given xmv action. It returns false if the address doesn't match the source.
+// The expected number of matches is ignored, because only a single address is
+// matched here.
+func replayXmv(a *StateXmvAction, address string) (string, bool) {
+	x := *a
+	x.expectMatches = nil
+	moves, err := newXmvExpander(&x).expand([]string{address})
+	if err != nil {
+		return "", false
+	}
+	for _, mv := range moves {
+		if mv.source == address {
+			return mv.destination, true
+		}
+	}
+	return "", false
+}
+
+// pullStateList is a helper function to initialize the work dir and returns a
+// list of addresses in the current remote state of a given workspace.
+// Unlike setupWorkDir, it doesn't override the backend because nothing is
+// pushed.
+func pullStateList(ctx context.Context, tf tfexec.TerraformCLI, workspace string, initOpts []string, skipInit bool) ([]string, error) {
+	state, err := pullRemoteState(ctx, tf, workspace, initOpts, skipInit)
+	if err != nil {
+		return nil, err
+	}
+
+	log.Printf("[INFO] [migrator@%s] list addresses in the current remote state\n", tf.Dir())
+	stateList, err := tf.StateList(ctx, state, nil)
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(stateList)
+	return stateList, nil
+}
+
+// missingDestinations returns discrepancies for destinations of mv actions
+// which are not present in a given state list of a dir.
+func missingDestinations(dir string, stateList []string, destinations []string) []string {
+	ret := []string{}
+	for _, d := range destinations {
+		if !containsAddress(stateList, d) {
+			log.Printf("[WARN] [migrator@%s] the destination of mv is not found in the current state: %s\n", dir, d)
+			ret = append(ret, fmt.Sprintf("%s: the destination of mv is not found in the current state: %s", dir, d))
+		}
+	}
+	return ret
+}
@@ -0,0 +1,59 @@
+package tfmigrate
+
+import (
+	"fmt"
+	"log"
+
+	"github.com/minamijoyo/tfmigrate/tfexec"
+)
+
+// verifyProviders compares providers required by a given state with the
+// dependency lock file and the installed providers in the work dir after
+// init, and returns warnings on mismatches. It catches environment drift,
+// which causes a confusing plan even if init succeeds.
+// Note that the state records only source addresses of providers, not their
+// versions, so the versions are compared between the lock file and the
+// installed providers.
+func verifyProviders(dir string, state *tfexec.State) ([]string, error) {
+	required, err := state.ProviderAddresses()
+	if err != nil {
+		return nil, err
+	}
+	if len(required) == 0 {
+		return nil, nil
+	}
+
+	locked, err := tfexec.LockedProviders(dir)
+	if err != nil {
+		return nil, err
+	}
+	if locked == nil {
+		return []string{"no dependency lock file found"}, nil
+	}
+
+	warnings := []string{}
+	for _, addr := range required {
+		version, ok := locked[addr]
+		if !ok {
+			warnings = append(warnings, fmt.Sprintf("provider %s is required by the state, but not locked in the dependency lock file", addr))
+			continue
+		}
+		if !tfexec.IsProviderInstalled(dir, addr, version) {
+			warnings = append(warnings, fmt.Sprintf("provider %s is locked to %s, but the version is not installed", addr, version))
+		}
+	}
+	return warnings, nil
+}
+
+// warnProviders logs warnings of verifyProviders for a given work dir.
+func warnProviders(tf tfexec.TerraformCLI, state *tfexec.State) error {
+	log.Printf("[INFO] [migrator@%s] verify providers\n", tf.Dir())
+	warnings, err := verifyProviders(tf.Dir(), state)
+	if err != nil {
+		return err
+	}
+	for _, w := range warnings {
+		log.Printf("[WARN] [migrator@%s] verify_providers: %s\n", tf.Dir(), w)
+	}
+	return nil
+}
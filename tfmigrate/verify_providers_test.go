@@ -0,0 +1,129 @@
+package tfmigrate
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/minamijoyo/tfmigrate/tfexec"
+)
+
+func TestVerifyProviders(t *testing.T) {
+	state := `{
+  "version": 4,
+  "resources": [
+    {"mode": "managed", "type": "time_static", "name": "foo", "provider": "provider[\"registry.terraform.io/hashicorp/time\"]"},
+    {"mode": "managed", "type": "null_resource", "name": "foo", "provider": "provider[\"registry.terraform.io/hashicorp/null\"]"}
+  ]
+}`
+	lock := `
+provider "registry.terraform.io/hashicorp/null" {
+  version = "3.2.2"
+}
+
+provider "registry.terraform.io/hashicorp/time" {
+  version = "0.11.1"
+}
+`
+
+	cases := []struct {
+		desc      string
+		state     string
+		lock      string
+		installed []string
+		want      []string
+		ok        bool
+	}{
+		{
+			desc:  "all installed",
+			state: state,
+			lock:  lock,
+			installed: []string{
+				"registry.terraform.io/hashicorp/null/3.2.2",
+				"registry.terraform.io/hashicorp/time/0.11.1",
+			},
+			want: []string{},
+			ok:   true,
+		},
+		{
+			desc:  "version mismatch",
+			state: state,
+			lock:  lock,
+			installed: []string{
+				"registry.terraform.io/hashicorp/null/3.2.1",
+				"registry.terraform.io/hashicorp/time/0.11.1",
+			},
+			want: []string{
+				"provider registry.terraform.io/hashicorp/null is locked to 3.2.2, but the version is not installed",
+			},
+			ok: true,
+		},
+		{
+			desc:  "not locked",
+			state: state,
+			lock: `
+provider "registry.terraform.io/hashicorp/null" {
+  version = "3.2.2"
+}
+`,
+			installed: []string{
+				"registry.terraform.io/hashicorp/null/3.2.2",
+			},
+			want: []string{
+				"provider registry.terraform.io/hashicorp/time is required by the state, but not locked in the dependency lock file",
+			},
+			ok: true,
+		},
+		{
+			desc:  "no lock file",
+			state: state,
+			want: []string{
+				"no dependency lock file found",
+			},
+			ok: true,
+		},
+		{
+			desc:  "empty state",
+			state: `{"version": 4, "resources": []}`,
+			want:  nil,
+			ok:    true,
+		},
+		{
+			desc:  "invalid state",
+			state: `foo`,
+			want:  nil,
+			ok:    false,
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.desc, func(t *testing.T) {
+			t.Setenv("TF_DATA_DIR", "")
+			dir := t.TempDir()
+			if len(tc.lock) > 0 {
+				if err := os.WriteFile(filepath.Join(dir, ".terraform.lock.hcl"), []byte(tc.lock), 0600); err != nil {
+					t.Fatalf("failed to write the lock file: %s", err)
+				}
+			}
+			for _, p := range tc.installed {
+				if err := os.MkdirAll(filepath.Join(dir, ".terraform", "providers", filepath.FromSlash(p)), 0700); err != nil {
+					t.Fatalf("failed to create a provider dir: %s", err)
+				}
+			}
+
+			got, err := verifyProviders(dir, tfexec.NewState([]byte(tc.state)))
+			if tc.ok && err != nil {
+				t.Fatalf("unexpected err: %s", err)
+			}
+			if !tc.ok && err == nil {
+				t.Fatalf("expected to return an error, but no error, got: %#v", got)
+			}
+			if tc.ok {
+				if diff := cmp.Diff(got, tc.want); diff != "" {
+					t.Errorf("got: %#v, want: %#v, diff: %s", got, tc.want, diff)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,160 @@
+package tfmigrate
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestMissingDestinations(t *testing.T) {
+	stateList := []string{
+		"aws_security_group.foo2",
+		"aws_instance.bar[0]",
+		"module.baz.aws_s3_bucket.qux",
+	}
+
+	cases := []struct {
+		desc         string
+		destinations []string
+		want         []string
+	}{
+		{
+			desc:         "all present",
+			destinations: []string{"aws_security_group.foo2", "aws_instance.bar", "module.baz"},
+			want:         []string{},
+		},
+		{
+			desc:         "some missing",
+			destinations: []string{"aws_security_group.foo2", "aws_security_group.bar2", "module.qux"},
+			want: []string{
+				"dir1: the destination of mv is not found in the current state: aws_security_group.bar2",
+				"dir1: the destination of mv is not found in the current state: module.qux",
+			},
+		},
+		{
+			desc:         "no destinations",
+			destinations: []string{},
+			want:         []string{},
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.desc, func(t *testing.T) {
+			got := missingDestinations("dir1", stateList, tc.destinations)
+			if !reflect.DeepEqual(got, tc.want) {
+				t.Errorf("got: %#v, want: %#v", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestVerifyDestinations(t *testing.T) {
+	stateList := []string{
+		"aws_security_group.baz",
+		"module.app.aws_instance.foo",
+	}
+	o := &MigratorOption{}
+
+	cases := []struct {
+		desc          string
+		destinations  []string
+		later         []Migrator
+		discrepancies []string
+		unchecked     []string
+	}{
+		{
+			desc:         "no later migrations",
+			destinations: []string{"aws_security_group.bar"},
+			later:        []Migrator{},
+			discrepancies: []string{
+				"dir1: the destination of mv is not found in the current state: aws_security_group.bar",
+			},
+			unchecked: []string{},
+		},
+		{
+			desc:         "moved again by a later mv",
+			destinations: []string{"aws_security_group.bar"},
+			later: []Migrator{
+				NewStateMigrator("dir1", "default", []StateAction{
+					NewStateMvAction("aws_security_group.bar", "aws_security_group.baz"),
+				}, o, StateMigratorOptions{}),
+			},
+			discrepancies: []string{},
+			unchecked:     []string{},
+		},
+		{
+			desc:         "moved again by a later xmv",
+			destinations: []string{"aws_instance.foo"},
+			later: []Migrator{
+				NewStateMigrator("dir1", "default", []StateAction{
+					NewStateXmvAction("aws_instance.*", "module.app.aws_instance.$1"),
+				}, o, StateMigratorOptions{}),
+			},
+			discrepancies: []string{},
+			unchecked:     []string{},
+		},
+		{
+			desc:         "moved again into a module which is missing",
+			destinations: []string{"aws_security_group.bar"},
+			later: []Migrator{
+				NewStateMigrator("dir1", "default", []StateAction{
+					NewStateMvAction("aws_security_group.bar", "module.qux.aws_security_group.bar"),
+				}, o, StateMigratorOptions{}),
+			},
+			discrepancies: []string{
+				"dir1: the destination of mv is not found in the current state: module.qux.aws_security_group.bar",
+			},
+			unchecked: []string{},
+		},
+		{
+			desc:         "moved in another workspace",
+			destinations: []string{"aws_security_group.bar"},
+			later: []Migrator{
+				NewStateMigrator("dir1", "prod", []StateAction{
+					NewStateMvAction("aws_security_group.bar", "aws_security_group.baz"),
+				}, o, StateMigratorOptions{}),
+			},
+			discrepancies: []string{
+				"dir1: the destination of mv is not found in the current state: aws_security_group.bar",
+			},
+			unchecked: []string{},
+		},
+		{
+			desc:         "removed by a later rm",
+			destinations: []string{"aws_security_group.bar"},
+			later: []Migrator{
+				NewStateMigrator("dir1", "default", []StateAction{
+					NewStateRmAction([]string{"aws_security_group.bar"}),
+				}, o, StateMigratorOptions{}),
+			},
+			discrepancies: []string{},
+			unchecked: []string{
+				"dir1: the destination of mv has been moved to another state or removed by a later migration: aws_security_group.bar",
+			},
+		},
+		{
+			desc:         "moved to another state",
+			destinations: []string{"aws_security_group.bar"},
+			later: []Migrator{
+				NewMultiStateMigrator("dir1", "dir2", "default", "default", []MultiStateAction{
+					NewMultiStateMvAction("aws_security_group.bar", "aws_security_group.bar"),
+				}, o, false, false, false),
+			},
+			discrepancies: []string{},
+			unchecked: []string{
+				"dir1: the destination of mv has been moved to another state or removed by a later migration: aws_security_group.bar",
+			},
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.desc, func(t *testing.T) {
+			got := verifyDestinations("dir1", "default", stateList, tc.destinations, []string{}, tc.later)
+			if !reflect.DeepEqual(got.Discrepancies, tc.discrepancies) {
+				t.Errorf("got discrepancies: %#v, want: %#v", got.Discrepancies, tc.discrepancies)
+			}
+			if !reflect.DeepEqual(got.Unchecked, tc.unchecked) {
+				t.Errorf("got unchecked: %#v, want: %#v", got.Unchecked, tc.unchecked)
+			}
+		})
+	}
+}
@@ -0,0 +1,95 @@
+package tfmigrate
+
+import (
+	"fmt"
+	"os"
+	"strings"
+)
+
+// keysFileFlagPrefix is a prefix of an optional flag of ximport action which
+// reads keys from a given file in addition to the ones in arguments.
+const keysFileFlagPrefix = "--keys-file="
+
+// parseXimportArgs parses arguments of ximport action in the form of
+// `[--keys-file=<path>] <address> <id> [<key>...]` and returns import entries
+// expanded over the keys.
+func parseXimportArgs(args []string) ([]StateImportEntry, error) {
+	keys := []string{}
+	for len(args) > 0 && strings.HasPrefix(args[0], "--") {
+		if !strings.HasPrefix(args[0], keysFileFlagPrefix) || len(args[0]) == len(keysFileFlagPrefix) {
+			return nil, fmt.Errorf("unknown flag: %s", args[0])
+		}
+		fileKeys, err := readKeysFile(strings.TrimPrefix(args[0], keysFileFlagPrefix))
+		if err != nil {
+			return nil, err
+		}
+		keys = append(keys, fileKeys...)
+		args = args[1:]
+	}
+	if len(args) < 2 {
+		return nil, fmt.Errorf("an address and an id are required")
+	}
+	keys = append(keys, args[2:]...)
+	return expandImports(args[0], args[1], keys)
+}
+
+// readKeysFile reads keys from a given file, one per line.
+// Leading and trailing spaces are trimmed, and empty lines and lines starting
+// with `#` are ignored.
+func readKeysFile(filename string) ([]string, error) {
+	b, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read keys file: %s", err)
+	}
+
+	keys := []string{}
+	for _, line := range strings.Split(string(b), "\n") {
+		key := strings.TrimSpace(line)
+		if len(key) == 0 || strings.HasPrefix(key, "#") {
+			continue
+		}
+		keys = append(keys, key)
+	}
+	return keys, nil
+}
+
+// expandImports expands a given address with a wildcard over given keys and
+// returns import entries. The address must contain exactly one wildcardChar,
+// which is replaced with each key. The id is a template in the same syntax
+// as the destination of xmv, that is, the key can be referenced by `$1`.
+func expandImports(address string, id string, keys []string) ([]StateImportEntry, error) {
+	if strings.Count(address, wildcardChar) != 1 {
+		return nil, fmt.Errorf("address must contain exactly one wildcard: %s", address)
+	}
+	if len(keys) == 0 {
+		return nil, fmt.Errorf("no keys are given for %s", address)
+	}
+
+	re, err := makeSrcRegex(address, false, false)
+	if err != nil {
+		return nil, err
+	}
+
+	seen := map[string]bool{}
+	entries := make([]StateImportEntry, 0, len(keys))
+	for _, key := range keys {
+		if seen[key] {
+			return nil, fmt.Errorf("duplicate key: %s", key)
+		}
+		seen[key] = true
+
+		addr := strings.Replace(address, wildcardChar, key, 1)
+		if err := validateImportAddress(addr); err != nil {
+			return nil, err
+		}
+		e := StateImportEntry{
+			Address: addr,
+			ID:      re.ReplaceAllString(addr, id),
+		}
+		if err := validateImportID(e.Address, e.ID); err != nil {
+			return nil, err
+		}
+		entries = append(entries, e)
+	}
+	return entries, nil
+}
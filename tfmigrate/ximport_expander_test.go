@@ -0,0 +1,196 @@
+package tfmigrate
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestExpandImports(t *testing.T) {
+	cases := []struct {
+		desc    string
+		address string
+		id      string
+		keys    []string
+		want    []StateImportEntry
+		ok      bool
+	}{
+		{
+			desc:    "resource name",
+			address: "aws_iam_role.*",
+			id:      "$1",
+			keys:    []string{"foo", "bar"},
+			want: []StateImportEntry{
+				{Address: "aws_iam_role.foo", ID: "foo"},
+				{Address: "aws_iam_role.bar", ID: "bar"},
+			},
+			ok: true,
+		},
+		{
+			desc:    "for_each key with id template",
+			address: `module.iam.aws_iam_role.this["*"]`,
+			id:      "arn:aws:iam::123456789012:role/${1}-role",
+			keys:    []string{"foo", "bar"},
+			want: []StateImportEntry{
+				{Address: `module.iam.aws_iam_role.this["foo"]`, ID: "arn:aws:iam::123456789012:role/foo-role"},
+				{Address: `module.iam.aws_iam_role.this["bar"]`, ID: "arn:aws:iam::123456789012:role/bar-role"},
+			},
+			ok: true,
+		},
+		{
+			desc:    "constant id",
+			address: "aws_iam_role.*",
+			id:      "foo",
+			keys:    []string{"foo"},
+			want: []StateImportEntry{
+				{Address: "aws_iam_role.foo", ID: "foo"},
+			},
+			ok: true,
+		},
+		{
+			desc:    "no wildcard",
+			address: "aws_iam_role.foo",
+			id:      "$1",
+			keys:    []string{"foo"},
+			want:    nil,
+			ok:      false,
+		},
+		{
+			desc:    "multiple wildcards",
+			address: "module.*.aws_iam_role.*",
+			id:      "$1",
+			keys:    []string{"foo"},
+			want:    nil,
+			ok:      false,
+		},
+		{
+			desc:    "no keys",
+			address: "aws_iam_role.*",
+			id:      "$1",
+			keys:    []string{},
+			want:    nil,
+			ok:      false,
+		},
+		{
+			desc:    "duplicate keys",
+			address: "aws_iam_role.*",
+			id:      "$1",
+			keys:    []string{"foo", "foo"},
+			want:    nil,
+			ok:      false,
+		},
+		{
+			desc:    "invalid address",
+			address: "aws_iam_role*",
+			id:      "$1",
+			keys:    []string{"foo"},
+			want:    nil,
+			ok:      false,
+		},
+		{
+			desc:    "empty id",
+			address: "aws_iam_role.*",
+			id:      "${2}",
+			keys:    []string{"foo"},
+			want:    nil,
+			ok:      false,
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.desc, func(t *testing.T) {
+			got, err := expandImports(tc.address, tc.id, tc.keys)
+			if tc.ok && err != nil {
+				t.Fatalf("unexpected err: %s", err)
+			}
+			if !tc.ok && err == nil {
+				t.Fatalf("expected to return an error, but no error, got: %#v", got)
+			}
+			if tc.ok {
+				if diff := cmp.Diff(got, tc.want); diff != "" {
+					t.Errorf("got: %#v, want: %#v, diff: %s", got, tc.want, diff)
+				}
+			}
+		})
+	}
+}
+
+func TestParseXimportArgs(t *testing.T) {
+	keysFile := filepath.Join(t.TempDir(), "keys.txt")
+	if err := os.WriteFile(keysFile, []byte("# roles\nfoo\n\n  bar  \n"), 0600); err != nil {
+		t.Fatalf("failed to write keys file: %s", err)
+	}
+
+	cases := []struct {
+		desc string
+		args []string
+		want []StateImportEntry
+		ok   bool
+	}{
+		{
+			desc: "keys in args",
+			args: []string{"aws_iam_role.*", "$1", "foo", "bar"},
+			want: []StateImportEntry{
+				{Address: "aws_iam_role.foo", ID: "foo"},
+				{Address: "aws_iam_role.bar", ID: "bar"},
+			},
+			ok: true,
+		},
+		{
+			desc: "keys file",
+			args: []string{"--keys-file=" + keysFile, "aws_iam_role.*", "$1"},
+			want: []StateImportEntry{
+				{Address: "aws_iam_role.foo", ID: "foo"},
+				{Address: "aws_iam_role.bar", ID: "bar"},
+			},
+			ok: true,
+		},
+		{
+			desc: "keys file and args",
+			args: []string{"--keys-file=" + keysFile, "aws_iam_role.*", "$1", "baz"},
+			want: []StateImportEntry{
+				{Address: "aws_iam_role.foo", ID: "foo"},
+				{Address: "aws_iam_role.bar", ID: "bar"},
+				{Address: "aws_iam_role.baz", ID: "baz"},
+			},
+			ok: true,
+		},
+		{
+			desc: "keys file not found",
+			args: []string{"--keys-file=" + filepath.Join(t.TempDir(), "not_found"), "aws_iam_role.*", "$1"},
+			want: nil,
+			ok:   false,
+		},
+		{
+			desc: "empty keys file flag",
+			args: []string{"--keys-file=", "aws_iam_role.*", "$1", "foo"},
+			want: nil,
+			ok:   false,
+		},
+		{
+			desc: "no id",
+			args: []string{"aws_iam_role.*"},
+			want: nil,
+			ok:   false,
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.desc, func(t *testing.T) {
+			got, err := parseXimportArgs(tc.args)
+			if tc.ok && err != nil {
+				t.Fatalf("unexpected err: %s", err)
+			}
+			if !tc.ok && err == nil {
+				t.Fatalf("expected to return an error, but no error, got: %#v", got)
+			}
+			if tc.ok {
+				if diff := cmp.Diff(got, tc.want); diff != "" {
+					t.Errorf("got: %#v, want: %#v, diff: %s", got, tc.want, diff)
+				}
+			}
+		})
+	}
+}
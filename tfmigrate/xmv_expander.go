@@ -2,7 +2,9 @@ package tfmigrate
 
 import (
 	"fmt"
+	"log"
 	"regexp"
+	"strconv"
 	"strings"
 )
 
@@ -20,21 +22,272 @@ func newXmvExpander(action *StateXmvAction) *xmvExpander {
 }
 
 // A wildcardChar will greedy match with any character in the resource path.
+// Note that it can also match across dots for backward compatibility.
 const matchWildcardRegex = "(.*)"
 const wildcardChar = "*"
 
+// With the singleSegmentFlag, a wildcardChar matches a single segment of the
+// resource path, that is, it doesn't match across dots except for dots in
+// index keys.
+// (e.g.) `aws_instance.*` matches `aws_instance.foo` and `aws_instance.foo["a.b"]`,
+// but not `aws_instance.foo.bar`.
+// Use a deepWildcardToken to match across dots.
+const matchSegmentWildcardRegex = `((?:[^.\[]|\[[^\]]*\])*)`
+
+// With the singleSegmentFlag, a wildcardChar in an index key matches any
+// character in the brackets.
+// (e.g.) `module.foo["*"]` matches `module.foo["a.b"]`.
+const matchIndexWildcardRegex = `([^\]]*)`
+
+// caseInsensitiveFlag is an optional flag of xmv action which matches the
+// source against the state case-insensitively.
+const caseInsensitiveFlag = "--case-insensitive"
+
+// excludeFlagPrefix is a prefix of an optional flag of xmv action which skips
+// sources matching a given pattern. It can be specified multiple times.
+const excludeFlagPrefix = "--exclude="
+
+// includeDataFlag is an optional flag of xmv action which allows wildcards to
+// match data sources even if the source doesn't explicitly target them.
+const includeDataFlag = "--include-data"
+
+// expectMatchesFlagPrefix is a prefix of an optional flag of xmv action which
+// fails if the number of matched sources differs from a given number.
+const expectMatchesFlagPrefix = "--expect-matches="
+
+// withinModuleFlagPrefix is a prefix of an optional flag of xmv action which
+// restricts matched sources to resources directly in a given module.
+const withinModuleFlagPrefix = "--within-module="
+
+// regexFlag is an optional flag of xmv action which interprets the source
+// and exclude patterns as regular expressions instead of wildcards.
+const regexFlag = "--regex"
+
+// singleSegmentFlag is an optional flag of xmv action which makes a single
+// wildcard in the source and exclude patterns match only one segment of the
+// resource path.
+const singleSegmentFlag = "--single-segment"
+
+// xmvFlags is a set of optional flags of xmv action.
+type xmvFlags struct {
+	// caseInsensitive matches the source against the state case-insensitively.
+	caseInsensitive bool
+	// excludes is a list of patterns of sources to be skipped.
+	excludes []string
+	// includeData allows wildcards to match data sources.
+	includeData bool
+	// expectMatches is an expected number of matched sources.
+	// It's nil if not set.
+	expectMatches *int
+	// withinModule is a module address which matched sources must be
+	// directly in. It's empty if not set.
+	withinModule string
+	// regex interprets the source and exclude patterns as regular
+	// expressions.
+	regex bool
+	// singleSegment makes a single wildcard match only one segment.
+	singleSegment bool
+}
+
+// parseXmvArgs parses arguments of xmv action in the form of
+// `[--case-insensitive] [--include-data] [--exclude=<pattern>]... [--expect-matches=<n>] [--within-module=<module>] [--regex] [--single-segment] <source> <destination>`.
+// The flags can be specified in any order before the source.
+// It returns false if the arguments are invalid.
+func parseXmvArgs(args []string) (source string, destination string, flags xmvFlags, ok bool) {
+	for len(args) > 0 && strings.HasPrefix(args[0], "--") {
+		switch {
+		case args[0] == caseInsensitiveFlag:
+			flags.caseInsensitive = true
+		case args[0] == includeDataFlag:
+			flags.includeData = true
+		case args[0] == regexFlag:
+			flags.regex = true
+		case args[0] == singleSegmentFlag:
+			flags.singleSegment = true
+		case strings.HasPrefix(args[0], excludeFlagPrefix) && len(args[0]) > len(excludeFlagPrefix):
+			flags.excludes = append(flags.excludes, strings.TrimPrefix(args[0], excludeFlagPrefix))
+		case strings.HasPrefix(args[0], expectMatchesFlagPrefix):
+			n, err := strconv.Atoi(strings.TrimPrefix(args[0], expectMatchesFlagPrefix))
+			if err != nil || n < 0 {
+				return "", "", xmvFlags{}, false
+			}
+			flags.expectMatches = &n
+		case strings.HasPrefix(args[0], withinModuleFlagPrefix):
+			module := strings.TrimPrefix(args[0], withinModuleFlagPrefix)
+			if !moduleAddressRegex.MatchString(module) {
+				return "", "", xmvFlags{}, false
+			}
+			flags.withinModule = module
+		default:
+			return "", "", xmvFlags{}, false
+		}
+		args = args[1:]
+	}
+	if len(args) != 2 {
+		return "", "", xmvFlags{}, false
+	}
+	return args[0], args[1], flags, true
+}
+
+// A deepWildcardToken matches any depth of module nesting, that is, it
+// explicitly matches across dots.
+// (e.g.) `module.**.aws_instance.*` matches resources at any nesting depth.
+// It's a distinct token from wildcardChar, but it is also captured as a single
+// group and can be referenced in the destination by its ordinal number.
+const matchDeepWildcardRegex = "(.*)"
+const deepWildcardToken = "**"
+
 // makeSourceMatchPattern returns regex pattern that matches the wildcard
 // source and make sure characters are not treated as special meta characters.
-func makeSourceMatchPattern(s string) string {
-	safeString := regexp.QuoteMeta(s)
-	quotedWildCardChar := regexp.QuoteMeta(wildcardChar)
-	return strings.ReplaceAll(safeString, quotedWildCardChar, matchWildcardRegex)
+// The pattern is anchored to the start and end so that it only matches whole
+// addresses in the state list, not a part of a longer address.
+// If singleSegment is true, a single wildcard matches only one segment.
+func makeSourceMatchPattern(s string, singleSegment bool) string {
+	literals, wildcards := splitSourcePattern(s, singleSegment)
+	var b strings.Builder
+	b.WriteString("^")
+	for i, literal := range literals {
+		b.WriteString(regexp.QuoteMeta(literal))
+		if i < len(wildcards) {
+			b.WriteString(wildcards[i])
+		}
+	}
+	b.WriteString("$")
+	return b.String()
+}
+
+// splitSourcePattern splits the wildcard source into literal parts and regex
+// patterns of wildcards between them, that is, the number of literals is
+// always one more than the number of wildcards.
+// (e.g.) `module.*.aws_instance.*_old` => [`module.`, `.aws_instance.`, `_old`], [`(.*)`, `(.*)`]
+// If singleSegment is true, a single wildcard matches only one segment.
+func splitSourcePattern(s string, singleSegment bool) (literals []string, wildcards []string) {
+	var b strings.Builder
+	inIndex := false
+	for i := 0; i < len(s); i++ {
+		var wildcard string
+		switch {
+		// Check the deep wildcard token first, because it also contains the
+		// single wildcardChar.
+		case strings.HasPrefix(s[i:], deepWildcardToken):
+			wildcard = matchDeepWildcardRegex
+			i += len(deepWildcardToken) - 1
+		case strings.HasPrefix(s[i:], wildcardChar) && !singleSegment:
+			wildcard = matchWildcardRegex
+		case strings.HasPrefix(s[i:], wildcardChar) && inIndex:
+			wildcard = matchIndexWildcardRegex
+		case strings.HasPrefix(s[i:], wildcardChar):
+			wildcard = matchSegmentWildcardRegex
+		default:
+			switch s[i] {
+			case '[':
+				inIndex = true
+			case ']':
+				inIndex = false
+			}
+			b.WriteByte(s[i])
+			continue
+		}
+		literals = append(literals, b.String())
+		wildcards = append(wildcards, wildcard)
+		b.Reset()
+	}
+	literals = append(literals, b.String())
+	return literals, wildcards
+}
+
+// moduleAddressRegex matches a module address such as `module.foo` or
+// `module.foo["bar"].module.baz[0]`.
+var moduleAddressRegex = regexp.MustCompile(`^module\.[A-Za-z0-9_-]+(\[[^\]]*\])?(\.module\.[A-Za-z0-9_-]+(\[[^\]]*\])?)*$`)
+
+// isDataAddress returns true if a given resource address is a data source,
+// that is, it begins with `data.` after the module path if any.
+// (e.g.) `data.aws_ami.foo`, `module.foo.data.aws_ami.foo`
+func isDataAddress(address string) bool {
+	return strings.HasPrefix(strings.TrimPrefix(address, modulePrefixRegex.FindString(address)), "data.")
+}
+
+// targetsData returns true if a given source pattern explicitly targets data
+// sources, that is, it begins with `data.` or contains a `.data.` segment.
+// (e.g.) `data.*`, `module.*.data.aws_ami.*`
+// If regex is true, it returns true if the source contains an escaped `data\.`.
+func targetsData(source string, caseInsensitive bool, regex bool) bool {
+	if caseInsensitive {
+		source = strings.ToLower(source)
+	}
+	if regex {
+		return strings.Contains(source, `data\.`)
+	}
+	return strings.HasPrefix(source, "data.") || strings.Contains(source, ".data.")
+}
+
+// withinModule returns true if a given address is directly in the module
+// given by the --within-module flag, not in the other modules nor its child
+// modules. It always returns true if the flag is not set.
+func (e *xmvExpander) withinModule(address string) bool {
+	if len(e.action.withinModule) == 0 {
+		return true
+	}
+	module := strings.TrimSuffix(modulePrefixRegex.FindString(address), ".")
+	if e.action.caseInsensitive {
+		return strings.EqualFold(module, e.action.withinModule)
+	}
+	return module == e.action.withinModule
+}
+
+// stateListAddresses returns addresses to filter terraform state list so that
+// terraform only lists a relevant subtree of the state.
+// The address is the longest module address in the source before the first
+// wildcard, or the module given by the --within-module flag. If neither is
+// available, it returns nil, which means listing the entire state.
+// (e.g.) `module.foo.null_resource.*` => `module.foo`
+// In case-insensitive matching, it always returns nil because terraform
+// filters addresses case-sensitively.
+// In regex mode, the source is not inspected and only the module given by the
+// --within-module flag is used.
+func (e *xmvExpander) stateListAddresses() []string {
+	if e.action.caseInsensitive {
+		return nil
+	}
+
+	if e.action.regex {
+		if len(e.action.withinModule) > 0 {
+			return []string{e.action.withinModule}
+		}
+		return nil
+	}
+
+	i := strings.Index(e.action.source, wildcardChar)
+	if i == -1 {
+		return nil
+	}
+
+	// find the longest module address in the prefix ending at a dot.
+	prefix := e.action.source[:i]
+	for j := strings.LastIndex(prefix, "."); j != -1; j = strings.LastIndex(prefix[:j], ".") {
+		address := prefix[:j]
+		if moduleAddressRegex.MatchString(address) {
+			return []string{address}
+		}
+	}
+
+	if len(e.action.withinModule) > 0 {
+		return []string{e.action.withinModule}
+	}
+	return nil
 }
 
 // makeSrcRegex returns a regex that will do matching based on the wildcard
 // source that was given.
-func makeSrcRegex(source string) (*regexp.Regexp, error) {
-	regPattern := makeSourceMatchPattern(source)
+// If caseInsensitive is true, the regex is compiled with the `(?i)` flag.
+// Note that the captured groups still have the original casing in the state,
+// so that placeholders in the destination reproduce it.
+// If singleSegment is true, a single wildcard matches only one segment.
+func makeSrcRegex(source string, caseInsensitive bool, singleSegment bool) (*regexp.Regexp, error) {
+	regPattern := makeSourceMatchPattern(source, singleSegment)
+	if caseInsensitive {
+		regPattern = "(?i)" + regPattern
+	}
 	regExpression, err := regexp.Compile(regPattern)
 	if err != nil {
 		return nil, fmt.Errorf("could not make pattern out of %s (%s) due to %s", source, regPattern, err)
@@ -42,9 +295,87 @@ func makeSrcRegex(source string) (*regexp.Regexp, error) {
 	return regExpression, nil
 }
 
+// makeRegex returns a regex for a given source or exclude pattern of the
+// action. In regex mode, the pattern is a regular expression anchored to the
+// start and end so that it only matches whole addresses. Otherwise, it has the
+// wildcard grammar.
+func (e *xmvExpander) makeRegex(pattern string) (*regexp.Regexp, error) {
+	if !e.action.regex {
+		return makeSrcRegex(pattern, e.action.caseInsensitive, e.action.singleSegment)
+	}
+
+	regPattern := "^(?:" + pattern + ")$"
+	if e.action.caseInsensitive {
+		regPattern = "(?i)" + regPattern
+	}
+	regExpression, err := regexp.Compile(regPattern)
+	if err != nil {
+		return nil, fmt.Errorf("could not compile regex %s due to %s", pattern, err)
+	}
+	return regExpression, nil
+}
+
+// destinationPlaceholderRegex matches a placeholder in the destination such
+// as `$1`, `${1}`, `$name` and `${name}`, or an escaped dollar sign `$$`.
+var destinationPlaceholderRegex = regexp.MustCompile(`\$\$|\$\{(\w+)\}|\$(\w+)`)
+
+// validateRegex validates the source and exclude patterns in regex mode, and
+// that all placeholders in the destination refer to capture groups defined
+// in the source. It's intended to be called at parse time to fail early.
+// It's a no-op unless in regex mode.
+func (e *xmvExpander) validateRegex() error {
+	if !e.action.regex {
+		return nil
+	}
+
+	re, err := e.makeRegex(e.action.source)
+	if err != nil {
+		return err
+	}
+	for _, exclude := range e.action.excludes {
+		if _, err := e.makeRegex(exclude); err != nil {
+			return err
+		}
+	}
+
+	for _, m := range destinationPlaceholderRegex.FindAllStringSubmatch(e.action.destination, -1) {
+		name := m[1] + m[2]
+		if len(name) == 0 {
+			// an escaped dollar sign
+			continue
+		}
+		if n, err := strconv.Atoi(name); err == nil {
+			if n <= re.NumSubexp() {
+				continue
+			}
+		} else if re.SubexpIndex(name) != -1 {
+			continue
+		}
+		return fmt.Errorf("the destination %s refers to an undefined capture group: %s", e.action.destination, name)
+	}
+	return nil
+}
+
+// hasPattern returns true if the source is a pattern to be matched against
+// the state, that is, it contains wildcards or it's a regex.
+func (e *xmvExpander) hasPattern() bool {
+	return e.action.regex || e.nrOfWildcards() > 0
+}
+
 // expand returns actions matching wildcard move actions based on the list of resources.
+// Sources matching any of exclude patterns are skipped.
 func (e *xmvExpander) expand(stateList []string) ([]*StateMvAction, error) {
-	if e.nrOfWildcards() == 0 {
+	if !e.hasPattern() {
+		excluded, err := e.excluded(e.action.source)
+		if err != nil {
+			return nil, err
+		}
+		if excluded || !e.withinModule(e.action.source) {
+			return []*StateMvAction{}, e.checkExpectMatches(0)
+		}
+		if err := e.checkExpectMatches(1); err != nil {
+			return nil, err
+		}
 		staticActionAsList := make([]*StateMvAction, 1)
 		staticActionAsList[0] = NewStateMvAction(e.action.source, e.action.destination)
 		return staticActionAsList, nil
@@ -53,6 +384,9 @@ func (e *xmvExpander) expand(stateList []string) ([]*StateMvAction, error) {
 	if err != nil {
 		return nil, err
 	}
+	if err := e.checkExpectMatches(len(matchingSources)); err != nil {
+		return nil, err
+	}
 	matchingActions := make([]*StateMvAction, len(matchingSources))
 	for i, matchingSource := range matchingSources {
 		destination, e2 := e.getDestinationForStateSrc(matchingSource)
@@ -64,36 +398,131 @@ func (e *xmvExpander) expand(stateList []string) ([]*StateMvAction, error) {
 	return matchingActions, nil
 }
 
-// nrOfWildcards counts a number of wildcard characters.
+// nrOfWildcards counts a number of wildcards.
+// A deepWildcardToken is counted as a single wildcard.
 func (e *xmvExpander) nrOfWildcards() int {
-	return strings.Count(e.action.source, wildcardChar)
+	deep := strings.Count(e.action.source, deepWildcardToken)
+	single := strings.Count(strings.ReplaceAll(e.action.source, deepWildcardToken, ""), wildcardChar)
+	return deep + single
 }
 
 // getMatchingSourcesFromState looks into the state and find sources that match
 // pattern with wildcards.
 func (e *xmvExpander) getMatchingSourcesFromState(stateList []string) ([]string, error) {
-	re, err := makeSrcRegex(e.action.source)
+	re, err := e.makeRegex(e.action.source)
 	if err != nil {
 		return nil, err
 	}
 
+	// Moving data sources in state is rarely intended, so wildcards don't match
+	// them unless the source explicitly targets them or the flag is set.
+	skipData := !e.action.includeData && !targetsData(e.action.source, e.action.caseInsensitive, e.action.regex)
+
 	var matchingStateSources []string
 
 	for _, s := range stateList {
 		match := re.FindString(s)
-		if match != "" {
-			matchingStateSources = append(matchingStateSources, match)
+		if match == "" {
+			continue
 		}
+		if skipData && isDataAddress(match) {
+			log.Printf("[INFO] [migrator] xmv: skipping a data source matched by %s: %s. Use %s to move data sources\n", e.action.source, match, includeDataFlag)
+			continue
+		}
+		if !e.withinModule(match) {
+			log.Printf("[DEBUG] [migrator] xmv: skipping a source matched by %s but not directly in %s: %s\n", e.action.source, e.action.withinModule, match)
+			continue
+		}
+		excluded, err := e.excluded(match)
+		if err != nil {
+			return nil, err
+		}
+		if excluded {
+			continue
+		}
+		matchingStateSources = append(matchingStateSources, match)
 	}
 	return matchingStateSources, err
 }
 
+// checkExpectMatches returns an error if a given number of matched sources
+// differs from the expected one set by the --expect-matches flag.
+// It guards against a wildcard which unexpectedly matches more or fewer
+// resources than intended, before any move is applied.
+func (e *xmvExpander) checkExpectMatches(n int) error {
+	if e.action.expectMatches == nil || *e.action.expectMatches == n {
+		return nil
+	}
+	return fmt.Errorf("xmv %s matched %d resources, but %s%d is given", e.action.source, n, expectMatchesFlagPrefix, *e.action.expectMatches)
+}
+
+// excluded returns true if a given source matches any of exclude patterns.
+// The exclude patterns have the same grammar as the source, that is, regular
+// expressions in regex mode or wildcards otherwise, and are also matched
+// case-insensitively if the action is case-insensitive.
+func (e *xmvExpander) excluded(source string) (bool, error) {
+	for _, exclude := range e.action.excludes {
+		re, err := e.makeRegex(exclude)
+		if err != nil {
+			return false, err
+		}
+		if re.MatchString(source) {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
 // getDestinationForStateSrc returns the destination for a source.
+// In regex mode, named capture groups can be referenced by `${name}`.
 func (e *xmvExpander) getDestinationForStateSrc(stateSource string) (string, error) {
-	re, err := makeSrcRegex(e.action.source)
+	re, err := e.makeRegex(e.action.source)
 	if err != nil {
 		return "", err
 	}
 	destination := re.ReplaceAllString(stateSource, e.action.destination)
 	return destination, err
 }
+
+// XmvMove is a pair of addresses of a move generated by an xmv action.
+type XmvMove struct {
+	// Source is an address to be moved.
+	Source string
+	// Destination is a new address to move.
+	Destination string
+}
+
+// ExpandXmv expands an xmv action against a given list of addresses without
+// running terraform. It's intended for previewing the moves when authoring a
+// wildcard pattern. The args are the same as the ones of xmv action, that is,
+// `[--case-insensitive] [--include-data] [--exclude=<pattern>]... [--expect-matches=<n>] [--within-module=<module>] [--regex] [--single-segment] <source> <destination>`.
+func ExpandXmv(args []string, stateList []string) ([]XmvMove, error) {
+	src, dst, flags, ok := parseXmvArgs(args)
+	if !ok {
+		return nil, fmt.Errorf("xmv arguments are invalid: %s", strings.Join(args, " "))
+	}
+	a := NewStateXmvAction(src, dst)
+	a.caseInsensitive = flags.caseInsensitive
+	a.excludes = flags.excludes
+	a.includeData = flags.includeData
+	a.expectMatches = flags.expectMatches
+	a.withinModule = flags.withinModule
+	a.regex = flags.regex
+	a.singleSegment = flags.singleSegment
+
+	e := newXmvExpander(a)
+	if err := e.validateRegex(); err != nil {
+		return nil, fmt.Errorf("xmv arguments are invalid: %s, err: %s", strings.Join(args, " "), err)
+	}
+
+	actions, err := e.expand(stateList)
+	if err != nil {
+		return nil, err
+	}
+
+	moves := make([]XmvMove, len(actions))
+	for i, action := range actions {
+		moves[i] = XmvMove{Source: action.source, Destination: action.destination}
+	}
+	return moves, nil
+}
@@ -23,6 +23,11 @@ func TestGetNrOfWildcard(t *testing.T) {
 			action: NewStateXmvAction("null_resource.*", "null_resource.$1"),
 			want:   1,
 		},
+		{
+			desc:   "deep wildcard token is counted as a single wildcard",
+			action: NewStateXmvAction("module.**.null_resource.*", "module.$1.null_resource.$2"),
+			want:   2,
+		},
 	}
 
 	for _, tc := range cases {
@@ -155,6 +160,358 @@ func TestXmvExpanderExpand(t *testing.T) {
 				},
 			},
 		},
+		{
+			desc: "deep wildcard token matches resources at any nesting depth",
+			stateList: []string{
+				"aws_instance.root",
+				"module.a.aws_instance.foo",
+				"module.a.module.b.aws_instance.bar",
+				"module.a.module.b.module.c[\"x\"].aws_instance.baz",
+				"module.a.aws_security_group.qux",
+			},
+			inputXMvAction: &StateXmvAction{
+				source:      "module.**.aws_instance.*",
+				destination: "module.$1.aws_instance.${2}_new",
+			},
+			outputMvActions: []*StateMvAction{
+				{
+					source:      "module.a.aws_instance.foo",
+					destination: "module.a.aws_instance.foo_new",
+				},
+				{
+					source:      "module.a.module.b.aws_instance.bar",
+					destination: "module.a.module.b.aws_instance.bar_new",
+				},
+				{
+					source:      "module.a.module.b.module.c[\"x\"].aws_instance.baz",
+					destination: "module.a.module.b.module.c[\"x\"].aws_instance.baz_new",
+				},
+			},
+		},
+		{
+			desc: "deep wildcard token used with a single wildcard in a module key",
+			stateList: []string{
+				"module.a.module.b[\"foo\"].null_resource.this",
+				"module.a.module.c.module.b[\"bar\"].null_resource.this",
+			},
+			inputXMvAction: &StateXmvAction{
+				source:      "module.**.module.b[\"*\"].null_resource.this",
+				destination: "module.$1.module.b2[\"$2\"].null_resource.this",
+			},
+			outputMvActions: []*StateMvAction{
+				{
+					source:      "module.a.module.b[\"foo\"].null_resource.this",
+					destination: "module.a.module.b2[\"foo\"].null_resource.this",
+				},
+				{
+					source:      "module.a.module.c.module.b[\"bar\"].null_resource.this",
+					destination: "module.a.module.c.module.b2[\"bar\"].null_resource.this",
+				},
+			},
+		},
+		{
+			desc: "wildcard does not match a part of a longer address",
+			stateList: []string{
+				"aws_instance.web",
+				"aws_instance.webapp",
+				"module.foo.aws_instance.web",
+				"data.aws_instance.web",
+			},
+			inputXMvAction: &StateXmvAction{
+				source:      "aws_instance.web*",
+				destination: "aws_instance.api$1",
+			},
+			outputMvActions: []*StateMvAction{
+				{
+					source:      "aws_instance.web",
+					destination: "aws_instance.api",
+				},
+				{
+					source:      "aws_instance.webapp",
+					destination: "aws_instance.apiapp",
+				},
+			},
+		},
+		{
+			desc: "wildcard in the middle does not match near-miss addresses",
+			stateList: []string{
+				"aws_s3_bucket.log",
+				"aws_s3_bucket.logs",
+				"aws_s3_bucket_policy.log",
+				"module.foo.aws_s3_bucket.log",
+			},
+			inputXMvAction: &StateXmvAction{
+				source:      "aws_s3_*.log",
+				destination: "module.s3.aws_s3_$1.log",
+			},
+			outputMvActions: []*StateMvAction{
+				{
+					source:      "aws_s3_bucket.log",
+					destination: "module.s3.aws_s3_bucket.log",
+				},
+				{
+					source:      "aws_s3_bucket_policy.log",
+					destination: "module.s3.aws_s3_bucket_policy.log",
+				},
+			},
+		},
+		{
+			desc: "case-sensitive by default",
+			stateList: []string{
+				"aws_instance.web_1",
+				"aws_instance.Web_2",
+			},
+			inputXMvAction: &StateXmvAction{
+				source:      "aws_instance.Web*",
+				destination: "module.web.aws_instance.Web$1",
+			},
+			outputMvActions: []*StateMvAction{
+				{
+					source:      "aws_instance.Web_2",
+					destination: "module.web.aws_instance.Web_2",
+				},
+			},
+		},
+		{
+			desc: "case-insensitive with mixed-case addresses",
+			stateList: []string{
+				"aws_instance.web_1",
+				"aws_instance.Web_2",
+				"aws_instance.WEB_Db",
+				"aws_instance.app_1",
+			},
+			inputXMvAction: &StateXmvAction{
+				source:          "aws_instance.Web*",
+				destination:     "module.web.aws_instance.web$1",
+				caseInsensitive: true,
+			},
+			outputMvActions: []*StateMvAction{
+				{
+					source:      "aws_instance.web_1",
+					destination: "module.web.aws_instance.web_1",
+				},
+				{
+					source:      "aws_instance.Web_2",
+					destination: "module.web.aws_instance.web_2",
+				},
+				{
+					source:      "aws_instance.WEB_Db",
+					destination: "module.web.aws_instance.web_Db",
+				},
+			},
+		},
+		{
+			desc: "case-insensitive placeholders reproduce the original casing",
+			stateList: []string{
+				"module.Foo.aws_instance.Web_A",
+				"module.foo.aws_instance.web_b",
+			},
+			inputXMvAction: &StateXmvAction{
+				source:          "module.FOO.aws_instance.*",
+				destination:     "aws_instance.$1",
+				caseInsensitive: true,
+			},
+			outputMvActions: []*StateMvAction{
+				{
+					source:      "module.Foo.aws_instance.Web_A",
+					destination: "aws_instance.Web_A",
+				},
+				{
+					source:      "module.foo.aws_instance.web_b",
+					destination: "aws_instance.web_b",
+				},
+			},
+		},
+		{
+			desc: "exclude a resource",
+			stateList: []string{
+				"aws_instance.app_1",
+				"aws_instance.app_2",
+				"aws_instance.bastion",
+			},
+			inputXMvAction: &StateXmvAction{
+				source:      "aws_instance.*",
+				destination: "module.app.aws_instance.$1",
+				excludes:    []string{"aws_instance.bastion"},
+			},
+			outputMvActions: []*StateMvAction{
+				{
+					source:      "aws_instance.app_1",
+					destination: "module.app.aws_instance.app_1",
+				},
+				{
+					source:      "aws_instance.app_2",
+					destination: "module.app.aws_instance.app_2",
+				},
+			},
+		},
+		{
+			desc: "overlapping excludes with wildcards",
+			stateList: []string{
+				"aws_instance.app_1",
+				"aws_instance.app_2",
+				"aws_instance.app_db",
+				"aws_instance.bastion",
+				"module.foo.aws_instance.app_3",
+			},
+			inputXMvAction: &StateXmvAction{
+				source:      "aws_instance.app*",
+				destination: "module.app.aws_instance.app$1",
+				excludes:    []string{"aws_instance.app_d*", "aws_instance.*_db", "aws_instance.*_2"},
+			},
+			outputMvActions: []*StateMvAction{
+				{
+					source:      "aws_instance.app_1",
+					destination: "module.app.aws_instance.app_1",
+				},
+			},
+		},
+		{
+			desc: "exclude everything",
+			stateList: []string{
+				"aws_instance.app_1",
+				"aws_instance.app_2",
+			},
+			inputXMvAction: &StateXmvAction{
+				source:      "aws_instance.*",
+				destination: "module.app.aws_instance.$1",
+				excludes:    []string{"aws_instance.*"},
+			},
+			outputMvActions: []*StateMvAction{},
+		},
+		{
+			desc: "case-insensitive excludes",
+			stateList: []string{
+				"aws_instance.app_1",
+				"aws_instance.Bastion",
+			},
+			inputXMvAction: &StateXmvAction{
+				source:          "aws_instance.*",
+				destination:     "module.app.aws_instance.$1",
+				caseInsensitive: true,
+				excludes:        []string{"aws_instance.bastion"},
+			},
+			outputMvActions: []*StateMvAction{
+				{
+					source:      "aws_instance.app_1",
+					destination: "module.app.aws_instance.app_1",
+				},
+			},
+		},
+		{
+			desc: "wildcards don't match data sources by default",
+			stateList: []string{
+				"aws_instance.foo",
+				"data.aws_ami.foo",
+				"module.bar.aws_instance.foo",
+				"module.bar.data.aws_ami.foo",
+				"module.data.aws_instance.foo",
+			},
+			inputXMvAction: &StateXmvAction{
+				source:      "*",
+				destination: "module.app.$1",
+			},
+			outputMvActions: []*StateMvAction{
+				{
+					source:      "aws_instance.foo",
+					destination: "module.app.aws_instance.foo",
+				},
+				{
+					source:      "module.bar.aws_instance.foo",
+					destination: "module.app.module.bar.aws_instance.foo",
+				},
+				{
+					source:      "module.data.aws_instance.foo",
+					destination: "module.app.module.data.aws_instance.foo",
+				},
+			},
+		},
+		{
+			desc: "wildcards match data sources with include-data",
+			stateList: []string{
+				"aws_instance.foo",
+				"data.aws_ami.foo",
+				"module.bar.data.aws_ami.foo",
+			},
+			inputXMvAction: &StateXmvAction{
+				source:      "*",
+				destination: "module.app.$1",
+				includeData: true,
+			},
+			outputMvActions: []*StateMvAction{
+				{
+					source:      "aws_instance.foo",
+					destination: "module.app.aws_instance.foo",
+				},
+				{
+					source:      "data.aws_ami.foo",
+					destination: "module.app.data.aws_ami.foo",
+				},
+				{
+					source:      "module.bar.data.aws_ami.foo",
+					destination: "module.app.module.bar.data.aws_ami.foo",
+				},
+			},
+		},
+		{
+			desc: "a source explicitly targets data sources",
+			stateList: []string{
+				"aws_instance.foo",
+				"data.aws_ami.foo",
+				"module.bar.data.aws_ami.foo",
+			},
+			inputXMvAction: &StateXmvAction{
+				source:      "data.*",
+				destination: "module.app.data.$1",
+			},
+			outputMvActions: []*StateMvAction{
+				{
+					source:      "data.aws_ami.foo",
+					destination: "module.app.data.aws_ami.foo",
+				},
+			},
+		},
+		{
+			desc: "a source explicitly targets data sources in modules",
+			stateList: []string{
+				"module.bar.aws_instance.foo",
+				"module.bar.data.aws_ami.foo",
+			},
+			inputXMvAction: &StateXmvAction{
+				source:      "module.*.data.aws_ami.foo",
+				destination: "module.$1.data.aws_ami.bar",
+			},
+			outputMvActions: []*StateMvAction{
+				{
+					source:      "module.bar.data.aws_ami.foo",
+					destination: "module.bar.data.aws_ami.bar",
+				},
+			},
+		},
+		{
+			desc:      "a static data source",
+			stateList: nil,
+			inputXMvAction: &StateXmvAction{
+				source:      "data.aws_ami.foo",
+				destination: "data.aws_ami.bar",
+			},
+			outputMvActions: []*StateMvAction{
+				{
+					source:      "data.aws_ami.foo",
+					destination: "data.aws_ami.bar",
+				},
+			},
+		},
+		{
+			desc:      "exclude a static source",
+			stateList: []string{"aws_instance.bastion"},
+			inputXMvAction: &StateXmvAction{
+				source:      "aws_instance.bastion",
+				destination: "module.app.aws_instance.bastion",
+				excludes:    []string{"aws_instance.*"},
+			},
+			outputMvActions: []*StateMvAction{},
+		},
 	}
 
 	for _, tc := range cases {
@@ -176,3 +533,604 @@ func TestXmvExpanderExpand(t *testing.T) {
 		})
 	}
 }
+
+func intPtr(n int) *int {
+	return &n
+}
+
+func TestXmvExpanderExpandWithExpectMatches(t *testing.T) {
+	stateList := []string{
+		"aws_instance.foo",
+		"aws_instance.bar",
+		"aws_instance.baz",
+	}
+	cases := []struct {
+		desc          string
+		source        string
+		excludes      []string
+		expectMatches *int
+		want          int
+		ok            bool
+	}{
+		{
+			desc:          "not set",
+			source:        "aws_instance.*",
+			expectMatches: nil,
+			want:          3,
+			ok:            true,
+		},
+		{
+			desc:          "exact matches",
+			source:        "aws_instance.*",
+			expectMatches: intPtr(3),
+			want:          3,
+			ok:            true,
+		},
+		{
+			desc:          "over matches",
+			source:        "aws_instance.*",
+			expectMatches: intPtr(2),
+			ok:            false,
+		},
+		{
+			desc:          "under matches",
+			source:        "aws_instance.*",
+			expectMatches: intPtr(4),
+			ok:            false,
+		},
+		{
+			desc:          "exact matches with excludes",
+			source:        "aws_instance.*",
+			excludes:      []string{"aws_instance.ba*"},
+			expectMatches: intPtr(1),
+			want:          1,
+			ok:            true,
+		},
+		{
+			desc:          "expect no matches",
+			source:        "aws_db_instance.*",
+			expectMatches: intPtr(0),
+			want:          0,
+			ok:            true,
+		},
+		{
+			desc:          "static source",
+			source:        "aws_instance.foo",
+			expectMatches: intPtr(1),
+			want:          1,
+			ok:            true,
+		},
+		{
+			desc:          "static source excluded",
+			source:        "aws_instance.foo",
+			excludes:      []string{"aws_instance.*"},
+			expectMatches: intPtr(1),
+			ok:            false,
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.desc, func(t *testing.T) {
+			a := NewStateXmvAction(tc.source, "module.app.aws_instance.$1")
+			a.excludes = tc.excludes
+			a.expectMatches = tc.expectMatches
+			got, err := newXmvExpander(a).expand(stateList)
+			if tc.ok && err != nil {
+				t.Fatalf("unexpected err: %s", err)
+			}
+			if !tc.ok && err == nil {
+				t.Fatalf("expected to return an error, but no error, got: %s", spew.Sdump(got))
+			}
+			if tc.ok && len(got) != tc.want {
+				t.Errorf("got: %d actions, want: %d", len(got), tc.want)
+			}
+		})
+	}
+}
+
+func TestMakeSourceMatchPattern(t *testing.T) {
+	cases := []struct {
+		desc          string
+		source        string
+		singleSegment bool
+		want          string
+	}{
+		{
+			desc:   "simple wildcard",
+			source: "null_resource.*",
+			want:   `^null_resource\.(.*)$`,
+		},
+		{
+			desc:   "deep wildcard",
+			source: "module.**.null_resource.*",
+			want:   `^module\.(.*)\.null_resource\.(.*)$`,
+		},
+		{
+			desc:   "meta characters are quoted",
+			source: `module.foo["*"]`,
+			want:   `^module\.foo\["(.*)"\]$`,
+		},
+		{
+			desc:          "simple wildcard with single segment",
+			source:        "null_resource.*",
+			singleSegment: true,
+			want:          `^null_resource\.((?:[^.\[]|\[[^\]]*\])*)$`,
+		},
+		{
+			desc:          "deep wildcard with single segment",
+			source:        "module.**.null_resource.*",
+			singleSegment: true,
+			want:          `^module\.(.*)\.null_resource\.((?:[^.\[]|\[[^\]]*\])*)$`,
+		},
+		{
+			desc:          "wildcard in an index key with single segment",
+			source:        `module.foo["*"]`,
+			singleSegment: true,
+			want:          `^module\.foo\["([^\]]*)"\]$`,
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.desc, func(t *testing.T) {
+			got := makeSourceMatchPattern(tc.source, tc.singleSegment)
+			if got != tc.want {
+				t.Errorf("got: %s, want: %s", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestXmvExpanderStateListAddresses(t *testing.T) {
+	cases := []struct {
+		desc   string
+		action *StateXmvAction
+		want   []string
+	}{
+		{
+			desc:   "no wildcard",
+			action: NewStateXmvAction("module.foo.null_resource.bar", "module.foo.null_resource.baz"),
+			want:   nil,
+		},
+		{
+			desc:   "resource in root module",
+			action: NewStateXmvAction("null_resource.*", "null_resource.$1_new"),
+			want:   nil,
+		},
+		{
+			desc:   "resource in a module",
+			action: NewStateXmvAction("module.foo.null_resource.*", "module.bar.null_resource.$1"),
+			want:   []string{"module.foo"},
+		},
+		{
+			desc:   "resource in a nested module with index",
+			action: NewStateXmvAction(`module.foo["a"].module.bar[0].null_resource.*`, "null_resource.$1"),
+			want:   []string{`module.foo["a"].module.bar[0]`},
+		},
+		{
+			desc:   "wildcard in a module key",
+			action: NewStateXmvAction(`module.foo["*"].null_resource.bar`, `module.baz["$1"].null_resource.bar`),
+			want:   nil,
+		},
+		{
+			desc:   "wildcard in a nested module key",
+			action: NewStateXmvAction(`module.foo.module.bar["*"].null_resource.bar`, `module.baz["$1"].null_resource.bar`),
+			want:   []string{"module.foo"},
+		},
+		{
+			desc:   "deep wildcard",
+			action: NewStateXmvAction("module.**.null_resource.*", "module.$1.null_resource.$2"),
+			want:   nil,
+		},
+		{
+			desc:   "match all",
+			action: NewStateXmvAction("*", "$1"),
+			want:   nil,
+		},
+		{
+			desc: "within module",
+			action: &StateXmvAction{
+				source:       "module.*.null_resource.*",
+				destination:  "module.bar.null_resource.$2",
+				withinModule: "module.foo",
+			},
+			want: []string{"module.foo"},
+		},
+		{
+			desc: "regex",
+			action: &StateXmvAction{
+				source:      `module\.foo\.null_resource\.(.*)`,
+				destination: "module.bar.null_resource.$1",
+				regex:       true,
+			},
+			want: nil,
+		},
+		{
+			desc: "regex within module",
+			action: &StateXmvAction{
+				source:       `module\.foo\.null_resource\.(.*)`,
+				destination:  "module.bar.null_resource.$1",
+				withinModule: "module.foo",
+				regex:        true,
+			},
+			want: []string{"module.foo"},
+		},
+		{
+			desc: "within module and a longer module address in source",
+			action: &StateXmvAction{
+				source:       "module.foo.module.bar.null_resource.*",
+				destination:  "module.bar.null_resource.$1",
+				withinModule: "module.foo.module.bar",
+			},
+			want: []string{"module.foo.module.bar"},
+		},
+		{
+			desc: "case-insensitive resource in a module",
+			action: &StateXmvAction{
+				source:          "module.Foo.null_resource.*",
+				destination:     "module.bar.null_resource.$1",
+				caseInsensitive: true,
+			},
+			want: nil,
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.desc, func(t *testing.T) {
+			e := newXmvExpander(tc.action)
+			got := e.stateListAddresses()
+			if diff := cmp.Diff(got, tc.want); diff != "" {
+				t.Errorf("got: %#v, want: %#v, diff: %s", got, tc.want, diff)
+			}
+		})
+	}
+}
+
+func TestXmvExpanderExpandWithinModule(t *testing.T) {
+	// Resources with the same name exist in different modules.
+	stateList := []string{
+		"aws_subnet.private",
+		"module.network.aws_subnet.private",
+		"module.network.aws_subnet.public",
+		"module.network2.aws_subnet.private",
+		"module.network.module.child.aws_subnet.private",
+		"module.app.module.network.aws_subnet.private",
+		`module.network["a"].aws_subnet.private`,
+	}
+	cases := []struct {
+		desc   string
+		action *StateXmvAction
+		want   []string
+	}{
+		{
+			desc:   "a literal module prefix doesn't match the other modules",
+			action: NewStateXmvAction("module.network.aws_subnet.*", "module.vpc.aws_subnet.$1"),
+			want: []string{
+				"module.network.aws_subnet.private",
+				"module.network.aws_subnet.public",
+			},
+		},
+		{
+			desc:   "a wildcard can match child modules without within-module",
+			action: NewStateXmvAction("module.network.*", "module.vpc.$1"),
+			want: []string{
+				"module.network.aws_subnet.private",
+				"module.network.aws_subnet.public",
+				"module.network.module.child.aws_subnet.private",
+			},
+		},
+		{
+			desc: "within-module excludes child modules",
+			action: &StateXmvAction{
+				source:       "module.network.*",
+				destination:  "module.vpc.$1",
+				withinModule: "module.network",
+			},
+			want: []string{
+				"module.network.aws_subnet.private",
+				"module.network.aws_subnet.public",
+			},
+		},
+		{
+			desc: "within-module scopes a wildcard module",
+			action: &StateXmvAction{
+				source:       "**.aws_subnet.private",
+				destination:  "$1.aws_subnet.main",
+				withinModule: "module.network",
+			},
+			want: []string{
+				"module.network.aws_subnet.private",
+			},
+		},
+		{
+			desc: "within-module with a module key",
+			action: &StateXmvAction{
+				source:       "**.aws_subnet.*",
+				destination:  "$1.aws_subnet.$2",
+				withinModule: `module.network["a"]`,
+			},
+			want: []string{
+				`module.network["a"].aws_subnet.private`,
+			},
+		},
+		{
+			desc: "within-module case-insensitively",
+			action: &StateXmvAction{
+				source:          "module.*.aws_subnet.private",
+				destination:     "module.$1.aws_subnet.main",
+				withinModule:    "module.Network",
+				caseInsensitive: true,
+			},
+			want: []string{
+				"module.network.aws_subnet.private",
+			},
+		},
+		{
+			desc: "a static source out of the module",
+			action: &StateXmvAction{
+				source:       "module.network2.aws_subnet.private",
+				destination:  "module.vpc.aws_subnet.private",
+				withinModule: "module.network",
+			},
+			want: []string{},
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.desc, func(t *testing.T) {
+			actions, err := newXmvExpander(tc.action).expand(stateList)
+			if err != nil {
+				t.Fatalf("unexpected err: %s", err)
+			}
+			got := []string{}
+			for _, a := range actions {
+				got = append(got, a.source)
+			}
+			if diff := cmp.Diff(got, tc.want); diff != "" {
+				t.Errorf("got: %#v, want: %#v, diff: %s", got, tc.want, diff)
+			}
+		})
+	}
+}
+
+func TestXmvExpanderExpandWithRegex(t *testing.T) {
+	stateList := []string{
+		"aws_instance.web_0",
+		"aws_instance.web_1",
+		"aws_instance.web_old",
+		"aws_instance.Web_2",
+		"module.app.aws_instance.web_3",
+		"data.aws_instance.web_4",
+	}
+	cases := []struct {
+		desc   string
+		action *StateXmvAction
+		want   []*StateMvAction
+	}{
+		{
+			desc: "named capture groups",
+			action: &StateXmvAction{
+				source:      `aws_instance\.web_(?P<index>[0-9]+)`,
+				destination: "module.web.aws_instance.this[${index}]",
+				regex:       true,
+			},
+			want: []*StateMvAction{
+				NewStateMvAction("aws_instance.web_0", "module.web.aws_instance.this[0]"),
+				NewStateMvAction("aws_instance.web_1", "module.web.aws_instance.this[1]"),
+			},
+		},
+		{
+			desc: "numbered capture groups and wildcard characters are not special",
+			action: &StateXmvAction{
+				source:      `(.+)\.aws_instance\.web_([0-9]+)`,
+				destination: "$1.aws_instance.web[$2]",
+				regex:       true,
+			},
+			want: []*StateMvAction{
+				NewStateMvAction("module.app.aws_instance.web_3", "module.app.aws_instance.web[3]"),
+			},
+		},
+		{
+			desc: "case-insensitive with excludes",
+			action: &StateXmvAction{
+				source:          `aws_instance\.web_(?P<name>.+)`,
+				destination:     "aws_instance.${name}",
+				regex:           true,
+				caseInsensitive: true,
+				excludes:        []string{`aws_instance\.web_[0-9]`},
+			},
+			want: []*StateMvAction{
+				NewStateMvAction("aws_instance.web_old", "aws_instance.old"),
+			},
+		},
+		{
+			desc: "data sources are matched only if targeted",
+			action: &StateXmvAction{
+				source:      `(?:data\.)?aws_instance\.web_(?P<index>4)`,
+				destination: "aws_instance.web[${index}]",
+				regex:       true,
+			},
+			want: []*StateMvAction{
+				NewStateMvAction("data.aws_instance.web_4", "aws_instance.web[4]"),
+			},
+		},
+		{
+			desc: "a regex without meta characters is still matched against the state",
+			action: &StateXmvAction{
+				source:      `aws_instance.web_9`,
+				destination: "aws_instance.web_10",
+				regex:       true,
+			},
+			want: []*StateMvAction{},
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.desc, func(t *testing.T) {
+			got, err := newXmvExpander(tc.action).expand(stateList)
+			if err != nil {
+				t.Fatalf("unexpected err: %s", err)
+			}
+			if got == nil {
+				got = []*StateMvAction{}
+			}
+			if diff := cmp.Diff(got, tc.want, cmp.AllowUnexported(StateMvAction{})); diff != "" {
+				t.Errorf("got: %#v, want: %#v, diff: %s", got, tc.want, diff)
+			}
+		})
+	}
+}
+
+func TestXmvExpanderValidateRegex(t *testing.T) {
+	cases := []struct {
+		desc   string
+		action *StateXmvAction
+		ok     bool
+	}{
+		{
+			desc:   "not regex",
+			action: NewStateXmvAction("aws_instance.(", "aws_instance.${foo}"),
+			ok:     true,
+		},
+		{
+			desc: "named and numbered groups",
+			action: &StateXmvAction{
+				source:      `(?P<type>[a-z_]+)\.(?P<name>.+)`,
+				destination: "module.${type}.$type.${2}_$$",
+				regex:       true,
+			},
+			ok: true,
+		},
+		{
+			desc: "invalid source",
+			action: &StateXmvAction{
+				source:      "aws_instance.(",
+				destination: "aws_instance.foo",
+				regex:       true,
+			},
+			ok: false,
+		},
+		{
+			desc: "invalid exclude",
+			action: &StateXmvAction{
+				source:      "aws_instance.(.+)",
+				destination: "aws_instance.$1",
+				excludes:    []string{"aws_instance.["},
+				regex:       true,
+			},
+			ok: false,
+		},
+		{
+			desc: "undefined named group",
+			action: &StateXmvAction{
+				source:      "aws_instance.(?P<name>.+)",
+				destination: "aws_instance.${index}",
+				regex:       true,
+			},
+			ok: false,
+		},
+		{
+			desc: "undefined numbered group",
+			action: &StateXmvAction{
+				source:      "aws_instance.(.+)",
+				destination: "aws_instance.${2}",
+				regex:       true,
+			},
+			ok: false,
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.desc, func(t *testing.T) {
+			err := newXmvExpander(tc.action).validateRegex()
+			if tc.ok && err != nil {
+				t.Fatalf("unexpected err: %s", err)
+			}
+			if !tc.ok && err == nil {
+				t.Fatal("expected to return an error, but no error")
+			}
+		})
+	}
+}
+
+// newSingleSegmentXmvAction returns a new StateXmvAction with the
+// --single-segment flag.
+func newSingleSegmentXmvAction(source string, destination string) *StateXmvAction {
+	a := NewStateXmvAction(source, destination)
+	a.singleSegment = true
+	return a
+}
+
+func TestXmvExpanderExpandWildcardSegments(t *testing.T) {
+	stateList := []string{
+		"aws_instance.qux[0]",
+		"module.a.aws_instance.foo",
+		"module.a.module.b.aws_instance.bar",
+		`module.c["x.y"].aws_instance.baz`,
+	}
+	cases := []struct {
+		desc   string
+		action *StateXmvAction
+		want   []*StateMvAction
+	}{
+		{
+			desc:   "a single wildcard matches nested modules by default",
+			action: NewStateXmvAction("module.*.aws_instance.*", "module.$1.aws_instance.${2}_new"),
+			want: []*StateMvAction{
+				NewStateMvAction("module.a.aws_instance.foo", "module.a.aws_instance.foo_new"),
+				NewStateMvAction("module.a.module.b.aws_instance.bar", "module.a.module.b.aws_instance.bar_new"),
+				NewStateMvAction(`module.c["x.y"].aws_instance.baz`, `module.c["x.y"].aws_instance.baz_new`),
+			},
+		},
+		{
+			desc:   "a single wildcard doesn't match nested modules with single segment",
+			action: newSingleSegmentXmvAction("module.*.aws_instance.*", "module.$1.aws_instance.${2}_new"),
+			want: []*StateMvAction{
+				NewStateMvAction("module.a.aws_instance.foo", "module.a.aws_instance.foo_new"),
+				NewStateMvAction(`module.c["x.y"].aws_instance.baz`, `module.c["x.y"].aws_instance.baz_new`),
+			},
+		},
+		{
+			desc:   "a deep wildcard matches nested modules with single segment",
+			action: newSingleSegmentXmvAction("module.**.aws_instance.*", "module.$1.aws_instance.${2}_new"),
+			want: []*StateMvAction{
+				NewStateMvAction("module.a.aws_instance.foo", "module.a.aws_instance.foo_new"),
+				NewStateMvAction("module.a.module.b.aws_instance.bar", "module.a.module.b.aws_instance.bar_new"),
+				NewStateMvAction(`module.c["x.y"].aws_instance.baz`, `module.c["x.y"].aws_instance.baz_new`),
+			},
+		},
+		{
+			desc:   "backreferences in a different order with single segment",
+			action: newSingleSegmentXmvAction("module.*.aws_instance.*", "module.${2}.aws_instance.$1"),
+			want: []*StateMvAction{
+				NewStateMvAction("module.a.aws_instance.foo", "module.foo.aws_instance.a"),
+				NewStateMvAction(`module.c["x.y"].aws_instance.baz`, `module.baz.aws_instance.c["x.y"]`),
+			},
+		},
+		{
+			desc:   "a single wildcard matches an index key with single segment",
+			action: newSingleSegmentXmvAction("aws_instance.*", "module.app.aws_instance.$1"),
+			want: []*StateMvAction{
+				NewStateMvAction("aws_instance.qux[0]", "module.app.aws_instance.qux[0]"),
+			},
+		},
+		{
+			desc:   "a wildcard in an index key matches dots with single segment",
+			action: newSingleSegmentXmvAction(`module.c["*"].aws_instance.baz`, `module.d["$1"].aws_instance.baz`),
+			want: []*StateMvAction{
+				NewStateMvAction(`module.c["x.y"].aws_instance.baz`, `module.d["x.y"].aws_instance.baz`),
+			},
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.desc, func(t *testing.T) {
+			got, err := newXmvExpander(tc.action).expand(stateList)
+			if err != nil {
+				t.Fatalf("unexpected err: %s", err)
+			}
+			if diff := cmp.Diff(got, tc.want, cmp.AllowUnexported(StateMvAction{})); diff != "" {
+				t.Errorf("got: %#v, want: %#v, diff: %s", got, tc.want, diff)
+			}
+		})
+	}
+}